@@ -16,15 +16,61 @@
 
 package config
 
+import "time"
+
 // Config object
 type Config struct {
-	Host string
-	Port int
+	Host  string
+	Port  int
+	Store string // Store is the name of the registered storage engine.
+	Path  string // Path is the data directory of the storage engine.
+
+	// SyncPolicy decides when the write-ahead log of the native and lsm stores is synced:
+	// "commit", "interval" or "none".
+	SyncPolicy         string
+	SyncInterval       time.Duration
+	CheckpointInterval time.Duration
+
+	// GCLifeTime is how long the old versions are kept for the transactions to read.
+	GCLifeTime time.Duration
+	// GCRunInterval is the period to remove the versions older than GCLifeTime, zero disables GC.
+	GCRunInterval time.Duration
+
+	// DumpAST logs the AST of every statement for debugging.
+	DumpAST bool
+
+	// SkipGrantTable accepts every user without checking the password, it's used to recover
+	// the access when the password of root is lost.
+	SkipGrantTable bool
+	// DefaultAuthPlugin is the auth plugin of the users created without IDENTIFIED WITH, and the
+	// plugin the server proposes in the handshake.
+	DefaultAuthPlugin string
+
+	// SSLCA, SSLCert and SSLKey are the paths of the PEM files for TLS of the client connections,
+	// TLS is enabled if SSLCert and SSLKey are given. The certificates of the clients are verified
+	// by SSLCA if it's given.
+	SSLCA   string
+	SSLCert string
+	SSLKey  string
+	// AutoTLS enables TLS with a self-signed certificate generated at startup if SSLCert and SSLKey
+	// aren't given, it's used for development.
+	AutoTLS bool
 }
 
 var defaultConf = Config{
-	Host: "127.0.0.1",
-	Port: 4444,
+	Host:  "127.0.0.1",
+	Port:  4444,
+	Store: "memory",
+	Path:  "/tmp/fedb",
+
+	SyncPolicy:         "commit",
+	SyncInterval:       100 * time.Millisecond,
+	CheckpointInterval: 5 * time.Minute,
+
+	GCLifeTime:    10 * time.Minute,
+	GCRunInterval: 10 * time.Minute,
+
+	DefaultAuthPlugin: "mysql_native_password",
 }
 
 var globalConf = defaultConf
@@ -0,0 +1,345 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/ddl.go
+//
+
+package ddl
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/meta"
+)
+
+var (
+	// TableColumnCountLimit is limit of the number of columns in a table.
+	// It's exported for testing.
+	TableColumnCountLimit = 512
+)
+
+var (
+	errUnsupportedCharset = terror.ClassDDL.New(codeUnsupportedCharset, "unsupported charset %s collate %s")
+	// errUnsupportedDDLOperation is returned for the DDL syntax which is parsed but not supported yet.
+	errUnsupportedDDLOperation = terror.ClassDDL.New(codeUnsupportedDDLOperation, "This version of FeDB doesn't yet support '%s'")
+
+	errKeyColumnDoesNotExits = terror.ClassDDL.New(codeKeyColumnDoesNotExits, mysql.MySQLErrName[mysql.ErrKeyColumnDoesNotExits])
+	errTooManyFields         = terror.ClassDDL.New(codeTooManyFields, "Too many columns")
+	errBlobCantHaveDefault   = terror.ClassDDL.New(codeBlobCantHaveDefault, mysql.MySQLErrName[mysql.ErrBlobCantHaveDefault])
+	errBlobKeyWithoutLength  = terror.ClassDDL.New(codeBlobKeyWithoutLength, "index for BLOB/TEXT column must specify a key length")
+	errJSONUsedAsKey         = terror.ClassDDL.New(codeJSONUsedAsKey, mysql.MySQLErrName[mysql.ErrJSONUsedAsKey])
+
+	// ErrDupKeyName returns for duplicated key name
+	ErrDupKeyName = terror.ClassDDL.New(codeDupKeyName, "duplicate key name")
+	// ErrInvalidOnUpdate returns for invalid ON UPDATE clause.
+	ErrInvalidOnUpdate = terror.ClassDDL.New(codeInvalidOnUpdate, mysql.MySQLErrName[mysql.ErrInvalidOnUpdate])
+	// ErrTooLongIdent returns for too long name of database/table/column/index.
+	ErrTooLongIdent = terror.ClassDDL.New(codeTooLongIdent, mysql.MySQLErrName[mysql.ErrTooLongIdent])
+	// ErrWrongDBName returns for wrong database name.
+	ErrWrongDBName = terror.ClassDDL.New(codeWrongDBName, mysql.MySQLErrName[mysql.ErrWrongDBName])
+	// ErrWrongTableName returns for wrong table name.
+	ErrWrongTableName = terror.ClassDDL.New(codeWrongTableName, mysql.MySQLErrName[mysql.ErrWrongTableName])
+	// ErrWrongColumnName returns for wrong column name.
+	ErrWrongColumnName = terror.ClassDDL.New(codeWrongColumnName, mysql.MySQLErrName[mysql.ErrWrongColumnName])
+	// ErrTableMustHaveColumns returns for missing column when creating a table.
+	ErrTableMustHaveColumns = terror.ClassDDL.New(codeTableMustHaveColumns, mysql.MySQLErrName[mysql.ErrTableMustHaveColumns])
+	// ErrUnknownCharacterSet returns unknown character set.
+	ErrUnknownCharacterSet = terror.ClassDDL.New(codeUnknownCharacterSet, "Unknown character set: '%s'")
+	// ErrPrimaryCantHaveNull returns All parts of a PRIMARY KEY must be NOT NULL; if you need NULL in a key, use UNIQUE instead
+	ErrPrimaryCantHaveNull = terror.ClassDDL.New(codePrimaryCantHaveNull, mysql.MySQLErrName[mysql.ErrPrimaryCantHaveNull])
+	// ErrCantDropFieldOrKey returns for dropping a non-existent field or key.
+	ErrCantDropFieldOrKey = terror.ClassDDL.New(codeCantDropFieldOrKey, "Can't DROP '%s'; check that column/key exists")
+
+	// ErrCantRemoveAllFields returns for deleting all columns.
+	ErrCantRemoveAllFields = terror.ClassDDL.New(codeCantRemoveAllFields, "can't drop only column %s in table %s")
+	// ErrUnsupportedModifyPrimaryKey returns for adding or dropping the primary key.
+	ErrUnsupportedModifyPrimaryKey = terror.ClassDDL.New(codeUnsupportedModifyPrimaryKey, "unsupported %s primary key")
+
+	errIncorrectPrefixKey = terror.ClassDDL.New(codeIncorrectPrefixKey, mysql.MySQLErrName[mysql.ErrWrongSubKey])
+	errTooLongKey         = terror.ClassDDL.New(codeTooLongKey, "Specified key was too long; max key length is %d bytes")
+	errBadField           = terror.ClassDDL.New(codeBadField, "Unknown column '%s' in '%s'")
+
+	errInvalidDDLJob           = terror.ClassDDL.New(codeInvalidDDLJob, "invalid DDL job")
+	errCancelledDDLJob         = terror.ClassDDL.New(codeCancelledDDLJob, "cancelled DDL job")
+	errInvalidJobState         = terror.ClassDDL.New(codeInvalidJobState, "invalid schema state %v")
+	errRunMultiSchemaChanges   = terror.ClassDDL.New(codeRunMultiSchemaChanges, "can't run multi schema change")
+	errCantDropColWithIndex    = terror.ClassDDL.New(codeCantDropColWithIndex, "can't drop column %s with index covered now")
+	errUnsupportedAddColumn    = terror.ClassDDL.New(codeUnsupportedAddColumn, "unsupported add column %s constraint %v")
+	errUnsupportedModifyColumn = terror.ClassDDL.New(codeUnsupportedModifyColumn, "unsupported modify column %s")
+	errUnsupportedPKHandle     = terror.ClassDDL.New(codeUnsupportedDropPKHandle, "unsupported drop integer primary key")
+)
+
+// DDL is responsible for updating schema in data store and maintaining in-memory InfoSchema cache.
+// The DDL statements are added to a job queue as DDL jobs, and the jobs are run one at a time by a
+// worker. A job changes the schema in one or more steps, each step is a transaction with a new schema
+// version, and the InfoSchema is reloaded by the Callback after every step.
+type DDL interface {
+	CreateSchema(schema model.CIStr, charsetInfo *ast.CharsetOpt) error
+	DropSchema(schema model.CIStr) error
+	CreateTable(ident ast.Ident, stmt *ast.CreateTableStmt) error
+	DropTable(tableIdent ast.Ident) error
+	CreateIndex(tableIdent ast.Ident, unique bool, indexName model.CIStr, columnNames []*ast.IndexColName) error
+	DropIndex(tableIdent ast.Ident, indexName model.CIStr) error
+	AlterTable(tableIdent ast.Ident, spec []*ast.AlterTableSpec) error
+	RenameTable(oldTableIdent, newTableIdent ast.Ident) error
+
+	// SchemaRLocker returns the read lock of the schema, the schema is not changed while it
+	// is held. The transactions are checked against the latest schema and committed with it held.
+	SchemaRLocker() sync.Locker
+
+	// GetInformationSchema gets the infoschema binding to d.
+	GetInformationSchema() infoschema.InfoSchema
+
+	// Stop stops the DDL worker, the jobs left in the queue are run after the restart.
+	Stop() error
+}
+
+// Callback is the interface supporting callback function when DDL changed.
+type Callback interface {
+	// OnChanged is called after schema is changed.
+	OnChanged(err error) error
+}
+
+// BaseCallback implements Callback.OnChanged interface.
+type BaseCallback struct {
+}
+
+// OnChanged implements Callback interface.
+func (c *BaseCallback) OnChanged(err error) error {
+	return err
+}
+
+type ddl struct {
+	// schemaMu is held while the schema is changed and the InfoSchema is reloaded.
+	schemaMu   sync.RWMutex
+	store      kv.Storage
+	infoHandle *infoschema.Handle
+	hook       Callback
+
+	// ddlJobCh wakes up the worker when a job is added to the queue.
+	ddlJobCh chan struct{}
+	quitCh   chan struct{}
+	wg       sync.WaitGroup
+
+	// doneCh is closed and replaced by a new one when a job is finished, it wakes up the
+	// sessions which wait for their jobs.
+	doneMu sync.Mutex
+	doneCh chan struct{}
+
+	// reorgJobID and reorgErr are the result of the last backfill of the worker, they are
+	// only accessed by the worker.
+	reorgJobID int64
+	reorgErr   error
+}
+
+// NewDDL creates a new DDL, and starts its worker.
+func NewDDL(store kv.Storage, infoHandle *infoschema.Handle, hook Callback) DDL {
+	if hook == nil {
+		hook = &BaseCallback{}
+	}
+	d := &ddl{
+		store:      store,
+		infoHandle: infoHandle,
+		hook:       hook,
+		ddlJobCh:   make(chan struct{}, 1),
+		quitCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	d.wg.Add(1)
+	go d.start()
+	return d
+}
+
+// Stop implements DDL Stop interface.
+func (d *ddl) Stop() error {
+	close(d.quitCh)
+	d.wg.Wait()
+	log.Info("[ddl] stop DDL worker")
+	return nil
+}
+
+func (d *ddl) isClosed() bool {
+	select {
+	case <-d.quitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetInformationSchema gets the infoschema binding to d.
+func (d *ddl) GetInformationSchema() infoschema.InfoSchema {
+	return d.infoHandle.Get()
+}
+
+// SchemaRLocker implements DDL SchemaRLocker interface.
+func (d *ddl) SchemaRLocker() sync.Locker {
+	return d.schemaMu.RLocker()
+}
+
+// checkJobInterval is the interval to check whether a job is finished, in case the notification is missed.
+const checkJobInterval = 100 * time.Millisecond
+
+// doDDLJob adds the job to the queue and waits until it is finished. The error of a job which is
+// cancelled or rolled back is returned.
+func (d *ddl) doDDLJob(job *model.Job) error {
+	job.State = model.JobStateNone
+	err := kv.RunInNewTxn(d.store, true, func(txn kv.Transaction) error {
+		m := meta.NewMeta(txn)
+		var err error
+		if job.ID, err = m.GenGlobalID(); err != nil {
+			return errors.Trace(err)
+		}
+		job.StartTS = txn.StartTS()
+		return errors.Trace(m.EnQueueDDLJob(job))
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Infof("[ddl] start DDL job %s, query %s", job, job.Query)
+	asyncNotify(d.ddlJobCh)
+
+	ticker := time.NewTicker(checkJobInterval)
+	defer ticker.Stop()
+	for {
+		// The channel is taken before the check, so that the notification after the check is not missed.
+		doneCh := d.getDoneCh()
+		historyJob, err := d.getHistoryDDLJob(job.ID)
+		if err != nil {
+			log.Errorf("[ddl] get history DDL job %d err %v", job.ID, errors.ErrorStack(err))
+		} else if historyJob != nil {
+			if historyJob.IsSynced() {
+				log.Infof("[ddl] DDL job %d is finished", job.ID)
+				return nil
+			}
+			if historyJob.Error != nil {
+				return errors.Trace(historyJob.Error)
+			}
+			return errInvalidDDLJob.GenWithStack("DDL job %d is finished with state %s", job.ID, historyJob.State)
+		}
+		select {
+		case <-doneCh:
+		case <-ticker.C:
+		case <-d.quitCh:
+			return errInvalidDDLJob.GenWithStack("DDL worker is stopped, job %d is not finished", job.ID)
+		}
+	}
+}
+
+func (d *ddl) getDoneCh() chan struct{} {
+	d.doneMu.Lock()
+	defer d.doneMu.Unlock()
+	return d.doneCh
+}
+
+// notifyJobDone wakes up the sessions which wait for their jobs.
+func (d *ddl) notifyJobDone() {
+	d.doneMu.Lock()
+	close(d.doneCh)
+	d.doneCh = make(chan struct{})
+	d.doneMu.Unlock()
+}
+
+func (d *ddl) getHistoryDDLJob(id int64) (job *model.Job, err error) {
+	err = kv.RunInNewTxn(d.store, false, func(txn kv.Transaction) error {
+		job, err = meta.NewMeta(txn).GetHistoryDDLJob(id)
+		return errors.Trace(err)
+	})
+	return job, errors.Trace(err)
+}
+
+// genGlobalID generates a global ID in a new transaction, the ID is used as the ID of the
+// schema or the table which is created by a job.
+func (d *ddl) genGlobalID() (id int64, err error) {
+	err = kv.RunInNewTxn(d.store, true, func(txn kv.Transaction) error {
+		id, err = meta.NewMeta(txn).GenGlobalID()
+		return errors.Trace(err)
+	})
+	return id, errors.Trace(err)
+}
+
+func asyncNotify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// DDL error codes.
+const (
+	codeInvalidDDLJob               terror.ErrCode = 3
+	codeInvalidJobState                            = 5
+	codeRunMultiSchemaChanges                      = 6
+	codeCancelledDDLJob                            = 12
+	codeCantDropColWithIndex                       = 201
+	codeUnsupportedAddColumn                       = 202
+	codeUnsupportedModifyColumn                    = 203
+	codeUnsupportedDropPKHandle                    = 204
+	codeUnsupportedCharset                         = 205
+	codeUnsupportedModifyPrimaryKey                = 206
+	codeUnsupportedDDLOperation                    = terror.ErrCode(mysql.ErrNotSupportedYet)
+
+	codeBadField              = 1054
+	codeTooLongIdent          = 1059
+	codeDupKeyName            = 1061
+	codeTooLongKey            = 1071
+	codeIncorrectPrefixKey    = 1089
+	codeCantRemoveAllFields   = 1090
+	codeCantDropFieldOrKey    = 1091
+	codeKeyColumnDoesNotExits = mysql.ErrKeyColumnDoesNotExits
+	codeBlobCantHaveDefault   = 1101
+	codeBlobKeyWithoutLength  = 1170
+	codeJSONUsedAsKey         = terror.ErrCode(mysql.ErrJSONUsedAsKey)
+	codeWrongDBName           = 1102
+	codeWrongTableName        = 1103
+	codeTooManyFields         = 1117
+	codeWrongColumnName       = 1166
+	codeInvalidOnUpdate       = 1294
+	codeUnknownCharacterSet   = terror.ErrCode(mysql.ErrUnknownCharacterSet)
+	codeTableMustHaveColumns  = terror.ErrCode(mysql.ErrTableMustHaveColumns)
+	codePrimaryCantHaveNull   = terror.ErrCode(mysql.ErrPrimaryCantHaveNull)
+)
+
+func init() {
+	ddlMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeTooLongIdent:            mysql.ErrTooLongIdent,
+		codeDupKeyName:              mysql.ErrDupKeyName,
+		codeTooLongKey:              mysql.ErrTooLongKey,
+		codeIncorrectPrefixKey:      mysql.ErrWrongSubKey,
+		codeCantRemoveAllFields:     mysql.ErrCantRemoveAllFields,
+		codeCantDropFieldOrKey:      mysql.ErrCantDropFieldOrKey,
+		codeBadField:                mysql.ErrBadField,
+		codeKeyColumnDoesNotExits:   mysql.ErrKeyColumnDoesNotExits,
+		codeBlobCantHaveDefault:     mysql.ErrBlobCantHaveDefault,
+		codeWrongDBName:             mysql.ErrWrongDBName,
+		codeWrongTableName:          mysql.ErrWrongTableName,
+		codeTooManyFields:           mysql.ErrTooManyFields,
+		codeWrongColumnName:         mysql.ErrWrongColumnName,
+		codeInvalidOnUpdate:         mysql.ErrInvalidOnUpdate,
+		codeUnknownCharacterSet:     mysql.ErrUnknownCharacterSet,
+		codeTableMustHaveColumns:    mysql.ErrTableMustHaveColumns,
+		codePrimaryCantHaveNull:     mysql.ErrPrimaryCantHaveNull,
+		codeUnsupportedDDLOperation: mysql.ErrNotSupportedYet,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassDDL] = ddlMySQLErrCodes
+}
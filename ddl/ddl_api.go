@@ -0,0 +1,1453 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/ddl_api.go
+//
+
+package ddl
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/opcode"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+
+	"fedb/infoschema"
+	"fedb/table"
+)
+
+func (d *ddl) CreateSchema(schema model.CIStr, charsetInfo *ast.CharsetOpt) (err error) {
+	is := d.GetInformationSchema()
+	_, ok := is.SchemaByName(schema)
+	if ok {
+		return infoschema.ErrDatabaseExists.GenWithStackByArgs(schema)
+	}
+
+	if err = checkTooLongSchema(schema); err != nil {
+		return errors.Trace(err)
+	}
+	schemaID, err := d.genGlobalID()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	dbInfo := &model.DBInfo{
+		Name: schema,
+	}
+	if charsetInfo != nil {
+		err = checkCharsetAndCollation(charsetInfo.Chs, charsetInfo.Col)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		dbInfo.Charset = charsetInfo.Chs
+		dbInfo.Collate = charsetInfo.Col
+	}
+	if dbInfo.Charset == "" {
+		dbInfo.Charset, dbInfo.Collate = charset.GetDefaultCharsetAndCollate()
+	} else if dbInfo.Collate == "" {
+		if dbInfo.Collate, err = charset.GetDefaultCollation(dbInfo.Charset); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	job := &model.Job{
+		SchemaID:   schemaID,
+		Type:       model.ActionCreateSchema,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{dbInfo},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+func (d *ddl) DropSchema(schema model.CIStr) (err error) {
+	is := d.GetInformationSchema()
+	old, ok := is.SchemaByName(schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(schema)
+	}
+
+	job := &model.Job{
+		SchemaID:   old.ID,
+		Type:       model.ActionDropSchema,
+		BinlogInfo: &model.HistoryInfo{},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+func checkTooLongSchema(schema model.CIStr) error {
+	if len(schema.L) > mysql.MaxDatabaseNameLength {
+		return ErrTooLongIdent.GenWithStackByArgs(schema)
+	}
+	return nil
+}
+
+func checkTooLongTable(table model.CIStr) error {
+	if len(table.L) > mysql.MaxTableNameLength {
+		return ErrTooLongIdent.GenWithStackByArgs(table)
+	}
+	return nil
+}
+
+func checkTooLongIndex(index model.CIStr) error {
+	if len(index.L) > mysql.MaxIndexIdentifierLen {
+		return ErrTooLongIdent.GenWithStackByArgs(index)
+	}
+	return nil
+}
+
+func setColumnFlagWithConstraint(colMap map[string]*model.ColumnInfo, v *ast.Constraint) {
+	switch v.Tp {
+	case ast.ConstraintPrimaryKey:
+		for _, key := range v.Keys {
+			c, ok := colMap[key.Column.Name.L]
+			if !ok {
+				continue
+			}
+			c.Flag |= mysql.PriKeyFlag
+			// Primary key can not be NULL.
+			c.Flag |= mysql.NotNullFlag
+		}
+	case ast.ConstraintUniq, ast.ConstraintUniqIndex, ast.ConstraintUniqKey:
+		for i, key := range v.Keys {
+			c, ok := colMap[key.Column.Name.L]
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				// Only the first column can be set
+				// if unique index has multi columns,
+				// the flag should be MultipleKeyFlag.
+				// See https://dev.mysql.com/doc/refman/5.7/en/show-columns.html
+				if len(v.Keys) > 1 {
+					c.Flag |= mysql.MultipleKeyFlag
+				} else {
+					c.Flag |= mysql.UniqueKeyFlag
+				}
+			}
+		}
+	case ast.ConstraintKey, ast.ConstraintIndex:
+		for i, key := range v.Keys {
+			c, ok := colMap[key.Column.Name.L]
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				// Only the first column can be set.
+				c.Flag |= mysql.MultipleKeyFlag
+			}
+		}
+	}
+}
+
+func buildColumnsAndConstraints(colDefs []*ast.ColumnDef,
+	constraints []*ast.Constraint) ([]*model.ColumnInfo, []*ast.Constraint, error) {
+	var cols []*model.ColumnInfo
+	colMap := map[string]*model.ColumnInfo{}
+	// outPriKeyConstraint is the primary key constraint out of column definition. such as: create table t1 (id int , age int, primary key(id));
+	var outPriKeyConstraint *ast.Constraint
+	for _, v := range constraints {
+		if v.Tp == ast.ConstraintPrimaryKey {
+			outPriKeyConstraint = v
+			break
+		}
+	}
+	for i, colDef := range colDefs {
+		col, cts, err := buildColumnAndConstraint(i, colDef, outPriKeyConstraint)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		col.State = model.StatePublic
+		constraints = append(constraints, cts...)
+		cols = append(cols, col)
+		colMap[colDef.Name.Name.L] = col
+	}
+	// Traverse table Constraints and set col.flag.
+	for _, v := range constraints {
+		setColumnFlagWithConstraint(colMap, v)
+	}
+	return cols, constraints, nil
+}
+
+func setCharsetCollationFlenDecimal(tp *types.FieldType) error {
+	tp.Charset = strings.ToLower(tp.Charset)
+	tp.Collate = strings.ToLower(tp.Collate)
+	if len(tp.Charset) == 0 {
+		switch tp.Tp {
+		case mysql.TypeString, mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeBlob, mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeEnum, mysql.TypeSet:
+			tp.Charset, tp.Collate = charset.GetDefaultCharsetAndCollate()
+		default:
+			tp.Charset = charset.CharsetBin
+			tp.Collate = charset.CharsetBin
+		}
+	} else {
+		if !charset.ValidCharsetAndCollation(tp.Charset, tp.Collate) {
+			return errUnsupportedCharset.GenWithStackByArgs(tp.Charset, tp.Collate)
+		}
+		if len(tp.Collate) == 0 {
+			var err error
+			tp.Collate, err = charset.GetDefaultCollation(tp.Charset)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	// Use default value for flen or decimal when they are unspecified.
+	defaultFlen, defaultDecimal := mysql.GetDefaultFieldLengthAndDecimal(tp.Tp)
+	if tp.Flen == types.UnspecifiedLength {
+		tp.Flen = defaultFlen
+		if mysql.HasUnsignedFlag(tp.Flag) && tp.Tp != mysql.TypeLonglong && mysql.IsIntegerType(tp.Tp) {
+			// Issue #4684: the flen of unsigned integer(except bigint) is 1 digit shorter than signed integer
+			// because it has no prefix "+" or "-" character.
+			tp.Flen--
+		}
+	}
+	if tp.Decimal == types.UnspecifiedLength {
+		tp.Decimal = defaultDecimal
+	}
+	return nil
+}
+
+// outPriKeyConstraint is the primary key constraint out of column definition. such as: create table t1 (id int , age int, primary key(id));
+func buildColumnAndConstraint(offset int,
+	colDef *ast.ColumnDef, outPriKeyConstraint *ast.Constraint) (*model.ColumnInfo, []*ast.Constraint, error) {
+	err := setCharsetCollationFlenDecimal(colDef.Tp)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	col, cts, err := columnDefToCol(offset, colDef, outPriKeyConstraint)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return col, cts, nil
+}
+
+// checkColumnDefaultValue checks the default value of the column,
+// TEXT/BLOB/JSON can't have not null default values.
+func checkColumnDefaultValue(col *model.ColumnInfo, value interface{}) error {
+	if value != nil && (col.Tp == mysql.TypeJSON ||
+		col.Tp == mysql.TypeTinyBlob || col.Tp == mysql.TypeMediumBlob ||
+		col.Tp == mysql.TypeLongBlob || col.Tp == mysql.TypeBlob) {
+		return errBlobCantHaveDefault.GenWithStackByArgs(col.Name.O)
+	}
+	return nil
+}
+
+// columnDefToCol converts ColumnDef to Col and TableConstraints.
+// outPriKeyConstraint is the primary key constraint out of column definition. such as: create table t1 (id int , age int, primary key(id));
+func columnDefToCol(offset int, colDef *ast.ColumnDef, outPriKeyConstraint *ast.Constraint) (*model.ColumnInfo, []*ast.Constraint, error) {
+	var constraints = make([]*ast.Constraint, 0)
+	col := &model.ColumnInfo{
+		Offset:    offset,
+		Name:      colDef.Name.Name,
+		FieldType: *colDef.Tp,
+	}
+
+	setOnUpdateNow := false
+	hasDefaultValue := false
+	hasNullFlag := false
+	if colDef.Options != nil {
+		length := types.UnspecifiedLength
+
+		keys := []*ast.IndexColName{
+			{
+				Column: colDef.Name,
+				Length: length,
+			},
+		}
+
+		for _, v := range colDef.Options {
+			switch v.Tp {
+			case ast.ColumnOptionNotNull:
+				col.Flag |= mysql.NotNullFlag
+			case ast.ColumnOptionNull:
+				col.Flag &= ^mysql.NotNullFlag
+				removeOnUpdateNowFlag(col)
+				hasNullFlag = true
+			case ast.ColumnOptionAutoIncrement:
+				col.Flag |= mysql.AutoIncrementFlag
+			case ast.ColumnOptionPrimaryKey:
+				constraint := &ast.Constraint{Tp: ast.ConstraintPrimaryKey, Keys: keys}
+				constraints = append(constraints, constraint)
+				col.Flag |= mysql.PriKeyFlag
+			case ast.ColumnOptionUniqKey:
+				constraint := &ast.Constraint{Tp: ast.ConstraintUniqKey, Name: colDef.Name.Name.O, Keys: keys}
+				constraints = append(constraints, constraint)
+				col.Flag |= mysql.UniqueKeyFlag
+			case ast.ColumnOptionDefaultValue:
+				value, err := getDefaultValue(v, colDef.Tp.Tp)
+				if err != nil {
+					return nil, nil, types.ErrInvalidDefault.GenWithStackByArgs(col.Name)
+				}
+				if err = checkColumnDefaultValue(col, value); err != nil {
+					return nil, nil, errors.Trace(err)
+				}
+				if err = col.SetDefaultValue(value); err != nil {
+					return nil, nil, errors.Trace(err)
+				}
+				hasDefaultValue = true
+				removeOnUpdateNowFlag(col)
+			case ast.ColumnOptionOnUpdate:
+				// TODO: Support other time functions.
+				if col.Tp == mysql.TypeTimestamp || col.Tp == mysql.TypeDatetime {
+					if !isCurrentTimestampExpr(v.Expr) {
+						return nil, nil, ErrInvalidOnUpdate.GenWithStackByArgs(col.Name)
+					}
+				} else {
+					return nil, nil, ErrInvalidOnUpdate.GenWithStackByArgs(col.Name)
+				}
+				col.Flag |= mysql.OnUpdateNowFlag
+				setOnUpdateNow = true
+			case ast.ColumnOptionComment:
+				err := setColumnComment(col, v)
+				if err != nil {
+					return nil, nil, errors.Trace(err)
+				}
+			case ast.ColumnOptionGenerated:
+				return nil, nil, errUnsupportedDDLOperation.GenWithStackByArgs("generated column")
+			case ast.ColumnOptionFulltext:
+				// TODO: Support this type.
+			}
+		}
+	}
+
+	setTimestampDefaultValue(col, hasDefaultValue, setOnUpdateNow)
+
+	// Set `NoDefaultValueFlag` if this field doesn't have a default value and
+	// it is `not null` and not an `AUTO_INCREMENT` field or `TIMESTAMP` field.
+	setNoDefaultValueFlag(col, hasDefaultValue)
+	if col.FieldType.EvalType().IsStringKind() && col.Charset == charset.CharsetBin {
+		col.Flag |= mysql.BinaryFlag
+	}
+	if col.Tp == mysql.TypeBit {
+		// For BIT field, it's charset is binary but does not have binary flag.
+		col.Flag &= ^mysql.BinaryFlag
+		col.Flag |= mysql.UnsignedFlag
+	}
+	if col.Tp == mysql.TypeYear {
+		// For Year field, it's charset is binary but does not have binary flag.
+		col.Flag &= ^mysql.BinaryFlag
+		col.Flag |= mysql.ZerofillFlag
+	}
+	// If you specify ZEROFILL for a numeric column, MySQL automatically adds the UNSIGNED attribute to the column.
+	// See https://dev.mysql.com/doc/refman/5.7/en/numeric-type-overview.html for more details.
+	// But some types like bit and year, won't show its unsigned flag in `show create table`.
+	if mysql.HasZerofillFlag(col.Flag) {
+		col.Flag |= mysql.UnsignedFlag
+	}
+	err := checkPriKeyConstraint(col, hasDefaultValue, hasNullFlag, outPriKeyConstraint)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	err = checkColumnValueConstraint(col)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	err = checkDefaultValue(col, hasDefaultValue)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return col, constraints, nil
+}
+
+// isCurrentTimestampExpr checks whether e is CURRENT_TIMESTAMP or one of its synonyms.
+func isCurrentTimestampExpr(e ast.ExprNode) bool {
+	fn, ok := e.(*ast.FuncCallExpr)
+	if !ok {
+		return false
+	}
+	switch fn.FnName.L {
+	case ast.CurrentTimestamp, ast.Now, ast.LocalTime, ast.LocalTimestamp:
+		return true
+	}
+	return false
+}
+
+// evalConstExpr evaluates the constant expression used in the column definition,
+// only the literals and the signed literals are supported.
+func evalConstExpr(expr ast.ExprNode) (types.Datum, error) {
+	switch x := expr.(type) {
+	case *driver.ValueExpr:
+		return x.Datum, nil
+	case *ast.ParenthesesExpr:
+		return evalConstExpr(x.Expr)
+	case *ast.UnaryOperationExpr:
+		d, err := evalConstExpr(x.V)
+		if err != nil || x.Op == opcode.Plus {
+			return d, errors.Trace(err)
+		}
+		if x.Op == opcode.Minus {
+			return negateDatum(d)
+		}
+	}
+	return types.Datum{}, errors.Errorf("unsupported expression: %s", expr.Text())
+}
+
+func negateDatum(d types.Datum) (types.Datum, error) {
+	switch d.Kind() {
+	case types.KindInt64:
+		if d.GetInt64() == math.MinInt64 {
+			return types.NewDecimalDatum(types.DecimalNeg(types.NewDecFromInt(d.GetInt64()))), nil
+		}
+		return types.NewIntDatum(-d.GetInt64()), nil
+	case types.KindUint64:
+		dec := new(types.MyDecimal)
+		if err := dec.FromString([]byte(strconv.FormatUint(d.GetUint64(), 10))); err != nil {
+			return d, errors.Trace(err)
+		}
+		return types.NewDecimalDatum(types.DecimalNeg(dec)), nil
+	case types.KindFloat32, types.KindFloat64:
+		return types.NewFloat64Datum(-d.GetFloat64()), nil
+	case types.KindMysqlDecimal:
+		return types.NewDecimalDatum(types.DecimalNeg(d.GetMysqlDecimal())), nil
+	}
+	return d, errors.Errorf("unsupported negative value: %v", d.GetValue())
+}
+
+func getDefaultValue(c *ast.ColumnOption, tp byte) (interface{}, error) {
+	if tp == mysql.TypeTimestamp || tp == mysql.TypeDatetime {
+		if isCurrentTimestampExpr(c.Expr) {
+			return strings.ToUpper(ast.CurrentTimestamp), nil
+		}
+	}
+	v, err := evalConstExpr(c.Expr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	if v.Kind() == types.KindBinaryLiteral || v.Kind() == types.KindMysqlBit {
+		if tp == mysql.TypeBit ||
+			tp == mysql.TypeString || tp == mysql.TypeVarchar || tp == mysql.TypeVarString ||
+			tp == mysql.TypeBlob || tp == mysql.TypeLongBlob || tp == mysql.TypeMediumBlob || tp == mysql.TypeTinyBlob ||
+			tp == mysql.TypeJSON {
+			// For BinaryLiteral / string fields, when getting default value we cast the value into BinaryLiteral{}, thus we return
+			// its raw string content here.
+			return v.GetBinaryLiteral().ToString(), nil
+		}
+		// For other kind of fields (e.g. INT), we supply its integer value so that it acts as integers.
+		return v.GetBinaryLiteral().ToInt(new(stmtctx.StatementContext))
+	}
+
+	if tp == mysql.TypeBit {
+		if v.Kind() == types.KindInt64 || v.Kind() == types.KindUint64 {
+			// For BIT fields, convert int into BinaryLiteral.
+			return types.NewBinaryLiteralFromUint(v.GetUint64(), -1).ToString(), nil
+		}
+	}
+
+	return v.ToString()
+}
+
+func removeOnUpdateNowFlag(c *model.ColumnInfo) {
+	// For timestamp Col, if it is set null or default value,
+	// OnUpdateNowFlag should be removed.
+	if mysql.HasTimestampFlag(c.Flag) {
+		c.Flag &= ^mysql.OnUpdateNowFlag
+	}
+}
+
+func setTimestampDefaultValue(c *model.ColumnInfo, hasDefaultValue bool, setOnUpdateNow bool) {
+	if hasDefaultValue {
+		return
+	}
+
+	// For timestamp Col, if is not set default value or not set null, use current timestamp.
+	if mysql.HasTimestampFlag(c.Flag) && mysql.HasNotNullFlag(c.Flag) {
+		if setOnUpdateNow {
+			c.SetDefaultValue(types.ZeroDatetimeStr)
+		} else {
+			c.SetDefaultValue(strings.ToUpper(ast.CurrentTimestamp))
+		}
+	}
+}
+
+func setNoDefaultValueFlag(c *model.ColumnInfo, hasDefaultValue bool) {
+	if hasDefaultValue {
+		return
+	}
+
+	if !mysql.HasNotNullFlag(c.Flag) {
+		return
+	}
+
+	// Check if it is an `AUTO_INCREMENT` field or `TIMESTAMP` field.
+	if !mysql.HasAutoIncrementFlag(c.Flag) && !mysql.HasTimestampFlag(c.Flag) {
+		c.Flag |= mysql.NoDefaultValueFlag
+	}
+}
+
+func checkDefaultValue(c *model.ColumnInfo, hasDefaultValue bool) error {
+	if !hasDefaultValue {
+		return nil
+	}
+
+	if c.GetDefaultValue() != nil {
+		value, err := convertDefaultValue(c)
+		if err != nil {
+			return types.ErrInvalidDefault.GenWithStackByArgs(c.Name)
+		}
+		return errors.Trace(c.SetDefaultValue(value))
+	}
+	// Primary key default null is invalid.
+	if mysql.HasPriKeyFlag(c.Flag) {
+		return ErrPrimaryCantHaveNull
+	}
+
+	// Set not null but default null is invalid.
+	if mysql.HasNotNullFlag(c.Flag) {
+		return types.ErrInvalidDefault.GenWithStackByArgs(c.Name)
+	}
+
+	return nil
+}
+
+// convertDefaultValue converts the default value to the type of the column, the value which
+// can't be stored in the column exactly is invalid. The time values are normalized, so that
+// they are stored in the same format.
+func convertDefaultValue(c *model.ColumnInfo) (interface{}, error) {
+	value := c.GetDefaultValue()
+	if s, ok := value.(string); ok && types.IsTypeTime(c.Tp) && strings.EqualFold(s, ast.CurrentTimestamp) {
+		return value, nil
+	}
+	if c.Tp == mysql.TypeBit {
+		return value, nil
+	}
+	sc := new(stmtctx.StatementContext)
+	d := types.NewDatum(value)
+	d, err := d.ConvertTo(sc, &c.FieldType)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if types.IsTypeTime(c.Tp) || c.Tp == mysql.TypeDuration {
+		return d.ToString()
+	}
+	return value, nil
+}
+
+// checkPriKeyConstraint check all parts of a PRIMARY KEY must be NOT NULL
+func checkPriKeyConstraint(col *model.ColumnInfo, hasDefaultValue, hasNullFlag bool, outPriKeyConstraint *ast.Constraint) error {
+	// Primary key should not be null.
+	if mysql.HasPriKeyFlag(col.Flag) && hasDefaultValue && col.GetDefaultValue() == nil {
+		return types.ErrInvalidDefault.GenWithStackByArgs(col.Name)
+	}
+	// Set primary key flag for outer primary key constraint.
+	// Such as: create table t1 (id int , age int, primary key(id))
+	if !mysql.HasPriKeyFlag(col.Flag) && outPriKeyConstraint != nil {
+		for _, key := range outPriKeyConstraint.Keys {
+			if key.Column.Name.L != col.Name.L {
+				continue
+			}
+			col.Flag |= mysql.PriKeyFlag
+			break
+		}
+	}
+	// Primary key should not be null.
+	if mysql.HasPriKeyFlag(col.Flag) && hasNullFlag {
+		return ErrPrimaryCantHaveNull
+	}
+	return nil
+}
+
+func checkColumnValueConstraint(col *model.ColumnInfo) error {
+	if col.Tp != mysql.TypeEnum && col.Tp != mysql.TypeSet {
+		return nil
+	}
+	valueMap := make(map[string]string, len(col.Elems))
+	for i := range col.Elems {
+		val := strings.ToLower(col.Elems[i])
+		if _, ok := valueMap[val]; ok {
+			tpStr := "ENUM"
+			if col.Tp == mysql.TypeSet {
+				tpStr = "SET"
+			}
+			return types.ErrDuplicatedValueInType.GenWithStackByArgs(col.Name, valueMap[val], tpStr)
+		}
+		valueMap[val] = col.Elems[i]
+	}
+	return nil
+}
+
+func checkDuplicateColumn(colDefs []*ast.ColumnDef) error {
+	colNames := map[string]bool{}
+	for _, colDef := range colDefs {
+		nameLower := colDef.Name.Name.L
+		if colNames[nameLower] {
+			return infoschema.ErrColumnExists.GenWithStackByArgs(colDef.Name.Name)
+		}
+		colNames[nameLower] = true
+	}
+	return nil
+}
+
+func checkTooLongColumn(colDefs []*ast.ColumnDef) error {
+	for _, colDef := range colDefs {
+		if len(colDef.Name.Name.O) > mysql.MaxColumnNameLength {
+			return ErrTooLongIdent.GenWithStackByArgs(colDef.Name.Name)
+		}
+	}
+	return nil
+}
+
+func checkTooManyColumns(colDefs []*ast.ColumnDef) error {
+	if len(colDefs) > TableColumnCountLimit {
+		return errTooManyFields
+	}
+	return nil
+}
+
+// checkColumnsAttributes checks attributes for multiple columns.
+func checkColumnsAttributes(colDefs []*ast.ColumnDef) error {
+	for _, colDef := range colDefs {
+		if err := checkColumnAttributes(colDef.Name.OrigColName(), colDef.Tp); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// checkColumnAttributes check attributes for single column.
+func checkColumnAttributes(colName string, tp *types.FieldType) error {
+	switch tp.Tp {
+	case mysql.TypeNewDecimal, mysql.TypeDouble, mysql.TypeFloat:
+		if tp.Flen < tp.Decimal {
+			return types.ErrMBiggerThanD.GenWithStackByArgs(colName)
+		}
+	case mysql.TypeDatetime, mysql.TypeDuration, mysql.TypeTimestamp:
+		if tp.Decimal != types.UnspecifiedFsp && (tp.Decimal < types.MinFsp || tp.Decimal > types.MaxFsp) {
+			return types.ErrTooBigPrecision.GenWithStackByArgs(tp.Decimal, colName, types.MaxFsp)
+		}
+	}
+	return nil
+}
+
+func checkDuplicateConstraint(namesMap map[string]bool, name string) error {
+	if name == "" {
+		return nil
+	}
+	nameLower := strings.ToLower(name)
+	if namesMap[nameLower] {
+		return ErrDupKeyName.GenWithStack("duplicate key name %s", name)
+	}
+	namesMap[nameLower] = true
+	return nil
+}
+
+func setEmptyConstraintName(namesMap map[string]bool, constr *ast.Constraint) {
+	if constr.Name == "" && len(constr.Keys) > 0 {
+		colName := constr.Keys[0].Column.Name.L
+		constrName := colName
+		i := 2
+		if strings.EqualFold(constrName, mysql.PrimaryKeyName) {
+			constrName = fmt.Sprintf("%s_%d", constrName, 2)
+			i = 3
+		}
+		for namesMap[constrName] {
+			// We loop forever until we find constrName that haven't been used.
+			constrName = fmt.Sprintf("%s_%d", colName, i)
+			i++
+		}
+		constr.Name = constrName
+		namesMap[constrName] = true
+	}
+}
+
+func checkConstraintNames(constraints []*ast.Constraint) error {
+	constrNames := map[string]bool{}
+
+	// Check not empty constraint name whether is duplicated.
+	for _, constr := range constraints {
+		if constr.Tp == ast.ConstraintForeignKey {
+			continue
+		}
+		err := checkDuplicateConstraint(constrNames, constr.Name)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	// Set empty constraint names.
+	for _, constr := range constraints {
+		if constr.Tp != ast.ConstraintForeignKey {
+			setEmptyConstraintName(constrNames, constr)
+		}
+	}
+
+	return nil
+}
+
+// buildTableInfo builds the table info without the table id, which is allocated in the DDL transaction.
+func buildTableInfo(tableName model.CIStr, cols []*model.ColumnInfo, constraints []*ast.Constraint) (tbInfo *model.TableInfo, err error) {
+	tbInfo = &model.TableInfo{
+		Name: tableName,
+	}
+	for _, v := range cols {
+		v.ID = allocateColumnID(tbInfo)
+		tbInfo.Columns = append(tbInfo.Columns, v)
+	}
+	for _, constr := range constraints {
+		if constr.Tp == ast.ConstraintForeignKey {
+			// The foreign keys are parsed but ignored, as MySQL does for the MyISAM tables.
+			continue
+		}
+		if constr.Tp == ast.ConstraintPrimaryKey {
+			var col *model.ColumnInfo
+			for _, key := range constr.Keys {
+				col = model.FindColumnInfo(cols, key.Column.Name.L)
+				if col == nil {
+					return nil, errKeyColumnDoesNotExits.GenWithStackByArgs(key.Column.Name)
+				}
+			}
+			if len(constr.Keys) == 1 {
+				switch col.Tp {
+				case mysql.TypeLong, mysql.TypeLonglong,
+					mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24:
+					tbInfo.PKIsHandle = true
+					// Avoid creating index for PK handle column.
+					continue
+				}
+			}
+		}
+		// build index info.
+		idxInfo, err := buildIndexInfo(tbInfo, model.NewCIStr(constr.Name), constr.Keys, model.StatePublic)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		//check if the index is primary or uniqiue.
+		switch constr.Tp {
+		case ast.ConstraintPrimaryKey:
+			idxInfo.Primary = true
+			idxInfo.Unique = true
+			idxInfo.Name = model.NewCIStr(mysql.PrimaryKeyName)
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			idxInfo.Unique = true
+		}
+		if err = checkTooLongIndex(idxInfo.Name); err != nil {
+			return nil, errors.Trace(err)
+		}
+		// set index type.
+		if constr.Option != nil {
+			idxInfo.Comment = constr.Option.Comment
+			if constr.Option.Tp == model.IndexTypeInvalid {
+				// Use btree as default index type.
+				idxInfo.Tp = model.IndexTypeBtree
+			} else {
+				idxInfo.Tp = constr.Option.Tp
+			}
+		} else {
+			// Use btree as default index type.
+			idxInfo.Tp = model.IndexTypeBtree
+		}
+		idxInfo.ID = allocateIndexID(tbInfo)
+		tbInfo.Indices = append(tbInfo.Indices, idxInfo)
+	}
+	return
+}
+
+// CreateTable creates the table in ident.Schema, it does nothing if the table exists and
+// IF NOT EXISTS is specified.
+func (d *ddl) CreateTable(ident ast.Ident, s *ast.CreateTableStmt) (err error) {
+	if s.ReferTable != nil {
+		return errUnsupportedDDLOperation.GenWithStackByArgs("CREATE TABLE ... LIKE")
+	}
+	if s.Select != nil {
+		return errUnsupportedDDLOperation.GenWithStackByArgs("CREATE TABLE ... SELECT")
+	}
+	if s.Partition != nil {
+		return errUnsupportedDDLOperation.GenWithStackByArgs("partitioned table")
+	}
+
+	colDefs := s.Cols
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ident.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ident.Schema)
+	}
+	if is.TableExists(ident.Schema, ident.Name) {
+		if s.IfNotExists {
+			return nil
+		}
+		return infoschema.ErrTableExists.GenWithStackByArgs(ident)
+	}
+	if err = checkTooLongTable(ident.Name); err != nil {
+		return errors.Trace(err)
+	}
+	if len(colDefs) == 0 {
+		return ErrTableMustHaveColumns
+	}
+	if err = checkDuplicateColumn(colDefs); err != nil {
+		return errors.Trace(err)
+	}
+	if err = checkTooLongColumn(colDefs); err != nil {
+		return errors.Trace(err)
+	}
+	if err = checkTooManyColumns(colDefs); err != nil {
+		return errors.Trace(err)
+	}
+
+	if err = checkColumnsAttributes(colDefs); err != nil {
+		return errors.Trace(err)
+	}
+
+	cols, newConstraints, err := buildColumnsAndConstraints(colDefs, s.Constraints)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = checkConstraintNames(newConstraints)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	tbInfo, err := buildTableInfo(ident.Name, cols, newConstraints)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	err = handleTableOptions(s.Options, tbInfo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = checkCharsetAndCollation(tbInfo.Charset, tbInfo.Collate)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// The table inherits the charset of the database if it is not specified.
+	if tbInfo.Charset == "" {
+		tbInfo.Charset, tbInfo.Collate = schema.Charset, schema.Collate
+	} else if tbInfo.Collate == "" {
+		if tbInfo.Collate, err = charset.GetDefaultCollation(tbInfo.Charset); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if tbInfo.ID, err = d.genGlobalID(); err != nil {
+		return errors.Trace(err)
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tbInfo.ID,
+		Type:       model.ActionCreateTable,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{tbInfo},
+	}
+	err = d.doDDLJob(job)
+	if infoschema.ErrTableExists.Equal(err) && s.IfNotExists {
+		return nil
+	}
+	return errors.Trace(err)
+}
+
+func checkCharsetAndCollation(cs string, co string) error {
+	if !charset.ValidCharsetAndCollation(cs, co) {
+		return ErrUnknownCharacterSet.GenWithStackByArgs(cs)
+	}
+	return nil
+}
+
+// handleTableOptions updates tableInfo according to table options.
+func handleTableOptions(options []*ast.TableOption, tbInfo *model.TableInfo) error {
+	for _, op := range options {
+		switch op.Tp {
+		case ast.TableOptionAutoIncrement:
+			tbInfo.AutoIncID = int64(op.UintValue)
+		case ast.TableOptionComment:
+			tbInfo.Comment = op.StrValue
+		case ast.TableOptionCharset:
+			tbInfo.Charset = strings.ToLower(op.StrValue)
+		case ast.TableOptionCollate:
+			tbInfo.Collate = strings.ToLower(op.StrValue)
+		case ast.TableOptionCompression:
+			tbInfo.Compression = op.StrValue
+		}
+	}
+	return nil
+}
+
+func setColumnComment(col *model.ColumnInfo, option *ast.ColumnOption) error {
+	value, err := evalConstExpr(option.Expr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	col.Comment, err = value.ToString()
+	return errors.Trace(err)
+}
+
+// DropTable drops the table, the database and the table must exist.
+func (d *ddl) DropTable(ti ast.Ident) (err error) {
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ti.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ti.Schema)
+	}
+
+	tb, err := is.TableByName(ti.Schema, ti.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ti.Schema, ti.Name))
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tb.ID,
+		Type:       model.ActionDropTable,
+		BinlogInfo: &model.HistoryInfo{},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+func isIgnorableSpec(tp ast.AlterTableType) bool {
+	// AlterTableLock/AlterTableAlgorithm are ignored.
+	return tp == ast.AlterTableLock || tp == ast.AlterTableAlgorithm
+}
+
+// AlterTable runs the change of the ALTER TABLE statement, only one change is supported in a statement.
+func (d *ddl) AlterTable(ident ast.Ident, specs []*ast.AlterTableSpec) (err error) {
+	validSpecs := make([]*ast.AlterTableSpec, 0, len(specs))
+	for _, spec := range specs {
+		if isIgnorableSpec(spec.Tp) {
+			continue
+		}
+		validSpecs = append(validSpecs, spec)
+	}
+	if len(validSpecs) > 1 {
+		return errRunMultiSchemaChanges
+	}
+
+	for _, spec := range validSpecs {
+		switch spec.Tp {
+		case ast.AlterTableAddColumns:
+			if len(spec.NewColumns) != 1 {
+				return errRunMultiSchemaChanges
+			}
+			err = d.AddColumn(ident, spec)
+		case ast.AlterTableDropColumn:
+			err = d.DropColumn(ident, spec.OldColumnName.Name)
+		case ast.AlterTableDropIndex:
+			err = d.DropIndex(ident, model.NewCIStr(spec.Name))
+		case ast.AlterTableAddConstraint:
+			constr := spec.Constraint
+			switch constr.Tp {
+			case ast.ConstraintKey, ast.ConstraintIndex:
+				err = d.CreateIndex(ident, false, model.NewCIStr(constr.Name), spec.Constraint.Keys)
+			case ast.ConstraintUniq, ast.ConstraintUniqIndex, ast.ConstraintUniqKey:
+				err = d.CreateIndex(ident, true, model.NewCIStr(constr.Name), spec.Constraint.Keys)
+			case ast.ConstraintPrimaryKey:
+				err = ErrUnsupportedModifyPrimaryKey.GenWithStackByArgs("add")
+			default:
+				err = errUnsupportedDDLOperation.GenWithStackByArgs("ADD FOREIGN KEY or FULLTEXT")
+			}
+		case ast.AlterTableDropPrimaryKey:
+			err = ErrUnsupportedModifyPrimaryKey.GenWithStackByArgs("drop")
+		case ast.AlterTableModifyColumn:
+			err = d.ModifyColumn(ident, spec)
+		case ast.AlterTableChangeColumn:
+			err = d.ChangeColumn(ident, spec)
+		case ast.AlterTableAlterColumn:
+			err = d.AlterColumn(ident, spec)
+		case ast.AlterTableRenameTable:
+			newIdent := ast.Ident{Schema: spec.NewTable.Schema, Name: spec.NewTable.Name}
+			// The table is renamed in its database if the new name isn't qualified.
+			if newIdent.Schema.L == "" {
+				newIdent.Schema = ident.Schema
+			}
+			err = d.RenameTable(ident, newIdent)
+		default:
+			err = errUnsupportedDDLOperation.GenWithStackByArgs("this ALTER TABLE clause")
+		}
+
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+func checkColumnConstraint(col *ast.ColumnDef, ti ast.Ident) error {
+	for _, constraint := range col.Options {
+		switch constraint.Tp {
+		case ast.ColumnOptionAutoIncrement:
+			return errUnsupportedAddColumn.GenWithStack("unsupported add column '%s' constraint AUTO_INCREMENT when altering '%s.%s'", col.Name, ti.Schema, ti.Name)
+		case ast.ColumnOptionPrimaryKey:
+			return errUnsupportedAddColumn.GenWithStack("unsupported add column '%s' constraint PRIMARY KEY when altering '%s.%s'", col.Name, ti.Schema, ti.Name)
+		case ast.ColumnOptionUniqKey:
+			return errUnsupportedAddColumn.GenWithStack("unsupported add column '%s' constraint UNIQUE KEY when altering '%s.%s'", col.Name, ti.Schema, ti.Name)
+		}
+	}
+
+	return nil
+}
+
+// AddColumn will add a new column to the table.
+func (d *ddl) AddColumn(ti ast.Ident, spec *ast.AlterTableSpec) error {
+	specNewColumn := spec.NewColumns[0]
+	// Check whether the added column constraints are supported.
+	err := checkColumnConstraint(specNewColumn, ti)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	colName := specNewColumn.Name.Name.O
+	if err = checkColumnAttributes(colName, specNewColumn.Tp); err != nil {
+		return errors.Trace(err)
+	}
+
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ti.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ti.Schema)
+	}
+	tblInfo, err := is.TableByName(ti.Schema, ti.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ti.Schema, ti.Name))
+	}
+	if err = checkAddColumnTooManyColumns(len(tblInfo.Columns) + 1); err != nil {
+		return errors.Trace(err)
+	}
+	// Check whether added column has existed.
+	if model.FindColumnInfo(tblInfo.Columns, colName) != nil {
+		return infoschema.ErrColumnExists.GenWithStackByArgs(colName)
+	}
+	if len(colName) > mysql.MaxColumnNameLength {
+		return ErrTooLongIdent.GenWithStackByArgs(colName)
+	}
+
+	pos := spec.Position
+	if pos == nil {
+		pos = &ast.ColumnPosition{Tp: ast.ColumnPositionNone}
+	}
+	if pos.Tp == ast.ColumnPositionAfter && model.FindColumnInfo(tblInfo.Columns, pos.RelativeColumn.Name.L) == nil {
+		return infoschema.ErrColumnNotExists.GenWithStackByArgs(pos.RelativeColumn, ti.Name)
+	}
+
+	// Ignore table constraints now, maybe return error later.
+	// We use length(t.Cols()) as the default offset firstly, we will change the
+	// column's offset later.
+	col, _, err := buildColumnAndConstraint(len(tblInfo.Columns), specNewColumn, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// The existing rows get the original default value, which is fixed when the column is added.
+	col.OriginDefaultValue = col.GetDefaultValue()
+	if col.OriginDefaultValue == nil && mysql.HasNotNullFlag(col.Flag) {
+		zeroVal := table.GetZeroValue(col)
+		if col.OriginDefaultValue, err = zeroVal.ToString(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if col.OriginDefaultValue == strings.ToUpper(ast.CurrentTimestamp) &&
+		(col.Tp == mysql.TypeTimestamp || col.Tp == mysql.TypeDatetime) {
+		col.OriginDefaultValue = time.Now().Format(types.TimeFormat)
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionAddColumn,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{col, pos, 0},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// DropColumn will drop a column from the table, now we don't support drop the column with index covered.
+func (d *ddl) DropColumn(ti ast.Ident, colName model.CIStr) error {
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ti.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ti.Schema)
+	}
+	tblInfo, err := is.TableByName(ti.Schema, ti.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ti.Schema, ti.Name))
+	}
+
+	// Check whether dropped column has existed.
+	col := model.FindColumnInfo(tblInfo.Columns, colName.L)
+	if col == nil {
+		return ErrCantDropFieldOrKey.GenWithStack("column %s doesn't exist", colName)
+	}
+	if err = isDroppableColumn(tblInfo, colName); err != nil {
+		return errors.Trace(err)
+	}
+	// The handle of the rows can't be dropped.
+	if tblInfo.PKIsHandle && mysql.HasPriKeyFlag(col.Flag) {
+		return errUnsupportedPKHandle
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionDropColumn,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{colName},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// modifiable checks if the 'origin' type can be modified to 'to' type with out the need to
+// change or check existing data in the table.
+// It returns true if the two types has the same Charset and Collation, the same sign, both are
+// integer types or string types, and new Flen and Decimal must be greater than or equal to origin.
+func modifiable(origin *types.FieldType, to *types.FieldType) error {
+	if to.Flen > 0 && to.Flen < origin.Flen {
+		msg := fmt.Sprintf("length %d is less than origin %d", to.Flen, origin.Flen)
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	}
+	if to.Decimal > 0 && to.Decimal < origin.Decimal {
+		msg := fmt.Sprintf("decimal %d is less than origin %d", to.Decimal, origin.Decimal)
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	}
+	if to.Charset != origin.Charset {
+		msg := fmt.Sprintf("charset %s not match origin %s", to.Charset, origin.Charset)
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	}
+	if to.Collate != origin.Collate {
+		msg := fmt.Sprintf("collate %s not match origin %s", to.Collate, origin.Collate)
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	}
+	toUnsigned := mysql.HasUnsignedFlag(to.Flag)
+	originUnsigned := mysql.HasUnsignedFlag(origin.Flag)
+	if originUnsigned != toUnsigned {
+		msg := fmt.Sprintf("unsigned %v not match origin %v", toUnsigned, originUnsigned)
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	}
+	switch origin.Tp {
+	case mysql.TypeVarchar, mysql.TypeString, mysql.TypeVarString,
+		mysql.TypeBlob, mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		switch to.Tp {
+		case mysql.TypeVarchar, mysql.TypeString, mysql.TypeVarString,
+			mysql.TypeBlob, mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+			return nil
+		}
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+		switch to.Tp {
+		case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+			return nil
+		}
+	case mysql.TypeEnum:
+		if origin.Tp == to.Tp {
+			if len(to.Elems) < len(origin.Elems) {
+				msg := fmt.Sprintf("the number of enum column's elements is less than the original: %d", len(origin.Elems))
+				return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+			}
+			for index, originElem := range origin.Elems {
+				toElem := to.Elems[index]
+				if originElem != toElem {
+					msg := fmt.Sprintf("cannot modify enum column value %s to %s", originElem, toElem)
+					return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+				}
+			}
+			return nil
+		}
+		msg := fmt.Sprintf("cannot modify enum type column's to type %s", to.String())
+		return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+	default:
+		if origin.Tp == to.Tp {
+			return nil
+		}
+	}
+	msg := fmt.Sprintf("type %v not match origin %v", to.Tp, origin.Tp)
+	return errUnsupportedModifyColumn.GenWithStackByArgs(msg)
+}
+
+func setDefaultValue(col *model.ColumnInfo, option *ast.ColumnOption) error {
+	value, err := getDefaultValue(option, col.Tp)
+	if err != nil {
+		return types.ErrInvalidDefault.GenWithStackByArgs(col.Name)
+	}
+	if err = checkColumnDefaultValue(col, value); err != nil {
+		return errors.Trace(err)
+	}
+	if err = col.SetDefaultValue(value); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(checkDefaultValue(col, true))
+}
+
+func setDefaultAndComment(col *model.ColumnInfo, options []*ast.ColumnOption) error {
+	if len(options) == 0 {
+		return nil
+	}
+	var hasDefaultValue, setOnUpdateNow bool
+	for _, opt := range options {
+		switch opt.Tp {
+		case ast.ColumnOptionDefaultValue:
+			value, err := getDefaultValue(opt, col.Tp)
+			if err != nil {
+				return types.ErrInvalidDefault.GenWithStackByArgs(col.Name)
+			}
+			if err = checkColumnDefaultValue(col, value); err != nil {
+				return errors.Trace(err)
+			}
+			if err = col.SetDefaultValue(value); err != nil {
+				return errors.Trace(err)
+			}
+			hasDefaultValue = true
+			removeOnUpdateNowFlag(col)
+		case ast.ColumnOptionComment:
+			err := setColumnComment(col, opt)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		case ast.ColumnOptionNotNull:
+			col.Flag |= mysql.NotNullFlag
+		case ast.ColumnOptionNull:
+			col.Flag &= ^mysql.NotNullFlag
+			removeOnUpdateNowFlag(col)
+		case ast.ColumnOptionAutoIncrement:
+			col.Flag |= mysql.AutoIncrementFlag
+		case ast.ColumnOptionPrimaryKey, ast.ColumnOptionUniqKey:
+			return errUnsupportedModifyColumn.GenWithStack("unsupported modify column constraint - %v", opt.Tp)
+		case ast.ColumnOptionOnUpdate:
+			if col.Tp == mysql.TypeTimestamp || col.Tp == mysql.TypeDatetime {
+				if !isCurrentTimestampExpr(opt.Expr) {
+					return ErrInvalidOnUpdate.GenWithStackByArgs(col.Name)
+				}
+			} else {
+				return ErrInvalidOnUpdate.GenWithStackByArgs(col.Name)
+			}
+			col.Flag |= mysql.OnUpdateNowFlag
+			setOnUpdateNow = true
+		default:
+			return errors.Trace(errUnsupportedModifyColumn.GenWithStack("unsupported modify column option - %v", opt.Tp))
+		}
+	}
+
+	setTimestampDefaultValue(col, hasDefaultValue, setOnUpdateNow)
+
+	// Set `NoDefaultValueFlag` if this field doesn't have a default value and
+	// it is `not null` and not an `AUTO_INCREMENT` field or `TIMESTAMP` field.
+	setNoDefaultValueFlag(col, hasDefaultValue)
+
+	return errors.Trace(checkDefaultValue(col, hasDefaultValue))
+}
+
+// getModifiableColumnJob returns a DDL job of model.ActionModifyColumn which changes the column
+// without rewriting the existing data.
+func (d *ddl) getModifiableColumnJob(ident ast.Ident, originalColName model.CIStr,
+	spec *ast.AlterTableSpec) (*model.Job, error) {
+	specNewColumn := spec.NewColumns[0]
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ident.Schema)
+	if !ok {
+		return nil, infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ident.Schema)
+	}
+	tblInfo, err := is.TableByName(ident.Schema, ident.Name)
+	if err != nil {
+		return nil, errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ident.Schema, ident.Name))
+	}
+
+	col := model.FindColumnInfo(tblInfo.Columns, originalColName.L)
+	if col == nil {
+		return nil, infoschema.ErrColumnNotExists.GenWithStackByArgs(originalColName, ident.Name)
+	}
+	newColName := specNewColumn.Name.Name
+	// If we want to rename the column name, we need to check whether it already exists.
+	if newColName.L != originalColName.L {
+		if model.FindColumnInfo(tblInfo.Columns, newColName.L) != nil {
+			return nil, infoschema.ErrColumnExists.GenWithStackByArgs(newColName)
+		}
+		if len(newColName.O) > mysql.MaxColumnNameLength {
+			return nil, ErrTooLongIdent.GenWithStackByArgs(newColName)
+		}
+	}
+
+	if err = checkColumnAttributes(specNewColumn.Name.OrigColName(), specNewColumn.Tp); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	newCol := &model.ColumnInfo{
+		ID:                 col.ID,
+		Offset:             col.Offset,
+		State:              col.State,
+		OriginDefaultValue: col.OriginDefaultValue,
+		FieldType:          *specNewColumn.Tp,
+		Name:               newColName,
+	}
+	if err = setCharsetCollationFlenDecimal(&newCol.FieldType); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = modifiable(&col.FieldType, &newCol.FieldType); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = setDefaultAndComment(newCol, specNewColumn.Options); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// Copy index related options to the new spec.
+	indexFlags := col.FieldType.Flag & (mysql.PriKeyFlag | mysql.UniqueKeyFlag | mysql.MultipleKeyFlag)
+	newCol.FieldType.Flag |= indexFlags
+	if mysql.HasPriKeyFlag(col.FieldType.Flag) {
+		newCol.FieldType.Flag |= mysql.NotNullFlag
+	}
+
+	// We don't support modifying column from not_auto_increment to auto_increment.
+	if !mysql.HasAutoIncrementFlag(col.Flag) && mysql.HasAutoIncrementFlag(newCol.Flag) {
+		return nil, errUnsupportedModifyColumn.GenWithStackByArgs("set auto_increment")
+	}
+	// The existing rows may have NULL values, they are not checked.
+	if !mysql.HasNotNullFlag(col.Flag) && mysql.HasNotNullFlag(newCol.Flag) {
+		return nil, errUnsupportedModifyColumn.GenWithStackByArgs("null to not null")
+	}
+
+	pos := spec.Position
+	if pos == nil {
+		pos = &ast.ColumnPosition{Tp: ast.ColumnPositionNone}
+	}
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionModifyColumn,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{newCol, originalColName, pos},
+	}
+	return job, nil
+}
+
+// ChangeColumn renames an existing column and modifies the column's definition,
+// currently we only support limited kind of changes
+// that do not need to change or check data on the table.
+func (d *ddl) ChangeColumn(ident ast.Ident, spec *ast.AlterTableSpec) error {
+	specNewColumn := spec.NewColumns[0]
+	if len(specNewColumn.Name.Schema.O) != 0 && ident.Schema.L != specNewColumn.Name.Schema.L {
+		return ErrWrongDBName.GenWithStackByArgs(specNewColumn.Name.Schema.O)
+	}
+	if len(spec.OldColumnName.Schema.O) != 0 && ident.Schema.L != spec.OldColumnName.Schema.L {
+		return ErrWrongDBName.GenWithStackByArgs(spec.OldColumnName.Schema.O)
+	}
+	if len(specNewColumn.Name.Table.O) != 0 && ident.Name.L != specNewColumn.Name.Table.L {
+		return ErrWrongTableName.GenWithStackByArgs(specNewColumn.Name.Table.O)
+	}
+	if len(spec.OldColumnName.Table.O) != 0 && ident.Name.L != spec.OldColumnName.Table.L {
+		return ErrWrongTableName.GenWithStackByArgs(spec.OldColumnName.Table.O)
+	}
+
+	job, err := d.getModifiableColumnJob(ident, spec.OldColumnName.Name, spec)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// ModifyColumn does modification on an existing column, currently we only support limited kind of changes
+// that do not need to change or check data on the table.
+func (d *ddl) ModifyColumn(ident ast.Ident, spec *ast.AlterTableSpec) error {
+	specNewColumn := spec.NewColumns[0]
+	if len(specNewColumn.Name.Schema.O) != 0 && ident.Schema.L != specNewColumn.Name.Schema.L {
+		return ErrWrongDBName.GenWithStackByArgs(specNewColumn.Name.Schema.O)
+	}
+	if len(specNewColumn.Name.Table.O) != 0 && ident.Name.L != specNewColumn.Name.Table.L {
+		return ErrWrongTableName.GenWithStackByArgs(specNewColumn.Name.Table.O)
+	}
+
+	job, err := d.getModifiableColumnJob(ident, specNewColumn.Name.Name, spec)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// AlterColumn sets or drops the default value of the column.
+func (d *ddl) AlterColumn(ident ast.Ident, spec *ast.AlterTableSpec) error {
+	specNewColumn := spec.NewColumns[0]
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ident.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ident.Schema)
+	}
+	tblInfo, err := is.TableByName(ident.Schema, ident.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ident.Schema, ident.Name))
+	}
+
+	colName := specNewColumn.Name.Name
+	// Check whether alter column has existed.
+	origin := model.FindColumnInfo(tblInfo.Columns, colName.L)
+	if origin == nil {
+		return errBadField.GenWithStackByArgs(colName, ident.Name)
+	}
+	// The column of the InfoSchema is shared, it's changed on a copy.
+	col := origin.Clone()
+
+	// Clean the NoDefaultValueFlag value.
+	col.Flag &= ^mysql.NoDefaultValueFlag
+	if len(specNewColumn.Options) == 0 {
+		if err = col.SetDefaultValue(nil); err != nil {
+			return errors.Trace(err)
+		}
+		setNoDefaultValueFlag(col, false)
+	} else {
+		if err = setDefaultValue(col, specNewColumn.Options[0]); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionSetDefaultValue,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{col},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// RenameTable renames the table, it can be moved to another database.
+func (d *ddl) RenameTable(oldIdent, newIdent ast.Ident) error {
+	is := d.GetInformationSchema()
+	oldSchema, ok := is.SchemaByName(oldIdent.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(oldIdent.Schema)
+	}
+	oldTbl, err := is.TableByName(oldIdent.Schema, oldIdent.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(oldIdent.Schema, oldIdent.Name))
+	}
+	if newIdent.Schema.L == oldIdent.Schema.L && newIdent.Name.L == oldIdent.Name.L {
+		// oldIdent is equal to newIdent, do nothing
+		return nil
+	}
+	newSchema, ok := is.SchemaByName(newIdent.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(newIdent.Schema)
+	}
+	if is.TableExists(newIdent.Schema, newIdent.Name) {
+		return infoschema.ErrTableExists.GenWithStackByArgs(newIdent)
+	}
+	if err = checkTooLongTable(newIdent.Name); err != nil {
+		return errors.Trace(err)
+	}
+
+	job := &model.Job{
+		SchemaID:   newSchema.ID,
+		TableID:    oldTbl.ID,
+		Type:       model.ActionRenameTable,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{oldSchema.ID, newIdent.Name},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
@@ -0,0 +1,295 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/ddl_worker.go
+//
+
+package ddl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/terror"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/meta"
+	"fedb/tablecodec"
+)
+
+// retryJobInterval is the interval to retry a job whose step fails.
+const retryJobInterval = time.Second
+
+// start runs the jobs in the queue until the DDL is stopped, it's woken up when a job is added.
+func (d *ddl) start() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(retryJobInterval)
+	defer ticker.Stop()
+	for {
+		// The jobs left by the last run are handled at first.
+		if err := d.handleDDLJobQueue(); err != nil {
+			log.Errorf("[ddl] handle DDL job err %v", errors.ErrorStack(err))
+		}
+		select {
+		case <-d.ddlJobCh:
+		case <-ticker.C:
+		case <-d.quitCh:
+			return
+		}
+	}
+}
+
+// handleDDLJobQueue runs the jobs in the queue step by step. Every step is a transaction with the
+// schema lock held, and the InfoSchema is reloaded before the lock is released, so the sessions
+// see the schema of the last step only. The backfill of an index is run without the lock.
+func (d *ddl) handleDDLJobQueue() error {
+	for !d.isClosed() {
+		job, err := d.getFirstDDLJob()
+		if err != nil || job == nil {
+			return errors.Trace(err)
+		}
+		if isReorgJob(job) && d.reorgJobID != job.ID {
+			d.reorgErr = d.backfillIndex(job)
+			d.reorgJobID = job.ID
+		}
+
+		var schemaVer int64
+		var runErr error
+		d.schemaMu.Lock()
+		err = kv.RunInNewTxn(d.store, true, func(txn kv.Transaction) error {
+			schemaVer, runErr = 0, nil
+			t := meta.NewMeta(txn)
+			var err error
+			if job, err = t.GetDDLJobByIdx(0); err != nil || job == nil {
+				return errors.Trace(err)
+			}
+			schemaVer, runErr = d.runDDLJob(t, job)
+			if job.IsCancelled() {
+				// The changes of the cancelled job are discarded.
+				txn.Reset()
+				schemaVer = 0
+				return errors.Trace(d.finishDDLJob(t, job))
+			}
+			if job.IsFinished() {
+				return errors.Trace(d.finishDDLJob(t, job))
+			}
+			return errors.Trace(updateDDLJob(t, job, runErr != nil))
+		})
+		if err == nil && schemaVer != 0 {
+			err = d.hook.OnChanged(nil)
+		}
+		d.schemaMu.Unlock()
+		if err != nil || job == nil {
+			return errors.Trace(err)
+		}
+
+		// The job which is done is synced by finishDDLJob.
+		if job.IsSynced() || job.IsFinished() {
+			d.cleanupDDLJob(job)
+			log.Infof("[ddl] finish DDL job %s", job)
+			d.notifyJobDone()
+		} else if runErr != nil {
+			// The step is retried later.
+			return errors.Trace(runErr)
+		}
+	}
+	return nil
+}
+
+func (d *ddl) getFirstDDLJob() (job *model.Job, err error) {
+	err = kv.RunInNewTxn(d.store, false, func(txn kv.Transaction) error {
+		job, err = meta.NewMeta(txn).GetDDLJobByIdx(0)
+		return errors.Trace(err)
+	})
+	return job, errors.Trace(err)
+}
+
+// isReorgJob checks whether the job is adding an index whose entries should be backfilled.
+func isReorgJob(job *model.Job) bool {
+	return job.Type == model.ActionAddIndex && job.IsRunning() && job.SchemaState == model.StateWriteReorganization
+}
+
+// updateDDLJob saves the job which enters another state. The RawArgs is kept if the Args
+// can't be decoded.
+func updateDDLJob(t *meta.Meta, job *model.Job, meetErr bool) error {
+	updateRawArgs := !(meetErr && job.RawArgs != nil && job.Args == nil)
+	return errors.Trace(t.UpdateDDLJob(0, job, updateRawArgs))
+}
+
+// finishDDLJob moves the finished job from the queue to the history. The job which is done is
+// synced at the same time, as the InfoSchema is reloaded with the schema lock held.
+func (d *ddl) finishDDLJob(t *meta.Meta, job *model.Job) error {
+	if _, err := t.DeQueueDDLJob(); err != nil {
+		return errors.Trace(err)
+	}
+	if job.IsDone() {
+		job.State = model.JobStateSynced
+	}
+	return errors.Trace(t.AddHistoryDDLJob(job))
+}
+
+// cleanupDDLJob deletes the data of the tables and the indices which are dropped by the job, they
+// are removed from the schema already, so the data is not accessed any more. The data is left
+// if the deletion fails, it's never read as the IDs are not reused.
+func (d *ddl) cleanupDDLJob(job *model.Job) {
+	var prefixes []kv.Key
+	var err error
+	switch {
+	case job.Type == model.ActionDropSchema && job.IsSynced():
+		var tableIDs []int64
+		err = job.DecodeArgs(&tableIDs)
+		for _, id := range tableIDs {
+			prefixes = append(prefixes, tablecodec.EncodeTablePrefix(id))
+		}
+	case job.Type == model.ActionDropTable && job.IsSynced():
+		prefixes = append(prefixes, tablecodec.EncodeTablePrefix(job.TableID))
+	case job.Type == model.ActionDropIndex && job.IsSynced(),
+		job.Type == model.ActionAddIndex && job.IsRollbackDone():
+		var indexName model.CIStr
+		var indexID int64
+		err = job.DecodeArgs(&indexName, &indexID)
+		prefixes = append(prefixes, tablecodec.EncodeTableIndexPrefix(job.TableID, indexID))
+	}
+	if err != nil {
+		log.Errorf("[ddl] decode args of DDL job %s err %v", job, err)
+		return
+	}
+	for _, prefix := range prefixes {
+		if err = d.deleteRange(prefix); err != nil {
+			log.Errorf("[ddl] delete data of DDL job %s err %v", job, errors.ErrorStack(err))
+		}
+	}
+}
+
+// runDDLJob runs a step of the job. It returns the new schema version, or 0 if the schema is
+// not changed. The error is saved in the job, the job is retried if it's not cancelled.
+func (d *ddl) runDDLJob(t *meta.Meta, job *model.Job) (ver int64, err error) {
+	log.Infof("[ddl] run DDL job %s", job)
+	if job.IsFinished() {
+		return
+	}
+	// The job is cancelled by the client, the jobs which have changed the schema are rolled back.
+	if job.IsCancelling() {
+		if job.SchemaState != model.StateNone {
+			switch job.Type {
+			case model.ActionAddIndex:
+				return convertAddIdxJob2RollbackJob(t, job, errCancelledDDLJob)
+			case model.ActionAddColumn:
+				return convertAddColumnJob2RollbackJob(t, job, errCancelledDDLJob)
+			}
+		}
+		job.State = model.JobStateCancelled
+		job.Error = errCancelledDDLJob
+		job.ErrorCount++
+		return
+	}
+
+	if !job.IsRollingback() {
+		job.State = model.JobStateRunning
+	}
+
+	switch job.Type {
+	case model.ActionCreateSchema:
+		ver, err = onCreateSchema(t, job)
+	case model.ActionDropSchema:
+		ver, err = onDropSchema(t, job)
+	case model.ActionCreateTable:
+		ver, err = onCreateTable(t, job)
+	case model.ActionDropTable:
+		ver, err = onDropTable(t, job)
+	case model.ActionRenameTable:
+		ver, err = onRenameTable(t, job)
+	case model.ActionAddColumn:
+		ver, err = onAddColumn(t, job)
+	case model.ActionDropColumn:
+		ver, err = onDropColumn(t, job)
+	case model.ActionModifyColumn:
+		ver, err = onModifyColumn(t, job)
+	case model.ActionSetDefaultValue:
+		ver, err = onSetDefaultValue(t, job)
+	case model.ActionAddIndex:
+		ver, err = d.onCreateIndex(t, job)
+	case model.ActionDropIndex:
+		ver, err = onDropIndex(t, job)
+	default:
+		// Invalid job, cancel it.
+		job.State = model.JobStateCancelled
+		err = errInvalidDDLJob.GenWithStack("invalid ddl job type: %v", job.Type)
+	}
+
+	// Save errors in job, so that others can know errors happened.
+	if err != nil {
+		if job.State != model.JobStateCancelled {
+			log.Errorf("[ddl] run DDL job err %v", errors.ErrorStack(err))
+		} else {
+			log.Infof("[ddl] the DDL job is normal to cancel because %v", err)
+		}
+		job.Error = toTError(err)
+		job.ErrorCount++
+	}
+	return
+}
+
+func toTError(err error) *terror.Error {
+	originErr := errors.Cause(err)
+	tErr, ok := originErr.(*terror.Error)
+	if ok {
+		return tErr
+	}
+
+	return terror.ClassDDL.New(terror.CodeUnknown, err.Error())
+}
+
+// updateSchemaVersion increases the schema version, so that the transactions compiled with the
+// old schema fail to commit.
+func updateSchemaVersion(t *meta.Meta) (int64, error) {
+	ver, err := t.GenSchemaVersion()
+	return ver, errors.Trace(err)
+}
+
+// updateVersionAndTableInfo saves the table info with a new schema version.
+func updateVersionAndTableInfo(t *meta.Meta, job *model.Job, tblInfo *model.TableInfo) (ver int64, err error) {
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return ver, errors.Trace(t.UpdateTable(job.SchemaID, tblInfo))
+}
+
+// getTableInfo gets the table info of the job, the table should be public.
+func getTableInfo(t *meta.Meta, job *model.Job, schemaID int64) (*model.TableInfo, error) {
+	tblInfo, err := t.GetTable(schemaID, job.TableID)
+	if err != nil {
+		if meta.ErrDBNotExists.Equal(err) {
+			job.State = model.JobStateCancelled
+			return nil, errors.Trace(infoschema.ErrDatabaseNotExists.GenWithStackByArgs(
+				fmt.Sprintf("(Schema ID %d)", schemaID)))
+		}
+		return nil, errors.Trace(err)
+	}
+	if tblInfo == nil {
+		job.State = model.JobStateCancelled
+		return nil, errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(
+			fmt.Sprintf("(Schema ID %d)", schemaID),
+			fmt.Sprintf("(Table ID %d)", job.TableID),
+		))
+	}
+	if tblInfo.State != model.StatePublic {
+		job.State = model.JobStateCancelled
+		return nil, errInvalidJobState.GenWithStackByArgs(tblInfo.State)
+	}
+	return tblInfo, nil
+}
@@ -0,0 +1,547 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/index.go
+//
+
+package ddl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/meta"
+	"fedb/privilege"
+	"fedb/sessionctx/variable"
+	"fedb/table"
+	"fedb/table/tables"
+	"fedb/util"
+)
+
+const (
+	// maxPrefixLength is the max length of an index prefix, the same as MySQL.
+	maxPrefixLength = 3072
+	// maxKeyParts is the max number of the columns of an index, the same as MySQL.
+	maxKeyParts = 16
+	// reorgBatchSize is the number of the rows or the index entries a reorganization
+	// transaction handles.
+	reorgBatchSize = 256
+)
+
+func buildIndexColumns(columns []*model.ColumnInfo, idxColNames []*ast.IndexColName) ([]*model.IndexColumn, error) {
+	if len(idxColNames) > maxKeyParts {
+		return nil, infoschema.ErrTooManyKeyParts.GenWithStackByArgs(maxKeyParts)
+	}
+	// Build offsets.
+	idxColumns := make([]*model.IndexColumn, 0, len(idxColNames))
+
+	for _, ic := range idxColNames {
+		col := model.FindColumnInfo(columns, ic.Column.Name.O)
+		if col == nil {
+			return nil, errKeyColumnDoesNotExits.GenWithStackByArgs(ic.Column.Name)
+		}
+
+		// JSON column cannot index.
+		if col.FieldType.Tp == mysql.TypeJSON {
+			return nil, errors.Trace(errJSONUsedAsKey.GenWithStackByArgs(col.Name.O))
+		}
+
+		// Length must be specified for BLOB and TEXT column indexes.
+		if types.IsTypeBlob(col.FieldType.Tp) && ic.Length == types.UnspecifiedLength {
+			return nil, errors.Trace(errBlobKeyWithoutLength)
+		}
+
+		if ic.Length != types.UnspecifiedLength {
+			// Only the string columns can be indexed by a prefix, which is not longer than the column.
+			if !types.IsTypePrefixable(col.FieldType.Tp) ||
+				(!types.IsTypeBlob(col.FieldType.Tp) && col.Flen != types.UnspecifiedLength && ic.Length > col.Flen) {
+				return nil, errors.Trace(errIncorrectPrefixKey)
+			}
+			if ic.Length > maxPrefixLength {
+				return nil, errors.Trace(errTooLongKey.GenWithStackByArgs(maxPrefixLength))
+			}
+		}
+
+		idxColumns = append(idxColumns, &model.IndexColumn{
+			Name:   col.Name,
+			Offset: col.Offset,
+			Length: ic.Length,
+		})
+	}
+
+	return idxColumns, nil
+}
+
+func buildIndexInfo(tblInfo *model.TableInfo, indexName model.CIStr, idxColNames []*ast.IndexColName, state model.SchemaState) (*model.IndexInfo, error) {
+	idxColumns, err := buildIndexColumns(tblInfo.Columns, idxColNames)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// Create index info.
+	idxInfo := &model.IndexInfo{
+		Name:    indexName,
+		Columns: idxColumns,
+		State:   state,
+	}
+	return idxInfo, nil
+}
+
+func allocateIndexID(tblInfo *model.TableInfo) int64 {
+	tblInfo.MaxIndexID++
+	return tblInfo.MaxIndexID
+}
+
+func findIndexByName(idxName string, indices []*model.IndexInfo) *model.IndexInfo {
+	for _, idx := range indices {
+		if idx.Name.L == idxName {
+			return idx
+		}
+	}
+	return nil
+}
+
+func addIndexColumnFlag(tblInfo *model.TableInfo, indexInfo *model.IndexInfo) {
+	col := indexInfo.Columns[0]
+	if indexInfo.Unique && len(indexInfo.Columns) == 1 {
+		tblInfo.Columns[col.Offset].Flag |= mysql.UniqueKeyFlag
+	} else {
+		tblInfo.Columns[col.Offset].Flag |= mysql.MultipleKeyFlag
+	}
+}
+
+func dropIndexColumnFlag(tblInfo *model.TableInfo, indexInfo *model.IndexInfo) {
+	col := indexInfo.Columns[0]
+	if indexInfo.Unique && len(indexInfo.Columns) == 1 {
+		tblInfo.Columns[col.Offset].Flag &= ^mysql.UniqueKeyFlag
+	} else {
+		tblInfo.Columns[col.Offset].Flag &= ^mysql.MultipleKeyFlag
+	}
+	// other index may still cover this col
+	for _, index := range tblInfo.Indices {
+		if index.Name.L == indexInfo.Name.L {
+			continue
+		}
+		if index.Columns[0].Name.L != col.Name.L {
+			continue
+		}
+		addIndexColumnFlag(tblInfo, index)
+	}
+}
+
+// getAnonymousIndex returns the name of the index without a name, which is the name of its
+// first column with a suffix if the name is used.
+func getAnonymousIndex(tblInfo *model.TableInfo, colName model.CIStr) model.CIStr {
+	id := 2
+	indexName := colName
+	for findIndexByName(indexName.L, tblInfo.Indices) != nil {
+		indexName = model.NewCIStr(fmt.Sprintf("%s_%d", colName.O, id))
+		id++
+	}
+	return indexName
+}
+
+// CreateIndex creates an index on the table without blocking the writes. The index goes through
+// the delete only, write only and write reorganization states before it is public, the InfoSchema
+// is reloaded in every state, and the transactions which are compiled with a previous state fail
+// to commit, so every row written after the index is write only has its entry. The entries of the
+// existing rows are added in the write reorganization state by backfillIndex.
+func (d *ddl) CreateIndex(ti ast.Ident, unique bool, indexName model.CIStr, idxColNames []*ast.IndexColName) error {
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ti.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ti.Schema)
+	}
+	tblInfo, err := is.TableByName(ti.Schema, ti.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ti.Schema, ti.Name))
+	}
+	if indexName.L == "" {
+		indexName = getAnonymousIndex(tblInfo, idxColNames[0].Column.Name)
+	}
+	if err = checkTooLongIndex(indexName); err != nil {
+		return errors.Trace(err)
+	}
+	if findIndexByName(indexName.L, tblInfo.Indices) != nil {
+		return infoschema.ErrKeyNameDuplicate.GenWithStackByArgs(indexName)
+	}
+	// The columns are checked before the job is added.
+	if _, err = buildIndexColumns(tblInfo.Columns, idxColNames); err != nil {
+		return errors.Trace(err)
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionAddIndex,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{unique, indexName, idxColNames},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+// DropIndex drops the index of the table, it goes through the states of CreateIndex in reverse order,
+// and its entries are deleted after it is removed from the table.
+func (d *ddl) DropIndex(ti ast.Ident, indexName model.CIStr) error {
+	is := d.GetInformationSchema()
+	schema, ok := is.SchemaByName(ti.Schema)
+	if !ok {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(ti.Schema)
+	}
+	tblInfo, err := is.TableByName(ti.Schema, ti.Name)
+	if err != nil {
+		return errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(ti.Schema, ti.Name))
+	}
+	if findIndexByName(indexName.L, tblInfo.Indices) == nil {
+		return ErrCantDropFieldOrKey.GenWithStackByArgs(indexName)
+	}
+
+	job := &model.Job{
+		SchemaID:   schema.ID,
+		TableID:    tblInfo.ID,
+		Type:       model.ActionDropIndex,
+		BinlogInfo: &model.HistoryInfo{},
+		Args:       []interface{}{indexName},
+	}
+	return errors.Trace(d.doDDLJob(job))
+}
+
+func (d *ddl) onCreateIndex(t *meta.Meta, job *model.Job) (ver int64, err error) {
+	// Handle the rolling back job.
+	if job.IsRollingback() {
+		return onDropIndex(t, job)
+	}
+
+	schemaID := job.SchemaID
+	tblInfo, err := getTableInfo(t, job, schemaID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+
+	var (
+		unique      bool
+		indexName   model.CIStr
+		idxColNames []*ast.IndexColName
+	)
+	err = job.DecodeArgs(&unique, &indexName, &idxColNames)
+	if err != nil {
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+
+	indexInfo := findIndexByName(indexName.L, tblInfo.Indices)
+	if indexInfo != nil && indexInfo.State == model.StatePublic {
+		job.State = model.JobStateCancelled
+		return ver, infoschema.ErrKeyNameDuplicate.GenWithStackByArgs(indexName)
+	}
+
+	if indexInfo == nil {
+		indexInfo, err = buildIndexInfo(tblInfo, indexName, idxColNames, model.StateNone)
+		if err != nil {
+			job.State = model.JobStateCancelled
+			return ver, errors.Trace(err)
+		}
+		indexInfo.Tp = model.IndexTypeBtree
+		indexInfo.Unique = unique
+		indexInfo.ID = allocateIndexID(tblInfo)
+		tblInfo.Indices = append(tblInfo.Indices, indexInfo)
+		log.Infof("[ddl] add index, run DDL job %s, index info %#v", job, indexInfo)
+	}
+	switch indexInfo.State {
+	case model.StateNone:
+		// none -> delete only
+		job.SchemaState = model.StateDeleteOnly
+		indexInfo.State = model.StateDeleteOnly
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+	case model.StateDeleteOnly:
+		// delete only -> write only
+		job.SchemaState = model.StateWriteOnly
+		indexInfo.State = model.StateWriteOnly
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+	case model.StateWriteOnly:
+		// write only -> reorganization
+		job.SchemaState = model.StateWriteReorganization
+		indexInfo.State = model.StateWriteReorganization
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+	case model.StateWriteReorganization:
+		// reorganization -> public, the entries are backfilled by the worker before this step.
+		if d.reorgJobID != job.ID {
+			return ver, nil
+		}
+		if err = d.reorgErr; err != nil {
+			if kv.ErrKeyExists.Equal(err) || errCancelledDDLJob.Equal(err) {
+				// The index which can't be built is removed, for example, the existing rows have
+				// duplicate values of the unique index.
+				log.Warnf("[ddl] run DDL job %v err %v, convert job to rollback job", job, err)
+				return convertAddIdxJob2RollbackJob(t, job, err)
+			}
+			// The backfill is run again.
+			d.reorgJobID = 0
+			return ver, errors.Trace(err)
+		}
+
+		indexInfo.State = model.StatePublic
+		// Set column index flag.
+		addIndexColumnFlag(tblInfo, indexInfo)
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+		if err != nil {
+			return ver, errors.Trace(err)
+		}
+		// Finish this job.
+		job.FinishTableJob(model.JobStateDone, model.StatePublic, ver, tblInfo)
+	default:
+		err = errInvalidJobState.GenWithStackByArgs(indexInfo.State)
+	}
+
+	return ver, errors.Trace(err)
+}
+
+// convertAddIdxJob2RollbackJob rolls back the job which adds the index, the index is delete only
+// and it's removed by onDropIndex later.
+func convertAddIdxJob2RollbackJob(t *meta.Meta, job *model.Job, cause error) (ver int64, err error) {
+	tblInfo, err := getTableInfo(t, job, job.SchemaID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	var (
+		unique      bool
+		indexName   model.CIStr
+		idxColNames []*ast.IndexColName
+	)
+	if err = job.DecodeArgs(&unique, &indexName, &idxColNames); err != nil {
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+	indexInfo := findIndexByName(indexName.L, tblInfo.Indices)
+	if indexInfo == nil {
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(cause)
+	}
+
+	job.SchemaState = model.StateDeleteOnly
+	indexInfo.State = model.StateDeleteOnly
+	if ver, err = updateVersionAndTableInfo(t, job, tblInfo); err != nil {
+		return ver, errors.Trace(err)
+	}
+	job.State = model.JobStateRollingback
+	job.Args = []interface{}{indexInfo.Name}
+	job.Error = toTError(cause)
+	job.ErrorCount++
+	return ver, nil
+}
+
+// onDropIndex removes the index, the ID of the index is appended to the args, its entries are
+// deleted after the job is finished.
+func onDropIndex(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	schemaID := job.SchemaID
+	tblInfo, err := getTableInfo(t, job, schemaID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+
+	var indexName model.CIStr
+	if err = job.DecodeArgs(&indexName); err != nil {
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+
+	indexInfo := findIndexByName(indexName.L, tblInfo.Indices)
+	if indexInfo == nil {
+		job.State = model.JobStateCancelled
+		return ver, ErrCantDropFieldOrKey.GenWithStackByArgs(indexName)
+	}
+
+	switch indexInfo.State {
+	case model.StatePublic:
+		// public -> write only
+		job.SchemaState = model.StateWriteOnly
+		indexInfo.State = model.StateWriteOnly
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+	case model.StateWriteOnly:
+		// write only -> delete only
+		job.SchemaState = model.StateDeleteOnly
+		indexInfo.State = model.StateDeleteOnly
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+	case model.StateDeleteOnly:
+		// delete only -> absent
+		newIndices := make([]*model.IndexInfo, 0, len(tblInfo.Indices))
+		for _, idx := range tblInfo.Indices {
+			if idx.Name.L != indexName.L {
+				newIndices = append(newIndices, idx)
+			}
+		}
+		tblInfo.Indices = newIndices
+		// Set column index flag.
+		dropIndexColumnFlag(tblInfo, indexInfo)
+
+		ver, err = updateVersionAndTableInfo(t, job, tblInfo)
+		if err != nil {
+			return ver, errors.Trace(err)
+		}
+
+		// Finish this job.
+		job.Args = append(job.Args, indexInfo.ID)
+		if job.IsRollingback() {
+			job.FinishTableJob(model.JobStateRollbackDone, model.StateNone, ver, tblInfo)
+		} else {
+			job.FinishTableJob(model.JobStateDone, model.StateNone, ver, tblInfo)
+		}
+	default:
+		err = errInvalidJobState.GenWithStackByArgs(indexInfo.State)
+	}
+	return ver, errors.Trace(err)
+}
+
+// backfillIndex adds the entries of the existing rows to the index of the job in batches, each
+// batch is a transaction. The rows read by a batch are locked, so that it conflicts with the
+// concurrent writes of the rows and is retried, instead of adding the entries of the old values.
+// The batches stop if the job is cancelled, and the number of the rows is saved in the job.
+func (d *ddl) backfillIndex(job *model.Job) error {
+	var tblInfo *model.TableInfo
+	err := kv.RunInNewTxn(d.store, false, func(txn kv.Transaction) error {
+		var err error
+		tblInfo, err = getTableInfo(meta.NewMeta(txn), job, job.SchemaID)
+		return errors.Trace(err)
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var idxInfo *model.IndexInfo
+	for _, idx := range tblInfo.Indices {
+		if idx.State == model.StateWriteReorganization {
+			idxInfo = idx
+		}
+	}
+	if idxInfo == nil {
+		return errInvalidJobState.GenWithStack("no index of table %s in write reorganization state", tblInfo.Name)
+	}
+
+	t, err := tables.TableFromMeta(nil, tblInfo)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	idx := tables.NewIndex(tblInfo, idxInfo)
+	startTime := time.Now()
+	startKey := t.RecordPrefix()
+	for startKey != nil {
+		var nextKey kv.Key
+		err = kv.RunInNewTxn(d.store, true, func(txn kv.Transaction) error {
+			nextKey = nil
+			m := meta.NewMeta(txn)
+			queued, err := m.GetDDLJobByIdx(0)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if queued == nil || queued.ID != job.ID {
+				return errInvalidDDLJob.GenWithStack("DDL job %d is not running", job.ID)
+			}
+			if queued.IsCancelling() {
+				return errCancelledDDLJob
+			}
+			ctx := newReorgContext(d, txn)
+			count := 0
+			err = t.IterRecords(ctx, startKey, t.Cols(), func(h int64, rec []types.Datum, cols []*table.Column) (bool, error) {
+				if count == reorgBatchSize {
+					nextKey = t.RecordKey(h)
+					return false, nil
+				}
+				count++
+				if err := txn.LockKeys(goctx.Background(), t.RecordKey(h)); err != nil {
+					return false, errors.Trace(err)
+				}
+				vals, err := idx.FetchValues(rec, nil)
+				if err != nil {
+					return false, errors.Trace(err)
+				}
+				// The entry may be added by a concurrent write already.
+				dupHandle, err := idx.Create(ctx, txn, vals, h)
+				if kv.ErrKeyExists.Equal(err) && dupHandle != h {
+					entryKey, err1 := tables.GenIndexKeyStr(vals)
+					if err1 != nil {
+						return false, errors.Trace(err1)
+					}
+					return false, kv.ErrKeyExists.FastGen("Duplicate entry '%s' for key '%s'", entryKey, idxInfo.Name)
+				}
+				if err != nil && !kv.ErrKeyExists.Equal(err) {
+					return false, errors.Trace(err)
+				}
+				return true, nil
+			})
+			if err != nil {
+				return errors.Trace(err)
+			}
+			queued.SetRowCount(queued.GetRowCount() + int64(count))
+			return errors.Trace(m.UpdateDDLJob(0, queued, false))
+		})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		startKey = nextKey
+	}
+	log.Infof("[ddl] backfill index %s of table %s in %v", idxInfo.Name, tblInfo.Name, time.Since(startTime))
+	return nil
+}
+
+// reorgContext is the context of the transactions which reorganize the data of the tables.
+type reorgContext struct {
+	d    *ddl
+	txn  kv.Transaction
+	vars *variable.SessionVars
+}
+
+func newReorgContext(d *ddl, txn kv.Transaction) *reorgContext {
+	vars := variable.NewSessionVars()
+	vars.StmtCtx = &stmtctx.StatementContext{TimeZone: time.Local}
+	return &reorgContext{d: d, txn: txn, vars: vars}
+}
+
+// Txn implements sessionctx.Context Txn interface.
+func (c *reorgContext) Txn() (kv.Transaction, error) {
+	return c.txn, nil
+}
+
+// GetStore implements sessionctx.Context GetStore interface.
+func (c *reorgContext) GetStore() kv.Storage {
+	return c.d.store
+}
+
+// GetSessionVars implements sessionctx.Context GetSessionVars interface.
+func (c *reorgContext) GetSessionVars() *variable.SessionVars {
+	return c.vars
+}
+
+// GetSessionManager implements sessionctx.Context GetSessionManager interface.
+func (c *reorgContext) GetSessionManager() util.SessionManager {
+	return nil
+}
+
+// GetInfoSchema implements sessionctx.Context GetInfoSchema interface.
+func (c *reorgContext) GetInfoSchema() infoschema.InfoSchema {
+	return c.d.GetInformationSchema()
+}
+
+// GetPrivilegeManager implements sessionctx.Context GetPrivilegeManager interface.
+func (c *reorgContext) GetPrivilegeManager() privilege.Manager {
+	return nil
+}
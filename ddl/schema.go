@@ -0,0 +1,90 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/schema.go
+//
+
+package ddl
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"fedb/infoschema"
+	"fedb/meta"
+)
+
+func onCreateSchema(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	schemaID := job.SchemaID
+	dbInfo := &model.DBInfo{}
+	if err := job.DecodeArgs(dbInfo); err != nil {
+		// Invalid arguments, cancel this job.
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+	dbInfo.ID = schemaID
+
+	dbs, err := t.ListDatabases()
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	for _, db := range dbs {
+		if db.Name.L == dbInfo.Name.L {
+			// The database already exists, can't create it, we should cancel this job now.
+			job.State = model.JobStateCancelled
+			return ver, infoschema.ErrDatabaseExists.GenWithStackByArgs(db.Name)
+		}
+	}
+
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return ver, errors.Trace(err)
+	}
+	dbInfo.State = model.StatePublic
+	if err = t.CreateDatabase(dbInfo); err != nil {
+		return ver, errors.Trace(err)
+	}
+	job.FinishDBJob(model.JobStateDone, model.StatePublic, ver, dbInfo)
+	return ver, nil
+}
+
+// onDropSchema drops the database with its tables, the IDs of the tables are appended to the args,
+// their data is deleted after the job is finished.
+func onDropSchema(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	dbInfo, err := t.GetDatabase(job.SchemaID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	if dbInfo == nil {
+		job.State = model.JobStateCancelled
+		return ver, infoschema.ErrDatabaseNotExists.GenWithStackByArgs("")
+	}
+
+	tables, err := t.ListTables(dbInfo.ID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	tableIDs := make([]int64, 0, len(tables))
+	for _, tblInfo := range tables {
+		tableIDs = append(tableIDs, tblInfo.ID)
+	}
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return ver, errors.Trace(err)
+	}
+	if err = t.DropDatabase(dbInfo.ID); err != nil {
+		return ver, errors.Trace(err)
+	}
+	dbInfo.State = model.StateNone
+	job.Args = append(job.Args, tableIDs)
+	job.FinishDBJob(model.JobStateDone, model.StateNone, ver, dbInfo)
+	return ver, nil
+}
@@ -0,0 +1,207 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/ddl/table.go
+//
+
+package ddl
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/meta"
+)
+
+func onCreateTable(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	schemaID := job.SchemaID
+	tbInfo := &model.TableInfo{}
+	if err := job.DecodeArgs(tbInfo); err != nil {
+		// Invalid arguments, cancel this job.
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+
+	err := checkTableNotExists(t, job, schemaID, tbInfo.Name.L)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return ver, errors.Trace(err)
+	}
+	tbInfo.State = model.StatePublic
+	tbInfo.UpdateTS = t.StartTS
+	if err = t.CreateTable(schemaID, tbInfo); err != nil {
+		return ver, errors.Trace(err)
+	}
+	// The first auto_increment ID is the AUTO_INCREMENT table option.
+	if tbInfo.AutoIncID > 1 {
+		if _, err = t.GenAutoTableID(schemaID, tbInfo.ID, tbInfo.AutoIncID-1); err != nil {
+			return ver, errors.Trace(err)
+		}
+	}
+	job.FinishTableJob(model.JobStateDone, model.StatePublic, ver, tbInfo)
+	return ver, nil
+}
+
+// onDropTable drops the table, its data is deleted after the job is finished.
+func onDropTable(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	schemaID := job.SchemaID
+	tableID := job.TableID
+
+	// Check this table's database.
+	tblInfo, err := t.GetTable(schemaID, tableID)
+	if err != nil {
+		if meta.ErrDBNotExists.Equal(err) {
+			job.State = model.JobStateCancelled
+			return ver, errors.Trace(infoschema.ErrDatabaseNotExists.GenWithStackByArgs(
+				fmt.Sprintf("(Schema ID %d)", schemaID),
+			))
+		}
+		return ver, errors.Trace(err)
+	}
+
+	// Check the table.
+	if tblInfo == nil {
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(infoschema.ErrTableNotExists.GenWithStackByArgs(
+			fmt.Sprintf("(Schema ID %d)", schemaID),
+			fmt.Sprintf("(Table ID %d)", tableID),
+		))
+	}
+
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return ver, errors.Trace(err)
+	}
+	if err = t.DropTable(schemaID, tableID); err != nil {
+		return ver, errors.Trace(err)
+	}
+	tblInfo.State = model.StateNone
+	job.FinishTableJob(model.JobStateDone, model.StateNone, ver, tblInfo)
+	return ver, nil
+}
+
+// onRenameTable moves the table to the database of the job with the new name, the args are
+// the ID of the old database and the new name. The auto_increment ID of the table is kept.
+func onRenameTable(t *meta.Meta, job *model.Job) (ver int64, _ error) {
+	var oldSchemaID int64
+	var tableName model.CIStr
+	if err := job.DecodeArgs(&oldSchemaID, &tableName); err != nil {
+		// Invalid arguments, cancel this job.
+		job.State = model.JobStateCancelled
+		return ver, errors.Trace(err)
+	}
+
+	tblInfo, err := getTableInfo(t, job, oldSchemaID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	newSchemaID := job.SchemaID
+	if err = checkTableNotExists(t, job, newSchemaID, tableName.L); err != nil {
+		return ver, errors.Trace(err)
+	}
+
+	// The auto_increment ID is dropped with the table, it's saved in the new database.
+	baseID, err := t.GetAutoTableID(oldSchemaID, tblInfo.ID)
+	if err != nil {
+		return ver, errors.Trace(err)
+	}
+	if err = t.DropTable(oldSchemaID, tblInfo.ID); err != nil {
+		return ver, errors.Trace(err)
+	}
+	tblInfo.Name = tableName
+	if err = t.CreateTable(newSchemaID, tblInfo); err != nil {
+		return ver, errors.Trace(err)
+	}
+	if _, err = t.GenAutoTableID(newSchemaID, tblInfo.ID, baseID); err != nil {
+		return ver, errors.Trace(err)
+	}
+
+	if ver, err = updateSchemaVersion(t); err != nil {
+		return ver, errors.Trace(err)
+	}
+	job.FinishTableJob(model.JobStateDone, model.StatePublic, ver, tblInfo)
+	return ver, nil
+}
+
+func checkTableNotExists(t *meta.Meta, job *model.Job, schemaID int64, tableName string) error {
+	// Check this table's database.
+	tables, err := t.ListTables(schemaID)
+	if err != nil {
+		if meta.ErrDBNotExists.Equal(err) {
+			job.State = model.JobStateCancelled
+			return infoschema.ErrDatabaseNotExists.GenWithStackByArgs("")
+		}
+		return errors.Trace(err)
+	}
+
+	// Check the table.
+	for _, tbl := range tables {
+		if tbl.Name.L == tableName {
+			// This table already exists and can't be created, we should cancel this job now.
+			job.State = model.JobStateCancelled
+			return infoschema.ErrTableExists.GenWithStackByArgs(tbl.Name)
+		}
+	}
+
+	return nil
+}
+
+// deleteRange deletes the keys with the prefix in batches, each batch is a transaction.
+func (d *ddl) deleteRange(prefix kv.Key) error {
+	for {
+		var keys []kv.Key
+		err := kv.RunInNewTxn(d.store, true, func(txn kv.Transaction) error {
+			var err error
+			// Collect the keys first, the buffer should not be changed during the iteration.
+			if keys, err = collectKeys(txn, prefix, reorgBatchSize); err != nil {
+				return errors.Trace(err)
+			}
+			for _, k := range keys {
+				if err = txn.Delete(k); err != nil {
+					return errors.Trace(err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if len(keys) < reorgBatchSize {
+			return nil
+		}
+	}
+}
+
+// collectKeys returns the keys with the prefix in txn, at most limit keys are returned
+// if limit is positive.
+func collectKeys(txn kv.Transaction, prefix kv.Key, limit int) ([]kv.Key, error) {
+	it, err := txn.Iter(prefix, prefix.PrefixNext())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer it.Close()
+	var keys []kv.Key
+	for it.Valid() && it.Key().HasPrefix(prefix) && (limit <= 0 || len(keys) < limit) {
+		keys = append(keys, it.Key().Clone())
+		if err = it.Next(); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return keys, nil
+}
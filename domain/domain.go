@@ -0,0 +1,179 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/domain/domain.go
+//
+
+package domain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/terror"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/ddl"
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/meta"
+	"fedb/privilege"
+)
+
+// Domain represents a storage space. Different domains can use the same database name.
+// Multiple domains can be used in parallel without synchronization.
+type Domain struct {
+	store      kv.Storage
+	infoHandle *infoschema.Handle
+	ddl        ddl.DDL
+	privHandle *privilege.Handle
+	// m serializes the reloads of the InfoSchema.
+	m sync.Mutex
+}
+
+// loadInfoSchema loads infoschema at startTS into handle, usedSchemaVersion is the currently used
+// infoschema version, if it is the same as the schema version at startTS, we don't need to reload again.
+// It returns the latest schema version.
+func (do *Domain) loadInfoSchema(handle *infoschema.Handle, usedSchemaVersion int64, startTS uint64) (int64, error) {
+	snapshot, err := do.store.GetSnapshot(kv.NewVersion(startTS))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	m := meta.NewSnapshotMeta(snapshot)
+	latestSchemaVersion, err := m.GetSchemaVersion()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if usedSchemaVersion != 0 && usedSchemaVersion == latestSchemaVersion {
+		return latestSchemaVersion, nil
+	}
+
+	startTime := time.Now()
+	schemas, err := do.fetchAllSchemasWithTables(m)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	infoschema.NewBuilder(handle).InitWithDBInfos(schemas, latestSchemaVersion).Build()
+	log.Infof("[ddl] full load InfoSchema from version %d to %d, in %v",
+		usedSchemaVersion, latestSchemaVersion, time.Since(startTime))
+	return latestSchemaVersion, nil
+}
+
+func (do *Domain) fetchAllSchemasWithTables(m *meta.Meta) ([]*model.DBInfo, error) {
+	allSchemas, err := m.ListDatabases()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, di := range allSchemas {
+		tables, err := m.ListTables(di.ID)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		di.Tables = make([]*model.TableInfo, 0, len(tables))
+		for _, tbl := range tables {
+			if tbl.State != model.StatePublic {
+				// Only load the public tables.
+				continue
+			}
+			di.Tables = append(di.Tables, tbl)
+		}
+	}
+	return allSchemas, nil
+}
+
+// InfoSchema gets information schema from domain.
+func (do *Domain) InfoSchema() infoschema.InfoSchema {
+	return do.infoHandle.Get()
+}
+
+// DDL gets DDL from domain.
+func (do *Domain) DDL() ddl.DDL {
+	return do.ddl
+}
+
+// PrivilegeHandle returns the cache of the privilege tables.
+func (do *Domain) PrivilegeHandle() *privilege.Handle {
+	return do.privHandle
+}
+
+// Store gets KV store from domain.
+func (do *Domain) Store() kv.Storage {
+	return do.store
+}
+
+// Reload reloads InfoSchema.
+// It's public in order to do the test.
+func (do *Domain) Reload() error {
+	// Lock here for only once at the same time.
+	do.m.Lock()
+	defer do.m.Unlock()
+
+	ver, err := do.store.CurrentVersion()
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	schemaVersion := int64(0)
+	oldInfoSchema := do.infoHandle.Get()
+	if oldInfoSchema != nil {
+		schemaVersion = oldInfoSchema.SchemaMetaVersion()
+	}
+
+	_, err = do.loadInfoSchema(do.infoHandle, schemaVersion, ver.Ver)
+	return errors.Trace(err)
+}
+
+// Close closes the Domain.
+func (do *Domain) Close() {
+	terror.Log(errors.Trace(do.ddl.Stop()))
+	log.Info("[domain] close")
+}
+
+type ddlCallback struct {
+	ddl.BaseCallback
+	do *Domain
+}
+
+// OnChanged reloads the InfoSchema after the schema is changed.
+func (c *ddlCallback) OnChanged(err error) error {
+	if err != nil {
+		return err
+	}
+	log.Infof("[ddl] on DDL change, must reload")
+
+	err = c.do.Reload()
+	if err != nil {
+		log.Errorf("[ddl] on DDL change reload err %v", err)
+	}
+
+	return nil
+}
+
+// NewDomain creates a new domain. Should not create multiple domains for the same store.
+func NewDomain(store kv.Storage) *Domain {
+	do := &Domain{
+		store:      store,
+		infoHandle: infoschema.NewHandle(store),
+		privHandle: privilege.NewHandle(),
+	}
+	do.ddl = ddl.NewDDL(store, do.infoHandle, &ddlCallback{do: do})
+	return do
+}
+
+// Init initializes a domain, the InfoSchema is loaded from the store.
+func (do *Domain) Init() error {
+	return errors.Trace(do.Reload())
+}
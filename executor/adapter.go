@@ -0,0 +1,144 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/adapter.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/terror"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/infoschema"
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+	"fedb/util/sqlexec"
+)
+
+// recordSet wraps an executor, implements sqlexec.RecordSet interface
+type recordSet struct {
+	fields   []*ast.ResultField
+	executor Executor
+	stmt     *ExecStmt
+}
+
+func (a *recordSet) Fields() []*ast.ResultField {
+	if len(a.fields) == 0 {
+		a.fields = schema2ResultFields(a.executor.Schema(), a.stmt.Ctx.GetSessionVars().CurrentDB)
+	}
+	return a.fields
+}
+
+func schema2ResultFields(schema *expression.Schema, defaultDB string) (rfs []*ast.ResultField) {
+	rfs = make([]*ast.ResultField, 0, schema.Len())
+	for _, col := range schema.Columns {
+		dbName := col.DBName.O
+		if dbName == "" && col.TblName.L != "" {
+			dbName = defaultDB
+		}
+		origColName := col.OrigColName
+		if origColName.L == "" {
+			origColName = col.ColName
+		}
+		rf := &ast.ResultField{
+			ColumnAsName: col.ColName,
+			TableAsName:  col.TblName,
+			DBName:       model.NewCIStr(dbName),
+			Table:        &model.TableInfo{Name: col.OrigTblName},
+			Column: &model.ColumnInfo{
+				FieldType: *col.RetType,
+				Name:      origColName,
+			},
+		}
+		rfs = append(rfs, rf)
+	}
+	return rfs
+}
+
+// Next uses recordSet's executor to get the next batch of rows.
+func (a *recordSet) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(a.executor.Next(goCtx, req))
+}
+
+// NewRecordBatch creates a new recordBatch from the executor's first chunk.
+func (a *recordSet) NewRecordBatch() *chunk.RecordBatch {
+	return chunk.NewRecordBatch(a.executor.newFirstChunk())
+}
+
+func (a *recordSet) Close() error {
+	return errors.Trace(a.executor.Close())
+}
+
+// ExecStmt is a statement compiled into a physical plan, it builds the executors when it is executed.
+type ExecStmt struct {
+	// InfoSchema stores a reference to the schema information.
+	InfoSchema infoschema.InfoSchema
+	// Plan stores a reference to the final physical plan.
+	Plan plannercore.Plan
+	// Text represents the origin query text.
+	Text string
+
+	StmtNode ast.StmtNode
+
+	Ctx sessionctx.Context
+}
+
+// OriginText returns original statement as a string.
+func (a *ExecStmt) OriginText() string {
+	return a.Text
+}
+
+// Exec builds an Executor from a plan. The executor is opened and wrapped in a
+// RecordSet, whose rows are read by the caller. An executor which returns no rows,
+// such as the one of INSERT, is executed at once and the RecordSet is nil.
+func (a *ExecStmt) Exec(goCtx goctx.Context) (sqlexec.RecordSet, error) {
+	e, err := a.buildExecutor()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = e.Open(goCtx); err != nil {
+		terror.Call(e.Close)
+		return nil, errors.Trace(err)
+	}
+	if e.Schema().Len() == 0 {
+		return nil, errors.Trace(a.handleNoDelayExecutor(goCtx, e))
+	}
+	return &recordSet{
+		executor: e,
+		stmt:     a,
+	}, nil
+}
+
+func (a *ExecStmt) handleNoDelayExecutor(goCtx goctx.Context, e Executor) error {
+	err := e.Next(goCtx, chunk.NewRecordBatch(e.newFirstChunk()))
+	if closeErr := e.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Trace(err)
+}
+
+// buildExecutor build a executor from plan.
+func (a *ExecStmt) buildExecutor() (Executor, error) {
+	b := newExecutorBuilder(a.Ctx, a.InfoSchema)
+	e := b.build(a.Plan)
+	if b.err != nil {
+		return nil, errors.Trace(b.err)
+	}
+	return e, nil
+}
@@ -0,0 +1,343 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/aggregate.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+	"fedb/util/chunk"
+)
+
+var (
+	_ Executor = &HashAggExec{}
+	_ Executor = &StreamAggExec{}
+)
+
+// groupKeyEvaluator evaluates the group by items on a whole chunk of the child at a time.
+type groupKeyEvaluator struct {
+	items  []expression.Expression
+	types  []*types.FieldType
+	values *chunk.Chunk
+}
+
+func newGroupKeyEvaluator(items []expression.Expression, maxChunkSize int) *groupKeyEvaluator {
+	g := &groupKeyEvaluator{
+		items: items,
+		types: make([]*types.FieldType, 0, len(items)),
+	}
+	for _, item := range items {
+		g.types = append(g.types, item.GetType())
+	}
+	g.values = chunk.NewChunkWithCapacity(g.types, maxChunkSize)
+	return g
+}
+
+// eval evaluates the group by items on the rows of input.
+func (g *groupKeyEvaluator) eval(input *chunk.Chunk) error {
+	return errors.Trace(expression.VectorizedExecute(g.items, input, g.values))
+}
+
+// getValue returns the value of the i-th group by item of the row.
+func (g *groupKeyEvaluator) getValue(row, i int) types.Datum {
+	return g.values.GetRow(row).GetDatum(i, g.types[i])
+}
+
+// appendAggResults appends a row of the results of the aggregation functions to req.
+func appendAggResults(sc *stmtctx.StatementContext, req *chunk.RecordBatch, aggFuncs []aggregation.Aggregation,
+	aggCtxs []*aggregation.AggEvaluateContext, retTypes []*types.FieldType) error {
+	for i, af := range aggFuncs {
+		// The value may not fit the return type, like the Enum value of MAX() on an ENUM column.
+		d, err := expression.FitDatum(sc, af.GetResult(aggCtxs[i]), retTypes[i])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		req.AppendDatum(i, &d)
+	}
+	return nil
+}
+
+// HashAggExec deals with all the aggregate functions.
+// It is built from the Aggregate Plan. When Next() is called, it reads all the data from Src
+// and updates all the items in AggFuncs, the groups are returned in the order they are met.
+type HashAggExec struct {
+	baseExecutor
+
+	sc           *stmtctx.StatementContext
+	AggFuncs     []aggregation.Aggregation
+	GroupByItems []expression.Expression
+
+	executed    bool
+	groupKeys   []string
+	groupMap    map[string][]*aggregation.AggEvaluateContext
+	cursor      int
+	childResult *chunk.Chunk
+	groupKey    *groupKeyEvaluator
+	keyBuf      []byte
+}
+
+// Open implements the Executor Open interface.
+func (e *HashAggExec) Open(goCtx goctx.Context) error {
+	e.executed = false
+	e.groupKeys = nil
+	e.groupMap = make(map[string][]*aggregation.AggEvaluateContext)
+	e.cursor = 0
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.childResult = e.children[0].newFirstChunk()
+	e.groupKey = newGroupKeyEvaluator(e.GroupByItems, e.maxChunkSize)
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *HashAggExec) Close() error {
+	e.groupKeys = nil
+	e.groupMap = nil
+	e.childResult = nil
+	e.groupKey = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *HashAggExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if !e.executed {
+		if err := e.execute(goCtx); err != nil {
+			return errors.Trace(err)
+		}
+		e.executed = true
+	}
+	for ; e.cursor < len(e.groupKeys) && !req.IsFull(); e.cursor++ {
+		aggCtxs := e.groupMap[e.groupKeys[e.cursor]]
+		if err := appendAggResults(e.sc, req, e.AggFuncs, aggCtxs, e.retTypes()); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// execute reads all the rows of the child and updates the groups.
+func (e *HashAggExec) execute(goCtx goctx.Context) error {
+	for {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		err := e.children[0].Next(goCtx, chunk.NewRecordBatch(e.childResult))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if e.childResult.NumRows() == 0 {
+			break
+		}
+		if err = e.groupKey.eval(e.childResult); err != nil {
+			return errors.Trace(err)
+		}
+		for i := 0; i < e.childResult.NumRows(); i++ {
+			groupKey, err := e.getGroupKey(i)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			aggCtxs := e.getContexts(groupKey)
+			row := e.childResult.GetRow(i)
+			for j, af := range e.AggFuncs {
+				if err = af.Update(aggCtxs[j], e.sc, row); err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+	}
+	// The aggregation without group by items returns a row for the empty input.
+	if len(e.groupKeys) == 0 && len(e.GroupByItems) == 0 {
+		e.getContexts("")
+	}
+	return nil
+}
+
+// getGroupKey encodes the group by values of the row-th row of the child.
+func (e *HashAggExec) getGroupKey(row int) (string, error) {
+	if len(e.GroupByItems) == 0 {
+		return "", nil
+	}
+	vals := make([]types.Datum, 0, len(e.GroupByItems))
+	for i := range e.GroupByItems {
+		vals = append(vals, e.groupKey.getValue(row, i))
+	}
+	var err error
+	e.keyBuf, err = codec.EncodeValue(e.sc, e.keyBuf[:0], vals...)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return string(e.keyBuf), nil
+}
+
+func (e *HashAggExec) getContexts(groupKey string) []*aggregation.AggEvaluateContext {
+	aggCtxs, ok := e.groupMap[groupKey]
+	if !ok {
+		aggCtxs = make([]*aggregation.AggEvaluateContext, 0, len(e.AggFuncs))
+		for _, af := range e.AggFuncs {
+			aggCtxs = append(aggCtxs, af.CreateContext(e.sc))
+		}
+		e.groupMap[groupKey] = aggCtxs
+		e.groupKeys = append(e.groupKeys, groupKey)
+	}
+	return aggCtxs
+}
+
+// StreamAggExec deals with all the aggregate functions.
+// It assumes all the input data is sorted by group by key.
+// When Next() is called, it returns the results of the groups until req is full.
+type StreamAggExec struct {
+	baseExecutor
+
+	sc           *stmtctx.StatementContext
+	AggFuncs     []aggregation.Aggregation
+	GroupByItems []expression.Expression
+
+	executed bool
+	// hasData is true if there is any row read from the child for the current group.
+	hasData bool
+	aggCtxs []*aggregation.AggEvaluateContext
+	// curGroupKey is the group values of the current group.
+	curGroupKey []types.Datum
+
+	childResult *chunk.Chunk
+	groupKey    *groupKeyEvaluator
+	// inputRow is the position of the next row of childResult to be aggregated.
+	inputRow int
+}
+
+// Open implements the Executor Open interface.
+func (e *StreamAggExec) Open(goCtx goctx.Context) error {
+	e.executed = false
+	e.hasData = false
+	e.curGroupKey = nil
+	e.inputRow = 0
+	e.aggCtxs = make([]*aggregation.AggEvaluateContext, 0, len(e.AggFuncs))
+	for _, agg := range e.AggFuncs {
+		e.aggCtxs = append(e.aggCtxs, agg.CreateContext(e.sc))
+	}
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.childResult = e.children[0].newFirstChunk()
+	e.groupKey = newGroupKeyEvaluator(e.GroupByItems, e.maxChunkSize)
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *StreamAggExec) Close() error {
+	e.childResult = nil
+	e.groupKey = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *StreamAggExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for !e.executed && !req.IsFull() {
+		if err := e.consumeOneGroup(goCtx, req); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// consumeOneGroup aggregates the rows of the current group and appends its results to req.
+func (e *StreamAggExec) consumeOneGroup(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	for {
+		if e.inputRow >= e.childResult.NumRows() {
+			if err := goCtx.Err(); err != nil {
+				return errors.Trace(err)
+			}
+			err := e.children[0].Next(goCtx, chunk.NewRecordBatch(e.childResult))
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if e.childResult.NumRows() == 0 {
+				e.executed = true
+				if !e.hasData && len(e.GroupByItems) > 0 {
+					return nil
+				}
+				return errors.Trace(e.appendResult(req))
+			}
+			if err = e.groupKey.eval(e.childResult); err != nil {
+				return errors.Trace(err)
+			}
+			e.inputRow = 0
+		}
+		newGroup, err := e.meetNewGroup(e.inputRow)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if newGroup && e.hasData {
+			// The row is the first one of the next group, the current group is returned.
+			e.hasData = false
+			return errors.Trace(e.appendResult(req))
+		}
+		e.hasData = true
+		row := e.childResult.GetRow(e.inputRow)
+		for i, af := range e.AggFuncs {
+			if err = af.Update(e.aggCtxs[i], e.sc, row); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		e.inputRow++
+	}
+}
+
+// appendResult appends the results of the current group to req and resets the contexts.
+func (e *StreamAggExec) appendResult(req *chunk.RecordBatch) error {
+	if err := appendAggResults(e.sc, req, e.AggFuncs, e.aggCtxs, e.retTypes()); err != nil {
+		return errors.Trace(err)
+	}
+	for i, af := range e.AggFuncs {
+		af.ResetContext(e.sc, e.aggCtxs[i])
+	}
+	return nil
+}
+
+// meetNewGroup returns a value that represents if the row-th row of the child starts a new group.
+func (e *StreamAggExec) meetNewGroup(row int) (bool, error) {
+	if len(e.GroupByItems) == 0 {
+		return false, nil
+	}
+	groupKey := make([]types.Datum, 0, len(e.GroupByItems))
+	matched := e.curGroupKey != nil
+	for i := range e.GroupByItems {
+		v := e.groupKey.getValue(row, i)
+		if matched {
+			c, err := v.CompareDatum(e.sc, &e.curGroupKey[i])
+			if err != nil {
+				return false, errors.Trace(err)
+			}
+			matched = c == 0
+		}
+		// The values are kept after the chunk of the child is reused.
+		groupKey = append(groupKey, types.CopyDatum(v))
+	}
+	if matched {
+		return false, nil
+	}
+	e.curGroupKey = groupKey
+	return true, nil
+}
@@ -0,0 +1,516 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/builder.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+	"fedb/infoschema"
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+	"fedb/table"
+	"fedb/table/tables"
+)
+
+// executorBuilder builds an Executor from a Plan.
+// The InfoSchema must not change during execution.
+type executorBuilder struct {
+	ctx sessionctx.Context
+	is  infoschema.InfoSchema
+	err error // err is set when there is error happened during Executor building process.
+}
+
+func newExecutorBuilder(ctx sessionctx.Context, is infoschema.InfoSchema) *executorBuilder {
+	return &executorBuilder{
+		ctx: ctx,
+		is:  is,
+	}
+}
+
+func (b *executorBuilder) build(p plannercore.Plan) Executor {
+	switch v := p.(type) {
+	case *plannercore.PhysicalTableScan:
+		return b.buildTableReader(v)
+	case *plannercore.PhysicalSelection:
+		return b.buildSelection(v)
+	case *plannercore.PhysicalProjection:
+		return b.buildProjection(v)
+	case *plannercore.PhysicalLimit:
+		return b.buildLimit(v)
+	case *plannercore.PhysicalTableDual:
+		return b.buildTableDual(v)
+	case *plannercore.PhysicalIndexScan:
+		return b.buildIndexReader(v)
+	case *plannercore.PhysicalMemTable:
+		return b.buildMemTable(v)
+	case *plannercore.PhysicalIndexLookUpReader:
+		return b.buildIndexLookUpReader(v)
+	case *plannercore.PhysicalSort:
+		return b.buildSort(v)
+	case *plannercore.PhysicalTopN:
+		return b.buildTopN(v)
+	case *plannercore.PhysicalHashAgg:
+		return b.buildHashAgg(v)
+	case *plannercore.PhysicalStreamAgg:
+		return b.buildStreamAgg(v)
+	case *plannercore.PhysicalUnionAll:
+		return b.buildUnionAll(v)
+	case *plannercore.PhysicalHashJoin:
+		return b.buildHashJoin(v)
+	case *plannercore.PhysicalMergeJoin:
+		return b.buildMergeJoin(v)
+	case *plannercore.PhysicalIndexJoin:
+		return b.buildIndexLookUpJoin(v)
+	case *plannercore.PhysicalApply:
+		return b.buildApply(v)
+	case *plannercore.PhysicalMaxOneRow:
+		return b.buildMaxOneRow(v)
+	case *plannercore.Insert:
+		return b.buildInsert(v)
+	case *plannercore.Update:
+		return b.buildUpdate(v)
+	case *plannercore.Delete:
+		return b.buildDelete(v)
+	case *plannercore.ShowDDLJobs:
+		return b.buildShowDDLJobs(v)
+	case *plannercore.CancelDDLJobs:
+		return b.buildCancelDDLJobs(v)
+	case *plannercore.Show:
+		return b.buildShow(v)
+	default:
+		b.err = ErrUnknownPlan.GenWithStack("Unknown Plan %T", p)
+		return nil
+	}
+}
+
+func (b *executorBuilder) buildTableReader(v *plannercore.PhysicalTableScan) Executor {
+	e, err := newTableReaderExecutor(newBaseExecutor(b.ctx, v.Schema()), v.Table, v.Columns, v.Ranges)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	return e
+}
+
+func (b *executorBuilder) buildSelection(v *plannercore.PhysicalSelection) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &SelectionExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		filters:      v.Conditions,
+	}
+}
+
+func (b *executorBuilder) buildProjection(v *plannercore.PhysicalProjection) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &ProjectionExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		exprs:        v.Exprs,
+	}
+}
+
+func (b *executorBuilder) buildLimit(v *plannercore.PhysicalLimit) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &LimitExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		begin:        v.Offset,
+		end:          v.Offset + v.Count,
+	}
+}
+
+func (b *executorBuilder) buildTableDual(v *plannercore.PhysicalTableDual) Executor {
+	if v.RowCount != 0 && v.RowCount != 1 {
+		b.err = errors.Errorf("buildTableDual failed, invalid row count for dual table: %v", v.RowCount)
+		return nil
+	}
+	return &TableDualExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema()),
+		numDualRows:  v.RowCount,
+	}
+}
+
+func (b *executorBuilder) buildMemTable(v *plannercore.PhysicalMemTable) Executor {
+	return &MemTableReaderExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema()),
+		is:           b.is,
+		table:        v.Table,
+		columns:      v.Columns,
+	}
+}
+
+func (b *executorBuilder) buildIndexReader(v *plannercore.PhysicalIndexScan) Executor {
+	e, err := newIndexReaderExecutor(newBaseExecutor(b.ctx, v.Schema()), v.Table, v.Index, v.Columns, v.Ranges)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	return e
+}
+
+func (b *executorBuilder) buildIndexLookUpReader(v *plannercore.PhysicalIndexLookUpReader) Executor {
+	e, err := newIndexLookUpExecutor(newBaseExecutor(b.ctx, v.Schema()), v.Table, v.Index, v.Columns, v.Ranges)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	return e
+}
+
+func (b *executorBuilder) buildSort(v *plannercore.PhysicalSort) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &SortExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		ByItems:      v.ByItems,
+	}
+}
+
+func (b *executorBuilder) buildTopN(v *plannercore.PhysicalTopN) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &TopNExec{
+		SortExec: SortExec{
+			baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+			ByItems:      v.ByItems,
+		},
+		limit: v,
+	}
+}
+
+func (b *executorBuilder) buildHashAgg(v *plannercore.PhysicalHashAgg) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	e := &HashAggExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		sc:           b.ctx.GetSessionVars().StmtCtx,
+		AggFuncs:     make([]aggregation.Aggregation, 0, len(v.AggFuncs)),
+		GroupByItems: v.GroupByItems,
+	}
+	for _, aggDesc := range v.AggFuncs {
+		e.AggFuncs = append(e.AggFuncs, aggDesc.GetAggFunc())
+	}
+	return e
+}
+
+func (b *executorBuilder) buildStreamAgg(v *plannercore.PhysicalStreamAgg) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	e := &StreamAggExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+		sc:           b.ctx.GetSessionVars().StmtCtx,
+		AggFuncs:     make([]aggregation.Aggregation, 0, len(v.AggFuncs)),
+		GroupByItems: v.GroupByItems,
+	}
+	for _, aggDesc := range v.AggFuncs {
+		e.AggFuncs = append(e.AggFuncs, aggDesc.GetAggFunc())
+	}
+	return e
+}
+
+func (b *executorBuilder) buildUnionAll(v *plannercore.PhysicalUnionAll) Executor {
+	childExecs := make([]Executor, len(v.Children()))
+	for i, child := range v.Children() {
+		childExecs[i] = b.build(child)
+		if b.err != nil {
+			return nil
+		}
+	}
+	return &UnionExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExecs...),
+	}
+}
+
+// buildJoinChildren builds the executors of the children of a join, the outer one is returned first.
+func (b *executorBuilder) buildJoinChildren(v plannercore.PhysicalPlan, innerChildIdx int) (outerExec, innerExec Executor) {
+	outerExec = b.build(v.Children()[1-innerChildIdx])
+	if b.err != nil {
+		return nil, nil
+	}
+	innerExec = b.build(v.Children()[innerChildIdx])
+	if b.err != nil {
+		return nil, nil
+	}
+	return outerExec, innerExec
+}
+
+// newJoinerForChildren creates the joiner of the join whose children have the types.
+func (b *executorBuilder) newJoinerForChildren(v plannercore.PhysicalPlan, joinType plannercore.JoinType, innerChildIdx int,
+	conditions, naConditions expression.CNFExprs) *joiner {
+	lhsTypes := childTypes(v.Children()[0])
+	rhsTypes := childTypes(v.Children()[1])
+	return newJoiner(b.ctx, joinType, innerChildIdx == 0, conditions, naConditions, lhsTypes, rhsTypes)
+}
+
+func childTypes(p plannercore.PhysicalPlan) []*types.FieldType {
+	tps := make([]*types.FieldType, 0, p.Schema().Len())
+	for _, col := range p.Schema().Columns {
+		tps = append(tps, col.RetType)
+	}
+	return tps
+}
+
+func (b *executorBuilder) buildHashJoin(v *plannercore.PhysicalHashJoin) Executor {
+	outerExec, innerExec := b.buildJoinChildren(v, v.InnerChildIdx)
+	if b.err != nil {
+		return nil
+	}
+	leftKeys := newJoinKeys(v.LeftJoinKeys, v.RightJoinKeys, v.LeftNAKeys, v.RightNAKeys)
+	rightKeys := newJoinKeys(v.RightJoinKeys, v.LeftJoinKeys, v.RightNAKeys, v.LeftNAKeys)
+	e := &HashJoinExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), outerExec, innerExec),
+		outerExec:    outerExec,
+		innerExec:    innerExec,
+		outerIsRight: v.InnerChildIdx == 0,
+		outerKeys:    leftKeys,
+		innerKeys:    rightKeys,
+		joinType:     v.JoinType,
+		conditions:   v.OtherConditions,
+		naConditions: v.NAConditions,
+		concurrency:  v.Concurrency,
+	}
+	if e.outerIsRight {
+		e.outerKeys, e.innerKeys = rightKeys, leftKeys
+	}
+	return e
+}
+
+func (b *executorBuilder) buildMergeJoin(v *plannercore.PhysicalMergeJoin) Executor {
+	outerExec, innerExec := b.buildJoinChildren(v, v.InnerChildIdx)
+	if b.err != nil {
+		return nil
+	}
+	outerKeys, innerKeys := v.LeftJoinKeys, v.RightJoinKeys
+	if v.InnerChildIdx == 0 {
+		outerKeys, innerKeys = innerKeys, outerKeys
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	return &MergeJoinExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), outerExec, innerExec),
+		sc:           sc,
+		outerExec:    outerExec,
+		outerKeys:    outerKeys,
+		innerIter: &mergeJoinInnerIterator{
+			sc:   sc,
+			exec: innerExec,
+			keys: innerKeys,
+		},
+		joiner: b.newJoinerForChildren(v, v.JoinType, v.InnerChildIdx, v.OtherConditions, v.NAConditions),
+	}
+}
+
+// buildIndexLookUpJoin builds the index look up join, the inner child is only used for its schema,
+// the inner rows are read by the readers built for the keys of the outer rows.
+func (b *executorBuilder) buildIndexLookUpJoin(v *plannercore.PhysicalIndexJoin) Executor {
+	outerExec := b.build(v.Children()[1-v.InnerChildIdx])
+	if b.err != nil {
+		return nil
+	}
+	outerKeys, innerKeys := v.LeftJoinKeys, v.RightJoinKeys
+	if v.InnerChildIdx == 0 {
+		outerKeys, innerKeys = innerKeys, outerKeys
+	}
+	e := &IndexLookUpJoinExec{
+		baseExecutor:  newBaseExecutor(b.ctx, v.Schema(), outerExec),
+		outerExec:     outerExec,
+		outerJoinKeys: newJoinKeys(outerKeys, innerKeys, nil, nil),
+		innerJoinKeys: newJoinKeys(innerKeys, outerKeys, nil, nil),
+		joiner:        b.newJoinerForChildren(v, v.JoinType, v.InnerChildIdx, v.OtherConditions, v.NAConditions),
+		innerSchema:   v.Children()[v.InnerChildIdx].Schema(),
+		innerTable:    v.InnerTable,
+		innerIndex:    v.InnerIndex,
+		innerCovered:  v.InnerIndexCovering,
+		innerColumns:  v.InnerColumns,
+		innerFilters:  v.InnerFilters,
+	}
+	for _, offset := range v.KeyOffsets {
+		e.lookUpKeys = append(e.lookUpKeys, outerKeys[offset])
+		e.lookUpTypes = append(e.lookUpTypes, innerKeys[offset].RetType)
+	}
+	return e
+}
+
+func (b *executorBuilder) buildApply(v *plannercore.PhysicalApply) Executor {
+	outerExec, innerExec := b.buildJoinChildren(v, v.InnerChildIdx)
+	if b.err != nil {
+		return nil
+	}
+	return &NestedLoopApplyExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), outerExec, innerExec),
+		outerExec:    outerExec,
+		innerExec:    innerExec,
+		outerSchema:  v.OuterSchema,
+		joiner:       b.newJoinerForChildren(v, v.JoinType, v.InnerChildIdx, v.OtherConditions, v.NAConditions),
+	}
+}
+
+func (b *executorBuilder) buildMaxOneRow(v *plannercore.PhysicalMaxOneRow) Executor {
+	childExec := b.build(v.Children()[0])
+	if b.err != nil {
+		return nil
+	}
+	return &MaxOneRowExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), childExec),
+	}
+}
+
+func (b *executorBuilder) buildInsert(v *plannercore.Insert) Executor {
+	alloc, ok := b.is.AllocByID(v.Table.ID)
+	if !ok {
+		b.err = errors.Errorf("Can't get the allocator of table %s.", v.Table.Name)
+		return nil
+	}
+	tbl, err := tables.TableFromMeta(alloc, v.Table)
+	if err != nil {
+		b.err = errors.Trace(err)
+		return nil
+	}
+	var children []Executor
+	if v.SelectPlan != nil {
+		selectExec := b.build(v.SelectPlan)
+		if b.err != nil {
+			return nil
+		}
+		children = append(children, selectExec)
+	}
+	columns := make([]*table.Column, 0, len(v.Columns))
+	for _, col := range v.Columns {
+		columns = append(columns, table.ToColumn(col))
+	}
+	ivs := newInsertValues(newBaseExecutor(b.ctx, nil, children...), tbl, columns, v.Lists)
+	if v.IsReplace {
+		return &ReplaceExec{InsertValues: ivs}
+	}
+	return &InsertExec{
+		InsertValues: ivs,
+		OnDuplicate:  v.OnDuplicate,
+	}
+}
+
+func (b *executorBuilder) buildUpdate(v *plannercore.Update) Executor {
+	tblID2table := b.getTables(v.TblColPosInfos)
+	if b.err != nil {
+		return nil
+	}
+	selExec := b.build(v.SelectPlan)
+	if b.err != nil {
+		return nil
+	}
+	e := &UpdateExec{
+		baseExecutor:   newBaseExecutor(b.ctx, nil, selExec),
+		OrderedList:    v.OrderedList,
+		tblID2table:    tblID2table,
+		tblColPosInfos: v.TblColPosInfos,
+		assignFlag:     make([]bool, v.SelectPlan.Schema().Len()),
+	}
+	for _, assign := range v.OrderedList {
+		e.assignFlag[assign.Col.Index] = true
+	}
+	return e
+}
+
+func (b *executorBuilder) buildDelete(v *plannercore.Delete) Executor {
+	tblID2table := b.getTables(v.TblColPosInfos)
+	if b.err != nil {
+		return nil
+	}
+	selExec := b.build(v.SelectPlan)
+	if b.err != nil {
+		return nil
+	}
+	return &DeleteExec{
+		baseExecutor:   newBaseExecutor(b.ctx, nil, selExec),
+		tblID2table:    tblID2table,
+		tblColPosInfos: v.TblColPosInfos,
+	}
+}
+
+// getTables returns the tables to write by their IDs, their allocators are rebased if the
+// auto_increment IDs are updated.
+func (b *executorBuilder) getTables(infos []plannercore.TblColPosInfo) map[int64]table.Table {
+	tblID2table := make(map[int64]table.Table, len(infos))
+	for _, info := range infos {
+		tblInfo, ok := b.is.TableByID(info.TblID)
+		if !ok {
+			b.err = errors.Errorf("Can't get table %d.", info.TblID)
+			return nil
+		}
+		alloc, _ := b.is.AllocByID(info.TblID)
+		tbl, err := tables.TableFromMeta(alloc, tblInfo)
+		if err != nil {
+			b.err = errors.Trace(err)
+			return nil
+		}
+		tblID2table[info.TblID] = tbl
+	}
+	return tblID2table
+}
+
+func (b *executorBuilder) buildShowDDLJobs(v *plannercore.ShowDDLJobs) Executor {
+	return &ShowDDLJobsExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema()),
+		is:           b.is,
+	}
+}
+
+// buildShow builds the executor of a SHOW statement, the rows are filtered by LIKE and WHERE
+// with a selection.
+func (b *executorBuilder) buildShow(v *plannercore.Show) Executor {
+	e := &ShowExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema()),
+		Tp:           v.Tp,
+		DBName:       v.DBName,
+		Table:        v.Table,
+		Column:       v.Column,
+		Full:         v.Full,
+		GlobalScope:  v.GlobalScope,
+		User:         v.User,
+		is:           b.is,
+	}
+	if len(v.Conditions) == 0 {
+		return e
+	}
+	return &SelectionExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema(), e),
+		filters:      v.Conditions,
+	}
+}
+
+func (b *executorBuilder) buildCancelDDLJobs(v *plannercore.CancelDDLJobs) Executor {
+	return &CancelDDLJobsExec{
+		baseExecutor: newBaseExecutor(b.ctx, v.Schema()),
+		jobIDs:       v.JobIDs,
+	}
+}
@@ -0,0 +1,54 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/compiler.go
+//
+
+package executor
+
+import (
+	"github.com/opentracing/opentracing-go"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	goctx "golang.org/x/net/context"
+
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+)
+
+// Compiler compiles an ast.StmtNode to a physical plan.
+type Compiler struct {
+	Ctx sessionctx.Context
+}
+
+// Compile compiles an ast.StmtNode to a physical plan.
+func (c *Compiler) Compile(goCtx goctx.Context, stmtNode ast.StmtNode) (*ExecStmt, error) {
+	if span := opentracing.SpanFromContext(goCtx); span != nil && span.Tracer() != nil {
+		span1 := span.Tracer().StartSpan("executor.Compile", opentracing.ChildOf(span.Context()))
+		defer span1.Finish()
+	}
+
+	infoSchema := c.Ctx.GetInfoSchema()
+	finalPlan, err := plannercore.Optimize(c.Ctx, stmtNode, infoSchema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	return &ExecStmt{
+		InfoSchema: infoSchema,
+		Plan:       finalPlan,
+		Text:       stmtNode.Text(),
+		StmtNode:   stmtNode,
+		Ctx:        c.Ctx,
+	}, nil
+}
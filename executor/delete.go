@@ -0,0 +1,69 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/delete.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	goctx "golang.org/x/net/context"
+
+	plannercore "fedb/planner/core"
+	"fedb/table"
+	"fedb/util/chunk"
+)
+
+// DeleteExec represents a delete executor.
+// See https://dev.mysql.com/doc/refman/5.7/en/delete.html
+type DeleteExec struct {
+	baseExecutor
+
+	tblID2table    map[int64]table.Table
+	tblColPosInfos []plannercore.TblColPosInfo
+}
+
+// Next implements the Executor Next interface. All the rows are deleted at once.
+func (e *DeleteExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	rows, err := fetchAllRows(goCtx, e.children[0])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	sc := e.ctx.GetSessionVars().StmtCtx
+	// A row joined many times is deleted only once.
+	deletedRowKeys := make(map[int64]map[int64]struct{}, len(e.tblColPosInfos))
+	for _, row := range rows {
+		for _, info := range e.tblColPosInfos {
+			// The row of an outer join may not match any row of the table.
+			if row[info.HandleOrdinal].IsNull() {
+				continue
+			}
+			handle := row[info.HandleOrdinal].GetInt64()
+			if deletedRowKeys[info.TblID] == nil {
+				deletedRowKeys[info.TblID] = make(map[int64]struct{})
+			}
+			if _, ok := deletedRowKeys[info.TblID][handle]; ok {
+				continue
+			}
+			deletedRowKeys[info.TblID][handle] = struct{}{}
+
+			if err = e.tblID2table[info.TblID].RemoveRecord(e.ctx, handle, row[info.Start:info.End]); err != nil {
+				return errors.Trace(err)
+			}
+			sc.AddAffectedRows(1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/errors.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+)
+
+// Error codes that are not mapping to mysql error codes.
+const (
+	codeUnknownPlan = iota
+	codePrepareMulti
+
+	codeSubqueryNo1Row     = mysql.ErrSubqueryNo1Row
+	codeWrongArguments     = mysql.ErrWrongArguments
+	codeUnknownStmtHandler = mysql.ErrUnknownStmtHandler
+	codePsManyParam        = mysql.ErrPsManyParam
+
+	codeNonexistingGrant        = mysql.ErrNonexistingGrant
+	codeOptionPreventsStatement = mysql.ErrOptionPreventsStatement
+)
+
+// Error instances.
+var (
+	ErrUnknownPlan  = terror.ClassExecutor.New(codeUnknownPlan, "Unknown plan")
+	ErrPrepareMulti = terror.ClassExecutor.New(codePrepareMulti, "Can not prepare multiple statements")
+
+	ErrSubqueryNo1Row  = terror.ClassExecutor.New(codeSubqueryNo1Row, mysql.MySQLErrName[mysql.ErrSubqueryNo1Row])
+	ErrWrongParamCount = terror.ClassExecutor.New(codeWrongArguments, mysql.MySQLErrName[mysql.ErrWrongArguments])
+	ErrStmtNotFound    = terror.ClassExecutor.New(codeUnknownStmtHandler, "Unknown prepared statement handler (%d) given to %s")
+	ErrPsManyParam     = terror.ClassExecutor.New(codePsManyParam, mysql.MySQLErrName[mysql.ErrPsManyParam])
+
+	ErrNonexistingGrant        = terror.ClassExecutor.New(codeNonexistingGrant, mysql.MySQLErrName[mysql.ErrNonexistingGrant])
+	ErrOptionPreventsStatement = terror.ClassExecutor.New(codeOptionPreventsStatement, mysql.MySQLErrName[mysql.ErrOptionPreventsStatement])
+)
+
+func init() {
+	mysqlErrCodeMap := map[terror.ErrCode]uint16{
+		codeSubqueryNo1Row:     mysql.ErrSubqueryNo1Row,
+		codeWrongArguments:     mysql.ErrWrongArguments,
+		codeUnknownStmtHandler: mysql.ErrUnknownStmtHandler,
+		codePsManyParam:        mysql.ErrPsManyParam,
+
+		codeNonexistingGrant:        mysql.ErrNonexistingGrant,
+		codeOptionPreventsStatement: mysql.ErrOptionPreventsStatement,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassExecutor] = mysqlErrCodeMap
+}
@@ -0,0 +1,548 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/executor.go
+//
+
+package executor
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/infoschema"
+	"fedb/kv"
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+	"fedb/util/admin"
+	"fedb/util/chunk"
+)
+
+var (
+	_ Executor = &TableDualExec{}
+	_ Executor = &SelectionExec{}
+	_ Executor = &ProjectionExec{}
+	_ Executor = &LimitExec{}
+	_ Executor = &UnionExec{}
+	_ Executor = &MaxOneRowExec{}
+	_ Executor = &ShowDDLJobsExec{}
+	_ Executor = &CancelDDLJobsExec{}
+)
+
+func init() {
+	// The uncorrelated subqueries are evaluated when the plan is built, but the planner package
+	// cannot import the executor package, so the function is assigned here.
+	plannercore.EvalSubquery = func(p plannercore.PhysicalPlan, is infoschema.InfoSchema, ctx sessionctx.Context) (rows [][]types.Datum, err error) {
+		b := newExecutorBuilder(ctx, is)
+		e := b.build(p)
+		if b.err != nil {
+			return nil, errors.Trace(b.err)
+		}
+		goCtx := goctx.Background()
+		if err = e.Open(goCtx); err != nil {
+			terror.Call(e.Close)
+			return nil, errors.Trace(err)
+		}
+		defer terror.Call(e.Close)
+		return fetchAllRows(goCtx, e)
+	}
+}
+
+// Executor executes a query.
+type Executor interface {
+	Open(goctx.Context) error
+	// Next fills req with the next rows, an empty req means there is no more to return.
+	Next(goCtx goctx.Context, req *chunk.RecordBatch) error
+	Close() error
+	Schema() *expression.Schema
+
+	retTypes() []*types.FieldType
+	newFirstChunk() *chunk.Chunk
+}
+
+type baseExecutor struct {
+	ctx           sessionctx.Context
+	schema        *expression.Schema
+	children      []Executor
+	retFieldTypes []*types.FieldType
+	maxChunkSize  int
+}
+
+func newBaseExecutor(ctx sessionctx.Context, schema *expression.Schema, children ...Executor) baseExecutor {
+	e := baseExecutor{
+		ctx:          ctx,
+		schema:       schema,
+		children:     children,
+		maxChunkSize: ctx.GetSessionVars().MaxChunkSize(),
+	}
+	if schema != nil {
+		e.retFieldTypes = make([]*types.FieldType, 0, schema.Len())
+		for _, col := range schema.Columns {
+			e.retFieldTypes = append(e.retFieldTypes, col.RetType)
+		}
+	}
+	return e
+}
+
+// Open initializes children recursively.
+func (e *baseExecutor) Open(goCtx goctx.Context) error {
+	for _, child := range e.children {
+		err := child.Open(goCtx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// Close closes all executors and release all resources.
+func (e *baseExecutor) Close() error {
+	var firstErr error
+	for _, child := range e.children {
+		if err := child.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return errors.Trace(firstErr)
+}
+
+// Schema returns the current baseExecutor's schema. If it is nil, then create and return a new one.
+func (e *baseExecutor) Schema() *expression.Schema {
+	if e.schema == nil {
+		return expression.NewSchema()
+	}
+	return e.schema
+}
+
+// retTypes returns all output column types.
+func (e *baseExecutor) retTypes() []*types.FieldType {
+	return e.retFieldTypes
+}
+
+// newFirstChunk creates a new chunk to buffer current executor's result.
+func (e *baseExecutor) newFirstChunk() *chunk.Chunk {
+	return chunk.New(e.retTypes(), e.maxChunkSize, e.maxChunkSize)
+}
+
+// fillRecordBatch appends the rows returned by nextRow to req until req is full or the rows
+// run out, it is used by the executors which produce a row at a time.
+func fillRecordBatch(goCtx goctx.Context, req *chunk.RecordBatch, nextRow func(goctx.Context) ([]types.Datum, error)) error {
+	req.Reset()
+	for !req.IsFull() {
+		row, err := nextRow(goCtx)
+		if row == nil || err != nil {
+			return errors.Trace(err)
+		}
+		req.AppendDatumRow(row)
+	}
+	return nil
+}
+
+// fetchAllRows reads all the rows of the opened executor.
+func fetchAllRows(goCtx goctx.Context, e Executor) ([][]types.Datum, error) {
+	var rows [][]types.Datum
+	for {
+		// The rows refer to the memory of the chunk, so a new one is used every time.
+		chk := e.newFirstChunk()
+		if err := e.Next(goCtx, chunk.NewRecordBatch(chk)); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if chk.NumRows() == 0 {
+			return rows, nil
+		}
+		for i := 0; i < chk.NumRows(); i++ {
+			rows = append(rows, chk.GetRow(i).GetDatumRow(e.retTypes()))
+		}
+	}
+}
+
+// TableDualExec represents a dual table executor.
+type TableDualExec struct {
+	baseExecutor
+
+	// numDualRows can only be 0 or 1.
+	numDualRows int
+	numReturned int
+}
+
+// Open implements the Executor Open interface.
+func (e *TableDualExec) Open(goCtx goctx.Context) error {
+	e.numReturned = 0
+	return nil
+}
+
+// Next implements the Executor Next interface.
+func (e *TableDualExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if e.numReturned >= e.numDualRows {
+		return nil
+	}
+	if e.Schema().Len() == 0 {
+		req.SetNumVirtualRows(1)
+	} else {
+		for i := range e.Schema().Columns {
+			req.AppendNull(i)
+		}
+	}
+	e.numReturned = e.numDualRows
+	return nil
+}
+
+// SelectionExec represents a filter executor.
+type SelectionExec struct {
+	baseExecutor
+
+	filters []expression.Expression
+
+	childResult *chunk.Chunk
+	selected    []bool
+	// inputRow is the position of the next row of childResult to be checked.
+	inputRow int
+}
+
+// Open implements the Executor Open interface.
+func (e *SelectionExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.childResult = e.children[0].newFirstChunk()
+	e.selected = nil
+	e.inputRow = 0
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *SelectionExec) Close() error {
+	e.childResult = nil
+	e.selected = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+// The filters are evaluated on a whole chunk of the child at a time.
+func (e *SelectionExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for {
+		for ; e.inputRow < e.childResult.NumRows(); e.inputRow++ {
+			if !e.selected[e.inputRow] {
+				continue
+			}
+			if req.IsFull() {
+				return nil
+			}
+			req.AppendRow(e.childResult.GetRow(e.inputRow))
+		}
+		err := e.children[0].Next(goCtx, chunk.NewRecordBatch(e.childResult))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if e.childResult.NumRows() == 0 {
+			return nil
+		}
+		e.selected, err = expression.VectorizedFilter(e.ctx, e.filters, e.childResult, e.selected)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.inputRow = 0
+	}
+}
+
+// ProjectionExec implements the select field list.
+type ProjectionExec struct {
+	baseExecutor
+
+	exprs []expression.Expression
+
+	childResult *chunk.Chunk
+}
+
+// Open implements the Executor Open interface.
+func (e *ProjectionExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.childResult = e.children[0].newFirstChunk()
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *ProjectionExec) Close() error {
+	e.childResult = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+// The expressions are evaluated on a whole chunk of the child at a time.
+func (e *ProjectionExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if err := e.children[0].Next(goCtx, chunk.NewRecordBatch(e.childResult)); err != nil {
+		return errors.Trace(err)
+	}
+	if e.childResult.NumRows() == 0 {
+		return nil
+	}
+	// A projection without columns still returns the rows, like the one under COUNT(*).
+	req.SetNumVirtualRows(e.childResult.NumRows())
+	return errors.Trace(expression.VectorizedExecute(e.exprs, e.childResult, req.Chunk))
+}
+
+// LimitExec represents limit executor
+// It ignores 'Offset' rows from src, then returns 'Count' rows at maximum.
+type LimitExec struct {
+	baseExecutor
+
+	begin  uint64
+	end    uint64
+	cursor uint64
+
+	// meetFirstBatch represents whether the rows before the offset have been skipped.
+	meetFirstBatch bool
+	childResult    *chunk.Chunk
+}
+
+// Open implements the Executor Open interface.
+func (e *LimitExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.cursor = 0
+	e.meetFirstBatch = e.begin == 0
+	e.childResult = e.children[0].newFirstChunk()
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *LimitExec) Close() error {
+	e.childResult = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *LimitExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if e.cursor >= e.end {
+		return nil
+	}
+	for !e.meetFirstBatch {
+		err := e.children[0].Next(goCtx, chunk.NewRecordBatch(e.childResult))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		batchSize := uint64(e.childResult.NumRows())
+		if batchSize == 0 {
+			return nil
+		}
+		if newCursor := e.cursor + batchSize; newCursor >= e.begin {
+			e.meetFirstBatch = true
+			begin, end := e.begin-e.cursor, batchSize
+			if newCursor > e.end {
+				end = e.end - e.cursor
+			}
+			e.cursor += end
+			if begin == end {
+				break
+			}
+			req.Append(e.childResult, int(begin), int(end))
+			return nil
+		}
+		e.cursor += batchSize
+	}
+	if err := e.children[0].Next(goCtx, req); err != nil {
+		return errors.Trace(err)
+	}
+	batchSize := uint64(req.NumRows())
+	if e.cursor+batchSize > e.end {
+		req.TruncateTo(int(e.end - e.cursor))
+		batchSize = e.end - e.cursor
+	}
+	e.cursor += batchSize
+	return nil
+}
+
+// UnionExec pulls all it's children's result and returns to its parent directly.
+// The children are read one after another.
+type UnionExec struct {
+	baseExecutor
+
+	cursor int
+}
+
+// Open implements the Executor Open interface.
+func (e *UnionExec) Open(goCtx goctx.Context) error {
+	e.cursor = 0
+	return errors.Trace(e.baseExecutor.Open(goCtx))
+}
+
+// Next implements the Executor Next interface.
+func (e *UnionExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for e.cursor < len(e.children) {
+		if err := e.children[e.cursor].Next(goCtx, req); err != nil {
+			return errors.Trace(err)
+		}
+		if req.NumRows() > 0 {
+			return nil
+		}
+		e.cursor++
+	}
+	return nil
+}
+
+// MaxOneRowExec checks if the child returns at most one row, a row of NULLs is returned if it
+// returns no row.
+type MaxOneRowExec struct {
+	baseExecutor
+
+	evaluated bool
+}
+
+// Open implements the Executor Open interface.
+func (e *MaxOneRowExec) Open(goCtx goctx.Context) error {
+	e.evaluated = false
+	return errors.Trace(e.baseExecutor.Open(goCtx))
+}
+
+// Next implements the Executor Next interface.
+func (e *MaxOneRowExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if e.evaluated {
+		return nil
+	}
+	e.evaluated = true
+	if err := e.children[0].Next(goCtx, req); err != nil {
+		return errors.Trace(err)
+	}
+	if num := req.NumRows(); num == 0 {
+		for i := range e.Schema().Columns {
+			req.AppendNull(i)
+		}
+		return nil
+	} else if num > 1 {
+		return ErrSubqueryNo1Row
+	}
+	childChk := e.children[0].newFirstChunk()
+	if err := e.children[0].Next(goCtx, chunk.NewRecordBatch(childChk)); err != nil {
+		return errors.Trace(err)
+	}
+	if childChk.NumRows() != 0 {
+		return ErrSubqueryNo1Row
+	}
+	return nil
+}
+
+// ShowDDLJobsExec represents a show DDL jobs executor, it shows the jobs in the queue and
+// the last finished ones.
+type ShowDDLJobsExec struct {
+	baseExecutor
+
+	cursor int
+	jobs   []*model.Job
+	is     infoschema.InfoSchema
+}
+
+// Open implements the Executor Open interface. The jobs are read by a new transaction, as the
+// queue is changed by the DDL worker out of the transaction of the session.
+func (e *ShowDDLJobsExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.jobs, e.cursor = nil, 0
+	return errors.Trace(kv.RunInNewTxn(e.ctx.GetStore(), false, func(txn kv.Transaction) error {
+		jobs, err := admin.GetDDLJobs(txn)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		historyJobs, err := admin.GetHistoryDDLJobs(txn, admin.DefNumHistoryJobs)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		e.jobs = append(jobs, historyJobs...)
+		return nil
+	}))
+}
+
+// Next implements the Executor Next interface.
+func (e *ShowDDLJobsExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for ; e.cursor < len(e.jobs) && !req.IsFull(); e.cursor++ {
+		job := e.jobs[e.cursor]
+		req.AppendInt64(0, job.ID)
+		req.AppendString(1, getSchemaName(e.is, job.SchemaID))
+		req.AppendString(2, getTableName(e.is, job.TableID))
+		req.AppendString(3, job.Type.String())
+		req.AppendString(4, job.SchemaState.String())
+		req.AppendInt64(5, job.SchemaID)
+		req.AppendInt64(6, job.TableID)
+		req.AppendInt64(7, job.RowCount)
+		req.AppendString(8, model.TSConvert2Time(job.StartTS).String())
+		req.AppendString(9, job.State.String())
+	}
+	return nil
+}
+
+func getSchemaName(is infoschema.InfoSchema, id int64) string {
+	if dbInfo, ok := is.SchemaByID(id); ok {
+		return dbInfo.Name.O
+	}
+	return ""
+}
+
+func getTableName(is infoschema.InfoSchema, id int64) string {
+	if tblInfo, ok := is.TableByID(id); ok {
+		return tblInfo.Name.O
+	}
+	return ""
+}
+
+// CancelDDLJobsExec represents a cancel DDL jobs executor, it returns the result of every job.
+type CancelDDLJobsExec struct {
+	baseExecutor
+
+	cursor int
+	jobIDs []int64
+	errs   []error
+}
+
+// Open implements the Executor Open interface. The jobs are cancelled by a new transaction
+// which is retried if it conflicts with the DDL worker.
+func (e *CancelDDLJobsExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.cursor = 0
+	return errors.Trace(kv.RunInNewTxn(e.ctx.GetStore(), true, func(txn kv.Transaction) error {
+		var err error
+		e.errs, err = admin.CancelJobs(txn, e.jobIDs)
+		return errors.Trace(err)
+	}))
+}
+
+// Next implements the Executor Next interface.
+func (e *CancelDDLJobsExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for ; e.cursor < len(e.jobIDs) && !req.IsFull(); e.cursor++ {
+		req.AppendString(0, fmt.Sprintf("%d", e.jobIDs[e.cursor]))
+		if err := e.errs[e.cursor]; err != nil {
+			req.AppendString(1, fmt.Sprintf("error: %v", err))
+		} else {
+			req.AppendString(1, "successful")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/index_lookup_join.go
+//
+
+package executor
+
+import (
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/util/chunk"
+	"fedb/util/ranger"
+)
+
+var _ Executor = &IndexLookUpJoinExec{}
+
+// IndexLookUpJoinExec implements the index look up join algorithm.
+// The outer child is read a chunk at a time, the rows of the inner table are read by the join keys
+// of the chunk through the int handle or an index, then the outer rows are joined with them.
+type IndexLookUpJoinExec struct {
+	baseExecutor
+
+	outerExec Executor
+	// outerJoinKeys and innerJoinKeys are all the join keys, the inner rows are matched by them.
+	outerJoinKeys *joinKeys
+	innerJoinKeys *joinKeys
+	joiner        *joiner
+
+	innerSchema  *expression.Schema
+	innerTable   *model.TableInfo
+	innerIndex   *model.IndexInfo
+	innerCovered bool
+	innerColumns []*model.ColumnInfo
+	innerFilters expression.CNFExprs
+	// lookUpKeys are the keys of the outer rows whose values are looked up, in the order of the
+	// index columns, and lookUpTypes are the types of the inner columns.
+	lookUpKeys  []*expression.Column
+	lookUpTypes []*types.FieldType
+
+	outerChk    *chunk.Chunk
+	outerCursor int
+	hashTable   *joinHashTable
+	keyBuf      []byte
+	inners      []chunk.Row
+	selected    []bool
+}
+
+// Open implements the Executor Open interface.
+func (e *IndexLookUpJoinExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.outerChk = e.outerExec.newFirstChunk()
+	e.outerCursor = 0
+	e.hashTable = nil
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *IndexLookUpJoinExec) Close() error {
+	e.outerChk = nil
+	e.hashTable = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *IndexLookUpJoinExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for !req.IsFull() {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		if e.outerCursor >= e.outerChk.NumRows() {
+			if err := e.outerExec.Next(goCtx, chunk.NewRecordBatch(e.outerChk)); err != nil {
+				return errors.Trace(err)
+			}
+			if e.outerChk.NumRows() == 0 {
+				return nil
+			}
+			e.outerCursor = 0
+			if err := e.fetchInnerRows(goCtx); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		outer := e.outerChk.GetRow(e.outerCursor)
+		e.outerCursor++
+		var err error
+		e.inners, e.keyBuf, err = e.hashTable.get(e.outerJoinKeys, outer, e.keyBuf, e.inners[:0])
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = e.joiner.join(goCtx, outer, e.inners, req.Chunk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// fetchInnerRows reads the inner rows of the keys of the current outer chunk into the hash table.
+func (e *IndexLookUpJoinExec) fetchInnerRows(goCtx goctx.Context) (err error) {
+	e.hashTable = newJoinHashTable(e.ctx.GetSessionVars().StmtCtx, e.innerJoinKeys)
+	ranges, err := e.buildLookUpRanges()
+	if err != nil || len(ranges) == 0 {
+		return errors.Trace(err)
+	}
+	innerExec, err := e.newInnerReader(ranges)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = innerExec.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	defer func() {
+		if closeErr := innerExec.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	for {
+		chk := innerExec.newFirstChunk()
+		if err = innerExec.Next(goCtx, chunk.NewRecordBatch(chk)); err != nil {
+			return errors.Trace(err)
+		}
+		if chk.NumRows() == 0 {
+			return nil
+		}
+		if len(e.innerFilters) > 0 {
+			chk, err = e.filterInnerRows(innerExec, chk)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if err = e.hashTable.put(chk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// buildLookUpRanges builds the point ranges of the distinct look up keys of the outer chunk, the
+// outer rows whose keys can't be converted to the inner columns without loss never match.
+func (e *IndexLookUpJoinExec) buildLookUpRanges() ([]*ranger.Range, error) {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	type lookUpKey struct {
+		encoded string
+		vals    []types.Datum
+	}
+	var keys []lookUpKey
+	dedup := make(map[string]struct{}, e.outerChk.NumRows())
+	var buf []byte
+	for i := 0; i < e.outerChk.NumRows(); i++ {
+		row := e.outerChk.GetRow(i)
+		vals := make([]types.Datum, 0, len(e.lookUpKeys))
+		for j, col := range e.lookUpKeys {
+			d := row.GetDatum(col.Index, col.RetType)
+			if d.IsNull() {
+				break
+			}
+			v, ok := ranger.ConvertLossless(sc, d, e.lookUpTypes[j])
+			if !ok {
+				break
+			}
+			vals = append(vals, v)
+		}
+		if len(vals) < len(e.lookUpKeys) {
+			continue
+		}
+		var err error
+		buf, err = codec.EncodeKey(sc, buf[:0], vals...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if _, ok := dedup[string(buf)]; ok {
+			continue
+		}
+		dedup[string(buf)] = struct{}{}
+		keys = append(keys, lookUpKey{encoded: string(buf), vals: vals})
+	}
+	// The keys are read in the order of their encoded values.
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].encoded < keys[j].encoded
+	})
+	ranges := make([]*ranger.Range, 0, len(keys))
+	for _, key := range keys {
+		ranges = append(ranges, &ranger.Range{LowVal: key.vals, HighVal: key.vals})
+	}
+	return ranges, nil
+}
+
+func (e *IndexLookUpJoinExec) newInnerReader(ranges []*ranger.Range) (Executor, error) {
+	b := newBaseExecutor(e.ctx, e.innerSchema)
+	switch {
+	case e.innerIndex == nil:
+		return newTableReaderExecutor(b, e.innerTable, e.innerColumns, ranges)
+	case e.innerCovered:
+		return newIndexReaderExecutor(b, e.innerTable, e.innerIndex, e.innerColumns, ranges)
+	}
+	return newIndexLookUpExecutor(b, e.innerTable, e.innerIndex, e.innerColumns, ranges)
+}
+
+// filterInnerRows returns the rows of chk that satisfy the filters of the inner table.
+func (e *IndexLookUpJoinExec) filterInnerRows(innerExec Executor, chk *chunk.Chunk) (*chunk.Chunk, error) {
+	var err error
+	e.selected, err = expression.VectorizedFilter(e.ctx, e.innerFilters, chk, e.selected)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	result := chunk.NewChunkWithCapacity(innerExec.retTypes(), chk.NumRows())
+	for i, selected := range e.selected {
+		if selected {
+			result.AppendRow(chk.GetRow(i))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,236 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executor
+
+import (
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	goctx "golang.org/x/net/context"
+
+	"fedb/table"
+	"fedb/table/tables"
+	"fedb/tablecodec"
+	"fedb/util/chunk"
+	"fedb/util/ranger"
+)
+
+var (
+	_ Executor = &IndexReaderExecutor{}
+	_ Executor = &IndexLookUpExecutor{}
+)
+
+// indexScanner reads the entries of an index in the ranges of the index values.
+type indexScanner struct {
+	table  table.Table
+	index  *model.IndexInfo
+	ranges []*ranger.Range
+
+	scanner *kvRangeScanner
+}
+
+func newIndexScanner(tblInfo *model.TableInfo, idx *model.IndexInfo, ranges []*ranger.Range) (*indexScanner, error) {
+	tbl, err := tables.TableFromMeta(nil, tblInfo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &indexScanner{table: tbl, index: idx, ranges: ranges}, nil
+}
+
+func (s *indexScanner) open(b *baseExecutor) error {
+	txn, err := b.ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	krs, err := indexRangesToKVRanges(b.ctx.GetSessionVars().StmtCtx, s.table.Meta().ID, s.index.ID, s.ranges)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.scanner = &kvRangeScanner{txn: txn, ranges: krs}
+	return nil
+}
+
+// next returns the index values and the handle of the next entry, the values are nil
+// when all the entries are read.
+func (s *indexScanner) next(goCtx goctx.Context) ([]types.Datum, int64, error) {
+	ok, err := s.scanner.next(goCtx)
+	if !ok || err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	key, value := s.scanner.it.Key(), s.scanner.it.Value()
+	encodedVals, _, err := tablecodec.CutIndexKeyNew(key, len(s.index.Columns))
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	vals := make([]types.Datum, 0, len(encodedVals))
+	fts := make([]*types.FieldType, 0, len(encodedVals))
+	for i, encodedVal := range encodedVals {
+		_, d, err := codec.DecodeOne(encodedVal)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+		vals = append(vals, d)
+		fts = append(fts, &s.table.Meta().Columns[s.index.Columns[i].Offset].FieldType)
+	}
+	vals, err = tablecodec.UnflattenDatums(vals, fts, time.Local)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	handle, err := tablecodec.DecodeIndexHandle(key, value, len(s.index.Columns))
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	if err = s.scanner.advance(); err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	return vals, handle, nil
+}
+
+func (s *indexScanner) close() {
+	if s.scanner != nil {
+		s.scanner.close()
+		s.scanner = nil
+	}
+}
+
+// IndexReaderExecutor reads the columns from the entries of an index, all the columns must be
+// covered by the index columns and the handle.
+type IndexReaderExecutor struct {
+	baseExecutor
+	*indexScanner
+
+	columns []*model.ColumnInfo
+	// offsets are the positions of the columns in the index columns, -1 means the handle.
+	offsets []int
+}
+
+func newIndexReaderExecutor(b baseExecutor, tblInfo *model.TableInfo, idx *model.IndexInfo,
+	columns []*model.ColumnInfo, ranges []*ranger.Range) (*IndexReaderExecutor, error) {
+	s, err := newIndexScanner(tblInfo, idx, ranges)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	e := &IndexReaderExecutor{
+		baseExecutor: b,
+		indexScanner: s,
+		columns:      columns,
+		offsets:      make([]int, 0, len(columns)),
+	}
+	for _, col := range columns {
+		offset := -1
+		for i, idxCol := range idx.Columns {
+			if idxCol.Name.L == col.Name.L {
+				offset = i
+				break
+			}
+		}
+		isHandle := (tblInfo.PKIsHandle && mysql.HasPriKeyFlag(col.Flag)) || col.ID == model.ExtraHandleID
+		if offset == -1 && !isHandle {
+			return nil, errors.Errorf("column %s is not covered by index %s", col.Name, idx.Name)
+		}
+		e.offsets = append(e.offsets, offset)
+	}
+	return e, nil
+}
+
+// Open implements the Executor Open interface.
+func (e *IndexReaderExecutor) Open(goCtx goctx.Context) error {
+	return errors.Trace(e.indexScanner.open(&e.baseExecutor))
+}
+
+// Next implements the Executor Next interface.
+func (e *IndexReaderExecutor) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(fillRecordBatch(goCtx, req, e.nextRow))
+}
+
+// nextRow returns the next row, nil row means there is no more to return.
+func (e *IndexReaderExecutor) nextRow(goCtx goctx.Context) ([]types.Datum, error) {
+	vals, handle, err := e.indexScanner.next(goCtx)
+	if vals == nil || err != nil {
+		return nil, errors.Trace(err)
+	}
+	row := make([]types.Datum, len(e.columns))
+	for i, offset := range e.offsets {
+		switch {
+		case offset != -1:
+			row[i] = vals[offset]
+		case mysql.HasUnsignedFlag(e.columns[i].Flag):
+			row[i].SetUint64(uint64(handle))
+		default:
+			row[i].SetInt64(handle)
+		}
+	}
+	return row, nil
+}
+
+// Close implements the Executor Close interface.
+func (e *IndexReaderExecutor) Close() error {
+	e.indexScanner.close()
+	return nil
+}
+
+// IndexLookUpExecutor reads the handles from an index, then reads the rows of the handles from the table.
+type IndexLookUpExecutor struct {
+	baseExecutor
+	*indexScanner
+
+	columns []*table.Column
+}
+
+func newIndexLookUpExecutor(b baseExecutor, tblInfo *model.TableInfo, idx *model.IndexInfo,
+	columns []*model.ColumnInfo, ranges []*ranger.Range) (*IndexLookUpExecutor, error) {
+	s, err := newIndexScanner(tblInfo, idx, ranges)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	e := &IndexLookUpExecutor{
+		baseExecutor: b,
+		indexScanner: s,
+		columns:      make([]*table.Column, 0, len(columns)),
+	}
+	for _, col := range columns {
+		e.columns = append(e.columns, table.ToColumn(col))
+	}
+	return e, nil
+}
+
+// Open implements the Executor Open interface.
+func (e *IndexLookUpExecutor) Open(goCtx goctx.Context) error {
+	return errors.Trace(e.indexScanner.open(&e.baseExecutor))
+}
+
+// Next implements the Executor Next interface.
+func (e *IndexLookUpExecutor) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(fillRecordBatch(goCtx, req, e.nextRow))
+}
+
+// nextRow returns the next row, nil row means there is no more to return.
+func (e *IndexLookUpExecutor) nextRow(goCtx goctx.Context) ([]types.Datum, error) {
+	vals, handle, err := e.indexScanner.next(goCtx)
+	if vals == nil || err != nil {
+		return nil, errors.Trace(err)
+	}
+	row, err := e.table.RowWithCols(e.ctx, handle, e.columns)
+	return row, errors.Trace(err)
+}
+
+// Close implements the Executor Close interface.
+func (e *IndexLookUpExecutor) Close() error {
+	e.indexScanner.close()
+	return nil
+}
@@ -0,0 +1,583 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/infoschema/tables.go
+//
+
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/infoschema"
+	"fedb/meta"
+	"fedb/sessionctx"
+	"fedb/sessionctx/variable"
+	"fedb/table"
+	"fedb/util"
+	"fedb/util/chunk"
+)
+
+var _ Executor = &MemTableReaderExec{}
+
+// MemTableReaderExec reads a table of the information schema. The rows are generated when it's
+// opened, from the InfoSchema the statement is compiled with and the sessions of the server.
+type MemTableReaderExec struct {
+	baseExecutor
+
+	is      infoschema.InfoSchema
+	table   *model.TableInfo
+	columns []*model.ColumnInfo
+
+	rows   [][]types.Datum
+	cursor int
+}
+
+// Open implements the Executor Open interface.
+func (e *MemTableReaderExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	fullRows, err := e.getRows()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// The rows have all the columns of the table, only the columns in the schema are kept.
+	e.rows, e.cursor = make([][]types.Datum, 0, len(fullRows)), 0
+	for _, fullRow := range fullRows {
+		row := make([]types.Datum, len(e.columns))
+		for i, col := range e.columns {
+			row[i] = fullRow[col.Offset]
+		}
+		e.rows = append(e.rows, row)
+	}
+	return nil
+}
+
+// Next implements the Executor Next interface.
+func (e *MemTableReaderExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(fillRecordBatch(goCtx, req, e.nextRow))
+}
+
+func (e *MemTableReaderExec) nextRow(_ goctx.Context) ([]types.Datum, error) {
+	if e.cursor >= len(e.rows) {
+		return nil, nil
+	}
+	row := e.rows[e.cursor]
+	e.cursor++
+	return row, nil
+}
+
+// Close implements the Executor Close interface.
+func (e *MemTableReaderExec) Close() error {
+	e.rows = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+func (e *MemTableReaderExec) getRows() ([][]types.Datum, error) {
+	dbs := visibleSchemas(e.ctx, e.is.AllSchemas())
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].Name.L < dbs[j].Name.L })
+	switch e.table.Name.O {
+	case infoschema.TableSchemata:
+		return dataForSchemata(dbs), nil
+	case infoschema.TableTables:
+		return dataForTables(e.ctx, e.is, dbs)
+	case infoschema.TableColumns:
+		return dataForColumns(dbs), nil
+	case infoschema.TableStatistics:
+		return dataForStatistics(dbs), nil
+	case infoschema.TableKeyColumnUsage:
+		return dataForKeyColumnUsage(dbs), nil
+	case infoschema.TableProcesslist:
+		return dataForProcesslist(e.ctx), nil
+	case infoschema.TableSessionVariables:
+		return dataForSessionVar(e.ctx), nil
+	}
+	return nil, errors.Errorf("unknown memory table %s", e.table.Name)
+}
+
+// visibleSchemas returns the databases with the tables the user has any privilege on, the
+// databases the user can't see are left out. The system views are always visible.
+func visibleSchemas(ctx sessionctx.Context, dbs []*model.DBInfo) []*model.DBInfo {
+	pm := ctx.GetPrivilegeManager()
+	if pm == nil {
+		return dbs
+	}
+	visible := make([]*model.DBInfo, 0, len(dbs))
+	for _, db := range dbs {
+		if infoschema.IsMemoryDB(db.Name.L) {
+			visible = append(visible, db)
+			continue
+		}
+		if !pm.DBIsVisible(db.Name.L) {
+			continue
+		}
+		// The DBInfo is shared by the sessions, so the visible tables are kept in a copy.
+		dbCopy := *db
+		dbCopy.Tables = make([]*model.TableInfo, 0, len(db.Tables))
+		for _, tbl := range db.Tables {
+			if pm.RequestVerification(db.Name.L, tbl.Name.L, "", mysql.AllPrivMask) {
+				dbCopy.Tables = append(dbCopy.Tables, tbl)
+			}
+		}
+		visible = append(visible, &dbCopy)
+	}
+	return visible
+}
+
+// publicTables returns the public tables of the database sorted by name, the tables of the
+// DBInfo are shared by the sessions, so they are copied before sorting.
+func publicTables(schema *model.DBInfo) []*model.TableInfo {
+	tables := make([]*model.TableInfo, 0, len(schema.Tables))
+	for _, tbl := range schema.Tables {
+		if tbl.State == model.StatePublic {
+			tables = append(tables, tbl)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name.L < tables[j].Name.L })
+	return tables
+}
+
+func dataForSchemata(schemas []*model.DBInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	for _, schema := range schemas {
+		charset := mysql.DefaultCharset
+		collation := mysql.DefaultCollationName
+		if len(schema.Charset) > 0 {
+			charset = schema.Charset
+		}
+		if len(schema.Collate) > 0 {
+			collation = schema.Collate
+		}
+		record := types.MakeDatums(
+			infoschema.CatalogVal, // CATALOG_NAME
+			schema.Name.O,         // SCHEMA_NAME
+			charset,               // DEFAULT_CHARACTER_SET_NAME
+			collation,             // DEFAULT_COLLATION_NAME
+			nil,                   // SQL_PATH
+		)
+		rows = append(rows, record)
+	}
+	return rows
+}
+
+// getAutoIncrementID returns the next auto_increment ID of the table, or nil if the table has
+// no auto_increment column. The allocator of the table hasn't allocated any ID if its base is 0,
+// the next ID is taken after the IDs persisted in the storage then.
+func getAutoIncrementID(ctx sessionctx.Context, is infoschema.InfoSchema, schema *model.DBInfo, tblInfo *model.TableInfo) (interface{}, error) {
+	hasAutoIncID := false
+	for _, col := range tblInfo.Columns {
+		if mysql.HasAutoIncrementFlag(col.Flag) {
+			hasAutoIncID = true
+			break
+		}
+	}
+	if !hasAutoIncID {
+		return nil, nil
+	}
+	if alloc, ok := is.AllocByID(tblInfo.ID); ok {
+		if base := alloc.Base(); base > 0 {
+			return base + 1, nil
+		}
+	}
+	txn, err := ctx.Txn()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	end, err := meta.NewMeta(txn).GetAutoTableID(schema.ID, tblInfo.ID)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return end + 1, nil
+}
+
+// dataForTables returns the rows of the tables, no statistics are kept, so the sizes are 0.
+func dataForTables(ctx sessionctx.Context, is infoschema.InfoSchema, schemas []*model.DBInfo) ([][]types.Datum, error) {
+	var rows [][]types.Datum
+	for _, schema := range schemas {
+		isMemoryDB := infoschema.IsMemoryDB(schema.Name.L)
+		for _, tbl := range publicTables(schema) {
+			tableType, engine := "BASE TABLE", "InnoDB"
+			var autoIncID, createTime interface{}
+			if isMemoryDB {
+				tableType, engine = "SYSTEM VIEW", "MEMORY"
+			} else {
+				var err error
+				if autoIncID, err = getAutoIncrementID(ctx, is, schema, tbl); err != nil {
+					return nil, errors.Trace(err)
+				}
+				createTime = types.Time{
+					Time: types.FromGoTime(model.TSConvert2Time(tbl.UpdateTS)),
+					Type: mysql.TypeDatetime,
+				}
+			}
+			collation := tbl.Collate
+			if collation == "" {
+				collation = mysql.DefaultCollationName
+			}
+			record := types.MakeDatums(
+				infoschema.CatalogVal, // TABLE_CATALOG
+				schema.Name.O,         // TABLE_SCHEMA
+				tbl.Name.O,            // TABLE_NAME
+				tableType,             // TABLE_TYPE
+				engine,                // ENGINE
+				uint64(10),            // VERSION
+				"Compact",             // ROW_FORMAT
+				uint64(0),             // TABLE_ROWS
+				uint64(0),             // AVG_ROW_LENGTH
+				uint64(0),             // DATA_LENGTH
+				uint64(0),             // MAX_DATA_LENGTH
+				uint64(0),             // INDEX_LENGTH
+				uint64(0),             // DATA_FREE
+				autoIncID,             // AUTO_INCREMENT
+				createTime,            // CREATE_TIME
+				nil,                   // UPDATE_TIME
+				nil,                   // CHECK_TIME
+				collation,             // TABLE_COLLATION
+				nil,                   // CHECKSUM
+				"",                    // CREATE_OPTIONS
+				tbl.Comment,           // TABLE_COMMENT
+			)
+			rows = append(rows, record)
+		}
+	}
+	return rows, nil
+}
+
+func dataForColumns(schemas []*model.DBInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	for _, schema := range schemas {
+		for _, tbl := range publicTables(schema) {
+			rows = append(rows, dataForColumnsInTable(schema, tbl)...)
+		}
+	}
+	return rows
+}
+
+func dataForColumnsInTable(schema *model.DBInfo, tbl *model.TableInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	pos := 0
+	for _, col := range tbl.Columns {
+		if col.State != model.StatePublic {
+			continue
+		}
+		pos++
+		var charMaxLen, charOctLen, numericPrecision, numericScale, datetimePrecision interface{}
+		colLen, decimal := col.Flen, col.Decimal
+		defaultFlen, defaultDecimal := mysql.GetDefaultFieldLengthAndDecimal(col.Tp)
+		if decimal == types.UnspecifiedLength {
+			decimal = defaultDecimal
+		}
+		if colLen == types.UnspecifiedLength {
+			colLen = defaultFlen
+		}
+		if col.Tp == mysql.TypeSet {
+			// Example: In MySQL set('a','bc','def','ghij') has length 13, because
+			// len('a')+len('bc')+len('def')+len('ghij')+len(ThreeComma)=13
+			colLen = 0
+			for _, ele := range col.Elems {
+				colLen += len(ele)
+			}
+			if len(col.Elems) != 0 {
+				colLen += len(col.Elems) - 1
+			}
+			charMaxLen = colLen
+			charOctLen = colLen
+		} else if col.Tp == mysql.TypeEnum {
+			// Example: In MySQL enum('a', 'ab', 'cdef') has length 4, because
+			// the longest string in the enum is 'cdef'
+			colLen = 0
+			for _, ele := range col.Elems {
+				if len(ele) > colLen {
+					colLen = len(ele)
+				}
+			}
+			charMaxLen = colLen
+			charOctLen = colLen
+		} else if types.IsString(col.Tp) {
+			charMaxLen = colLen
+			charOctLen = colLen
+		} else if types.IsTypeFractionable(col.Tp) {
+			datetimePrecision = decimal
+		} else if types.IsTypeNumeric(col.Tp) {
+			numericPrecision = colLen
+			if col.Tp != mysql.TypeFloat && col.Tp != mysql.TypeDouble {
+				numericScale = decimal
+			} else if decimal != -1 {
+				numericScale = decimal
+			}
+		}
+		columnDesc := table.NewColDesc(table.ToColumn(col))
+		var columnDefault interface{}
+		if columnDesc.DefaultValue != nil {
+			columnDefault = fmt.Sprintf("%v", columnDesc.DefaultValue)
+		}
+		record := types.MakeDatums(
+			infoschema.CatalogVal,                // TABLE_CATALOG
+			schema.Name.O,                        // TABLE_SCHEMA
+			tbl.Name.O,                           // TABLE_NAME
+			col.Name.O,                           // COLUMN_NAME
+			pos,                                  // ORDINAL_POSITION
+			columnDefault,                        // COLUMN_DEFAULT
+			columnDesc.Null,                      // IS_NULLABLE
+			types.TypeToStr(col.Tp, col.Charset), // DATA_TYPE
+			charMaxLen,                           // CHARACTER_MAXIMUM_LENGTH
+			charOctLen,                           // CHARACTER_OCTET_LENGTH
+			numericPrecision,                     // NUMERIC_PRECISION
+			numericScale,                         // NUMERIC_SCALE
+			datetimePrecision,                    // DATETIME_PRECISION
+			col.Charset,                          // CHARACTER_SET_NAME
+			col.Collate,                          // COLLATION_NAME
+			col.FieldType.InfoSchemaStr(),        // COLUMN_TYPE
+			columnDesc.Key,                       // COLUMN_KEY
+			columnDesc.Extra,                     // EXTRA
+			columnDesc.Privileges,                // PRIVILEGES
+			columnDesc.Comment,                   // COLUMN_COMMENT
+			"",                                   // GENERATION_EXPRESSION
+		)
+		// The character set and the collation are NULL for the columns which aren't strings,
+		// and for the binary strings.
+		if !types.IsString(col.Tp) || col.Charset == charset.CharsetBin {
+			record[13].SetNull()
+			record[14].SetNull()
+		}
+		rows = append(rows, record)
+	}
+	return rows
+}
+
+func dataForStatistics(schemas []*model.DBInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	for _, schema := range schemas {
+		for _, tbl := range publicTables(schema) {
+			rows = append(rows, dataForStatisticsInTable(schema, tbl)...)
+		}
+	}
+	return rows
+}
+
+func dataForStatisticsInTable(schema *model.DBInfo, tbl *model.TableInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	if tbl.PKIsHandle {
+		for _, col := range tbl.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				record := types.MakeDatums(
+					infoschema.CatalogVal, // TABLE_CATALOG
+					schema.Name.O,         // TABLE_SCHEMA
+					tbl.Name.O,            // TABLE_NAME
+					"0",                   // NON_UNIQUE
+					schema.Name.O,         // INDEX_SCHEMA
+					"PRIMARY",             // INDEX_NAME
+					1,                     // SEQ_IN_INDEX
+					col.Name.O,            // COLUMN_NAME
+					"A",                   // COLLATION
+					0,                     // CARDINALITY
+					nil,                   // SUB_PART
+					nil,                   // PACKED
+					"",                    // NULLABLE
+					"BTREE",               // INDEX_TYPE
+					"",                    // COMMENT
+					"",                    // INDEX_COMMENT
+				)
+				rows = append(rows, record)
+			}
+		}
+	}
+	nameToCol := make(map[string]*model.ColumnInfo, len(tbl.Columns))
+	for _, c := range tbl.Columns {
+		nameToCol[c.Name.L] = c
+	}
+	for _, index := range tbl.Indices {
+		if index.State != model.StatePublic {
+			continue
+		}
+		nonUnique := "1"
+		if index.Unique {
+			nonUnique = "0"
+		}
+		for i, key := range index.Columns {
+			col := nameToCol[key.Name.L]
+			nullable := "YES"
+			if mysql.HasNotNullFlag(col.Flag) {
+				nullable = ""
+			}
+			var subPart interface{}
+			if key.Length != types.UnspecifiedLength {
+				subPart = key.Length
+			}
+			record := types.MakeDatums(
+				infoschema.CatalogVal, // TABLE_CATALOG
+				schema.Name.O,         // TABLE_SCHEMA
+				tbl.Name.O,            // TABLE_NAME
+				nonUnique,             // NON_UNIQUE
+				schema.Name.O,         // INDEX_SCHEMA
+				index.Name.O,          // INDEX_NAME
+				i+1,                   // SEQ_IN_INDEX
+				key.Name.O,            // COLUMN_NAME
+				"A",                   // COLLATION
+				0,                     // CARDINALITY
+				subPart,               // SUB_PART
+				nil,                   // PACKED
+				nullable,              // NULLABLE
+				"BTREE",               // INDEX_TYPE
+				"",                    // COMMENT
+				index.Comment,         // INDEX_COMMENT
+			)
+			rows = append(rows, record)
+		}
+	}
+	return rows
+}
+
+const primaryConstraint = "PRIMARY"
+
+func dataForKeyColumnUsage(schemas []*model.DBInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	for _, schema := range schemas {
+		for _, tbl := range publicTables(schema) {
+			rows = append(rows, keyColumnUsageInTable(schema, tbl)...)
+		}
+	}
+	return rows
+}
+
+// keyColumnUsageInTable returns the columns of the primary key and the unique indices.
+func keyColumnUsageInTable(schema *model.DBInfo, tbl *model.TableInfo) [][]types.Datum {
+	var rows [][]types.Datum
+	if tbl.PKIsHandle {
+		for _, col := range tbl.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				record := types.MakeDatums(
+					infoschema.CatalogVal, // CONSTRAINT_CATALOG
+					schema.Name.O,         // CONSTRAINT_SCHEMA
+					primaryConstraint,     // CONSTRAINT_NAME
+					infoschema.CatalogVal, // TABLE_CATALOG
+					schema.Name.O,         // TABLE_SCHEMA
+					tbl.Name.O,            // TABLE_NAME
+					col.Name.O,            // COLUMN_NAME
+					1,                     // ORDINAL_POSITION
+					nil,                   // POSITION_IN_UNIQUE_CONSTRAINT
+					nil,                   // REFERENCED_TABLE_SCHEMA
+					nil,                   // REFERENCED_TABLE_NAME
+					nil,                   // REFERENCED_COLUMN_NAME
+				)
+				rows = append(rows, record)
+				break
+			}
+		}
+	}
+	for _, index := range tbl.Indices {
+		if index.State != model.StatePublic {
+			continue
+		}
+		var idxName string
+		if index.Primary {
+			idxName = primaryConstraint
+		} else if index.Unique {
+			idxName = index.Name.O
+		} else {
+			continue
+		}
+		for i, key := range index.Columns {
+			record := types.MakeDatums(
+				infoschema.CatalogVal, // CONSTRAINT_CATALOG
+				schema.Name.O,         // CONSTRAINT_SCHEMA
+				idxName,               // CONSTRAINT_NAME
+				infoschema.CatalogVal, // TABLE_CATALOG
+				schema.Name.O,         // TABLE_SCHEMA
+				tbl.Name.O,            // TABLE_NAME
+				key.Name.O,            // COLUMN_NAME
+				i+1,                   // ORDINAL_POSITION
+				nil,                   // POSITION_IN_UNIQUE_CONSTRAINT
+				nil,                   // REFERENCED_TABLE_SCHEMA
+				nil,                   // REFERENCED_TABLE_NAME
+				nil,                   // REFERENCED_COLUMN_NAME
+			)
+			rows = append(rows, record)
+		}
+	}
+	return rows
+}
+
+// getProcessList returns the connections of the server ordered by ID, there is none if the
+// session isn't connected to a server.
+func getProcessList(ctx sessionctx.Context) []util.ProcessInfo {
+	sm := ctx.GetSessionManager()
+	if sm == nil {
+		return nil
+	}
+	// The users without the PROCESS privilege see their own connections only.
+	pm := ctx.GetPrivilegeManager()
+	ownOnly := pm != nil && !pm.RequestVerification("", "", "", mysql.ProcessPriv)
+	pl := sm.ShowProcessList()
+	pis := make([]util.ProcessInfo, 0, len(pl))
+	for _, pi := range pl {
+		if !ownOnly || pi.User == ctx.GetSessionVars().User.Username {
+			pis = append(pis, pi)
+		}
+	}
+	sort.Slice(pis, func(i, j int) bool { return pis[i].ID < pis[j].ID })
+	return pis
+}
+
+func dataForProcesslist(ctx sessionctx.Context) [][]types.Datum {
+	pis := getProcessList(ctx)
+	rows := make([][]types.Datum, 0, len(pis))
+	for _, pi := range pis {
+		var db, info interface{}
+		if pi.DB != "" {
+			db = pi.DB
+		}
+		if pi.Info != "" {
+			info = pi.Info
+		}
+		record := types.MakeDatums(
+			pi.ID,      // ID
+			pi.User,    // USER
+			pi.Host,    // HOST
+			db,         // DB
+			pi.Command, // COMMAND
+			uint64(time.Since(pi.Time)/time.Second), // TIME
+			fmt.Sprintf("%d", pi.State),             // STATE
+			info,                                    // INFO
+		)
+		rows = append(rows, record)
+	}
+	return rows
+}
+
+// dataForSessionVar returns the values of the system variables in the session ordered by name.
+func dataForSessionVar(ctx sessionctx.Context) [][]types.Datum {
+	sessionVars := ctx.GetSessionVars()
+	names := make([]string, 0, len(variable.SysVars))
+	for name := range variable.SysVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rows := make([][]types.Datum, 0, len(names))
+	for _, name := range names {
+		value, _ := sessionVars.GetSystemVar(name)
+		rows = append(rows, types.MakeDatums(name, value))
+	}
+	return rows
+}
@@ -0,0 +1,119 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/insert.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/kv"
+	"fedb/table"
+	"fedb/util/chunk"
+)
+
+// InsertExec represents an insert executor.
+type InsertExec struct {
+	*InsertValues
+
+	OnDuplicate []*expression.Assignment
+}
+
+// Next implements the Executor Next interface. All the rows are inserted at once.
+func (e *InsertExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	rows, err := e.getRows(goCtx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, row := range rows {
+		if len(e.OnDuplicate) > 0 {
+			err = e.insertOrUpdate(row)
+		} else {
+			err = e.insertRow(row)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// insertRow adds the row, the duplicate entry error is a warning in INSERT IGNORE.
+func (e *InsertExec) insertRow(row []types.Datum) error {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	if _, err := e.Table.AddRecord(e.ctx, row); err != nil {
+		if sc.DupKeyAsWarning && kv.ErrKeyExists.Equal(err) {
+			sc.AppendWarning(err)
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	sc.AddAffectedRows(1)
+	return nil
+}
+
+// insertOrUpdate adds the row, or updates the row it conflicts with by ON DUPLICATE KEY UPDATE.
+func (e *InsertExec) insertOrUpdate(row []types.Datum) error {
+	h, err := findDuplicate(e.ctx, e.Table, row)
+	if err == nil {
+		return e.insertRow(row)
+	}
+	if !kv.ErrKeyExists.Equal(err) {
+		return errors.Trace(err)
+	}
+	oldRow, err := e.Table.Row(e.ctx, h)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return e.doDupRowUpdate(h, oldRow, row)
+}
+
+// doDupRowUpdate updates the duplicate row of handle h by the assignments, which are evaluated on
+// the duplicate row followed by the row being inserted. An updated row counts as two affected
+// rows, like MySQL does.
+func (e *InsertExec) doDupRowUpdate(h int64, oldRow []types.Datum, newRow []types.Datum) error {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	cols := e.Table.Cols()
+	fields := append(append(make([]*types.FieldType, 0, 2*len(cols)), e.fieldTypes...), e.fieldTypes...)
+	row4Update := append(append(make([]types.Datum, 0, 2*len(cols)), oldRow...), newRow...)
+	assignFlag := make([]bool, len(cols))
+	for _, assign := range e.OnDuplicate {
+		val, err := assign.Expr.Eval(datumsToRow(fields, row4Update))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		idx := assign.Col.Index
+		if row4Update[idx], err = table.CastValue(e.ctx, val, cols[idx].ToInfo()); err != nil {
+			return errors.Trace(err)
+		}
+		assignFlag[idx] = true
+	}
+	changed, err := updateRecord(e.ctx, h, oldRow, row4Update[:len(cols)], assignFlag, e.Table)
+	if err != nil {
+		if sc.DupKeyAsWarning && kv.ErrKeyExists.Equal(err) {
+			sc.AppendWarning(err)
+			return nil
+		}
+		return errors.Trace(err)
+	}
+	if changed {
+		sc.AddAffectedRows(1)
+	}
+	return nil
+}
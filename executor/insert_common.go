@@ -0,0 +1,194 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/insert_common.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/table"
+)
+
+// InsertValues is the data to insert, which is shared by InsertExec and ReplaceExec. The rows are
+// given by Lists, or read from the child executor of the SELECT.
+type InsertValues struct {
+	baseExecutor
+
+	Table table.Table
+	// Columns are the columns that the values are given to, in order.
+	Columns []*table.Column
+	Lists   [][]expression.Expression
+
+	// fieldTypes are the types of the columns of the table, the values are evaluated on the row
+	// being inserted.
+	fieldTypes []*types.FieldType
+	// given marks the columns that are given values.
+	given []bool
+}
+
+func newInsertValues(b baseExecutor, t table.Table, columns []*table.Column, lists [][]expression.Expression) *InsertValues {
+	e := &InsertValues{
+		baseExecutor: b,
+		Table:        t,
+		Columns:      columns,
+		Lists:        lists,
+		fieldTypes:   make([]*types.FieldType, 0, len(t.Cols())),
+		given:        make([]bool, len(t.Cols())),
+	}
+	for _, col := range t.Cols() {
+		e.fieldTypes = append(e.fieldTypes, &col.FieldType)
+	}
+	for _, col := range columns {
+		e.given[col.Offset] = true
+	}
+	return e
+}
+
+// getRows returns the rows to insert, the values are cast to the types of the columns.
+func (e *InsertValues) getRows(goCtx goctx.Context) ([][]types.Datum, error) {
+	if len(e.children) > 0 {
+		return e.getRowsSelect(goCtx)
+	}
+	rows := make([][]types.Datum, 0, len(e.Lists))
+	for _, list := range e.Lists {
+		row, err := e.evalRow(list)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// evalRow evaluates the values of a list in order. A value may refer to the columns of the row,
+// the columns given values later hold NULL, and the others hold their default values.
+func (e *InsertValues) evalRow(list []expression.Expression) ([]types.Datum, error) {
+	row, err := e.initRow()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i, expr := range list {
+		var val types.Datum
+		if con, ok := expr.(*expression.Constant); ok {
+			val = con.Value
+		} else if val, err = expr.Eval(datumsToRow(e.fieldTypes, row)); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if err = e.setValue(row, e.Columns[i], val); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return e.fillRow(row)
+}
+
+// getRowsSelect reads all the rows of the SELECT before any of them is inserted, so that the
+// rows inserted into the table being read are not read again.
+func (e *InsertValues) getRowsSelect(goCtx goctx.Context) ([][]types.Datum, error) {
+	selectRows, err := fetchAllRows(goCtx, e.children[0])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rows := make([][]types.Datum, 0, len(selectRows))
+	for _, vals := range selectRows {
+		row, err := e.initRow()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for i, val := range vals {
+			if err = e.setValue(row, e.Columns[i], val); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if row, err = e.fillRow(row); err != nil {
+			return nil, errors.Trace(err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// initRow returns a row whose columns not given values hold their default values.
+func (e *InsertValues) initRow() ([]types.Datum, error) {
+	cols := e.Table.Cols()
+	row := make([]types.Datum, len(cols))
+	for i, col := range cols {
+		if e.given[i] {
+			continue
+		}
+		var err error
+		if row[i], err = table.GetColDefaultValue(e.ctx, col.ToInfo()); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return row, nil
+}
+
+func (e *InsertValues) setValue(row []types.Datum, col *table.Column, val types.Datum) (err error) {
+	row[col.Offset], err = table.CastValue(e.ctx, val, col.ToInfo())
+	return errors.Trace(err)
+}
+
+// fillRow fills the auto_increment column and checks the NOT NULL columns of the row, NULL is
+// replaced with the zero value of the column if the bad NULL values are warnings.
+func (e *InsertValues) fillRow(row []types.Datum) ([]types.Datum, error) {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for i, col := range e.Table.Cols() {
+		var err error
+		if mysql.HasAutoIncrementFlag(col.Flag) {
+			if row[i], err = e.adjustAutoIncrementDatum(row[i], col); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		if row[i], err = col.HandleBadNull(row[i], sc); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return row, nil
+}
+
+// adjustAutoIncrementDatum allocates a new ID for the auto_increment column if the value is NULL
+// or 0, otherwise the allocator is rebased so that the IDs allocated later are greater than it.
+func (e *InsertValues) adjustAutoIncrementDatum(d types.Datum, col *table.Column) (types.Datum, error) {
+	vars := e.ctx.GetSessionVars()
+	var id int64
+	if !d.IsNull() {
+		var err error
+		if id, err = d.ToInt64(vars.StmtCtx); err != nil {
+			return d, errors.Trace(err)
+		}
+	}
+	if id != 0 {
+		if err := e.Table.RebaseAutoID(id); err != nil {
+			return d, errors.Trace(err)
+		}
+		vars.InsertID = uint64(id)
+		return d, nil
+	}
+	id, err := e.Table.AllocAutoID(e.ctx)
+	if err != nil {
+		return d, errors.Trace(err)
+	}
+	// Like MySQL, the last insert ID is the first ID generated by the statement.
+	if vars.LastInsertID == 0 {
+		vars.LastInsertID = uint64(id)
+	}
+	d, err = table.CastValue(e.ctx, types.NewIntDatum(id), col.ToInfo())
+	return d, errors.Trace(err)
+}
@@ -0,0 +1,474 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/join.go
+//
+
+package executor
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	plannercore "fedb/planner/core"
+	"fedb/util/chunk"
+)
+
+var (
+	_ Executor = &HashJoinExec{}
+	_ Executor = &NestedLoopApplyExec{}
+)
+
+// joinKeys are the columns of the join keys of a child, the "eq" keys are followed by the keys
+// of the NAConditions.
+type joinKeys struct {
+	cols []*expression.Column
+	// cmpTypes are the evaluation types by which the keys of the two children are compared.
+	cmpTypes  []types.EvalType
+	numEqKeys int
+}
+
+func newJoinKeys(eqKeys, otherEqKeys, naKeys, otherNAKeys []*expression.Column) *joinKeys {
+	k := &joinKeys{numEqKeys: len(eqKeys)}
+	k.cols = append(k.cols, eqKeys...)
+	k.cols = append(k.cols, naKeys...)
+	otherCols := append(append([]*expression.Column{}, otherEqKeys...), otherNAKeys...)
+	for i, col := range k.cols {
+		k.cmpTypes = append(k.cmpTypes, expression.GetCmpEvalType(col.RetType, otherCols[i].RetType))
+	}
+	return k
+}
+
+// encode encodes the keys in [begin, end) of the row, the values that are equal in comparison
+// are encoded to the same bytes. hasNull is true if any value is NULL.
+func (k *joinKeys) encode(sc *stmtctx.StatementContext, buf []byte, row chunk.Row, begin, end int) (_ []byte, hasNull bool, err error) {
+	for i := begin; i < end; i++ {
+		col := k.cols[i]
+		d := row.GetDatum(col.Index, col.RetType)
+		if d.IsNull() {
+			return buf, true, nil
+		}
+		d, err = expression.NormalizeCmpDatum(sc, k.cmpTypes[i], d)
+		if err != nil {
+			return buf, false, errors.Trace(err)
+		}
+		buf, err = codec.HashValues(sc, buf, d)
+		if err != nil {
+			return buf, false, errors.Trace(err)
+		}
+	}
+	return buf, false, nil
+}
+
+// rowPtr is the position of a row in the chunks of the hash table.
+type rowPtr struct {
+	chkIdx uint32
+	rowIdx uint32
+}
+
+// joinHashTable stores the inner rows by their join keys, the rows with a NULL "eq" key are not
+// stored because they never match.
+type joinHashTable struct {
+	sc     *stmtctx.StatementContext
+	keys   *joinKeys
+	chunks []*chunk.Chunk
+	// rows are indexed by all the keys.
+	rows map[string][]rowPtr
+	// The following are only used if there are keys of the NAConditions.
+	// eqRows are indexed by the "eq" keys, they are matched by the outer rows with a NULL NA key.
+	eqRows map[string][]rowPtr
+	// nullNARows are the rows with a NULL NA key indexed by the "eq" keys, the NAConditions are
+	// NULL on them for any outer row.
+	nullNARows map[string][]rowPtr
+	keyBuf     []byte
+}
+
+func newJoinHashTable(sc *stmtctx.StatementContext, keys *joinKeys) *joinHashTable {
+	return &joinHashTable{
+		sc:         sc,
+		keys:       keys,
+		rows:       make(map[string][]rowPtr),
+		eqRows:     make(map[string][]rowPtr),
+		nullNARows: make(map[string][]rowPtr),
+	}
+}
+
+// put stores the rows of chk, chk is owned by the hash table after it is put.
+func (t *joinHashTable) put(chk *chunk.Chunk) error {
+	chkIdx := uint32(len(t.chunks))
+	t.chunks = append(t.chunks, chk)
+	numEqKeys, numKeys := t.keys.numEqKeys, len(t.keys.cols)
+	for i := 0; i < chk.NumRows(); i++ {
+		row := chk.GetRow(i)
+		ptr := rowPtr{chkIdx: chkIdx, rowIdx: uint32(i)}
+		buf, hasNull, err := t.keys.encode(t.sc, t.keyBuf[:0], row, 0, numEqKeys)
+		t.keyBuf = buf
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if hasNull {
+			continue
+		}
+		if numEqKeys == numKeys {
+			t.rows[string(buf)] = append(t.rows[string(buf)], ptr)
+			continue
+		}
+		eqKey := string(buf)
+		t.eqRows[eqKey] = append(t.eqRows[eqKey], ptr)
+		buf, hasNull, err = t.keys.encode(t.sc, buf, row, numEqKeys, numKeys)
+		t.keyBuf = buf
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if hasNull {
+			t.nullNARows[eqKey] = append(t.nullNARows[eqKey], ptr)
+			continue
+		}
+		t.rows[string(buf)] = append(t.rows[string(buf)], ptr)
+	}
+	return nil
+}
+
+// isEmpty checks whether there is no row that can be matched.
+func (t *joinHashTable) isEmpty() bool {
+	return len(t.rows) == 0 && len(t.nullNARows) == 0
+}
+
+// get appends the inner rows that may match the outer row to rows, keys are the join keys of the
+// outer row.
+func (t *joinHashTable) get(keys *joinKeys, row chunk.Row, buf []byte, rows []chunk.Row) ([]chunk.Row, []byte, error) {
+	numEqKeys, numKeys := keys.numEqKeys, len(keys.cols)
+	buf, hasNull, err := keys.encode(t.sc, buf[:0], row, 0, numEqKeys)
+	if err != nil || hasNull {
+		return rows, buf, errors.Trace(err)
+	}
+	if numEqKeys == numKeys {
+		return t.appendRows(rows, t.rows[string(buf)]), buf, nil
+	}
+	eqKey := string(buf)
+	buf, hasNull, err = keys.encode(t.sc, buf, row, numEqKeys, numKeys)
+	if err != nil {
+		return rows, buf, errors.Trace(err)
+	}
+	if hasNull {
+		return t.appendRows(rows, t.eqRows[eqKey]), buf, nil
+	}
+	rows = t.appendRows(rows, t.rows[string(buf)])
+	return t.appendRows(rows, t.nullNARows[eqKey]), buf, nil
+}
+
+func (t *joinHashTable) appendRows(rows []chunk.Row, ptrs []rowPtr) []chunk.Row {
+	for _, ptr := range ptrs {
+		rows = append(rows, t.chunks[ptr.chkIdx].GetRow(int(ptr.rowIdx)))
+	}
+	return rows
+}
+
+// HashJoinExec implements the hash join algorithm.
+// The hash table is built on the rows of the inner child, then the chunks of the outer child are
+// read and probed by the workers concurrently. The children are only read by the goroutine that
+// calls Next, like the other executors.
+type HashJoinExec struct {
+	baseExecutor
+
+	outerExec    Executor
+	innerExec    Executor
+	outerIsRight bool
+	outerKeys    *joinKeys
+	innerKeys    *joinKeys
+	joinType     plannercore.JoinType
+	conditions   expression.CNFExprs
+	naConditions expression.CNFExprs
+	concurrency  int
+
+	prepared       bool
+	hashTable      *joinHashTable
+	outerExhausted bool
+	// numPending is the number of the outer chunks which are not finished by the workers.
+	numPending int
+	taskCh     chan *chunk.Chunk
+	resultCh   chan *hashJoinResult
+	closeCh    chan struct{}
+	workerWg   sync.WaitGroup
+}
+
+// hashJoinResult is the joined rows of a part of an outer chunk, done is true if it is the
+// last part of the chunk.
+type hashJoinResult struct {
+	chk  *chunk.Chunk
+	done bool
+	err  error
+}
+
+// Open implements the Executor Open interface.
+func (e *HashJoinExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.prepared = false
+	e.hashTable = nil
+	e.outerExhausted = false
+	e.numPending = 0
+	e.closeCh = nil
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *HashJoinExec) Close() error {
+	if e.closeCh != nil {
+		close(e.closeCh)
+		e.workerWg.Wait()
+		e.closeCh = nil
+	}
+	e.hashTable = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *HashJoinExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if !e.prepared {
+		if err := e.buildHashTable(goCtx); err != nil {
+			return errors.Trace(err)
+		}
+		// The outer rows never match if there is no inner row.
+		if e.hashTable.isEmpty() && (e.joinType == plannercore.InnerJoin || e.joinType == plannercore.SemiJoin) {
+			e.outerExhausted = true
+		}
+		e.startWorkers(goCtx)
+		e.prepared = true
+	}
+	for {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		for !e.outerExhausted && e.numPending < e.concurrency {
+			outerChk := e.outerExec.newFirstChunk()
+			if err := e.outerExec.Next(goCtx, chunk.NewRecordBatch(outerChk)); err != nil {
+				return errors.Trace(err)
+			}
+			if outerChk.NumRows() == 0 {
+				e.outerExhausted = true
+				break
+			}
+			e.taskCh <- outerChk
+			e.numPending++
+		}
+		if e.numPending == 0 {
+			return nil
+		}
+		var result *hashJoinResult
+		select {
+		case result = <-e.resultCh:
+		case <-goCtx.Done():
+			return errors.Trace(goCtx.Err())
+		}
+		if result.err != nil {
+			return errors.Trace(result.err)
+		}
+		if result.done {
+			e.numPending--
+		}
+		if result.chk != nil && result.chk.NumRows() > 0 {
+			req.SwapColumns(result.chk)
+			return nil
+		}
+	}
+}
+
+// buildHashTable reads all the rows of the inner child into the hash table.
+func (e *HashJoinExec) buildHashTable(goCtx goctx.Context) error {
+	e.hashTable = newJoinHashTable(e.ctx.GetSessionVars().StmtCtx, e.innerKeys)
+	for {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		chk := e.innerExec.newFirstChunk()
+		if err := e.innerExec.Next(goCtx, chunk.NewRecordBatch(chk)); err != nil {
+			return errors.Trace(err)
+		}
+		if chk.NumRows() == 0 {
+			return nil
+		}
+		if err := e.hashTable.put(chk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// startWorkers starts the join workers, they are interrupted once goCtx of the statement is done.
+func (e *HashJoinExec) startWorkers(goCtx goctx.Context) {
+	e.taskCh = make(chan *chunk.Chunk, e.concurrency)
+	e.resultCh = make(chan *hashJoinResult, e.concurrency)
+	e.closeCh = make(chan struct{})
+	lhsTypes, rhsTypes := e.innerExec.retTypes(), e.outerExec.retTypes()
+	if !e.outerIsRight {
+		lhsTypes, rhsTypes = rhsTypes, lhsTypes
+	}
+	for i := 0; i < e.concurrency; i++ {
+		// The expressions cache the values of the arguments, so every worker has its own copy.
+		j := newJoiner(e.ctx, e.joinType, e.outerIsRight, e.conditions.Clone(), e.naConditions.Clone(), lhsTypes, rhsTypes)
+		e.workerWg.Add(1)
+		go e.runJoinWorker(goCtx, j, e.taskCh, e.resultCh, e.closeCh)
+	}
+}
+
+// runJoinWorker joins the outer chunks with the hash table until the executor is closed.
+func (e *HashJoinExec) runJoinWorker(goCtx goctx.Context, j *joiner, taskCh <-chan *chunk.Chunk, resultCh chan<- *hashJoinResult, closeCh <-chan struct{}) {
+	defer e.workerWg.Done()
+	send := func(result *hashJoinResult) bool {
+		select {
+		case resultCh <- result:
+			return true
+		case <-closeCh:
+			return false
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			send(&hashJoinResult{err: errors.Errorf("hash join worker panicked: %v", r), done: true})
+		}
+	}()
+	var (
+		keyBuf []byte
+		inners []chunk.Row
+		err    error
+	)
+	for {
+		var outerChk *chunk.Chunk
+		select {
+		case outerChk = <-taskCh:
+		case <-closeCh:
+			return
+		}
+		chk := e.newFirstChunk()
+		for i := 0; i < outerChk.NumRows() && err == nil; i++ {
+			if chk.IsFull() {
+				if !send(&hashJoinResult{chk: chk}) {
+					return
+				}
+				chk = e.newFirstChunk()
+			}
+			row := outerChk.GetRow(i)
+			inners, keyBuf, err = e.hashTable.get(e.outerKeys, row, keyBuf, inners[:0])
+			if err == nil {
+				err = j.join(goCtx, row, inners, chk)
+			}
+		}
+		if !send(&hashJoinResult{chk: chk, done: true, err: err}) || err != nil {
+			return
+		}
+	}
+}
+
+// NestedLoopApplyExec is the executor for apply, the inner child is executed again for every
+// row of the outer child with the correlated columns set by the row.
+type NestedLoopApplyExec struct {
+	baseExecutor
+
+	outerExec   Executor
+	innerExec   Executor
+	outerSchema []*expression.CorrelatedColumn
+	joiner      *joiner
+
+	outerChk    *chunk.Chunk
+	outerCursor int
+	innerChunks []*chunk.Chunk
+	innerRows   []chunk.Row
+	innerOpened bool
+}
+
+// Open implements the Executor Open interface.
+// Only the outer child is opened, the inner child is opened for every outer row.
+func (e *NestedLoopApplyExec) Open(goCtx goctx.Context) error {
+	if err := e.outerExec.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.outerChk = e.outerExec.newFirstChunk()
+	e.outerCursor = 0
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *NestedLoopApplyExec) Close() error {
+	e.outerChk = nil
+	e.innerChunks, e.innerRows = nil, nil
+	if e.innerOpened {
+		e.innerOpened = false
+		if err := e.innerExec.Close(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(e.outerExec.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *NestedLoopApplyExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	for !req.IsFull() {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		if e.outerCursor >= e.outerChk.NumRows() {
+			if err := e.outerExec.Next(goCtx, chunk.NewRecordBatch(e.outerChk)); err != nil {
+				return errors.Trace(err)
+			}
+			if e.outerChk.NumRows() == 0 {
+				return nil
+			}
+			e.outerCursor = 0
+		}
+		outer := e.outerChk.GetRow(e.outerCursor)
+		e.outerCursor++
+		for _, col := range e.outerSchema {
+			*col.Data = outer.GetDatum(col.Index, col.RetType)
+		}
+		if err := e.fetchInnerRows(goCtx); err != nil {
+			return errors.Trace(err)
+		}
+		if err := e.joiner.join(goCtx, outer, e.innerRows, req.Chunk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// fetchInnerRows executes the inner child and reads all of its rows.
+func (e *NestedLoopApplyExec) fetchInnerRows(goCtx goctx.Context) error {
+	if err := e.innerExec.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.innerOpened = true
+	e.innerChunks, e.innerRows = e.innerChunks[:0], e.innerRows[:0]
+	for {
+		chk := e.innerExec.newFirstChunk()
+		if err := e.innerExec.Next(goCtx, chunk.NewRecordBatch(chk)); err != nil {
+			return errors.Trace(err)
+		}
+		if chk.NumRows() == 0 {
+			break
+		}
+		e.innerChunks = append(e.innerChunks, chk)
+		for i := 0; i < chk.NumRows(); i++ {
+			e.innerRows = append(e.innerRows, chk.GetRow(i))
+		}
+	}
+	e.innerOpened = false
+	return errors.Trace(e.innerExec.Close())
+}
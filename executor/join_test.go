@@ -0,0 +1,240 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package executor_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/executor"
+	plannercore "fedb/planner/core"
+	"fedb/session"
+	"fedb/sessionctx"
+	"fedb/store"
+	"fedb/store/localstore"
+	"fedb/store/localstore/memory"
+)
+
+func newTestSession(t *testing.T, name string) session.Session {
+	// The driver is registered by every test, only the first succeeds.
+	store.Register("memory", localstore.Driver{Driver: memory.Driver{}})
+	s, err := store.New("memory://" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = session.BootstrapSession(s); err != nil {
+		t.Fatal(err)
+	}
+	se, err := session.CreateSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, se, "create database test")
+	mustExec(t, se, "use test")
+	return se
+}
+
+// execSQL executes the statements with goCtx and returns the rows of the last record set.
+func execSQL(goCtx goctx.Context, se session.Session, sql string) ([][]string, error) {
+	rss, err := se.Execute(goCtx, sql)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, rs := range rss {
+		var fts []*types.FieldType
+		for _, f := range rs.Fields() {
+			fts = append(fts, &f.Column.FieldType)
+		}
+		rows = nil
+		for {
+			req := rs.NewRecordBatch()
+			if err = rs.Next(goCtx, req); err != nil || req.NumRows() == 0 {
+				break
+			}
+			for i := 0; i < req.NumRows(); i++ {
+				var row []string
+				for _, d := range req.GetRow(i).GetDatumRow(fts) {
+					if d.IsNull() {
+						row = append(row, "NULL")
+						continue
+					}
+					s, _ := d.ToString()
+					row = append(row, s)
+				}
+				rows = append(rows, row)
+			}
+		}
+		if closeErr := rs.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func mustExec(t *testing.T, se session.Session, sql string) [][]string {
+	rows, err := execSQL(goctx.Background(), se, sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return rows
+}
+
+func TestKillJoinQuery(t *testing.T) {
+	se := newTestSession(t, "TestKillJoinQuery")
+	mustExec(t, se, "create table t (a int)")
+	var values []string
+	for i := 0; i < 80; i++ {
+		values = append(values, fmt.Sprintf("(%d)", i))
+	}
+	mustExec(t, se, "insert into t values "+strings.Join(values, ", "))
+
+	for _, sql := range []string{
+		"select count(*) from t t1, t t2, t t3, t t4",
+		"select t1.a, count(*) from t t1, t t2, t t3, t t4 group by t1.a",
+	} {
+		goCtx, cancel := goctx.WithCancel(goctx.Background())
+		done := make(chan error, 1)
+		go func() {
+			_, err := execSQL(goCtx, se, sql)
+			done <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		select {
+		case err := <-done:
+			tErr, ok := errors.Cause(err).(*terror.Error)
+			if !ok || tErr.ToSQLError().Code != mysql.ErrQueryInterrupted {
+				t.Fatalf("%s: expected the query to be interrupted, got %v", sql, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: the query is not interrupted", sql)
+		}
+	}
+}
+
+// joinTypes returns the types of the joins in the plan of the query.
+func joinTypes(t *testing.T, se session.Session, sql string) []string {
+	stmts, err := se.Parse(goctx.Background(), sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := (&executor.Compiler{Ctx: se.(sessionctx.Context)}).Compile(goctx.Background(), stmts[0])
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	var types []string
+	var walk func(p plannercore.PhysicalPlan)
+	walk = func(p plannercore.PhysicalPlan) {
+		switch p.(type) {
+		case *plannercore.PhysicalHashJoin:
+			types = append(types, "hash")
+		case *plannercore.PhysicalMergeJoin:
+			types = append(types, "merge")
+		case *plannercore.PhysicalIndexJoin:
+			types = append(types, "index")
+		}
+		for _, child := range p.Children() {
+			walk(child)
+		}
+	}
+	walk(stmt.Plan.(plannercore.PhysicalPlan))
+	return types
+}
+
+func TestJoinNulls(t *testing.T) {
+	se := newTestSession(t, "TestJoinNulls")
+	mustExec(t, se, "create table t1 (id int primary key, a int, index ia (a))")
+	mustExec(t, se, "create table t2 (id int primary key, b int, index ib (b))")
+	mustExec(t, se, "insert into t1 values (1, 1), (2, 2), (3, NULL), (4, 4)")
+	mustExec(t, se, "insert into t2 values (1, 1), (2, NULL), (3, 2), (4, 2), (5, 5)")
+
+	joins := []struct {
+		tp    string
+		hint  string
+		rHint string
+	}{
+		{"hash", "/*+ TIDB_HJ(t1, t2) */", "/*+ TIDB_HJ(t1, t2) */"},
+		{"merge", "/*+ TIDB_SMJ(t1, t2) */", "/*+ TIDB_SMJ(t1, t2) */"},
+		// The inner table of the index join is the one of the hint.
+		{"index", "/*+ TIDB_INLJ(t2) */", "/*+ TIDB_INLJ(t1) */"},
+	}
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"select %[1]s t1.id, t2.id from t1 join t2 on t1.a = t2.b order by t1.id, t2.id",
+			"[[1 1] [2 3] [2 4]]"},
+		{"select %[1]s t1.id, t2.id from t1 left join t2 on t1.a = t2.b order by t1.id, t2.id",
+			"[[1 1] [2 3] [2 4] [3 NULL] [4 NULL]]"},
+		{"select %[2]s t1.id, t2.id from t1 right join t2 on t1.a = t2.b order by t2.id",
+			"[[1 1] [NULL 2] [2 3] [2 4] [NULL 5]]"},
+		{"select %[1]s t1.id, t2.id from t1 left join t2 on t1.a = t2.b and t2.id > 3 order by t1.id, t2.id",
+			"[[1 NULL] [2 4] [3 NULL] [4 NULL]]"},
+	}
+	for _, join := range joins {
+		for _, tt := range tests {
+			sql := fmt.Sprintf(tt.sql, join.hint, join.rHint)
+			if tps := joinTypes(t, se, sql); len(tps) != 1 || tps[0] != join.tp {
+				t.Fatalf("%s: expected the %s join, got %v", sql, join.tp, tps)
+			}
+			if rows := fmt.Sprint(mustExec(t, se, sql)); rows != tt.expected {
+				t.Fatalf("%s: expected %s, got %s", sql, tt.expected, rows)
+			}
+		}
+	}
+}
+
+func TestSemiJoinNulls(t *testing.T) {
+	se := newTestSession(t, "TestSemiJoinNulls")
+	mustExec(t, se, "create table t1 (id int primary key, a int)")
+	mustExec(t, se, "create table t2 (id int primary key, b int)")
+	mustExec(t, se, "insert into t1 values (1, 1), (2, 2), (3, NULL), (4, 4)")
+	mustExec(t, se, "insert into t2 values (1, 1), (2, NULL), (3, 2), (4, 2), (5, 5)")
+
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{"select id from t1 where a in (select b from t2) order by id", "[[1] [2]]"},
+		// NULL on the inner side.
+		{"select id from t1 where a not in (select b from t2) order by id", "[]"},
+		// NULL on the outer side.
+		{"select id from t1 where a not in (select b from t2 where b is not null) order by id", "[[4]]"},
+		{"select id from t1 where a not in (select b from t2 where id > 10) order by id", "[[1] [2] [3] [4]]"},
+		{"select id from t1 where not exists (select 1 from t2 where t2.b = t1.a) order by id", "[[3] [4]]"},
+		{"select id from t1 where exists (select 1 from t2 where t2.b = t1.a) order by id", "[[1] [2]]"},
+		// NULL on both sides, the results of IN and NOT IN are NULL.
+		{"select id, a in (select b from t2) from t1 order by id", "[[1 1] [2 1] [3 NULL] [4 NULL]]"},
+		{"select id, a not in (select b from t2) from t1 order by id", "[[1 0] [2 0] [3 NULL] [4 NULL]]"},
+		{"select id, a not in (select b from t2 where b is not null) from t1 order by id", "[[1 0] [2 0] [3 NULL] [4 1]]"},
+		{"select id, a in (select b from t2 where id > 10) from t1 order by id", "[[1 0] [2 0] [3 0] [4 0]]"},
+	}
+	for _, tt := range tests {
+		if rows := fmt.Sprint(mustExec(t, se, tt.sql)); rows != tt.expected {
+			t.Fatalf("%s: expected %s, got %s", tt.sql, tt.expected, rows)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/joiner.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// joiner joins an outer row with the inner rows whose join keys are equal to the ones of the
+// outer row, and appends the results to a chunk by the join type.
+type joiner struct {
+	ctx          sessionctx.Context
+	joinType     plannercore.JoinType
+	outerIsRight bool
+	conditions   expression.CNFExprs
+	naConditions expression.CNFExprs
+
+	// defaultInner is the row of NULLs padded to the unmatched outer rows of the outer joins.
+	defaultInner chunk.Row
+	// joined holds the outer row joined with a batch of the inner rows, the conditions are
+	// evaluated on it.
+	joined   *chunk.Chunk
+	selected []bool
+}
+
+func newJoiner(ctx sessionctx.Context, joinType plannercore.JoinType, outerIsRight bool, conditions, naConditions expression.CNFExprs,
+	lhsTypes, rhsTypes []*types.FieldType) *joiner {
+	j := &joiner{
+		ctx:          ctx,
+		joinType:     joinType,
+		outerIsRight: outerIsRight,
+		conditions:   conditions,
+		naConditions: naConditions,
+	}
+	if joinType == plannercore.LeftOuterJoin || joinType == plannercore.RightOuterJoin {
+		innerTypes := rhsTypes
+		if outerIsRight {
+			innerTypes = lhsTypes
+		}
+		chk := chunk.NewChunkWithCapacity(innerTypes, 1)
+		if len(innerTypes) == 0 {
+			chk.SetNumVirtualRows(1)
+		}
+		for i := range innerTypes {
+			chk.AppendNull(i)
+		}
+		j.defaultInner = chk.GetRow(0)
+	}
+	joinedTypes := make([]*types.FieldType, 0, len(lhsTypes)+len(rhsTypes))
+	joinedTypes = append(joinedTypes, lhsTypes...)
+	joinedTypes = append(joinedTypes, rhsTypes...)
+	j.joined = chunk.NewChunkWithCapacity(joinedTypes, ctx.GetSessionVars().MaxChunkSize())
+	return j
+}
+
+// join appends the results of the outer row and the inner rows to chk. The join is interrupted
+// between the batches of the inner rows once goCtx is done.
+func (j *joiner) join(goCtx goctx.Context, outer chunk.Row, inners []chunk.Row, chk *chunk.Chunk) error {
+	switch j.joinType {
+	case plannercore.SemiJoin, plannercore.AntiSemiJoin, plannercore.LeftOuterSemiJoin, plannercore.AntiLeftOuterSemiJoin:
+		matched, hasNull, err := j.match(goCtx, outer, inners)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		j.appendSemiResult(outer, matched, hasNull, chk)
+		return nil
+	}
+	numMatched := 0
+	for begin := 0; begin < len(inners); begin += j.joined.Capacity() {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		end := begin + j.joined.Capacity()
+		if end > len(inners) {
+			end = len(inners)
+		}
+		if err := j.filter(outer, inners[begin:end]); err != nil {
+			return errors.Trace(err)
+		}
+		for i, selected := range j.selected {
+			if selected {
+				chk.AppendRow(j.joined.GetRow(i))
+				numMatched++
+			}
+		}
+	}
+	if numMatched == 0 && !j.defaultInner.IsEmpty() {
+		j.appendJoinedRow(outer, j.defaultInner, chk)
+	}
+	return nil
+}
+
+func (j *joiner) appendJoinedRow(outer, inner chunk.Row, chk *chunk.Chunk) {
+	if j.outerIsRight {
+		chk.AppendJoinedRow(inner, outer)
+		return
+	}
+	chk.AppendJoinedRow(outer, inner)
+}
+
+// filter joins the outer row with the inner rows and evaluates the conditions on them.
+func (j *joiner) filter(outer chunk.Row, inners []chunk.Row) (err error) {
+	j.joined.Reset()
+	for _, inner := range inners {
+		j.appendJoinedRow(outer, inner, j.joined)
+	}
+	j.selected, err = expression.VectorizedFilter(j.ctx, j.conditions, j.joined, j.selected)
+	return errors.Trace(err)
+}
+
+// match checks whether the outer row matches any of the inner rows for the semi joins, hasNull
+// is true if it doesn't match but the NAConditions are NULL on some rows.
+func (j *joiner) match(goCtx goctx.Context, outer chunk.Row, inners []chunk.Row) (matched bool, hasNull bool, err error) {
+	for begin := 0; begin < len(inners); begin += j.joined.Capacity() {
+		if err = goCtx.Err(); err != nil {
+			return false, false, errors.Trace(err)
+		}
+		end := begin + j.joined.Capacity()
+		if end > len(inners) {
+			end = len(inners)
+		}
+		if err = j.filter(outer, inners[begin:end]); err != nil {
+			return false, false, errors.Trace(err)
+		}
+		for i, selected := range j.selected {
+			if !selected {
+				continue
+			}
+			if len(j.naConditions) == 0 {
+				return true, false, nil
+			}
+			ok, isNull, err := expression.EvalBoolWithNull(j.ctx, j.naConditions, j.joined.GetRow(i))
+			if err != nil {
+				return false, false, errors.Trace(err)
+			}
+			if ok {
+				return true, false, nil
+			}
+			hasNull = hasNull || isNull
+		}
+	}
+	return false, hasNull, nil
+}
+
+func (j *joiner) appendSemiResult(outer chunk.Row, matched, hasNull bool, chk *chunk.Chunk) {
+	switch j.joinType {
+	case plannercore.SemiJoin:
+		if matched {
+			chk.AppendRow(outer)
+		}
+	case plannercore.AntiSemiJoin:
+		if !matched && !hasNull {
+			chk.AppendRow(outer)
+		}
+	case plannercore.LeftOuterSemiJoin, plannercore.AntiLeftOuterSemiJoin:
+		chk.AppendPartialRow(0, outer)
+		auxIdx := outer.Len()
+		switch {
+		case hasNull:
+			chk.AppendNull(auxIdx)
+		case matched == (j.joinType == plannercore.LeftOuterSemiJoin):
+			chk.AppendInt64(auxIdx, 1)
+		default:
+			chk.AppendInt64(auxIdx, 0)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/merge_join.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/util/chunk"
+)
+
+var _ Executor = &MergeJoinExec{}
+
+// MergeJoinExec implements the merge join algorithm.
+// Both children are sorted by the join keys in ascending order, the rows of the inner child with
+// the same keys are grouped and every outer row is joined with the group of its keys.
+type MergeJoinExec struct {
+	baseExecutor
+
+	sc        *stmtctx.StatementContext
+	outerExec Executor
+	outerKeys []*expression.Column
+	innerIter *mergeJoinInnerIterator
+	joiner    *joiner
+
+	prepared    bool
+	outerChk    *chunk.Chunk
+	outerCursor int
+}
+
+// mergeJoinInnerIterator reads the groups of the rows of the inner child with the same keys.
+type mergeJoinInnerIterator struct {
+	sc   *stmtctx.StatementContext
+	exec Executor
+	keys []*expression.Column
+
+	chk       *chunk.Chunk
+	cursor    int
+	exhausted bool
+	// group holds the copies of the rows of the current group, because chk is reused.
+	group     *chunk.Chunk
+	groupRows []chunk.Row
+	// groupKey is the keys of the current group, nil means there is no more group.
+	groupKey []types.Datum
+}
+
+func (it *mergeJoinInnerIterator) open() {
+	it.chk = it.exec.newFirstChunk()
+	it.cursor = 0
+	it.exhausted = false
+	it.group = it.exec.newFirstChunk()
+	it.groupRows = nil
+	it.groupKey = nil
+}
+
+// nextGroup reads the next group of rows, the rows with a NULL key are skipped since they never match.
+func (it *mergeJoinInnerIterator) nextGroup(goCtx goctx.Context) error {
+	it.group.Reset()
+	it.groupRows = it.groupRows[:0]
+	it.groupKey = nil
+	for {
+		if it.cursor >= it.chk.NumRows() {
+			if it.exhausted {
+				break
+			}
+			if err := it.exec.Next(goCtx, chunk.NewRecordBatch(it.chk)); err != nil {
+				return errors.Trace(err)
+			}
+			if it.chk.NumRows() == 0 {
+				it.exhausted = true
+				break
+			}
+			it.cursor = 0
+		}
+		row := it.chk.GetRow(it.cursor)
+		key := getJoinKey(row, it.keys)
+		if key == nil {
+			it.cursor++
+			continue
+		}
+		if it.groupKey == nil {
+			for _, d := range key {
+				it.groupKey = append(it.groupKey, types.CopyDatum(d))
+			}
+		} else {
+			cmp, err := compareJoinKey(it.sc, key, it.groupKey)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if cmp != 0 {
+				// The row is the first one of the next group.
+				break
+			}
+		}
+		it.group.AppendRow(row)
+		it.cursor++
+	}
+	for i := 0; i < it.group.NumRows(); i++ {
+		it.groupRows = append(it.groupRows, it.group.GetRow(i))
+	}
+	return nil
+}
+
+// getJoinKey returns the values of the keys of the row, nil is returned if any of them is NULL.
+func getJoinKey(row chunk.Row, keys []*expression.Column) []types.Datum {
+	key := make([]types.Datum, 0, len(keys))
+	for _, col := range keys {
+		d := row.GetDatum(col.Index, col.RetType)
+		if d.IsNull() {
+			return nil
+		}
+		key = append(key, d)
+	}
+	return key
+}
+
+func compareJoinKey(sc *stmtctx.StatementContext, lhs, rhs []types.Datum) (int, error) {
+	for i := range lhs {
+		cmp, err := lhs[i].CompareDatum(sc, &rhs[i])
+		if err != nil || cmp != 0 {
+			return cmp, errors.Trace(err)
+		}
+	}
+	return 0, nil
+}
+
+// Open implements the Executor Open interface.
+func (e *MergeJoinExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.prepared = false
+	e.outerChk = e.outerExec.newFirstChunk()
+	e.outerCursor = 0
+	e.innerIter.open()
+	return nil
+}
+
+// Close implements the Executor Close interface.
+func (e *MergeJoinExec) Close() error {
+	e.outerChk = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+// Next implements the Executor Next interface.
+func (e *MergeJoinExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if !e.prepared {
+		if err := e.innerIter.nextGroup(goCtx); err != nil {
+			return errors.Trace(err)
+		}
+		e.prepared = true
+	}
+	for !req.IsFull() {
+		if err := goCtx.Err(); err != nil {
+			return errors.Trace(err)
+		}
+		if e.outerCursor >= e.outerChk.NumRows() {
+			if err := e.outerExec.Next(goCtx, chunk.NewRecordBatch(e.outerChk)); err != nil {
+				return errors.Trace(err)
+			}
+			if e.outerChk.NumRows() == 0 {
+				return nil
+			}
+			e.outerCursor = 0
+		}
+		outer := e.outerChk.GetRow(e.outerCursor)
+		e.outerCursor++
+		inners, err := e.getInnerRows(goCtx, outer)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = e.joiner.join(goCtx, outer, inners, req.Chunk); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// getInnerRows skips the inner groups before the keys of the outer row, and returns the group of
+// the same keys.
+func (e *MergeJoinExec) getInnerRows(goCtx goctx.Context, outer chunk.Row) ([]chunk.Row, error) {
+	key := getJoinKey(outer, e.outerKeys)
+	if key == nil {
+		return nil, nil
+	}
+	for e.innerIter.groupKey != nil {
+		cmp, err := compareJoinKey(e.sc, e.innerIter.groupKey, key)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if cmp == 0 {
+			return e.innerIter.groupRows, nil
+		}
+		if cmp > 0 {
+			return nil, nil
+		}
+		if err = e.innerIter.nextGroup(goCtx); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return nil, nil
+}
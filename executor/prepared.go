@@ -0,0 +1,123 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/prepared.go
+//
+
+package executor
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+
+	plannercore "fedb/planner/core"
+	"fedb/sessionctx"
+)
+
+type paramMarkerSorter struct {
+	markers []ast.ParamMarkerExpr
+}
+
+func (p *paramMarkerSorter) Len() int {
+	return len(p.markers)
+}
+
+func (p *paramMarkerSorter) Less(i, j int) bool {
+	return p.markers[i].(*driver.ParamMarkerExpr).Offset < p.markers[j].(*driver.ParamMarkerExpr).Offset
+}
+
+func (p *paramMarkerSorter) Swap(i, j int) {
+	p.markers[i], p.markers[j] = p.markers[j], p.markers[i]
+}
+
+type paramMarkerExtractor struct {
+	markers []ast.ParamMarkerExpr
+}
+
+func (e *paramMarkerExtractor) Enter(in ast.Node) (ast.Node, bool) {
+	return in, false
+}
+
+func (e *paramMarkerExtractor) Leave(in ast.Node) (ast.Node, bool) {
+	if x, ok := in.(*driver.ParamMarkerExpr); ok {
+		e.markers = append(e.markers, x)
+	}
+	return in, true
+}
+
+// Prepare collects the parameter markers of a statement in the order of their positions, the
+// statements which are compiled are planned to check them before they are executed, and the
+// fields of the rows they return are returned.
+func Prepare(ctx sessionctx.Context, stmt ast.StmtNode) (*ast.Prepared, []*ast.ResultField, error) {
+	var extractor paramMarkerExtractor
+	stmt.Accept(&extractor)
+
+	// Prepare parameters should NOT over 2 bytes(MaxUint16)
+	// https://dev.mysql.com/doc/internals/en/com-stmt-prepare-response.html#packet-COM_STMT_PREPARE_OK.
+	if len(extractor.markers) > math.MaxUint16 {
+		return nil, nil, ErrPsManyParam
+	}
+
+	// The parameter markers are appended in visiting order, which may not
+	// be the same as the position order in the query string. We need to
+	// sort it by position.
+	sorter := &paramMarkerSorter{markers: extractor.markers}
+	sort.Sort(sorter)
+	for i, marker := range sorter.markers {
+		marker.SetOrder(i)
+	}
+	is := ctx.GetInfoSchema()
+	prepared := &ast.Prepared{
+		Stmt:          stmt,
+		Params:        sorter.markers,
+		SchemaVersion: is.SchemaMetaVersion(),
+	}
+	if _, ok := stmt.(ast.DMLNode); !ok {
+		return prepared, nil, nil
+	}
+
+	// We try to build the real statement of preparedStmt.
+	for _, param := range prepared.Params {
+		param.(*driver.ParamMarkerExpr).Datum = types.NewIntDatum(0)
+	}
+	p, err := plannercore.Optimize(ctx, stmt, is)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	var fields []*ast.ResultField
+	switch stmt.(type) {
+	case *ast.SelectStmt, *ast.UnionStmt, *ast.ShowStmt:
+		fields = schema2ResultFields(p.Schema(), ctx.GetSessionVars().CurrentDB)
+	}
+	return prepared, fields, nil
+}
+
+// SetPreparedParams sets the values of the parameter markers of a prepared statement, the
+// statement is planned again with the values when it's executed.
+func SetPreparedParams(prepared *ast.Prepared, args []interface{}) error {
+	if len(args) != len(prepared.Params) {
+		return ErrWrongParamCount.GenWithStackByArgs("EXECUTE")
+	}
+	for i, arg := range args {
+		param := prepared.Params[i].(*driver.ParamMarkerExpr)
+		param.SetValue(arg)
+		param.Type = types.FieldType{}
+		types.DefaultTypeForValue(arg, &param.Type)
+	}
+	return nil
+}
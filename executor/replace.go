@@ -0,0 +1,94 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/replace.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+	"fedb/util/chunk"
+)
+
+// ReplaceExec represents a replace executor.
+type ReplaceExec struct {
+	*InsertValues
+}
+
+// Next implements the Executor Next interface. All the rows are replaced at once.
+func (e *ReplaceExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	rows, err := e.getRows(goCtx)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, row := range rows {
+		if err = e.replaceRow(row); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// replaceRow removes the rows that the row conflicts with on the primary key or the unique keys,
+// then adds the row. Every row removed or added counts as an affected row, a row the same as
+// the one it replaces is not written.
+func (e *ReplaceExec) replaceRow(row []types.Datum) error {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for {
+		h, err := findDuplicate(e.ctx, e.Table, row)
+		if err == nil {
+			break
+		}
+		if !kv.ErrKeyExists.Equal(err) {
+			return errors.Trace(err)
+		}
+		oldRow, err := e.Table.Row(e.ctx, h)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		equal, err := e.equalRows(oldRow, row)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if equal {
+			sc.AddAffectedRows(1)
+			return nil
+		}
+		if err = e.Table.RemoveRecord(e.ctx, h, oldRow); err != nil {
+			return errors.Trace(err)
+		}
+		sc.AddAffectedRows(1)
+	}
+	if _, err := e.Table.AddRecord(e.ctx, row); err != nil {
+		return errors.Trace(err)
+	}
+	sc.AddAffectedRows(1)
+	return nil
+}
+
+func (e *ReplaceExec) equalRows(a, b []types.Datum) (bool, error) {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for i := range a {
+		cmp, err := a[i].CompareDatum(sc, &b[i])
+		if err != nil || cmp != 0 {
+			return false, errors.Trace(err)
+		}
+	}
+	return true, nil
+}
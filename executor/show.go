@@ -0,0 +1,540 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/show.go
+//
+
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/format"
+	goctx "golang.org/x/net/context"
+
+	"fedb/infoschema"
+	"fedb/sessionctx/variable"
+	"fedb/table"
+	"fedb/util/chunk"
+)
+
+var _ Executor = &ShowExec{}
+
+// ShowExec represents a show executor. The rows are generated when it's opened.
+type ShowExec struct {
+	baseExecutor
+
+	Tp          ast.ShowStmtType
+	DBName      model.CIStr
+	Table       *model.TableInfo
+	Column      *ast.ColumnName
+	Full        bool
+	GlobalScope bool
+	User        *auth.UserIdentity
+
+	is infoschema.InfoSchema
+
+	rows   [][]types.Datum
+	cursor int
+}
+
+// Open implements the Executor Open interface.
+func (e *ShowExec) Open(goCtx goctx.Context) error {
+	if err := e.baseExecutor.Open(goCtx); err != nil {
+		return errors.Trace(err)
+	}
+	e.rows, e.cursor = nil, 0
+	if err := e.fetchAll(); err != nil {
+		return errors.Trace(err)
+	}
+	// The varchar columns are widened to the longest values, the clients align the columns by them.
+	for colIdx, col := range e.Schema().Columns {
+		if col.RetType.Tp != mysql.TypeVarchar {
+			continue
+		}
+		for _, row := range e.rows {
+			if valLen := len(row[colIdx].GetString()); col.RetType.Flen < valLen {
+				col.RetType.Flen = valLen
+			}
+		}
+	}
+	return nil
+}
+
+// Next implements the Executor Next interface.
+func (e *ShowExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(fillRecordBatch(goCtx, req, e.nextRow))
+}
+
+func (e *ShowExec) nextRow(_ goctx.Context) ([]types.Datum, error) {
+	if e.cursor >= len(e.rows) {
+		return nil, nil
+	}
+	row := e.rows[e.cursor]
+	e.cursor++
+	return row, nil
+}
+
+// Close implements the Executor Close interface.
+func (e *ShowExec) Close() error {
+	e.rows = nil
+	return errors.Trace(e.baseExecutor.Close())
+}
+
+func (e *ShowExec) appendRow(row ...interface{}) {
+	e.rows = append(e.rows, types.MakeDatums(row...))
+}
+
+func (e *ShowExec) fetchAll() error {
+	switch e.Tp {
+	case ast.ShowDatabases:
+		e.fetchShowDatabases()
+	case ast.ShowTables:
+		e.fetchShowTables()
+	case ast.ShowColumns:
+		e.fetchShowColumns()
+	case ast.ShowIndex:
+		e.fetchShowIndex()
+	case ast.ShowCreateTable:
+		return e.fetchShowCreateTable()
+	case ast.ShowVariables:
+		e.fetchShowVariables()
+	case ast.ShowStatus:
+		return e.fetchShowStatus()
+	case ast.ShowWarnings:
+		e.fetchShowWarnings(false)
+	case ast.ShowErrors:
+		e.fetchShowWarnings(true)
+	case ast.ShowProcessList:
+		e.fetchShowProcessList()
+	case ast.ShowEngines:
+		e.fetchShowEngines()
+	case ast.ShowGrants:
+		return e.fetchShowGrants()
+	}
+	return nil
+}
+
+func (e *ShowExec) fetchShowEngines() {
+	e.appendRow(
+		"InnoDB",
+		"DEFAULT",
+		"Supports transactions, row-level locking, and foreign keys",
+		"YES",
+		"YES",
+		"YES",
+	)
+}
+
+// fetchShowDatabases shows the databases sorted by name, information_schema is the first. The
+// databases the user has no privilege on are not shown.
+func (e *ShowExec) fetchShowDatabases() {
+	pm := e.ctx.GetPrivilegeManager()
+	dbs := e.is.AllSchemaNames()
+	sort.Slice(dbs, func(i, j int) bool {
+		if infoschema.IsMemoryDB(strings.ToLower(dbs[i])) != infoschema.IsMemoryDB(strings.ToLower(dbs[j])) {
+			return infoschema.IsMemoryDB(strings.ToLower(dbs[i]))
+		}
+		return dbs[i] < dbs[j]
+	})
+	for _, d := range dbs {
+		if pm != nil && !pm.DBIsVisible(d) {
+			continue
+		}
+		e.appendRow(d)
+	}
+}
+
+func (e *ShowExec) fetchShowTables() {
+	schema, ok := e.is.SchemaByName(e.DBName)
+	if !ok {
+		return
+	}
+	tableType := "BASE TABLE"
+	if infoschema.IsMemoryDB(schema.Name.L) {
+		tableType = "SYSTEM VIEW"
+	}
+	pm := e.ctx.GetPrivilegeManager()
+	for _, tbl := range publicTables(schema) {
+		// The tables the user has no privilege on are not shown, unless they are system views.
+		if pm != nil && !infoschema.IsMemoryDB(schema.Name.L) &&
+			!pm.RequestVerification(schema.Name.L, tbl.Name.L, "", mysql.AllPrivMask) {
+			continue
+		}
+		if e.Full {
+			e.appendRow(tbl.Name.O, tableType)
+		} else {
+			e.appendRow(tbl.Name.O)
+		}
+	}
+}
+
+func (e *ShowExec) fetchShowColumns() {
+	for _, colInfo := range e.Table.Columns {
+		if colInfo.State != model.StatePublic {
+			continue
+		}
+		if e.Column != nil && e.Column.Name.L != colInfo.Name.L {
+			continue
+		}
+		desc := table.NewColDesc(table.ToColumn(colInfo))
+		var columnDefault interface{}
+		if desc.DefaultValue != nil {
+			// SHOW COLUMNS result expects string value
+			columnDefault = fmt.Sprintf("%v", desc.DefaultValue)
+		}
+
+		// The FULL keyword causes the output to include the column collation and comments,
+		// as well as the privileges you have for each column.
+		if e.Full {
+			var collation interface{}
+			if types.IsString(colInfo.Tp) && desc.Collation != "" && desc.Collation != charset.CollationBin {
+				collation = desc.Collation
+			}
+			e.appendRow(
+				desc.Field,
+				desc.Type,
+				collation,
+				desc.Null,
+				desc.Key,
+				columnDefault,
+				desc.Extra,
+				desc.Privileges,
+				desc.Comment,
+			)
+		} else {
+			e.appendRow(
+				desc.Field,
+				desc.Type,
+				desc.Null,
+				desc.Key,
+				columnDefault,
+				desc.Extra,
+			)
+		}
+	}
+}
+
+func (e *ShowExec) fetchShowIndex() {
+	tbl := e.Table
+	if tbl.PKIsHandle {
+		for _, col := range tbl.Columns {
+			if mysql.HasPriKeyFlag(col.Flag) {
+				e.appendRow(
+					tbl.Name.O, // Table
+					0,          // Non_unique
+					"PRIMARY",  // Key_name
+					1,          // Seq_in_index
+					col.Name.O, // Column_name
+					"A",        // Collation
+					0,          // Cardinality
+					nil,        // Sub_part
+					nil,        // Packed
+					"",         // Null
+					"BTREE",    // Index_type
+					"",         // Comment
+					"",         // Index_comment
+				)
+				break
+			}
+		}
+	}
+	nameToCol := make(map[string]*model.ColumnInfo, len(tbl.Columns))
+	for _, c := range tbl.Columns {
+		nameToCol[c.Name.L] = c
+	}
+	for _, idxInfo := range tbl.Indices {
+		if idxInfo.State != model.StatePublic {
+			continue
+		}
+		nonUniq := 1
+		if idxInfo.Unique {
+			nonUniq = 0
+		}
+		for i, col := range idxInfo.Columns {
+			var subPart interface{}
+			if col.Length != types.UnspecifiedLength {
+				subPart = col.Length
+			}
+			nullable := "YES"
+			if mysql.HasNotNullFlag(nameToCol[col.Name.L].Flag) {
+				nullable = ""
+			}
+			e.appendRow(
+				tbl.Name.O,      // Table
+				nonUniq,         // Non_unique
+				idxInfo.Name.O,  // Key_name
+				i+1,             // Seq_in_index
+				col.Name.O,      // Column_name
+				"A",             // Collation
+				0,               // Cardinality
+				subPart,         // Sub_part
+				nil,             // Packed
+				nullable,        // Null
+				"BTREE",         // Index_type
+				"",              // Comment
+				idxInfo.Comment, // Index_comment
+			)
+		}
+	}
+}
+
+// fetchShowVariables shows the system variables sorted by name. The session-only variables are
+// not shown by SHOW GLOBAL VARIABLES.
+func (e *ShowExec) fetchShowVariables() {
+	sessionVars := e.ctx.GetSessionVars()
+	names := make([]string, 0, len(variable.SysVars))
+	for name, sv := range variable.SysVars {
+		if e.GlobalScope && sv.Scope == variable.ScopeSession {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var value string
+		if e.GlobalScope {
+			value, _ = variable.GetGlobalSysVar(name)
+		} else {
+			value, _ = sessionVars.GetSystemVar(name)
+		}
+		e.appendRow(name, value)
+	}
+}
+
+// fetchShowStatus shows the status variables sorted by name. The session-only variables are not
+// shown by SHOW GLOBAL STATUS.
+func (e *ShowExec) fetchShowStatus() error {
+	statusVars, err := variable.GetStatusVars(e.ctx.GetSessionVars())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	names := make([]string, 0, len(statusVars))
+	for name, v := range statusVars {
+		if e.GlobalScope && v.Scope == variable.ScopeSession {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e.appendRow(name, fmt.Sprintf("%v", statusVars[name].Value))
+	}
+	return nil
+}
+
+// fetchShowWarnings shows the warnings of the last statement, or the errors only.
+func (e *ShowExec) fetchShowWarnings(errOnly bool) {
+	warns := e.ctx.GetSessionVars().StmtCtx.GetWarnings()
+	for _, w := range warns {
+		if errOnly && w.Level != stmtctx.WarnLevelError {
+			continue
+		}
+		warn := errors.Cause(w.Err)
+		switch x := warn.(type) {
+		case *terror.Error:
+			sqlErr := x.ToSQLError()
+			e.appendRow(w.Level, int64(sqlErr.Code), sqlErr.Message)
+		default:
+			e.appendRow(w.Level, int64(mysql.ErrUnknown), warn.Error())
+		}
+	}
+}
+
+// fetchShowProcessList shows the connections of the server ordered by ID, the statements are
+// truncated to 100 characters unless FULL is given.
+func (e *ShowExec) fetchShowProcessList() {
+	for _, pi := range getProcessList(e.ctx) {
+		var db, info interface{}
+		if pi.DB != "" {
+			db = pi.DB
+		}
+		if pi.Info != "" {
+			if e.Full {
+				info = pi.Info
+			} else {
+				info = fmt.Sprintf("%.100v", pi.Info)
+			}
+		}
+		e.appendRow(
+			pi.ID,
+			pi.User,
+			pi.Host,
+			db,
+			pi.Command,
+			uint64(time.Since(pi.Time)/time.Second),
+			fmt.Sprintf("%d", pi.State),
+			info,
+		)
+	}
+}
+
+// fetchShowGrants shows the GRANT statements of the privileges and the roles granted to the account.
+func (e *ShowExec) fetchShowGrants() error {
+	pm := e.ctx.GetPrivilegeManager()
+	if pm == nil {
+		return ErrOptionPreventsStatement.GenWithStackByArgs("--skip-grant-tables")
+	}
+	gs, ok := pm.ShowGrants(e.User.Username, strings.ToLower(e.User.Hostname))
+	if !ok {
+		return ErrNonexistingGrant.GenWithStackByArgs(e.User.Username, e.User.Hostname)
+	}
+	for _, g := range gs {
+		e.appendRow(g)
+	}
+	return nil
+}
+
+// escape the identifier for pretty-printing.
+// For instance, the identifier "foo `bar`" will become "`foo “bar```".
+func escape(cis model.CIStr) string {
+	return "`" + strings.Replace(cis.O, "`", "``", -1) + "`"
+}
+
+func (e *ShowExec) fetchShowCreateTable() error {
+	tb := e.Table
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "CREATE TABLE %s (\n", escape(tb.Name))
+	var pkCol *model.ColumnInfo
+	var hasAutoIncID bool
+	publicCols := make([]*model.ColumnInfo, 0, len(tb.Columns))
+	for _, col := range tb.Columns {
+		if col.State == model.StatePublic {
+			publicCols = append(publicCols, col)
+		}
+	}
+	for i, colInfo := range publicCols {
+		col := table.ToColumn(colInfo)
+		fmt.Fprintf(&buf, "  %s %s", escape(col.Name), col.GetTypeDesc())
+		if mysql.HasAutoIncrementFlag(col.Flag) {
+			hasAutoIncID = true
+			buf.WriteString(" NOT NULL AUTO_INCREMENT")
+		} else {
+			if mysql.HasNotNullFlag(col.Flag) {
+				buf.WriteString(" NOT NULL")
+			} else if col.Tp == mysql.TypeTimestamp {
+				// The timestamp columns are NOT NULL by default in MySQL.
+				buf.WriteString(" NULL")
+			}
+			if !mysql.HasNoDefaultValueFlag(col.Flag) {
+				defaultValue := col.GetDefaultValue()
+				switch defaultValue {
+				case nil:
+					if !mysql.HasNotNullFlag(col.Flag) {
+						buf.WriteString(" DEFAULT NULL")
+					}
+				case "CURRENT_TIMESTAMP":
+					buf.WriteString(" DEFAULT CURRENT_TIMESTAMP")
+				default:
+					fmt.Fprintf(&buf, " DEFAULT '%s'", format.OutputFormat(fmt.Sprintf("%v", defaultValue)))
+				}
+			}
+			if mysql.HasOnUpdateNowFlag(col.Flag) {
+				buf.WriteString(" ON UPDATE CURRENT_TIMESTAMP")
+			}
+		}
+		if len(col.Comment) > 0 {
+			fmt.Fprintf(&buf, " COMMENT '%s'", format.OutputFormat(col.Comment))
+		}
+		if i != len(publicCols)-1 {
+			buf.WriteString(",\n")
+		}
+		if tb.PKIsHandle && mysql.HasPriKeyFlag(col.Flag) {
+			pkCol = colInfo
+		}
+	}
+
+	if pkCol != nil {
+		// If PKIsHanle, pk info is not in tb.Indices. We should handle it here.
+		buf.WriteString(",\n")
+		fmt.Fprintf(&buf, "  PRIMARY KEY (%s)", escape(pkCol.Name))
+	}
+
+	publicIndices := make([]*model.IndexInfo, 0, len(tb.Indices))
+	for _, idx := range tb.Indices {
+		if idx.State == model.StatePublic {
+			publicIndices = append(publicIndices, idx)
+		}
+	}
+	if len(publicIndices) > 0 {
+		buf.WriteString(",\n")
+	}
+	for i, idxInfo := range publicIndices {
+		if idxInfo.Primary {
+			buf.WriteString("  PRIMARY KEY ")
+		} else if idxInfo.Unique {
+			fmt.Fprintf(&buf, "  UNIQUE KEY %s ", escape(idxInfo.Name))
+		} else {
+			fmt.Fprintf(&buf, "  KEY %s ", escape(idxInfo.Name))
+		}
+
+		cols := make([]string, 0, len(idxInfo.Columns))
+		for _, c := range idxInfo.Columns {
+			colInfo := escape(c.Name)
+			if c.Length != types.UnspecifiedLength {
+				colInfo = fmt.Sprintf("%s(%d)", colInfo, c.Length)
+			}
+			cols = append(cols, colInfo)
+		}
+		fmt.Fprintf(&buf, "(%s)", strings.Join(cols, ","))
+		if i != len(publicIndices)-1 {
+			buf.WriteString(",\n")
+		}
+	}
+	buf.WriteString("\n")
+
+	buf.WriteString(") ENGINE=InnoDB")
+	charsetName := tb.Charset
+	if len(charsetName) == 0 {
+		charsetName = mysql.DefaultCharset
+	}
+	collate := tb.Collate
+	if len(collate) == 0 {
+		collate = mysql.DefaultCollationName
+	}
+	fmt.Fprintf(&buf, " DEFAULT CHARSET=%s COLLATE=%s", charsetName, collate)
+
+	if hasAutoIncID {
+		if schema, ok := e.is.SchemaByName(e.DBName); ok {
+			autoIncID, err := getAutoIncrementID(e.ctx, e.is, schema, tb)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			// It's compatible with MySQL.
+			if id, ok := autoIncID.(int64); ok && id > 1 {
+				fmt.Fprintf(&buf, " AUTO_INCREMENT=%d", id)
+			}
+		}
+	}
+
+	if len(tb.Comment) > 0 {
+		fmt.Fprintf(&buf, " COMMENT='%s'", format.OutputFormat(tb.Comment))
+	}
+
+	e.appendRow(tb.Name.O, buf.String())
+	return nil
+}
@@ -0,0 +1,287 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/sort.go
+//
+
+package executor
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	plannercore "fedb/planner/core"
+	"fedb/util/chunk"
+)
+
+var (
+	_ Executor = &SortExec{}
+	_ Executor = &TopNExec{}
+)
+
+// orderByRow binds a row to its order values, so it can be sorted.
+type orderByRow struct {
+	key []types.Datum
+	row chunk.Row
+}
+
+// SortExec represents sorting executor.
+type SortExec struct {
+	baseExecutor
+
+	ByItems []*plannercore.ByItems
+	Rows    []*orderByRow
+	Idx     int
+	fetched bool
+	err     error
+
+	// keyExprs and keyTypes are the expressions and types of the order values.
+	keyExprs []expression.Expression
+	keyTypes []*types.FieldType
+}
+
+// Open implements the Executor Open interface.
+func (e *SortExec) Open(goCtx goctx.Context) error {
+	e.fetched = false
+	e.Idx = 0
+	e.Rows = nil
+	e.err = nil
+	e.keyExprs = make([]expression.Expression, 0, len(e.ByItems))
+	e.keyTypes = make([]*types.FieldType, 0, len(e.ByItems))
+	for _, by := range e.ByItems {
+		e.keyExprs = append(e.keyExprs, by.Expr)
+		e.keyTypes = append(e.keyTypes, by.Expr.GetType())
+	}
+	return errors.Trace(e.children[0].Open(goCtx))
+}
+
+// Close implements the Executor Close interface.
+func (e *SortExec) Close() error {
+	e.Rows = nil
+	return errors.Trace(e.children[0].Close())
+}
+
+// Len returns the number of rows.
+func (e *SortExec) Len() int {
+	return len(e.Rows)
+}
+
+// Swap implements sort.Interface Swap interface.
+func (e *SortExec) Swap(i, j int) {
+	e.Rows[i], e.Rows[j] = e.Rows[j], e.Rows[i]
+}
+
+// Less implements sort.Interface Less interface.
+func (e *SortExec) Less(i, j int) bool {
+	return e.lessRow(e.Rows[i], e.Rows[j])
+}
+
+// lessRow compares the order values of two rows, the first error is kept in e.err.
+func (e *SortExec) lessRow(rowI, rowJ *orderByRow) bool {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for index, by := range e.ByItems {
+		v1 := rowI.key[index]
+		v2 := rowJ.key[index]
+
+		ret, err := v1.CompareDatum(sc, &v2)
+		if err != nil {
+			e.err = errors.Trace(err)
+			return true
+		}
+
+		if by.Desc {
+			ret = -ret
+		}
+
+		if ret < 0 {
+			return true
+		} else if ret > 0 {
+			return false
+		}
+	}
+
+	return false
+}
+
+// fetchRows reads a chunk from the child and evaluates the order values of its rows, no rows
+// are returned when the child is drained. Every chunk is newly allocated, so the rows stay valid.
+func (e *SortExec) fetchRows(goCtx goctx.Context) ([]*orderByRow, error) {
+	chk := e.children[0].newFirstChunk()
+	if err := e.children[0].Next(goCtx, chunk.NewRecordBatch(chk)); err != nil {
+		return nil, errors.Trace(err)
+	}
+	n := chk.NumRows()
+	if n == 0 {
+		return nil, nil
+	}
+	keys := chunk.NewChunkWithCapacity(e.keyTypes, n)
+	if err := expression.VectorizedExecute(e.keyExprs, chk, keys); err != nil {
+		return nil, errors.Trace(err)
+	}
+	rows := make([]*orderByRow, 0, n)
+	for i := 0; i < n; i++ {
+		rows = append(rows, &orderByRow{
+			key: keys.GetRow(i).GetDatumRow(e.keyTypes),
+			row: chk.GetRow(i),
+		})
+	}
+	return rows, nil
+}
+
+// Next implements the Executor Next interface.
+// All the rows of the child are read and sorted by the first call.
+func (e *SortExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if !e.fetched {
+		for {
+			rows, err := e.fetchRows(goCtx)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if len(rows) == 0 {
+				break
+			}
+			e.Rows = append(e.Rows, rows...)
+		}
+		sort.Stable(e)
+		if e.err != nil {
+			return errors.Trace(e.err)
+		}
+		e.fetched = true
+	}
+	e.appendRows(req)
+	return nil
+}
+
+// appendRows appends the sorted rows to req until req is full.
+func (e *SortExec) appendRows(req *chunk.RecordBatch) {
+	for ; e.Idx < len(e.Rows) && !req.IsFull(); e.Idx++ {
+		req.AppendRow(e.Rows[e.Idx].row)
+	}
+}
+
+// topNRow is a row in the heap of TopNExec, seq is the position of the row in the input,
+// it keeps the rows with the same order values in the input order.
+type topNRow struct {
+	*orderByRow
+	seq int
+}
+
+// TopNExec implements a Top-N algorithm and it is built from a SELECT statement with ORDER BY and LIMIT.
+// Instead of sorting all the rows fetched from the child, it keeps the Top-N rows in a max heap.
+type TopNExec struct {
+	SortExec
+
+	limit    *plannercore.PhysicalTopN
+	heapRows []*topNRow
+}
+
+// Len implements heap.Interface Len interface.
+func (e *TopNExec) Len() int {
+	return len(e.heapRows)
+}
+
+// Swap implements heap.Interface Swap interface.
+func (e *TopNExec) Swap(i, j int) {
+	e.heapRows[i], e.heapRows[j] = e.heapRows[j], e.heapRows[i]
+}
+
+// Less implements heap.Interface Less interface, the largest row is at the top of the heap.
+func (e *TopNExec) Less(i, j int) bool {
+	return e.lessTopNRow(e.heapRows[j], e.heapRows[i])
+}
+
+func (e *TopNExec) lessTopNRow(rowI, rowJ *topNRow) bool {
+	if e.lessRow(rowI.orderByRow, rowJ.orderByRow) {
+		return true
+	}
+	if e.lessRow(rowJ.orderByRow, rowI.orderByRow) {
+		return false
+	}
+	return rowI.seq < rowJ.seq
+}
+
+// Push implements heap.Interface Push interface.
+func (e *TopNExec) Push(x interface{}) {
+	e.heapRows = append(e.heapRows, x.(*topNRow))
+}
+
+// Pop implements heap.Interface Pop interface.
+func (e *TopNExec) Pop() interface{} {
+	row := e.heapRows[len(e.heapRows)-1]
+	e.heapRows = e.heapRows[:len(e.heapRows)-1]
+	return row
+}
+
+// Open implements the Executor Open interface.
+func (e *TopNExec) Open(goCtx goctx.Context) error {
+	e.heapRows = nil
+	return errors.Trace(e.SortExec.Open(goCtx))
+}
+
+// Next implements the Executor Next interface.
+func (e *TopNExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if !e.fetched {
+		if err := e.loadTopN(goCtx); err != nil {
+			return errors.Trace(err)
+		}
+		e.fetched = true
+	}
+	e.appendRows(req)
+	return nil
+}
+
+// loadTopN reads all the rows of the child and keeps the smallest ones in the heap,
+// then the rows after the offset are sorted into e.Rows.
+func (e *TopNExec) loadTopN(goCtx goctx.Context) error {
+	totalLimit := e.limit.Offset + e.limit.Count
+	for seq := 0; totalLimit > 0; {
+		orderRows, err := e.fetchRows(goCtx)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if len(orderRows) == 0 {
+			break
+		}
+		for _, orderRow := range orderRows {
+			row := &topNRow{orderByRow: orderRow, seq: seq}
+			seq++
+			if uint64(len(e.heapRows)) < totalLimit {
+				heap.Push(e, row)
+			} else if e.lessTopNRow(row, e.heapRows[0]) {
+				e.heapRows[0] = row
+				heap.Fix(e, 0)
+			}
+			if e.err != nil {
+				return errors.Trace(e.err)
+			}
+		}
+	}
+	sort.Slice(e.heapRows, func(i, j int) bool {
+		return e.lessTopNRow(e.heapRows[i], e.heapRows[j])
+	})
+	if e.err != nil {
+		return errors.Trace(e.err)
+	}
+	for i := e.limit.Offset; i < uint64(len(e.heapRows)); i++ {
+		e.Rows = append(e.Rows, e.heapRows[i].orderByRow)
+	}
+	e.heapRows = nil
+	return nil
+}
@@ -0,0 +1,208 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/table_reader.go
+//
+
+package executor
+
+import (
+	"math"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+	"fedb/table"
+	"fedb/table/tables"
+	"fedb/tablecodec"
+	"fedb/util/chunk"
+	"fedb/util/ranger"
+)
+
+var _ Executor = &TableReaderExecutor{}
+
+// kvRangeScanner iterates the entries of the key ranges one range after another.
+type kvRangeScanner struct {
+	txn    kv.Transaction
+	ranges []kv.KeyRange
+	cursor int
+	it     kv.Iterator
+}
+
+// next moves to the next entry, it returns false when all the ranges are read. The statement is
+// interrupted between the entries once goCtx is done.
+func (s *kvRangeScanner) next(goCtx goctx.Context) (bool, error) {
+	if err := goCtx.Err(); err != nil {
+		return false, errors.Trace(err)
+	}
+	for {
+		if s.it != nil {
+			if s.it.Valid() {
+				return true, nil
+			}
+			s.it.Close()
+			s.it = nil
+		}
+		if s.cursor >= len(s.ranges) {
+			return false, nil
+		}
+		ran := s.ranges[s.cursor]
+		s.cursor++
+		it, err := s.txn.Iter(ran.StartKey, ran.EndKey)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		s.it = it
+	}
+}
+
+// advance moves the iterator past the current entry.
+func (s *kvRangeScanner) advance() error {
+	return errors.Trace(s.it.Next())
+}
+
+func (s *kvRangeScanner) close() {
+	if s.it != nil {
+		s.it.Close()
+		s.it = nil
+	}
+}
+
+// tableRangesToKVRanges converts the ranges of the int handle to the ranges of the row keys.
+func tableRangesToKVRanges(tid int64, ranges []*ranger.Range) []kv.KeyRange {
+	krs := make([]kv.KeyRange, 0, len(ranges))
+	for _, ran := range ranges {
+		low, high := ran.LowVal[0].GetInt64(), ran.HighVal[0].GetInt64()
+		if ran.LowExclude {
+			if low == math.MaxInt64 {
+				continue
+			}
+			low++
+		}
+		if ran.HighExclude {
+			if high == math.MinInt64 {
+				continue
+			}
+			high--
+		}
+		if low > high {
+			continue
+		}
+		startKey := tablecodec.EncodeRowKeyWithHandle(tid, low)
+		endKey := tablecodec.EncodeRowKeyWithHandle(tid, high)
+		krs = append(krs, kv.KeyRange{StartKey: startKey, EndKey: endKey.PrefixNext()})
+	}
+	return krs
+}
+
+// indexRangesToKVRanges converts the ranges of the index values to the ranges of the index keys.
+func indexRangesToKVRanges(sc *stmtctx.StatementContext, tid, idxID int64, ranges []*ranger.Range) ([]kv.KeyRange, error) {
+	krs := make([]kv.KeyRange, 0, len(ranges))
+	for _, ran := range ranges {
+		low, err := codec.EncodeKey(sc, nil, ran.LowVal...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if ran.LowExclude {
+			low = []byte(kv.Key(low).PrefixNext())
+		}
+		high, err := codec.EncodeKey(sc, nil, ran.HighVal...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !ran.HighExclude {
+			high = []byte(kv.Key(high).PrefixNext())
+		}
+		startKey := tablecodec.EncodeIndexSeekKey(tid, idxID, low)
+		endKey := tablecodec.EncodeIndexSeekKey(tid, idxID, high)
+		krs = append(krs, kv.KeyRange{StartKey: startKey, EndKey: endKey})
+	}
+	return krs, nil
+}
+
+// TableReaderExecutor reads the rows of a table from the transaction in the ranges of the handles.
+type TableReaderExecutor struct {
+	baseExecutor
+
+	table   table.Table
+	columns []*table.Column
+	ranges  []*ranger.Range
+
+	scanner *kvRangeScanner
+}
+
+func newTableReaderExecutor(b baseExecutor, tblInfo *model.TableInfo, columns []*model.ColumnInfo, ranges []*ranger.Range) (*TableReaderExecutor, error) {
+	tbl, err := tables.TableFromMeta(nil, tblInfo)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	e := &TableReaderExecutor{
+		baseExecutor: b,
+		table:        tbl,
+		columns:      make([]*table.Column, 0, len(columns)),
+		ranges:       ranges,
+	}
+	for _, col := range columns {
+		e.columns = append(e.columns, table.ToColumn(col))
+	}
+	return e, nil
+}
+
+// Open implements the Executor Open interface.
+func (e *TableReaderExecutor) Open(goCtx goctx.Context) error {
+	txn, err := e.ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.scanner = &kvRangeScanner{txn: txn, ranges: tableRangesToKVRanges(e.table.Meta().ID, e.ranges)}
+	return nil
+}
+
+// Next implements the Executor Next interface.
+func (e *TableReaderExecutor) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	return errors.Trace(fillRecordBatch(goCtx, req, e.nextRow))
+}
+
+// nextRow returns the next row, nil row means there is no more to return.
+func (e *TableReaderExecutor) nextRow(goCtx goctx.Context) ([]types.Datum, error) {
+	ok, err := e.scanner.next(goCtx)
+	if !ok || err != nil {
+		return nil, errors.Trace(err)
+	}
+	handle, err := tablecodec.DecodeRowKey(e.scanner.it.Key())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	row, _, err := tables.DecodeRawRowData(e.ctx, e.table.Meta(), handle, e.columns, e.scanner.it.Value())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = e.scanner.advance(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return row, nil
+}
+
+// Close implements the Executor Close interface.
+func (e *TableReaderExecutor) Close() error {
+	if e.scanner != nil {
+		e.scanner.close()
+		e.scanner = nil
+	}
+	return nil
+}
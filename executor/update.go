@@ -0,0 +1,110 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/update.go
+//
+
+package executor
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/expression"
+	"fedb/kv"
+	plannercore "fedb/planner/core"
+	"fedb/table"
+	"fedb/util/chunk"
+)
+
+// UpdateExec represents a new update executor.
+type UpdateExec struct {
+	baseExecutor
+
+	OrderedList []*expression.Assignment
+
+	tblID2table    map[int64]table.Table
+	tblColPosInfos []plannercore.TblColPosInfo
+	// assignFlag marks the columns of the rows of the child executor which are assigned.
+	assignFlag []bool
+	// updatedRowKeys is a map for unique (Table, handle) pair, a row joined many times is
+	// updated only once.
+	updatedRowKeys map[int64]map[int64]struct{}
+}
+
+// Next implements the Executor Next interface. All the rows are updated at once.
+func (e *UpdateExec) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	rows, err := fetchAllRows(goCtx, e.children[0])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.updatedRowKeys = make(map[int64]map[int64]struct{}, len(e.tblColPosInfos))
+	for _, row := range rows {
+		newRow, err := e.composeNewRow(row)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = e.exec(row, newRow); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// composeNewRow evaluates the assignments in order, an assignment sees the values assigned
+// before it.
+func (e *UpdateExec) composeNewRow(oldRow []types.Datum) ([]types.Datum, error) {
+	newRow := make([]types.Datum, len(oldRow))
+	copy(newRow, oldRow)
+	fields := e.children[0].retTypes()
+	for _, assign := range e.OrderedList {
+		val, err := assign.Expr.Eval(datumsToRow(fields, newRow))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		newRow[assign.Col.Index] = val
+	}
+	return newRow, nil
+}
+
+func (e *UpdateExec) exec(oldRow, newRow []types.Datum) error {
+	sc := e.ctx.GetSessionVars().StmtCtx
+	for _, info := range e.tblColPosInfos {
+		// The row of an outer join may not match any row of the table.
+		if oldRow[info.HandleOrdinal].IsNull() {
+			continue
+		}
+		handle := oldRow[info.HandleOrdinal].GetInt64()
+		if e.updatedRowKeys[info.TblID] == nil {
+			e.updatedRowKeys[info.TblID] = make(map[int64]struct{})
+		}
+		if _, ok := e.updatedRowKeys[info.TblID][handle]; ok {
+			continue
+		}
+		e.updatedRowKeys[info.TblID][handle] = struct{}{}
+
+		tbl := e.tblID2table[info.TblID]
+		oldData, newData := oldRow[info.Start:info.End], newRow[info.Start:info.End]
+		_, err := updateRecord(e.ctx, handle, oldData, newData, e.assignFlag[info.Start:info.End], tbl)
+		if err != nil {
+			if sc.DupKeyAsWarning && kv.ErrKeyExists.Equal(err) {
+				sc.AppendWarning(err)
+				continue
+			}
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/write.go
+//
+
+package executor
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/kv"
+	"fedb/sessionctx"
+	"fedb/table"
+	"fedb/table/tables"
+	"fedb/util/chunk"
+)
+
+// updateRecord updates the row of handle h from oldData to newData, the columns assigned by the
+// statement are marked by assignFlag. The values of newData are cast to the types of the columns.
+// It returns whether the row is changed, an unchanged row isn't written or counted as affected.
+func updateRecord(ctx sessionctx.Context, h int64, oldData, newData []types.Datum, assignFlag []bool, t table.Table) (bool, error) {
+	sc := ctx.GetSessionVars().StmtCtx
+	cols := t.Cols()
+	touched := make([]bool, len(cols))
+	changed, handleChanged := false, false
+	newHandle := h
+	for i, col := range cols {
+		if !assignFlag[i] {
+			continue
+		}
+		v, err := table.CastValue(ctx, newData[i], col.ToInfo())
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if newData[i], err = col.HandleBadNull(v, sc); err != nil {
+			return false, errors.Trace(err)
+		}
+		cmp, err := newData[i].CompareDatum(sc, &oldData[i])
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if cmp != 0 {
+			changed = true
+			touched[i] = true
+			if col.IsPKHandleColumn(t.Meta()) {
+				handleChanged = true
+				newHandle = newData[i].GetInt64()
+			}
+			// The IDs allocated later are greater than the updated auto_increment ID.
+			if mysql.HasAutoIncrementFlag(col.Flag) && !newData[i].IsNull() {
+				id, err := newData[i].ToInt64(sc)
+				if err != nil {
+					return false, errors.Trace(err)
+				}
+				if err = t.RebaseAutoID(id); err != nil {
+					return false, errors.Trace(err)
+				}
+			}
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	// The columns of ON UPDATE CURRENT_TIMESTAMP are refreshed when the row is changed.
+	for i, col := range cols {
+		if assignFlag[i] || !mysql.HasOnUpdateNowFlag(col.Flag) {
+			continue
+		}
+		v, err := expression.GetTimeValue(ctx, strings.ToUpper(ast.CurrentTimestamp), col.Tp, col.Decimal)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		newData[i] = v
+		touched[i] = true
+	}
+
+	if handleChanged {
+		if err := tables.CheckHandleExists(ctx, t, newHandle); err != nil {
+			return false, errors.Trace(err)
+		}
+		if err := t.RemoveRecord(ctx, h, oldData); err != nil {
+			return false, errors.Trace(err)
+		}
+		if _, err := t.AddRecord(ctx, newData); err != nil {
+			// The row is added back, so that the statement can go on if the duplicate
+			// entry is ignored.
+			if _, err1 := t.AddRecord(ctx, oldData); err1 != nil {
+				return false, errors.Trace(err1)
+			}
+			return false, errors.Trace(err)
+		}
+	} else if err := t.UpdateRecord(ctx, h, oldData, newData, touched); err != nil {
+		return false, errors.Trace(err)
+	}
+	sc.AddAffectedRows(1)
+	return true, nil
+}
+
+// findDuplicate returns the handle of the row which has the same primary key or unique key values
+// as the new row r, the error is the duplicate entry error of the key if there is such a row.
+func findDuplicate(ctx sessionctx.Context, t table.Table, r []types.Datum) (int64, error) {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	// The handle of a new row is allocated later if it isn't the primary key, the allocated
+	// handles are positive, so no index entry of the other rows has the handle 0.
+	var h int64
+	for _, col := range t.Cols() {
+		if col.IsPKHandleColumn(t.Meta()) {
+			h = r[col.Offset].GetInt64()
+			if err = tables.CheckHandleExists(ctx, t, h); err != nil {
+				return h, errors.Trace(err)
+			}
+			break
+		}
+	}
+	sc := ctx.GetSessionVars().StmtCtx
+	for _, idx := range t.WritableIndices() {
+		if !idx.Meta().Unique {
+			continue
+		}
+		vals, err := idx.FetchValues(r, nil)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		// The rows with NULL values never conflict on a unique index.
+		hasNull := false
+		for _, v := range vals {
+			hasNull = hasNull || v.IsNull()
+		}
+		if hasNull {
+			continue
+		}
+		_, dupHandle, err := idx.Exist(sc, txn, vals, h)
+		if kv.ErrKeyExists.Equal(err) {
+			entryKey, err1 := tables.GenIndexKeyStr(vals)
+			if err1 != nil {
+				return 0, errors.Trace(err1)
+			}
+			return dupHandle, kv.ErrKeyExists.FastGen("Duplicate entry '%s' for key '%s'", entryKey, idx.Meta().Name)
+		}
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+	return 0, nil
+}
+
+// datumsToRow converts the datums of the types into a row that the expressions are evaluated on.
+func datumsToRow(fields []*types.FieldType, datums []types.Datum) chunk.Row {
+	chk := chunk.NewChunkWithCapacity(fields, 1)
+	chk.AppendDatumRow(datums)
+	return chk.GetRow(0)
+}
@@ -0,0 +1,104 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/aggregation/aggregation.go
+//
+
+package aggregation
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/util/chunk"
+)
+
+// Aggregation stands for aggregate functions.
+type Aggregation interface {
+	// Update during executing.
+	Update(evalCtx *AggEvaluateContext, sc *stmtctx.StatementContext, row chunk.Row) error
+
+	// GetResult will be called when all data have been processed.
+	GetResult(evalCtx *AggEvaluateContext) types.Datum
+
+	// CreateContext creates a new AggEvaluateContext for the aggregation function.
+	CreateContext(sc *stmtctx.StatementContext) *AggEvaluateContext
+
+	// ResetContext resets the content of the evaluate context.
+	ResetContext(sc *stmtctx.StatementContext, evalCtx *AggEvaluateContext)
+
+	// GetArgs gets the args of the aggregate function.
+	GetArgs() []expression.Expression
+}
+
+// AggEvaluateContext is used to store intermediate result when calculating aggregate functions.
+type AggEvaluateContext struct {
+	DistinctChecker *distinctChecker
+	Count           int64
+	Value           types.Datum
+	GotFirstRow     bool // It will check if the agg has met the first row key.
+}
+
+type aggFunction struct {
+	*AggFuncDesc
+}
+
+// CreateContext implements Aggregation interface.
+func (af *aggFunction) CreateContext(sc *stmtctx.StatementContext) *AggEvaluateContext {
+	evalCtx := &AggEvaluateContext{}
+	if af.HasDistinct {
+		evalCtx.DistinctChecker = createDistinctChecker(sc)
+	}
+	return evalCtx
+}
+
+// ResetContext implements Aggregation interface.
+func (af *aggFunction) ResetContext(sc *stmtctx.StatementContext, evalCtx *AggEvaluateContext) {
+	if af.HasDistinct {
+		evalCtx.DistinctChecker = createDistinctChecker(sc)
+	}
+	evalCtx.Value.SetNull()
+}
+
+// GetArgs implements Aggregation interface.
+func (af *aggFunction) GetArgs() []expression.Expression {
+	return af.Args
+}
+
+func (af *aggFunction) updateSum(sc *stmtctx.StatementContext, evalCtx *AggEvaluateContext, row chunk.Row) error {
+	a := af.Args[0]
+	value, err := a.Eval(row)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if value.IsNull() {
+		return nil
+	}
+	if af.HasDistinct {
+		d, err1 := evalCtx.DistinctChecker.Check([]types.Datum{value})
+		if err1 != nil {
+			return errors.Trace(err1)
+		}
+		if !d {
+			return nil
+		}
+	}
+	evalCtx.Value, err = calculateSum(sc, evalCtx.Value, value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	evalCtx.Count++
+	return nil
+}
@@ -0,0 +1,69 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/aggregation/avg.go
+//
+
+package aggregation
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/util/chunk"
+)
+
+type avgFunction struct {
+	aggFunction
+}
+
+// ResetContext implements Aggregation interface.
+func (af *avgFunction) ResetContext(sc *stmtctx.StatementContext, evalCtx *AggEvaluateContext) {
+	if af.HasDistinct {
+		evalCtx.DistinctChecker = createDistinctChecker(sc)
+	}
+	evalCtx.Value.SetNull()
+	evalCtx.Count = 0
+}
+
+// Update implements Aggregation interface.
+func (af *avgFunction) Update(evalCtx *AggEvaluateContext, sc *stmtctx.StatementContext, row chunk.Row) error {
+	return errors.Trace(af.updateSum(sc, evalCtx, row))
+}
+
+// GetResult implements Aggregation interface.
+func (af *avgFunction) GetResult(evalCtx *AggEvaluateContext) (d types.Datum) {
+	switch evalCtx.Value.Kind() {
+	case types.KindFloat64:
+		sum := evalCtx.Value.GetFloat64()
+		d.SetFloat64(sum / float64(evalCtx.Count))
+		return
+	case types.KindMysqlDecimal:
+		x := evalCtx.Value.GetMysqlDecimal()
+		y := types.NewDecFromInt(evalCtx.Count)
+		to := new(types.MyDecimal)
+		err := types.DecimalDiv(x, y, to, types.DivFracIncr)
+		terror.Log(errors.Trace(err))
+		frac := af.RetTp.Decimal
+		if frac == -1 || frac > mysql.MaxDecimalScale {
+			frac = mysql.MaxDecimalScale
+		}
+		err = to.Round(to, frac, types.ModeHalfEven)
+		terror.Log(errors.Trace(err))
+		d.SetMysqlDecimal(to)
+	}
+	return
+}
@@ -0,0 +1,213 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2018 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/aggregation/descriptor.go
+//
+
+package aggregation
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/sessionctx"
+)
+
+// AggFuncDesc describes an aggregation function signature, only used in planner.
+type AggFuncDesc struct {
+	// Name represents the aggregation function name.
+	Name string
+	// Args represents the arguments of the aggregation function.
+	Args []expression.Expression
+	// RetTp represents the return type of the aggregation function.
+	RetTp *types.FieldType
+	// HasDistinct represents whether the aggregation function contains distinct attribute.
+	HasDistinct bool
+}
+
+// NewAggFuncDesc creates an aggregation function signature descriptor.
+func NewAggFuncDesc(ctx sessionctx.Context, name string, args []expression.Expression, hasDistinct bool) (*AggFuncDesc, error) {
+	a := &AggFuncDesc{
+		Name:        strings.ToLower(name),
+		Args:        args,
+		HasDistinct: hasDistinct,
+	}
+	if err := a.typeInfer(ctx); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return a, nil
+}
+
+// IsSupported checks whether the aggregation function can be evaluated.
+func IsSupported(name string) bool {
+	switch strings.ToLower(name) {
+	case ast.AggFuncCount, ast.AggFuncSum, ast.AggFuncAvg, ast.AggFuncMax, ast.AggFuncMin, ast.AggFuncFirstRow:
+		return true
+	}
+	return false
+}
+
+// Equal checks whether two aggregation function signatures are equal.
+func (a *AggFuncDesc) Equal(other *AggFuncDesc) bool {
+	if a.Name != other.Name || a.HasDistinct != other.HasDistinct || len(a.Args) != len(other.Args) {
+		return false
+	}
+	for i := range a.Args {
+		if !a.Args[i].Equal(other.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Clone copies an aggregation function signature totally.
+func (a *AggFuncDesc) Clone() *AggFuncDesc {
+	clone := *a
+	newTp := *a.RetTp
+	clone.RetTp = &newTp
+	clone.Args = make([]expression.Expression, len(a.Args))
+	for i := range a.Args {
+		clone.Args[i] = a.Args[i].Clone()
+	}
+	return &clone
+}
+
+// String implements the fmt.Stringer interface.
+func (a *AggFuncDesc) String() string {
+	buffer := bytes.NewBufferString(a.Name)
+	buffer.WriteString("(")
+	for i, arg := range a.Args {
+		buffer.WriteString(arg.String())
+		if i+1 != len(a.Args) {
+			buffer.WriteString(", ")
+		}
+	}
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+// typeInfer infers the arguments and return types of an aggregation function.
+func (a *AggFuncDesc) typeInfer(ctx sessionctx.Context) error {
+	switch a.Name {
+	case ast.AggFuncCount:
+		a.typeInfer4Count()
+	case ast.AggFuncSum:
+		a.typeInfer4Sum()
+	case ast.AggFuncAvg:
+		a.typeInfer4Avg()
+	case ast.AggFuncMax, ast.AggFuncMin, ast.AggFuncFirstRow:
+		return errors.Trace(a.typeInfer4MaxMin(ctx))
+	default:
+		return errors.Errorf("unsupported agg function: %s", a.Name)
+	}
+	return nil
+}
+
+// GetAggFunc gets an evaluator according to the aggregation function signature.
+func (a *AggFuncDesc) GetAggFunc() Aggregation {
+	aggFunc := aggFunction{AggFuncDesc: a}
+	switch a.Name {
+	case ast.AggFuncSum:
+		return &sumFunction{aggFunction: aggFunc}
+	case ast.AggFuncCount:
+		return &countFunction{aggFunction: aggFunc}
+	case ast.AggFuncAvg:
+		return &avgFunction{aggFunction: aggFunc}
+	case ast.AggFuncMax:
+		return &maxMinFunction{aggFunction: aggFunc, isMax: true}
+	case ast.AggFuncMin:
+		return &maxMinFunction{aggFunction: aggFunc, isMax: false}
+	case ast.AggFuncFirstRow:
+		return &firstRowFunction{aggFunction: aggFunc}
+	default:
+		panic("unsupported agg function")
+	}
+}
+
+func (a *AggFuncDesc) typeInfer4Count() {
+	a.RetTp = types.NewFieldType(mysql.TypeLonglong)
+	a.RetTp.Flen = 21
+	types.SetBinChsClnFlag(a.RetTp)
+}
+
+// typeInfer4Sum should returns a "decimal", otherwise it returns a "double".
+// Because child returns integer or decimal type.
+func (a *AggFuncDesc) typeInfer4Sum() {
+	switch a.Args[0].GetType().Tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong:
+		a.RetTp = types.NewFieldType(mysql.TypeNewDecimal)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxDecimalWidth, 0
+	case mysql.TypeNewDecimal:
+		a.RetTp = types.NewFieldType(mysql.TypeNewDecimal)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxDecimalWidth, a.Args[0].GetType().Decimal
+		if a.RetTp.Decimal < 0 || a.RetTp.Decimal > mysql.MaxDecimalScale {
+			a.RetTp.Decimal = mysql.MaxDecimalScale
+		}
+	case mysql.TypeDouble, mysql.TypeFloat:
+		a.RetTp = types.NewFieldType(mysql.TypeDouble)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxRealWidth, a.Args[0].GetType().Decimal
+	default:
+		a.RetTp = types.NewFieldType(mysql.TypeDouble)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxRealWidth, types.UnspecifiedLength
+	}
+	types.SetBinChsClnFlag(a.RetTp)
+}
+
+// typeInfer4Avg should returns a "decimal", otherwise it returns a "double".
+// Because child returns integer or decimal type.
+func (a *AggFuncDesc) typeInfer4Avg() {
+	switch a.Args[0].GetType().Tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong, mysql.TypeNewDecimal:
+		a.RetTp = types.NewFieldType(mysql.TypeNewDecimal)
+		a.RetTp.Decimal = mysql.MaxDecimalScale
+		if dec := a.Args[0].GetType().Decimal; dec >= 0 && dec+types.DivFracIncr < mysql.MaxDecimalScale {
+			a.RetTp.Decimal = dec + types.DivFracIncr
+		}
+		a.RetTp.Flen = mysql.MaxDecimalWidth
+	case mysql.TypeDouble, mysql.TypeFloat:
+		a.RetTp = types.NewFieldType(mysql.TypeDouble)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxRealWidth, a.Args[0].GetType().Decimal
+	default:
+		a.RetTp = types.NewFieldType(mysql.TypeDouble)
+		a.RetTp.Flen, a.RetTp.Decimal = mysql.MaxRealWidth, types.UnspecifiedLength
+	}
+	types.SetBinChsClnFlag(a.RetTp)
+}
+
+func (a *AggFuncDesc) typeInfer4MaxMin(ctx sessionctx.Context) error {
+	_, argIsScalaFunc := a.Args[0].(*expression.ScalarFunction)
+	if argIsScalaFunc && a.Args[0].GetType().Tp == mysql.TypeFloat {
+		// For scalar function, the result of "float32" is set to the "float64"
+		// field in the "Datum". If we do not wrap a cast-as-double function on a.Args[0],
+		// error would happen when extracting the evaluation of a.Args[0] to a ProjectionExec.
+		tp := types.NewFieldType(mysql.TypeDouble)
+		tp.Flen, tp.Decimal = mysql.MaxRealWidth, types.UnspecifiedLength
+		types.SetBinChsClnFlag(tp)
+		arg, err := expression.BuildCastFunction(ctx, a.Args[0], tp)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		a.Args[0] = arg
+	}
+	a.RetTp = a.Args[0].GetType()
+	if a.RetTp.Tp == mysql.TypeEnum || a.RetTp.Tp == mysql.TypeSet {
+		a.RetTp = &types.FieldType{Tp: mysql.TypeString, Flen: mysql.MaxFieldCharLength}
+	}
+	return nil
+}
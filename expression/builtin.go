@@ -0,0 +1,253 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin.go
+//
+
+package expression
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// baseBuiltinFunc will be contained in every struct that implement builtinFunc interface.
+type baseBuiltinFunc struct {
+	args []Expression
+	ctx  sessionctx.Context
+	tp   *types.FieldType
+
+	// bufs holds the values of the arguments when the function is vectorized.
+	bufs []*chunk.Column
+}
+
+func newBaseBuiltinFunc(ctx sessionctx.Context, args []Expression, tp *types.FieldType) baseBuiltinFunc {
+	return baseBuiltinFunc{
+		args: args,
+		ctx:  ctx,
+		tp:   tp,
+	}
+}
+
+func (b *baseBuiltinFunc) getArgs() []Expression {
+	return b.args
+}
+
+func (b *baseBuiltinFunc) getCtx() sessionctx.Context {
+	return b.ctx
+}
+
+func (b *baseBuiltinFunc) getRetTp() *types.FieldType {
+	return b.tp
+}
+
+// evalArgs evaluates all the arguments, hasNull is true if any of them is NULL.
+func (b *baseBuiltinFunc) evalArgs(row chunk.Row) (args []types.Datum, hasNull bool, err error) {
+	args = make([]types.Datum, 0, len(b.args))
+	for _, arg := range b.args {
+		d, err := arg.Eval(row)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		hasNull = hasNull || d.IsNull()
+		args = append(args, d)
+	}
+	return args, hasNull, nil
+}
+
+// vecEvalArgs evaluates all the arguments on the input chunk, the columns holding the
+// values are reused by the following calls.
+func (b *baseBuiltinFunc) vecEvalArgs(input *chunk.Chunk) ([]*chunk.Column, error) {
+	if b.bufs == nil {
+		b.bufs = make([]*chunk.Column, 0, len(b.args))
+		for _, arg := range b.args {
+			b.bufs = append(b.bufs, chunk.NewColumn(arg.GetType(), input.NumRows()))
+		}
+	}
+	for i, arg := range b.args {
+		if err := arg.VecEval(input, b.bufs[i]); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return b.bufs, nil
+}
+
+// builtinFunc stands for a particular function signature.
+type builtinFunc interface {
+	// eval evaluates the function through a row.
+	eval(row chunk.Row) (types.Datum, error)
+	// getArgs returns the arguments expressions.
+	getArgs() []Expression
+	// getCtx returns the context of the function.
+	getCtx() sessionctx.Context
+	// getRetTp returns the inferred return type of the function.
+	getRetTp() *types.FieldType
+}
+
+// vecBuiltinFunc is implemented by the function signatures which can be evaluated on whole
+// columns, the values are written to a column of int64 or float64 layout.
+type vecBuiltinFunc interface {
+	// vectorized returns whether the function can be evaluated by vecEval with the types of its arguments.
+	vectorized() bool
+	// vecEval evaluates the function on all the rows of the input chunk.
+	vecEval(input *chunk.Chunk, result *chunk.Column) error
+}
+
+// functionClass is the interface for a function which may contains multiple functions.
+type functionClass interface {
+	// getFunction gets a function signature by the types and the counts of given arguments.
+	getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error)
+}
+
+// baseFunctionClass will be contained in every struct that implement functionClass interface.
+type baseFunctionClass struct {
+	funcName string
+	minArgs  int
+	maxArgs  int
+}
+
+func (b *baseFunctionClass) verifyArgs(args []Expression) error {
+	l := len(args)
+	if l < b.minArgs || (b.maxArgs != -1 && l > b.maxArgs) {
+		return ErrIncorrectParameterCount.GenWithStackByArgs(b.funcName)
+	}
+	return nil
+}
+
+// newRetType creates the return type of a function by its eval type.
+func newRetType(et types.EvalType) *types.FieldType {
+	var ft *types.FieldType
+	switch et {
+	case types.ETInt:
+		ft = types.NewFieldType(mysql.TypeLonglong)
+	case types.ETReal:
+		ft = types.NewFieldType(mysql.TypeDouble)
+	case types.ETDecimal:
+		ft = types.NewFieldType(mysql.TypeNewDecimal)
+	case types.ETDatetime:
+		ft = types.NewFieldType(mysql.TypeDatetime)
+	case types.ETTimestamp:
+		ft = types.NewFieldType(mysql.TypeTimestamp)
+	case types.ETDuration:
+		ft = types.NewFieldType(mysql.TypeDuration)
+	case types.ETJson:
+		ft = types.NewFieldType(mysql.TypeJSON)
+	default:
+		ft = types.NewFieldType(mysql.TypeVarString)
+	}
+	ft.Charset, ft.Collate = types.DefaultCharsetForType(ft.Tp)
+	if et != types.ETString {
+		ft.Flag |= mysql.BinaryFlag
+	}
+	return ft
+}
+
+// funcs holds all registered builtin functions. When new function is added,
+// check unFoldableFunctions to see if it should be appended there.
+var funcs = map[string]functionClass{
+	// common functions
+	ast.Coalesce: &coalesceFunctionClass{baseFunctionClass{ast.Coalesce, 1, -1}},
+
+	// math functions
+	ast.Abs:     &absFunctionClass{baseFunctionClass{ast.Abs, 1, 1}},
+	ast.Ceil:    &ceilFunctionClass{baseFunctionClass{ast.Ceil, 1, 1}},
+	ast.Ceiling: &ceilFunctionClass{baseFunctionClass{ast.Ceiling, 1, 1}},
+	ast.Floor:   &floorFunctionClass{baseFunctionClass{ast.Floor, 1, 1}},
+	ast.Round:   &roundFunctionClass{baseFunctionClass{ast.Round, 1, 2}},
+
+	// time functions
+	ast.Curdate:          &currentDateFunctionClass{baseFunctionClass{ast.Curdate, 0, 0}},
+	ast.CurrentDate:      &currentDateFunctionClass{baseFunctionClass{ast.CurrentDate, 0, 0}},
+	ast.CurrentTimestamp: &nowFunctionClass{baseFunctionClass{ast.CurrentTimestamp, 0, 1}},
+	ast.Now:              &nowFunctionClass{baseFunctionClass{ast.Now, 0, 1}},
+
+	// string functions
+	ast.CharLength:      &charLengthFunctionClass{baseFunctionClass{ast.CharLength, 1, 1}},
+	ast.CharacterLength: &charLengthFunctionClass{baseFunctionClass{ast.CharacterLength, 1, 1}},
+	ast.Concat:          &concatFunctionClass{baseFunctionClass{ast.Concat, 1, -1}},
+	ast.ConcatWS:        &concatWSFunctionClass{baseFunctionClass{ast.ConcatWS, 2, -1}},
+	ast.Length:          &lengthFunctionClass{baseFunctionClass{ast.Length, 1, 1}},
+	ast.Lower:           &lowerFunctionClass{baseFunctionClass{ast.Lower, 1, 1}},
+	ast.Lcase:           &lowerFunctionClass{baseFunctionClass{ast.Lcase, 1, 1}},
+	ast.Replace:         &replaceFunctionClass{baseFunctionClass{ast.Replace, 3, 3}},
+	ast.Substring:       &substringFunctionClass{baseFunctionClass{ast.Substring, 2, 3}},
+	ast.Substr:          &substringFunctionClass{baseFunctionClass{ast.Substr, 2, 3}},
+	ast.Trim:            &trimFunctionClass{baseFunctionClass{ast.Trim, 1, 3}},
+	ast.Upper:           &upperFunctionClass{baseFunctionClass{ast.Upper, 1, 1}},
+	ast.Ucase:           &upperFunctionClass{baseFunctionClass{ast.Ucase, 1, 1}},
+
+	// information functions
+	ast.ConnectionID: &connectionIDFunctionClass{baseFunctionClass{ast.ConnectionID, 0, 0}},
+	ast.CurrentUser:  &currentUserFunctionClass{baseFunctionClass{ast.CurrentUser, 0, 0}},
+	currentRole:      &currentRoleFunctionClass{baseFunctionClass{currentRole, 0, 0}},
+	ast.Database:     &databaseFunctionClass{baseFunctionClass{ast.Database, 0, 0}},
+	ast.LastInsertId: &lastInsertIDFunctionClass{baseFunctionClass{ast.LastInsertId, 0, 1}},
+	// This function is a synonym for DATABASE().
+	// See http://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_schema
+	ast.Schema:  &databaseFunctionClass{baseFunctionClass{ast.Schema, 0, 0}},
+	ast.Version: &versionFunctionClass{baseFunctionClass{ast.Version, 0, 0}},
+
+	// control functions
+	ast.Case:   &caseWhenFunctionClass{baseFunctionClass{ast.Case, 1, -1}},
+	ast.If:     &ifFunctionClass{baseFunctionClass{ast.If, 3, 3}},
+	ast.Ifnull: &ifNullFunctionClass{baseFunctionClass{ast.Ifnull, 2, 2}},
+	ast.Nullif: &nullIfFunctionClass{baseFunctionClass{ast.Nullif, 2, 2}},
+
+	// op functions
+	ast.LogicAnd:   &logicAndFunctionClass{baseFunctionClass{ast.LogicAnd, 2, 2}},
+	ast.LogicOr:    &logicOrFunctionClass{baseFunctionClass{ast.LogicOr, 2, 2}},
+	ast.LogicXor:   &logicXorFunctionClass{baseFunctionClass{ast.LogicXor, 2, 2}},
+	ast.GE:         &compareFunctionClass{baseFunctionClass{ast.GE, 2, 2}},
+	ast.LE:         &compareFunctionClass{baseFunctionClass{ast.LE, 2, 2}},
+	ast.EQ:         &compareFunctionClass{baseFunctionClass{ast.EQ, 2, 2}},
+	ast.NE:         &compareFunctionClass{baseFunctionClass{ast.NE, 2, 2}},
+	ast.LT:         &compareFunctionClass{baseFunctionClass{ast.LT, 2, 2}},
+	ast.GT:         &compareFunctionClass{baseFunctionClass{ast.GT, 2, 2}},
+	ast.NullEQ:     &compareFunctionClass{baseFunctionClass{ast.NullEQ, 2, 2}},
+	ast.Plus:       &arithmeticFunctionClass{baseFunctionClass{ast.Plus, 2, 2}},
+	ast.Minus:      &arithmeticFunctionClass{baseFunctionClass{ast.Minus, 2, 2}},
+	ast.Mod:        &arithmeticFunctionClass{baseFunctionClass{ast.Mod, 2, 2}},
+	ast.Div:        &arithmeticDivideFunctionClass{baseFunctionClass{ast.Div, 2, 2}},
+	ast.Mul:        &arithmeticFunctionClass{baseFunctionClass{ast.Mul, 2, 2}},
+	ast.IntDiv:     &arithmeticIntDivideFunctionClass{baseFunctionClass{ast.IntDiv, 2, 2}},
+	ast.BitNeg:     &bitFunctionClass{baseFunctionClass{ast.BitNeg, 1, 1}},
+	ast.And:        &bitFunctionClass{baseFunctionClass{ast.And, 2, 2}},
+	ast.LeftShift:  &bitFunctionClass{baseFunctionClass{ast.LeftShift, 2, 2}},
+	ast.RightShift: &bitFunctionClass{baseFunctionClass{ast.RightShift, 2, 2}},
+	ast.UnaryNot:   &unaryNotFunctionClass{baseFunctionClass{ast.UnaryNot, 1, 1}},
+	ast.Or:         &bitFunctionClass{baseFunctionClass{ast.Or, 2, 2}},
+	ast.Xor:        &bitFunctionClass{baseFunctionClass{ast.Xor, 2, 2}},
+	ast.UnaryMinus: &unaryMinusFunctionClass{baseFunctionClass{ast.UnaryMinus, 1, 1}},
+	ast.In:         &inFunctionClass{baseFunctionClass{ast.In, 2, -1}},
+	ast.IsTruth:    &isTrueOrFalseFunctionClass{baseFunctionClass{ast.IsTruth, 1, 1}},
+	ast.IsFalsity:  &isTrueOrFalseFunctionClass{baseFunctionClass{ast.IsFalsity, 1, 1}},
+	ast.Like:       &likeFunctionClass{baseFunctionClass{ast.Like, 3, 3}},
+	ast.IsNull:     &isNullFunctionClass{baseFunctionClass{ast.IsNull, 1, 1}},
+
+	// other functions
+	ast.Cast: &castFunctionClass{baseFunctionClass{ast.Cast, 1, 1}},
+}
+
+// unFoldableFunctions stores functions which can not be folded during constant folding stage.
+var unFoldableFunctions = map[string]bool{
+	ast.Now:              true,
+	ast.CurrentTimestamp: true,
+	ast.Curdate:          true,
+	ast.CurrentDate:      true,
+	ast.LastInsertId:     true,
+}
@@ -0,0 +1,600 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_arithmetic.go
+//
+
+package expression
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// numericContextResultType returns the eval type of the argument in a numeric context,
+// a string is converted to a double, and a temporal value to a number.
+func numericContextResultType(ft *types.FieldType) types.EvalType {
+	if types.IsTypeTemporal(ft.Tp) {
+		if ft.Decimal > 0 {
+			return types.ETDecimal
+		}
+		return types.ETInt
+	}
+	switch et := ft.EvalType(); et {
+	case types.ETInt, types.ETDecimal, types.ETReal:
+		return et
+	}
+	return types.ETReal
+}
+
+// getArithmeticEvalType returns the eval type of the arithmetic on the two arguments.
+func getArithmeticEvalType(lhs, rhs *types.FieldType) types.EvalType {
+	lhsEvalType, rhsEvalType := numericContextResultType(lhs), numericContextResultType(rhs)
+	if lhsEvalType == types.ETReal || rhsEvalType == types.ETReal {
+		return types.ETReal
+	}
+	if lhsEvalType == types.ETDecimal || rhsEvalType == types.ETDecimal {
+		return types.ETDecimal
+	}
+	return types.ETInt
+}
+
+// setFlenDecimal4RealOrDecimal sets the decimal of the result by the arguments.
+func setFlenDecimal4RealOrDecimal(retTp, a, b *types.FieldType, isReal bool) {
+	if a.Decimal != types.UnspecifiedLength && b.Decimal != types.UnspecifiedLength {
+		retTp.Decimal = a.Decimal + b.Decimal
+		if !isReal && retTp.Decimal > mysql.MaxDecimalScale {
+			retTp.Decimal = mysql.MaxDecimalScale
+		}
+		return
+	}
+	retTp.Decimal = types.UnspecifiedLength
+}
+
+// evalIntArg returns the integer value of the datum, unsigned is true if it is an unsigned value.
+func evalIntArg(sc *stmtctx.StatementContext, d types.Datum) (val int64, unsigned bool, err error) {
+	if d.Kind() == types.KindUint64 {
+		return int64(d.GetUint64()), true, nil
+	}
+	val, err = d.ToInt64(sc)
+	return val, false, errors.Trace(err)
+}
+
+// newIntDatum creates the datum of an integer result.
+func newIntDatum(val int64, unsigned bool) types.Datum {
+	if unsigned {
+		return types.NewUintDatum(uint64(val))
+	}
+	return types.NewIntDatum(val)
+}
+
+type arithmeticFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *arithmeticFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	lhsTp, rhsTp := args[0].GetType(), args[1].GetType()
+	et := getArithmeticEvalType(lhsTp, rhsTp)
+	tp := newRetType(et)
+	switch et {
+	case types.ETInt:
+		if mysql.HasUnsignedFlag(lhsTp.Flag) || (c.funcName != ast.Mod && mysql.HasUnsignedFlag(rhsTp.Flag)) {
+			tp.Flag |= mysql.UnsignedFlag
+		}
+	case types.ETDecimal:
+		if c.funcName == ast.Mul {
+			setFlenDecimal4RealOrDecimal(tp, lhsTp, rhsTp, false)
+		} else if lhsTp.Decimal != types.UnspecifiedLength && rhsTp.Decimal != types.UnspecifiedLength {
+			tp.Decimal = mathMax(lhsTp.Decimal, rhsTp.Decimal)
+		}
+	}
+	sig := &builtinArithmeticSig{newBaseBuiltinFunc(ctx, args, tp), c.funcName, et}
+	return sig, nil
+}
+
+type builtinArithmeticSig struct {
+	baseBuiltinFunc
+
+	op string
+	et types.EvalType
+}
+
+func (b *builtinArithmeticSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	switch b.et {
+	case types.ETInt:
+		return b.evalInt(sc, args[0], args[1])
+	case types.ETDecimal:
+		return b.evalDecimal(sc, args[0], args[1])
+	}
+	return b.evalReal(sc, args[0], args[1])
+}
+
+// vectorized implements vecBuiltinFunc interface, +, - and * on signed integers or doubles are vectorized.
+func (b *builtinArithmeticSig) vectorized() bool {
+	if b.op == ast.Mod {
+		return false
+	}
+	lhsTp, rhsTp := b.args[0].GetType(), b.args[1].GetType()
+	switch b.et {
+	case types.ETInt:
+		return !mysql.HasUnsignedFlag(b.tp.Flag) && isSignedInt64Layout(lhsTp) && isSignedInt64Layout(rhsTp)
+	case types.ETReal:
+		return isFloat64Layout(lhsTp) && isFloat64Layout(rhsTp)
+	}
+	return false
+}
+
+// vecEval implements vecBuiltinFunc interface.
+func (b *builtinArithmeticSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if b.et == types.ETInt {
+		return errors.Trace(b.vecEvalInt(input.NumRows(), bufs[0], bufs[1], result))
+	}
+	return errors.Trace(b.vecEvalReal(input.NumRows(), bufs[0], bufs[1], result))
+}
+
+func (b *builtinArithmeticSig) vecEvalInt(n int, lhs, rhs, result *chunk.Column) error {
+	calc := types.MulInt64
+	switch b.op {
+	case ast.Plus:
+		calc = types.AddInt64
+	case ast.Minus:
+		calc = types.SubInt64
+	}
+	result.ResizeInt64(n, false)
+	a, c, res := lhs.Int64s(), rhs.Int64s(), result.Int64s()
+	var err error
+	for i := 0; i < n; i++ {
+		if lhs.IsNull(i) || rhs.IsNull(i) {
+			result.SetNull(i, true)
+			continue
+		}
+		if res[i], err = calc(a[i], c[i]); err != nil {
+			if types.ErrOverflow.Equal(err) {
+				err = b.overflowError()
+			}
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (b *builtinArithmeticSig) vecEvalReal(n int, lhs, rhs, result *chunk.Column) error {
+	result.ResizeFloat64(n, false)
+	a, c, res := lhs.Float64s(), rhs.Float64s(), result.Float64s()
+	for i := 0; i < n; i++ {
+		if lhs.IsNull(i) || rhs.IsNull(i) {
+			result.SetNull(i, true)
+			continue
+		}
+		switch b.op {
+		case ast.Plus:
+			res[i] = a[i] + c[i]
+		case ast.Minus:
+			res[i] = a[i] - c[i]
+		default:
+			res[i] = a[i] * c[i]
+		}
+		if math.IsInf(res[i], 0) || math.IsNaN(res[i]) {
+			return b.overflowError()
+		}
+	}
+	return nil
+}
+
+func (b *builtinArithmeticSig) evalInt(sc *stmtctx.StatementContext, lhs, rhs types.Datum) (types.Datum, error) {
+	a, isLHSUnsigned, err := evalIntArg(sc, lhs)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	c, isRHSUnsigned, err := evalIntArg(sc, rhs)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if b.op == ast.Mod {
+		return b.evalIntMod(a, c, isLHSUnsigned, isRHSUnsigned)
+	}
+	unsigned := mysql.HasUnsignedFlag(b.tp.Flag)
+	var (
+		res  int64
+		ures uint64
+	)
+	switch {
+	case isLHSUnsigned && isRHSUnsigned:
+		switch b.op {
+		case ast.Plus:
+			ures, err = types.AddUint64(uint64(a), uint64(c))
+		case ast.Minus:
+			ures, err = types.SubUint64(uint64(a), uint64(c))
+		default:
+			ures, err = types.MulUint64(uint64(a), uint64(c))
+		}
+		res = int64(ures)
+	case isLHSUnsigned:
+		switch b.op {
+		case ast.Plus:
+			ures, err = types.AddInteger(uint64(a), c)
+		case ast.Minus:
+			ures, err = types.SubUintWithInt(uint64(a), c)
+		default:
+			ures, err = types.MulInteger(uint64(a), c)
+		}
+		res = int64(ures)
+	case isRHSUnsigned:
+		switch b.op {
+		case ast.Plus:
+			ures, err = types.AddInteger(uint64(c), a)
+		case ast.Minus:
+			ures, err = types.SubIntWithUint(a, uint64(c))
+		default:
+			ures, err = types.MulInteger(uint64(c), a)
+		}
+		res = int64(ures)
+	case unsigned:
+		// Both values are signed, but the result is unsigned.
+		switch b.op {
+		case ast.Plus:
+			res, err = types.AddInt64(a, c)
+		case ast.Minus:
+			res, err = types.SubInt64(a, c)
+		default:
+			res, err = types.MulInt64(a, c)
+		}
+		if err == nil && res < 0 {
+			err = types.ErrOverflow.GenWithStackByArgs("BIGINT UNSIGNED", b.String())
+		}
+	default:
+		switch b.op {
+		case ast.Plus:
+			res, err = types.AddInt64(a, c)
+		case ast.Minus:
+			res, err = types.SubInt64(a, c)
+		default:
+			res, err = types.MulInt64(a, c)
+		}
+	}
+	if err != nil {
+		if types.ErrOverflow.Equal(err) {
+			err = b.overflowError()
+		}
+		return types.Datum{}, errors.Trace(err)
+	}
+	return newIntDatum(res, unsigned), nil
+}
+
+func (b *builtinArithmeticSig) evalIntMod(a, c int64, isLHSUnsigned, isRHSUnsigned bool) (types.Datum, error) {
+	if c == 0 {
+		return handleDivisionByZero(b.ctx)
+	}
+	// The sign of the result is the same as the dividend.
+	switch {
+	case isLHSUnsigned && isRHSUnsigned:
+		return types.NewUintDatum(uint64(a) % uint64(c)), nil
+	case isLHSUnsigned:
+		if c < 0 {
+			c = -c
+		}
+		return types.NewUintDatum(uint64(a) % uint64(c)), nil
+	case isRHSUnsigned:
+		if a < 0 {
+			return types.NewIntDatum(-int64(uint64(-a) % uint64(c))), nil
+		}
+		return types.NewIntDatum(int64(uint64(a) % uint64(c))), nil
+	}
+	if c == -1 {
+		return newIntDatum(0, mysql.HasUnsignedFlag(b.tp.Flag)), nil
+	}
+	return newIntDatum(a%c, mysql.HasUnsignedFlag(b.tp.Flag)), nil
+}
+
+func (b *builtinArithmeticSig) evalReal(sc *stmtctx.StatementContext, lhs, rhs types.Datum) (types.Datum, error) {
+	a, err := lhs.ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	c, err := rhs.ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	var res float64
+	switch b.op {
+	case ast.Plus:
+		res = a + c
+	case ast.Minus:
+		res = a - c
+	case ast.Mul:
+		res = a * c
+	case ast.Mod:
+		if c == 0 {
+			return handleDivisionByZero(b.ctx)
+		}
+		res = math.Mod(a, c)
+	}
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return types.Datum{}, b.overflowError()
+	}
+	return types.NewFloat64Datum(res), nil
+}
+
+func (b *builtinArithmeticSig) evalDecimal(sc *stmtctx.StatementContext, lhs, rhs types.Datum) (types.Datum, error) {
+	a, err := lhs.ToDecimal(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	c, err := rhs.ToDecimal(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	res := new(types.MyDecimal)
+	switch b.op {
+	case ast.Plus:
+		err = types.DecimalAdd(a, c, res)
+	case ast.Minus:
+		err = types.DecimalSub(a, c, res)
+	case ast.Mul:
+		err = types.DecimalMul(a, c, res)
+	case ast.Mod:
+		err = types.DecimalMod(a, c, res)
+		if err == types.ErrDivByZero {
+			return handleDivisionByZero(b.ctx)
+		}
+	}
+	if err == types.ErrOverflow {
+		return types.Datum{}, b.overflowError()
+	}
+	if err != nil && err != types.ErrTruncated {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewDecimalDatum(res), nil
+}
+
+func (b *builtinArithmeticSig) overflowError() error {
+	tp := "BIGINT"
+	switch b.et {
+	case types.ETReal:
+		tp = "DOUBLE"
+	case types.ETDecimal:
+		tp = "DECIMAL"
+	default:
+		if mysql.HasUnsignedFlag(b.tp.Flag) {
+			tp = "BIGINT UNSIGNED"
+		}
+	}
+	return ErrOverflow.GenWithStackByArgs(tp, b.String())
+}
+
+// String returns the text of the arithmetic for the error messages.
+func (b *builtinArithmeticSig) String() string {
+	return fmt.Sprintf("(%s %s %s)", b.args[0], arithmeticOps[b.op], b.args[1])
+}
+
+var arithmeticOps = map[string]string{
+	ast.Plus:   "+",
+	ast.Minus:  "-",
+	ast.Mul:    "*",
+	ast.Mod:    "%",
+	ast.Div:    "/",
+	ast.IntDiv: "DIV",
+}
+
+type arithmeticDivideFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *arithmeticDivideFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	lhsTp, rhsTp := args[0].GetType(), args[1].GetType()
+	et := types.ETDecimal
+	if numericContextResultType(lhsTp) == types.ETReal || numericContextResultType(rhsTp) == types.ETReal {
+		et = types.ETReal
+	}
+	tp := newRetType(et)
+	if et == types.ETDecimal {
+		tp.Decimal = types.DivFracIncr
+		if lhsTp.Decimal != types.UnspecifiedLength {
+			tp.Decimal += lhsTp.Decimal
+		}
+		if tp.Decimal > mysql.MaxDecimalScale {
+			tp.Decimal = mysql.MaxDecimalScale
+		}
+	}
+	sig := &builtinArithmeticDivideSig{newBaseBuiltinFunc(ctx, args, tp), et}
+	return sig, nil
+}
+
+type builtinArithmeticDivideSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+func (b *builtinArithmeticDivideSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	if b.et == types.ETReal {
+		a, err := args[0].ToFloat64(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		c, err := args[1].ToFloat64(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if c == 0 {
+			return handleDivisionByZero(b.ctx)
+		}
+		res := a / c
+		if math.IsInf(res, 0) {
+			return types.Datum{}, ErrOverflow.GenWithStackByArgs("DOUBLE", fmt.Sprintf("(%s / %s)", b.args[0], b.args[1]))
+		}
+		return types.NewFloat64Datum(res), nil
+	}
+	res, isNull, err := divideDecimal(b.ctx, args[0], args[1])
+	if err != nil || isNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	d := types.NewDecimalDatum(res)
+	d.SetFrac(b.tp.Decimal)
+	return d, nil
+}
+
+// divideDecimal divides the datums as decimals, the result is NULL if the divisor is zero.
+func divideDecimal(ctx sessionctx.Context, lhs, rhs types.Datum) (*types.MyDecimal, bool, error) {
+	sc := ctx.GetSessionVars().StmtCtx
+	a, err := lhs.ToDecimal(sc)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	c, err := rhs.ToDecimal(sc)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	res := new(types.MyDecimal)
+	err = types.DecimalDiv(a, c, res, types.DivFracIncr)
+	if err == types.ErrDivByZero {
+		_, err = handleDivisionByZero(ctx)
+		return nil, true, errors.Trace(err)
+	}
+	if err == types.ErrOverflow {
+		return nil, false, ErrOverflow.GenWithStackByArgs("DECIMAL", fmt.Sprintf("(%s / %s)", lhs.GetValue(), rhs.GetValue()))
+	}
+	if err != nil && err != types.ErrTruncated {
+		return nil, false, errors.Trace(err)
+	}
+	return res, false, nil
+}
+
+type arithmeticIntDivideFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *arithmeticIntDivideFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	lhsTp, rhsTp := args[0].GetType(), args[1].GetType()
+	et := getArithmeticEvalType(lhsTp, rhsTp)
+	if et != types.ETInt {
+		et = types.ETDecimal
+	}
+	tp := newRetType(types.ETInt)
+	if mysql.HasUnsignedFlag(lhsTp.Flag) || mysql.HasUnsignedFlag(rhsTp.Flag) {
+		tp.Flag |= mysql.UnsignedFlag
+	}
+	sig := &builtinArithmeticIntDivideSig{newBaseBuiltinFunc(ctx, args, tp), et}
+	return sig, nil
+}
+
+type builtinArithmeticIntDivideSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+func (b *builtinArithmeticIntDivideSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	unsigned := mysql.HasUnsignedFlag(b.tp.Flag)
+	if b.et == types.ETDecimal {
+		res, isNull, err := divideDecimal(b.ctx, args[0], args[1])
+		if err != nil || isNull {
+			return types.Datum{}, errors.Trace(err)
+		}
+		d := types.NewDecimalDatum(res)
+		tp := types.NewFieldType(mysql.TypeLonglong)
+		if unsigned {
+			tp.Flag |= mysql.UnsignedFlag
+		}
+		// The quotient is truncated to an integer.
+		err = res.Round(res, 0, types.ModeTruncate)
+		if err != nil && err != types.ErrTruncated {
+			return types.Datum{}, errors.Trace(err)
+		}
+		d.SetMysqlDecimal(res)
+		ret, err := d.ConvertTo(sc, tp)
+		return ret, errors.Trace(err)
+	}
+	a, isLHSUnsigned, err := evalIntArg(sc, args[0])
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	c, isRHSUnsigned, err := evalIntArg(sc, args[1])
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if c == 0 {
+		return handleDivisionByZero(b.ctx)
+	}
+	var (
+		res  int64
+		ures uint64
+	)
+	switch {
+	case isLHSUnsigned && isRHSUnsigned:
+		res = int64(uint64(a) / uint64(c))
+	case isLHSUnsigned:
+		ures, err = types.DivUintWithInt(uint64(a), c)
+		res = int64(ures)
+	case isRHSUnsigned:
+		ures, err = types.DivIntWithUint(a, uint64(c))
+		res = int64(ures)
+	default:
+		res, err = types.DivInt64(a, c)
+	}
+	if err != nil {
+		if types.ErrOverflow.Equal(err) {
+			tp := "BIGINT"
+			if unsigned {
+				tp = "BIGINT UNSIGNED"
+			}
+			err = ErrOverflow.GenWithStackByArgs(tp, fmt.Sprintf("(%s DIV %s)", b.args[0], b.args[1]))
+		}
+		return types.Datum{}, errors.Trace(err)
+	}
+	return newIntDatum(res, unsigned), nil
+}
+
+func mathMax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,59 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_cast.go
+//
+
+package expression
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+type castFunctionClass struct {
+	baseFunctionClass
+}
+
+// getFunction gets the function of CAST, the target type is set by NewFunction.
+func (c *castFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinCastSig{newBaseBuiltinFunc(ctx, args, args[0].GetType())}
+	return sig, nil
+}
+
+type builtinCastSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CAST(expr AS type).
+// See https://dev.mysql.com/doc/refman/5.7/en/cast-functions.html
+func (b *builtinCastSig) eval(row chunk.Row) (types.Datum, error) {
+	d, err := b.args[0].Eval(row)
+	if err != nil || d.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	ret, err := d.ConvertTo(b.ctx.GetSessionVars().StmtCtx, b.tp)
+	return ret, errors.Trace(err)
+}
+
+// BuildCastFunction builds a CAST ScalarFunction from the Expression.
+func BuildCastFunction(ctx sessionctx.Context, expr Expression, tp *types.FieldType) (Expression, error) {
+	return NewFunction(ctx, ast.Cast, tp, expr)
+}
@@ -0,0 +1,434 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_compare.go
+//
+
+package expression
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+func isTemporalEvalType(et types.EvalType) bool {
+	return et == types.ETDatetime || et == types.ETTimestamp || et == types.ETDuration
+}
+
+// GetCmpEvalType returns the eval type to compare the values of the two types.
+// See https://dev.mysql.com/doc/refman/5.7/en/type-conversion.html
+func GetCmpEvalType(lhs, rhs *types.FieldType) types.EvalType {
+	if lhs.Tp == mysql.TypeNull {
+		lhs = rhs
+	} else if rhs.Tp == mysql.TypeNull {
+		rhs = lhs
+	}
+	lhsEvalType, rhsEvalType := lhs.EvalType(), rhs.EvalType()
+	if lhsEvalType == types.ETTimestamp {
+		lhsEvalType = types.ETDatetime
+	}
+	if rhsEvalType == types.ETTimestamp {
+		rhsEvalType = types.ETDatetime
+	}
+	switch {
+	case lhsEvalType == rhsEvalType:
+		return lhsEvalType
+	case isTemporalEvalType(lhsEvalType) && isTemporalEvalType(rhsEvalType):
+		return types.ETDatetime
+	case isTemporalEvalType(lhsEvalType) && rhsEvalType == types.ETString:
+		return lhsEvalType
+	case isTemporalEvalType(rhsEvalType) && lhsEvalType == types.ETString:
+		return rhsEvalType
+	case lhsEvalType == types.ETString || rhsEvalType == types.ETString:
+		return types.ETReal
+	case lhsEvalType == types.ETJson || rhsEvalType == types.ETJson:
+		return types.ETJson
+	}
+	return getArithmeticEvalType(lhs, rhs)
+}
+
+// compareDatum compares two datums which are not NULL by the eval type.
+func compareDatum(sc *stmtctx.StatementContext, et types.EvalType, a, b types.Datum) (int, error) {
+	switch et {
+	case types.ETInt, types.ETJson:
+		return a.CompareDatum(sc, &b)
+	case types.ETReal:
+		x, err := a.ToFloat64(sc)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		y, err := b.ToFloat64(sc)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		return types.CompareFloat64(x, y), nil
+	case types.ETDecimal:
+		x, err := a.ToDecimal(sc)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		y, err := b.ToDecimal(sc)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		return x.Compare(y), nil
+	case types.ETString:
+		x, err := a.ToString()
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		y, err := b.ToString()
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		return strings.Compare(x, y), nil
+	}
+	// The temporal values are compared after they are converted to the same type.
+	tp := types.NewFieldType(mysql.TypeDatetime)
+	if et == types.ETDuration {
+		tp = types.NewFieldType(mysql.TypeDuration)
+	}
+	tp.Decimal = types.MaxFsp
+	x, err := a.ConvertTo(sc, tp)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	y, err := b.ConvertTo(sc, tp)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return x.CompareDatum(sc, &y)
+}
+
+// NormalizeCmpDatum converts d to the value which is compared by the evaluation type et,
+// the values are equal in comparison if and only if their normalized values are encoded
+// to the same bytes, so they can be used as the keys to hash.
+func NormalizeCmpDatum(sc *stmtctx.StatementContext, et types.EvalType, d types.Datum) (types.Datum, error) {
+	if d.IsNull() {
+		return d, nil
+	}
+	switch et {
+	case types.ETInt:
+		switch d.Kind() {
+		case types.KindInt64, types.KindUint64:
+			return d, nil
+		}
+		v, err := d.ToInt64(sc)
+		return types.NewIntDatum(v), errors.Trace(err)
+	case types.ETReal:
+		v, err := d.ToFloat64(sc)
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		if v == 0 {
+			// -0 is equal to 0.
+			v = 0
+		}
+		return types.NewFloat64Datum(v), nil
+	case types.ETDecimal:
+		v, err := d.ToDecimal(sc)
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+		// The decimals of different fractions, like 1.0 and 1.00, are normalized to the same string.
+		str := v.String()
+		if strings.Contains(str, ".") {
+			str = strings.TrimRight(strings.TrimRight(str, "0"), ".")
+		}
+		if str == "-0" {
+			str = "0"
+		}
+		return types.NewStringDatum(str), nil
+	case types.ETString:
+		v, err := d.ToString()
+		return types.NewStringDatum(v), errors.Trace(err)
+	case types.ETJson:
+		return d, nil
+	}
+	tp := types.NewFieldType(mysql.TypeDatetime)
+	if et == types.ETDuration {
+		tp = types.NewFieldType(mysql.TypeDuration)
+	}
+	tp.Decimal = types.MaxFsp
+	v, err := d.ConvertTo(sc, tp)
+	return v, errors.Trace(err)
+}
+
+type compareFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *compareFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flen = 1
+	et := GetCmpEvalType(args[0].GetType(), args[1].GetType())
+	sig := &builtinCompareSig{newBaseBuiltinFunc(ctx, args, tp), c.funcName, et}
+	return sig, nil
+}
+
+type builtinCompareSig struct {
+	baseBuiltinFunc
+
+	op string
+	et types.EvalType
+}
+
+func (b *builtinCompareSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if hasNull {
+		if b.op != ast.NullEQ {
+			return types.Datum{}, nil
+		}
+		if args[0].IsNull() && args[1].IsNull() {
+			return types.NewIntDatum(1), nil
+		}
+		return types.NewIntDatum(0), nil
+	}
+	cmp, err := compareDatum(b.ctx.GetSessionVars().StmtCtx, b.et, args[0], args[1])
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return boolToDatum(b.cmpResult(cmp)), nil
+}
+
+// cmpResult returns the result of the comparison by the sign of cmp.
+func (b *builtinCompareSig) cmpResult(cmp int) bool {
+	switch b.op {
+	case ast.LT:
+		return cmp < 0
+	case ast.LE:
+		return cmp <= 0
+	case ast.GT:
+		return cmp > 0
+	case ast.GE:
+		return cmp >= 0
+	case ast.NE:
+		return cmp != 0
+	}
+	return cmp == 0
+}
+
+// vectorized implements vecBuiltinFunc interface, the comparisons between signed integers,
+// doubles or strings are vectorized.
+func (b *builtinCompareSig) vectorized() bool {
+	lhsTp, rhsTp := b.args[0].GetType(), b.args[1].GetType()
+	switch b.et {
+	case types.ETInt:
+		return isSignedInt64Layout(lhsTp) && isSignedInt64Layout(rhsTp)
+	case types.ETReal:
+		return isFloat64Layout(lhsTp) && isFloat64Layout(rhsTp)
+	case types.ETString:
+		return isStringLayout(lhsTp) && isStringLayout(rhsTp)
+	}
+	return false
+}
+
+// vecEval implements vecBuiltinFunc interface.
+func (b *builtinCompareSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	n := input.NumRows()
+	lhs, rhs := bufs[0], bufs[1]
+	result.ResizeInt64(n, false)
+	res := result.Int64s()
+	for i := 0; i < n; i++ {
+		isNull0, isNull1 := lhs.IsNull(i), rhs.IsNull(i)
+		if isNull0 || isNull1 {
+			if b.op == ast.NullEQ {
+				res[i] = boolToInt64(isNull0 && isNull1)
+			} else {
+				result.SetNull(i, true)
+			}
+			continue
+		}
+		var cmp int
+		switch b.et {
+		case types.ETInt:
+			cmp = types.CompareInt64(lhs.GetInt64(i), rhs.GetInt64(i))
+		case types.ETReal:
+			cmp = types.CompareFloat64(lhs.GetFloat64(i), rhs.GetFloat64(i))
+		default:
+			cmp = strings.Compare(lhs.GetString(i), rhs.GetString(i))
+		}
+		res[i] = boolToInt64(b.cmpResult(cmp))
+	}
+	return nil
+}
+
+func boolToDatum(b bool) types.Datum {
+	if b {
+		return types.NewIntDatum(1)
+	}
+	return types.NewIntDatum(0)
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type inFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *inFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flen = 1
+	ets := make([]types.EvalType, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		ets = append(ets, GetCmpEvalType(args[0].GetType(), arg.GetType()))
+	}
+	sig := &builtinInSig{newBaseBuiltinFunc(ctx, args, tp), ets}
+	return sig, nil
+}
+
+type builtinInSig struct {
+	baseBuiltinFunc
+
+	ets []types.EvalType
+}
+
+// eval evals `a IN (b, c, ...)`, the result is NULL if a is NULL, or there is no
+// match but there is a NULL in the list.
+func (b *builtinInSig) eval(row chunk.Row) (types.Datum, error) {
+	arg0, err := b.args[0].Eval(row)
+	if err != nil || arg0.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	hasNull := false
+	for i, arg := range b.args[1:] {
+		d, err := arg.Eval(row)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if d.IsNull() {
+			hasNull = true
+			continue
+		}
+		cmp, err := compareDatum(sc, b.ets[i], arg0, d)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if cmp == 0 {
+			return types.NewIntDatum(1), nil
+		}
+	}
+	if hasNull {
+		return types.Datum{}, nil
+	}
+	return types.NewIntDatum(0), nil
+}
+
+type coalesceFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *coalesceFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinCoalesceSig{newBaseBuiltinFunc(ctx, args, inferTypeFromArgs(args...))}
+	return sig, nil
+}
+
+type builtinCoalesceSig struct {
+	baseBuiltinFunc
+}
+
+// eval returns the first non-NULL value in the list, or NULL if there are no non-NULL values.
+func (b *builtinCoalesceSig) eval(row chunk.Row) (types.Datum, error) {
+	for _, arg := range b.args {
+		d, err := arg.Eval(row)
+		if err != nil || !d.IsNull() {
+			return convertToRetType(b.ctx, d, b.tp, err)
+		}
+	}
+	return types.Datum{}, nil
+}
+
+// inferTypeFromArgs infers the return type of the functions like IF and COALESCE
+// whose result is one of the arguments.
+func inferTypeFromArgs(args ...Expression) *types.FieldType {
+	fts := make([]*types.FieldType, 0, len(args))
+	for _, arg := range args {
+		fts = append(fts, arg.GetType())
+	}
+	var flag uint
+	et := types.AggregateEvalType(fts, &flag)
+	tp := newRetType(et)
+	if aggTp := types.AggFieldType(fts); aggTp.Tp != mysql.TypeNull && aggTp.EvalType() == et {
+		// Keep the type like DATE or INT if the arguments are of it.
+		tp.Tp = aggTp.Tp
+	}
+	tp.Flag |= flag & mysql.UnsignedFlag
+	decimal := 0
+	for _, ft := range fts {
+		if ft.Tp != mysql.TypeNull && ft.Decimal > decimal {
+			decimal = ft.Decimal
+		}
+	}
+	if et == types.ETReal || et == types.ETDecimal || isTemporalEvalType(et) {
+		tp.Decimal = decimal
+	}
+	return tp
+}
+
+// convertToRetType converts the result to the return type, so that the values of
+// a function like IF are of the same kind.
+func convertToRetType(ctx sessionctx.Context, d types.Datum, tp *types.FieldType, err error) (types.Datum, error) {
+	if err != nil || d.IsNull() {
+		return d, errors.Trace(err)
+	}
+	switch tp.EvalType() {
+	case types.ETString:
+		if d.Kind() == types.KindString || d.Kind() == types.KindBytes {
+			return d, nil
+		}
+		s, err := d.ToString()
+		return types.NewStringDatum(s), errors.Trace(err)
+	case types.ETReal:
+		f, err := d.ToFloat64(ctx.GetSessionVars().StmtCtx)
+		return types.NewFloat64Datum(f), errors.Trace(err)
+	case types.ETDecimal:
+		dec, err := d.ToDecimal(ctx.GetSessionVars().StmtCtx)
+		return types.NewDecimalDatum(dec), errors.Trace(err)
+	case types.ETInt:
+		if d.Kind() == types.KindInt64 || d.Kind() == types.KindUint64 {
+			return d, nil
+		}
+	}
+	ret, err := d.ConvertTo(ctx.GetSessionVars().StmtCtx, tp)
+	return ret, errors.Trace(err)
+}
@@ -0,0 +1,149 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_control.go
+//
+
+package expression
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+type caseWhenFunctionClass struct {
+	baseFunctionClass
+}
+
+// getFunction gets the function of `CASE WHEN c0 THEN r0 WHEN c1 THEN r1 ... ELSE rn END`,
+// whose arguments are [c0, r0, c1, r1, ..., rn], the ELSE result is optional.
+func (c *caseWhenFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	l := len(args)
+	results := make([]Expression, 0, l/2+1)
+	for i := 1; i < l; i += 2 {
+		results = append(results, args[i])
+	}
+	if l%2 == 1 {
+		results = append(results, args[l-1])
+	}
+	sig := &builtinCaseWhenSig{newBaseBuiltinFunc(ctx, args, inferTypeFromArgs(results...))}
+	return sig, nil
+}
+
+type builtinCaseWhenSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinCaseWhenSig) eval(row chunk.Row) (types.Datum, error) {
+	l := len(b.args)
+	for i := 0; i < l-1; i += 2 {
+		cond, isNull, err := evalBool(b.ctx, b.args[i], row)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if isNull || !cond {
+			continue
+		}
+		d, err := b.args[i+1].Eval(row)
+		return convertToRetType(b.ctx, d, b.tp, err)
+	}
+	// No condition matches, the result is the ELSE clause, or NULL if there is no ELSE clause.
+	if l%2 == 1 {
+		d, err := b.args[l-1].Eval(row)
+		return convertToRetType(b.ctx, d, b.tp, err)
+	}
+	return types.Datum{}, nil
+}
+
+type ifFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *ifFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinIfSig{newBaseBuiltinFunc(ctx, args, inferTypeFromArgs(args[1], args[2]))}
+	return sig, nil
+}
+
+type builtinIfSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinIfSig) eval(row chunk.Row) (types.Datum, error) {
+	cond, isNull, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	arg := b.args[2]
+	if !isNull && cond {
+		arg = b.args[1]
+	}
+	d, err := arg.Eval(row)
+	return convertToRetType(b.ctx, d, b.tp, err)
+}
+
+type ifNullFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *ifNullFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinCoalesceSig{newBaseBuiltinFunc(ctx, args, inferTypeFromArgs(args...))}
+	return sig, nil
+}
+
+type nullIfFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *nullIfFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := *args[0].GetType()
+	et := GetCmpEvalType(args[0].GetType(), args[1].GetType())
+	sig := &builtinNullIfSig{newBaseBuiltinFunc(ctx, args, &tp), et}
+	return sig, nil
+}
+
+type builtinNullIfSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+// eval evals `NULLIF(a, b)`, the result is NULL if a = b is true, otherwise a.
+func (b *builtinNullIfSig) eval(row chunk.Row) (types.Datum, error) {
+	args, _, err := b.evalArgs(row)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if args[0].IsNull() || args[1].IsNull() {
+		return args[0], nil
+	}
+	cmp, err := compareDatum(b.ctx.GetSessionVars().StmtCtx, b.et, args[0], args[1])
+	if err != nil || cmp == 0 {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return args[0], nil
+}
@@ -0,0 +1,209 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_info.go
+//
+
+package expression
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+type databaseFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *databaseFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETString)
+	tp.Flen = 64
+	sig := &builtinDatabaseSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinDatabaseSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals DATABASE(), the result is NULL if there is no default database.
+// See https://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_database
+func (b *builtinDatabaseSig) eval(row chunk.Row) (types.Datum, error) {
+	currentDB := b.ctx.GetSessionVars().CurrentDB
+	if currentDB == "" {
+		return types.Datum{}, nil
+	}
+	return types.NewStringDatum(currentDB), nil
+}
+
+type currentUserFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *currentUserFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETString)
+	tp.Flen = 64
+	sig := &builtinCurrentUserSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinCurrentUserSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CURRENT_USER(), the account the session is authenticated as.
+// See https://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_current-user
+func (b *builtinCurrentUserSig) eval(row chunk.Row) (types.Datum, error) {
+	user := b.ctx.GetSessionVars().User
+	if user == nil {
+		return types.Datum{}, errors.Errorf("Missing session variable when eval builtin")
+	}
+	return types.NewStringDatum(user.AuthIdentityString()), nil
+}
+
+// currentRole is the name of CURRENT_ROLE(), which isn't defined by the ast package.
+const currentRole = "current_role"
+
+type currentRoleFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *currentRoleFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETString)
+	tp.Flen = mysql.MaxFieldVarCharLength
+	sig := &builtinCurrentRoleSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinCurrentRoleSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CURRENT_ROLE(), the active roles of the session sorted and separated by commas,
+// it's NONE if there is no active role.
+// See https://dev.mysql.com/doc/refman/8.0/en/information-functions.html#function_current-role
+func (b *builtinCurrentRoleSig) eval(row chunk.Row) (types.Datum, error) {
+	roles := b.ctx.GetSessionVars().ActiveRoles
+	if len(roles) == 0 {
+		return types.NewStringDatum("NONE"), nil
+	}
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, "`"+role.Username+"`@`"+role.Hostname+"`")
+	}
+	sort.Strings(names)
+	return types.NewStringDatum(strings.Join(names, ",")), nil
+}
+
+type connectionIDFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *connectionIDFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flag |= mysql.UnsignedFlag
+	sig := &builtinConnectionIDSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinConnectionIDSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CONNECTION_ID().
+// See https://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_connection-id
+func (b *builtinConnectionIDSig) eval(row chunk.Row) (types.Datum, error) {
+	return types.NewUintDatum(b.ctx.GetSessionVars().ConnectionID), nil
+}
+
+type lastInsertIDFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *lastInsertIDFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flag |= mysql.UnsignedFlag
+	sig := &builtinLastInsertIDSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinLastInsertIDSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals LAST_INSERT_ID() and LAST_INSERT_ID(expr). The value is the first ID generated for
+// an auto_increment column by the last statement which generates one, LAST_INSERT_ID(expr) sets
+// it to expr, which is returned by the next LAST_INSERT_ID().
+// See https://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_last-insert-id
+func (b *builtinLastInsertIDSig) eval(row chunk.Row) (types.Datum, error) {
+	vars := b.ctx.GetSessionVars()
+	if len(b.args) == 0 {
+		return types.NewUintDatum(vars.PrevLastInsertID), nil
+	}
+	d, err := b.args[0].Eval(row)
+	if err != nil || d.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	val, _, err := evalIntArg(vars.StmtCtx, d)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	vars.LastInsertID = uint64(val)
+	return types.NewUintDatum(uint64(val)), nil
+}
+
+type versionFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *versionFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETString)
+	tp.Flen = 64
+	sig := &builtinVersionSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinVersionSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals VERSION().
+// See https://dev.mysql.com/doc/refman/5.7/en/information-functions.html#function_version
+func (b *builtinVersionSig) eval(row chunk.Row) (types.Datum, error) {
+	return types.NewStringDatum(mysql.ServerVersion), nil
+}
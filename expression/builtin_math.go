@@ -0,0 +1,263 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_math.go
+//
+
+package expression
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+type absFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *absFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	argTp := args[0].GetType()
+	et := numericContextResultType(argTp)
+	tp := newRetType(et)
+	tp.Flag |= argTp.Flag & mysql.UnsignedFlag
+	if et == types.ETDecimal {
+		tp.Decimal = argTp.Decimal
+	}
+	sig := &builtinAbsSig{newBaseBuiltinFunc(ctx, args, tp), et}
+	return sig, nil
+}
+
+type builtinAbsSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+// eval evals ABS(X).
+// See https://dev.mysql.com/doc/refman/5.7/en/mathematical-functions.html#function_abs
+func (b *builtinAbsSig) eval(row chunk.Row) (types.Datum, error) {
+	d, err := b.args[0].Eval(row)
+	if err != nil || d.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	switch b.et {
+	case types.ETInt:
+		val, unsigned, err := evalIntArg(sc, d)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if unsigned || val >= 0 {
+			return newIntDatum(val, unsigned), nil
+		}
+		if val == math.MinInt64 {
+			return types.Datum{}, ErrOverflow.GenWithStackByArgs("BIGINT", fmt.Sprintf("abs(%s)", b.args[0]))
+		}
+		return types.NewIntDatum(-val), nil
+	case types.ETDecimal:
+		dec, err := d.ToDecimal(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if dec.IsNegative() {
+			dec = types.DecimalNeg(dec)
+		}
+		return types.NewDecimalDatum(dec), nil
+	}
+	f, err := d.ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewFloat64Datum(math.Abs(f)), nil
+}
+
+type ceilFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *ceilFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newCeilOrFloorSig(ctx, args, true), nil
+}
+
+type floorFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *floorFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return newCeilOrFloorSig(ctx, args, false), nil
+}
+
+func newCeilOrFloorSig(ctx sessionctx.Context, args []Expression, isCeil bool) builtinFunc {
+	argTp := args[0].GetType()
+	et := numericContextResultType(argTp)
+	tp := newRetType(et)
+	tp.Flag |= argTp.Flag & mysql.UnsignedFlag
+	if et == types.ETDecimal {
+		tp.Decimal = 0
+	}
+	return &builtinCeilOrFloorSig{newBaseBuiltinFunc(ctx, args, tp), et, isCeil}
+}
+
+type builtinCeilOrFloorSig struct {
+	baseBuiltinFunc
+
+	et     types.EvalType
+	isCeil bool
+}
+
+// eval evals CEIL(X) or FLOOR(X), the result of an exact-value number is a decimal without fraction.
+// See https://dev.mysql.com/doc/refman/5.7/en/mathematical-functions.html#function_ceil
+func (b *builtinCeilOrFloorSig) eval(row chunk.Row) (types.Datum, error) {
+	d, err := b.args[0].Eval(row)
+	if err != nil || d.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	switch b.et {
+	case types.ETInt:
+		val, unsigned, err := evalIntArg(sc, d)
+		return newIntDatum(val, unsigned), errors.Trace(err)
+	case types.ETDecimal:
+		dec, err := d.ToDecimal(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		res := new(types.MyDecimal)
+		if err = dec.Round(res, 0, types.ModeTruncate); err != nil && err != types.ErrTruncated {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if cmp := dec.Compare(res); cmp != 0 && (cmp > 0) == b.isCeil {
+			one := new(types.MyDecimal).FromInt(1)
+			if cmp < 0 {
+				one = types.DecimalNeg(one)
+			}
+			if err = types.DecimalAdd(res, one, res); err != nil {
+				return types.Datum{}, errors.Trace(err)
+			}
+		}
+		return types.NewDecimalDatum(res), nil
+	}
+	f, err := d.ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if b.isCeil {
+		return types.NewFloat64Datum(math.Ceil(f)), nil
+	}
+	return types.NewFloat64Datum(math.Floor(f)), nil
+}
+
+type roundFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *roundFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	argTp := args[0].GetType()
+	et := numericContextResultType(argTp)
+	tp := newRetType(et)
+	tp.Flag |= argTp.Flag & mysql.UnsignedFlag
+	if et == types.ETDecimal {
+		tp.Decimal = 0
+		if len(args) > 1 {
+			tp.Decimal = argTp.Decimal
+			if con, ok := args[1].(*Constant); ok && !con.Value.IsNull() {
+				if frac, err := con.Value.ToInt64(ctx.GetSessionVars().StmtCtx); err == nil && frac >= 0 && int(frac) < tp.Decimal {
+					tp.Decimal = int(frac)
+				}
+			}
+		}
+	}
+	sig := &builtinRoundSig{newBaseBuiltinFunc(ctx, args, tp), et}
+	return sig, nil
+}
+
+type builtinRoundSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+// eval evals ROUND(X[, D]), the value is rounded half away from zero.
+// See https://dev.mysql.com/doc/refman/5.7/en/mathematical-functions.html#function_round
+func (b *builtinRoundSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	var frac int64
+	if len(args) > 1 {
+		if frac, err = args[1].ToInt64(sc); err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if frac > mysql.MaxDecimalScale {
+			frac = mysql.MaxDecimalScale
+		} else if frac < -mysql.MaxDecimalScale {
+			frac = -mysql.MaxDecimalScale
+		}
+	}
+	switch b.et {
+	case types.ETInt:
+		val, unsigned, err := evalIntArg(sc, args[0])
+		if err != nil || frac >= 0 {
+			return newIntDatum(val, unsigned), errors.Trace(err)
+		}
+		// Round the integer to the tens, hundreds and so on.
+		dec := new(types.MyDecimal)
+		if unsigned {
+			dec.FromUint(uint64(val))
+		} else {
+			dec.FromInt(val)
+		}
+		if err = dec.Round(dec, int(frac), types.ModeHalfEven); err != nil && err != types.ErrTruncated {
+			return types.Datum{}, errors.Trace(err)
+		}
+		d := types.NewDecimalDatum(dec)
+		ret, err := d.ConvertTo(sc, b.tp)
+		return ret, errors.Trace(err)
+	case types.ETDecimal:
+		dec, err := args[0].ToDecimal(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		res := new(types.MyDecimal)
+		if err = dec.Round(res, int(frac), types.ModeHalfEven); err != nil && err != types.ErrTruncated {
+			return types.Datum{}, errors.Trace(err)
+		}
+		return types.NewDecimalDatum(res), nil
+	}
+	f, err := args[0].ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewFloat64Datum(types.Round(f, int(frac))), nil
+}
@@ -0,0 +1,424 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_op.go
+//
+
+package expression
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// newBoolRetType returns the return type of the functions whose result is 0, 1 or NULL.
+func newBoolRetType() *types.FieldType {
+	tp := newRetType(types.ETInt)
+	tp.Flen = 1
+	return tp
+}
+
+// evalBool evaluates the expression to a boolean, isNull is true if the value is NULL.
+func evalBool(ctx sessionctx.Context, expr Expression, row chunk.Row) (res bool, isNull bool, err error) {
+	d, err := expr.Eval(row)
+	if err != nil || d.IsNull() {
+		return false, d.IsNull(), errors.Trace(err)
+	}
+	i, err := d.ToBool(ctx.GetSessionVars().StmtCtx)
+	if err != nil {
+		return false, false, errors.Trace(err)
+	}
+	return i != 0, false, nil
+}
+
+type logicAndFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *logicAndFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinLogicAndSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinLogicAndSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals `a AND b`, the second argument is not evaluated if the first one is false.
+func (b *builtinLogicAndSig) eval(row chunk.Row) (types.Datum, error) {
+	arg0, isNull0, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil || (!isNull0 && !arg0) {
+		return types.NewIntDatum(0), errors.Trace(err)
+	}
+	arg1, isNull1, err := evalBool(b.ctx, b.args[1], row)
+	if err != nil || (!isNull1 && !arg1) {
+		return types.NewIntDatum(0), errors.Trace(err)
+	}
+	if isNull0 || isNull1 {
+		return types.Datum{}, nil
+	}
+	return types.NewIntDatum(1), nil
+}
+
+// vectorized implements vecBuiltinFunc interface.
+func (b *builtinLogicAndSig) vectorized() bool {
+	return isInt64Layout(b.args[0].GetType()) && isInt64Layout(b.args[1].GetType())
+}
+
+// vecEval implements vecBuiltinFunc interface, unlike eval, both the arguments are evaluated.
+func (b *builtinLogicAndSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	n := input.NumRows()
+	result.ResizeInt64(n, false)
+	a, c, res := bufs[0].Int64s(), bufs[1].Int64s(), result.Int64s()
+	for i := 0; i < n; i++ {
+		isNull0, isNull1 := bufs[0].IsNull(i), bufs[1].IsNull(i)
+		switch {
+		case (!isNull0 && a[i] == 0) || (!isNull1 && c[i] == 0):
+			res[i] = 0
+		case isNull0 || isNull1:
+			result.SetNull(i, true)
+		default:
+			res[i] = 1
+		}
+	}
+	return nil
+}
+
+type logicOrFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *logicOrFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinLogicOrSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinLogicOrSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals `a OR b`, the second argument is not evaluated if the first one is true.
+func (b *builtinLogicOrSig) eval(row chunk.Row) (types.Datum, error) {
+	arg0, isNull0, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil || (!isNull0 && arg0) {
+		return types.NewIntDatum(1), errors.Trace(err)
+	}
+	arg1, isNull1, err := evalBool(b.ctx, b.args[1], row)
+	if err != nil || (!isNull1 && arg1) {
+		return types.NewIntDatum(1), errors.Trace(err)
+	}
+	if isNull0 || isNull1 {
+		return types.Datum{}, nil
+	}
+	return types.NewIntDatum(0), nil
+}
+
+// vectorized implements vecBuiltinFunc interface.
+func (b *builtinLogicOrSig) vectorized() bool {
+	return isInt64Layout(b.args[0].GetType()) && isInt64Layout(b.args[1].GetType())
+}
+
+// vecEval implements vecBuiltinFunc interface, unlike eval, both the arguments are evaluated.
+func (b *builtinLogicOrSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	n := input.NumRows()
+	result.ResizeInt64(n, false)
+	a, c, res := bufs[0].Int64s(), bufs[1].Int64s(), result.Int64s()
+	for i := 0; i < n; i++ {
+		isNull0, isNull1 := bufs[0].IsNull(i), bufs[1].IsNull(i)
+		switch {
+		case (!isNull0 && a[i] != 0) || (!isNull1 && c[i] != 0):
+			res[i] = 1
+		case isNull0 || isNull1:
+			result.SetNull(i, true)
+		default:
+			res[i] = 0
+		}
+	}
+	return nil
+}
+
+type logicXorFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *logicXorFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinLogicXorSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinLogicXorSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinLogicXorSig) eval(row chunk.Row) (types.Datum, error) {
+	arg0, isNull, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil || isNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	arg1, isNull, err := evalBool(b.ctx, b.args[1], row)
+	if err != nil || isNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return boolToDatum(arg0 != arg1), nil
+}
+
+type bitFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *bitFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flag |= mysql.UnsignedFlag
+	sig := &builtinBitSig{newBaseBuiltinFunc(ctx, args, tp), c.funcName}
+	return sig, nil
+}
+
+type builtinBitSig struct {
+	baseBuiltinFunc
+
+	op string
+}
+
+// eval evals the bit operations, the arguments are converted to unsigned 64-bit integers.
+func (b *builtinBitSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	vals := make([]uint64, 0, len(args))
+	for _, arg := range args {
+		val, _, err := evalIntArg(sc, arg)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		vals = append(vals, uint64(val))
+	}
+	var res uint64
+	switch b.op {
+	case ast.BitNeg:
+		res = ^vals[0]
+	case ast.And:
+		res = vals[0] & vals[1]
+	case ast.Or:
+		res = vals[0] | vals[1]
+	case ast.Xor:
+		res = vals[0] ^ vals[1]
+	case ast.LeftShift:
+		res = vals[0] << vals[1]
+	case ast.RightShift:
+		res = vals[0] >> vals[1]
+	}
+	return types.NewUintDatum(res), nil
+}
+
+type unaryNotFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *unaryNotFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinUnaryNotSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinUnaryNotSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinUnaryNotSig) eval(row chunk.Row) (types.Datum, error) {
+	arg, isNull, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil || isNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return boolToDatum(!arg), nil
+}
+
+// vectorized implements vecBuiltinFunc interface.
+func (b *builtinUnaryNotSig) vectorized() bool {
+	return isInt64Layout(b.args[0].GetType())
+}
+
+// vecEval implements vecBuiltinFunc interface.
+func (b *builtinUnaryNotSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	n := input.NumRows()
+	result.ResizeInt64(n, false)
+	a, res := bufs[0].Int64s(), result.Int64s()
+	for i := 0; i < n; i++ {
+		if bufs[0].IsNull(i) {
+			result.SetNull(i, true)
+			continue
+		}
+		res[i] = boolToInt64(a[i] == 0)
+	}
+	return nil
+}
+
+type unaryMinusFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *unaryMinusFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	argTp := args[0].GetType()
+	et := numericContextResultType(argTp)
+	tp := newRetType(et)
+	if et == types.ETDecimal {
+		tp.Decimal = argTp.Decimal
+	}
+	sig := &builtinUnaryMinusSig{newBaseBuiltinFunc(ctx, args, tp), et}
+	return sig, nil
+}
+
+type builtinUnaryMinusSig struct {
+	baseBuiltinFunc
+
+	et types.EvalType
+}
+
+func (b *builtinUnaryMinusSig) eval(row chunk.Row) (types.Datum, error) {
+	d, err := b.args[0].Eval(row)
+	if err != nil || d.IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	switch b.et {
+	case types.ETInt:
+		val, unsigned, err := evalIntArg(sc, d)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if unsigned && uint64(val) > uint64(math.MaxInt64)+1 || !unsigned && val == math.MinInt64 {
+			return types.Datum{}, ErrOverflow.GenWithStackByArgs("BIGINT", fmt.Sprintf("-%s", b.args[0]))
+		}
+		return types.NewIntDatum(-val), nil
+	case types.ETDecimal:
+		dec, err := d.ToDecimal(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		return types.NewDecimalDatum(types.DecimalNeg(dec)), nil
+	}
+	f, err := d.ToFloat64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewFloat64Datum(-f), nil
+}
+
+type isTrueOrFalseFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *isTrueOrFalseFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinIsTrueOrFalseSig{newBaseBuiltinFunc(ctx, args, newBoolRetType()), c.funcName == ast.IsTruth}
+	return sig, nil
+}
+
+type builtinIsTrueOrFalseSig struct {
+	baseBuiltinFunc
+
+	isTrue bool
+}
+
+// eval evals `a IS TRUE` or `a IS FALSE`, the result is never NULL.
+func (b *builtinIsTrueOrFalseSig) eval(row chunk.Row) (types.Datum, error) {
+	arg, isNull, err := evalBool(b.ctx, b.args[0], row)
+	if err != nil || isNull {
+		return types.NewIntDatum(0), errors.Trace(err)
+	}
+	return boolToDatum(arg == b.isTrue), nil
+}
+
+type isNullFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *isNullFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinIsNullSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinIsNullSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinIsNullSig) eval(row chunk.Row) (types.Datum, error) {
+	d, err := b.args[0].Eval(row)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return boolToDatum(d.IsNull()), nil
+}
+
+// vectorized implements vecBuiltinFunc interface, the argument can be of any type.
+func (b *builtinIsNullSig) vectorized() bool {
+	return true
+}
+
+// vecEval implements vecBuiltinFunc interface.
+func (b *builtinIsNullSig) vecEval(input *chunk.Chunk, result *chunk.Column) error {
+	bufs, err := b.vecEvalArgs(input)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	n := input.NumRows()
+	result.ResizeInt64(n, false)
+	res := result.Int64s()
+	for i := 0; i < n; i++ {
+		res[i] = boolToInt64(bufs[0].IsNull(i))
+	}
+	return nil
+}
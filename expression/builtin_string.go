@@ -0,0 +1,425 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_string.go
+//
+
+package expression
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/stringutil"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// newStringRetType returns the string type of the given length, the result is a
+// binary string if any argument is.
+func newStringRetType(flen int, args ...Expression) *types.FieldType {
+	tp := newRetType(types.ETString)
+	tp.Flen = flen
+	for _, arg := range args {
+		if types.IsBinaryStr(arg.GetType()) {
+			types.SetBinChsClnFlag(tp)
+			break
+		}
+	}
+	return tp
+}
+
+// evalStrings evaluates all the arguments to strings, hasNull is true if any of them is NULL.
+func (b *baseBuiltinFunc) evalStrings(row chunk.Row) (strs []string, hasNull bool, err error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return nil, hasNull, errors.Trace(err)
+	}
+	strs = make([]string, 0, len(args))
+	for _, arg := range args {
+		s, err := arg.ToString()
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		strs = append(strs, s)
+	}
+	return strs, false, nil
+}
+
+type concatFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *concatFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	flen := 0
+	for _, arg := range args {
+		if argFlen := arg.GetType().Flen; argFlen == types.UnspecifiedLength || flen == types.UnspecifiedLength {
+			flen = types.UnspecifiedLength
+		} else {
+			flen += argFlen
+		}
+	}
+	sig := &builtinConcatSig{newBaseBuiltinFunc(ctx, args, newStringRetType(flen, args...))}
+	return sig, nil
+}
+
+type builtinConcatSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CONCAT(str1,str2,...), the result is NULL if any argument is NULL.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_concat
+func (b *builtinConcatSig) eval(row chunk.Row) (types.Datum, error) {
+	strs, hasNull, err := b.evalStrings(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewStringDatum(strings.Join(strs, "")), nil
+}
+
+type concatWSFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *concatWSFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinConcatWSSig{newBaseBuiltinFunc(ctx, args, newStringRetType(types.UnspecifiedLength, args...))}
+	return sig, nil
+}
+
+type builtinConcatWSSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CONCAT_WS(separator,str1,str2,...), the NULL values after the separator are skipped.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_concat-ws
+func (b *builtinConcatWSSig) eval(row chunk.Row) (types.Datum, error) {
+	args, _, err := b.evalArgs(row)
+	if err != nil || args[0].IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sep, err := args[0].ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	strs := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		if arg.IsNull() {
+			continue
+		}
+		s, err := arg.ToString()
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		strs = append(strs, s)
+	}
+	return types.NewStringDatum(strings.Join(strs, sep)), nil
+}
+
+type lengthFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *lengthFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flen = 10
+	sig := &builtinLengthSig{newBaseBuiltinFunc(ctx, args, tp), false}
+	return sig, nil
+}
+
+type charLengthFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *charLengthFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETInt)
+	tp.Flen = 10
+	// The length of a binary string is counted in bytes.
+	sig := &builtinLengthSig{newBaseBuiltinFunc(ctx, args, tp), !types.IsBinaryStr(args[0].GetType())}
+	return sig, nil
+}
+
+type builtinLengthSig struct {
+	baseBuiltinFunc
+
+	inChars bool
+}
+
+// eval evals LENGTH(str) in bytes, or CHAR_LENGTH(str) in characters.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_length
+func (b *builtinLengthSig) eval(row chunk.Row) (types.Datum, error) {
+	strs, hasNull, err := b.evalStrings(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if b.inChars {
+		return types.NewIntDatum(int64(utf8.RuneCountInString(strs[0]))), nil
+	}
+	return types.NewIntDatum(int64(len(strs[0]))), nil
+}
+
+type upperFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *upperFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinCaseConvSig{newBaseBuiltinFunc(ctx, args, newStringRetType(args[0].GetType().Flen, args...)), strings.ToUpper}
+	return sig, nil
+}
+
+type lowerFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *lowerFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinCaseConvSig{newBaseBuiltinFunc(ctx, args, newStringRetType(args[0].GetType().Flen, args...)), strings.ToLower}
+	return sig, nil
+}
+
+type builtinCaseConvSig struct {
+	baseBuiltinFunc
+
+	conv func(string) string
+}
+
+// eval evals UPPER(str) or LOWER(str), a binary string is returned unchanged.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_upper
+func (b *builtinCaseConvSig) eval(row chunk.Row) (types.Datum, error) {
+	strs, hasNull, err := b.evalStrings(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if types.IsBinaryStr(b.args[0].GetType()) {
+		return types.NewStringDatum(strs[0]), nil
+	}
+	return types.NewStringDatum(b.conv(strs[0])), nil
+}
+
+type replaceFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *replaceFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinReplaceSig{newBaseBuiltinFunc(ctx, args, newStringRetType(types.UnspecifiedLength, args...))}
+	return sig, nil
+}
+
+type builtinReplaceSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals REPLACE(str,from_str,to_str).
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_replace
+func (b *builtinReplaceSig) eval(row chunk.Row) (types.Datum, error) {
+	strs, hasNull, err := b.evalStrings(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	if strs[1] == "" {
+		return types.NewStringDatum(strs[0]), nil
+	}
+	return types.NewStringDatum(strings.Replace(strs[0], strs[1], strs[2], -1)), nil
+}
+
+type substringFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *substringFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinSubstringSig{newBaseBuiltinFunc(ctx, args, newStringRetType(args[0].GetType().Flen, args[0]))}
+	return sig, nil
+}
+
+type builtinSubstringSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals SUBSTRING(str,pos[,len]), the position counts from the end if it is negative.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_substring
+func (b *builtinSubstringSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	str, err := args[0].ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	sc := b.ctx.GetSessionVars().StmtCtx
+	pos, err := args[1].ToInt64(sc)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	var s []rune
+	if types.IsBinaryStr(b.args[0].GetType()) {
+		s = make([]rune, 0, len(str))
+		for i := 0; i < len(str); i++ {
+			s = append(s, rune(str[i]))
+		}
+	} else {
+		s = []rune(str)
+	}
+	length := int64(len(s))
+	if pos < 0 {
+		pos += length
+	} else {
+		pos--
+	}
+	if pos < 0 || pos >= length {
+		return types.NewStringDatum(""), nil
+	}
+	end := length
+	if len(args) == 3 {
+		l, err := args[2].ToInt64(sc)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		if l <= 0 {
+			return types.NewStringDatum(""), nil
+		}
+		if pos+l < end {
+			end = pos + l
+		}
+	}
+	if types.IsBinaryStr(b.args[0].GetType()) {
+		return types.NewStringDatum(str[pos:end]), nil
+	}
+	return types.NewStringDatum(string(s[pos:end])), nil
+}
+
+type trimFunctionClass struct {
+	baseFunctionClass
+}
+
+// getFunction gets the function of TRIM, whose arguments are [str, remstr, direction].
+// See https://dev.mysql.com/doc/refman/5.7/en/string-functions.html#function_trim
+func (c *trimFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinTrimSig{newBaseBuiltinFunc(ctx, args, newStringRetType(args[0].GetType().Flen, args[0]))}
+	return sig, nil
+}
+
+type builtinTrimSig struct {
+	baseBuiltinFunc
+}
+
+func (b *builtinTrimSig) eval(row chunk.Row) (types.Datum, error) {
+	args, _, err := b.evalArgs(row)
+	if err != nil || args[0].IsNull() {
+		return types.Datum{}, errors.Trace(err)
+	}
+	str, err := args[0].ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	remstr, direction := " ", ast.TrimBothDefault
+	if len(args) > 1 {
+		if !args[1].IsNull() {
+			if remstr, err = args[1].ToString(); err != nil {
+				return types.Datum{}, errors.Trace(err)
+			}
+		} else if len(args) == 2 {
+			// TRIM(NULL FROM str) is NULL, while TRIM(LEADING FROM str) trims the spaces.
+			return types.Datum{}, nil
+		}
+	}
+	if len(args) > 2 {
+		dir, err := args[2].ToInt64(b.ctx.GetSessionVars().StmtCtx)
+		if err != nil {
+			return types.Datum{}, errors.Trace(err)
+		}
+		direction = ast.TrimDirectionType(dir)
+	}
+	if remstr == "" {
+		return types.NewStringDatum(str), nil
+	}
+	if direction != ast.TrimTrailing {
+		for strings.HasPrefix(str, remstr) {
+			str = str[len(remstr):]
+		}
+	}
+	if direction != ast.TrimLeading {
+		for strings.HasSuffix(str, remstr) {
+			str = str[:len(str)-len(remstr)]
+		}
+	}
+	return types.NewStringDatum(str), nil
+}
+
+type likeFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *likeFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	sig := &builtinLikeSig{newBaseBuiltinFunc(ctx, args, newBoolRetType())}
+	return sig, nil
+}
+
+type builtinLikeSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals `str LIKE pattern ESCAPE escape`, the escape is given as an integer.
+// See https://dev.mysql.com/doc/refman/5.7/en/string-comparison-functions.html#operator_like
+func (b *builtinLikeSig) eval(row chunk.Row) (types.Datum, error) {
+	args, hasNull, err := b.evalArgs(row)
+	if err != nil || hasNull {
+		return types.Datum{}, errors.Trace(err)
+	}
+	str, err := args[0].ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	pattern, err := args[1].ToString()
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	escape, err := args[2].ToInt64(b.ctx.GetSessionVars().StmtCtx)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	patChars, patTypes := stringutil.CompilePattern(pattern, byte(escape))
+	return boolToDatum(stringutil.DoMatch(str, patChars, patTypes)), nil
+}
@@ -0,0 +1,116 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/builtin_time.go
+//
+
+package expression
+
+import (
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// getStmtTimestamp returns the time the statement starts, so that the time
+// functions return the same value in a statement.
+func getStmtTimestamp(ctx sessionctx.Context) time.Time {
+	now := ctx.GetSessionVars().StmtCtx.NowTs
+	if now.IsZero() {
+		return time.Now()
+	}
+	return now
+}
+
+type nowFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *nowFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	fsp := 0
+	if len(args) == 1 {
+		con, ok := args[0].(*Constant)
+		if !ok {
+			return nil, errors.Trace(ErrIncorrectArgs.GenWithStackByArgs(c.funcName))
+		}
+		val, err := con.Value.ToInt64(ctx.GetSessionVars().StmtCtx)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if fsp, err = types.CheckFsp(int(val)); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	tp := newRetType(types.ETDatetime)
+	tp.Decimal = fsp
+	sig := &builtinNowSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinNowSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals NOW([fsp]).
+// See https://dev.mysql.com/doc/refman/5.7/en/date-and-time-functions.html#function_now
+func (b *builtinNowSig) eval(row chunk.Row) (types.Datum, error) {
+	t := types.Time{
+		Time: types.FromGoTime(getStmtTimestamp(b.ctx)),
+		Type: mysql.TypeDatetime,
+		Fsp:  types.MaxFsp,
+	}
+	t, err := t.RoundFrac(b.ctx.GetSessionVars().StmtCtx, b.tp.Decimal)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return types.NewTimeDatum(t), nil
+}
+
+type currentDateFunctionClass struct {
+	baseFunctionClass
+}
+
+func (c *currentDateFunctionClass) getFunction(ctx sessionctx.Context, args []Expression) (builtinFunc, error) {
+	if err := c.verifyArgs(args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := newRetType(types.ETDatetime)
+	tp.Tp = mysql.TypeDate
+	tp.Decimal = 0
+	sig := &builtinCurrentDateSig{newBaseBuiltinFunc(ctx, args, tp)}
+	return sig, nil
+}
+
+type builtinCurrentDateSig struct {
+	baseBuiltinFunc
+}
+
+// eval evals CURDATE().
+// See https://dev.mysql.com/doc/refman/5.7/en/date-and-time-functions.html#function_curdate
+func (b *builtinCurrentDateSig) eval(row chunk.Row) (types.Datum, error) {
+	year, month, day := getStmtTimestamp(b.ctx).Date()
+	t := types.Time{
+		Time: types.FromDate(year, int(month), day, 0, 0, 0, 0),
+		Type: mysql.TypeDate,
+		Fsp:  0,
+	}
+	return types.NewTimeDatum(t), nil
+}
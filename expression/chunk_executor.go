@@ -0,0 +1,181 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/chunk_executor.go
+//
+
+package expression
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// isInt64Layout checks whether the values of the type are stored as 8-byte integers in a chunk.
+func isInt64Layout(tp *types.FieldType) bool {
+	switch tp.Tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong, mysql.TypeYear:
+		return true
+	}
+	return false
+}
+
+func isSignedInt64Layout(tp *types.FieldType) bool {
+	return isInt64Layout(tp) && !mysql.HasUnsignedFlag(tp.Flag)
+}
+
+func isFloat64Layout(tp *types.FieldType) bool {
+	return tp.Tp == mysql.TypeDouble
+}
+
+func isStringLayout(tp *types.FieldType) bool {
+	switch tp.Tp {
+	case mysql.TypeVarchar, mysql.TypeVarString, mysql.TypeString,
+		mysql.TypeBlob, mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob:
+		return true
+	}
+	return false
+}
+
+// sameVecLayout checks whether the values of a vectorized function of type b can be
+// written to a column of type a.
+func sameVecLayout(a, b *types.FieldType) bool {
+	return (isInt64Layout(a) && isInt64Layout(b)) || (isFloat64Layout(a) && isFloat64Layout(b))
+}
+
+// datumFitsType checks whether the datum can be appended to a column of the type as it is.
+func datumFitsType(d types.Datum, tp *types.FieldType) bool {
+	switch d.Kind() {
+	case types.KindNull:
+		return true
+	case types.KindInt64, types.KindUint64:
+		return isInt64Layout(tp)
+	case types.KindFloat32:
+		return tp.Tp == mysql.TypeFloat
+	case types.KindFloat64:
+		return tp.Tp == mysql.TypeDouble
+	case types.KindMysqlDecimal:
+		return tp.Tp == mysql.TypeNewDecimal
+	case types.KindMysqlTime:
+		return tp.Tp == mysql.TypeDate || tp.Tp == mysql.TypeDatetime || tp.Tp == mysql.TypeTimestamp
+	case types.KindMysqlDuration:
+		return tp.Tp == mysql.TypeDuration
+	case types.KindMysqlEnum:
+		return tp.Tp == mysql.TypeEnum
+	case types.KindMysqlSet:
+		return tp.Tp == mysql.TypeSet
+	case types.KindMysqlJSON:
+		return tp.Tp == mysql.TypeJSON
+	case types.KindString, types.KindBytes, types.KindBinaryLiteral, types.KindMysqlBit:
+		return isStringLayout(tp) || tp.Tp == mysql.TypeBit || tp.Tp == mysql.TypeNull
+	}
+	return false
+}
+
+// FitDatum converts the datum to the type if it can not be appended to a column of the type as it is,
+// like the float64 value of a FLOAT function.
+func FitDatum(sc *stmtctx.StatementContext, d types.Datum, tp *types.FieldType) (types.Datum, error) {
+	if datumFitsType(d, tp) {
+		return d, nil
+	}
+	ret, err := d.ConvertTo(sc, tp)
+	return ret, errors.Trace(err)
+}
+
+// vecEvalByRow evaluates the expression row by row on the input chunk, it is used by the
+// functions which are not vectorized.
+func vecEvalByRow(sc *stmtctx.StatementContext, expr Expression, input *chunk.Chunk, result *chunk.Column) error {
+	result.Reset()
+	tp := expr.GetType()
+	for i := 0; i < input.NumRows(); i++ {
+		d, err := expr.Eval(input.GetRow(i))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if d, err = FitDatum(sc, d, tp); err != nil {
+			return errors.Trace(err)
+		}
+		result.AppendDatum(&d)
+	}
+	return nil
+}
+
+// VectorizedExecute evaluates the expressions on the input chunk, the values of the i-th
+// expression are written to the i-th column of output.
+func VectorizedExecute(exprs []Expression, input, output *chunk.Chunk) error {
+	for i, expr := range exprs {
+		if err := expr.VecEval(input, output.Column(i)); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// VectorizedFilter applies the CNF filters on the input chunk, selected[i] is true if the
+// i-th row satisfies all the filters. NULL is false as in EvalBool.
+func VectorizedFilter(ctx sessionctx.Context, filters []Expression, input *chunk.Chunk, selected []bool) ([]bool, error) {
+	n := input.NumRows()
+	selected = selected[:0]
+	for i := 0; i < n; i++ {
+		selected = append(selected, true)
+	}
+	for _, filter := range filters {
+		tp := filter.GetType()
+		if !isInt64Layout(tp) && !isFloat64Layout(tp) {
+			if err := filterByRow(ctx, filter, input, selected); err != nil {
+				return nil, errors.Trace(err)
+			}
+			continue
+		}
+		col := chunk.NewColumn(tp, n)
+		if err := filter.VecEval(input, col); err != nil {
+			// The error may come from the rows which are filtered out by the previous filters,
+			// EvalBool doesn't evaluate them.
+			if err = filterByRow(ctx, filter, input, selected); err != nil {
+				return nil, errors.Trace(err)
+			}
+			continue
+		}
+		if isInt64Layout(tp) {
+			vals := col.Int64s()
+			for i := range selected {
+				selected[i] = selected[i] && !col.IsNull(i) && vals[i] != 0
+			}
+			continue
+		}
+		vals := col.Float64s()
+		for i := range selected {
+			selected[i] = selected[i] && !col.IsNull(i) && types.RoundFloat(vals[i]) != 0
+		}
+	}
+	return selected, nil
+}
+
+func filterByRow(ctx sessionctx.Context, filter Expression, input *chunk.Chunk, selected []bool) error {
+	for i := range selected {
+		if !selected[i] {
+			continue
+		}
+		match, err := EvalBool(ctx, CNFExprs{filter}, input.GetRow(i))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		selected[i] = match
+	}
+	return nil
+}
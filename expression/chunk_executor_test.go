@@ -0,0 +1,317 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package expression
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/sessionctx/variable"
+	"fedb/util/chunk"
+)
+
+// benchContext is the sessionctx.Context of the benchmarks, the expressions only use its session vars.
+type benchContext struct {
+	sessionctx.Context
+	vars *variable.SessionVars
+}
+
+func (c *benchContext) GetSessionVars() *variable.SessionVars {
+	return c.vars
+}
+
+const benchChunkSize = 1024
+
+// newBenchInput builds a chunk of benchChunkSize rows with the columns (a bigint, b bigint, c double),
+// one in every 16 values of b is NULL.
+func newBenchInput() (*chunk.Chunk, []*Column) {
+	fields := []*types.FieldType{
+		types.NewFieldType(mysql.TypeLonglong),
+		types.NewFieldType(mysql.TypeLonglong),
+		types.NewFieldType(mysql.TypeDouble),
+	}
+	chk := chunk.NewChunkWithCapacity(fields, benchChunkSize)
+	for i := 0; i < benchChunkSize; i++ {
+		chk.AppendInt64(0, int64(i))
+		if i%16 == 0 {
+			chk.AppendNull(1)
+		} else {
+			chk.AppendInt64(1, int64(benchChunkSize-i))
+		}
+		chk.AppendFloat64(2, float64(i)/3)
+	}
+	cols := make([]*Column, len(fields))
+	for i, tp := range fields {
+		cols[i] = &Column{RetType: tp, Index: i, UniqueID: int64(i + 1)}
+	}
+	return chk, cols
+}
+
+func newBenchFunction(b testing.TB, ctx sessionctx.Context, name string, args ...Expression) Expression {
+	expr, err := NewFunction(ctx, name, types.NewFieldType(mysql.TypeUnspecified), args...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return expr
+}
+
+// newBenchProjection returns the expressions of SELECT a + b, a * 2, c * c, they are all vectorized.
+func newBenchProjection(b testing.TB) (*chunk.Chunk, []Expression) {
+	ctx := &benchContext{vars: variable.NewSessionVars()}
+	input, cols := newBenchInput()
+	two := NewConstant(types.NewIntDatum(2))
+	two.RetType = types.NewFieldType(mysql.TypeLonglong)
+	return input, []Expression{
+		newBenchFunction(b, ctx, ast.Plus, cols[0], cols[1]),
+		newBenchFunction(b, ctx, ast.Mul, cols[0], two),
+		newBenchFunction(b, ctx, ast.Mul, cols[2], cols[2]),
+	}
+}
+
+// newBenchFilters returns the CNF filters of WHERE a > 100 AND b < 800 AND c IS NOT NULL.
+func newBenchFilters(b testing.TB) (sessionctx.Context, *chunk.Chunk, []Expression) {
+	ctx := &benchContext{vars: variable.NewSessionVars()}
+	input, cols := newBenchInput()
+	newInt := func(v int64) *Constant {
+		c := NewConstant(types.NewIntDatum(v))
+		c.RetType = types.NewFieldType(mysql.TypeLonglong)
+		return c
+	}
+	return ctx, input, []Expression{
+		newBenchFunction(b, ctx, ast.GT, cols[0], newInt(100)),
+		newBenchFunction(b, ctx, ast.LT, cols[1], newInt(800)),
+		newBenchFunction(b, ctx, ast.UnaryNot, newBenchFunction(b, ctx, ast.IsNull, cols[2])),
+	}
+}
+
+func newBenchOutput(exprs []Expression) *chunk.Chunk {
+	fields := make([]*types.FieldType, len(exprs))
+	for i, expr := range exprs {
+		fields[i] = expr.GetType()
+	}
+	return chunk.NewChunkWithCapacity(fields, benchChunkSize)
+}
+
+func BenchmarkVectorizedExecute(b *testing.B) {
+	input, exprs := newBenchProjection(b)
+	output := newBenchOutput(exprs)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		output.Reset()
+		if err := VectorizedExecute(exprs, input, output); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowExecute(b *testing.B) {
+	input, exprs := newBenchProjection(b)
+	output := newBenchOutput(exprs)
+	sc := exprs[0].(*ScalarFunction).GetCtx().GetSessionVars().StmtCtx
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		output.Reset()
+		for j := 0; j < input.NumRows(); j++ {
+			row := input.GetRow(j)
+			for k, expr := range exprs {
+				d, err := expr.Eval(row)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if d, err = FitDatum(sc, d, expr.GetType()); err != nil {
+					b.Fatal(err)
+				}
+				output.AppendDatum(k, &d)
+			}
+		}
+	}
+}
+
+func BenchmarkVectorizedFilter(b *testing.B) {
+	ctx, input, filters := newBenchFilters(b)
+	selected := make([]bool, 0, benchChunkSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if selected, err = VectorizedFilter(ctx, filters, input, selected); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowFilter(b *testing.B) {
+	ctx, input, filters := newBenchFilters(b)
+	selected := make([]bool, 0, benchChunkSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		selected = selected[:0]
+		for j := 0; j < input.NumRows(); j++ {
+			match, err := EvalBool(ctx, filters, input.GetRow(j))
+			if err != nil {
+				b.Fatal(err)
+			}
+			selected = append(selected, match)
+		}
+	}
+}
+
+// newOverflowInput builds a chunk of the columns of newBenchInput whose a + b and a * 2
+// overflow BIGINT from the second row.
+func newOverflowInput() *chunk.Chunk {
+	chk := chunk.NewChunkWithCapacity([]*types.FieldType{
+		types.NewFieldType(mysql.TypeLonglong),
+		types.NewFieldType(mysql.TypeLonglong),
+		types.NewFieldType(mysql.TypeDouble),
+	}, 4)
+	for i := 0; i < 4; i++ {
+		chk.AppendInt64(0, math.MaxInt64/2+int64(i))
+		chk.AppendInt64(1, math.MaxInt64/2)
+		chk.AppendFloat64(2, float64(i))
+	}
+	return chk
+}
+
+// rowExecute evaluates the expressions row by row like the executors which are not vectorized.
+func rowExecute(exprs []Expression, input, output *chunk.Chunk) error {
+	sc := exprs[0].(*ScalarFunction).GetCtx().GetSessionVars().StmtCtx
+	for j := 0; j < input.NumRows(); j++ {
+		row := input.GetRow(j)
+		for k, expr := range exprs {
+			d, err := expr.Eval(row)
+			if err != nil {
+				return err
+			}
+			if d, err = FitDatum(sc, d, expr.GetType()); err != nil {
+				return err
+			}
+			output.AppendDatum(k, &d)
+		}
+	}
+	return nil
+}
+
+func checkSameError(t *testing.T, vecErr, rowErr error) {
+	if (vecErr == nil) != (rowErr == nil) {
+		t.Fatalf("vectorized error %v, row error %v", vecErr, rowErr)
+	}
+	if vecErr != nil && errors.Cause(vecErr).Error() != errors.Cause(rowErr).Error() {
+		t.Fatalf("vectorized error %v, row error %v", vecErr, rowErr)
+	}
+}
+
+// TestVectorizedExecute checks that the vectorized expressions return the values and the errors
+// of the row-at-a-time evaluation, the NULLs included.
+func TestVectorizedExecute(t *testing.T) {
+	input, exprs := newBenchProjection(t)
+	vecOutput, rowOutput := newBenchOutput(exprs), newBenchOutput(exprs)
+	vecErr := VectorizedExecute(exprs, input, vecOutput)
+	checkSameError(t, vecErr, rowExecute(exprs, input, rowOutput))
+	if vecErr != nil {
+		t.Fatal(vecErr)
+	}
+	sc := exprs[0].(*ScalarFunction).GetCtx().GetSessionVars().StmtCtx
+	if vecOutput.NumRows() != input.NumRows() || rowOutput.NumRows() != input.NumRows() {
+		t.Fatalf("expected %d rows, got %d and %d", input.NumRows(), vecOutput.NumRows(), rowOutput.NumRows())
+	}
+	var nulls int
+	for i := 0; i < input.NumRows(); i++ {
+		for k, expr := range exprs {
+			vec := vecOutput.GetRow(i).GetDatum(k, expr.GetType())
+			row := rowOutput.GetRow(i).GetDatum(k, expr.GetType())
+			if vec.IsNull() {
+				nulls++
+			}
+			c, err := vec.CompareDatum(sc, &row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != 0 || vec.IsNull() != row.IsNull() {
+				t.Fatalf("row %d, %s: vectorized %v, row %v", i, expr, vec, row)
+			}
+		}
+	}
+	if nulls != benchChunkSize/16 {
+		t.Fatalf("expected %d NULLs of a + b, got %d", benchChunkSize/16, nulls)
+	}
+
+	input = newOverflowInput()
+	for _, expr := range exprs[:2] {
+		exprs := []Expression{expr}
+		vecErr = VectorizedExecute(exprs, input, newBenchOutput(exprs))
+		if !ErrOverflow.Equal(errors.Cause(vecErr)) && !types.ErrOverflow.Equal(errors.Cause(vecErr)) {
+			t.Fatalf("%s: expected the overflow error, got %v", expr, vecErr)
+		}
+		checkSameError(t, vecErr, rowExecute(exprs, input, newBenchOutput(exprs)))
+	}
+}
+
+// TestVectorizedFilter checks that the vectorized filters select the rows of EvalBool and return
+// its errors, the filters are not evaluated on the rows which are filtered out.
+func TestVectorizedFilter(t *testing.T) {
+	ctx, input, filters := newBenchFilters(t)
+	_, cols := newBenchInput()
+	// a + b overflows on the overflow input and is NULL on the rows whose b is NULL.
+	plus := newBenchFunction(t, ctx, ast.GT,
+		newBenchFunction(t, ctx, ast.Plus, cols[0], cols[1]), NewConstant(types.NewIntDatum(0)))
+	tests := []struct {
+		input    *chunk.Chunk
+		filters  []Expression
+		expected int
+		overflow bool
+	}{
+		// b = 1024 - a, so a is in [225, 1023] and 49 of the values are the multiples of 16
+		// whose b is NULL.
+		{input, filters, 799 - 49, false},
+		{input, append(filters[:len(filters):len(filters)], plus), 799 - 49, false},
+		{input, []Expression{plus}, benchChunkSize - benchChunkSize/16, false},
+		// b < 800 filters out all the rows before a + b overflows.
+		{newOverflowInput(), append(filters[:len(filters):len(filters)], plus), 0, false},
+		{newOverflowInput(), []Expression{plus}, 0, true},
+	}
+	for i, tt := range tests {
+		selected, vecErr := VectorizedFilter(ctx, tt.filters, tt.input, nil)
+		var rowErr error
+		var matched int
+		for j := 0; j < tt.input.NumRows() && rowErr == nil; j++ {
+			var match bool
+			if match, rowErr = EvalBool(ctx, tt.filters, tt.input.GetRow(j)); rowErr == nil && vecErr == nil {
+				if match != selected[j] {
+					t.Fatalf("test %d, row %d: vectorized %v, row %v", i, j, selected[j], match)
+				}
+				if match {
+					matched++
+				}
+			}
+		}
+		checkSameError(t, vecErr, rowErr)
+		if tt.overflow {
+			if !ErrOverflow.Equal(errors.Cause(vecErr)) && !types.ErrOverflow.Equal(errors.Cause(vecErr)) {
+				t.Fatalf("test %d: expected the overflow error, got %v", i, vecErr)
+			}
+			continue
+		}
+		if vecErr != nil {
+			t.Fatalf("test %d: %v", i, vecErr)
+		}
+		if matched != tt.expected {
+			t.Fatalf("test %d: expected %d rows, got %d", i, tt.expected, matched)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/column.go
+//
+
+package expression
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/util/chunk"
+)
+
+// CorrelatedColumn stands for a column in a correlated sub query.
+type CorrelatedColumn struct {
+	Column
+
+	// Data is the value of the column in the row of the outer query, it is set before the sub query is evaluated.
+	Data *types.Datum
+}
+
+// Clone implements Expression interface.
+func (col *CorrelatedColumn) Clone() Expression {
+	return col
+}
+
+// Eval implements Expression interface.
+func (col *CorrelatedColumn) Eval(row chunk.Row) (types.Datum, error) {
+	return *col.Data, nil
+}
+
+// VecEval implements Expression interface.
+func (col *CorrelatedColumn) VecEval(input *chunk.Chunk, result *chunk.Column) error {
+	c := &Constant{Value: *col.Data, RetType: col.RetType}
+	return errors.Trace(c.VecEval(input, result))
+}
+
+// Equal implements Expression interface.
+func (col *CorrelatedColumn) Equal(expr Expression) bool {
+	if cc, ok := expr.(*CorrelatedColumn); ok {
+		return col.Column.Equal(&cc.Column)
+	}
+	return false
+}
+
+// Decorrelate implements Expression interface.
+func (col *CorrelatedColumn) Decorrelate(schema *Schema) Expression {
+	if !schema.Contains(&col.Column) {
+		return col
+	}
+	return &col.Column
+}
+
+// ResolveIndices implements Expression interface.
+func (col *CorrelatedColumn) ResolveIndices(_ *Schema) (Expression, error) {
+	return col, nil
+}
+
+func (col *CorrelatedColumn) resolveIndices(_ *Schema) error {
+	return nil
+}
+
+// Column represents a column.
+type Column struct {
+	OrigColName model.CIStr
+	ColName     model.CIStr
+	DBName      model.CIStr
+	OrigTblName model.CIStr
+	TblName     model.CIStr
+	RetType     *types.FieldType
+	// ID is used to specify whether this column is ExtraHandleColumn or to access histogram.
+	// We'll try to remove it in the future.
+	ID int64
+	// UniqueID is the unique id of this column.
+	UniqueID int64
+	// IsAggOrSubq means if this column is referenced to a Aggregation column or a Subquery column.
+	// If so, this column's name will be the plain sql text.
+	IsAggOrSubq bool
+
+	// Index is used for execution, to tell the column's position in the given row.
+	Index int
+}
+
+// Equal implements Expression interface.
+func (col *Column) Equal(expr Expression) bool {
+	if newCol, ok := expr.(*Column); ok {
+		return newCol.UniqueID == col.UniqueID
+	}
+	return false
+}
+
+// String implements Stringer interface.
+func (col *Column) String() string {
+	result := col.ColName.L
+	if col.TblName.L != "" {
+		result = col.TblName.L + "." + result
+	}
+	if col.DBName.L != "" {
+		result = col.DBName.L + "." + result
+	}
+	return result
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (col *Column) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%s\"", col)), nil
+}
+
+// GetType implements Expression interface.
+func (col *Column) GetType() *types.FieldType {
+	return col.RetType
+}
+
+// Eval implements Expression interface.
+func (col *Column) Eval(row chunk.Row) (types.Datum, error) {
+	return row.GetDatum(col.Index, col.RetType), nil
+}
+
+// VecEval implements Expression interface.
+func (col *Column) VecEval(input *chunk.Chunk, result *chunk.Column) error {
+	result.CopyFrom(input.Column(col.Index))
+	return nil
+}
+
+// Clone implements Expression interface.
+func (col *Column) Clone() Expression {
+	newCol := *col
+	return &newCol
+}
+
+// Decorrelate implements Expression interface.
+func (col *Column) Decorrelate(_ *Schema) Expression {
+	return col
+}
+
+// ResolveIndices implements Expression interface.
+func (col *Column) ResolveIndices(schema *Schema) (Expression, error) {
+	newCol := col.Clone()
+	err := newCol.resolveIndices(schema)
+	return newCol, errors.Trace(err)
+}
+
+func (col *Column) resolveIndices(schema *Schema) error {
+	col.Index = schema.ColumnIndex(col)
+	if col.Index == -1 {
+		return errors.Errorf("Can't find column %s in schema %s", col, schema)
+	}
+	return nil
+}
+
+// ColumnInfos2ColumnsWithDBName converts a slice of ColumnInfo to a slice of Column.
+func ColumnInfos2ColumnsWithDBName(dbName, tblName model.CIStr, colInfos []*model.ColumnInfo) []*Column {
+	columns := make([]*Column, 0, len(colInfos))
+	for _, col := range colInfos {
+		newCol := &Column{
+			ColName:     col.Name,
+			OrigColName: col.Name,
+			TblName:     tblName,
+			OrigTblName: tblName,
+			DBName:      dbName,
+			RetType:     &col.FieldType,
+			ID:          col.ID,
+			Index:       col.Offset,
+		}
+		columns = append(columns, newCol)
+	}
+	return columns
+}
+
+// Column2Exprs will transfer column slice to expression slice.
+func Column2Exprs(cols []*Column) []Expression {
+	result := make([]Expression, 0, len(cols))
+	for _, col := range cols {
+		result = append(result, col)
+	}
+	return result
+}
+
+// indexColumnNames returns the names of the columns for the error messages.
+func indexColumnNames(cols []*Column) string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.String())
+	}
+	return strings.Join(names, ",")
+}
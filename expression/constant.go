@@ -0,0 +1,146 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/constant.go
+//
+
+package expression
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/util/chunk"
+)
+
+var (
+	// One stands for a number 1.
+	One = &Constant{
+		Value:   types.NewDatum(1),
+		RetType: types.NewFieldType(mysql.TypeTiny),
+	}
+
+	// Zero stands for a number 0.
+	Zero = &Constant{
+		Value:   types.NewDatum(0),
+		RetType: types.NewFieldType(mysql.TypeTiny),
+	}
+
+	// Null stands for null constant.
+	Null = &Constant{
+		Value:   types.NewDatum(nil),
+		RetType: types.NewFieldType(mysql.TypeTiny),
+	}
+)
+
+// Constant stands for a constant value.
+type Constant struct {
+	Value   types.Datum
+	RetType *types.FieldType
+}
+
+// NewConstant creates a Constant of the value, its type is inferred from the value.
+func NewConstant(value types.Datum) *Constant {
+	ft := types.NewFieldType(mysql.TypeUnspecified)
+	types.DefaultTypeForValue(value.GetValue(), ft)
+	return &Constant{Value: value, RetType: ft}
+}
+
+// String implements fmt.Stringer interface.
+func (c *Constant) String() string {
+	return fmt.Sprintf("%v", c.Value.GetValue())
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (c *Constant) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%s\"", c)), nil
+}
+
+// Clone implements Expression interface.
+func (c *Constant) Clone() Expression {
+	con := *c
+	return &con
+}
+
+// GetType implements Expression interface.
+func (c *Constant) GetType() *types.FieldType {
+	return c.RetType
+}
+
+// Eval implements Expression interface.
+func (c *Constant) Eval(_ chunk.Row) (types.Datum, error) {
+	return c.Value, nil
+}
+
+// VecEval implements Expression interface.
+func (c *Constant) VecEval(input *chunk.Chunk, result *chunk.Column) error {
+	n := input.NumRows()
+	d, err := FitDatum(&stmtctx.StatementContext{}, c.Value, c.RetType)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	switch {
+	case !d.IsNull() && isInt64Layout(c.RetType):
+		result.ResizeInt64(n, false)
+		vals, v := result.Int64s(), d.GetInt64()
+		for i := range vals {
+			vals[i] = v
+		}
+	case !d.IsNull() && isFloat64Layout(c.RetType):
+		result.ResizeFloat64(n, false)
+		vals, v := result.Float64s(), d.GetFloat64()
+		for i := range vals {
+			vals[i] = v
+		}
+	default:
+		result.Reset()
+		for i := 0; i < n; i++ {
+			result.AppendDatum(&d)
+		}
+	}
+	return nil
+}
+
+// Equal implements Expression interface.
+func (c *Constant) Equal(b Expression) bool {
+	y, ok := b.(*Constant)
+	if !ok {
+		return false
+	}
+	if c.Value.Kind() != y.Value.Kind() {
+		return false
+	}
+	con, err := c.Value.CompareDatum(&stmtctx.StatementContext{}, &y.Value)
+	if err != nil || con != 0 {
+		return false
+	}
+	return true
+}
+
+// Decorrelate implements Expression interface.
+func (c *Constant) Decorrelate(_ *Schema) Expression {
+	return c
+}
+
+// ResolveIndices implements Expression interface.
+func (c *Constant) ResolveIndices(_ *Schema) (Expression, error) {
+	return c, nil
+}
+
+func (c *Constant) resolveIndices(_ *Schema) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/errors.go
+//
+
+package expression
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+)
+
+// Error codes.
+const (
+	codeAmbiguous               = mysql.ErrNonUniq
+	codeIncorrectParameterCount = mysql.ErrWrongParamcountToNativeFct
+	codeFunctionNotExists       = mysql.ErrSpDoesNotExist
+	codeDivisionByZero          = mysql.ErrDivisionByZero
+	codeOverflow                = mysql.ErrDataOutOfRange
+	codeIncorrectArgs           = mysql.ErrWrongArguments
+)
+
+var (
+	// ErrAmbiguous is the error when the column name is ambiguous.
+	ErrAmbiguous = terror.ClassExpression.New(codeAmbiguous, mysql.MySQLErrName[mysql.ErrNonUniq])
+	// ErrIncorrectParameterCount is the error when a function is called with a wrong number of arguments.
+	ErrIncorrectParameterCount = terror.ClassExpression.New(codeIncorrectParameterCount, mysql.MySQLErrName[mysql.ErrWrongParamcountToNativeFct])
+	// ErrFunctionNotExists is the error when a function does not exist.
+	ErrFunctionNotExists = terror.ClassExpression.New(codeFunctionNotExists, "FUNCTION %s does not exist")
+	// ErrDivisionByZero is the warning when a number is divided by zero.
+	ErrDivisionByZero = terror.ClassExpression.New(codeDivisionByZero, mysql.MySQLErrName[mysql.ErrDivisionByZero])
+	// ErrOverflow is the error when the result of an expression is out of range.
+	ErrOverflow = terror.ClassExpression.New(codeOverflow, mysql.MySQLErrName[mysql.ErrDataOutOfRange])
+	// ErrIncorrectArgs is the error when the arguments of a function are invalid.
+	ErrIncorrectArgs = terror.ClassExpression.New(codeIncorrectArgs, mysql.MySQLErrName[mysql.ErrWrongArguments])
+)
+
+func init() {
+	expressionMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeAmbiguous:               mysql.ErrNonUniq,
+		codeIncorrectParameterCount: mysql.ErrWrongParamcountToNativeFct,
+		codeFunctionNotExists:       mysql.ErrSpDoesNotExist,
+		codeDivisionByZero:          mysql.ErrDivisionByZero,
+		codeOverflow:                mysql.ErrDataOutOfRange,
+		codeIncorrectArgs:           mysql.ErrWrongArguments,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassExpression] = expressionMySQLErrCodes
+}
+
+// handleDivisionByZero records a warning for the division by zero, whose result is NULL.
+func handleDivisionByZero(ctx sessionctx.Context) (types.Datum, error) {
+	ctx.GetSessionVars().StmtCtx.AppendWarning(ErrDivisionByZero)
+	return types.Datum{}, nil
+}
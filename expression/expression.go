@@ -0,0 +1,239 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/expression.go
+//
+
+package expression
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// Expression represents all scalar expression in SQL.
+type Expression interface {
+	fmt.Stringer
+
+	// Eval evaluates an expression through a row.
+	Eval(row chunk.Row) (types.Datum, error)
+
+	// VecEval evaluates the expression on all the rows of the input chunk, the values are
+	// written to result, whose layout is of the return type of the expression.
+	VecEval(input *chunk.Chunk, result *chunk.Column) error
+
+	// GetType gets the type that the expression returns.
+	GetType() *types.FieldType
+
+	// Clone copies an expression totally.
+	Clone() Expression
+
+	// Equal checks whether two expressions are equal.
+	Equal(e Expression) bool
+
+	// Decorrelate try to decorrelate the expression by schema.
+	Decorrelate(schema *Schema) Expression
+
+	// ResolveIndices resolves indices by the given schema. It will copy the original expression and return the copied one.
+	ResolveIndices(schema *Schema) (Expression, error)
+
+	// resolveIndices is called inside the `ResolveIndices` It will perform on the expression itself.
+	resolveIndices(schema *Schema) error
+}
+
+// Assignment represents a set assignment in Update, such as
+// Update t set c1 = hex(12), c2 = c3 where c2 = 1
+type Assignment struct {
+	Col  *Column
+	Expr Expression
+}
+
+// CNFExprs stands for a CNF expression.
+type CNFExprs []Expression
+
+// Clone clones itself.
+func (e CNFExprs) Clone() CNFExprs {
+	cnf := make(CNFExprs, 0, len(e))
+	for _, expr := range e {
+		cnf = append(cnf, expr.Clone())
+	}
+	return cnf
+}
+
+// EvalBool evaluates expression list to a boolean value, NULL is false.
+func EvalBool(ctx sessionctx.Context, exprList CNFExprs, row chunk.Row) (bool, error) {
+	for _, expr := range exprList {
+		data, err := expr.Eval(row)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if data.IsNull() {
+			return false, nil
+		}
+
+		i, err := data.ToBool(ctx.GetSessionVars().StmtCtx)
+		if err != nil {
+			return false, errors.Trace(err)
+		}
+		if i == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// EvalBoolWithNull evaluates expression list to a boolean value in three-valued logic,
+// isNull is true if the result is unknown, that is, no expression is false and some are NULL.
+func EvalBoolWithNull(ctx sessionctx.Context, exprList CNFExprs, row chunk.Row) (result bool, isNull bool, err error) {
+	for _, expr := range exprList {
+		data, err := expr.Eval(row)
+		if err != nil {
+			return false, false, errors.Trace(err)
+		}
+		if data.IsNull() {
+			isNull = true
+			continue
+		}
+
+		i, err := data.ToBool(ctx.GetSessionVars().StmtCtx)
+		if err != nil {
+			return false, false, errors.Trace(err)
+		}
+		if i == 0 {
+			return false, false, nil
+		}
+	}
+	return !isNull, isNull, nil
+}
+
+// SplitCNFItems splits CNF items.
+// CNF means conjunctive normal form, e.g. "a and b and c".
+func SplitCNFItems(onExpr Expression) []Expression {
+	return splitNormalFormItems(onExpr, ast.LogicAnd)
+}
+
+// SplitDNFItems splits DNF items.
+// DNF means disjunctive normal form, e.g. "a or b or c".
+func SplitDNFItems(onExpr Expression) []Expression {
+	return splitNormalFormItems(onExpr, ast.LogicOr)
+}
+
+func splitNormalFormItems(onExpr Expression, funcName string) []Expression {
+	switch v := onExpr.(type) {
+	case *ScalarFunction:
+		if v.FuncName.L == funcName {
+			var ret []Expression
+			for _, arg := range v.GetArgs() {
+				ret = append(ret, splitNormalFormItems(arg, funcName)...)
+			}
+			return ret
+		}
+	}
+	return []Expression{onExpr}
+}
+
+// ComposeCNFCondition composes CNF items into a balance deep CNF tree, which benefits a lot for pb decoder/encoder.
+func ComposeCNFCondition(ctx sessionctx.Context, conditions ...Expression) Expression {
+	return composeConditionWithBinaryOp(ctx, conditions, ast.LogicAnd)
+}
+
+// ComposeDNFCondition composes DNF items into a balance deep DNF tree.
+func ComposeDNFCondition(ctx sessionctx.Context, conditions ...Expression) Expression {
+	return composeConditionWithBinaryOp(ctx, conditions, ast.LogicOr)
+}
+
+func composeConditionWithBinaryOp(ctx sessionctx.Context, conditions []Expression, funcName string) Expression {
+	length := len(conditions)
+	if length == 0 {
+		return nil
+	}
+	if length == 1 {
+		return conditions[0]
+	}
+	expr := NewFunctionInternal(ctx, funcName,
+		types.NewFieldType(mysql.TypeTiny),
+		composeConditionWithBinaryOp(ctx, conditions[:length/2], funcName),
+		composeConditionWithBinaryOp(ctx, conditions[length/2:], funcName))
+	return expr
+}
+
+// ExtractColumns extracts all columns from an expression.
+func ExtractColumns(expr Expression) (cols []*Column) {
+	// Pre-allocate a slice to reduce allocation, 8 doesn't have special meaning.
+	result := make([]*Column, 0, 8)
+	return extractColumns(result, expr, nil)
+}
+
+func extractColumns(result []*Column, expr Expression, filter func(*Column) bool) []*Column {
+	switch v := expr.(type) {
+	case *Column:
+		if filter == nil || filter(v) {
+			result = append(result, v)
+		}
+	case *ScalarFunction:
+		for _, arg := range v.GetArgs() {
+			result = extractColumns(result, arg, filter)
+		}
+	}
+	return result
+}
+
+// ExtractCorColumns extracts correlated column from given expression.
+func ExtractCorColumns(expr Expression) (cols []*CorrelatedColumn) {
+	switch v := expr.(type) {
+	case *CorrelatedColumn:
+		return []*CorrelatedColumn{v}
+	case *ScalarFunction:
+		for _, arg := range v.GetArgs() {
+			cols = append(cols, ExtractCorColumns(arg)...)
+		}
+	}
+	return
+}
+
+// Filter the input expressions, append the results to result.
+func Filter(result []Expression, input []Expression, filter func(Expression) bool) []Expression {
+	for _, e := range input {
+		if filter(e) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// ColumnSubstitute substitutes the columns in filter to expressions in select fields.
+// e.g. select * from (select b as a from t) k where a < 10 => select * from (select b as a from t where b < 10) k.
+func ColumnSubstitute(expr Expression, schema *Schema, newExprs []Expression) Expression {
+	switch v := expr.(type) {
+	case *Column:
+		id := schema.ColumnIndex(v)
+		if id == -1 {
+			return v
+		}
+		return newExprs[id]
+	case *ScalarFunction:
+		newArgs := make([]Expression, 0, len(v.GetArgs()))
+		for _, arg := range v.GetArgs() {
+			newArgs = append(newArgs, ColumnSubstitute(arg, schema, newExprs))
+		}
+		return NewFunctionInternal(v.GetCtx(), v.FuncName.L, v.RetType, newArgs...)
+	}
+	return expr
+}
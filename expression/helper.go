@@ -0,0 +1,75 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/helper.go
+//
+
+package expression
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+)
+
+// IsCurrentTimestampExpr returns whether e is CurrentTimestamp expression.
+func IsCurrentTimestampExpr(e ast.ExprNode) bool {
+	if fn, ok := e.(*ast.FuncCallExpr); ok && fn.FnName.L == ast.CurrentTimestamp {
+		return true
+	}
+	return false
+}
+
+// GetTimeValue gets the time value with type tp, v is the default value of a column
+// which may be CURRENT_TIMESTAMP.
+func GetTimeValue(ctx sessionctx.Context, v interface{}, tp byte, fsp int) (d types.Datum, err error) {
+	value := types.Time{
+		Type: tp,
+		Fsp:  fsp,
+	}
+	sc := ctx.GetSessionVars().StmtCtx
+	switch x := v.(type) {
+	case string:
+		upperX := strings.ToUpper(x)
+		if upperX == strings.ToUpper(ast.CurrentTimestamp) {
+			defaultTime := getStmtTimestamp(ctx)
+			value.Time = types.FromGoTime(defaultTime.Truncate(time.Duration(math.Pow10(9-fsp)) * time.Nanosecond))
+		} else if upperX == types.ZeroDatetimeStr {
+			value, err = types.ParseTimeFromNum(sc, 0, tp, fsp)
+			terror.Log(errors.Trace(err))
+		} else {
+			value, err = types.ParseTime(sc, x, tp, fsp)
+			if err != nil {
+				return d, errors.Trace(err)
+			}
+		}
+	case int64:
+		value, err = types.ParseTimeFromNum(sc, x, tp, fsp)
+		if err != nil {
+			return d, errors.Trace(err)
+		}
+	case nil:
+		return d, nil
+	default:
+		return d, errors.Errorf("invalid time value %v for type %s", v, types.TypeStr(tp))
+	}
+	d.SetMysqlTime(value)
+	return d, nil
+}
@@ -0,0 +1,212 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/scalar_function.go
+//
+
+package expression
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/sessionctx"
+	"fedb/util/chunk"
+)
+
+// ScalarFunction is the function that returns a value.
+type ScalarFunction struct {
+	FuncName model.CIStr
+	// RetType is the type that ScalarFunction returns.
+	RetType  *types.FieldType
+	Function builtinFunc
+}
+
+// GetArgs gets arguments of function.
+func (sf *ScalarFunction) GetArgs() []Expression {
+	return sf.Function.getArgs()
+}
+
+// GetCtx gets the context of function.
+func (sf *ScalarFunction) GetCtx() sessionctx.Context {
+	return sf.Function.getCtx()
+}
+
+// String implements fmt.Stringer interface.
+func (sf *ScalarFunction) String() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(sf.FuncName.L + "(")
+	for i, arg := range sf.GetArgs() {
+		buffer.WriteString(arg.String())
+		if i+1 != len(sf.GetArgs()) {
+			buffer.WriteString(", ")
+		}
+	}
+	buffer.WriteString(")")
+	return buffer.String()
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (sf *ScalarFunction) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%s\"", sf)), nil
+}
+
+// newFunctionImpl creates a new scalar function, the constant arguments are folded if fold is true.
+func newFunctionImpl(ctx sessionctx.Context, fold bool, funcName string, retType *types.FieldType, args ...Expression) (Expression, error) {
+	if retType == nil {
+		return nil, errors.Errorf("RetType cannot be nil for ScalarFunction.")
+	}
+	fc, ok := funcs[funcName]
+	if !ok {
+		return nil, ErrFunctionNotExists.GenWithStackByArgs(funcName)
+	}
+	funcArgs := make([]Expression, len(args))
+	copy(funcArgs, args)
+	f, err := fc.getFunction(ctx, funcArgs)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if retType.Tp == mysql.TypeUnspecified {
+		retType = f.getRetTp()
+	} else if sig, ok := f.(*builtinCastSig); ok {
+		// The target type of CAST is given by the caller.
+		sig.tp = retType
+	}
+	sf := &ScalarFunction{
+		FuncName: model.NewCIStr(funcName),
+		RetType:  retType,
+		Function: f,
+	}
+	if fold {
+		return FoldConstant(sf), nil
+	}
+	return sf, nil
+}
+
+// NewFunction creates a new scalar function or constant by folding the constant arguments.
+// The return type is inferred from the arguments if retType is of TypeUnspecified.
+func NewFunction(ctx sessionctx.Context, funcName string, retType *types.FieldType, args ...Expression) (Expression, error) {
+	return newFunctionImpl(ctx, true, funcName, retType, args...)
+}
+
+// NewFunctionInternal is similar to NewFunction, but do not returns error, should only be used internally.
+func NewFunctionInternal(ctx sessionctx.Context, funcName string, retType *types.FieldType, args ...Expression) Expression {
+	expr, err := NewFunction(ctx, funcName, retType, args...)
+	terror.Log(errors.Trace(err))
+	return expr
+}
+
+// Clone implements Expression interface.
+func (sf *ScalarFunction) Clone() Expression {
+	args := make([]Expression, 0, len(sf.GetArgs()))
+	for _, arg := range sf.GetArgs() {
+		args = append(args, arg.Clone())
+	}
+	newFunc, err := newFunctionImpl(sf.GetCtx(), false, sf.FuncName.L, sf.RetType, args...)
+	terror.Log(errors.Trace(err))
+	return newFunc
+}
+
+// GetType implements Expression interface.
+func (sf *ScalarFunction) GetType() *types.FieldType {
+	return sf.RetType
+}
+
+// Equal implements Expression interface.
+func (sf *ScalarFunction) Equal(e Expression) bool {
+	fun, ok := e.(*ScalarFunction)
+	if !ok {
+		return false
+	}
+	if sf.FuncName.L != fun.FuncName.L {
+		return false
+	}
+	args, funArgs := sf.GetArgs(), fun.GetArgs()
+	if len(args) != len(funArgs) {
+		return false
+	}
+	for i := range args {
+		if !args[i].Equal(funArgs[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval implements Expression interface.
+func (sf *ScalarFunction) Eval(row chunk.Row) (types.Datum, error) {
+	d, err := sf.Function.eval(row)
+	return d, errors.Trace(err)
+}
+
+// VecEval implements Expression interface.
+func (sf *ScalarFunction) VecEval(input *chunk.Chunk, result *chunk.Column) error {
+	if f, ok := sf.Function.(vecBuiltinFunc); ok && f.vectorized() && sameVecLayout(sf.RetType, sf.Function.getRetTp()) {
+		return errors.Trace(f.vecEval(input, result))
+	}
+	err := vecEvalByRow(sf.GetCtx().GetSessionVars().StmtCtx, sf, input, result)
+	return errors.Trace(err)
+}
+
+// Decorrelate implements Expression interface.
+func (sf *ScalarFunction) Decorrelate(schema *Schema) Expression {
+	args := sf.GetArgs()
+	for i := range args {
+		args[i] = args[i].Decorrelate(schema)
+	}
+	return sf
+}
+
+// ResolveIndices implements Expression interface.
+func (sf *ScalarFunction) ResolveIndices(schema *Schema) (Expression, error) {
+	newSf := sf.Clone()
+	err := newSf.resolveIndices(schema)
+	return newSf, errors.Trace(err)
+}
+
+func (sf *ScalarFunction) resolveIndices(schema *Schema) error {
+	for _, arg := range sf.GetArgs() {
+		err := arg.resolveIndices(schema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// FoldConstant does constant folding optimization on an expression, the scalar
+// function whose arguments are all constants is evaluated to a constant. A function
+// whose result is not determined by its arguments, like NOW(), is kept.
+func FoldConstant(expr Expression) Expression {
+	sf, ok := expr.(*ScalarFunction)
+	if !ok || unFoldableFunctions[sf.FuncName.L] {
+		return expr
+	}
+	for _, arg := range sf.GetArgs() {
+		if _, isCons := arg.(*Constant); !isCons {
+			return expr
+		}
+	}
+	value, err := sf.Eval(chunk.Row{})
+	if err != nil {
+		// The error is returned when the expression is evaluated at execution time.
+		return expr
+	}
+	return &Constant{Value: value, RetType: sf.RetType}
+}
@@ -0,0 +1,183 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/expression/schema.go
+//
+
+package expression
+
+import (
+	"bytes"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+)
+
+// Schema stands for the row schema and unique key information get from input.
+type Schema struct {
+	Columns []*Column
+}
+
+// String implements fmt.Stringer interface.
+func (s *Schema) String() string {
+	var buf bytes.Buffer
+	buf.WriteString("Column: [")
+	buf.WriteString(indexColumnNames(s.Columns))
+	buf.WriteString("]")
+	return buf.String()
+}
+
+// Clone copies the total schema.
+func (s *Schema) Clone() *Schema {
+	cols := make([]*Column, 0, s.Len())
+	for _, col := range s.Columns {
+		cols = append(cols, col.Clone().(*Column))
+	}
+	return NewSchema(cols...)
+}
+
+// ExprFromSchema checks if all columns of this expression are from the same schema.
+func ExprFromSchema(expr Expression, schema *Schema) bool {
+	switch v := expr.(type) {
+	case *Column:
+		return schema.Contains(v)
+	case *ScalarFunction:
+		for _, arg := range v.GetArgs() {
+			if !ExprFromSchema(arg, schema) {
+				return false
+			}
+		}
+		return true
+	case *Constant, *CorrelatedColumn:
+		return true
+	}
+	return false
+}
+
+// FindColumn finds an Column from schema for a ast.ColumnName. It compares the db/table/column names.
+// If there are more than one result, it will raise ambiguous error.
+func (s *Schema) FindColumn(astCol *ast.ColumnName) (*Column, error) {
+	col, _, err := s.FindColumnAndIndex(astCol)
+	return col, errors.Trace(err)
+}
+
+// FindColumnAndIndex finds an Column and its index from schema for a ast.ColumnName.
+// It compares the db/table/column names. If there are more than one result, raise ambiguous error.
+func (s *Schema) FindColumnAndIndex(astCol *ast.ColumnName) (*Column, int, error) {
+	dbName, tblName, colName := astCol.Schema, astCol.Table, astCol.Name
+	idx := -1
+	for i, col := range s.Columns {
+		if (dbName.L == "" || dbName.L == col.DBName.L) &&
+			(tblName.L == "" || tblName.L == col.TblName.L) &&
+			(colName.L == col.ColName.L) {
+			if idx == -1 {
+				idx = i
+			} else {
+				// For query like:
+				// create table t1(a int); create table t2(d int);
+				// select 1 from t1, t2 where 1 = (select d from t2 where a > 1) and d = 1;
+				// we will get an Apply operator whose schema is [test.t1.a, test.t2.d],
+				// and the outer schema of the nested query is [test.t1.a, test.t2.d];
+				// when we resolve column `d` in where clause, we would get ambiguous error
+				// if we do not check this.
+				if col.UniqueID == s.Columns[idx].UniqueID {
+					continue
+				}
+				return nil, -1, errors.Trace(ErrAmbiguous.GenWithStackByArgs(colName, clauseMsg[fieldList]))
+			}
+		}
+	}
+	if idx == -1 {
+		return nil, idx, nil
+	}
+	return s.Columns[idx], idx, nil
+}
+
+// RetrieveColumn retrieves column in expression from the columns in schema.
+func (s *Schema) RetrieveColumn(col *Column) *Column {
+	index := s.ColumnIndex(col)
+	if index != -1 {
+		return s.Columns[index]
+	}
+	return nil
+}
+
+// ColumnIndex finds the index for a column.
+func (s *Schema) ColumnIndex(col *Column) int {
+	for i, c := range s.Columns {
+		if c.UniqueID == col.UniqueID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Contains checks if the schema contains the column.
+func (s *Schema) Contains(col *Column) bool {
+	return s.ColumnIndex(col) != -1
+}
+
+// Len returns the number of columns in schema.
+func (s *Schema) Len() int {
+	return len(s.Columns)
+}
+
+// Append append new column to the columns stored in schema.
+func (s *Schema) Append(col ...*Column) {
+	s.Columns = append(s.Columns, col...)
+}
+
+// ColumnsIndices will return a slice which contains the position of each column in schema.
+// If there is one column that doesn't match, nil will be returned.
+func (s *Schema) ColumnsIndices(cols []*Column) (ret []int) {
+	ret = make([]int, 0, len(cols))
+	for _, col := range cols {
+		pos := s.ColumnIndex(col)
+		if pos != -1 {
+			ret = append(ret, pos)
+		} else {
+			return nil
+		}
+	}
+	return
+}
+
+// MergeSchema will merge two schema into one schema.
+func MergeSchema(lSchema, rSchema *Schema) *Schema {
+	if lSchema == nil && rSchema == nil {
+		return nil
+	}
+	if lSchema == nil {
+		return rSchema.Clone()
+	}
+	if rSchema == nil {
+		return lSchema.Clone()
+	}
+	tmpL := lSchema.Clone()
+	tmpR := rSchema.Clone()
+	ret := NewSchema(append(tmpL.Columns, tmpR.Columns...)...)
+	return ret
+}
+
+// NewSchema returns a schema made by its parameter.
+func NewSchema(cols ...*Column) *Schema {
+	return &Schema{Columns: cols}
+}
+
+const (
+	fieldList = iota + 1
+)
+
+var clauseMsg = map[int]string{
+	fieldList: "field list",
+}
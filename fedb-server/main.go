@@ -17,30 +17,78 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/pingcap/errors"
 	"github.com/pingcap/parser/terror"
 	log "github.com/sirupsen/logrus"
 
 	"fedb/config"
+	"fedb/domain"
+	"fedb/kv"
 	"fedb/server"
+	"fedb/session"
+	"fedb/store"
+	"fedb/store/localstore"
+	"fedb/store/localstore/boltdb"
+	"fedb/store/localstore/lsm"
+	"fedb/store/localstore/memory"
+	"fedb/store/localstore/native"
 
 	_ "github.com/pingcap/tidb/types/parser_driver"
 )
 
+// Flag Names
+const (
+	nmHost       = "host"
+	nmPort       = "P"
+	nmStore      = "store"
+	nmStorePath  = "path"
+	nmSync       = "sync"
+	nmDumpAST    = "dump-ast"
+	nmSkipGrant  = "skip-grant-table"
+	nmAuthPlugin = "default-auth-plugin"
+	nmSSLCA      = "ssl-ca"
+	nmSSLCert    = "ssl-cert"
+	nmSSLKey     = "ssl-key"
+	nmAutoTLS    = "auto-tls"
+)
+
+var (
+	host       = flag.String(nmHost, "127.0.0.1", "fedb server host")
+	port       = flag.Int(nmPort, 4444, "fedb server port")
+	storeName  = flag.String(nmStore, "memory", "registered store name, [memory, boltdb, native, lsm]")
+	storePath  = flag.String(nmStorePath, "/tmp/fedb", "fedb storage path")
+	syncLog    = flag.String(nmSync, "commit", "when the native and lsm stores sync the write-ahead log, [commit, interval, none]")
+	dumpAST    = flag.Bool(nmDumpAST, false, "log the AST of every statement")
+	skipGrant  = flag.Bool(nmSkipGrant, false, "accept every user without checking the password")
+	authPlugin = flag.String(nmAuthPlugin, "mysql_native_password", "default auth plugin, [mysql_native_password, caching_sha2_password]")
+	sslCA      = flag.String(nmSSLCA, "", "path of the CA certificate to verify the client certificates")
+	sslCert    = flag.String(nmSSLCert, "", "path of the server certificate for TLS")
+	sslKey     = flag.String(nmSSLKey, "", "path of the server private key for TLS")
+	autoTLS    = flag.Bool(nmAutoTLS, false, "enable TLS with a self-signed certificate if ssl-cert and ssl-key aren't given")
+)
+
 var (
 	cfg      *config.Config
+	storage  kv.Storage
+	dom      *domain.Domain
 	svr      *server.Server
 	graceful bool
 )
 
 func main() {
+	flag.Parse()
 	fmt.Println("Hello, FeDB !!")
 
+	registerStores()
 	loadConfig()
+	overrideConfig()
+	createStoreAndDomain()
 	createServer()
 	setupSignalHandler()
 	runServer()
@@ -48,13 +96,78 @@ func main() {
 	os.Exit(0)
 }
 
+func registerStores() {
+	err := store.Register("memory", localstore.Driver{Driver: memory.Driver{}})
+	terror.MustNil(err)
+	err = store.Register("boltdb", localstore.Driver{Driver: boltdb.Driver{}})
+	terror.MustNil(err)
+	err = store.Register("native", localstore.Driver{Driver: native.Driver{}})
+	terror.MustNil(err)
+	err = store.Register("lsm", localstore.Driver{Driver: lsm.Driver{}})
+	terror.MustNil(err)
+}
+
 func loadConfig() {
 	cfg = config.GetGlobalConfig()
 }
 
+// overrideConfig overrides the config with the flags set in the command line.
+func overrideConfig() {
+	actualFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		actualFlags[f.Name] = true
+	})
+
+	if actualFlags[nmHost] {
+		cfg.Host = *host
+	}
+	if actualFlags[nmPort] {
+		cfg.Port = *port
+	}
+	if actualFlags[nmStore] {
+		cfg.Store = *storeName
+	}
+	if actualFlags[nmStorePath] {
+		cfg.Path = *storePath
+	}
+	if actualFlags[nmSync] {
+		cfg.SyncPolicy = *syncLog
+	}
+	if actualFlags[nmDumpAST] {
+		cfg.DumpAST = *dumpAST
+	}
+	if actualFlags[nmSkipGrant] {
+		cfg.SkipGrantTable = *skipGrant
+	}
+	if actualFlags[nmAuthPlugin] {
+		cfg.DefaultAuthPlugin = *authPlugin
+	}
+	if actualFlags[nmSSLCA] {
+		cfg.SSLCA = *sslCA
+	}
+	if actualFlags[nmSSLCert] {
+		cfg.SSLCert = *sslCert
+	}
+	if actualFlags[nmSSLKey] {
+		cfg.SSLKey = *sslKey
+	}
+	if actualFlags[nmAutoTLS] {
+		cfg.AutoTLS = *autoTLS
+	}
+}
+
+func createStoreAndDomain() {
+	var err error
+	storage, err = store.New(fmt.Sprintf("%s://%s", cfg.Store, cfg.Path))
+	terror.MustNil(err)
+	// Bootstrap a session to load the schema.
+	dom, err = session.BootstrapSession(storage)
+	terror.MustNil(err)
+}
+
 func createServer() {
 	var driver server.IDriver
-	driver = server.NewFeDBDriver()
+	driver = server.NewFeDBDriver(storage)
 	var err error
 	svr, err = server.NewServer(cfg, driver)
 	terror.MustNil(err)
@@ -86,5 +199,7 @@ func cleanup() {
 	if graceful {
 		svr.GracefulDown()
 	}
-	//TODO storage.Close
+	dom.Close()
+	err := storage.Close()
+	terror.Log(errors.Trace(err))
 }
@@ -0,0 +1,104 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/infoschema/builder.go
+//
+
+package infoschema
+
+import (
+	"sort"
+
+	"github.com/pingcap/parser/model"
+
+	"fedb/meta/autoid"
+)
+
+// Builder builds a new InfoSchema.
+type Builder struct {
+	is     *infoSchema
+	handle *Handle
+	allocs map[allocKey]autoid.Allocator
+}
+
+// InitWithDBInfos initializes an empty new InfoSchema with a slice of DBInfo and schema version.
+func (b *Builder) InitWithDBInfos(dbInfos []*model.DBInfo, schemaVersion int64) *Builder {
+	info := b.is
+	info.schemaMetaVersion = schemaVersion
+	for _, di := range dbInfos {
+		b.createSchemaTablesForDB(di)
+	}
+	b.createSchemaTablesForInfoSchemaDB()
+	for _, v := range info.sortedTablesBuckets {
+		sort.Sort(v)
+	}
+	return b
+}
+
+func (b *Builder) createSchemaTablesForDB(di *model.DBInfo) {
+	schTbls := &schemaTables{
+		dbInfo: di,
+		tables: make(map[string]*model.TableInfo, len(di.Tables)),
+	}
+	b.is.schemaMap[di.Name.L] = schTbls
+	for _, t := range di.Tables {
+		schTbls.tables[t.Name.L] = t
+		key := allocKey{dbID: di.ID, tableID: t.ID}
+		alloc, ok := b.handle.allocs[key]
+		if !ok {
+			alloc = autoid.NewAllocator(b.handle.store, di.ID)
+		}
+		b.allocs[key] = alloc
+		b.is.allocs[t.ID] = alloc
+		bucketIdx := tableBucketIdx(t.ID)
+		b.is.sortedTablesBuckets[bucketIdx] = append(b.is.sortedTablesBuckets[bucketIdx], t)
+	}
+}
+
+// createSchemaTablesForInfoSchemaDB adds the information schema database, its tables have no
+// data in the storage, so no allocators are created for them.
+func (b *Builder) createSchemaTablesForInfoSchemaDB() {
+	schTbls := &schemaTables{
+		dbInfo: infoSchemaDB,
+		tables: make(map[string]*model.TableInfo, len(infoSchemaDB.Tables)),
+	}
+	b.is.schemaMap[infoSchemaDB.Name.L] = schTbls
+	for _, t := range infoSchemaDB.Tables {
+		schTbls.tables[t.Name.L] = t
+		bucketIdx := tableBucketIdx(t.ID)
+		b.is.sortedTablesBuckets[bucketIdx] = append(b.is.sortedTablesBuckets[bucketIdx], t)
+	}
+}
+
+// Build sets new InfoSchema to the handle in the Builder.
+func (b *Builder) Build() {
+	b.handle.allocs = b.allocs
+	b.handle.value.Store(b.is)
+}
+
+// NewBuilder creates a new Builder with a Handle.
+func NewBuilder(handle *Handle) *Builder {
+	b := new(Builder)
+	b.handle = handle
+	b.allocs = map[allocKey]autoid.Allocator{}
+	b.is = &infoSchema{
+		schemaMap:           map[string]*schemaTables{},
+		sortedTablesBuckets: make([]sortedTables, bucketCount),
+		allocs:              map[int64]autoid.Allocator{},
+	}
+	return b
+}
+
+func tableBucketIdx(tableID int64) int {
+	return int(tableID % bucketCount)
+}
@@ -0,0 +1,241 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/infoschema/tables.go
+//
+
+package infoschema
+
+import (
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/meta/autoid"
+)
+
+// Name is the name of the information schema database, its tables are generated from the
+// catalog and the sessions when they are read, nothing is stored.
+const Name = "INFORMATION_SCHEMA"
+
+// CatalogVal is the catalog name of all the databases.
+const CatalogVal = "def"
+
+// The tables of the information schema database.
+const (
+	TableSchemata         = "SCHEMATA"
+	TableTables           = "TABLES"
+	TableColumns          = "COLUMNS"
+	TableStatistics       = "STATISTICS"
+	TableKeyColumnUsage   = "KEY_COLUMN_USAGE"
+	TableProcesslist      = "PROCESSLIST"
+	TableSessionVariables = "SESSION_VARIABLES"
+)
+
+type columnInfo struct {
+	name string
+	tp   byte
+	size int
+	flag uint
+}
+
+func buildColumnInfo(col columnInfo) *model.ColumnInfo {
+	mCharset := charset.CharsetBin
+	mCollation := charset.CollationBin
+	mFlag := col.flag
+	if types.IsString(col.tp) {
+		mCharset = charset.CharsetUTF8MB4
+		mCollation = charset.CollationUTF8MB4
+	} else if types.IsTypeNumeric(col.tp) {
+		mFlag |= mysql.UnsignedFlag
+	}
+	fieldType := types.FieldType{
+		Charset: mCharset,
+		Collate: mCollation,
+		Tp:      col.tp,
+		Flen:    col.size,
+		Decimal: types.UnspecifiedLength,
+		Flag:    mFlag,
+	}
+	return &model.ColumnInfo{
+		ID:        autoid.GenLocalSchemaID(),
+		Name:      model.NewCIStr(col.name),
+		FieldType: fieldType,
+		State:     model.StatePublic,
+	}
+}
+
+func buildTableMeta(tableName string, cs []columnInfo) *model.TableInfo {
+	cols := make([]*model.ColumnInfo, 0, len(cs))
+	for i, c := range cs {
+		col := buildColumnInfo(c)
+		col.Offset = i
+		cols = append(cols, col)
+	}
+	return &model.TableInfo{
+		ID:      autoid.GenLocalSchemaID(),
+		Name:    model.NewCIStr(tableName),
+		Columns: cols,
+		State:   model.StatePublic,
+		Charset: mysql.DefaultCharset,
+		Collate: mysql.DefaultCollationName,
+	}
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/schemata-table.html
+var schemataCols = []columnInfo{
+	{"CATALOG_NAME", mysql.TypeVarchar, 512, 0},
+	{"SCHEMA_NAME", mysql.TypeVarchar, 64, 0},
+	{"DEFAULT_CHARACTER_SET_NAME", mysql.TypeVarchar, 64, 0},
+	{"DEFAULT_COLLATION_NAME", mysql.TypeVarchar, 32, 0},
+	{"SQL_PATH", mysql.TypeVarchar, 512, 0},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/tables-table.html
+var tablesCols = []columnInfo{
+	{"TABLE_CATALOG", mysql.TypeVarchar, 512, 0},
+	{"TABLE_SCHEMA", mysql.TypeVarchar, 64, 0},
+	{"TABLE_NAME", mysql.TypeVarchar, 64, 0},
+	{"TABLE_TYPE", mysql.TypeVarchar, 64, 0},
+	{"ENGINE", mysql.TypeVarchar, 64, 0},
+	{"VERSION", mysql.TypeLonglong, 21, 0},
+	{"ROW_FORMAT", mysql.TypeVarchar, 10, 0},
+	{"TABLE_ROWS", mysql.TypeLonglong, 21, 0},
+	{"AVG_ROW_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"DATA_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"MAX_DATA_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"INDEX_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"DATA_FREE", mysql.TypeLonglong, 21, 0},
+	{"AUTO_INCREMENT", mysql.TypeLonglong, 21, 0},
+	{"CREATE_TIME", mysql.TypeDatetime, 19, 0},
+	{"UPDATE_TIME", mysql.TypeDatetime, 19, 0},
+	{"CHECK_TIME", mysql.TypeDatetime, 19, 0},
+	{"TABLE_COLLATION", mysql.TypeVarchar, 32, mysql.NotNullFlag},
+	{"CHECKSUM", mysql.TypeLonglong, 21, 0},
+	{"CREATE_OPTIONS", mysql.TypeVarchar, 255, 0},
+	{"TABLE_COMMENT", mysql.TypeVarchar, 2048, 0},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/columns-table.html
+var columnsCols = []columnInfo{
+	{"TABLE_CATALOG", mysql.TypeVarchar, 512, 0},
+	{"TABLE_SCHEMA", mysql.TypeVarchar, 64, 0},
+	{"TABLE_NAME", mysql.TypeVarchar, 64, 0},
+	{"COLUMN_NAME", mysql.TypeVarchar, 64, 0},
+	{"ORDINAL_POSITION", mysql.TypeLonglong, 64, 0},
+	{"COLUMN_DEFAULT", mysql.TypeBlob, 196606, 0},
+	{"IS_NULLABLE", mysql.TypeVarchar, 3, 0},
+	{"DATA_TYPE", mysql.TypeVarchar, 64, 0},
+	{"CHARACTER_MAXIMUM_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"CHARACTER_OCTET_LENGTH", mysql.TypeLonglong, 21, 0},
+	{"NUMERIC_PRECISION", mysql.TypeLonglong, 21, 0},
+	{"NUMERIC_SCALE", mysql.TypeLonglong, 21, 0},
+	{"DATETIME_PRECISION", mysql.TypeLonglong, 21, 0},
+	{"CHARACTER_SET_NAME", mysql.TypeVarchar, 32, 0},
+	{"COLLATION_NAME", mysql.TypeVarchar, 32, 0},
+	{"COLUMN_TYPE", mysql.TypeBlob, 196606, 0},
+	{"COLUMN_KEY", mysql.TypeVarchar, 3, 0},
+	{"EXTRA", mysql.TypeVarchar, 30, 0},
+	{"PRIVILEGES", mysql.TypeVarchar, 80, 0},
+	{"COLUMN_COMMENT", mysql.TypeVarchar, 1024, 0},
+	{"GENERATION_EXPRESSION", mysql.TypeBlob, 589779, mysql.NotNullFlag},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/statistics-table.html
+var statisticsCols = []columnInfo{
+	{"TABLE_CATALOG", mysql.TypeVarchar, 512, 0},
+	{"TABLE_SCHEMA", mysql.TypeVarchar, 64, 0},
+	{"TABLE_NAME", mysql.TypeVarchar, 64, 0},
+	{"NON_UNIQUE", mysql.TypeVarchar, 1, 0},
+	{"INDEX_SCHEMA", mysql.TypeVarchar, 64, 0},
+	{"INDEX_NAME", mysql.TypeVarchar, 64, 0},
+	{"SEQ_IN_INDEX", mysql.TypeLonglong, 2, 0},
+	{"COLUMN_NAME", mysql.TypeVarchar, 21, 0},
+	{"COLLATION", mysql.TypeVarchar, 1, 0},
+	{"CARDINALITY", mysql.TypeLonglong, 21, 0},
+	{"SUB_PART", mysql.TypeLonglong, 3, 0},
+	{"PACKED", mysql.TypeVarchar, 10, 0},
+	{"NULLABLE", mysql.TypeVarchar, 3, 0},
+	{"INDEX_TYPE", mysql.TypeVarchar, 16, 0},
+	{"COMMENT", mysql.TypeVarchar, 16, 0},
+	{"INDEX_COMMENT", mysql.TypeVarchar, 1024, 0},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/key-column-usage-table.html
+var keyColumnUsageCols = []columnInfo{
+	{"CONSTRAINT_CATALOG", mysql.TypeVarchar, 512, mysql.NotNullFlag},
+	{"CONSTRAINT_SCHEMA", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"CONSTRAINT_NAME", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"TABLE_CATALOG", mysql.TypeVarchar, 512, mysql.NotNullFlag},
+	{"TABLE_SCHEMA", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"TABLE_NAME", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"COLUMN_NAME", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"ORDINAL_POSITION", mysql.TypeLonglong, 10, mysql.NotNullFlag},
+	{"POSITION_IN_UNIQUE_CONSTRAINT", mysql.TypeLonglong, 10, 0},
+	{"REFERENCED_TABLE_SCHEMA", mysql.TypeVarchar, 64, 0},
+	{"REFERENCED_TABLE_NAME", mysql.TypeVarchar, 64, 0},
+	{"REFERENCED_COLUMN_NAME", mysql.TypeVarchar, 64, 0},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/processlist-table.html
+var processlistCols = []columnInfo{
+	{"ID", mysql.TypeLonglong, 21, mysql.NotNullFlag},
+	{"USER", mysql.TypeVarchar, 32, mysql.NotNullFlag},
+	{"HOST", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"DB", mysql.TypeVarchar, 64, 0},
+	{"COMMAND", mysql.TypeVarchar, 16, mysql.NotNullFlag},
+	{"TIME", mysql.TypeLong, 7, mysql.NotNullFlag},
+	{"STATE", mysql.TypeVarchar, 64, 0},
+	{"INFO", mysql.TypeBlob, 196606, 0},
+}
+
+// See https://dev.mysql.com/doc/refman/5.7/en/variables-table.html
+var sessionVariablesCols = []columnInfo{
+	{"VARIABLE_NAME", mysql.TypeVarchar, 64, mysql.NotNullFlag},
+	{"VARIABLE_VALUE", mysql.TypeVarchar, 1024, 0},
+}
+
+var tableNameToColumns = map[string][]columnInfo{
+	TableSchemata:         schemataCols,
+	TableTables:           tablesCols,
+	TableColumns:          columnsCols,
+	TableStatistics:       statisticsCols,
+	TableKeyColumnUsage:   keyColumnUsageCols,
+	TableProcesslist:      processlistCols,
+	TableSessionVariables: sessionVariablesCols,
+}
+
+// infoSchemaDB is the information schema database, it's added to every InfoSchema.
+var infoSchemaDB *model.DBInfo
+
+func initInfoSchemaDB() {
+	dbID := autoid.GenLocalSchemaID()
+	infoSchemaTables := make([]*model.TableInfo, 0, len(tableNameToColumns))
+	for name, cols := range tableNameToColumns {
+		infoSchemaTables = append(infoSchemaTables, buildTableMeta(name, cols))
+	}
+	infoSchemaDB = &model.DBInfo{
+		ID:      dbID,
+		Name:    model.NewCIStr(Name),
+		Charset: mysql.DefaultCharset,
+		Collate: mysql.DefaultCollationName,
+		Tables:  infoSchemaTables,
+		State:   model.StatePublic,
+	}
+}
+
+// IsMemoryDB checks whether the database is a memory database, whose tables are read only.
+func IsMemoryDB(dbName string) bool {
+	return dbName == "information_schema"
+}
@@ -0,0 +1,126 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/kv/error.go
+//
+
+package kv
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+)
+
+// KV error codes.
+const (
+	codeClosed            terror.ErrCode = 1
+	codeNotExist                         = 2
+	codeConditionNotMatch                = 3
+	codeLockConflict                     = 4
+	codeRetryable                        = 6
+	codeCantSetNilValue                  = 7
+	codeInvalidTxn                       = 8
+	codeNotCommitted                     = 9
+	codeNotImplemented                   = 10
+	codeTxnTooLarge                      = 11
+	codeEntryTooLarge                    = 12
+	codeWriteConflict                    = 13
+	codeStoreClosed                      = 14
+	codeGCTooEarly                       = 15
+	codeLockWaitTimeout                  = 16
+	codeDeadlock                         = 17
+
+	codeKeyExists = 1062
+
+	// mysqlErrWriteConflict is the error code returned to the client for a write conflict, the same as TiDB.
+	mysqlErrWriteConflict = 9007
+)
+
+var (
+	// ErrClosed is used when close an already closed txn.
+	ErrClosed = terror.ClassKV.New(codeClosed, "Error: Transaction already closed")
+	// ErrNotExist is used when try to get an entry with an unexist key from KV store.
+	ErrNotExist = terror.ClassKV.New(codeNotExist, "Error: key not exist")
+	// ErrConditionNotMatch is used when condition is not met.
+	ErrConditionNotMatch = terror.ClassKV.New(codeConditionNotMatch, "Error: Condition not match")
+	// ErrLockConflict is used when try to lock an already locked key.
+	ErrLockConflict = terror.ClassKV.New(codeLockConflict, "Error: Lock conflict")
+	// ErrRetryable is used when KV store occurs some errors which SQL layer can safely retry.
+	ErrRetryable = terror.ClassKV.New(codeRetryable, "Error: KV error safe to retry")
+	// ErrCannotSetNilValue is the error when sets an empty value.
+	ErrCannotSetNilValue = terror.ClassKV.New(codeCantSetNilValue, "can not set nil value")
+	// ErrInvalidTxn is the error when commits or rollbacks in an invalid transaction.
+	ErrInvalidTxn = terror.ClassKV.New(codeInvalidTxn, "invalid transaction")
+	// ErrTxnTooLarge is the error when transaction is too large, lock time reached the maximum value.
+	ErrTxnTooLarge = terror.ClassKV.New(codeTxnTooLarge, "transaction is too large")
+	// ErrEntryTooLarge is the error when a key value entry is too large.
+	ErrEntryTooLarge = terror.ClassKV.New(codeEntryTooLarge, "entry is too large")
+	// ErrWriteConflict is the error when the keys written by the transaction are committed by others
+	// after the transaction started.
+	ErrWriteConflict = terror.ClassKV.New(codeWriteConflict, "write conflict")
+	// ErrStoreClosed is the error when the storage is used after closed.
+	ErrStoreClosed = terror.ClassKV.New(codeStoreClosed, "storage is closed")
+	// ErrGCTooEarly is the error when the versions read by a transaction may have been removed by GC.
+	ErrGCTooEarly = terror.ClassKV.New(codeGCTooEarly, "GC life time is shorter than transaction duration")
+	// ErrLockWaitTimeout is the error when a pessimistic transaction waits for a lock longer than the lock wait timeout.
+	ErrLockWaitTimeout = terror.ClassKV.New(codeLockWaitTimeout, mysql.MySQLErrName[mysql.ErrLockWaitTimeout])
+	// ErrDeadlock is the error when waiting for a lock would make a deadlock, the waiting transaction is the victim.
+	ErrDeadlock = terror.ClassKV.New(codeDeadlock, mysql.MySQLErrName[mysql.ErrLockDeadlock])
+
+	// ErrNotCommitted is the error returned by CommitVersion when this
+	// transaction is not committed.
+	ErrNotCommitted = terror.ClassKV.New(codeNotCommitted, "this transaction has not committed")
+
+	// ErrKeyExists returns when key is already exist.
+	ErrKeyExists = terror.ClassKV.New(codeKeyExists, "key already exist")
+	// ErrNotImplemented returns when a function is not implemented yet.
+	ErrNotImplemented = terror.ClassKV.New(codeNotImplemented, "not implemented")
+)
+
+func init() {
+	kvMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeKeyExists:       mysql.ErrDupEntry,
+		codeEntryTooLarge:   mysql.ErrTooBigRowsize,
+		codeTxnTooLarge:     mysql.ErrTxnTooLarge,
+		codeWriteConflict:   mysqlErrWriteConflict,
+		codeGCTooEarly:      mysql.ErrGCTooEarly,
+		codeLockWaitTimeout: mysql.ErrLockWaitTimeout,
+		codeDeadlock:        mysql.ErrLockDeadlock,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassKV] = kvMySQLErrCodes
+}
+
+// IsRetryableError checks if the err is a fatal error and the under going operation is worth to retry.
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if ErrRetryable.Equal(err) ||
+		ErrLockConflict.Equal(err) ||
+		ErrConditionNotMatch.Equal(err) ||
+		ErrWriteConflict.Equal(err) {
+		return true
+	}
+
+	return false
+}
+
+// IsErrNotFound checks if err is a kind of NotFound error.
+func IsErrNotFound(err error) bool {
+	if ErrNotExist.Equal(err) {
+		return true
+	}
+
+	return false
+}
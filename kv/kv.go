@@ -0,0 +1,157 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/kv/kv.go
+//
+
+package kv
+
+import (
+	goctx "golang.org/x/net/context"
+)
+
+// Those limits is enforced to make sure the transaction can be well handled by the storage.
+var (
+	// TxnEntrySizeLimit is limit of single entry size (len(key) + len(value)).
+	TxnEntrySizeLimit = 6 * 1024 * 1024
+	// TxnEntryCountLimit  is limit of number of entries in the MemBuffer.
+	TxnEntryCountLimit uint64 = 300 * 1000
+	// TxnTotalSizeLimit is limit of the sum of all entry size.
+	TxnTotalSizeLimit = 100 * 1024 * 1024
+)
+
+// Option is used for customizing kv store's behaviors during a transaction.
+type Option int
+
+// Transaction options
+const (
+	// Pessimistic makes the transaction lock the keys by LockKeys when it executes,
+	// a key locked by a transaction can not be locked or committed by others until
+	// the transaction finishes. The value is a bool.
+	Pessimistic Option = iota + 1
+	// LockWaitTimeout is the time a pessimistic transaction waits for a lock held by
+	// others, the value is a time.Duration.
+	LockWaitTimeout
+	// ForUpdateTS makes the transaction read the versions committed before the ts instead of
+	// its start ts, the statements which lock the keys in a pessimistic transaction read the
+	// latest values by it. The value is a uint64, 0 restores the start ts.
+	ForUpdateTS
+)
+
+// Retriever is the interface wraps the basic Get and Seek methods.
+type Retriever interface {
+	// Get gets the value for key k from kv store.
+	// If corresponding kv pair does not exist, it returns nil and ErrNotExist.
+	Get(k Key) ([]byte, error)
+	// Iter creates an Iterator positioned on the first entry that k <= entry's key.
+	// If such entry is not found, it returns an invalid Iterator with no error.
+	// It yields only keys that < upperBound. If upperBound is nil, it means the upperBound is unbounded.
+	// The Iterator must be Closed after use.
+	Iter(k Key, upperBound Key) (Iterator, error)
+}
+
+// Mutator is the interface wraps the basic Set and Delete methods.
+type Mutator interface {
+	// Set sets the value for key k as v into kv store.
+	// v must NOT be nil or empty, otherwise it returns ErrCannotSetNilValue.
+	Set(k Key, v []byte) error
+	// Delete removes the entry for key k from kv store.
+	Delete(k Key) error
+}
+
+// RetrieverMutator is the interface that groups Retriever and Mutator interfaces.
+type RetrieverMutator interface {
+	Retriever
+	Mutator
+}
+
+// MemBuffer is an in-memory kv collection, can be used to buffer write operations.
+type MemBuffer interface {
+	RetrieverMutator
+	// Size returns sum of keys and values length.
+	Size() int
+	// Len returns the number of entries in the DB.
+	Len() int
+	// Reset cleanup the MemBuffer
+	Reset()
+}
+
+// Transaction defines the interface for operations inside a Transaction.
+// This is not thread safe.
+type Transaction interface {
+	MemBuffer
+	// Commit commits the transaction operations to KV store.
+	Commit(goctx.Context) error
+	// Rollback undoes the transaction operations to KV store.
+	Rollback() error
+	// String implements fmt.Stringer interface.
+	String() string
+	// LockKeys tries to lock the entries with the keys in KV store.
+	// In a pessimistic transaction, it blocks until the locks are acquired, the
+	// lock wait times out or the goctx.Context is done.
+	LockKeys(goCtx goctx.Context, keys ...Key) error
+	// SetOption sets an option with a value, when val is nil, uses the default
+	// value of this option.
+	SetOption(opt Option, val interface{})
+	// IsReadOnly checks if the transaction has only performed read operations.
+	IsReadOnly() bool
+	// StartTS returns the transaction start timestamp.
+	StartTS() uint64
+	// Valid returns if the transaction is valid.
+	// A transaction become invalid after commit or rollback.
+	Valid() bool
+	// GetMemBuffer return the MemBuffer binding to this transaction.
+	GetMemBuffer() MemBuffer
+	// GetSnapshot returns the snapshot of this transaction.
+	GetSnapshot() Snapshot
+}
+
+// Snapshot defines the interface for the snapshot fetched from KV store.
+type Snapshot interface {
+	Retriever
+	// BatchGet gets a batch of values from snapshot.
+	BatchGet(keys []Key) (map[string][]byte, error)
+}
+
+// Driver is the interface that must be implemented by a KV storage.
+type Driver interface {
+	// Open returns a new Storage.
+	// The path is the string for storage specific format.
+	Open(path string) (Storage, error)
+}
+
+// Storage defines the interface for storage.
+type Storage interface {
+	// Begin transaction
+	Begin() (Transaction, error)
+	// BeginWithStartTS begins transaction with startTS.
+	BeginWithStartTS(startTS uint64) (Transaction, error)
+	// GetSnapshot gets a snapshot that is able to read any data which data is <= ver.
+	// if ver is MaxVersion or > current max committed version, we will use current version for this snapshot.
+	GetSnapshot(ver Version) (Snapshot, error)
+	// Close store
+	Close() error
+	// UUID return a unique ID which represents a Storage.
+	UUID() string
+	// CurrentVersion returns current max committed version.
+	CurrentVersion() (Version, error)
+}
+
+// Iterator is the interface for a iterator on KV store.
+type Iterator interface {
+	Valid() bool
+	Key() Key
+	Value() []byte
+	Next() error
+	Close()
+}
@@ -0,0 +1,202 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/meta/autoid/autoid.go
+//
+
+package autoid
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/kv"
+	"fedb/meta"
+)
+
+// Error instances.
+var (
+	errInvalidTableID = terror.ClassAutoid.New(codeInvalidTableID, "invalid TableID")
+
+	// ErrAutoincReadFailed returns when the auto-increment IDs are used up.
+	ErrAutoincReadFailed = terror.ClassAutoid.New(codeAutoincReadFailed, mysql.MySQLErrName[mysql.ErrAutoincReadFailed])
+)
+
+// step is the number of IDs a server takes from the storage at a time, the IDs
+// which are not allocated before the server exits are skipped.
+var step = int64(30000)
+
+// Allocator is an auto increment id generator.
+// Just keep id unique actually.
+type Allocator interface {
+	// Alloc allocs the next autoID for table with tableID, it is the smallest value of
+	// offset + N*increment greater than the allocated ones. The IDs are taken from the
+	// batch cached in the allocator, the storage is accessed only if it is used up.
+	Alloc(tableID int64, increment, offset int64) (int64, error)
+	// Rebase rebases the autoID base for table with tableID, the IDs allocated later
+	// are greater than newBase. It does nothing if newBase is not greater than the base.
+	Rebase(tableID, newBase int64) error
+	// Base returns the largest allocated ID of the allocator.
+	Base() int64
+}
+
+type allocator struct {
+	mu sync.Mutex
+	// base is the largest allocated ID, the IDs in (base, end] are cached, end is
+	// persisted in the storage before they are allocated, so that no ID is allocated
+	// twice even if the server crashes.
+	base  int64
+	end   int64
+	store kv.Storage
+	// dbID is current database's ID.
+	dbID int64
+}
+
+// Alloc implements autoid.Allocator Alloc interface.
+func (alloc *allocator) Alloc(tableID int64, increment, offset int64) (int64, error) {
+	if tableID == 0 {
+		return 0, errInvalidTableID.GenWithStack("Invalid tableID")
+	}
+	if increment < 1 {
+		increment = 1
+	}
+	// The offset is ignored if it is greater than the increment, like MySQL.
+	if offset < 1 || offset > increment {
+		offset = 1
+	}
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+
+	if alloc.base > math.MaxInt64-increment {
+		return 0, ErrAutoincReadFailed
+	}
+	id := seekToFirstAutoID(alloc.base, increment, offset)
+	if id > alloc.end {
+		// Take the increment more than a batch, there is at least one ID of the sequence in it.
+		n := step + increment
+		var newEnd int64
+		err := kv.RunInNewTxn(alloc.store, true, func(txn kv.Transaction) error {
+			m := meta.NewMeta(txn)
+			currentEnd, err1 := m.GetAutoTableID(alloc.dbID, tableID)
+			if err1 != nil {
+				return errors.Trace(err1)
+			}
+			if currentEnd > math.MaxInt64-n {
+				return ErrAutoincReadFailed
+			}
+			newEnd, err1 = m.GenAutoTableID(alloc.dbID, tableID, n)
+			return errors.Trace(err1)
+		})
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		log.Debugf("[kv] Alloc ids (%d, %d], table ID:%d, database ID:%d", newEnd-n, newEnd, tableID, alloc.dbID)
+		alloc.base, alloc.end = newEnd-n, newEnd
+		id = seekToFirstAutoID(alloc.base, increment, offset)
+	}
+	alloc.base = id
+	return id, nil
+}
+
+// Rebase implements autoid.Allocator Rebase interface.
+func (alloc *allocator) Rebase(tableID, newBase int64) error {
+	if tableID == 0 {
+		return errInvalidTableID.GenWithStack("Invalid tableID")
+	}
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+
+	if newBase <= alloc.base {
+		return nil
+	}
+	if newBase <= alloc.end {
+		alloc.base = newBase
+		return nil
+	}
+	// Take a new batch after newBase, or after the IDs taken by the other servers.
+	var base, end int64
+	err := kv.RunInNewTxn(alloc.store, true, func(txn kv.Transaction) error {
+		m := meta.NewMeta(txn)
+		currentEnd, err1 := m.GetAutoTableID(alloc.dbID, tableID)
+		if err1 != nil {
+			return errors.Trace(err1)
+		}
+		base = newBase
+		if currentEnd > base {
+			base = currentEnd
+		}
+		end = base + step
+		if base > math.MaxInt64-step {
+			end = math.MaxInt64
+		}
+		_, err1 = m.GenAutoTableID(alloc.dbID, tableID, end-currentEnd)
+		return errors.Trace(err1)
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Debugf("[kv] Rebase ids (%d, %d], table ID:%d, database ID:%d", base, end, tableID, alloc.dbID)
+	alloc.base, alloc.end = base, end
+	return nil
+}
+
+// Base implements autoid.Allocator Base interface.
+func (alloc *allocator) Base() int64 {
+	alloc.mu.Lock()
+	defer alloc.mu.Unlock()
+	return alloc.base
+}
+
+// seekToFirstAutoID returns the smallest value of offset + N*increment greater than base,
+// base is never negative and offset is not greater than increment.
+func seekToFirstAutoID(base, increment, offset int64) int64 {
+	nr := (base + increment - offset) / increment
+	return nr*increment + offset
+}
+
+// localSchemaID is the last ID of the schema objects which are not persisted, they are
+// allocated downwards from the max int64, so they never collide with the IDs in the storage.
+var localSchemaID = int64(math.MaxInt64)
+
+// GenLocalSchemaID generates an ID for a database, a table or a column of the memory databases.
+func GenLocalSchemaID() int64 {
+	return atomic.AddInt64(&localSchemaID, -1)
+}
+
+// NewAllocator returns a new auto increment id generator on the store.
+func NewAllocator(store kv.Storage, dbID int64) Allocator {
+	return &allocator{
+		store: store,
+		dbID:  dbID,
+	}
+}
+
+// autoid error codes.
+const (
+	codeInvalidTableID    terror.ErrCode = 1
+	codeAutoincReadFailed terror.ErrCode = 1467
+)
+
+func init() {
+	// Map error codes to mysql error codes.
+	autoidMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeAutoincReadFailed: mysql.ErrAutoincReadFailed,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassAutoid] = autoidMySQLErrCodes
+}
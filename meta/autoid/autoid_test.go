@@ -0,0 +1,203 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package autoid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pingcap/parser/model"
+
+	"fedb/kv"
+	"fedb/meta"
+	"fedb/store/localstore"
+	"fedb/store/localstore/native"
+)
+
+const (
+	testDBID    = 1
+	testTableID = 2
+)
+
+func openStore(t *testing.T, dir string) kv.Storage {
+	s, err := localstore.Driver{Driver: native.Driver{}}.Open("native://" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestStore opens a store in a new directory with the table of testTableID, step is
+// small so that the allocators take several batches.
+func newTestStore(t *testing.T) (kv.Storage, string) {
+	dir, err := ioutil.TempDir("", "autoid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origStep := step
+	step = 10
+	t.Cleanup(func() {
+		step = origStep
+		os.RemoveAll(dir)
+	})
+	s := openStore(t, filepath.Join(dir, "db"))
+	err = kv.RunInNewTxn(s, false, func(txn kv.Transaction) error {
+		m := meta.NewMeta(txn)
+		if err1 := m.CreateDatabase(&model.DBInfo{ID: testDBID, Name: model.NewCIStr("d")}); err1 != nil {
+			return err1
+		}
+		return m.CreateTable(testDBID, &model.TableInfo{ID: testTableID, Name: model.NewCIStr("t")})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, dir
+}
+
+// copyDir copies the files of the store while it is open, which is what a crash leaves.
+func copyDir(t *testing.T, src string, dst string) {
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustAlloc allocates n IDs and checks that they are greater than last and are in the
+// sequence of increment and offset, it returns the last one.
+func mustAlloc(t *testing.T, alloc Allocator, n int, last, increment, offset int64) int64 {
+	for i := 0; i < n; i++ {
+		id, err := alloc.Alloc(testTableID, increment, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id <= last {
+			t.Fatalf("allocated %d after %d", id, last)
+		}
+		if id%increment != offset%increment {
+			t.Fatalf("allocated %d, not in the sequence of increment %d offset %d", id, increment, offset)
+		}
+		last = id
+	}
+	return last
+}
+
+func TestAllocAfterRestart(t *testing.T) {
+	s, dir := newTestStore(t)
+	last := mustAlloc(t, NewAllocator(s, testDBID), 25, 0, 1, 1)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The IDs cached by the allocator before the restart are skipped, not reused.
+	s = openStore(t, filepath.Join(dir, "db"))
+	alloc := NewAllocator(s, testDBID)
+	last = mustAlloc(t, alloc, 1, last, 1, 1)
+	// A batch is step + increment IDs, the three batches before the restart end at 33.
+	if last != 34 {
+		t.Fatalf("expected the first ID of the next batch 34, got %d", last)
+	}
+	if err := alloc.Rebase(testTableID, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, filepath.Join(dir, "db"))
+	defer s.Close()
+	mustAlloc(t, NewAllocator(s, testDBID), 25, 1000, 1, 1)
+}
+
+func TestAllocAfterCrash(t *testing.T) {
+	s, dir := newTestStore(t)
+	defer s.Close()
+	alloc := NewAllocator(s, testDBID)
+	var last int64
+	for i := 0; i < 5; i++ {
+		last = mustAlloc(t, alloc, 7, last, 1, 1)
+		crashed := filepath.Join(dir, "crash", string(rune('a'+i)))
+		copyDir(t, filepath.Join(dir, "db"), crashed)
+		// Every ID allocated before the crash is covered by the end persisted with its batch.
+		cs := openStore(t, crashed)
+		mustAlloc(t, NewAllocator(cs, testDBID), 20, last, 1, 1)
+		if err := cs.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAllocIncrementOffset(t *testing.T) {
+	tests := []struct {
+		increment, offset int64
+		// first is the first ID of the sequence, the sequence continues after a restart.
+		first int64
+	}{
+		{1, 1, 1},
+		{5, 1, 1},
+		{5, 3, 3},
+		{10, 10, 10},
+		{7, 0, 1},
+		// The offset greater than the increment is ignored like MySQL.
+		{3, 5, 1},
+		{0, 0, 1},
+		// The increment larger than a batch.
+		{25, 2, 2},
+	}
+	for _, tt := range tests {
+		s, dir := newTestStore(t)
+		alloc := NewAllocator(s, testDBID)
+		first, err := alloc.Alloc(testTableID, tt.increment, tt.offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != tt.first {
+			t.Fatalf("increment %d offset %d: expected the first ID %d, got %d", tt.increment, tt.offset, tt.first, first)
+		}
+		increment, offset := tt.increment, tt.first
+		if increment < 1 {
+			increment = 1
+		}
+		last := mustAlloc(t, alloc, 30, first, increment, offset)
+		if err = s.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		s = openStore(t, filepath.Join(dir, "db"))
+		alloc = NewAllocator(s, testDBID)
+		last = mustAlloc(t, alloc, 30, last, increment, offset)
+		// The sequence changed by the session variables starts after the allocated IDs.
+		mustAlloc(t, alloc, 30, last, 4, 2)
+		if err = s.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package parser
+
+import (
+	"strings"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+)
+
+// extStmt is embedded in the extension statements and implements the methods of
+// ast.StmtNode except Accept, which every statement implements itself. The marker
+// method of ast.StmtNode is unexported, so it is taken from the embedded
+// ast.CommitStmt, whose other methods are all shadowed and never called.
+type extStmt struct {
+	ast.CommitStmt
+	text string
+}
+
+// Text implements Node interface.
+func (n *extStmt) Text() string {
+	return n.text
+}
+
+// SetText implements Node interface.
+func (n *extStmt) SetText(text string) {
+	n.text = text
+}
+
+// BeginStmt is a statement to start a new transaction with the characteristics
+// which ast.BeginStmt doesn't have.
+// See https://dev.mysql.com/doc/refman/5.7/en/commit.html
+type BeginStmt struct {
+	extStmt
+
+	ReadOnly  bool
+	ReadWrite bool
+}
+
+// Accept implements Node Accept interface.
+func (n *BeginStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// SavepointStmt is a statement to set a named savepoint in the transaction.
+// See https://dev.mysql.com/doc/refman/5.7/en/savepoint.html
+type SavepointStmt struct {
+	extStmt
+
+	Name string
+}
+
+// Accept implements Node Accept interface.
+func (n *SavepointStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// RollbackToStmt is a statement to roll back the transaction to a savepoint.
+type RollbackToStmt struct {
+	extStmt
+
+	Name string
+}
+
+// Accept implements Node Accept interface.
+func (n *RollbackToStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// ReleaseSavepointStmt is a statement to remove a savepoint.
+type ReleaseSavepointStmt struct {
+	extStmt
+
+	Name string
+}
+
+// Accept implements Node Accept interface.
+func (n *ReleaseSavepointStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// UserSpec is a user and its auth option of CREATE USER and ALTER USER, with the
+// auth plugin which ast.UserSpec doesn't keep.
+type UserSpec struct {
+	User *auth.UserIdentity
+	// AuthPlugin is the plugin of IDENTIFIED WITH, it's empty if it isn't given.
+	AuthPlugin string
+	// AuthOpt is the password or its hash, it's nil if neither is given.
+	AuthOpt *ast.AuthOption
+}
+
+// secureText returns the text of the spec with the password hidden.
+func (spec *UserSpec) secureText() string {
+	text := spec.User.String()
+	if spec.AuthPlugin == "" && spec.AuthOpt == nil {
+		return text
+	}
+	text += " IDENTIFIED"
+	if spec.AuthPlugin != "" {
+		text += " WITH " + spec.AuthPlugin
+	}
+	if spec.AuthOpt != nil {
+		text += " BY <secret>"
+	}
+	return text
+}
+
+func secureSpecs(specs []*UserSpec) string {
+	texts := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		texts = append(texts, spec.secureText())
+	}
+	return strings.Join(texts, ", ")
+}
+
+// The TLS options of REQUIRE in CREATE USER and ALTER USER.
+const (
+	RequireNone = "NONE"
+	RequireSSL  = "SSL"
+	RequireX509 = "X509"
+)
+
+// CreateUserStmt is a statement to create the users.
+// See https://dev.mysql.com/doc/refman/8.0/en/create-user.html
+type CreateUserStmt struct {
+	extStmt
+
+	IfNotExists bool
+	Specs       []*UserSpec
+	// Require is the TLS option of the users, it's empty if REQUIRE isn't given.
+	Require string
+}
+
+// Accept implements Node Accept interface.
+func (n *CreateUserStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// SecureText implements SensitiveStmtNode interface.
+func (n *CreateUserStmt) SecureText() string {
+	return "create user " + secureSpecs(n.Specs)
+}
+
+// AlterUserStmt is a statement to change the users, CurrentAuth is the password
+// of ALTER USER USER().
+// See https://dev.mysql.com/doc/refman/8.0/en/alter-user.html
+type AlterUserStmt struct {
+	extStmt
+
+	IfExists    bool
+	CurrentAuth *ast.AuthOption
+	Specs       []*UserSpec
+	// Require is the TLS option of the users, it's empty if REQUIRE isn't given.
+	Require string
+}
+
+// Accept implements Node Accept interface.
+func (n *AlterUserStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// SecureText implements SensitiveStmtNode interface.
+func (n *AlterUserStmt) SecureText() string {
+	if n.CurrentAuth != nil {
+		return "alter user user() identified by <secret>"
+	}
+	return "alter user " + secureSpecs(n.Specs)
+}
+
+// CreateRoleStmt is a statement to create the roles.
+// See https://dev.mysql.com/doc/refman/8.0/en/create-role.html
+type CreateRoleStmt struct {
+	extStmt
+
+	IfNotExists bool
+	Roles       []*auth.UserIdentity
+}
+
+// Accept implements Node Accept interface.
+func (n *CreateRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// DropRoleStmt is a statement to drop the roles.
+// See https://dev.mysql.com/doc/refman/8.0/en/drop-role.html
+type DropRoleStmt struct {
+	extStmt
+
+	IfExists bool
+	Roles    []*auth.UserIdentity
+}
+
+// Accept implements Node Accept interface.
+func (n *DropRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// GrantRoleStmt is a statement to grant the roles to the users, the users may be roles too.
+// See https://dev.mysql.com/doc/refman/8.0/en/grant.html
+type GrantRoleStmt struct {
+	extStmt
+
+	Roles           []*auth.UserIdentity
+	Users           []*auth.UserIdentity
+	WithAdminOption bool
+}
+
+// Accept implements Node Accept interface.
+func (n *GrantRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// RevokeRoleStmt is a statement to revoke the roles from the users.
+// See https://dev.mysql.com/doc/refman/8.0/en/revoke.html
+type RevokeRoleStmt struct {
+	extStmt
+
+	Roles []*auth.UserIdentity
+	Users []*auth.UserIdentity
+}
+
+// Accept implements Node Accept interface.
+func (n *RevokeRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// SetRoleType is the kind of the roles of SET ROLE and SET DEFAULT ROLE.
+type SetRoleType int
+
+// The kinds of the roles, SET DEFAULT ROLE doesn't accept DEFAULT and ALL EXCEPT.
+const (
+	SetRoleDefault SetRoleType = iota
+	SetRoleNone
+	SetRoleAll
+	SetRoleAllExcept
+	SetRoleList
+)
+
+// SetRoleStmt is a statement to activate the roles in the session.
+// See https://dev.mysql.com/doc/refman/8.0/en/set-role.html
+type SetRoleStmt struct {
+	extStmt
+
+	Tp SetRoleType
+	// Roles are the activated ones for SetRoleList, and the excepted ones for SetRoleAllExcept.
+	Roles []*auth.UserIdentity
+}
+
+// Accept implements Node Accept interface.
+func (n *SetRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
+
+// SetDefaultRoleStmt is a statement to set the roles activated when the users log in.
+// See https://dev.mysql.com/doc/refman/8.0/en/set-default-role.html
+type SetDefaultRoleStmt struct {
+	extStmt
+
+	Tp    SetRoleType
+	Roles []*auth.UserIdentity
+	Users []*auth.UserIdentity
+}
+
+// Accept implements Node Accept interface.
+func (n *SetDefaultRoleStmt) Accept(v ast.Visitor) (ast.Node, bool) {
+	newNode, _ := v.Enter(n)
+	return v.Leave(newNode)
+}
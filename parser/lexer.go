@@ -0,0 +1,226 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package parser
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+type tokenType int
+
+const (
+	tokIdent tokenType = iota
+	tokQuotedIdent
+	tokString
+	tokSymbol
+)
+
+type token struct {
+	tp tokenType
+	s  string
+}
+
+// lexer splits the SQL text into tokens for the extension rules.
+type lexer struct {
+	tokens []token
+	pos    int
+}
+
+var errLex = errors.New("invalid token")
+
+func newLexer(sql string) (*lexer, error) {
+	l := &lexer{}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || c == '-' && strings.HasPrefix(sql[i:], "-- "):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, errLex
+			}
+			i += end + 4
+		case isIdentChar(c):
+			start := i
+			for i < len(sql) && isIdentChar(sql[i]) {
+				i++
+			}
+			l.tokens = append(l.tokens, token{tp: tokIdent, s: sql[start:i]})
+		case c == '`' || c == '\'' || c == '"':
+			s, n, err := scanQuoted(sql[i:], c)
+			if err != nil {
+				return nil, err
+			}
+			tp := tokString
+			if c == '`' {
+				tp = tokQuotedIdent
+			}
+			l.tokens = append(l.tokens, token{tp: tp, s: s})
+			i += n
+		default:
+			l.tokens = append(l.tokens, token{tp: tokSymbol, s: sql[i : i+1]})
+			i++
+		}
+	}
+	// Ignore the trailing semicolons.
+	for len(l.tokens) > 0 && l.tokens[len(l.tokens)-1] == (token{tp: tokSymbol, s: ";"}) {
+		l.tokens = l.tokens[:len(l.tokens)-1]
+	}
+	return l, nil
+}
+
+// splitStatements splits the SQL text into the texts of its statements by the semicolons
+// which are not in the quotes or the comments. The empty statements are skipped.
+func splitStatements(sql string) ([]string, error) {
+	var stmts []string
+	start := 0
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '#' || c == '-' && strings.HasPrefix(sql[i:], "-- "):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, errLex
+			}
+			i += end + 4
+		case c == '`' || c == '\'' || c == '"':
+			_, n, err := scanQuoted(sql[i:], c)
+			if err != nil {
+				return nil, err
+			}
+			i += n
+		case c == ';':
+			if stmt := strings.TrimSpace(sql[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			i++
+			start = i
+		default:
+			i++
+		}
+	}
+	if stmt := strings.TrimSpace(sql[start:]); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+// scanQuoted scans a quoted string or identifier, the quote is escaped by doubling it,
+// and the backslash escapes are supported in the strings.
+func scanQuoted(s string, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i++
+				continue
+			}
+			return b.String(), i + 1, nil
+		case c == '\\' && quote != '`' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, errLex
+}
+
+func (l *lexer) peek() (token, bool) {
+	if l.pos >= len(l.tokens) {
+		return token{}, false
+	}
+	return l.tokens[l.pos], true
+}
+
+func (l *lexer) end() bool {
+	return l.pos >= len(l.tokens)
+}
+
+// acceptKeyword consumes the next token if it is the keyword.
+func (l *lexer) acceptKeyword(keyword string) bool {
+	t, ok := l.peek()
+	if !ok || t.tp != tokIdent || !strings.EqualFold(t.s, keyword) {
+		return false
+	}
+	l.pos++
+	return true
+}
+
+// acceptSymbol consumes the next token if it is the symbol.
+func (l *lexer) acceptSymbol(symbol string) bool {
+	t, ok := l.peek()
+	if !ok || t.tp != tokSymbol || t.s != symbol {
+		return false
+	}
+	l.pos++
+	return true
+}
+
+// ident consumes an identifier, quoted or not.
+func (l *lexer) ident() (string, bool) {
+	t, ok := l.peek()
+	if !ok || t.tp != tokIdent && t.tp != tokQuotedIdent {
+		return "", false
+	}
+	l.pos++
+	return t.s, true
+}
+
+// str consumes a string literal.
+func (l *lexer) str() (string, bool) {
+	t, ok := l.peek()
+	if !ok || t.tp != tokString {
+		return "", false
+	}
+	l.pos++
+	return t.s, true
+}
+
+// name consumes a name which may be an identifier or a string, such as a user name.
+func (l *lexer) name() (string, bool) {
+	if s, ok := l.str(); ok {
+		return s, true
+	}
+	return l.ident()
+}
@@ -0,0 +1,515 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package parser wraps the pingcap parser with the extension rules for the
+// statements it doesn't support yet, such as SAVEPOINT and the role statements.
+package parser
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+)
+
+// Parser parses the SQL text into statements.
+type Parser struct {
+	*parser.Parser
+}
+
+// New creates a Parser.
+func New() *Parser {
+	return &Parser{Parser: parser.New()}
+}
+
+// Parse parses a query string to raw ast.StmtNode. When the pingcap parser fails,
+// the query is split into statements, and each of them which the pingcap parser
+// fails too is parsed by the extension rules. The error of the pingcap parser is
+// returned if none of the rules matches a statement.
+func (p *Parser) Parse(sql, charset, collation string) ([]ast.StmtNode, error) {
+	stmts, err := p.parse(sql, charset, collation)
+	if err == nil {
+		return stmts, nil
+	}
+	texts, err1 := splitStatements(sql)
+	if err1 != nil {
+		return nil, errors.Trace(err)
+	}
+	stmts = nil
+	for _, text := range texts {
+		// A single statement is parsed by the pingcap parser already.
+		if len(texts) > 1 {
+			stmtsOfText, err1 := p.parse(text, charset, collation)
+			if err1 == nil {
+				stmts = append(stmts, stmtsOfText...)
+				continue
+			}
+			err = err1
+		}
+		stmt, err1 := parseExtension(text)
+		if err1 != nil {
+			return nil, errors.Trace(err1)
+		}
+		if stmt == nil {
+			return nil, errors.Trace(err)
+		}
+		stmt.SetText(text)
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+// parse parses the query by the pingcap parser, and converts the user statements.
+func (p *Parser) parse(sql, charset, collation string) ([]ast.StmtNode, error) {
+	stmts, err := p.Parser.Parse(sql, charset, collation)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i, stmt := range stmts {
+		stmts[i] = convertUserStmt(stmt)
+	}
+	return stmts, nil
+}
+
+// parseExtension parses the statement by the extension rules, it returns nil if none of them matches.
+func parseExtension(sql string) (ast.StmtNode, error) {
+	l, err := newLexer(sql)
+	if err != nil {
+		return nil, nil
+	}
+	var stmt ast.StmtNode
+	switch {
+	case l.acceptKeyword("SAVEPOINT"):
+		stmt = parseSavepoint(l)
+	case l.acceptKeyword("RELEASE"):
+		stmt = parseReleaseSavepoint(l)
+	case l.acceptKeyword("ROLLBACK"):
+		stmt = parseRollbackTo(l)
+	case l.acceptKeyword("START"):
+		stmt = parseStartTransaction(l)
+	case l.acceptKeyword("CREATE"):
+		if stmt = parseCreateUser(l); stmt == nil {
+			stmt = parseCreateRole(l)
+		}
+	case l.acceptKeyword("ALTER"):
+		stmt = parseAlterUser(l)
+	case l.acceptKeyword("DROP"):
+		stmt = parseDropRole(l)
+	case l.acceptKeyword("GRANT"):
+		stmt = parseGrantRole(l)
+	case l.acceptKeyword("REVOKE"):
+		stmt = parseRevokeRole(l)
+	case l.acceptKeyword("SET"):
+		stmt = parseSetRole(l)
+	}
+	if stmt == nil || !l.end() {
+		return nil, nil
+	}
+	return stmt, nil
+}
+
+// SAVEPOINT identifier
+func parseSavepoint(l *lexer) ast.StmtNode {
+	name, ok := l.ident()
+	if !ok {
+		return nil
+	}
+	return &SavepointStmt{Name: name}
+}
+
+// RELEASE SAVEPOINT identifier
+func parseReleaseSavepoint(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("SAVEPOINT") {
+		return nil
+	}
+	name, ok := l.ident()
+	if !ok {
+		return nil
+	}
+	return &ReleaseSavepointStmt{Name: name}
+}
+
+// ROLLBACK [WORK] TO [SAVEPOINT] identifier
+func parseRollbackTo(l *lexer) ast.StmtNode {
+	l.acceptKeyword("WORK")
+	if !l.acceptKeyword("TO") {
+		return nil
+	}
+	l.acceptKeyword("SAVEPOINT")
+	name, ok := l.ident()
+	if !ok {
+		return nil
+	}
+	return &RollbackToStmt{Name: name}
+}
+
+// START TRANSACTION [transaction_characteristic [, transaction_characteristic] ...]
+//
+// transaction_characteristic: WITH CONSISTENT SNAPSHOT | READ WRITE | READ ONLY
+func parseStartTransaction(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("TRANSACTION") {
+		return nil
+	}
+	stmt := &BeginStmt{}
+	if l.end() {
+		return stmt
+	}
+	for {
+		switch {
+		case l.acceptKeyword("WITH"):
+			if !l.acceptKeyword("CONSISTENT") || !l.acceptKeyword("SNAPSHOT") {
+				return nil
+			}
+		case l.acceptKeyword("READ"):
+			switch {
+			case l.acceptKeyword("ONLY"):
+				stmt.ReadOnly = true
+			case l.acceptKeyword("WRITE"):
+				stmt.ReadWrite = true
+			default:
+				return nil
+			}
+		default:
+			return nil
+		}
+		if !l.acceptSymbol(",") {
+			break
+		}
+	}
+	if stmt.ReadOnly && stmt.ReadWrite {
+		return nil
+	}
+	return stmt
+}
+
+// convertUserStmt converts CREATE USER and ALTER USER to the extension statements, as the auth
+// plugins are dropped by the pingcap parser. They are parsed again by the extension rules, and
+// converted without the plugins if the rules don't match.
+func convertUserStmt(stmt ast.StmtNode) ast.StmtNode {
+	var ext ast.StmtNode
+	switch x := stmt.(type) {
+	case *ast.CreateUserStmt:
+		if ext, _ = parseExtension(x.Text()); ext == nil {
+			ext = &CreateUserStmt{IfNotExists: x.IfNotExists, Specs: convertUserSpecs(x.Specs)}
+		}
+	case *ast.AlterUserStmt:
+		if ext, _ = parseExtension(x.Text()); ext == nil {
+			ext = &AlterUserStmt{IfExists: x.IfExists, CurrentAuth: x.CurrentAuth, Specs: convertUserSpecs(x.Specs)}
+		}
+	default:
+		return stmt
+	}
+	ext.SetText(stmt.Text())
+	return ext
+}
+
+func convertUserSpecs(specs []*ast.UserSpec) []*UserSpec {
+	converted := make([]*UserSpec, 0, len(specs))
+	for _, spec := range specs {
+		converted = append(converted, &UserSpec{User: spec.User, AuthOpt: spec.AuthOpt})
+	}
+	return converted
+}
+
+// CREATE USER [IF NOT EXISTS] user [auth_option] [, user [auth_option]] ...
+// [REQUIRE {NONE | SSL | X509}]
+func parseCreateUser(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("USER") {
+		return nil
+	}
+	stmt := &CreateUserStmt{}
+	if l.acceptKeyword("IF") {
+		if !l.acceptKeyword("NOT") || !l.acceptKeyword("EXISTS") {
+			return nil
+		}
+		stmt.IfNotExists = true
+	}
+	if stmt.Specs = parseUserSpecs(l); stmt.Specs == nil {
+		return nil
+	}
+	var ok bool
+	if stmt.Require, ok = parseRequire(l); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// ALTER USER [IF EXISTS] user [auth_option] [, user [auth_option]] ...
+// [REQUIRE {NONE | SSL | X509}]
+// ALTER USER USER() IDENTIFIED BY 'auth_string'
+func parseAlterUser(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("USER") {
+		return nil
+	}
+	stmt := &AlterUserStmt{}
+	if l.acceptKeyword("IF") {
+		if !l.acceptKeyword("EXISTS") {
+			return nil
+		}
+		stmt.IfExists = true
+	}
+	if l.acceptKeyword("USER") {
+		if !l.acceptSymbol("(") || !l.acceptSymbol(")") || !l.acceptKeyword("IDENTIFIED") || !l.acceptKeyword("BY") {
+			return nil
+		}
+		pwd, ok := l.str()
+		if !ok {
+			return nil
+		}
+		stmt.CurrentAuth = &ast.AuthOption{ByAuthString: true, AuthString: pwd}
+		return stmt
+	}
+	if stmt.Specs = parseUserSpecs(l); stmt.Specs == nil {
+		return nil
+	}
+	var ok bool
+	if stmt.Require, ok = parseRequire(l); !ok {
+		return nil
+	}
+	return stmt
+}
+
+// [REQUIRE {NONE | SSL | X509}]
+func parseRequire(l *lexer) (string, bool) {
+	if !l.acceptKeyword("REQUIRE") {
+		return "", true
+	}
+	for _, require := range []string{RequireNone, RequireSSL, RequireX509} {
+		if l.acceptKeyword(require) {
+			return require, true
+		}
+	}
+	return "", false
+}
+
+// user [auth_option] [, user [auth_option]] ...
+func parseUserSpecs(l *lexer) []*UserSpec {
+	var specs []*UserSpec
+	for {
+		user := parseUser(l)
+		if user == nil {
+			return nil
+		}
+		spec := &UserSpec{User: user}
+		if l.acceptKeyword("IDENTIFIED") && !parseAuthOption(l, spec) {
+			return nil
+		}
+		specs = append(specs, spec)
+		if !l.acceptSymbol(",") {
+			return specs
+		}
+	}
+}
+
+// CREATE ROLE [IF NOT EXISTS] role [, role] ...
+func parseCreateRole(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("ROLE") {
+		return nil
+	}
+	stmt := &CreateRoleStmt{}
+	if l.acceptKeyword("IF") {
+		if !l.acceptKeyword("NOT") || !l.acceptKeyword("EXISTS") {
+			return nil
+		}
+		stmt.IfNotExists = true
+	}
+	if stmt.Roles = parseRoles(l); stmt.Roles == nil {
+		return nil
+	}
+	return stmt
+}
+
+// DROP ROLE [IF EXISTS] role [, role] ...
+func parseDropRole(l *lexer) ast.StmtNode {
+	if !l.acceptKeyword("ROLE") {
+		return nil
+	}
+	stmt := &DropRoleStmt{}
+	if l.acceptKeyword("IF") {
+		if !l.acceptKeyword("EXISTS") {
+			return nil
+		}
+		stmt.IfExists = true
+	}
+	if stmt.Roles = parseRoles(l); stmt.Roles == nil {
+		return nil
+	}
+	return stmt
+}
+
+// GRANT role [, role] ... TO user [, user] ... [WITH ADMIN OPTION]
+func parseGrantRole(l *lexer) ast.StmtNode {
+	stmt := &GrantRoleStmt{}
+	if stmt.Roles = parseRoles(l); stmt.Roles == nil || !l.acceptKeyword("TO") {
+		return nil
+	}
+	if stmt.Users = parseUsers(l); stmt.Users == nil {
+		return nil
+	}
+	if l.acceptKeyword("WITH") {
+		if !l.acceptKeyword("ADMIN") || !l.acceptKeyword("OPTION") {
+			return nil
+		}
+		stmt.WithAdminOption = true
+	}
+	return stmt
+}
+
+// REVOKE role [, role] ... FROM user [, user] ...
+func parseRevokeRole(l *lexer) ast.StmtNode {
+	stmt := &RevokeRoleStmt{}
+	if stmt.Roles = parseRoles(l); stmt.Roles == nil || !l.acceptKeyword("FROM") {
+		return nil
+	}
+	if stmt.Users = parseUsers(l); stmt.Users == nil {
+		return nil
+	}
+	return stmt
+}
+
+// SET ROLE {DEFAULT | NONE | ALL | ALL EXCEPT role [, role] ... | role [, role] ...}
+// SET DEFAULT ROLE {NONE | ALL | role [, role] ...} TO user [, user] ...
+func parseSetRole(l *lexer) ast.StmtNode {
+	if l.acceptKeyword("DEFAULT") {
+		if !l.acceptKeyword("ROLE") {
+			return nil
+		}
+		stmt := &SetDefaultRoleStmt{}
+		switch {
+		case l.acceptKeyword("NONE"):
+			stmt.Tp = SetRoleNone
+		case l.acceptKeyword("ALL"):
+			stmt.Tp = SetRoleAll
+		default:
+			stmt.Tp = SetRoleList
+			if stmt.Roles = parseRoles(l); stmt.Roles == nil {
+				return nil
+			}
+		}
+		if !l.acceptKeyword("TO") {
+			return nil
+		}
+		if stmt.Users = parseUsers(l); stmt.Users == nil {
+			return nil
+		}
+		return stmt
+	}
+	if !l.acceptKeyword("ROLE") {
+		return nil
+	}
+	stmt := &SetRoleStmt{}
+	switch {
+	case l.acceptKeyword("DEFAULT"):
+		stmt.Tp = SetRoleDefault
+	case l.acceptKeyword("NONE"):
+		stmt.Tp = SetRoleNone
+	case l.acceptKeyword("ALL"):
+		stmt.Tp = SetRoleAll
+		if l.acceptKeyword("EXCEPT") {
+			stmt.Tp = SetRoleAllExcept
+			if stmt.Roles = parseRoles(l); stmt.Roles == nil {
+				return nil
+			}
+		}
+	default:
+		stmt.Tp = SetRoleList
+		if stmt.Roles = parseRoles(l); stmt.Roles == nil {
+			return nil
+		}
+	}
+	return stmt
+}
+
+// role [, role] ..., a role is named like a user, but it can't be CURRENT_USER.
+func parseRoles(l *lexer) []*auth.UserIdentity {
+	users := parseUsers(l)
+	for _, user := range users {
+		if user.CurrentUser {
+			return nil
+		}
+	}
+	return users
+}
+
+// user [, user] ...
+func parseUsers(l *lexer) []*auth.UserIdentity {
+	var users []*auth.UserIdentity
+	for {
+		user := parseUser(l)
+		if user == nil {
+			return nil
+		}
+		users = append(users, user)
+		if !l.acceptSymbol(",") {
+			return users
+		}
+	}
+}
+
+// user_name[@host_name] | CURRENT_USER[()]
+func parseUser(l *lexer) *auth.UserIdentity {
+	if l.acceptKeyword("CURRENT_USER") {
+		if l.acceptSymbol("(") && !l.acceptSymbol(")") {
+			return nil
+		}
+		return &auth.UserIdentity{CurrentUser: true}
+	}
+	name, ok := l.name()
+	if !ok {
+		return nil
+	}
+	user := &auth.UserIdentity{Username: name, Hostname: "%"}
+	if l.acceptSymbol("@") {
+		if user.Hostname, ok = l.name(); !ok {
+			return nil
+		}
+	}
+	return user
+}
+
+// IDENTIFIED BY 'auth_string' | IDENTIFIED BY PASSWORD 'hash_string'
+// | IDENTIFIED WITH auth_plugin [BY 'auth_string' | AS 'hash_string']
+func parseAuthOption(l *lexer, spec *UserSpec) bool {
+	var ok bool
+	if l.acceptKeyword("WITH") {
+		if spec.AuthPlugin, ok = l.name(); !ok {
+			return false
+		}
+		switch {
+		case l.acceptKeyword("BY"):
+			return parseAuthString(l, spec)
+		case l.acceptKeyword("AS"):
+			return parseHashString(l, spec)
+		}
+		return true
+	}
+	if !l.acceptKeyword("BY") {
+		return false
+	}
+	if l.acceptKeyword("PASSWORD") {
+		return parseHashString(l, spec)
+	}
+	return parseAuthString(l, spec)
+}
+
+func parseAuthString(l *lexer, spec *UserSpec) bool {
+	pwd, ok := l.str()
+	spec.AuthOpt = &ast.AuthOption{ByAuthString: true, AuthString: pwd}
+	return ok
+}
+
+func parseHashString(l *lexer, spec *UserSpec) bool {
+	hash, ok := l.str()
+	spec.AuthOpt = &ast.AuthOption{HashString: hash}
+	return ok
+}
@@ -0,0 +1,80 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	// The driver of the values in the pingcap parser.
+	_ "github.com/pingcap/tidb/types/parser_driver"
+)
+
+func TestParseMultiStatements(t *testing.T) {
+	tests := []struct {
+		sql   string
+		stmts []string
+	}{
+		{"BEGIN; SAVEPOINT s1; UPDATE t SET a = 1; ROLLBACK TO s1",
+			[]string{"*ast.BeginStmt", "*parser.SavepointStmt", "*ast.UpdateStmt", "*parser.RollbackToStmt"}},
+		{"START TRANSACTION READ ONLY; SELECT 1",
+			[]string{"*parser.BeginStmt", "*ast.SelectStmt"}},
+		{"CREATE ROLE r; GRANT r TO u; SET ROLE r;",
+			[]string{"*parser.CreateRoleStmt", "*parser.GrantRoleStmt", "*parser.SetRoleStmt"}},
+		{"CREATE USER u IDENTIFIED WITH caching_sha2_password BY 'x'; SELECT 1",
+			[]string{"*parser.CreateUserStmt", "*ast.SelectStmt"}},
+		// The semicolons in the quotes and the comments don't end the statements.
+		{"SELECT ';', \"a;b\", `c;d` FROM t; /* ; */ SAVEPOINT `s;1` -- ;\n; # ;\nRELEASE SAVEPOINT `s;1`",
+			[]string{"*ast.SelectStmt", "*parser.SavepointStmt", "*parser.ReleaseSavepointStmt"}},
+		{"SELECT 'it''s; \\' ;'; SAVEPOINT s1;;", []string{"*ast.SelectStmt", "*parser.SavepointStmt"}},
+		{"SAVEPOINT s1", []string{"*parser.SavepointStmt"}},
+	}
+	p := New()
+	for _, tt := range tests {
+		stmts, err := p.Parse(tt.sql, "", "")
+		if err != nil {
+			t.Fatalf("%s: %v", tt.sql, err)
+		}
+		var types []string
+		for _, stmt := range stmts {
+			types = append(types, fmt.Sprintf("%T", stmt))
+		}
+		if fmt.Sprint(types) != fmt.Sprint(tt.stmts) {
+			t.Fatalf("%s: got %v, expected %v", tt.sql, types, tt.stmts)
+		}
+	}
+
+	stmts, err := p.Parse("SAVEPOINT s1; CREATE USER u IDENTIFIED WITH mysql_native_password BY 'x'", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmts[0].Text() != "SAVEPOINT s1" || stmts[1].(*CreateUserStmt).Specs[0].AuthPlugin != "mysql_native_password" {
+		t.Fatalf("unexpected statements %q, %v", stmts[0].Text(), stmts[1])
+	}
+}
+
+func TestParseMultiStatementsError(t *testing.T) {
+	p := New()
+	for _, sql := range []string{
+		"SAVEPOINT s1; SELEC 1",
+		"SELEC 1; SAVEPOINT s1",
+		"SAVEPOINT s1; START TRANSACTION READ ONLY, READ WRITE",
+		"SAVEPOINT s1; SELECT 'a",
+		"SAVEPOINT s1; /* SELECT 1",
+	} {
+		if _, err := p.Parse(sql, "", ""); err == nil {
+			t.Fatalf("%s: expected an error", sql)
+		}
+	}
+}
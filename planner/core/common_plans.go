@@ -0,0 +1,145 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/common_plans.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/model"
+
+	"fedb/expression"
+)
+
+const (
+	// TypeInsert is the type of Insert.
+	TypeInsert = "Insert"
+	// TypeUpdate is the type of Update.
+	TypeUpdate = "Update"
+	// TypeDelete is the type of Delete.
+	TypeDelete = "Delete"
+	// TypeShowDDLJobs is the type of ShowDDLJobs.
+	TypeShowDDLJobs = "ShowDDLJobs"
+	// TypeCancelDDLJobs is the type of CancelDDLJobs.
+	TypeCancelDDLJobs = "CancelDDLJobs"
+	// TypeShow is the type of Show.
+	TypeShow = "Show"
+)
+
+// baseSchemaProducer stores the schema for the plans that are neither logical nor physical,
+// such as the plans of the DML statements.
+type baseSchemaProducer struct {
+	schema *expression.Schema
+	basePlan
+}
+
+// Schema implements the Plan.Schema interface.
+func (s *baseSchemaProducer) Schema() *expression.Schema {
+	if s.schema == nil {
+		s.schema = expression.NewSchema()
+	}
+	return s.schema
+}
+
+// SetSchema implements the Plan.SetSchema interface.
+func (s *baseSchemaProducer) SetSchema(schema *expression.Schema) {
+	s.schema = schema
+}
+
+// Insert represents an insert plan.
+type Insert struct {
+	baseSchemaProducer
+
+	Table *model.TableInfo
+	// DBName is the name of the database of the table.
+	DBName model.CIStr
+	// Columns are the columns that the values of Lists or the rows of SelectPlan are given to, in order.
+	Columns []*model.ColumnInfo
+	// Lists are the rows of values. They are evaluated on the row being inserted, whose columns
+	// given values later or not at all hold NULL and the default values respectively.
+	Lists [][]expression.Expression
+	// OnDuplicate are the assignments of ON DUPLICATE KEY UPDATE. They are evaluated on the
+	// duplicate row followed by the row being inserted, which is referred to by VALUES(col).
+	OnDuplicate []*expression.Assignment
+
+	IsReplace bool
+
+	SelectPlan PhysicalPlan
+}
+
+// Update represents an update plan.
+type Update struct {
+	baseSchemaProducer
+
+	// OrderedList are the assignments in order, they are evaluated on the rows of SelectPlan.
+	OrderedList []*expression.Assignment
+
+	SelectPlan PhysicalPlan
+
+	// TblColPosInfos are the positions of the tables to update in the rows of SelectPlan.
+	TblColPosInfos []TblColPosInfo
+}
+
+// Delete represents a delete plan.
+type Delete struct {
+	baseSchemaProducer
+
+	SelectPlan PhysicalPlan
+
+	// TblColPosInfos are the positions of the tables to delete from in the rows of SelectPlan.
+	TblColPosInfos []TblColPosInfo
+}
+
+// TblColPosInfo represents the position of the columns of a table in the rows of the
+// SelectPlan of an Update or a Delete.
+type TblColPosInfo struct {
+	TblID int64
+	// Start and End are the range of the public columns of the table.
+	Start, End int
+	// HandleOrdinal is the position of the handle column.
+	HandleOrdinal int
+}
+
+// ShowDDLJobs is for showing the DDL jobs in the queue and the last finished ones.
+type ShowDDLJobs struct {
+	baseSchemaProducer
+}
+
+// CancelDDLJobs represents a cancel DDL jobs plan.
+type CancelDDLJobs struct {
+	baseSchemaProducer
+
+	JobIDs []int64
+}
+
+// Show represents a show plan.
+type Show struct {
+	baseSchemaProducer
+
+	Tp     ast.ShowStmtType
+	DBName model.CIStr
+	// Table is the table of SHOW COLUMNS, SHOW INDEX and SHOW CREATE TABLE.
+	Table  *model.TableInfo
+	Column *ast.ColumnName
+	Full   bool
+	// GlobalScope is set by SHOW GLOBAL VARIABLES and SHOW GLOBAL STATUS.
+	GlobalScope bool
+	// User is the account of SHOW GRANTS, it's the current user if FOR isn't given.
+	User *auth.UserIdentity
+
+	// Conditions are the filters of LIKE and WHERE, they are evaluated on the shown rows.
+	Conditions []expression.Expression
+}
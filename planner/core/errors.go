@@ -0,0 +1,108 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/errors.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+)
+
+const (
+	codeUnsupportedType terror.ErrCode = 1
+
+	codeUnknownColumn   = mysql.ErrBadField
+	codeWrongArguments  = mysql.ErrWrongArguments
+	codeNoDB            = mysql.ErrNoDB
+	codeBadTable        = mysql.ErrBadTable
+	codeNoTablesUsed    = mysql.ErrNoTablesUsed
+	codeDupFieldName    = mysql.ErrDupFieldName
+	codeNotSupportedYet = mysql.ErrNotSupportedYet
+
+	codeInvalidGroupFuncUse          = mysql.ErrInvalidGroupFuncUse
+	codeWrongGroupField              = mysql.ErrWrongGroupField
+	codeWrongNumberOfColumnsInSelect = mysql.ErrWrongNumberOfColumnsInSelect
+	codeOperandColumns               = mysql.ErrOperandColumns
+
+	codeWrongValueCountOnRow = mysql.ErrWrongValueCountOnRow
+	codeNonUpdatableTable    = mysql.ErrNonUpdatableTable
+	codeUnknownTable         = mysql.ErrUnknownTable
+	codeFieldSpecifiedTwice  = mysql.ErrFieldSpecifiedTwice
+
+	codeTableaccessDenied    = mysql.ErrTableaccessDenied
+	codeColumnaccessDenied   = mysql.ErrColumnaccessDenied
+	codeDBaccessDenied       = mysql.ErrDBaccessDenied
+	codeSpecificAccessDenied = mysql.ErrSpecificAccessDenied
+	codeKillDenied           = mysql.ErrKillDenied
+)
+
+// error definitions.
+var (
+	ErrUnsupportedType = terror.ClassOptimizer.New(codeUnsupportedType, "Unsupported type %T")
+	ErrUnknownColumn   = terror.ClassOptimizer.New(codeUnknownColumn, mysql.MySQLErrName[mysql.ErrBadField])
+	ErrWrongArguments  = terror.ClassOptimizer.New(codeWrongArguments, mysql.MySQLErrName[mysql.ErrWrongArguments])
+	ErrNoDB            = terror.ClassOptimizer.New(codeNoDB, "No database selected")
+	ErrBadTable        = terror.ClassOptimizer.New(codeBadTable, mysql.MySQLErrName[mysql.ErrBadTable])
+	ErrNoTablesUsed    = terror.ClassOptimizer.New(codeNoTablesUsed, mysql.MySQLErrName[mysql.ErrNoTablesUsed])
+	ErrDupFieldName    = terror.ClassOptimizer.New(codeDupFieldName, mysql.MySQLErrName[mysql.ErrDupFieldName])
+	// ErrNotSupportedYet is the error when a feature of a statement is not supported.
+	ErrNotSupportedYet = terror.ClassOptimizer.New(codeNotSupportedYet, "This version of FeDB doesn't yet support '%s'")
+
+	ErrInvalidGroupFuncUse          = terror.ClassOptimizer.New(codeInvalidGroupFuncUse, mysql.MySQLErrName[mysql.ErrInvalidGroupFuncUse])
+	ErrWrongGroupField              = terror.ClassOptimizer.New(codeWrongGroupField, mysql.MySQLErrName[mysql.ErrWrongGroupField])
+	ErrWrongNumberOfColumnsInSelect = terror.ClassOptimizer.New(codeWrongNumberOfColumnsInSelect, mysql.MySQLErrName[mysql.ErrWrongNumberOfColumnsInSelect])
+	ErrOperandColumns               = terror.ClassOptimizer.New(codeOperandColumns, mysql.MySQLErrName[mysql.ErrOperandColumns])
+
+	ErrWrongValueCountOnRow = terror.ClassOptimizer.New(codeWrongValueCountOnRow, mysql.MySQLErrName[mysql.ErrWrongValueCountOnRow])
+	ErrNonUpdatableTable    = terror.ClassOptimizer.New(codeNonUpdatableTable, mysql.MySQLErrName[mysql.ErrNonUpdatableTable])
+	ErrUnknownTable         = terror.ClassOptimizer.New(codeUnknownTable, mysql.MySQLErrName[mysql.ErrUnknownTable])
+	ErrFieldSpecifiedTwice  = terror.ClassOptimizer.New(codeFieldSpecifiedTwice, mysql.MySQLErrName[mysql.ErrFieldSpecifiedTwice])
+
+	ErrTableaccessDenied    = terror.ClassOptimizer.New(codeTableaccessDenied, mysql.MySQLErrName[mysql.ErrTableaccessDenied])
+	ErrColumnaccessDenied   = terror.ClassOptimizer.New(codeColumnaccessDenied, mysql.MySQLErrName[mysql.ErrColumnaccessDenied])
+	ErrDBaccessDenied       = terror.ClassOptimizer.New(codeDBaccessDenied, mysql.MySQLErrName[mysql.ErrDBaccessDenied])
+	ErrSpecificAccessDenied = terror.ClassOptimizer.New(codeSpecificAccessDenied, mysql.MySQLErrName[mysql.ErrSpecificAccessDenied])
+	ErrKillDenied           = terror.ClassOptimizer.New(codeKillDenied, "You are not owner of thread %d")
+)
+
+func init() {
+	mysqlErrCodeMap := map[terror.ErrCode]uint16{
+		codeUnknownColumn:   mysql.ErrBadField,
+		codeWrongArguments:  mysql.ErrWrongArguments,
+		codeNoDB:            mysql.ErrNoDB,
+		codeBadTable:        mysql.ErrBadTable,
+		codeNoTablesUsed:    mysql.ErrNoTablesUsed,
+		codeDupFieldName:    mysql.ErrDupFieldName,
+		codeNotSupportedYet: mysql.ErrNotSupportedYet,
+
+		codeInvalidGroupFuncUse:          mysql.ErrInvalidGroupFuncUse,
+		codeWrongGroupField:              mysql.ErrWrongGroupField,
+		codeWrongNumberOfColumnsInSelect: mysql.ErrWrongNumberOfColumnsInSelect,
+		codeOperandColumns:               mysql.ErrOperandColumns,
+
+		codeWrongValueCountOnRow: mysql.ErrWrongValueCountOnRow,
+		codeNonUpdatableTable:    mysql.ErrNonUpdatableTable,
+		codeUnknownTable:         mysql.ErrUnknownTable,
+		codeFieldSpecifiedTwice:  mysql.ErrFieldSpecifiedTwice,
+
+		codeTableaccessDenied:    mysql.ErrTableaccessDenied,
+		codeColumnaccessDenied:   mysql.ErrColumnaccessDenied,
+		codeDBaccessDenied:       mysql.ErrDBaccessDenied,
+		codeSpecificAccessDenied: mysql.ErrSpecificAccessDenied,
+		codeKillDenied:           mysql.ErrKillDenied,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassOptimizer] = mysqlErrCodeMap
+}
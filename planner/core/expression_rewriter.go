@@ -0,0 +1,627 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/expression_rewriter.go
+//
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/opcode"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+
+	"fedb/expression"
+	"fedb/infoschema"
+	"fedb/sessionctx"
+	"fedb/sessionctx/variable"
+	"fedb/util/chunk"
+)
+
+// EvalSubquery evaluates incorrelated subqueries once.
+var EvalSubquery func(p PhysicalPlan, is infoschema.InfoSchema, ctx sessionctx.Context) ([][]types.Datum, error)
+
+// EvalAstExpr evaluates ast expression directly, the expression must not refer to any column.
+func EvalAstExpr(ctx sessionctx.Context, expr ast.ExprNode) (types.Datum, error) {
+	if val, ok := expr.(*driver.ValueExpr); ok {
+		return val.Datum, nil
+	}
+	b := NewPlanBuilder(ctx, ctx.GetInfoSchema())
+	newExpr, _, err := b.rewrite(expr, nil, true)
+	if err != nil {
+		return types.Datum{}, errors.Trace(err)
+	}
+	return newExpr.Eval(chunk.Row{})
+}
+
+// rewrite converts the ast expression into an expression.Expression, the columns are
+// resolved against the schema of p, which is nil if there is no FROM clause. The subqueries
+// that can't be evaluated at once are built into the joins over p, the new plan is returned.
+// asScalar is false if the expression is a WHERE or HAVING condition, whose [NOT] IN and
+// EXISTS subqueries at the top level only filter the rows of p.
+func (b *PlanBuilder) rewrite(exprNode ast.ExprNode, p LogicalPlan, asScalar bool) (expression.Expression, LogicalPlan, error) {
+	er := &expressionRewriter{b: b, ctx: b.ctx, p: p, asScalar: asScalar, schema: expression.NewSchema()}
+	if p != nil {
+		er.schema = p.Schema()
+	}
+	expr, err := er.rewrite(exprNode)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return expr, er.p, nil
+}
+
+type expressionRewriter struct {
+	b      *PlanBuilder
+	ctx    sessionctx.Context
+	p      LogicalPlan
+	schema *expression.Schema
+
+	// asScalar means the return value must be a scalar value.
+	asScalar bool
+}
+
+func (er *expressionRewriter) rewriteList(nodes []ast.ExprNode) ([]expression.Expression, error) {
+	exprs := make([]expression.Expression, 0, len(nodes))
+	for _, node := range nodes {
+		expr, err := er.rewrite(node)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func (er *expressionRewriter) newFunction(funcName string, args ...expression.Expression) (expression.Expression, error) {
+	return expression.NewFunction(er.ctx, funcName, &types.FieldType{Tp: mysql.TypeUnspecified}, args...)
+}
+
+// notToExpression wraps the expression with `not` if hasNot is true.
+func (er *expressionRewriter) notToExpression(hasNot bool, expr expression.Expression) (expression.Expression, error) {
+	if !hasNot {
+		return expr, nil
+	}
+	return er.newFunction(ast.UnaryNot, expr)
+}
+
+func (er *expressionRewriter) rewrite(inNode ast.ExprNode) (expression.Expression, error) {
+	if idx, ok := er.b.colMapper[inNode]; ok {
+		return er.schema.Columns[idx], nil
+	}
+	// Only the subqueries at the top level of a condition, or in parentheses, filter the rows.
+	asScalar := er.asScalar
+	if _, ok := inNode.(*ast.ParenthesesExpr); !ok {
+		er.asScalar = true
+		defer func() { er.asScalar = asScalar }()
+	}
+	switch v := inNode.(type) {
+	case *ast.AggregateFuncExpr:
+		idx, ok := er.b.aggMapper[v]
+		if !ok {
+			return nil, ErrInvalidGroupFuncUse
+		}
+		return er.schema.Columns[idx], nil
+	case *driver.ValueExpr:
+		return &expression.Constant{Value: v.Datum, RetType: &v.Type}, nil
+	case *driver.ParamMarkerExpr:
+		// The value of a parameter marker is changed by every execution of the prepared statement.
+		tp := v.Type
+		return &expression.Constant{Value: v.Datum, RetType: &tp}, nil
+	case *ast.ParenthesesExpr:
+		return er.rewrite(v.Expr)
+	case *ast.ColumnNameExpr:
+		return er.toColumn(v.Name)
+	case *ast.VariableExpr:
+		return er.rewriteVariable(v)
+	case *ast.FuncCallExpr:
+		args, err := er.rewriteList(v.Args)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return er.newFunction(v.FnName.L, args...)
+	case *ast.BinaryOperationExpr:
+		args, err := er.rewriteList([]ast.ExprNode{v.L, v.R})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return er.newFunction(v.Op.String(), args...)
+	case *ast.UnaryOperationExpr:
+		return er.unaryOpToExpression(v)
+	case *ast.IsNullExpr:
+		arg, err := er.rewrite(v.Expr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		expr, err := er.newFunction(ast.IsNull, arg)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return er.notToExpression(v.Not, expr)
+	case *ast.IsTruthExpr:
+		arg, err := er.rewrite(v.Expr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		funcName := ast.IsTruth
+		if v.True == 0 {
+			funcName = ast.IsFalsity
+		}
+		expr, err := er.newFunction(funcName, arg)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return er.notToExpression(v.Not, expr)
+	case *ast.BetweenExpr:
+		return er.betweenToExpression(v)
+	case *ast.PatternInExpr:
+		if v.Sel != nil {
+			return er.handleInSubquery(v, asScalar)
+		}
+		return er.inToExpression(v)
+	case *ast.SubqueryExpr:
+		return er.handleScalarSubquery(v)
+	case *ast.ExistsSubqueryExpr:
+		return er.handleExistSubquery(v, asScalar)
+	case *ast.CompareSubqueryExpr:
+		return er.handleCompareSubquery(v, asScalar)
+	case *ast.PatternLikeExpr:
+		args, err := er.rewriteList([]ast.ExprNode{v.Expr, v.Pattern})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		escape := expression.NewConstant(types.NewIntDatum(int64(v.Escape)))
+		expr, err := er.newFunction(ast.Like, append(args, escape)...)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return er.notToExpression(v.Not, expr)
+	case *ast.CaseExpr:
+		return er.caseWhenToExpression(v)
+	case *ast.FuncCastExpr:
+		arg, err := er.rewrite(v.Expr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		return expression.BuildCastFunction(er.ctx, arg, v.Tp)
+	case *ast.ValuesExpr:
+		return er.valuesToExpression(v)
+	}
+	return nil, ErrUnsupportedType.GenWithStackByArgs(inNode)
+}
+
+// valuesToExpression rewrites VALUES(col) into the column of the row being inserted, it is
+// NULL out of the ON DUPLICATE KEY UPDATE clause.
+func (er *expressionRewriter) valuesToExpression(v *ast.ValuesExpr) (expression.Expression, error) {
+	if er.b.insertValuesSchema == nil {
+		return expression.Null.Clone(), nil
+	}
+	column, err := er.b.insertValuesSchema.FindColumn(v.Column.Name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if column == nil {
+		return nil, ErrUnknownColumn.GenWithStackByArgs(v.Column.Name.OrigColName(), clauseMsg[er.b.curClause])
+	}
+	return column, nil
+}
+
+func (er *expressionRewriter) toColumn(colName *ast.ColumnName) (expression.Expression, error) {
+	expr, err := er.findColumn(colName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Referring to a column of a table requires the privilege of reading it.
+	var uniqueID int64
+	switch x := expr.(type) {
+	case *expression.Column:
+		uniqueID = x.UniqueID
+	case *expression.CorrelatedColumn:
+		uniqueID = x.UniqueID
+	}
+	if v, ok := er.b.tableColumns[uniqueID]; ok {
+		er.b.visitInfo = append(er.b.visitInfo, v)
+	}
+	return expr, nil
+}
+
+// findColumn resolves the column name by the schema of the plan, then by the ones of the outer queries.
+func (er *expressionRewriter) findColumn(colName *ast.ColumnName) (expression.Expression, error) {
+	column, err := er.schema.FindColumn(colName)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if column != nil {
+		return column, nil
+	}
+	// The common columns of the right table of USING and NATURAL joins are not in the schema.
+	if redundant := findRedundantSchema(er.p); redundant != nil {
+		column, err = redundant.FindColumn(colName)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if column != nil {
+			return column, nil
+		}
+	}
+	// The column of an outer query is correlated, the inner ones are searched first.
+	for i := len(er.b.outerSchemas) - 1; i >= 0; i-- {
+		column, err = er.b.outerSchemas[i].FindColumn(colName)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if column != nil {
+			return &expression.CorrelatedColumn{Column: *column, Data: new(types.Datum)}, nil
+		}
+	}
+	return nil, ErrUnknownColumn.GenWithStackByArgs(colName.OrigColName(), clauseMsg[er.b.curClause])
+}
+
+// findRedundantSchema returns the redundant schema of the join under p, the selections and
+// the joins built for the subqueries are skipped.
+func findRedundantSchema(p LogicalPlan) *expression.Schema {
+	for p != nil {
+		switch x := p.(type) {
+		case *LogicalApply:
+			p = x.children[0]
+		case *LogicalJoin:
+			if !x.JoinType.IsSemiJoin() {
+				return x.redundantSchema
+			}
+			p = x.children[0]
+		case *LogicalSelection:
+			p = x.children[0]
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (er *expressionRewriter) rewriteVariable(v *ast.VariableExpr) (expression.Expression, error) {
+	name := strings.ToLower(v.Name)
+	if v.Value != nil {
+		return nil, ErrNotSupportedYet.GenWithStackByArgs("variable assignment in expressions")
+	}
+	if !v.IsSystem {
+		if val, ok := er.ctx.GetSessionVars().Users[name]; ok {
+			return expression.NewConstant(types.NewStringDatum(val)), nil
+		}
+		return expression.Null.Clone(), nil
+	}
+	var (
+		val string
+		ok  bool
+	)
+	if v.IsGlobal {
+		val, ok = variable.GetGlobalSysVar(name)
+	} else {
+		val, ok = er.ctx.GetSessionVars().GetSystemVar(name)
+	}
+	if !ok {
+		return nil, variable.UnknownSystemVar.GenWithStackByArgs(name)
+	}
+	return expression.NewConstant(types.NewStringDatum(val)), nil
+}
+
+func (er *expressionRewriter) unaryOpToExpression(v *ast.UnaryOperationExpr) (expression.Expression, error) {
+	arg, err := er.rewrite(v.V)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var op string
+	switch v.Op {
+	case opcode.Plus:
+		// expression (+ a) is equal to a
+		return arg, nil
+	case opcode.Minus:
+		op = ast.UnaryMinus
+	case opcode.BitNeg:
+		op = ast.BitNeg
+	case opcode.Not:
+		op = ast.UnaryNot
+	default:
+		return nil, errors.Errorf("Unknown Unary Op %T", v.Op)
+	}
+	return er.newFunction(op, arg)
+}
+
+// betweenToExpression converts `expr BETWEEN l AND r` into `expr >= l AND expr <= r`,
+// and `expr NOT BETWEEN l AND r` into `expr < l OR expr > r`.
+func (er *expressionRewriter) betweenToExpression(v *ast.BetweenExpr) (expression.Expression, error) {
+	args, err := er.rewriteList([]ast.ExprNode{v.Expr, v.Left, v.Right})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	lOp, rOp, op := ast.GE, ast.LE, ast.LogicAnd
+	if v.Not {
+		lOp, rOp, op = ast.LT, ast.GT, ast.LogicOr
+	}
+	l, err := er.newFunction(lOp, args[0], args[1])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	r, err := er.newFunction(rOp, args[0].Clone(), args[2])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return er.newFunction(op, l, r)
+}
+
+func (er *expressionRewriter) inToExpression(v *ast.PatternInExpr) (expression.Expression, error) {
+	args, err := er.rewriteList(append([]ast.ExprNode{v.Expr}, v.List...))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	expr, err := er.newFunction(ast.In, args...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return er.notToExpression(v.Not, expr)
+}
+
+// caseWhenToExpression converts the CASE expression into the `case` function, whose arguments
+// are the pairs of condition and result followed by the optional else result. The simple form
+// `CASE value WHEN v1 THEN r1` is converted into `CASE WHEN value = v1 THEN r1`.
+func (er *expressionRewriter) caseWhenToExpression(v *ast.CaseExpr) (expression.Expression, error) {
+	var value expression.Expression
+	if v.Value != nil {
+		var err error
+		if value, err = er.rewrite(v.Value); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	args := make([]expression.Expression, 0, len(v.WhenClauses)*2+1)
+	for _, clause := range v.WhenClauses {
+		exprs, err := er.rewriteList([]ast.ExprNode{clause.Expr, clause.Result})
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cond := exprs[0]
+		if value != nil {
+			if cond, err = er.newFunction(ast.EQ, value.Clone(), cond); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+		args = append(args, cond, exprs[1])
+	}
+	if v.ElseClause != nil {
+		elseExpr, err := er.rewrite(v.ElseClause)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		args = append(args, elseExpr)
+	}
+	return er.newFunction(ast.Case, args...)
+}
+
+func (er *expressionRewriter) buildSubquery(subq *ast.SubqueryExpr) (LogicalPlan, error) {
+	er.b.outerSchemas = append(er.b.outerSchemas, er.schema)
+	np, err := er.b.buildResultSetNode(subq.Query)
+	er.b.outerSchemas = er.b.outerSchemas[:len(er.b.outerSchemas)-1]
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return np, nil
+}
+
+// setPlan sets the plan the subqueries are joined to, the columns are resolved against its schema.
+func (er *expressionRewriter) setPlan(p LogicalPlan) {
+	er.p = p
+	er.schema = p.Schema()
+}
+
+// evalSubquery optimizes and executes the incorrelated subquery, its rows are returned.
+func (er *expressionRewriter) evalSubquery(np LogicalPlan) ([][]types.Datum, error) {
+	physicalPlan, err := DoOptimize(np)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rows, err := EvalSubquery(physicalPlan, er.b.is, er.ctx)
+	return rows, errors.Trace(err)
+}
+
+// buildSemiJoin joins the subquery np to the plan with cond, an outer row is matched if cond is true
+// on some inner rows, not means the row is matched if there are no such rows instead. If the
+// result is used as a value, the auxiliary column of the match result is returned, whose value is
+// NULL if the row is not matched but cond is NULL on some inner rows.
+func (er *expressionRewriter) buildSemiJoin(np LogicalPlan, cond expression.Expression, not, asScalar bool) (expression.Expression, error) {
+	if er.p == nil {
+		return nil, ErrNotSupportedYet.GenWithStackByArgs("subquery without FROM clause")
+	}
+	var join *LogicalJoin
+	var p LogicalPlan
+	if len(extractCorColumns(np)) > 0 {
+		ap := LogicalApply{}.Init(er.ctx)
+		join, p = &ap.LogicalJoin, ap
+	} else {
+		join = LogicalJoin{}.Init(er.ctx)
+		p = join
+	}
+	join.SetChildren(er.p, np)
+	if err := join.setPreferredJoinType(er.b.TableHints()); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var conds []expression.Expression
+	if cond != nil {
+		conds = expression.SplitCNFItems(cond.Decorrelate(er.p.Schema()))
+	}
+	schema := er.p.Schema().Clone()
+	switch {
+	case asScalar:
+		join.JoinType = LeftOuterSemiJoin
+		if not {
+			join.JoinType = AntiLeftOuterSemiJoin
+		}
+		join.NAConditions = conds
+		schema.Append(&expression.Column{
+			ColName:     model.NewCIStr(fmt.Sprintf("%d_aux_0", join.id)),
+			RetType:     types.NewFieldType(mysql.TypeTiny),
+			UniqueID:    er.ctx.GetSessionVars().AllocPlanColumnID(),
+			IsAggOrSubq: true,
+		})
+	case not:
+		join.JoinType = AntiSemiJoin
+		join.NAConditions = conds
+	default:
+		join.JoinType = SemiJoin
+		join.attachOnConds(conds)
+	}
+	join.SetSchema(schema)
+	er.setPlan(p)
+	if asScalar {
+		return schema.Columns[schema.Len()-1], nil
+	}
+	return expression.One.Clone(), nil
+}
+
+// negatedCmpOps are the comparisons that are true if and only if the comparisons are false.
+var negatedCmpOps = map[opcode.Op]opcode.Op{
+	opcode.EQ: opcode.NE,
+	opcode.NE: opcode.EQ,
+	opcode.LT: opcode.GE,
+	opcode.GE: opcode.LT,
+	opcode.LE: opcode.GT,
+	opcode.GT: opcode.LE,
+}
+
+// buildCmpSubquery builds `lexpr op ANY (subquery)`, or `NOT (lexpr op ANY (subquery))` if not is true.
+func (er *expressionRewriter) buildCmpSubquery(lexpr expression.Expression, op opcode.Op, subq ast.ExprNode, not, asScalar bool) (expression.Expression, error) {
+	sq, ok := subq.(*ast.SubqueryExpr)
+	if !ok {
+		return nil, ErrUnsupportedType.GenWithStackByArgs(subq)
+	}
+	np, err := er.buildSubquery(sq)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if np.Schema().Len() != 1 {
+		return nil, ErrOperandColumns.GenWithStackByArgs(1)
+	}
+	cond, err := er.newFunction(op.String(), lexpr, np.Schema().Columns[0])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return er.buildSemiJoin(np, cond, not, asScalar)
+}
+
+// handleInSubquery rewrites `a IN (subquery)` as `a = ANY (subquery)`.
+func (er *expressionRewriter) handleInSubquery(v *ast.PatternInExpr, asScalar bool) (expression.Expression, error) {
+	lexpr, err := er.rewrite(v.Expr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return er.buildCmpSubquery(lexpr, opcode.EQ, v.Sel, v.Not, asScalar)
+}
+
+// handleCompareSubquery rewrites `a op ALL (subquery)` as `NOT (a negated-op ANY (subquery))`.
+func (er *expressionRewriter) handleCompareSubquery(v *ast.CompareSubqueryExpr, asScalar bool) (expression.Expression, error) {
+	lexpr, err := er.rewrite(v.L)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !v.All {
+		return er.buildCmpSubquery(lexpr, v.Op, v.R, false, asScalar)
+	}
+	op, ok := negatedCmpOps[v.Op]
+	if !ok {
+		return nil, ErrNotSupportedYet.GenWithStackByArgs(v.Op.String() + " ALL subquery")
+	}
+	return er.buildCmpSubquery(lexpr, op, v.R, true, asScalar)
+}
+
+func (er *expressionRewriter) handleExistSubquery(v *ast.ExistsSubqueryExpr, asScalar bool) (expression.Expression, error) {
+	subq, ok := v.Sel.(*ast.SubqueryExpr)
+	if !ok {
+		return nil, ErrUnsupportedType.GenWithStackByArgs(v.Sel)
+	}
+	np, err := er.buildSubquery(subq)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	np = er.popExistsSubPlan(np)
+	if len(extractCorColumns(np)) > 0 {
+		return er.buildSemiJoin(np, nil, v.Not, asScalar)
+	}
+	limit := LogicalLimit{Count: 1}.Init(er.ctx)
+	limit.SetChildren(np)
+	rows, err := er.evalSubquery(limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if (len(rows) > 0) != v.Not {
+		return expression.One.Clone(), nil
+	}
+	return expression.Zero.Clone(), nil
+}
+
+// popExistsSubPlan will remove the useless plan in exist's child.
+// See comments inside the method for more details.
+func (er *expressionRewriter) popExistsSubPlan(p LogicalPlan) LogicalPlan {
+out:
+	for {
+		switch plan := p.(type) {
+		// This can be removed when in exists clause,
+		// e.g. exists(select count(*) from t order by a) is equal to exists t.
+		case *LogicalProjection, *LogicalSort:
+			p = p.Children()[0]
+		case *LogicalAggregation:
+			if len(plan.GroupByItems) == 0 {
+				p = LogicalTableDual{RowCount: 1}.Init(er.ctx)
+				break out
+			}
+			p = p.Children()[0]
+		default:
+			break out
+		}
+	}
+	return p
+}
+
+func (er *expressionRewriter) handleScalarSubquery(v *ast.SubqueryExpr) (expression.Expression, error) {
+	np, err := er.buildSubquery(v)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if np.Schema().Len() != 1 {
+		return nil, ErrOperandColumns.GenWithStackByArgs(1)
+	}
+	maxOneRow := LogicalMaxOneRow{}.Init(er.ctx)
+	maxOneRow.SetChildren(np)
+	if len(extractCorColumns(np)) > 0 {
+		if er.p == nil {
+			return nil, ErrNotSupportedYet.GenWithStackByArgs("subquery without FROM clause")
+		}
+		ap := LogicalApply{LogicalJoin: LogicalJoin{JoinType: LeftOuterJoin}}.Init(er.ctx)
+		ap.SetChildren(er.p, maxOneRow)
+		ap.SetSchema(expression.MergeSchema(er.p.Schema(), maxOneRow.Schema()))
+		resetNotNullFlag(ap.schema, er.p.Schema().Len(), ap.schema.Len())
+		er.setPlan(ap)
+		return ap.schema.Columns[ap.schema.Len()-1], nil
+	}
+	rows, err := er.evalSubquery(maxOneRow)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &expression.Constant{
+		Value:   rows[0][0],
+		RetType: np.Schema().Columns[0].GetType(),
+	}, nil
+}
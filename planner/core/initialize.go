@@ -0,0 +1,247 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/initialize.go
+//
+
+package core
+
+import (
+	"fedb/expression"
+	"fedb/sessionctx"
+)
+
+// Init initializes DataSource.
+func (ds DataSource) Init(ctx sessionctx.Context) *DataSource {
+	ds.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeDataSource, &ds)
+	return &ds
+}
+
+// Init initializes LogicalSelection.
+func (p LogicalSelection) Init(ctx sessionctx.Context) *LogicalSelection {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeSel, &p)
+	return &p
+}
+
+// Init initializes LogicalProjection.
+func (p LogicalProjection) Init(ctx sessionctx.Context) *LogicalProjection {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeProj, &p)
+	return &p
+}
+
+// Init initializes LogicalLimit.
+func (p LogicalLimit) Init(ctx sessionctx.Context) *LogicalLimit {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeLimit, &p)
+	return &p
+}
+
+// Init initializes LogicalTableDual.
+func (p LogicalTableDual) Init(ctx sessionctx.Context) *LogicalTableDual {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeDual, &p)
+	return &p
+}
+
+// Init initializes PhysicalTableScan.
+func (p PhysicalTableScan) Init(ctx sessionctx.Context) *PhysicalTableScan {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeTableScan, &p)
+	return &p
+}
+
+// Init initializes PhysicalMemTable.
+func (p PhysicalMemTable) Init(ctx sessionctx.Context) *PhysicalMemTable {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeMemTableScan, &p)
+	return &p
+}
+
+// Init initializes PhysicalSelection.
+func (p PhysicalSelection) Init(ctx sessionctx.Context) *PhysicalSelection {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeSel, &p)
+	return &p
+}
+
+// Init initializes PhysicalProjection.
+func (p PhysicalProjection) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalProjection {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeProj, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalLimit.
+func (p PhysicalLimit) Init(ctx sessionctx.Context) *PhysicalLimit {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeLimit, &p)
+	return &p
+}
+
+// Init initializes PhysicalTableDual.
+func (p PhysicalTableDual) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalTableDual {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeDual, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes LogicalAggregation.
+func (la LogicalAggregation) Init(ctx sessionctx.Context) *LogicalAggregation {
+	la.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeAgg, &la)
+	return &la
+}
+
+// Init initializes LogicalSort.
+func (ls LogicalSort) Init(ctx sessionctx.Context) *LogicalSort {
+	ls.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeSort, &ls)
+	return &ls
+}
+
+// Init initializes LogicalTopN.
+func (lt LogicalTopN) Init(ctx sessionctx.Context) *LogicalTopN {
+	lt.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeTopN, &lt)
+	return &lt
+}
+
+// Init initializes LogicalUnionAll.
+func (p LogicalUnionAll) Init(ctx sessionctx.Context) *LogicalUnionAll {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeUnion, &p)
+	return &p
+}
+
+// Init initializes PhysicalIndexScan.
+func (p PhysicalIndexScan) Init(ctx sessionctx.Context) *PhysicalIndexScan {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeIndexScan, &p)
+	return &p
+}
+
+// Init initializes PhysicalIndexLookUpReader.
+func (p PhysicalIndexLookUpReader) Init(ctx sessionctx.Context) *PhysicalIndexLookUpReader {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeIndexLookUp, &p)
+	return &p
+}
+
+// Init initializes PhysicalSort.
+func (p PhysicalSort) Init(ctx sessionctx.Context) *PhysicalSort {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeSort, &p)
+	return &p
+}
+
+// Init initializes PhysicalTopN.
+func (p PhysicalTopN) Init(ctx sessionctx.Context) *PhysicalTopN {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeTopN, &p)
+	return &p
+}
+
+// Init initializes PhysicalHashAgg.
+func (p PhysicalHashAgg) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalHashAgg {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeHashAgg, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalStreamAgg.
+func (p PhysicalStreamAgg) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalStreamAgg {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeStreamAgg, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalUnionAll.
+func (p PhysicalUnionAll) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalUnionAll {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeUnion, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes LogicalJoin.
+func (p LogicalJoin) Init(ctx sessionctx.Context) *LogicalJoin {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeJoin, &p)
+	return &p
+}
+
+// Init initializes LogicalApply.
+func (la LogicalApply) Init(ctx sessionctx.Context) *LogicalApply {
+	la.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeApply, &la)
+	return &la
+}
+
+// Init initializes LogicalMaxOneRow.
+func (p LogicalMaxOneRow) Init(ctx sessionctx.Context) *LogicalMaxOneRow {
+	p.baseLogicalPlan = newBaseLogicalPlan(ctx, TypeMaxOneRow, &p)
+	return &p
+}
+
+// Init initializes PhysicalHashJoin.
+func (p PhysicalHashJoin) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalHashJoin {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeHashJoin, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalMergeJoin.
+func (p PhysicalMergeJoin) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalMergeJoin {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeMergeJoin, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalIndexJoin.
+func (p PhysicalIndexJoin) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalIndexJoin {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeIndexJoin, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalApply.
+func (p PhysicalApply) Init(ctx sessionctx.Context, schema *expression.Schema) *PhysicalApply {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeApply, &p)
+	p.schema = schema
+	return &p
+}
+
+// Init initializes PhysicalMaxOneRow.
+func (p PhysicalMaxOneRow) Init(ctx sessionctx.Context) *PhysicalMaxOneRow {
+	p.basePhysicalPlan = newBasePhysicalPlan(ctx, TypeMaxOneRow, &p)
+	return &p
+}
+
+// Init initializes Insert.
+func (p Insert) Init(ctx sessionctx.Context) *Insert {
+	p.basePlan = newBasePlan(ctx, TypeInsert)
+	return &p
+}
+
+// Init initializes Update.
+func (p Update) Init(ctx sessionctx.Context) *Update {
+	p.basePlan = newBasePlan(ctx, TypeUpdate)
+	return &p
+}
+
+// Init initializes Delete.
+func (p Delete) Init(ctx sessionctx.Context) *Delete {
+	p.basePlan = newBasePlan(ctx, TypeDelete)
+	return &p
+}
+
+// Init initializes ShowDDLJobs.
+func (p ShowDDLJobs) Init(ctx sessionctx.Context) *ShowDDLJobs {
+	p.basePlan = newBasePlan(ctx, TypeShowDDLJobs)
+	return &p
+}
+
+// Init initializes CancelDDLJobs.
+func (p CancelDDLJobs) Init(ctx sessionctx.Context) *CancelDDLJobs {
+	p.basePlan = newBasePlan(ctx, TypeCancelDDLJobs)
+	return &p
+}
+
+// Init initializes Show.
+func (p Show) Init(ctx sessionctx.Context) *Show {
+	p.basePlan = newBasePlan(ctx, TypeShow)
+	return &p
+}
@@ -0,0 +1,1487 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/logical_plan_builder.go
+//
+
+package core
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+	"fedb/infoschema"
+	"fedb/util/chunk"
+)
+
+type clauseCode int
+
+const (
+	fieldList clauseCode = iota
+	whereClause
+	groupByClause
+	havingClause
+	orderByClause
+	onClause
+)
+
+var clauseMsg = map[clauseCode]string{
+	fieldList:     "field list",
+	whereClause:   "where clause",
+	groupByClause: "group statement",
+	havingClause:  "having clause",
+	orderByClause: "order clause",
+	onClause:      "on clause",
+}
+
+const (
+	// TiDBMergeJoin is hint enforce merge join.
+	TiDBMergeJoin = "tidb_smj"
+	// TiDBIndexNestedLoopJoin is hint enforce index nested loop join.
+	TiDBIndexNestedLoopJoin = "tidb_inlj"
+	// TiDBHashJoin is hint enforce hash join.
+	TiDBHashJoin = "tidb_hj"
+)
+
+func (b *PlanBuilder) buildSelect(sel *ast.SelectStmt) (LogicalPlan, error) {
+	if b.pushTableHints(sel.TableHints) {
+		// table hints are only visible in the current SELECT statement.
+		defer b.popTableHints()
+	}
+	// The mappers belong to the select being built, a nested select has its own ones.
+	oldAggMapper, oldColMapper, oldClause := b.aggMapper, b.colMapper, b.curClause
+	b.aggMapper, b.colMapper = nil, nil
+	defer func() {
+		b.aggMapper, b.colMapper, b.curClause = oldAggMapper, oldColMapper, oldClause
+	}()
+
+	var (
+		p   LogicalPlan
+		err error
+	)
+	if sel.From != nil {
+		p, err = b.buildResultSetNode(sel.From.TableRefs)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else {
+		p = b.buildTableDual()
+	}
+
+	sel.Fields.Fields, err = b.unfoldWildStar(p, sel.Fields.Fields)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if sel.Where != nil {
+		b.curClause = whereClause
+		p, err = b.buildSelection(p, sel.Where)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	// The columns and aggregate functions in HAVING and ORDER BY are resolved to the select fields,
+	// the auxiliary fields are appended for the ones that are not selected.
+	fields, colMapper, err := b.resolveHavingAndOrderBy(sel, p)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var gbyItems []expression.Expression
+	if sel.GroupBy != nil {
+		p, gbyItems, err = b.resolveGbyExprs(p, sel.GroupBy, sel.Fields.Fields)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if aggFuncs := extractAggFuncs(fields); sel.GroupBy != nil || len(aggFuncs) > 0 {
+		p, err = b.buildAggregation(p, aggFuncs, gbyItems)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	var oldLen int
+	p, oldLen, err = b.buildProjection(p, fields)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	b.colMapper = colMapper
+	if sel.Having != nil {
+		b.curClause = havingClause
+		p, err = b.buildSelection(p, sel.Having.Expr)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if sel.Distinct {
+		p, err = b.buildDistinct(p, oldLen)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if sel.OrderBy != nil {
+		p, err = b.buildSort(p, sel.OrderBy.Items)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if sel.Limit != nil {
+		p, err = b.buildLimit(p, sel.Limit)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if oldLen != p.Schema().Len() {
+		// The auxiliary fields are removed from the output.
+		proj := LogicalProjection{Exprs: expression.Column2Exprs(p.Schema().Columns[:oldLen])}.Init(b.ctx)
+		proj.SetChildren(p)
+		proj.SetSchema(expression.NewSchema(p.Schema().Clone().Columns[:oldLen]...))
+		p = proj
+	}
+	return p, nil
+}
+
+func (b *PlanBuilder) buildResultSetNode(node ast.ResultSetNode) (LogicalPlan, error) {
+	switch x := node.(type) {
+	case *ast.Join:
+		return b.buildJoin(x)
+	case *ast.TableSource:
+		var (
+			p   LogicalPlan
+			err error
+		)
+		switch v := x.Source.(type) {
+		case *ast.SelectStmt:
+			p, err = b.buildSelect(v)
+		case *ast.UnionStmt:
+			p, err = b.buildUnion(v)
+		case *ast.TableName:
+			p, err = b.buildDataSource(v)
+		default:
+			err = ErrUnsupportedType.GenWithStackByArgs(v)
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+
+		if v, ok := p.(*DataSource); ok {
+			v.TableAsName = &x.AsName
+		}
+		for _, col := range p.Schema().Columns {
+			col.OrigTblName = col.TblName
+			if x.AsName.L != "" {
+				col.TblName = x.AsName
+				col.DBName = model.NewCIStr("")
+			}
+		}
+		// Duplicate column name in one table is not allowed.
+		// "select * from (select 1, 1) as a;" is duplicate
+		dupNames := make(map[string]struct{}, len(p.Schema().Columns))
+		for _, col := range p.Schema().Columns {
+			name := col.ColName.L
+			if _, ok := dupNames[name]; ok {
+				return nil, ErrDupFieldName.GenWithStackByArgs(col.ColName.O)
+			}
+			dupNames[name] = struct{}{}
+		}
+		return p, nil
+	case *ast.SelectStmt:
+		return b.buildSelect(x)
+	case *ast.UnionStmt:
+		return b.buildUnion(x)
+	}
+	return nil, ErrUnsupportedType.GenWithStack("Unsupported ast.ResultSetNode(%T) for buildResultSetNode()", node)
+}
+
+func (b *PlanBuilder) pushTableHints(hints []*ast.TableOptimizerHint) bool {
+	var sortMergeTables, INLJTables, hashJoinTables []model.CIStr
+	for _, hint := range hints {
+		switch hint.HintName.L {
+		case TiDBMergeJoin:
+			sortMergeTables = append(sortMergeTables, hint.Tables...)
+		case TiDBIndexNestedLoopJoin:
+			INLJTables = append(INLJTables, hint.Tables...)
+		case TiDBHashJoin:
+			hashJoinTables = append(hashJoinTables, hint.Tables...)
+		default:
+			// ignore hints that not implemented
+		}
+	}
+	if len(sortMergeTables)+len(INLJTables)+len(hashJoinTables) > 0 {
+		b.tableHintInfo = append(b.tableHintInfo, tableHintInfo{
+			sortMergeJoinTables:       sortMergeTables,
+			indexNestedLoopJoinTables: INLJTables,
+			hashJoinTables:            hashJoinTables,
+		})
+		return true
+	}
+	return false
+}
+
+func (b *PlanBuilder) popTableHints() {
+	b.tableHintInfo = b.tableHintInfo[:len(b.tableHintInfo)-1]
+}
+
+// TableHints returns the *tableHintInfo of PlanBuilder.
+func (b *PlanBuilder) TableHints() *tableHintInfo {
+	if len(b.tableHintInfo) == 0 {
+		return nil
+	}
+	return &(b.tableHintInfo[len(b.tableHintInfo)-1])
+}
+
+func extractTableAlias(p LogicalPlan) *model.CIStr {
+	if p.Schema().Len() > 0 && p.Schema().Columns[0].TblName.L != "" {
+		return &(p.Schema().Columns[0].TblName)
+	}
+	return nil
+}
+
+func (p *LogicalJoin) setPreferredJoinType(hintInfo *tableHintInfo) error {
+	if hintInfo == nil {
+		return nil
+	}
+
+	lhsAlias := extractTableAlias(p.children[0])
+	rhsAlias := extractTableAlias(p.children[1])
+	if hintInfo.ifPreferMergeJoin(lhsAlias, rhsAlias) {
+		p.preferJoinType |= preferMergeJoin
+	}
+	if hintInfo.ifPreferHashJoin(lhsAlias, rhsAlias) {
+		p.preferJoinType |= preferHashJoin
+	}
+	if hintInfo.ifPreferINLJ(lhsAlias) {
+		p.preferJoinType |= preferLeftAsIndexInner
+	}
+	if hintInfo.ifPreferINLJ(rhsAlias) {
+		p.preferJoinType |= preferRightAsIndexInner
+	}
+
+	// If there're multiple join types and one of them is not index join hint,
+	// then there is a conflict of join types.
+	if bits.OnesCount(p.preferJoinType) > 1 && (p.preferJoinType^preferRightAsIndexInner^preferLeftAsIndexInner) > 0 {
+		return errors.New("Join hints are conflict, you can only specify one type of join")
+	}
+	return nil
+}
+
+func resetNotNullFlag(schema *expression.Schema, start, end int) {
+	for i := start; i < end; i++ {
+		col := *schema.Columns[i]
+		newFieldType := *col.RetType
+		newFieldType.Flag &= ^mysql.NotNullFlag
+		col.RetType = &newFieldType
+		schema.Columns[i] = &col
+	}
+}
+
+func (b *PlanBuilder) buildJoin(joinNode *ast.Join) (LogicalPlan, error) {
+	if joinNode.Right == nil {
+		return b.buildResultSetNode(joinNode.Left)
+	}
+
+	leftPlan, err := b.buildResultSetNode(joinNode.Left)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	rightPlan, err := b.buildResultSetNode(joinNode.Right)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	joinPlan := LogicalJoin{}.Init(b.ctx)
+	joinPlan.SetChildren(leftPlan, rightPlan)
+	joinPlan.SetSchema(expression.MergeSchema(leftPlan.Schema(), rightPlan.Schema()))
+
+	// Set join type.
+	switch joinNode.Tp {
+	case ast.LeftJoin:
+		joinPlan.JoinType = LeftOuterJoin
+		resetNotNullFlag(joinPlan.schema, leftPlan.Schema().Len(), joinPlan.schema.Len())
+	case ast.RightJoin:
+		joinPlan.JoinType = RightOuterJoin
+		resetNotNullFlag(joinPlan.schema, 0, leftPlan.Schema().Len())
+	default:
+		joinPlan.JoinType = InnerJoin
+	}
+
+	// Merge sub join's redundantSchema into this join plan. When handle query like
+	// select t2.a from (t1 join t2 using (a)) join t3 using (a);
+	// we can simply search in the top level join plan to find redundant column.
+	var lRedundant, rRedundant *expression.Schema
+	if left, ok := leftPlan.(*LogicalJoin); ok && left.redundantSchema != nil {
+		lRedundant = left.redundantSchema
+	}
+	if right, ok := rightPlan.(*LogicalJoin); ok && right.redundantSchema != nil {
+		rRedundant = right.redundantSchema
+	}
+	joinPlan.redundantSchema = expression.MergeSchema(lRedundant, rRedundant)
+
+	// Set preferred join algorithm if some join hints is specified by user.
+	err = joinPlan.setPreferredJoinType(b.TableHints())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	// "NATURAL JOIN" doesn't have "ON" or "USING" conditions.
+	//
+	// The "NATURAL [LEFT] JOIN" of two tables is defined to be semantically
+	// equivalent to an "INNER JOIN" or a "LEFT JOIN" with a "USING" clause
+	// that names all columns that exist in both tables.
+	//
+	// See https://dev.mysql.com/doc/refman/5.7/en/join.html for more detail.
+	if joinNode.NaturalJoin {
+		err = b.buildNaturalJoin(joinPlan, leftPlan, rightPlan, joinNode)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else if joinNode.Using != nil {
+		err = b.buildUsingClause(joinPlan, leftPlan, rightPlan, joinNode)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	} else if joinNode.On != nil {
+		b.curClause = onClause
+		onExpr, newPlan, err := b.rewrite(joinNode.On.Expr, joinPlan, true)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if newPlan != joinPlan {
+			return nil, ErrNotSupportedYet.GenWithStackByArgs("subquery in ON clause")
+		}
+		onCondition := expression.SplitCNFItems(onExpr)
+		joinPlan.attachOnConds(onCondition)
+	}
+
+	return joinPlan, nil
+}
+
+// buildUsingClause eliminate the redundant columns and ordering columns based
+// on the "USING" clause.
+//
+// According to the standard SQL, columns are ordered in the following way:
+//  1. coalesced common columns of "leftPlan" and "rightPlan", in the order they
+//     appears in "leftPlan".
+//  2. the rest columns in "leftPlan", in the order they appears in "leftPlan".
+//  3. the rest columns in "rightPlan", in the order they appears in "rightPlan".
+func (b *PlanBuilder) buildUsingClause(p *LogicalJoin, leftPlan, rightPlan LogicalPlan, join *ast.Join) error {
+	filter := make(map[string]bool, len(join.Using))
+	for _, col := range join.Using {
+		filter[col.Name.L] = true
+	}
+	return b.coalesceCommonColumns(p, leftPlan, rightPlan, join.Tp == ast.RightJoin, filter)
+}
+
+// buildNaturalJoin builds natural join output schema. It finds out all the common columns
+// then using the same mechanism as buildUsingClause to eliminate redundant columns and build join conditions.
+// According to standard SQL, producing this display order:
+//
+//	All the common columns
+//	Every column in the first (left) table that is not a common column
+//	Every column in the second (right) table that is not a common column
+func (b *PlanBuilder) buildNaturalJoin(p *LogicalJoin, leftPlan, rightPlan LogicalPlan, join *ast.Join) error {
+	return b.coalesceCommonColumns(p, leftPlan, rightPlan, join.Tp == ast.RightJoin, nil)
+}
+
+// coalesceCommonColumns is used by buildUsingClause and buildNaturalJoin. The filter is used by buildUsingClause.
+func (b *PlanBuilder) coalesceCommonColumns(p *LogicalJoin, leftPlan, rightPlan LogicalPlan, rightJoin bool, filter map[string]bool) error {
+	lsc := leftPlan.Schema().Clone()
+	rsc := rightPlan.Schema().Clone()
+	lColumns, rColumns := lsc.Columns, rsc.Columns
+	if rightJoin {
+		lColumns, rColumns = rsc.Columns, lsc.Columns
+	}
+
+	// Find out all the common columns and put them ahead.
+	commonLen := 0
+	for i, lCol := range lColumns {
+		for j := commonLen; j < len(rColumns); j++ {
+			if lCol.ColName.L != rColumns[j].ColName.L {
+				continue
+			}
+
+			if len(filter) > 0 {
+				if !filter[lCol.ColName.L] {
+					break
+				}
+				// Mark this column exist.
+				filter[lCol.ColName.L] = false
+			}
+
+			col := lColumns[i]
+			copy(lColumns[commonLen+1:i+1], lColumns[commonLen:i])
+			lColumns[commonLen] = col
+
+			col = rColumns[j]
+			copy(rColumns[commonLen+1:j+1], rColumns[commonLen:j])
+			rColumns[commonLen] = col
+
+			commonLen++
+			break
+		}
+	}
+
+	if len(filter) > 0 && len(filter) != commonLen {
+		for col, notExist := range filter {
+			if notExist {
+				return ErrUnknownColumn.GenWithStackByArgs(col, "from clause")
+			}
+		}
+	}
+
+	schemaCols := make([]*expression.Column, len(lColumns)+len(rColumns)-commonLen)
+	copy(schemaCols[:len(lColumns)], lColumns)
+	copy(schemaCols[len(lColumns):], rColumns[commonLen:])
+
+	conds := make([]expression.Expression, 0, commonLen)
+	for i := 0; i < commonLen; i++ {
+		lc, rc := lsc.Columns[i], rsc.Columns[i]
+		cond, err := expression.NewFunction(b.ctx, ast.EQ, types.NewFieldType(mysql.TypeTiny), lc, rc)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		conds = append(conds, cond)
+	}
+
+	p.SetSchema(expression.NewSchema(schemaCols...))
+	redundant := expression.NewSchema(rColumns[:commonLen]...)
+	if p.JoinType == LeftOuterJoin || p.JoinType == RightOuterJoin {
+		// The columns of the inner side are NULL for the outer rows without matches.
+		resetNotNullFlag(p.schema, len(lColumns), p.schema.Len())
+		resetNotNullFlag(redundant, 0, redundant.Len())
+	}
+	p.redundantSchema = expression.MergeSchema(p.redundantSchema, redundant)
+	p.attachOnConds(conds)
+	return nil
+}
+
+func (b *PlanBuilder) buildDataSource(tn *ast.TableName) (LogicalPlan, error) {
+	dbName, tableInfo, err := b.tableByName(tn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	ds := DataSource{
+		DBName:    dbName,
+		tableInfo: tableInfo,
+		Columns:   make([]*model.ColumnInfo, 0, len(tableInfo.Columns)),
+	}.Init(b.ctx)
+	for _, col := range tableInfo.Columns {
+		if col.State != model.StatePublic {
+			continue
+		}
+		ds.Columns = append(ds.Columns, col)
+	}
+	// The rows to update or delete are located by their handles, the handle is read as an
+	// extra column if it is not the primary key.
+	if b.withRowHandle && !tableInfo.PKIsHandle {
+		ds.Columns = append(ds.Columns, model.NewExtraHandleColInfo())
+	}
+	ds.SetSchema(b.buildTableSchema(dbName, tableInfo, ds.Columns))
+
+	// The tables of the memory databases are readable by all the users, UPDATE and DELETE
+	// require the privileges on the columns they read only.
+	if infoschema.IsMemoryDB(dbName.L) {
+		return ds, nil
+	}
+	if !b.withRowHandle {
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.SelectPriv, dbName.L, tableInfo.Name.L, "")
+	}
+	for i, col := range ds.Columns {
+		if col.ID != model.ExtraHandleID {
+			b.tableColumns[ds.schema.Columns[i].UniqueID] = visitInfo{
+				privilege: mysql.SelectPriv, db: dbName.L, table: tableInfo.Name.L, column: col.Name.L}
+		}
+	}
+	return ds, nil
+}
+
+// tableByName returns the database name and the information of the table tn refers to.
+func (b *PlanBuilder) tableByName(tn *ast.TableName) (model.CIStr, *model.TableInfo, error) {
+	dbName := tn.Schema
+	if dbName.L == "" {
+		dbName = model.NewCIStr(b.ctx.GetSessionVars().CurrentDB)
+	}
+	if dbName.L == "" {
+		return dbName, nil, ErrNoDB
+	}
+	tableInfo, err := b.is.TableByName(dbName, tn.Name)
+	if err != nil {
+		return dbName, nil, errors.Trace(err)
+	}
+	return dbName, tableInfo, nil
+}
+
+// buildTableSchema builds the schema of the columns of the table.
+func (b *PlanBuilder) buildTableSchema(dbName model.CIStr, tableInfo *model.TableInfo, cols []*model.ColumnInfo) *expression.Schema {
+	schema := expression.NewSchema(make([]*expression.Column, 0, len(cols))...)
+	for _, col := range cols {
+		schema.Append(&expression.Column{
+			UniqueID:    b.ctx.GetSessionVars().AllocPlanColumnID(),
+			DBName:      dbName,
+			TblName:     tableInfo.Name,
+			ColName:     col.Name,
+			OrigColName: col.Name,
+			RetType:     &col.FieldType,
+			ID:          col.ID,
+		})
+	}
+	return schema
+}
+
+func (b *PlanBuilder) buildTableDual() *LogicalTableDual {
+	return LogicalTableDual{RowCount: 1}.Init(b.ctx)
+}
+
+// splitWhere split a where expression to a list of AND conditions.
+func splitWhere(where ast.ExprNode) []ast.ExprNode {
+	var conditions []ast.ExprNode
+	switch x := where.(type) {
+	case nil:
+	case *ast.BinaryOperationExpr:
+		if x.Op.String() == ast.LogicAnd {
+			conditions = append(conditions, splitWhere(x.L)...)
+			conditions = append(conditions, splitWhere(x.R)...)
+		} else {
+			conditions = append(conditions, x)
+		}
+	case *ast.ParenthesesExpr:
+		conditions = append(conditions, splitWhere(x.Expr)...)
+	default:
+		conditions = append(conditions, where)
+	}
+	return conditions
+}
+
+func (b *PlanBuilder) buildSelection(p LogicalPlan, where ast.ExprNode) (LogicalPlan, error) {
+	conditions := splitWhere(where)
+	expressions := make([]expression.Expression, 0, len(conditions))
+	for _, cond := range conditions {
+		expr, np, err := b.rewrite(cond, p, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		p = np
+		for _, item := range expression.SplitCNFItems(expr) {
+			if con, ok := item.(*expression.Constant); ok {
+				ret, err := expression.EvalBool(b.ctx, expression.CNFExprs{con}, chunk.Row{})
+				if err != nil {
+					return nil, errors.Trace(err)
+				}
+				if ret {
+					continue
+				}
+				// If there is condition which is always false, return dual plan directly.
+				dual := LogicalTableDual{}.Init(b.ctx)
+				dual.SetSchema(p.Schema())
+				return dual, nil
+			}
+			expressions = append(expressions, item)
+		}
+	}
+	if len(expressions) == 0 {
+		return p, nil
+	}
+	selection := LogicalSelection{Conditions: expressions}.Init(b.ctx)
+	selection.SetChildren(p)
+	return selection, nil
+}
+
+// unfoldWildStar replaces the wildcards in the select fields with the columns of the plan.
+func (b *PlanBuilder) unfoldWildStar(p LogicalPlan, selectFields []*ast.SelectField) (resultList []*ast.SelectField, err error) {
+	for _, field := range selectFields {
+		if field.WildCard == nil {
+			resultList = append(resultList, field)
+			continue
+		}
+		dbName := field.WildCard.Schema
+		tblName := field.WildCard.Table
+		findTblNameInSchema := false
+		for _, col := range p.Schema().Columns {
+			if col.ID == model.ExtraHandleID {
+				continue
+			}
+			if (dbName.L == "" || dbName.L == col.DBName.L) &&
+				(tblName.L == "" || tblName.L == col.TblName.L) {
+				findTblNameInSchema = true
+				colName := &ast.ColumnNameExpr{
+					Name: &ast.ColumnName{
+						Schema: col.DBName,
+						Table:  col.TblName,
+						Name:   col.ColName,
+					}}
+				newField := &ast.SelectField{Expr: colName}
+				newField.SetText(col.ColName.O)
+				resultList = append(resultList, newField)
+			}
+		}
+		if !findTblNameInSchema {
+			if tblName.L == "" {
+				return nil, ErrNoTablesUsed
+			}
+			return nil, ErrBadTable.GenWithStackByArgs(tblName)
+		}
+	}
+	return resultList, nil
+}
+
+// buildProjection returns a Projection plan and the number of the fields that are not auxiliary.
+func (b *PlanBuilder) buildProjection(p LogicalPlan, fields []*ast.SelectField) (LogicalPlan, int, error) {
+	b.curClause = fieldList
+	proj := LogicalProjection{Exprs: make([]expression.Expression, 0, len(fields))}.Init(b.ctx)
+	schema := expression.NewSchema(make([]*expression.Column, 0, len(fields))...)
+	oldLen := 0
+	for _, field := range fields {
+		newExpr, np, err := b.rewrite(field.Expr, p, true)
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+		p = np
+		if !field.Auxiliary {
+			oldLen++
+		}
+		proj.Exprs = append(proj.Exprs, newExpr)
+		schema.Append(b.buildProjectionField(field, newExpr))
+	}
+	proj.SetSchema(schema)
+	proj.SetChildren(p)
+	return proj, oldLen, nil
+}
+
+// buildProjectionField builds the output column of a select field, a column reference keeps
+// the names of the column, other expressions are named by their text.
+func (b *PlanBuilder) buildProjectionField(field *ast.SelectField, expr expression.Expression) *expression.Column {
+	newCol := &expression.Column{
+		UniqueID: b.ctx.GetSessionVars().AllocPlanColumnID(),
+		RetType:  expr.GetType(),
+	}
+	if c, ok := expr.(*expression.Column); ok {
+		if _, ok := getInnerFromParentheses(field.Expr).(*ast.ColumnNameExpr); ok {
+			newCol.ColName, newCol.OrigColName = c.ColName, c.OrigColName
+			newCol.TblName, newCol.OrigTblName = c.TblName, c.OrigTblName
+			newCol.DBName = c.DBName
+			if field.AsName.L != "" {
+				newCol.ColName = field.AsName
+			}
+			return newCol
+		}
+	}
+	newCol.ColName = buildProjectionFieldNameFromExpressions(field)
+	return newCol
+}
+
+func getInnerFromParentheses(expr ast.ExprNode) ast.ExprNode {
+	if pexpr, ok := expr.(*ast.ParenthesesExpr); ok {
+		return getInnerFromParentheses(pexpr.Expr)
+	}
+	return expr
+}
+
+// buildProjectionFieldNameFromExpressions builds the field name when field expression is a normal expression.
+func buildProjectionFieldNameFromExpressions(field *ast.SelectField) model.CIStr {
+	if field.AsName.L != "" {
+		return field.AsName
+	}
+	valueExpr, ok := getInnerFromParentheses(field.Expr).(*driver.ValueExpr)
+	if !ok {
+		return model.NewCIStr(field.Text())
+	}
+	switch valueExpr.Kind() {
+	case types.KindString:
+		// For string literals, string content is used as column name. Non-graph initial characters are trimmed.
+		projName := valueExpr.GetString()
+		if projOffset := valueExpr.GetProjectionOffset(); projOffset >= 0 {
+			projName = projName[:projOffset]
+		}
+		return model.NewCIStr(strings.TrimLeftFunc(projName, func(r rune) bool {
+			return !unicode.IsOneOf(mysql.RangeGraph, r)
+		}))
+	case types.KindNull:
+		return model.NewCIStr("NULL")
+	}
+	return model.NewCIStr(field.Text())
+}
+
+// getUintFromNode gets the uint64 value of a LIMIT or OFFSET, which must be a non-negative integer.
+func getUintFromNode(n ast.Node) (uVal uint64, isValid bool) {
+	var v *driver.ValueExpr
+	switch x := n.(type) {
+	case *driver.ValueExpr:
+		v = x
+	case *driver.ParamMarkerExpr:
+		v = &x.ValueExpr
+	default:
+		return 0, false
+	}
+	switch v.Kind() {
+	case types.KindInt64:
+		if v.GetInt64() >= 0 {
+			return uint64(v.GetInt64()), true
+		}
+	case types.KindUint64:
+		return v.GetUint64(), true
+	}
+	return 0, false
+}
+
+func extractLimitCountOffset(limit *ast.Limit) (count uint64, offset uint64, err error) {
+	var isValid bool
+	if limit.Count != nil {
+		if count, isValid = getUintFromNode(limit.Count); !isValid {
+			return 0, 0, ErrWrongArguments.GenWithStackByArgs("LIMIT")
+		}
+	}
+	if limit.Offset != nil {
+		if offset, isValid = getUintFromNode(limit.Offset); !isValid {
+			return 0, 0, ErrWrongArguments.GenWithStackByArgs("LIMIT")
+		}
+	}
+	return count, offset, nil
+}
+
+func (b *PlanBuilder) buildLimit(src LogicalPlan, limit *ast.Limit) (LogicalPlan, error) {
+	count, offset, err := extractLimitCountOffset(limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if count > math.MaxUint64-offset {
+		count = math.MaxUint64 - offset
+	}
+	if count == 0 {
+		dual := LogicalTableDual{}.Init(b.ctx)
+		dual.SetSchema(src.Schema())
+		return dual, nil
+	}
+	li := LogicalLimit{
+		Offset: offset,
+		Count:  count,
+	}.Init(b.ctx)
+	li.SetChildren(src)
+	return li, nil
+}
+
+// havingAndOrderbyExprResolver resolves the columns and the aggregate functions in HAVING and ORDER BY
+// to the select fields, the ones that can't be found in the select fields are appended as auxiliary fields.
+type havingAndOrderbyExprResolver struct {
+	inAggFunc    bool
+	err          error
+	p            LogicalPlan
+	selectFields []*ast.SelectField
+	// fieldCount is the number of the select fields that are not auxiliary.
+	fieldCount int
+	colMapper  map[ast.Node]int
+	curClause  clauseCode
+}
+
+// Enter implements Visitor interface.
+func (a *havingAndOrderbyExprResolver) Enter(n ast.Node) (node ast.Node, skipChildren bool) {
+	switch n.(type) {
+	case *ast.AggregateFuncExpr:
+		a.inAggFunc = true
+	case *ast.SubqueryExpr:
+		// The columns of a subquery are resolved when it is built.
+		return n, true
+	}
+	return n, false
+}
+
+func (a *havingAndOrderbyExprResolver) addAuxiliaryField(expr ast.ExprNode) int {
+	a.selectFields = append(a.selectFields, &ast.SelectField{Expr: expr, Auxiliary: true})
+	return len(a.selectFields) - 1
+}
+
+// resolveFromSelectFields finds the select field that is the column or whose alias is the column name.
+func (a *havingAndOrderbyExprResolver) resolveFromSelectFields(v *ast.ColumnNameExpr) int {
+	name := v.Name
+	for i, field := range a.selectFields {
+		if c, ok := field.Expr.(*ast.ColumnNameExpr); ok &&
+			(name.Schema.L == "" || name.Schema.L == c.Name.Schema.L) &&
+			(name.Table.L == "" || name.Table.L == c.Name.Table.L) &&
+			name.Name.L == c.Name.Name.L {
+			return i
+		}
+		if name.Table.L == "" && field.AsName.L != "" && field.AsName.L == name.Name.L {
+			return i
+		}
+	}
+	return -1
+}
+
+// Leave implements Visitor interface.
+func (a *havingAndOrderbyExprResolver) Leave(n ast.Node) (node ast.Node, ok bool) {
+	switch v := n.(type) {
+	case *ast.AggregateFuncExpr:
+		a.inAggFunc = false
+		a.colMapper[v] = a.addAuxiliaryField(v)
+	case *ast.ColumnNameExpr:
+		if a.inAggFunc {
+			break
+		}
+		idx := a.resolveFromSelectFields(v)
+		if idx == -1 {
+			col, err := a.p.Schema().FindColumn(v.Name)
+			if err != nil {
+				a.err = errors.Trace(err)
+				return n, false
+			}
+			if col == nil {
+				a.err = ErrUnknownColumn.GenWithStackByArgs(v.Name.OrigColName(), clauseMsg[a.curClause])
+				return n, false
+			}
+			idx = a.addAuxiliaryField(v)
+		}
+		a.colMapper[v] = idx
+	case *ast.PositionExpr:
+		if v.P != nil {
+			a.err = ErrNotSupportedYet.GenWithStackByArgs("parameterized position")
+			return n, false
+		}
+		if v.N < 1 || v.N > a.fieldCount {
+			a.err = ErrUnknownColumn.GenWithStackByArgs(strconv.Itoa(v.N), clauseMsg[a.curClause])
+			return n, false
+		}
+		a.colMapper[v] = v.N - 1
+	}
+	return n, true
+}
+
+// resolveHavingAndOrderBy returns the select fields with the auxiliary ones and the mapper from the
+// expressions in HAVING and ORDER BY to the fields.
+func (b *PlanBuilder) resolveHavingAndOrderBy(sel *ast.SelectStmt, p LogicalPlan) ([]*ast.SelectField, map[ast.Node]int, error) {
+	extractor := &havingAndOrderbyExprResolver{
+		p:            p,
+		selectFields: append([]*ast.SelectField(nil), sel.Fields.Fields...),
+		fieldCount:   len(sel.Fields.Fields),
+		colMapper:    make(map[ast.Node]int),
+	}
+	if sel.Having != nil {
+		extractor.curClause = havingClause
+		sel.Having.Expr.Accept(extractor)
+		if extractor.err != nil {
+			return nil, nil, errors.Trace(extractor.err)
+		}
+	}
+	if sel.OrderBy != nil {
+		extractor.curClause = orderByClause
+		for _, item := range sel.OrderBy.Items {
+			item.Expr.Accept(extractor)
+			if extractor.err != nil {
+				return nil, nil, errors.Trace(extractor.err)
+			}
+		}
+	}
+	return extractor.selectFields, extractor.colMapper, nil
+}
+
+// aggExtractor collects the aggregate functions, the arguments of them and the subqueries are not visited.
+type aggExtractor struct {
+	aggFuncs []*ast.AggregateFuncExpr
+}
+
+// Enter implements Visitor interface.
+func (a *aggExtractor) Enter(n ast.Node) (ast.Node, bool) {
+	switch v := n.(type) {
+	case *ast.AggregateFuncExpr:
+		a.aggFuncs = append(a.aggFuncs, v)
+		return n, true
+	case *ast.SubqueryExpr:
+		return n, true
+	}
+	return n, false
+}
+
+// Leave implements Visitor interface.
+func (a *aggExtractor) Leave(n ast.Node) (ast.Node, bool) {
+	return n, true
+}
+
+func extractAggFuncs(fields []*ast.SelectField) []*ast.AggregateFuncExpr {
+	extractor := &aggExtractor{}
+	for _, f := range fields {
+		f.Expr.Accept(extractor)
+	}
+	return extractor.aggFuncs
+}
+
+// resolveGbyExprs rewrites the GROUP BY items, a position refers to a select field and a column name
+// that is not in the FROM clause refers to the alias of a select field.
+func (b *PlanBuilder) resolveGbyExprs(p LogicalPlan, gby *ast.GroupByClause, fields []*ast.SelectField) (LogicalPlan, []expression.Expression, error) {
+	b.curClause = groupByClause
+	exprs := make([]expression.Expression, 0, len(gby.Items))
+	for _, item := range gby.Items {
+		node := item.Expr
+		var field *ast.SelectField
+		switch v := node.(type) {
+		case *ast.PositionExpr:
+			if v.P != nil {
+				return nil, nil, ErrNotSupportedYet.GenWithStackByArgs("parameterized position")
+			}
+			if v.N < 1 || v.N > len(fields) {
+				return nil, nil, ErrUnknownColumn.GenWithStackByArgs(strconv.Itoa(v.N), clauseMsg[groupByClause])
+			}
+			field = fields[v.N-1]
+		case *ast.ColumnNameExpr:
+			col, err := p.Schema().FindColumn(v.Name)
+			if err != nil {
+				return nil, nil, errors.Trace(err)
+			}
+			if col != nil || v.Name.Table.L != "" {
+				break
+			}
+			for _, f := range fields {
+				if f.AsName.L == v.Name.Name.L {
+					field = f
+					break
+				}
+			}
+		}
+		if field != nil {
+			if len(extractAggFuncs([]*ast.SelectField{field})) > 0 {
+				return nil, nil, ErrWrongGroupField.GenWithStackByArgs(buildProjectionFieldNameFromExpressions(field).O)
+			}
+			node = field.Expr
+		}
+		expr, np, err := b.rewrite(node, p, true)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		p = np
+		exprs = append(exprs, expr)
+	}
+	return p, exprs, nil
+}
+
+// buildAggregation builds the aggregation over p. A first_row function is added for every column of p,
+// its output column keeps the unique id of the column, so the columns can still be referred to.
+func (b *PlanBuilder) buildAggregation(p LogicalPlan, aggFuncList []*ast.AggregateFuncExpr, gbyItems []expression.Expression) (LogicalPlan, error) {
+	b.curClause = fieldList
+	plan4Agg := LogicalAggregation{AggFuncs: make([]*aggregation.AggFuncDesc, 0, len(aggFuncList))}.Init(b.ctx)
+	schema4Agg := expression.NewSchema(make([]*expression.Column, 0, len(aggFuncList)+p.Schema().Len())...)
+	aggMapper := make(map[*ast.AggregateFuncExpr]int, len(aggFuncList))
+	for _, aggFunc := range aggFuncList {
+		if !aggregation.IsSupported(aggFunc.F) {
+			return nil, ErrNotSupportedYet.GenWithStackByArgs(strings.ToUpper(aggFunc.F))
+		}
+		newArgs := make([]expression.Expression, 0, len(aggFunc.Args))
+		for _, arg := range aggFunc.Args {
+			newArg, np, err := b.rewrite(arg, p, true)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			p = np
+			newArgs = append(newArgs, newArg)
+		}
+		newFunc, err := aggregation.NewAggFuncDesc(b.ctx, aggFunc.F, newArgs, aggFunc.Distinct)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		combined := false
+		for j, oldFunc := range plan4Agg.AggFuncs {
+			if oldFunc.Equal(newFunc) {
+				aggMapper[aggFunc] = j
+				combined = true
+				break
+			}
+		}
+		if combined {
+			continue
+		}
+		aggMapper[aggFunc] = len(plan4Agg.AggFuncs)
+		plan4Agg.AggFuncs = append(plan4Agg.AggFuncs, newFunc)
+		schema4Agg.Append(&expression.Column{
+			ColName:     model.NewCIStr(fmt.Sprintf("%d_col_%d", plan4Agg.id, len(schema4Agg.Columns))),
+			UniqueID:    b.ctx.GetSessionVars().AllocPlanColumnID(),
+			IsAggOrSubq: true,
+			RetType:     newFunc.RetTp,
+		})
+	}
+	for _, col := range p.Schema().Columns {
+		newFunc, err := aggregation.NewAggFuncDesc(b.ctx, ast.AggFuncFirstRow, []expression.Expression{col}, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		plan4Agg.AggFuncs = append(plan4Agg.AggFuncs, newFunc)
+		newCol := col.Clone().(*expression.Column)
+		newCol.RetType = newFunc.RetTp
+		schema4Agg.Append(newCol)
+	}
+	plan4Agg.SetChildren(p)
+	plan4Agg.GroupByItems = gbyItems
+	plan4Agg.SetSchema(schema4Agg)
+	b.aggMapper = aggMapper
+	return plan4Agg, nil
+}
+
+// buildDistinct builds an aggregation grouped by the first length columns of the child,
+// the rows of the same group are reduced to the first one.
+func (b *PlanBuilder) buildDistinct(child LogicalPlan, length int) (*LogicalAggregation, error) {
+	plan4Agg := LogicalAggregation{
+		GroupByItems: expression.Column2Exprs(child.Schema().Clone().Columns[:length]),
+		AggFuncs:     make([]*aggregation.AggFuncDesc, 0, child.Schema().Len()),
+	}.Init(b.ctx)
+	for _, col := range child.Schema().Columns {
+		aggDesc, err := aggregation.NewAggFuncDesc(b.ctx, ast.AggFuncFirstRow, []expression.Expression{col}, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		plan4Agg.AggFuncs = append(plan4Agg.AggFuncs, aggDesc)
+	}
+	plan4Agg.SetChildren(child)
+	plan4Agg.SetSchema(child.Schema().Clone())
+	// Distinct will be rewritten as first_row, we reset the type here since the return type
+	// of first_row is not always the same as the column arg of first_row.
+	for i, col := range plan4Agg.schema.Columns {
+		col.RetType = plan4Agg.AggFuncs[i].RetTp
+	}
+	return plan4Agg, nil
+}
+
+func (b *PlanBuilder) buildSort(p LogicalPlan, byItems []*ast.ByItem) (LogicalPlan, error) {
+	b.curClause = orderByClause
+	sort := LogicalSort{}.Init(b.ctx)
+	exprs := make([]*ByItems, 0, len(byItems))
+	for _, item := range byItems {
+		it, np, err := b.rewrite(item.Expr, p, true)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		p = np
+		// Ordering by a constant has no effect.
+		if _, ok := it.(*expression.Constant); ok {
+			continue
+		}
+		exprs = append(exprs, &ByItems{Expr: it, Desc: item.Desc})
+	}
+	if len(exprs) == 0 {
+		return p, nil
+	}
+	sort.ByItems = exprs
+	sort.SetChildren(p)
+	return sort, nil
+}
+
+func (b *PlanBuilder) buildUnion(union *ast.UnionStmt) (LogicalPlan, error) {
+	distinctSelectPlans, allSelectPlans, err := b.divideUnionSelectPlans(union.SelectList.Selects)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	unionDistinctPlan := b.buildUnionAll(distinctSelectPlans)
+	if unionDistinctPlan != nil {
+		unionDistinctPlan, err = b.buildDistinct(unionDistinctPlan, unionDistinctPlan.Schema().Len())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if len(allSelectPlans) > 0 {
+			allSelectPlans = append([]LogicalPlan{unionDistinctPlan}, allSelectPlans...)
+		}
+	}
+
+	unionPlan := unionDistinctPlan
+	if unionAllPlan := b.buildUnionAll(allSelectPlans); unionAllPlan != nil {
+		unionPlan = unionAllPlan
+	}
+
+	if union.OrderBy != nil {
+		oldColMapper := b.colMapper
+		b.colMapper = make(map[ast.Node]int)
+		for _, item := range union.OrderBy.Items {
+			if v, ok := item.Expr.(*ast.PositionExpr); ok {
+				if v.P != nil {
+					return nil, ErrNotSupportedYet.GenWithStackByArgs("parameterized position")
+				}
+				if v.N < 1 || v.N > unionPlan.Schema().Len() {
+					return nil, ErrUnknownColumn.GenWithStackByArgs(strconv.Itoa(v.N), clauseMsg[orderByClause])
+				}
+				b.colMapper[v] = v.N - 1
+			}
+		}
+		unionPlan, err = b.buildSort(unionPlan, union.OrderBy.Items)
+		b.colMapper = oldColMapper
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	if union.Limit != nil {
+		unionPlan, err = b.buildLimit(unionPlan, union.Limit)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return unionPlan, nil
+}
+
+// divideUnionSelectPlans resolves union's select stmts to logical plans.
+// and divide result plans into "union-distinct" and "union-all" parts.
+// divide rule ref: https://dev.mysql.com/doc/refman/5.7/en/union.html
+// "Mixed UNION types are treated such that a DISTINCT union overrides any ALL union to its left."
+func (b *PlanBuilder) divideUnionSelectPlans(selects []*ast.SelectStmt) (distinctSelects []LogicalPlan, allSelects []LogicalPlan, err error) {
+	firstUnionAllIdx, columnNums := 0, -1
+	children := make([]LogicalPlan, len(selects))
+	for i := len(selects) - 1; i >= 0; i-- {
+		stmt := selects[i]
+		if firstUnionAllIdx == 0 && stmt.IsAfterUnionDistinct {
+			firstUnionAllIdx = i + 1
+		}
+
+		selectPlan, err := b.buildSelect(stmt)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+
+		if columnNums == -1 {
+			columnNums = selectPlan.Schema().Len()
+		}
+		if selectPlan.Schema().Len() != columnNums {
+			return nil, nil, ErrWrongNumberOfColumnsInSelect.GenWithStackByArgs()
+		}
+		children[i] = selectPlan
+	}
+	return children[:firstUnionAllIdx], children[firstUnionAllIdx:], nil
+}
+
+func (b *PlanBuilder) buildUnionAll(subPlan []LogicalPlan) LogicalPlan {
+	if len(subPlan) == 0 {
+		return nil
+	}
+	u := LogicalUnionAll{}.Init(b.ctx)
+	u.children = subPlan
+	b.buildProjection4Union(u)
+	return u
+}
+
+// buildProjection4Union infers the result types of the union, a projection is added over every child
+// to cast the columns to the result types.
+func (b *PlanBuilder) buildProjection4Union(u *LogicalUnionAll) {
+	unionCols := make([]*expression.Column, 0, u.children[0].Schema().Len())
+
+	// Infer union result types by its children's schema.
+	for i, col := range u.children[0].Schema().Columns {
+		resultTp := col.RetType
+		for j := 1; j < len(u.children); j++ {
+			childTp := u.children[j].Schema().Columns[i].RetType
+			resultTp = unionJoinFieldType(resultTp, childTp)
+		}
+		unionCols = append(unionCols, &expression.Column{
+			ColName:  col.ColName,
+			RetType:  resultTp,
+			UniqueID: b.ctx.GetSessionVars().AllocPlanColumnID(),
+		})
+	}
+	u.schema = expression.NewSchema(unionCols...)
+	// Process each child and add a projection above original child.
+	// So the schema of `UnionAll` can be the same with its children's.
+	for childID, child := range u.children {
+		exprs := make([]expression.Expression, len(child.Schema().Columns))
+		for i, srcCol := range child.Schema().Columns {
+			dstType := unionCols[i].RetType
+			srcType := srcCol.RetType
+			exprs[i] = srcCol
+			if !srcType.Equal(dstType) {
+				// The cast can't fail since the target type is given.
+				exprs[i] = expression.NewFunctionInternal(b.ctx, ast.Cast, dstType, srcCol)
+			}
+		}
+		proj := LogicalProjection{Exprs: exprs}.Init(b.ctx)
+		proj.SetSchema(u.schema.Clone())
+		proj.SetChildren(child)
+		u.children[childID] = proj
+	}
+}
+
+// unionJoinFieldType merges the types of the same column of two selects in a union.
+func unionJoinFieldType(a, b *types.FieldType) *types.FieldType {
+	resultTp := types.NewFieldType(types.MergeFieldType(a.Tp, b.Tp))
+	if resultTp.Tp == mysql.TypeNewDecimal {
+		// The decimal result type will be unsigned only when all the decimals to be united are unsigned.
+		resultTp.Flag |= a.Flag & b.Flag & mysql.UnsignedFlag
+	} else {
+		// Non-decimal results will be unsigned when the first SQL statement result in the union is unsigned.
+		resultTp.Flag |= a.Flag & mysql.UnsignedFlag
+	}
+	resultTp.Decimal = a.Decimal
+	if b.Decimal > resultTp.Decimal {
+		resultTp.Decimal = b.Decimal
+	}
+	// `Flen - Decimal` is the fraction before '.'
+	resultTp.Flen = a.Flen - a.Decimal
+	if b.Flen-b.Decimal > resultTp.Flen {
+		resultTp.Flen = b.Flen - b.Decimal
+	}
+	resultTp.Flen += resultTp.Decimal
+	switch {
+	case !resultTp.EvalType().IsStringKind():
+		types.SetBinChsClnFlag(resultTp)
+	case a.EvalType().IsStringKind():
+		resultTp.Charset, resultTp.Collate = a.Charset, a.Collate
+	case b.EvalType().IsStringKind():
+		resultTp.Charset, resultTp.Collate = b.Charset, b.Collate
+	default:
+		resultTp.Charset, resultTp.Collate = charset.GetDefaultCharsetAndCollate()
+	}
+	return resultTp
+}
+
+// buildDMLSelectPlan builds the plan that reads the rows to update or delete. The data sources
+// that the rows come from directly are returned, they are the tables that can be written,
+// the derived tables can't.
+func (b *PlanBuilder) buildDMLSelectPlan(tableRefs *ast.TableRefsClause, where ast.ExprNode,
+	order *ast.OrderByClause, limit *ast.Limit) (LogicalPlan, []*DataSource, error) {
+	b.withRowHandle = true
+	p, err := b.buildResultSetNode(tableRefs.TableRefs)
+	b.withRowHandle = false
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	dataSources := collectDataSources(p, nil)
+
+	if where != nil {
+		b.curClause = whereClause
+		p, err = b.buildSelection(p, where)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	if order != nil {
+		p, err = b.buildSort(p, order.Items)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	if limit != nil {
+		p, err = b.buildLimit(p, limit)
+		if err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+	}
+	return p, dataSources, nil
+}
+
+// collectDataSources appends the data sources of the tables joined in p to dataSources.
+func collectDataSources(p LogicalPlan, dataSources []*DataSource) []*DataSource {
+	switch x := p.(type) {
+	case *DataSource:
+		dataSources = append(dataSources, x)
+	case *LogicalJoin:
+		for _, child := range x.children {
+			dataSources = collectDataSources(child, dataSources)
+		}
+	}
+	return dataSources
+}
+
+// getHandleCol returns the column of the handle, the data source must be built with the handle.
+func (ds *DataSource) getHandleCol() *expression.Column {
+	for i, col := range ds.Columns {
+		if (ds.tableInfo.PKIsHandle && mysql.HasPriKeyFlag(col.Flag)) || col.ID == model.ExtraHandleID {
+			return ds.schema.Columns[i]
+		}
+	}
+	return nil
+}
+
+// buildTblColPosInfos returns the positions of the columns of the tables of the data sources in
+// the schema of the plan that reads the rows.
+func buildTblColPosInfos(dataSources []*DataSource, schema *expression.Schema) ([]TblColPosInfo, error) {
+	infos := make([]TblColPosInfo, 0, len(dataSources))
+	for _, ds := range dataSources {
+		// The extra handle column follows the public columns.
+		numCols := len(ds.Columns)
+		if !ds.tableInfo.PKIsHandle {
+			numCols--
+		}
+		start := schema.ColumnIndex(ds.schema.Columns[0])
+		handleOrdinal := schema.ColumnIndex(ds.getHandleCol())
+		if start == -1 || handleOrdinal == -1 {
+			return nil, errors.Errorf("the columns of table %s are not read", ds.tableInfo.Name)
+		}
+		infos = append(infos, TblColPosInfo{
+			TblID:         ds.tableInfo.ID,
+			Start:         start,
+			End:           start + numCols,
+			HandleOrdinal: handleOrdinal,
+		})
+	}
+	return infos, nil
+}
+
+func (b *PlanBuilder) buildUpdate(update *ast.UpdateStmt) (Plan, error) {
+	p, dataSources, err := b.buildDMLSelectPlan(update.TableRefs, update.Where, update.Order, update.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	b.curClause = fieldList
+	assigns := make([]*expression.Assignment, 0, len(update.List))
+	var targets []*DataSource
+	for _, assign := range update.List {
+		col, err := p.Schema().FindColumn(assign.Column)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if col == nil || col.ID == model.ExtraHandleID {
+			return nil, ErrUnknownColumn.GenWithStackByArgs(assign.Column.OrigColName(), clauseMsg[fieldList])
+		}
+		var target *DataSource
+		for _, ds := range dataSources {
+			if ds.schema.Contains(col) {
+				target = ds
+				break
+			}
+		}
+		if target == nil || infoschema.IsMemoryDB(target.DBName.L) {
+			return nil, ErrNonUpdatableTable.GenWithStackByArgs(col.TblName.O, "UPDATE")
+		}
+
+		var expr expression.Expression
+		if v, ok := assign.Expr.(*ast.DefaultExpr); ok && v.Name == nil {
+			expr, err = b.buildDefaultValue(target.Columns[target.schema.ColumnIndex(col)])
+		} else {
+			expr, p, err = b.rewrite(assign.Expr, p, true)
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		expr, err = expression.BuildCastFunction(b.ctx, expr, col.RetType)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		assigns = append(assigns, &expression.Assignment{Col: col, Expr: expr})
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.UpdatePriv, target.DBName.L, target.tableInfo.Name.L, "")
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.UpdatePriv, target.DBName.L, target.tableInfo.Name.L,
+			target.Columns[target.schema.ColumnIndex(col)].Name.L)
+
+		found := false
+		for _, ds := range targets {
+			found = found || ds == target
+		}
+		if !found {
+			targets = append(targets, target)
+		}
+	}
+
+	selectPlan, err := DoOptimize(p)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	updatePlan := Update{
+		SelectPlan:  selectPlan,
+		OrderedList: make([]*expression.Assignment, 0, len(assigns)),
+	}.Init(b.ctx)
+	updatePlan.TblColPosInfos, err = buildTblColPosInfos(targets, selectPlan.Schema())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, assign := range assigns {
+		col, err := assign.Col.ResolveIndices(selectPlan.Schema())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		expr, err := assign.Expr.ResolveIndices(selectPlan.Schema())
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		updatePlan.OrderedList = append(updatePlan.OrderedList, &expression.Assignment{
+			Col:  col.(*expression.Column),
+			Expr: expr,
+		})
+	}
+	return updatePlan, nil
+}
+
+func (b *PlanBuilder) buildDelete(delete *ast.DeleteStmt) (Plan, error) {
+	p, dataSources, err := b.buildDMLSelectPlan(delete.TableRefs, delete.Where, delete.Order, delete.Limit)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	targets := dataSources
+	if delete.IsMultiTable {
+		targets = make([]*DataSource, 0, len(delete.Tables.Tables))
+		for _, tn := range delete.Tables.Tables {
+			target, err := findDeleteTarget(dataSources, p.Schema(), tn)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			found := false
+			for _, ds := range targets {
+				found = found || ds == target
+			}
+			if !found {
+				targets = append(targets, target)
+			}
+		}
+	}
+
+	for _, ds := range targets {
+		if infoschema.IsMemoryDB(ds.DBName.L) {
+			return nil, ErrNonUpdatableTable.GenWithStackByArgs(ds.tableInfo.Name.O, "DELETE")
+		}
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.DeletePriv, ds.DBName.L, ds.tableInfo.Name.L, "")
+	}
+
+	selectPlan, err := DoOptimize(p)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	deletePlan := Delete{SelectPlan: selectPlan}.Init(b.ctx)
+	deletePlan.TblColPosInfos, err = buildTblColPosInfos(targets, selectPlan.Schema())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return deletePlan, nil
+}
+
+// findDeleteTarget returns the data source of the table named tn in a multiple-table DELETE,
+// the table is referred to by its alias if it has one.
+func findDeleteTarget(dataSources []*DataSource, schema *expression.Schema, tn *ast.TableName) (*DataSource, error) {
+	for _, ds := range dataSources {
+		name := ds.tableInfo.Name
+		if ds.TableAsName != nil && ds.TableAsName.L != "" {
+			name = *ds.TableAsName
+		}
+		if name.L == tn.Name.L && (tn.Schema.L == "" || tn.Schema.L == ds.DBName.L) {
+			return ds, nil
+		}
+	}
+	for _, col := range schema.Columns {
+		if col.TblName.L == tn.Name.L {
+			return nil, ErrNonUpdatableTable.GenWithStackByArgs(tn.Name.O, "DELETE")
+		}
+	}
+	return nil, ErrUnknownTable.GenWithStackByArgs(tn.Name.O, "MULTI DELETE")
+}
@@ -0,0 +1,364 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/logical_plans.go
+//
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+)
+
+var (
+	_ LogicalPlan = &DataSource{}
+	_ LogicalPlan = &LogicalSelection{}
+	_ LogicalPlan = &LogicalProjection{}
+	_ LogicalPlan = &LogicalLimit{}
+	_ LogicalPlan = &LogicalTableDual{}
+	_ LogicalPlan = &LogicalAggregation{}
+	_ LogicalPlan = &LogicalSort{}
+	_ LogicalPlan = &LogicalTopN{}
+	_ LogicalPlan = &LogicalUnionAll{}
+	_ LogicalPlan = &LogicalJoin{}
+	_ LogicalPlan = &LogicalApply{}
+	_ LogicalPlan = &LogicalMaxOneRow{}
+)
+
+const (
+	// TypeDataSource is the type of DataSource.
+	TypeDataSource = "DataSource"
+	// TypeSel is the type of Selection.
+	TypeSel = "Selection"
+	// TypeProj is the type of Projection.
+	TypeProj = "Projection"
+	// TypeLimit is the type of Limit.
+	TypeLimit = "Limit"
+	// TypeDual is the type of TableDual.
+	TypeDual = "TableDual"
+	// TypeTableScan is the type of TableScan.
+	TypeTableScan = "TableScan"
+	// TypeIndexScan is the type of IndexScan.
+	TypeIndexScan = "IndexScan"
+	// TypeMemTableScan is the type of MemTableScan.
+	TypeMemTableScan = "MemTableScan"
+	// TypeIndexLookUp is the type of IndexLookUp.
+	TypeIndexLookUp = "IndexLookUp"
+	// TypeAgg is the type of Aggregation.
+	TypeAgg = "Aggregation"
+	// TypeHashAgg is the type of HashAgg.
+	TypeHashAgg = "HashAgg"
+	// TypeStreamAgg is the type of StreamAgg.
+	TypeStreamAgg = "StreamAgg"
+	// TypeSort is the type of Sort.
+	TypeSort = "Sort"
+	// TypeTopN is the type of TopN.
+	TypeTopN = "TopN"
+	// TypeUnion is the type of UnionAll.
+	TypeUnion = "UnionAll"
+	// TypeJoin is the type of Join.
+	TypeJoin = "Join"
+	// TypeApply is the type of Apply.
+	TypeApply = "Apply"
+	// TypeMaxOneRow is the type of MaxOneRow.
+	TypeMaxOneRow = "MaxOneRow"
+	// TypeHashJoin is the type of hash join.
+	TypeHashJoin = "HashJoin"
+	// TypeMergeJoin is the type of merge join.
+	TypeMergeJoin = "MergeJoin"
+	// TypeIndexJoin is the type of index look up join.
+	TypeIndexJoin = "IndexJoin"
+)
+
+// JoinType contains InnerJoin, LeftOuterJoin, RightOuterJoin, SemiJoin, AntiSemiJoin,
+// LeftOuterSemiJoin and AntiLeftOuterSemiJoin.
+type JoinType int
+
+const (
+	// InnerJoin means inner join.
+	InnerJoin JoinType = iota
+	// LeftOuterJoin means left join.
+	LeftOuterJoin
+	// RightOuterJoin means right join.
+	RightOuterJoin
+	// SemiJoin means if row a in table A matches some rows in B, just output a.
+	SemiJoin
+	// AntiSemiJoin means if row a in table A does not match any row in B, then output a.
+	AntiSemiJoin
+	// LeftOuterSemiJoin means if row a in table A matches some rows in B, output (a, true), otherwise, output (a, false).
+	LeftOuterSemiJoin
+	// AntiLeftOuterSemiJoin means if row a in table A matches some rows in B, output (a, false), otherwise, output (a, true).
+	AntiLeftOuterSemiJoin
+)
+
+// IsOuterJoin returns if this joiner is a outer joiner
+func (tp JoinType) IsOuterJoin() bool {
+	return tp == LeftOuterJoin || tp == RightOuterJoin ||
+		tp == LeftOuterSemiJoin || tp == AntiLeftOuterSemiJoin
+}
+
+// IsSemiJoin returns if the join only returns the rows of the left child, with an auxiliary
+// column of the match result in the left outer semi joins.
+func (tp JoinType) IsSemiJoin() bool {
+	return tp == SemiJoin || tp == AntiSemiJoin ||
+		tp == LeftOuterSemiJoin || tp == AntiLeftOuterSemiJoin
+}
+
+func (tp JoinType) String() string {
+	switch tp {
+	case InnerJoin:
+		return "inner join"
+	case LeftOuterJoin:
+		return "left outer join"
+	case RightOuterJoin:
+		return "right outer join"
+	case SemiJoin:
+		return "semi join"
+	case AntiSemiJoin:
+		return "anti semi join"
+	case LeftOuterSemiJoin:
+		return "left outer semi join"
+	case AntiLeftOuterSemiJoin:
+		return "anti left outer semi join"
+	}
+	return "unsupported join type"
+}
+
+const (
+	preferLeftAsIndexInner = 1 << iota
+	preferRightAsIndexInner
+	preferHashJoin
+	preferMergeJoin
+)
+
+// DataSource represents a tableScan without condition push down.
+type DataSource struct {
+	logicalSchemaProducer
+
+	DBName      model.CIStr
+	TableAsName *model.CIStr
+	tableInfo   *model.TableInfo
+	Columns     []*model.ColumnInfo
+
+	// pushedDownConds are the conditions that will be evaluated on the rows read from the table.
+	pushedDownConds []expression.Expression
+}
+
+// LogicalJoin is the logical join plan.
+type LogicalJoin struct {
+	logicalSchemaProducer
+
+	JoinType JoinType
+
+	// EqualConditions are the "eq" conditions of a column of the left child and a column
+	// of the right child, the first argument is always the column of the left child.
+	EqualConditions []*expression.ScalarFunction
+	// NAConditions are the conditions built from the [NOT] IN and ALL subqueries, they are
+	// evaluated in three-valued logic, a NULL result makes the row of the left child
+	// unknown instead of unmatched.
+	NAConditions    []expression.Expression
+	LeftConditions  expression.CNFExprs
+	RightConditions expression.CNFExprs
+	OtherConditions expression.CNFExprs
+
+	// preferJoinType is the join algorithm chosen by the hints.
+	preferJoinType uint
+
+	// redundantSchema contains columns which are eliminated in join.
+	// For select * from a join b using (c); a.c will in output schema, and b.c will in redundantSchema.
+	redundantSchema *expression.Schema
+}
+
+// extractOnCondition divides conditions in CNF of join node into 4 groups.
+// These conditions can be where conditions, join conditions, or collection of both.
+func (p *LogicalJoin) extractOnCondition(conditions []expression.Expression) (eqCond []*expression.ScalarFunction,
+	leftCond []expression.Expression, rightCond []expression.Expression, otherCond []expression.Expression) {
+	left, right := p.children[0], p.children[1]
+	for _, expr := range conditions {
+		binop, ok := expr.(*expression.ScalarFunction)
+		if ok && binop.FuncName.L == ast.EQ {
+			ln, lOK := binop.GetArgs()[0].(*expression.Column)
+			rn, rOK := binop.GetArgs()[1].(*expression.Column)
+			if lOK && rOK {
+				if left.Schema().Contains(ln) && right.Schema().Contains(rn) {
+					eqCond = append(eqCond, binop)
+					continue
+				}
+				if left.Schema().Contains(rn) && right.Schema().Contains(ln) {
+					cond := expression.NewFunctionInternal(p.ctx, ast.EQ, types.NewFieldType(mysql.TypeTiny), rn, ln)
+					eqCond = append(eqCond, cond.(*expression.ScalarFunction))
+					continue
+				}
+			}
+		}
+		columns := expression.ExtractColumns(expr)
+		allFromLeft, allFromRight := true, true
+		for _, col := range columns {
+			if !left.Schema().Contains(col) {
+				allFromLeft = false
+			}
+			if !right.Schema().Contains(col) {
+				allFromRight = false
+			}
+		}
+		if allFromRight && len(columns) > 0 {
+			rightCond = append(rightCond, expr)
+		} else if allFromLeft {
+			leftCond = append(leftCond, expr)
+		} else {
+			otherCond = append(otherCond, expr)
+		}
+	}
+	return
+}
+
+// attachOnConds extracts on conditions for join and set the `EqualConditions`, `LeftConditions`, `RightConditions` and
+// `OtherConditions` by the result of extract.
+func (p *LogicalJoin) attachOnConds(onConds []expression.Expression) {
+	eq, left, right, other := p.extractOnCondition(onConds)
+	p.EqualConditions = append(eq, p.EqualConditions...)
+	p.LeftConditions = append(left, p.LeftConditions...)
+	p.RightConditions = append(right, p.RightConditions...)
+	p.OtherConditions = append(other, p.OtherConditions...)
+}
+
+// joinConditions returns all the conditions of the join except NAConditions.
+func (p *LogicalJoin) joinConditions() []expression.Expression {
+	conds := make([]expression.Expression, 0, len(p.EqualConditions)+len(p.LeftConditions)+len(p.RightConditions)+len(p.OtherConditions))
+	for _, cond := range p.EqualConditions {
+		conds = append(conds, cond)
+	}
+	conds = append(conds, p.LeftConditions...)
+	conds = append(conds, p.RightConditions...)
+	return append(conds, p.OtherConditions...)
+}
+
+// resetConditions clears the conditions except NAConditions and attaches conds again.
+func (p *LogicalJoin) resetConditions(conds []expression.Expression) {
+	p.EqualConditions, p.LeftConditions, p.RightConditions, p.OtherConditions = nil, nil, nil, nil
+	p.attachOnConds(conds)
+}
+
+// columnSubstitute substitutes the columns of the schema in the conditions with the expressions.
+func (p *LogicalJoin) columnSubstitute(schema *expression.Schema, exprs []expression.Expression) {
+	conds := p.joinConditions()
+	for i, cond := range conds {
+		conds[i] = expression.ColumnSubstitute(cond, schema, exprs)
+	}
+	for i, cond := range p.NAConditions {
+		p.NAConditions[i] = expression.ColumnSubstitute(cond, schema, exprs)
+	}
+	p.resetConditions(conds)
+}
+
+// LogicalApply gets one row from outer executor and gets all the rows of the inner executor
+// with the correlated columns set by the row.
+type LogicalApply struct {
+	LogicalJoin
+
+	corCols []*expression.CorrelatedColumn
+}
+
+// LogicalMaxOneRow checks if a query returns no more than one row, it returns a row of NULLs if
+// its child returns no row.
+type LogicalMaxOneRow struct {
+	baseLogicalPlan
+}
+
+// LogicalSelection represents a where or having predicate.
+type LogicalSelection struct {
+	baseLogicalPlan
+
+	// Originally the WHERE or ON condition is parsed into a single expression,
+	// but after we converted to CNF(Conjunctive normal form), it can be
+	// split into a list of AND conditions.
+	Conditions []expression.Expression
+}
+
+// LogicalProjection represents a select fields plan.
+type LogicalProjection struct {
+	logicalSchemaProducer
+
+	Exprs []expression.Expression
+}
+
+// LogicalLimit represents offset and limit plan.
+type LogicalLimit struct {
+	baseLogicalPlan
+
+	Offset uint64
+	Count  uint64
+}
+
+// LogicalTableDual represents a dual table plan.
+type LogicalTableDual struct {
+	logicalSchemaProducer
+
+	RowCount int
+}
+
+// LogicalAggregation represents an aggregate plan.
+type LogicalAggregation struct {
+	logicalSchemaProducer
+
+	AggFuncs     []*aggregation.AggFuncDesc
+	GroupByItems []expression.Expression
+}
+
+// ByItems wraps a "by" item.
+type ByItems struct {
+	Expr expression.Expression
+	Desc bool
+}
+
+// String implements fmt.Stringer interface.
+func (by *ByItems) String() string {
+	if by.Desc {
+		return fmt.Sprintf("%s true", by.Expr)
+	}
+	return by.Expr.String()
+}
+
+// Clone makes a copy of ByItems.
+func (by *ByItems) Clone() *ByItems {
+	return &ByItems{Expr: by.Expr.Clone(), Desc: by.Desc}
+}
+
+// LogicalSort stands for the order by plan.
+type LogicalSort struct {
+	baseLogicalPlan
+
+	ByItems []*ByItems
+}
+
+// LogicalTopN represents a top-n plan, it is a sort followed by a limit.
+type LogicalTopN struct {
+	baseLogicalPlan
+
+	ByItems []*ByItems
+	Offset  uint64
+	Count   uint64
+}
+
+// LogicalUnionAll represents LogicalUnionAll plan.
+type LogicalUnionAll struct {
+	logicalSchemaProducer
+}
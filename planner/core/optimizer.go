@@ -0,0 +1,93 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/optimizer.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+
+	"fedb/infoschema"
+	"fedb/sessionctx"
+)
+
+// logicalOptRule means a logical optimizing rule, such as column pruning and predicate push down.
+type logicalOptRule interface {
+	optimize(LogicalPlan) (LogicalPlan, error)
+}
+
+var optRuleList = []logicalOptRule{
+	&decorrelateSolver{},
+	&columnPruner{},
+	&ppdSolver{},
+	&pushDownTopNOptimizer{},
+}
+
+// Optimize does optimization and creates a Plan.
+func Optimize(ctx sessionctx.Context, node ast.Node, is infoschema.InfoSchema) (Plan, error) {
+	builder := NewPlanBuilder(ctx, is)
+	p, err := builder.Build(node)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = checkVisitInfo(ctx, builder.visitInfo); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if logic, ok := p.(LogicalPlan); ok {
+		return DoOptimize(logic)
+	}
+	return p, nil
+}
+
+// DoOptimize optimizes a logical plan to a physical plan.
+func DoOptimize(logic LogicalPlan) (PhysicalPlan, error) {
+	logic, err := logicalOptimize(logic)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	physical, err := physicalOptimize(logic)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = physical.ResolveIndices(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return physical, nil
+}
+
+func logicalOptimize(logic LogicalPlan) (LogicalPlan, error) {
+	var err error
+	for _, rule := range optRuleList {
+		logic, err = rule.optimize(logic)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	return logic, nil
+}
+
+// physicalOptimize converts the logical plan into a physical plan bottom up.
+func physicalOptimize(logic LogicalPlan) (PhysicalPlan, error) {
+	children := make([]PhysicalPlan, 0, len(logic.Children()))
+	for _, child := range logic.Children() {
+		p, err := physicalOptimize(child)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		children = append(children, p)
+	}
+	return logic.toPhysicalPlan(children), nil
+}
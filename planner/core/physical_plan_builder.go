@@ -0,0 +1,587 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package core
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/infoschema"
+	"fedb/util/ranger"
+)
+
+// toPhysicalPlan implements LogicalPlan interface.
+// The pushed down conditions are used to build the ranges of the int handle or an index, the path
+// that uses the most conditions is chosen and the rest are evaluated by a selection over the scan.
+func (ds *DataSource) toPhysicalPlan(_ []PhysicalPlan) PhysicalPlan {
+	if infoschema.IsMemoryDB(ds.DBName.L) {
+		return ds.newMemTable()
+	}
+	sc := ds.ctx.GetSessionVars().StmtCtx
+	var (
+		scan        PhysicalPlan
+		accessConds []expression.Expression
+		filterConds = ds.pushedDownConds
+	)
+	if pkCol := ds.getPKIsHandleCol(); pkCol != nil && len(ds.pushedDownConds) > 0 {
+		ranges, access, filter := ranger.DetachCondsForTable(sc, ds.pushedDownConds, pkCol)
+		if len(access) > 0 {
+			scan, accessConds, filterConds = ds.newTableScan(ranges), access, filter
+		}
+	}
+	for _, idx := range ds.tableInfo.Indices {
+		if idx.State != model.StatePublic || len(ds.pushedDownConds) == 0 {
+			continue
+		}
+		cols, lengths := ds.indexCols(idx)
+		ranges, access, filter := ranger.DetachCondsForIndex(sc, ds.pushedDownConds, cols, lengths)
+		if len(access) > len(accessConds) {
+			scan, accessConds, filterConds = ds.newIndexScan(idx, ranges), access, filter
+		}
+	}
+	if scan == nil {
+		scan = ds.newTableScan(ranger.FullIntRange())
+	}
+	if len(filterConds) == 0 {
+		return scan
+	}
+	sel := PhysicalSelection{Conditions: filterConds}.Init(ds.ctx)
+	sel.SetChildren(scan)
+	return sel
+}
+
+// newMemTable reads the table of a memory database, which has no indices, the conditions are
+// evaluated on the generated rows.
+func (ds *DataSource) newMemTable() PhysicalPlan {
+	memTable := PhysicalMemTable{
+		DBName:      ds.DBName,
+		Table:       ds.tableInfo,
+		Columns:     ds.Columns,
+		TableAsName: ds.TableAsName,
+	}.Init(ds.ctx)
+	memTable.SetSchema(ds.schema)
+	if len(ds.pushedDownConds) == 0 {
+		return memTable
+	}
+	sel := PhysicalSelection{Conditions: ds.pushedDownConds}.Init(ds.ctx)
+	sel.SetChildren(memTable)
+	return sel
+}
+
+// getPKIsHandleCol returns the column of the primary key if it is the handle of the rows and
+// the handle order is the same as the order of its values.
+func (ds *DataSource) getPKIsHandleCol() *expression.Column {
+	if !ds.tableInfo.PKIsHandle {
+		return nil
+	}
+	for i, col := range ds.Columns {
+		if mysql.HasPriKeyFlag(col.Flag) && !mysql.HasUnsignedFlag(col.Flag) {
+			return ds.schema.Columns[i]
+		}
+	}
+	return nil
+}
+
+// indexCols returns the columns of the index in the schema and their prefix lengths, a column
+// that is not in the schema is nil.
+func (ds *DataSource) indexCols(idx *model.IndexInfo) ([]*expression.Column, []int) {
+	cols := make([]*expression.Column, 0, len(idx.Columns))
+	lengths := make([]int, 0, len(idx.Columns))
+	for _, idxCol := range idx.Columns {
+		var col *expression.Column
+		for i, colInfo := range ds.Columns {
+			if colInfo.Name.L == idxCol.Name.L {
+				col = ds.schema.Columns[i]
+				break
+			}
+		}
+		cols = append(cols, col)
+		lengths = append(lengths, idxCol.Length)
+	}
+	return cols, lengths
+}
+
+// isCoveringIndex checks whether all the columns can be read from the entries of the index.
+func (ds *DataSource) isCoveringIndex(idx *model.IndexInfo) bool {
+	for _, colInfo := range ds.Columns {
+		if (ds.tableInfo.PKIsHandle && mysql.HasPriKeyFlag(colInfo.Flag)) || colInfo.ID == model.ExtraHandleID {
+			continue
+		}
+		covered := false
+		for _, idxCol := range idx.Columns {
+			if colInfo.Name.L == idxCol.Name.L && idxCol.Length == types.UnspecifiedLength {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
+
+func (ds *DataSource) newTableScan(ranges []*ranger.Range) PhysicalPlan {
+	ts := PhysicalTableScan{
+		Table:       ds.tableInfo,
+		Columns:     ds.Columns,
+		DBName:      ds.DBName,
+		Ranges:      ranges,
+		TableAsName: ds.TableAsName,
+	}.Init(ds.ctx)
+	ts.SetSchema(ds.schema)
+	return ts
+}
+
+func (ds *DataSource) newIndexScan(idx *model.IndexInfo, ranges []*ranger.Range) PhysicalPlan {
+	if ds.isCoveringIndex(idx) {
+		is := PhysicalIndexScan{
+			Table:       ds.tableInfo,
+			Index:       idx,
+			Columns:     ds.Columns,
+			DBName:      ds.DBName,
+			Ranges:      ranges,
+			TableAsName: ds.TableAsName,
+		}.Init(ds.ctx)
+		is.SetSchema(ds.schema)
+		return is
+	}
+	reader := PhysicalIndexLookUpReader{
+		Table:       ds.tableInfo,
+		Index:       idx,
+		Columns:     ds.Columns,
+		DBName:      ds.DBName,
+		Ranges:      ranges,
+		TableAsName: ds.TableAsName,
+	}.Init(ds.ctx)
+	reader.SetSchema(ds.schema)
+	return reader
+}
+
+// orderedCols returns the columns that the rows of p are sorted by in ascending order.
+func orderedCols(p PhysicalPlan) []*expression.Column {
+	switch x := p.(type) {
+	case *PhysicalTableScan:
+		for i, col := range x.Columns {
+			if x.Table.PKIsHandle && mysql.HasPriKeyFlag(col.Flag) && !mysql.HasUnsignedFlag(col.Flag) {
+				return []*expression.Column{x.schema.Columns[i]}
+			}
+		}
+	case *PhysicalIndexScan:
+		return indexOrderedCols(x.Index, x.Columns, x.schema)
+	case *PhysicalIndexLookUpReader:
+		return indexOrderedCols(x.Index, x.Columns, x.schema)
+	case *PhysicalSelection, *PhysicalLimit:
+		return orderedCols(x.Children()[0])
+	case *PhysicalProjection:
+		var cols []*expression.Column
+		for _, childCol := range orderedCols(x.children[0]) {
+			idx := -1
+			for i, expr := range x.Exprs {
+				if col, ok := expr.(*expression.Column); ok && col.Equal(childCol) {
+					idx = i
+					break
+				}
+			}
+			if idx == -1 {
+				break
+			}
+			cols = append(cols, x.schema.Columns[idx])
+		}
+		return cols
+	case *PhysicalSort:
+		return byItemsCols(x.ByItems)
+	}
+	return nil
+}
+
+// indexOrderedCols returns the prefix of the index columns that are in the schema.
+func indexOrderedCols(idx *model.IndexInfo, columns []*model.ColumnInfo, schema *expression.Schema) []*expression.Column {
+	var cols []*expression.Column
+	for _, idxCol := range idx.Columns {
+		found := false
+		for i, colInfo := range columns {
+			if colInfo.Name.L == idxCol.Name.L && idxCol.Length == types.UnspecifiedLength {
+				cols = append(cols, schema.Columns[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return cols
+}
+
+// byItemsCols returns the columns of the items if they are all columns in ascending order.
+func byItemsCols(byItems []*ByItems) []*expression.Column {
+	cols := make([]*expression.Column, 0, len(byItems))
+	for _, item := range byItems {
+		col, ok := item.Expr.(*expression.Column)
+		if !ok || item.Desc {
+			return nil
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// matchOrder checks whether the rows of p are already sorted by the columns.
+func matchOrder(p PhysicalPlan, cols []*expression.Column) bool {
+	ordered := orderedCols(p)
+	if len(cols) == 0 || len(cols) > len(ordered) {
+		return false
+	}
+	for i, col := range cols {
+		if !col.Equal(ordered[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalSelection) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	sel := PhysicalSelection{Conditions: p.Conditions}.Init(p.ctx)
+	sel.SetChildren(children...)
+	return sel
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalProjection) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	proj := PhysicalProjection{Exprs: p.Exprs}.Init(p.ctx, p.schema)
+	proj.SetChildren(children...)
+	return proj
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalLimit) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	limit := PhysicalLimit{Offset: p.Offset, Count: p.Count}.Init(p.ctx)
+	limit.SetChildren(children...)
+	return limit
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalTableDual) toPhysicalPlan(_ []PhysicalPlan) PhysicalPlan {
+	return PhysicalTableDual{RowCount: p.RowCount}.Init(p.ctx, p.schema)
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+// The sort is eliminated if the rows of the child are already in order.
+func (ls *LogicalSort) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	if matchOrder(children[0], byItemsCols(ls.ByItems)) {
+		return children[0]
+	}
+	sort := PhysicalSort{ByItems: ls.ByItems}.Init(ls.ctx)
+	sort.SetChildren(children...)
+	return sort
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+// The topN is converted to a limit if the rows of the child are already in order.
+func (lt *LogicalTopN) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	if matchOrder(children[0], byItemsCols(lt.ByItems)) {
+		limit := PhysicalLimit{Offset: lt.Offset, Count: lt.Count}.Init(lt.ctx)
+		limit.SetChildren(children...)
+		return limit
+	}
+	topN := PhysicalTopN{ByItems: lt.ByItems, Offset: lt.Offset, Count: lt.Count}.Init(lt.ctx)
+	topN.SetChildren(children...)
+	return topN
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+// The stream aggregation is used if there is no group by item or the rows of the child are
+// sorted by the group by columns, otherwise the rows are grouped by a hash table.
+func (la *LogicalAggregation) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	base := basePhysicalAgg{AggFuncs: la.AggFuncs, GroupByItems: la.GroupByItems}
+	if len(la.GroupByItems) == 0 || la.groupByColsMatchOrder(children[0]) {
+		agg := PhysicalStreamAgg{basePhysicalAgg: base}.Init(la.ctx, la.schema)
+		agg.SetChildren(children...)
+		return agg
+	}
+	agg := PhysicalHashAgg{basePhysicalAgg: base}.Init(la.ctx, la.schema)
+	agg.SetChildren(children...)
+	return agg
+}
+
+// groupByColsMatchOrder checks whether the group by items are columns and they are the same
+// as a prefix of the ordered columns of the child regardless of the order of the items.
+func (la *LogicalAggregation) groupByColsMatchOrder(child PhysicalPlan) bool {
+	ordered := orderedCols(child)
+	if len(la.GroupByItems) > len(ordered) {
+		return false
+	}
+	for _, item := range la.GroupByItems {
+		col, ok := item.(*expression.Column)
+		if !ok {
+			return false
+		}
+		found := false
+		for _, orderedCol := range ordered[:len(la.GroupByItems)] {
+			if col.Equal(orderedCol) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalUnionAll) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	union := PhysicalUnionAll{}.Init(p.ctx, p.schema)
+	union.SetChildren(children...)
+	return union
+}
+
+// newBasePhysicalJoin extracts the join keys and the conditions evaluated on the joined rows.
+func (p *LogicalJoin) newBasePhysicalJoin() basePhysicalJoin {
+	base := basePhysicalJoin{
+		JoinType:      p.JoinType,
+		InnerChildIdx: 1,
+	}
+	if p.JoinType == RightOuterJoin {
+		base.InnerChildIdx = 0
+	}
+	for _, eqCond := range p.EqualConditions {
+		args := eqCond.GetArgs()
+		base.LeftJoinKeys = append(base.LeftJoinKeys, args[0].(*expression.Column))
+		base.RightJoinKeys = append(base.RightJoinKeys, args[1].(*expression.Column))
+	}
+	base.OtherConditions = make(expression.CNFExprs, 0, len(p.LeftConditions)+len(p.RightConditions)+len(p.OtherConditions))
+	base.OtherConditions = append(base.OtherConditions, p.LeftConditions...)
+	base.OtherConditions = append(base.OtherConditions, p.RightConditions...)
+	base.OtherConditions = append(base.OtherConditions, p.OtherConditions...)
+	base.NAConditions = append(base.NAConditions, p.NAConditions...)
+	for _, cond := range p.NAConditions {
+		sf, ok := cond.(*expression.ScalarFunction)
+		if !ok || sf.FuncName.L != ast.EQ {
+			continue
+		}
+		lCol, lOK := sf.GetArgs()[0].(*expression.Column)
+		rCol, rOK := sf.GetArgs()[1].(*expression.Column)
+		if lOK && rOK && p.children[0].Schema().Contains(lCol) && p.children[1].Schema().Contains(rCol) {
+			base.LeftNAKeys = append(base.LeftNAKeys, lCol)
+			base.RightNAKeys = append(base.RightNAKeys, rCol)
+		}
+	}
+	return base
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+// The index join is only used if it is chosen by the hint, the merge join is used if the children
+// are ordered by the join keys or it is chosen by the hint, otherwise it is the hash join.
+func (p *LogicalJoin) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	base := p.newBasePhysicalJoin()
+	if len(p.NAConditions) == 0 {
+		if join := p.tryToGetIndexJoin(base, children); join != nil {
+			return join
+		}
+		if join := p.tryToGetMergeJoin(base, children); join != nil {
+			return join
+		}
+	}
+	join := PhysicalHashJoin{
+		basePhysicalJoin: base,
+		Concurrency:      p.ctx.GetSessionVars().HashJoinConcurrency(),
+	}.Init(p.ctx, p.schema)
+	join.SetChildren(children...)
+	return join
+}
+
+// isMergeJoinKeyType checks whether the values of the key columns can be compared by their
+// datums and the keys are ordered in the same way in the children.
+func isMergeJoinKeyType(lTp, rTp *types.FieldType) bool {
+	switch lTp.Tp {
+	case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+		return false
+	}
+	switch rTp.Tp {
+	case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+		return false
+	}
+	if lTp.EvalType() != rTp.EvalType() {
+		return false
+	}
+	switch lTp.EvalType() {
+	case types.ETInt, types.ETReal, types.ETDecimal, types.ETString, types.ETDatetime, types.ETTimestamp, types.ETDuration:
+		return true
+	}
+	return false
+}
+
+func (p *LogicalJoin) tryToGetMergeJoin(base basePhysicalJoin, children []PhysicalPlan) PhysicalPlan {
+	if len(base.LeftJoinKeys) == 0 {
+		return nil
+	}
+	for i := range base.LeftJoinKeys {
+		if !isMergeJoinKeyType(base.LeftJoinKeys[i].RetType, base.RightJoinKeys[i].RetType) {
+			return nil
+		}
+	}
+	lOrdered, rOrdered := matchOrder(children[0], base.LeftJoinKeys), matchOrder(children[1], base.RightJoinKeys)
+	if (!lOrdered || !rOrdered) && p.preferJoinType&preferMergeJoin == 0 {
+		return nil
+	}
+	if !lOrdered {
+		children[0] = newSortByCols(p, children[0], base.LeftJoinKeys)
+	}
+	if !rOrdered {
+		children[1] = newSortByCols(p, children[1], base.RightJoinKeys)
+	}
+	join := PhysicalMergeJoin{basePhysicalJoin: base}.Init(p.ctx, p.schema)
+	join.SetChildren(children...)
+	return join
+}
+
+// newSortByCols sorts the rows of the child by the columns in ascending order.
+func newSortByCols(p *LogicalJoin, child PhysicalPlan, cols []*expression.Column) PhysicalPlan {
+	byItems := make([]*ByItems, 0, len(cols))
+	for _, col := range cols {
+		byItems = append(byItems, &ByItems{Expr: col})
+	}
+	sort := PhysicalSort{ByItems: byItems}.Init(p.ctx)
+	sort.SetChildren(child)
+	return sort
+}
+
+// tryToGetIndexJoin builds the index join whose inner child is the table of the hint, the rows of
+// the inner table are read by the int handle or an index whose prefix columns are the join keys.
+func (p *LogicalJoin) tryToGetIndexJoin(base basePhysicalJoin, children []PhysicalPlan) PhysicalPlan {
+	if p.preferJoinType&preferRightAsIndexInner != 0 && p.JoinType != RightOuterJoin {
+		if join := p.getIndexJoinByInnerIdx(base, children, 1); join != nil {
+			return join
+		}
+	}
+	if p.preferJoinType&preferLeftAsIndexInner != 0 && (p.JoinType == InnerJoin || p.JoinType == RightOuterJoin) {
+		if join := p.getIndexJoinByInnerIdx(base, children, 0); join != nil {
+			return join
+		}
+	}
+	return nil
+}
+
+// isIndexJoinKeyType checks whether the value of the outer key can be converted to the value of
+// the inner key to look up the rows.
+func isIndexJoinKeyType(outerTp, innerTp *types.FieldType) bool {
+	switch outerTp.Tp {
+	case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+		return false
+	}
+	switch innerTp.Tp {
+	case mysql.TypeEnum, mysql.TypeSet, mysql.TypeBit:
+		return false
+	}
+	et := innerTp.EvalType()
+	return outerTp.EvalType() == et && (et == types.ETInt || et == types.ETString)
+}
+
+func (p *LogicalJoin) getIndexJoinByInnerIdx(base basePhysicalJoin, children []PhysicalPlan, innerIdx int) PhysicalPlan {
+	ds, ok := p.children[innerIdx].(*DataSource)
+	if !ok {
+		return nil
+	}
+	outerKeys, innerKeys := base.LeftJoinKeys, base.RightJoinKeys
+	if innerIdx == 0 {
+		outerKeys, innerKeys = base.RightJoinKeys, base.LeftJoinKeys
+	}
+	// keyOffset returns the position of the usable join key of the inner column.
+	keyOffset := func(col *expression.Column) int {
+		if col == nil {
+			return -1
+		}
+		for i, key := range innerKeys {
+			if key.Equal(col) && isIndexJoinKeyType(outerKeys[i].RetType, key.RetType) {
+				return i
+			}
+		}
+		return -1
+	}
+	var (
+		bestIdx     *model.IndexInfo
+		bestOffsets []int
+	)
+	if offset := keyOffset(ds.getPKIsHandleCol()); offset != -1 {
+		bestOffsets = []int{offset}
+	} else {
+		for _, idx := range ds.tableInfo.Indices {
+			if idx.State != model.StatePublic {
+				continue
+			}
+			var offsets []int
+			cols, lengths := ds.indexCols(idx)
+			for i, col := range cols {
+				// The join keys are compared with the whole values of the columns.
+				offset := keyOffset(col)
+				if offset == -1 || lengths[i] != types.UnspecifiedLength {
+					break
+				}
+				offsets = append(offsets, offset)
+			}
+			if len(offsets) > len(bestOffsets) {
+				bestIdx, bestOffsets = idx, offsets
+			}
+		}
+	}
+	if len(bestOffsets) == 0 {
+		return nil
+	}
+	base.InnerChildIdx = innerIdx
+	join := PhysicalIndexJoin{
+		basePhysicalJoin: base,
+		InnerTable:       ds.tableInfo,
+		InnerIndex:       bestIdx,
+		InnerColumns:     ds.Columns,
+		KeyOffsets:       bestOffsets,
+		InnerFilters:     append(expression.CNFExprs(nil), ds.pushedDownConds...),
+	}.Init(p.ctx, p.schema)
+	if bestIdx != nil {
+		join.InnerIndexCovering = ds.isCoveringIndex(bestIdx)
+	}
+	join.SetChildren(children...)
+	return join
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+// All the conditions are evaluated on the joined rows, because the inner child is executed again
+// for each outer row.
+func (la *LogicalApply) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	base := la.newBasePhysicalJoin()
+	for i := range base.LeftJoinKeys {
+		cond := expression.NewFunctionInternal(la.ctx, ast.EQ, types.NewFieldType(mysql.TypeTiny), base.LeftJoinKeys[i], base.RightJoinKeys[i])
+		base.OtherConditions = append(base.OtherConditions, cond)
+	}
+	base.LeftJoinKeys, base.RightJoinKeys = nil, nil
+	apply := PhysicalApply{
+		basePhysicalJoin: base,
+		OuterSchema:      extractCorColumnsBySchema(la.children[1], la.children[0].Schema()),
+	}.Init(la.ctx, la.schema)
+	apply.SetChildren(children...)
+	return apply
+}
+
+// toPhysicalPlan implements LogicalPlan interface.
+func (p *LogicalMaxOneRow) toPhysicalPlan(children []PhysicalPlan) PhysicalPlan {
+	maxOneRow := PhysicalMaxOneRow{}.Init(p.ctx)
+	maxOneRow.SetChildren(children...)
+	return maxOneRow
+}
@@ -0,0 +1,233 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/physical_plans.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/parser/model"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+	"fedb/util/ranger"
+)
+
+var (
+	_ PhysicalPlan = &PhysicalTableScan{}
+	_ PhysicalPlan = &PhysicalMemTable{}
+	_ PhysicalPlan = &PhysicalSelection{}
+	_ PhysicalPlan = &PhysicalProjection{}
+	_ PhysicalPlan = &PhysicalLimit{}
+	_ PhysicalPlan = &PhysicalTableDual{}
+	_ PhysicalPlan = &PhysicalIndexScan{}
+	_ PhysicalPlan = &PhysicalIndexLookUpReader{}
+	_ PhysicalPlan = &PhysicalSort{}
+	_ PhysicalPlan = &PhysicalTopN{}
+	_ PhysicalPlan = &PhysicalHashAgg{}
+	_ PhysicalPlan = &PhysicalStreamAgg{}
+	_ PhysicalPlan = &PhysicalUnionAll{}
+	_ PhysicalPlan = &PhysicalHashJoin{}
+	_ PhysicalPlan = &PhysicalMergeJoin{}
+	_ PhysicalPlan = &PhysicalIndexJoin{}
+	_ PhysicalPlan = &PhysicalApply{}
+	_ PhysicalPlan = &PhysicalMaxOneRow{}
+)
+
+// PhysicalTableScan represents a table scan plan, it reads the rows of the table in handle order.
+type PhysicalTableScan struct {
+	physicalSchemaProducer
+
+	Table   *model.TableInfo
+	Columns []*model.ColumnInfo
+	DBName  model.CIStr
+	// Ranges are the ranges of the handles, the rows are read in the order of the ranges.
+	Ranges []*ranger.Range
+
+	TableAsName *model.CIStr
+}
+
+// PhysicalMemTable reads a table of a memory database, all the rows are generated when it's
+// opened.
+type PhysicalMemTable struct {
+	physicalSchemaProducer
+
+	DBName  model.CIStr
+	Table   *model.TableInfo
+	Columns []*model.ColumnInfo
+
+	TableAsName *model.CIStr
+}
+
+// PhysicalIndexScan represents an index scan plan, the output columns are read from the index
+// entries, so they must be covered by the index columns and the handle.
+type PhysicalIndexScan struct {
+	physicalSchemaProducer
+
+	Table   *model.TableInfo
+	Index   *model.IndexInfo
+	Columns []*model.ColumnInfo
+	DBName  model.CIStr
+	// Ranges are the ranges of the index values, the entries are read in the order of the ranges.
+	Ranges []*ranger.Range
+
+	TableAsName *model.CIStr
+}
+
+// PhysicalIndexLookUpReader reads the handles from an index and then reads the rows of the handles
+// from the table, the rows are returned in the order of the index.
+type PhysicalIndexLookUpReader struct {
+	physicalSchemaProducer
+
+	Table   *model.TableInfo
+	Index   *model.IndexInfo
+	Columns []*model.ColumnInfo
+	DBName  model.CIStr
+	Ranges  []*ranger.Range
+
+	TableAsName *model.CIStr
+}
+
+// PhysicalSelection represents a filter.
+type PhysicalSelection struct {
+	basePhysicalPlan
+
+	Conditions []expression.Expression
+}
+
+// PhysicalProjection is the physical operator of projection.
+type PhysicalProjection struct {
+	physicalSchemaProducer
+
+	Exprs []expression.Expression
+}
+
+// PhysicalLimit is the physical operator of Limit.
+type PhysicalLimit struct {
+	basePhysicalPlan
+
+	Offset uint64
+	Count  uint64
+}
+
+// PhysicalTableDual is the physical operator of dual.
+type PhysicalTableDual struct {
+	physicalSchemaProducer
+
+	RowCount int
+}
+
+// PhysicalSort is the physical operator of sort, which implements a memory sort.
+type PhysicalSort struct {
+	basePhysicalPlan
+
+	ByItems []*ByItems
+}
+
+// PhysicalTopN is the physical operator of topN.
+type PhysicalTopN struct {
+	basePhysicalPlan
+
+	ByItems []*ByItems
+	Offset  uint64
+	Count   uint64
+}
+
+type basePhysicalAgg struct {
+	physicalSchemaProducer
+
+	AggFuncs     []*aggregation.AggFuncDesc
+	GroupByItems []expression.Expression
+}
+
+// PhysicalHashAgg is hash operator of aggregate.
+type PhysicalHashAgg struct {
+	basePhysicalAgg
+}
+
+// PhysicalStreamAgg is stream operator of aggregate, its child must be ordered by the group by items.
+type PhysicalStreamAgg struct {
+	basePhysicalAgg
+}
+
+// PhysicalUnionAll is the physical operator of UnionAll.
+type PhysicalUnionAll struct {
+	physicalSchemaProducer
+}
+
+type basePhysicalJoin struct {
+	physicalSchemaProducer
+
+	JoinType JoinType
+	// InnerChildIdx is the index of the child whose rows are matched by the rows of the other child,
+	// the outer rows are returned in the outer joins and the semi joins.
+	InnerChildIdx int
+
+	LeftJoinKeys  []*expression.Column
+	RightJoinKeys []*expression.Column
+	// LeftNAKeys and RightNAKeys are the columns of the "eq" conditions in NAConditions.
+	LeftNAKeys  []*expression.Column
+	RightNAKeys []*expression.Column
+
+	// OtherConditions are the conditions evaluated on the joined rows, including the ones on one child
+	// which can't be pushed down.
+	OtherConditions expression.CNFExprs
+	NAConditions    expression.CNFExprs
+}
+
+// PhysicalHashJoin represents hash join for inner/outer/semi join, it builds a hash table on the
+// rows of the inner child and probes it with the rows of the outer child.
+type PhysicalHashJoin struct {
+	basePhysicalJoin
+
+	// Concurrency is the number of the workers which probe the hash table.
+	Concurrency int
+}
+
+// PhysicalMergeJoin represents merge join, both of its children are ordered by the join keys.
+type PhysicalMergeJoin struct {
+	basePhysicalJoin
+}
+
+// PhysicalIndexJoin represents the plan of index look up join, the rows of the inner table are read
+// by the join keys of a batch of the outer rows.
+type PhysicalIndexJoin struct {
+	basePhysicalJoin
+
+	InnerTable *model.TableInfo
+	// InnerIndex is the index to look up, nil means the rows are read by the int handle.
+	InnerIndex *model.IndexInfo
+	// InnerIndexCovering is true if the inner columns are covered by the index.
+	InnerIndexCovering bool
+	InnerColumns       []*model.ColumnInfo
+	// KeyOffsets are the positions of the join keys whose values are looked up, in the order of
+	// the index columns.
+	KeyOffsets []int
+	// InnerFilters are the conditions on the inner table.
+	InnerFilters expression.CNFExprs
+}
+
+// PhysicalApply represents apply plan, only used for subquery, the inner child is executed again
+// for every row of the outer child.
+type PhysicalApply struct {
+	basePhysicalJoin
+
+	// OuterSchema are the correlated columns of the inner child, they are set by the rows of the outer child.
+	OuterSchema []*expression.CorrelatedColumn
+}
+
+// PhysicalMaxOneRow is the physical operator of maxOneRow.
+type PhysicalMaxOneRow struct {
+	basePhysicalPlan
+}
@@ -0,0 +1,223 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/plan.go
+//
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+
+	"fedb/expression"
+	"fedb/sessionctx"
+)
+
+// Plan is the description of an execution flow.
+// It is created from ast.Node first, then optimized by the optimizer,
+// finally used by the executor to create a Cursor which executes the statement.
+type Plan interface {
+	// Schema returns the output columns of the plan.
+	Schema() *expression.Schema
+
+	// ID gets the ID of the plan.
+	ID() int
+
+	// TP gets the type of the plan, like "Selection".
+	TP() string
+
+	// ExplainID gets the ID in explain statement.
+	ExplainID() string
+
+	context() sessionctx.Context
+}
+
+// LogicalPlan is a tree of logical operators.
+// We can do a lot of logical optimizations to it, like predicate pushdown and column pruning.
+type LogicalPlan interface {
+	Plan
+
+	// PredicatePushDown pushes down the predicates in the where/on/having clauses as deeply as possible.
+	// It will accept a predicate that is an expression slice, and return the expressions that can't be pushed.
+	// Because it might change the root if the having clause exists, we need to return a plan that represents a new root.
+	PredicatePushDown([]expression.Expression) ([]expression.Expression, LogicalPlan)
+
+	// PruneColumns prunes the unused columns.
+	PruneColumns([]*expression.Column)
+
+	// toPhysicalPlan converts the operator itself into a physical operator over the
+	// children, which are converted by the caller.
+	toPhysicalPlan(children []PhysicalPlan) PhysicalPlan
+
+	// Children returns the children of the plan.
+	Children() []LogicalPlan
+
+	// SetChildren sets the children of the plan.
+	SetChildren(...LogicalPlan)
+}
+
+// PhysicalPlan is a tree of the physical operators.
+type PhysicalPlan interface {
+	Plan
+
+	// Children returns the children of the plan.
+	Children() []PhysicalPlan
+
+	// SetChildren sets the children of the plan.
+	SetChildren(...PhysicalPlan)
+
+	// ResolveIndices resolves the indices for columns. After doing this, the columns can evaluate the rows by their indices.
+	ResolveIndices() error
+}
+
+// basePlan implements base Plan interface.
+// Should be used as embedded struct in Plan implementations.
+type basePlan struct {
+	tp  string
+	id  int
+	ctx sessionctx.Context
+}
+
+func newBasePlan(ctx sessionctx.Context, tp string) basePlan {
+	ctx.GetSessionVars().PlanID++
+	return basePlan{
+		tp:  tp,
+		id:  ctx.GetSessionVars().PlanID,
+		ctx: ctx,
+	}
+}
+
+// ID implements Plan ID interface.
+func (p *basePlan) ID() int {
+	return p.id
+}
+
+// TP implements Plan interface.
+func (p *basePlan) TP() string {
+	return p.tp
+}
+
+// ExplainID implements Plan interface.
+func (p *basePlan) ExplainID() string {
+	return fmt.Sprintf("%s_%d", p.tp, p.id)
+}
+
+func (p *basePlan) context() sessionctx.Context {
+	return p.ctx
+}
+
+type baseLogicalPlan struct {
+	basePlan
+
+	self     LogicalPlan
+	children []LogicalPlan
+}
+
+func newBaseLogicalPlan(ctx sessionctx.Context, tp string, self LogicalPlan) baseLogicalPlan {
+	return baseLogicalPlan{
+		basePlan: newBasePlan(ctx, tp),
+		self:     self,
+	}
+}
+
+// Schema implements Plan Schema interface.
+func (p *baseLogicalPlan) Schema() *expression.Schema {
+	return p.children[0].Schema()
+}
+
+// Children implements LogicalPlan Children interface.
+func (p *baseLogicalPlan) Children() []LogicalPlan {
+	return p.children
+}
+
+// SetChildren implements LogicalPlan SetChildren interface.
+func (p *baseLogicalPlan) SetChildren(children ...LogicalPlan) {
+	p.children = children
+}
+
+type basePhysicalPlan struct {
+	basePlan
+
+	self     PhysicalPlan
+	children []PhysicalPlan
+}
+
+func newBasePhysicalPlan(ctx sessionctx.Context, tp string, self PhysicalPlan) basePhysicalPlan {
+	return basePhysicalPlan{
+		basePlan: newBasePlan(ctx, tp),
+		self:     self,
+	}
+}
+
+// Schema implements Plan Schema interface.
+func (p *basePhysicalPlan) Schema() *expression.Schema {
+	return p.children[0].Schema()
+}
+
+// Children implements PhysicalPlan Children interface.
+func (p *basePhysicalPlan) Children() []PhysicalPlan {
+	return p.children
+}
+
+// SetChildren implements PhysicalPlan SetChildren interface.
+func (p *basePhysicalPlan) SetChildren(children ...PhysicalPlan) {
+	p.children = children
+}
+
+// ResolveIndices implements PhysicalPlan interface.
+func (p *basePhysicalPlan) ResolveIndices() error {
+	for _, child := range p.children {
+		if err := child.ResolveIndices(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+type logicalSchemaProducer struct {
+	schema *expression.Schema
+	baseLogicalPlan
+}
+
+// Schema implements the Plan.Schema interface.
+func (s *logicalSchemaProducer) Schema() *expression.Schema {
+	if s.schema == nil {
+		s.schema = expression.NewSchema()
+	}
+	return s.schema
+}
+
+// SetSchema implements the Plan.SetSchema interface.
+func (s *logicalSchemaProducer) SetSchema(schema *expression.Schema) {
+	s.schema = schema
+}
+
+type physicalSchemaProducer struct {
+	schema *expression.Schema
+	basePhysicalPlan
+}
+
+// Schema implements the Plan.Schema interface.
+func (s *physicalSchemaProducer) Schema() *expression.Schema {
+	if s.schema == nil {
+		s.schema = expression.NewSchema()
+	}
+	return s.schema
+}
+
+// SetSchema implements the Plan.SetSchema interface.
+func (s *physicalSchemaProducer) SetSchema(schema *expression.Schema) {
+	s.schema = schema
+}
@@ -0,0 +1,591 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/planbuilder.go
+//
+
+package core
+
+import (
+	"fmt"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+	"fedb/infoschema"
+	"fedb/sessionctx"
+	"fedb/table"
+)
+
+// PlanBuilder builds Plan from an ast.Node.
+// It just builds the ast node straightforwardly.
+type PlanBuilder struct {
+	ctx sessionctx.Context
+	is  infoschema.InfoSchema
+
+	// curClause tracks which part of the query is being built, it is used for the error messages.
+	curClause clauseCode
+
+	// aggMapper maps the aggregate functions to the output columns of the aggregation.
+	aggMapper map[*ast.AggregateFuncExpr]int
+	// colMapper maps the expressions in HAVING and ORDER BY to the output columns of the projection.
+	colMapper map[ast.Node]int
+
+	// outerSchemas are the schemas of the outer queries of the subquery being built, the columns
+	// of them are correlated.
+	outerSchemas []*expression.Schema
+	// tableHintInfo is a stack of the join hints of the selects being built.
+	tableHintInfo []tableHintInfo
+
+	// withRowHandle is set when the tables of an UPDATE or a DELETE are being built, the data
+	// sources read the handles of the rows then.
+	withRowHandle bool
+	// insertValuesSchema is the schema of the row being inserted, VALUES(col) in the ON
+	// DUPLICATE KEY UPDATE clause refers to its columns.
+	insertValuesSchema *expression.Schema
+
+	// visitInfo are the privileges the statement requires, they are checked when it is optimized.
+	visitInfo []visitInfo
+	// tableColumns maps the unique IDs of the columns read from the tables to the privileges of
+	// reading them, the columns the statement refers to require them.
+	tableColumns map[int64]visitInfo
+}
+
+type tableHintInfo struct {
+	indexNestedLoopJoinTables []model.CIStr
+	sortMergeJoinTables       []model.CIStr
+	hashJoinTables            []model.CIStr
+}
+
+func (info *tableHintInfo) ifPreferMergeJoin(tableNames ...*model.CIStr) bool {
+	return info.matchTableName(tableNames, info.sortMergeJoinTables)
+}
+
+func (info *tableHintInfo) ifPreferHashJoin(tableNames ...*model.CIStr) bool {
+	return info.matchTableName(tableNames, info.hashJoinTables)
+}
+
+func (info *tableHintInfo) ifPreferINLJ(tableNames ...*model.CIStr) bool {
+	return info.matchTableName(tableNames, info.indexNestedLoopJoinTables)
+}
+
+// matchTableName checks whether the hint hit the need.
+// Only need either side matches one on the list.
+// Even though you can put 2 tables on the list,
+// it doesn't mean optimizer will reorder to make them
+// join directly.
+// Which it joins on with depend on sequence of traverse
+// and without reorder, user might adjust themselves.
+// This is similar to MySQL hints.
+func (info *tableHintInfo) matchTableName(tables []*model.CIStr, tablesInHints []model.CIStr) bool {
+	for _, tableName := range tables {
+		if tableName == nil {
+			continue
+		}
+		for _, curEntry := range tablesInHints {
+			if curEntry.L == tableName.L {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewPlanBuilder creates a new PlanBuilder.
+func NewPlanBuilder(ctx sessionctx.Context, is infoschema.InfoSchema) *PlanBuilder {
+	return &PlanBuilder{
+		ctx:          ctx,
+		is:           is,
+		tableColumns: make(map[int64]visitInfo),
+	}
+}
+
+// Build builds the ast node to a Plan.
+func (b *PlanBuilder) Build(node ast.Node) (Plan, error) {
+	switch x := node.(type) {
+	case *ast.SelectStmt:
+		p, err := b.buildSelect(x)
+		return p, errors.Trace(err)
+	case *ast.UnionStmt:
+		p, err := b.buildUnion(x)
+		return p, errors.Trace(err)
+	case *ast.InsertStmt:
+		p, err := b.buildInsert(x)
+		return p, errors.Trace(err)
+	case *ast.UpdateStmt:
+		p, err := b.buildUpdate(x)
+		return p, errors.Trace(err)
+	case *ast.DeleteStmt:
+		p, err := b.buildDelete(x)
+		return p, errors.Trace(err)
+	case *ast.AdminStmt:
+		p, err := b.buildAdmin(x)
+		return p, errors.Trace(err)
+	case *ast.ShowStmt:
+		p, err := b.buildShow(x)
+		return p, errors.Trace(err)
+	}
+	return nil, ErrUnsupportedType.GenWithStack("Unsupported type %T", node)
+}
+
+func (b *PlanBuilder) buildInsert(insert *ast.InsertStmt) (Plan, error) {
+	ts, ok := insert.Table.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return nil, ErrUnsupportedType.GenWithStackByArgs(insert.Table.TableRefs.Left)
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok {
+		return nil, ErrUnsupportedType.GenWithStackByArgs(ts.Source)
+	}
+	dbName, tableInfo, err := b.tableByName(tn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if infoschema.IsMemoryDB(dbName.L) {
+		stmtName := "INSERT"
+		if insert.IsReplace {
+			stmtName = "REPLACE"
+		}
+		return nil, ErrNonUpdatableTable.GenWithStackByArgs(tableInfo.Name.O, stmtName)
+	}
+	b.visitInfo = appendVisitInfo(b.visitInfo, mysql.InsertPriv, dbName.L, tableInfo.Name.L, "")
+	if insert.IsReplace {
+		// REPLACE deletes the duplicate rows.
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.DeletePriv, dbName.L, tableInfo.Name.L, "")
+	}
+	publicCols := make([]*model.ColumnInfo, 0, len(tableInfo.Columns))
+	for _, col := range tableInfo.Columns {
+		if col.State == model.StatePublic {
+			publicCols = append(publicCols, col)
+		}
+	}
+	// The values are evaluated on the row being inserted, whose schema is the one of the table.
+	mockTablePlan := LogicalTableDual{}.Init(b.ctx)
+	mockTablePlan.SetSchema(b.buildTableSchema(dbName, tableInfo, publicCols))
+
+	insertPlan := Insert{
+		Table:     tableInfo,
+		DBName:    dbName,
+		IsReplace: insert.IsReplace,
+	}.Init(b.ctx)
+	b.curClause = fieldList
+	switch {
+	case len(insert.Setlist) > 0:
+		// Branch for `INSERT ... SET ...`.
+		err = b.buildSetValuesOfInsert(insert, insertPlan, mockTablePlan, publicCols)
+	case len(insert.Lists) > 0:
+		// Branch for `INSERT ... VALUES ...`.
+		err = b.buildValuesListOfInsert(insert, insertPlan, mockTablePlan, publicCols)
+	default:
+		// Branch for `INSERT ... SELECT ...`.
+		err = b.buildSelectPlanOfInsert(insert, insertPlan, mockTablePlan, publicCols)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = b.buildOnDuplicateOfInsert(insert, insertPlan, mockTablePlan, publicCols); err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, col := range insertPlan.Columns {
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.InsertPriv, dbName.L, tableInfo.Name.L, col.Name.L)
+	}
+	for _, assign := range insertPlan.OnDuplicate {
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.UpdatePriv, dbName.L, tableInfo.Name.L, assign.Col.ColName.L)
+	}
+	return insertPlan, nil
+}
+
+// findInsertColumn returns the column that name refers to, given records the columns found
+// before, a column can't be given two values.
+func findInsertColumn(mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo, name *ast.ColumnName,
+	given map[int64]struct{}) (*model.ColumnInfo, error) {
+	col, idx, err := mockTablePlan.Schema().FindColumnAndIndex(name)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if col == nil {
+		return nil, ErrUnknownColumn.GenWithStackByArgs(name.OrigColName(), clauseMsg[fieldList])
+	}
+	colInfo := publicCols[idx]
+	if given != nil {
+		if _, ok := given[colInfo.ID]; ok {
+			return nil, ErrFieldSpecifiedTwice.GenWithStackByArgs(colInfo.Name.O)
+		}
+		given[colInfo.ID] = struct{}{}
+	}
+	return colInfo, nil
+}
+
+// getInsertColumns returns the columns given values by the VALUES lists or the SELECT.
+func getInsertColumns(insert *ast.InsertStmt, mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo) ([]*model.ColumnInfo, error) {
+	if len(insert.Columns) == 0 {
+		return publicCols, nil
+	}
+	cols := make([]*model.ColumnInfo, 0, len(insert.Columns))
+	given := make(map[int64]struct{}, len(insert.Columns))
+	for _, name := range insert.Columns {
+		col, err := findInsertColumn(mockTablePlan, publicCols, name, given)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		cols = append(cols, col)
+	}
+	return cols, nil
+}
+
+// buildInsertValue builds the value given to col, DEFAULT is the default value of the column.
+// The returned expression is not resolved.
+func (b *PlanBuilder) buildInsertValue(valueItem ast.ExprNode, col *model.ColumnInfo, mockTablePlan LogicalPlan) (expression.Expression, error) {
+	if v, ok := valueItem.(*ast.DefaultExpr); ok && v.Name == nil {
+		return b.buildDefaultValue(col)
+	}
+	expr, np, err := b.rewrite(valueItem, mockTablePlan, true)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if np != mockTablePlan {
+		return nil, ErrNotSupportedYet.GenWithStackByArgs("correlated subquery in INSERT")
+	}
+	return expr, nil
+}
+
+// buildDefaultValue builds the default value of the column for DEFAULT.
+func (b *PlanBuilder) buildDefaultValue(col *model.ColumnInfo) (expression.Expression, error) {
+	d, err := table.GetColDefaultValue(b.ctx, col)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	tp := col.FieldType
+	return &expression.Constant{Value: d, RetType: &tp}, nil
+}
+
+func (b *PlanBuilder) buildSetValuesOfInsert(insert *ast.InsertStmt, insertPlan *Insert, mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo) error {
+	given := make(map[int64]struct{}, len(insert.Setlist))
+	exprs := make([]expression.Expression, 0, len(insert.Setlist))
+	for _, assign := range insert.Setlist {
+		col, err := findInsertColumn(mockTablePlan, publicCols, assign.Column, given)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		expr, err := b.buildInsertValue(assign.Expr, col, mockTablePlan)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if expr, err = expr.ResolveIndices(mockTablePlan.Schema()); err != nil {
+			return errors.Trace(err)
+		}
+		insertPlan.Columns = append(insertPlan.Columns, col)
+		exprs = append(exprs, expr)
+	}
+	insertPlan.Lists = [][]expression.Expression{exprs}
+	return nil
+}
+
+func (b *PlanBuilder) buildValuesListOfInsert(insert *ast.InsertStmt, insertPlan *Insert, mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo) error {
+	cols, err := getInsertColumns(insert, mockTablePlan, publicCols)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// `INSERT INTO t VALUES ()` inserts a row of the default values.
+	if len(insert.Columns) == 0 && len(insert.Lists[0]) == 0 {
+		cols = nil
+	}
+	insertPlan.Columns = cols
+	insertPlan.Lists = make([][]expression.Expression, 0, len(insert.Lists))
+	for i, list := range insert.Lists {
+		if len(list) != len(cols) {
+			return ErrWrongValueCountOnRow.GenWithStackByArgs(i + 1)
+		}
+		exprs := make([]expression.Expression, 0, len(list))
+		for j, valueItem := range list {
+			expr, err := b.buildInsertValue(valueItem, cols[j], mockTablePlan)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if expr, err = expr.ResolveIndices(mockTablePlan.Schema()); err != nil {
+				return errors.Trace(err)
+			}
+			exprs = append(exprs, expr)
+		}
+		insertPlan.Lists = append(insertPlan.Lists, exprs)
+	}
+	return nil
+}
+
+func (b *PlanBuilder) buildSelectPlanOfInsert(insert *ast.InsertStmt, insertPlan *Insert, mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo) error {
+	cols, err := getInsertColumns(insert, mockTablePlan, publicCols)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	p, err := b.Build(insert.Select)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	logic, ok := p.(LogicalPlan)
+	if !ok {
+		return ErrUnsupportedType.GenWithStackByArgs(p)
+	}
+	if logic.Schema().Len() != len(cols) {
+		return ErrWrongValueCountOnRow.GenWithStackByArgs(1)
+	}
+	insertPlan.Columns = cols
+	insertPlan.SelectPlan, err = DoOptimize(logic)
+	return errors.Trace(err)
+}
+
+func (b *PlanBuilder) buildOnDuplicateOfInsert(insert *ast.InsertStmt, insertPlan *Insert, mockTablePlan LogicalPlan, publicCols []*model.ColumnInfo) error {
+	if len(insert.OnDuplicate) == 0 {
+		return nil
+	}
+	tableSchema := mockTablePlan.Schema()
+	// The columns of the row being inserted follow the ones of the duplicate row.
+	b.insertValuesSchema = expression.NewSchema(make([]*expression.Column, 0, tableSchema.Len())...)
+	defer func() { b.insertValuesSchema = nil }()
+	for _, col := range tableSchema.Columns {
+		newCol := *col
+		newCol.UniqueID = b.ctx.GetSessionVars().AllocPlanColumnID()
+		b.insertValuesSchema.Append(&newCol)
+	}
+	schema := expression.MergeSchema(tableSchema, b.insertValuesSchema)
+	for _, assign := range insert.OnDuplicate {
+		col, err := findInsertColumn(mockTablePlan, publicCols, assign.Column, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		expr, err := b.buildInsertValue(assign.Expr, col, mockTablePlan)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if expr, err = expr.ResolveIndices(schema); err != nil {
+			return errors.Trace(err)
+		}
+		exprCol, err := tableSchema.FindColumn(assign.Column)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		resolved, err := exprCol.ResolveIndices(tableSchema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		insertPlan.OnDuplicate = append(insertPlan.OnDuplicate, &expression.Assignment{
+			Col:  resolved.(*expression.Column),
+			Expr: expr,
+		})
+	}
+	return nil
+}
+
+func (b *PlanBuilder) buildAdmin(as *ast.AdminStmt) (Plan, error) {
+	b.visitInfo = appendVisitInfo(b.visitInfo, mysql.SuperPriv, "", "", "")
+	switch as.Tp {
+	case ast.AdminShowDDLJobs:
+		p := ShowDDLJobs{}.Init(b.ctx)
+		p.SetSchema(buildShowDDLJobsFields())
+		return p, nil
+	case ast.AdminCancelDDLJobs:
+		p := CancelDDLJobs{JobIDs: as.JobIDs}.Init(b.ctx)
+		p.SetSchema(buildCancelDDLJobsFields())
+		return p, nil
+	}
+	return nil, ErrUnsupportedType.GenWithStack("Unsupported ADMIN statement")
+}
+
+func buildShowDDLJobsFields() *expression.Schema {
+	schema := expression.NewSchema(make([]*expression.Column, 0, 10)...)
+	schema.Append(buildColumn("", "JOB_ID", mysql.TypeLonglong, 4))
+	schema.Append(buildColumn("", "DB_NAME", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "TABLE_NAME", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "JOB_TYPE", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "SCHEMA_STATE", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "SCHEMA_ID", mysql.TypeLonglong, 4))
+	schema.Append(buildColumn("", "TABLE_ID", mysql.TypeLonglong, 4))
+	schema.Append(buildColumn("", "ROW_COUNT", mysql.TypeLonglong, 4))
+	schema.Append(buildColumn("", "START_TIME", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "STATE", mysql.TypeVarchar, 64))
+	return schema
+}
+
+func buildCancelDDLJobsFields() *expression.Schema {
+	schema := expression.NewSchema(make([]*expression.Column, 0, 2)...)
+	schema.Append(buildColumn("", "JOB_ID", mysql.TypeVarchar, 64))
+	schema.Append(buildColumn("", "RESULT", mysql.TypeVarchar, 128))
+	return schema
+}
+
+// buildShow builds the plan of a SHOW statement. The pattern of LIKE is matched against the first
+// column, and the conditions are resolved by the shown rows.
+func (b *PlanBuilder) buildShow(show *ast.ShowStmt) (Plan, error) {
+	p := Show{
+		Tp:          show.Tp,
+		Column:      show.Column,
+		Full:        show.Full,
+		GlobalScope: show.GlobalScope,
+	}.Init(b.ctx)
+	switch show.Tp {
+	case ast.ShowDatabases, ast.ShowVariables, ast.ShowStatus, ast.ShowWarnings, ast.ShowErrors,
+		ast.ShowProcessList, ast.ShowEngines:
+	case ast.ShowTables:
+		p.DBName = model.NewCIStr(show.DBName)
+		if p.DBName.L == "" {
+			p.DBName = model.NewCIStr(b.ctx.GetSessionVars().CurrentDB)
+		}
+		if p.DBName.L == "" {
+			return nil, ErrNoDB
+		}
+		if !b.is.SchemaExists(p.DBName) {
+			return nil, infoschema.ErrDatabaseNotExists.GenWithStackByArgs(p.DBName)
+		}
+		b.visitInfo = appendVisitInfo(b.visitInfo, mysql.AllPrivMask, p.DBName.L, "", "")
+	case ast.ShowColumns, ast.ShowIndex, ast.ShowCreateTable:
+		// SHOW COLUMNS FROM tbl FROM db is the same as SHOW COLUMNS FROM db.tbl.
+		if show.DBName != "" {
+			show.Table.Schema = model.NewCIStr(show.DBName)
+		}
+		dbName, tableInfo, err := b.tableByName(show.Table)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		p.DBName, p.Table = dbName, tableInfo
+		if !infoschema.IsMemoryDB(dbName.L) {
+			b.visitInfo = appendVisitInfo(b.visitInfo, mysql.AllPrivMask, dbName.L, tableInfo.Name.L, "")
+		}
+	case ast.ShowGrants:
+		// The grants of the other users are read from the privilege tables.
+		if p.User = show.User; p.User == nil || p.User.CurrentUser || isCurrentUser(b.ctx, p.User) {
+			p.User = b.ctx.GetSessionVars().User
+			if p.User == nil {
+				return nil, errors.New("Session user is empty")
+			}
+			p.User = &auth.UserIdentity{Username: p.User.AuthUsername, Hostname: p.User.AuthHostname}
+		} else {
+			b.visitInfo = appendVisitInfo(b.visitInfo, mysql.SelectPriv, mysql.SystemDB, "", "")
+		}
+	default:
+		return nil, ErrUnsupportedType.GenWithStack("Unsupported SHOW statement")
+	}
+	p.SetSchema(buildShowSchema(show, p.DBName, p.User))
+	for _, col := range p.schema.Columns {
+		col.UniqueID = b.ctx.GetSessionVars().AllocPlanColumnID()
+	}
+
+	mockTablePlan := LogicalTableDual{}.Init(b.ctx)
+	mockTablePlan.SetSchema(p.schema)
+	var conds []ast.ExprNode
+	if show.Pattern != nil {
+		show.Pattern.Expr = &ast.ColumnNameExpr{
+			Name: &ast.ColumnName{Name: p.schema.Columns[0].ColName},
+		}
+		conds = append(conds, show.Pattern)
+	}
+	if show.Where != nil {
+		conds = append(conds, splitWhere(show.Where)...)
+	}
+	for _, cond := range conds {
+		expr, np, err := b.rewrite(cond, mockTablePlan, false)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if np != mockTablePlan {
+			return nil, ErrNotSupportedYet.GenWithStackByArgs("subquery in SHOW")
+		}
+		if expr, err = expr.ResolveIndices(p.schema); err != nil {
+			return nil, errors.Trace(err)
+		}
+		p.Conditions = append(p.Conditions, expr)
+	}
+	return p, nil
+}
+
+// buildShowSchema builds the columns of the rows shown by the SHOW statement, they are
+// varchars if the types are not given.
+func buildShowSchema(show *ast.ShowStmt, dbName model.CIStr, user *auth.UserIdentity) *expression.Schema {
+	var names []string
+	var ftypes []byte
+	switch show.Tp {
+	case ast.ShowDatabases:
+		names = []string{"Database"}
+	case ast.ShowTables:
+		names = []string{"Tables_in_" + dbName.O}
+		if show.Full {
+			names = append(names, "Table_type")
+		}
+	case ast.ShowColumns:
+		names = table.ColDescFieldNames(show.Full)
+	case ast.ShowIndex:
+		names = []string{"Table", "Non_unique", "Key_name", "Seq_in_index",
+			"Column_name", "Collation", "Cardinality", "Sub_part", "Packed",
+			"Null", "Index_type", "Comment", "Index_comment"}
+		ftypes = []byte{mysql.TypeVarchar, mysql.TypeLonglong, mysql.TypeVarchar, mysql.TypeLonglong,
+			mysql.TypeVarchar, mysql.TypeVarchar, mysql.TypeLonglong, mysql.TypeLonglong,
+			mysql.TypeVarchar, mysql.TypeVarchar, mysql.TypeVarchar, mysql.TypeVarchar, mysql.TypeVarchar}
+	case ast.ShowCreateTable:
+		names = []string{"Table", "Create Table"}
+	case ast.ShowVariables, ast.ShowStatus:
+		names = []string{"Variable_name", "Value"}
+	case ast.ShowWarnings, ast.ShowErrors:
+		names = []string{"Level", "Code", "Message"}
+		ftypes = []byte{mysql.TypeVarchar, mysql.TypeLong, mysql.TypeVarchar}
+	case ast.ShowProcessList:
+		names = []string{"Id", "User", "Host", "db", "Command", "Time", "State", "Info"}
+		ftypes = []byte{mysql.TypeLonglong, mysql.TypeVarchar, mysql.TypeVarchar, mysql.TypeVarchar,
+			mysql.TypeVarchar, mysql.TypeLong, mysql.TypeVarchar, mysql.TypeVarchar}
+	case ast.ShowEngines:
+		names = []string{"Engine", "Support", "Comment", "Transactions", "XA", "Savepoints"}
+	case ast.ShowGrants:
+		names = []string{fmt.Sprintf("Grants for %s@%s", user.Username, user.Hostname)}
+	}
+
+	schema := expression.NewSchema(make([]*expression.Column, 0, len(names))...)
+	for i, name := range names {
+		tp := mysql.TypeVarchar
+		if len(ftypes) != 0 {
+			tp = ftypes[i]
+		}
+		size, _ := mysql.GetDefaultFieldLengthAndDecimal(tp)
+		if tp == mysql.TypeVarchar {
+			size = 64
+		}
+		schema.Append(buildColumn("", name, tp, size))
+	}
+	return schema
+}
+
+// buildColumn builds a result column of the statements which don't read tables.
+func buildColumn(tableName, name string, tp byte, size int) *expression.Column {
+	cs, cl := types.DefaultCharsetForType(tp)
+	flag := mysql.UnsignedFlag
+	if tp == mysql.TypeVarchar || tp == mysql.TypeBlob {
+		cs = charset.CharsetUTF8MB4
+		cl = charset.CollationUTF8MB4
+		flag = 0
+	}
+
+	fieldType := &types.FieldType{
+		Charset: cs,
+		Collate: cl,
+		Tp:      tp,
+		Flen:    size,
+		Flag:    flag,
+	}
+	return &expression.Column{
+		ColName: model.NewCIStr(name),
+		TblName: model.NewCIStr(tableName),
+		RetType: fieldType,
+	}
+}
@@ -0,0 +1,251 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package core
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+
+	"fedb/parser"
+	"fedb/privilege"
+	"fedb/sessionctx"
+)
+
+// visitInfo is a privilege the statement requires, on a column, a table, a database, or globally
+// if db is empty.
+type visitInfo struct {
+	privilege mysql.PrivilegeType
+	db        string
+	table     string
+	column    string
+}
+
+func appendVisitInfo(vi []visitInfo, priv mysql.PrivilegeType, db, tbl, col string) []visitInfo {
+	return append(vi, visitInfo{privilege: priv, db: db, table: tbl, column: col})
+}
+
+// checkVisitInfo checks the privileges the statement requires, the error is reported for the first
+// one the user doesn't have. Nothing is checked if the session doesn't check the privileges.
+func checkVisitInfo(ctx sessionctx.Context, vs []visitInfo) error {
+	pm := ctx.GetPrivilegeManager()
+	if pm == nil {
+		return nil
+	}
+	for _, v := range vs {
+		if !pm.RequestVerification(v.db, v.table, v.column, v.privilege) {
+			return v.accessDenied(ctx.GetSessionVars().User)
+		}
+	}
+	return nil
+}
+
+// accessDenied returns the error MySQL reports when the user doesn't have the privilege.
+func (v *visitInfo) accessDenied(user *auth.UserIdentity) error {
+	// AllPrivMask is required by the SHOW statements, any privilege is enough.
+	priv := "SHOW"
+	if v.privilege != mysql.AllPrivMask {
+		priv = strings.ToUpper(mysql.Priv2Str[v.privilege])
+	}
+	switch {
+	case v.column != "":
+		return ErrColumnaccessDenied.GenWithStackByArgs(priv, user.Username, user.Hostname, v.column, v.table)
+	case v.table != "":
+		return ErrTableaccessDenied.GenWithStackByArgs(priv, user.Username, user.Hostname, v.table)
+	case v.db != "":
+		return ErrDBaccessDenied.GenWithStackByArgs(user.Username, user.Hostname, v.db)
+	}
+	return ErrSpecificAccessDenied.GenWithStackByArgs(priv)
+}
+
+// CheckPrivilege checks the privileges of the statements which are not built into plans, the ones
+// that are built are checked when they are optimized.
+func CheckPrivilege(ctx sessionctx.Context, node ast.StmtNode) error {
+	pm := ctx.GetPrivilegeManager()
+	if pm == nil {
+		return nil
+	}
+	if x, ok := node.(*ast.KillStmt); ok {
+		return errors.Trace(checkKill(ctx, pm, x))
+	}
+	vs, err := collectVisitInfo(ctx, node)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(checkVisitInfo(ctx, vs))
+}
+
+// checkKill checks the connection to kill is one of the user's, the connections of the others can be
+// killed with the SUPER privilege.
+func checkKill(ctx sessionctx.Context, pm privilege.Manager, stmt *ast.KillStmt) error {
+	sm := ctx.GetSessionManager()
+	if sm == nil {
+		return nil
+	}
+	pi, ok := sm.ShowProcessList()[stmt.ConnectionID]
+	if !ok || pi.User == ctx.GetSessionVars().User.Username || pm.RequestVerification("", "", "", mysql.SuperPriv) {
+		return nil
+	}
+	return ErrKillDenied.GenWithStackByArgs(stmt.ConnectionID)
+}
+
+// collectVisitInfo returns the privileges the statement requires, the tables whose database isn't
+// given are in the current database.
+func collectVisitInfo(ctx sessionctx.Context, node ast.StmtNode) ([]visitInfo, error) {
+	var vi []visitInfo
+	switch x := node.(type) {
+	case *ast.CreateDatabaseStmt:
+		vi = appendVisitInfo(vi, mysql.CreatePriv, strings.ToLower(x.Name), "", "")
+	case *ast.DropDatabaseStmt:
+		vi = appendVisitInfo(vi, mysql.DropPriv, strings.ToLower(x.Name), "", "")
+	case *ast.UseStmt:
+		vi = appendVisitInfo(vi, mysql.AllPrivMask, strings.ToLower(x.DBName), "", "")
+	case *ast.CreateTableStmt:
+		if x.ReferTable != nil {
+			return tableVisitInfo(ctx, vi, x.ReferTable, mysql.SelectPriv, mysql.CreatePriv)
+		}
+		return tableVisitInfo(ctx, vi, x.Table, mysql.CreatePriv)
+	case *ast.DropTableStmt:
+		for _, tn := range x.Tables {
+			var err error
+			if vi, err = tableVisitInfo(ctx, vi, tn, mysql.DropPriv); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+	case *ast.AlterTableStmt:
+		return tableVisitInfo(ctx, vi, x.Table, mysql.AlterPriv)
+	case *ast.CreateIndexStmt:
+		return tableVisitInfo(ctx, vi, x.Table, mysql.IndexPriv)
+	case *ast.DropIndexStmt:
+		return tableVisitInfo(ctx, vi, x.Table, mysql.IndexPriv)
+	case *ast.RenameTableStmt:
+		for _, t2t := range x.TableToTables {
+			var err error
+			if vi, err = tableVisitInfo(ctx, vi, t2t.OldTable, mysql.AlterPriv, mysql.DropPriv); err != nil {
+				return nil, errors.Trace(err)
+			}
+			if vi, err = tableVisitInfo(ctx, vi, t2t.NewTable, mysql.CreatePriv, mysql.InsertPriv); err != nil {
+				return nil, errors.Trace(err)
+			}
+		}
+	case *parser.CreateUserStmt, *ast.DropUserStmt, *parser.CreateRoleStmt, *parser.DropRoleStmt:
+		vi = appendVisitInfo(vi, mysql.CreateUserPriv, "", "", "")
+	case *parser.AlterUserStmt:
+		// ALTER USER USER() changes the password of the user itself.
+		if x.CurrentAuth == nil {
+			vi = appendVisitInfo(vi, mysql.CreateUserPriv, "", "", "")
+		}
+	case *parser.SetDefaultRoleStmt:
+		// The default roles of the user itself can be set without the privilege.
+		for _, u := range x.Users {
+			if !isCurrentUser(ctx, u) {
+				vi = appendVisitInfo(vi, mysql.CreateUserPriv, "", "", "")
+				break
+			}
+		}
+	case *parser.GrantRoleStmt, *parser.RevokeRoleStmt:
+		vi = appendVisitInfo(vi, mysql.SuperPriv, "", "", "")
+	case *ast.GrantStmt:
+		return grantVisitInfo(ctx, vi, x.Level, x.Privs)
+	case *ast.RevokeStmt:
+		return grantVisitInfo(ctx, vi, x.Level, x.Privs)
+	case *ast.SetStmt:
+		for _, v := range x.Variables {
+			if v.IsGlobal {
+				vi = appendVisitInfo(vi, mysql.SuperPriv, "", "", "")
+				break
+			}
+		}
+	case *ast.FlushStmt:
+		if x.Tp == ast.FlushPrivileges {
+			vi = appendVisitInfo(vi, mysql.SuperPriv, "", "", "")
+		}
+	}
+	return vi, nil
+}
+
+// tableVisitInfo appends the privileges on the table, the current database is used if the table
+// name is not qualified.
+func tableVisitInfo(ctx sessionctx.Context, vi []visitInfo, tn *ast.TableName, privs ...mysql.PrivilegeType) ([]visitInfo, error) {
+	db, err := defaultDBName(ctx, tn.Schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for _, priv := range privs {
+		vi = appendVisitInfo(vi, priv, db, tn.Name.L, "")
+	}
+	return vi, nil
+}
+
+// grantVisitInfo appends the privileges GRANT and REVOKE require, they are GRANT OPTION and the
+// privileges granted or revoked at the level.
+func grantVisitInfo(ctx sessionctx.Context, vi []visitInfo, level *ast.GrantLevel, privs []*ast.PrivElem) ([]visitInfo, error) {
+	db, table, err := GrantObject(ctx, level)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	vi = appendVisitInfo(vi, mysql.GrantPriv, db, table, "")
+	for _, item := range privs {
+		if item.Priv != mysql.AllPriv {
+			vi = appendVisitInfo(vi, item.Priv, db, table, "")
+			continue
+		}
+		for _, priv := range privilege.LevelPrivs(level.Level) {
+			if priv != mysql.GrantPriv {
+				vi = appendVisitInfo(vi, priv, db, table, "")
+			}
+		}
+	}
+	return vi, nil
+}
+
+// GrantObject returns the database and the table of the level of GRANT and REVOKE, they are empty
+// at the global level. The current database is used if the database isn't given.
+func GrantObject(ctx sessionctx.Context, level *ast.GrantLevel) (db, table string, err error) {
+	if level.Level == ast.GrantLevelGlobal {
+		return "", "", nil
+	}
+	db, err = defaultDBName(ctx, model.NewCIStr(level.DBName))
+	if err != nil {
+		return "", "", errors.Trace(err)
+	}
+	if level.Level == ast.GrantLevelTable {
+		table = strings.ToLower(level.TableName)
+	}
+	return db, table, nil
+}
+
+// defaultDBName returns the lower case name of the database, it's the current database if the name is empty.
+func defaultDBName(ctx sessionctx.Context, name model.CIStr) (string, error) {
+	if name.L != "" {
+		return name.L, nil
+	}
+	if db := ctx.GetSessionVars().CurrentDB; db != "" {
+		return strings.ToLower(db), nil
+	}
+	return "", ErrNoDB
+}
+
+// isCurrentUser checks whether the user is the account the session is authenticated as.
+func isCurrentUser(ctx sessionctx.Context, u *auth.UserIdentity) bool {
+	if u.CurrentUser {
+		return true
+	}
+	user := ctx.GetSessionVars().User
+	return user != nil && u.Username == user.AuthUsername && strings.EqualFold(u.Hostname, user.AuthHostname)
+}
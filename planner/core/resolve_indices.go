@@ -0,0 +1,187 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/resolve_indices.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/errors"
+
+	"fedb/expression"
+)
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalProjection) ResolveIndices() error {
+	err := p.basePhysicalPlan.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i, expr := range p.Exprs {
+		p.Exprs[i], err = expr.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalSelection) ResolveIndices() error {
+	err := p.basePhysicalPlan.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i, expr := range p.Conditions {
+		p.Conditions[i], err = expr.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalSort) ResolveIndices() error {
+	err := p.basePhysicalPlan.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, item := range p.ByItems {
+		item.Expr, err = item.Expr.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalTopN) ResolveIndices() error {
+	err := p.basePhysicalPlan.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, item := range p.ByItems {
+		item.Expr, err = item.Expr.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *basePhysicalAgg) ResolveIndices() error {
+	err := p.physicalSchemaProducer.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, aggFun := range p.AggFuncs {
+		for i, arg := range aggFun.Args {
+			aggFun.Args[i], err = arg.ResolveIndices(p.children[0].Schema())
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	for i, item := range p.GroupByItems {
+		p.GroupByItems[i], err = item.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+// The conditions are evaluated on the joined rows, whose columns are the ones of the left child
+// followed by the ones of the right child.
+func (p *basePhysicalJoin) ResolveIndices() error {
+	err := p.physicalSchemaProducer.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	lSchema := p.children[0].Schema()
+	rSchema := p.children[1].Schema()
+	if err = resolveColumns(p.LeftJoinKeys, lSchema); err != nil {
+		return errors.Trace(err)
+	}
+	if err = resolveColumns(p.RightJoinKeys, rSchema); err != nil {
+		return errors.Trace(err)
+	}
+	if err = resolveColumns(p.LeftNAKeys, lSchema); err != nil {
+		return errors.Trace(err)
+	}
+	if err = resolveColumns(p.RightNAKeys, rSchema); err != nil {
+		return errors.Trace(err)
+	}
+	mergedSchema := expression.MergeSchema(lSchema, rSchema)
+	for i, expr := range p.OtherConditions {
+		p.OtherConditions[i], err = expr.ResolveIndices(mergedSchema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for i, expr := range p.NAConditions {
+		p.NAConditions[i], err = expr.ResolveIndices(mergedSchema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func resolveColumns(cols []*expression.Column, schema *expression.Schema) error {
+	for i, col := range cols {
+		newCol, err := col.ResolveIndices(schema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		cols[i] = newCol.(*expression.Column)
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalIndexJoin) ResolveIndices() error {
+	err := p.basePhysicalJoin.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	innerSchema := p.children[p.InnerChildIdx].Schema()
+	for i, expr := range p.InnerFilters {
+		p.InnerFilters[i], err = expr.ResolveIndices(innerSchema)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// ResolveIndices implements Plan interface.
+func (p *PhysicalApply) ResolveIndices() error {
+	err := p.basePhysicalJoin.ResolveIndices()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, col := range p.OuterSchema {
+		newCol, err := col.Column.ResolveIndices(p.children[0].Schema())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		col.Column = *newCol.(*expression.Column)
+	}
+	return nil
+}
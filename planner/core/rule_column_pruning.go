@@ -0,0 +1,229 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/rule_column_pruning.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/terror"
+
+	"fedb/expression"
+	"fedb/expression/aggregation"
+)
+
+type columnPruner struct{}
+
+func (s *columnPruner) optimize(lp LogicalPlan) (LogicalPlan, error) {
+	lp.PruneColumns(lp.Schema().Columns)
+	return lp, nil
+}
+
+func getUsedList(usedCols []*expression.Column, schema *expression.Schema) []bool {
+	used := make([]bool, schema.Len())
+	for _, col := range usedCols {
+		if idx := schema.ColumnIndex(col); idx != -1 {
+			used[idx] = true
+		}
+	}
+	return used
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (p *baseLogicalPlan) PruneColumns(parentUsedCols []*expression.Column) {
+	if len(p.children) == 0 {
+		return
+	}
+	p.children[0].PruneColumns(parentUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (p *LogicalProjection) PruneColumns(parentUsedCols []*expression.Column) {
+	child := p.children[0]
+	used := getUsedList(parentUsedCols, p.schema)
+	for i := len(used) - 1; i >= 0; i-- {
+		if !used[i] {
+			p.schema.Columns = append(p.schema.Columns[:i], p.schema.Columns[i+1:]...)
+			p.Exprs = append(p.Exprs[:i], p.Exprs[i+1:]...)
+		}
+	}
+	var selfUsedCols []*expression.Column
+	for _, expr := range p.Exprs {
+		selfUsedCols = append(selfUsedCols, expression.ExtractColumns(expr)...)
+	}
+	child.PruneColumns(selfUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (p *LogicalSelection) PruneColumns(parentUsedCols []*expression.Column) {
+	child := p.children[0]
+	for _, cond := range p.Conditions {
+		parentUsedCols = append(parentUsedCols, expression.ExtractColumns(cond)...)
+	}
+	child.PruneColumns(parentUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (ds *DataSource) PruneColumns(parentUsedCols []*expression.Column) {
+	used := getUsedList(parentUsedCols, ds.schema)
+	firstCol, firstColInfo := ds.schema.Columns[0], ds.Columns[0]
+	for i := len(used) - 1; i >= 0; i-- {
+		if !used[i] {
+			ds.schema.Columns = append(ds.schema.Columns[:i], ds.schema.Columns[i+1:]...)
+			ds.Columns = append(ds.Columns[:i], ds.Columns[i+1:]...)
+		}
+	}
+	// For SQL like `select 1 from t`, the rows are still needed to be read, so one column is kept.
+	if ds.schema.Len() == 0 {
+		ds.schema.Append(firstCol)
+		ds.Columns = append(ds.Columns, firstColInfo)
+	}
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (la *LogicalAggregation) PruneColumns(parentUsedCols []*expression.Column) {
+	child := la.children[0]
+	used := getUsedList(parentUsedCols, la.Schema())
+	for i := len(used) - 1; i >= 0; i-- {
+		if !used[i] {
+			la.schema.Columns = append(la.schema.Columns[:i], la.schema.Columns[i+1:]...)
+			la.AggFuncs = append(la.AggFuncs[:i], la.AggFuncs[i+1:]...)
+		}
+	}
+	// If all the aggregate functions are pruned, one is kept so the number of the groups is not changed.
+	if len(la.AggFuncs) == 0 {
+		desc, err := aggregation.NewAggFuncDesc(la.ctx, ast.AggFuncFirstRow, []expression.Expression{expression.One}, false)
+		terror.Log(errors.Trace(err))
+		la.AggFuncs = []*aggregation.AggFuncDesc{desc}
+		la.schema.Columns = []*expression.Column{{
+			ColName:  model.NewCIStr("dummy_agg"),
+			UniqueID: la.ctx.GetSessionVars().AllocPlanColumnID(),
+			RetType:  desc.RetTp,
+		}}
+	}
+	var selfUsedCols []*expression.Column
+	for _, aggrFunc := range la.AggFuncs {
+		for _, arg := range aggrFunc.Args {
+			selfUsedCols = append(selfUsedCols, expression.ExtractColumns(arg)...)
+		}
+	}
+	for _, item := range la.GroupByItems {
+		selfUsedCols = append(selfUsedCols, expression.ExtractColumns(item)...)
+	}
+	child.PruneColumns(selfUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (ls *LogicalSort) PruneColumns(parentUsedCols []*expression.Column) {
+	child := ls.children[0]
+	for _, item := range ls.ByItems {
+		parentUsedCols = append(parentUsedCols, expression.ExtractColumns(item.Expr)...)
+	}
+	child.PruneColumns(parentUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (lt *LogicalTopN) PruneColumns(parentUsedCols []*expression.Column) {
+	child := lt.children[0]
+	for _, item := range lt.ByItems {
+		parentUsedCols = append(parentUsedCols, expression.ExtractColumns(item.Expr)...)
+	}
+	child.PruneColumns(parentUsedCols)
+}
+
+// PruneColumns implements LogicalPlan interface.
+// The columns of the children are pruned by position, so they still have the same schema as the union.
+func (p *LogicalUnionAll) PruneColumns(parentUsedCols []*expression.Column) {
+	used := getUsedList(parentUsedCols, p.schema)
+	hasBeenUsed := false
+	for i := range used {
+		hasBeenUsed = hasBeenUsed || used[i]
+	}
+	if !hasBeenUsed {
+		used[0] = true
+	}
+	for i := len(used) - 1; i >= 0; i-- {
+		if !used[i] {
+			p.schema.Columns = append(p.schema.Columns[:i], p.schema.Columns[i+1:]...)
+		}
+	}
+	for _, child := range p.children {
+		childUsedCols := make([]*expression.Column, 0, p.schema.Len())
+		for i, col := range child.Schema().Columns {
+			if used[i] {
+				childUsedCols = append(childUsedCols, col)
+			}
+		}
+		child.PruneColumns(childUsedCols)
+	}
+}
+
+func (p *LogicalJoin) extractUsedCols(parentUsedCols []*expression.Column) (leftCols []*expression.Column, rightCols []*expression.Column) {
+	for _, cond := range append(p.joinConditions(), p.NAConditions...) {
+		parentUsedCols = append(parentUsedCols, expression.ExtractColumns(cond)...)
+	}
+	lChild, rChild := p.children[0], p.children[1]
+	for _, col := range parentUsedCols {
+		if lChild.Schema().Contains(col) {
+			leftCols = append(leftCols, col)
+		} else if rChild.Schema().Contains(col) {
+			rightCols = append(rightCols, col)
+		}
+	}
+	return leftCols, rightCols
+}
+
+func (p *LogicalJoin) mergeSchema() {
+	lChild, rChild := p.children[0], p.children[1]
+	switch p.JoinType {
+	case SemiJoin, AntiSemiJoin:
+		p.schema = lChild.Schema().Clone()
+	case LeftOuterSemiJoin, AntiLeftOuterSemiJoin:
+		joinCol := p.schema.Columns[p.schema.Len()-1]
+		p.schema = lChild.Schema().Clone()
+		p.schema.Append(joinCol)
+	default:
+		p.schema = expression.MergeSchema(lChild.Schema(), rChild.Schema())
+		switch p.JoinType {
+		case LeftOuterJoin:
+			resetNotNullFlag(p.schema, lChild.Schema().Len(), p.schema.Len())
+		case RightOuterJoin:
+			resetNotNullFlag(p.schema, 0, lChild.Schema().Len())
+		}
+	}
+}
+
+// PruneColumns implements LogicalPlan interface.
+func (p *LogicalJoin) PruneColumns(parentUsedCols []*expression.Column) {
+	leftCols, rightCols := p.extractUsedCols(parentUsedCols)
+	p.children[0].PruneColumns(leftCols)
+	p.children[1].PruneColumns(rightCols)
+	p.mergeSchema()
+}
+
+// PruneColumns implements LogicalPlan interface.
+// The columns of the outer child used by the inner child are kept.
+func (la *LogicalApply) PruneColumns(parentUsedCols []*expression.Column) {
+	leftCols, rightCols := la.extractUsedCols(parentUsedCols)
+	la.children[1].PruneColumns(rightCols)
+	la.corCols = extractCorColumnsBySchema(la.children[1], la.children[0].Schema())
+	for _, col := range la.corCols {
+		leftCols = append(leftCols, &col.Column)
+	}
+	la.children[0].PruneColumns(leftCols)
+	la.mergeSchema()
+}
@@ -0,0 +1,161 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/rule_decorrelate.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+)
+
+// extractCorColumns extracts the correlated columns of the plan and its descendants.
+func extractCorColumns(p LogicalPlan) []*expression.CorrelatedColumn {
+	var exprs []expression.Expression
+	switch x := p.(type) {
+	case *DataSource:
+		exprs = x.pushedDownConds
+	case *LogicalSelection:
+		exprs = x.Conditions
+	case *LogicalProjection:
+		exprs = x.Exprs
+	case *LogicalAggregation:
+		exprs = append(exprs, x.GroupByItems...)
+		for _, f := range x.AggFuncs {
+			exprs = append(exprs, f.Args...)
+		}
+	case *LogicalSort:
+		for _, item := range x.ByItems {
+			exprs = append(exprs, item.Expr)
+		}
+	case *LogicalTopN:
+		for _, item := range x.ByItems {
+			exprs = append(exprs, item.Expr)
+		}
+	case *LogicalJoin:
+		exprs = append(x.joinConditions(), x.NAConditions...)
+	case *LogicalApply:
+		exprs = append(x.joinConditions(), x.NAConditions...)
+	}
+	var corCols []*expression.CorrelatedColumn
+	for _, expr := range exprs {
+		corCols = append(corCols, expression.ExtractCorColumns(expr)...)
+	}
+	for _, child := range p.Children() {
+		corCols = append(corCols, extractCorColumns(child)...)
+	}
+	return corCols
+}
+
+// extractCorColumnsBySchema only extracts the correlated columns that match the specified schema.
+// e.g. If the correlated columns from plan are [t1.a, t2.a, t3.a] and specified schema is [t2.a, t2.b, t2.c],
+// only [t2.a] is returned. The correlated columns of the same column share the same data.
+func extractCorColumnsBySchema(p LogicalPlan, schema *expression.Schema) []*expression.CorrelatedColumn {
+	corCols := extractCorColumns(p)
+	resultCorCols := make([]*expression.CorrelatedColumn, schema.Len())
+	for _, corCol := range corCols {
+		idx := schema.ColumnIndex(&corCol.Column)
+		if idx != -1 {
+			if resultCorCols[idx] == nil {
+				resultCorCols[idx] = &expression.CorrelatedColumn{
+					Column: *schema.Columns[idx],
+					Data:   new(types.Datum),
+				}
+			}
+			corCol.Data = resultCorCols[idx].Data
+		}
+	}
+	// Shrink slice. e.g. [col1, nil, col2, nil] will be changed to [col1, col2].
+	length := 0
+	for _, col := range resultCorCols {
+		if col != nil {
+			resultCorCols[length] = col
+			length++
+		}
+	}
+	return resultCorCols[:length]
+}
+
+// hasMaxOneRow checks whether the plan returns at most one row.
+func hasMaxOneRow(p LogicalPlan) bool {
+	switch x := p.(type) {
+	case *LogicalMaxOneRow:
+		return true
+	case *LogicalAggregation:
+		return len(x.GroupByItems) == 0
+	case *LogicalLimit:
+		return x.Count <= 1
+	case *LogicalTopN:
+		return x.Count <= 1
+	case *LogicalTableDual:
+		return x.RowCount <= 1
+	case *LogicalProjection, *LogicalSelection, *LogicalSort:
+		return hasMaxOneRow(x.Children()[0])
+	}
+	return false
+}
+
+// decorrelateSolver tries to convert apply plan to join plan.
+type decorrelateSolver struct{}
+
+// optimize implements logicalOptRule interface.
+func (s *decorrelateSolver) optimize(p LogicalPlan) (LogicalPlan, error) {
+	if apply, ok := p.(*LogicalApply); ok {
+		outerPlan := apply.children[0]
+		innerPlan := apply.children[1]
+		apply.corCols = extractCorColumnsBySchema(innerPlan, outerPlan.Schema())
+		if len(apply.corCols) == 0 {
+			// If the inner plan is non-correlated, the apply will be simplified to join.
+			join := &apply.LogicalJoin
+			join.self = join
+			join.tp = TypeJoin
+			p = join
+		} else if sel, ok := innerPlan.(*LogicalSelection); ok {
+			// If the inner plan is a selection, the conditions are pulled up as the join conditions.
+			conds := make([]expression.Expression, 0, len(sel.Conditions))
+			for _, cond := range sel.Conditions {
+				conds = append(conds, cond.Decorrelate(outerPlan.Schema()))
+			}
+			apply.attachOnConds(conds)
+			apply.SetChildren(outerPlan, sel.children[0])
+			return s.optimize(p)
+		} else if m, ok := innerPlan.(*LogicalMaxOneRow); ok && hasMaxOneRow(m.children[0]) {
+			// The check is unnecessary if the child returns at most one row.
+			apply.SetChildren(outerPlan, m.children[0])
+			return s.optimize(p)
+		} else if proj, ok := innerPlan.(*LogicalProjection); ok && apply.JoinType.IsSemiJoin() {
+			// The columns of the projection are only used by the conditions of the semi joins, so
+			// they are substituted by the expressions.
+			for i, expr := range proj.Exprs {
+				proj.Exprs[i] = expr.Decorrelate(outerPlan.Schema())
+			}
+			apply.columnSubstitute(proj.schema, proj.Exprs)
+			apply.SetChildren(outerPlan, proj.children[0])
+			return s.optimize(p)
+		}
+	}
+	newChildren := make([]LogicalPlan, 0, len(p.Children()))
+	for _, child := range p.Children() {
+		np, err := s.optimize(child)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		newChildren = append(newChildren, np)
+	}
+	p.SetChildren(newChildren...)
+	return p, nil
+}
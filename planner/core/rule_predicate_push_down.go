@@ -0,0 +1,236 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/planner/core/rule_predicate_push_down.go
+//
+
+package core
+
+import (
+	"github.com/pingcap/parser/ast"
+
+	"fedb/expression"
+	"fedb/sessionctx"
+)
+
+type ppdSolver struct{}
+
+func (s *ppdSolver) optimize(lp LogicalPlan) (LogicalPlan, error) {
+	_, p := lp.PredicatePushDown(nil)
+	return p, nil
+}
+
+func addSelection(p LogicalPlan, child LogicalPlan, conditions []expression.Expression, chIdx int) {
+	if len(conditions) == 0 {
+		p.Children()[chIdx] = child
+		return
+	}
+	selection := LogicalSelection{Conditions: conditions}.Init(p.context())
+	selection.SetChildren(child)
+	p.Children()[chIdx] = selection
+}
+
+// PredicatePushDown implements LogicalPlan interface.
+func (p *baseLogicalPlan) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	if len(p.children) == 0 {
+		return predicates, p.self
+	}
+	child := p.children[0]
+	rest, newChild := child.PredicatePushDown(predicates)
+	addSelection(p.self, newChild, rest, 0)
+	return nil, p.self
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalSelection) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	retConditions, child := p.children[0].PredicatePushDown(append(p.Conditions, predicates...))
+	if len(retConditions) > 0 {
+		p.Conditions = retConditions
+		p.children[0] = child
+		return nil, p
+	}
+	return nil, child
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+// The rows are filtered when they are read from the table.
+func (ds *DataSource) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	ds.pushedDownConds = predicates
+	return nil, ds
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalProjection) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan) {
+	canBePushed := make([]expression.Expression, 0, len(predicates))
+	for _, cond := range predicates {
+		canBePushed = append(canBePushed, expression.ColumnSubstitute(cond, p.Schema(), p.Exprs))
+	}
+	p.baseLogicalPlan.PredicatePushDown(canBePushed)
+	return nil, p
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalLimit) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	// Limit forbids any condition to push down.
+	p.baseLogicalPlan.PredicatePushDown(nil)
+	return predicates, p
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (la *LogicalAggregation) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	// The predicates over the aggregation are evaluated on the groups, so they are kept above it.
+	la.baseLogicalPlan.PredicatePushDown(nil)
+	return predicates, la
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalTopN) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	// TopN forbids any condition to push down.
+	p.baseLogicalPlan.PredicatePushDown(nil)
+	return predicates, p
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalUnionAll) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	for i, proj := range p.children {
+		rest, newChild := proj.PredicatePushDown(nil)
+		addSelection(p, newChild, rest, i)
+	}
+	return predicates, p
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalJoin) PredicatePushDown(predicates []expression.Expression) (ret []expression.Expression, retPlan LogicalPlan) {
+	simplifyOuterJoin(p, predicates)
+	var leftCond, rightCond []expression.Expression
+	switch p.JoinType {
+	case InnerJoin, SemiJoin:
+		// The predicates and the join conditions are the same for inner join, and the predicates
+		// over semi join are only on the left child.
+		p.resetConditions(append(p.joinConditions(), predicates...))
+		leftCond, rightCond = p.LeftConditions, p.RightConditions
+		p.LeftConditions, p.RightConditions = nil, nil
+	case AntiSemiJoin:
+		// The conditions on the left child decide which rows are unmatched, so only the predicates
+		// are pushed to the left child.
+		leftCond = predicates
+		rightCond, p.RightConditions = p.RightConditions, nil
+	case LeftOuterJoin, LeftOuterSemiJoin, AntiLeftOuterSemiJoin:
+		for _, cond := range predicates {
+			if expression.ExprFromSchema(cond, p.children[0].Schema()) {
+				leftCond = append(leftCond, cond)
+			} else {
+				ret = append(ret, cond)
+			}
+		}
+		rightCond, p.RightConditions = p.RightConditions, nil
+	case RightOuterJoin:
+		for _, cond := range predicates {
+			if expression.ExprFromSchema(cond, p.children[1].Schema()) {
+				rightCond = append(rightCond, cond)
+			} else {
+				ret = append(ret, cond)
+			}
+		}
+		leftCond, p.LeftConditions = p.LeftConditions, nil
+	}
+	leftRet, lCh := p.children[0].PredicatePushDown(leftCond)
+	rightRet, rCh := p.children[1].PredicatePushDown(rightCond)
+	addSelection(p, lCh, leftRet, 0)
+	addSelection(p, rCh, rightRet, 1)
+	return ret, p.self
+}
+
+// simplifyOuterJoin converts the outer join to inner join if a predicate is null-rejected on the
+// inner child, the padded rows are filtered out by it anyway.
+func simplifyOuterJoin(p *LogicalJoin, predicates []expression.Expression) {
+	if p.JoinType != LeftOuterJoin && p.JoinType != RightOuterJoin {
+		return
+	}
+	innerSchema := p.children[1].Schema()
+	if p.JoinType == RightOuterJoin {
+		innerSchema = p.children[0].Schema()
+	}
+	for _, cond := range predicates {
+		if isNullRejected(p.ctx, innerSchema, cond) {
+			p.JoinType = InnerJoin
+			return
+		}
+	}
+}
+
+// isNullRejected checks whether the condition is not true when the columns of the schema are NULL.
+func isNullRejected(ctx sessionctx.Context, schema *expression.Schema, expr expression.Expression) bool {
+	if sf, ok := expr.(*expression.ScalarFunction); ok {
+		switch sf.FuncName.L {
+		case ast.LogicAnd:
+			for _, arg := range sf.GetArgs() {
+				if isNullRejected(ctx, schema, arg) {
+					return true
+				}
+			}
+			return false
+		case ast.LogicOr:
+			for _, arg := range sf.GetArgs() {
+				if !isNullRejected(ctx, schema, arg) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	switch x := evaluateExprWithNull(ctx, schema, expr).(type) {
+	case *expression.Constant:
+		if x.Value.IsNull() {
+			return true
+		}
+		isTrue, err := x.Value.ToBool(ctx.GetSessionVars().StmtCtx)
+		return err == nil && isTrue == 0
+	case *expression.ScalarFunction:
+		// The comparisons are NULL if any argument is NULL.
+		switch x.FuncName.L {
+		case ast.EQ, ast.NE, ast.LT, ast.LE, ast.GT, ast.GE:
+			for _, arg := range x.GetArgs() {
+				if c, ok := arg.(*expression.Constant); ok && c.Value.IsNull() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// evaluateExprWithNull replaces the columns of the schema in the expression with NULL and folds
+// the constants.
+func evaluateExprWithNull(ctx sessionctx.Context, schema *expression.Schema, expr expression.Expression) expression.Expression {
+	switch x := expr.(type) {
+	case *expression.Column:
+		if schema.Contains(x) {
+			return &expression.Constant{RetType: x.RetType}
+		}
+	case *expression.ScalarFunction:
+		args := make([]expression.Expression, 0, len(x.GetArgs()))
+		for _, arg := range x.GetArgs() {
+			args = append(args, evaluateExprWithNull(ctx, schema, arg))
+		}
+		return expression.NewFunctionInternal(ctx, x.FuncName.L, x.RetType, args...)
+	}
+	return expr
+}
+
+// PredicatePushDown implements LogicalPlan PredicatePushDown interface.
+func (p *LogicalMaxOneRow) PredicatePushDown(predicates []expression.Expression) ([]expression.Expression, LogicalPlan) {
+	// The predicates would change whether there is more than one row.
+	p.baseLogicalPlan.PredicatePushDown(nil)
+	return predicates, p
+}
@@ -0,0 +1,39 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package core
+
+// pushDownTopNOptimizer merges a limit over a sort into a topN, so the rows can be sorted
+// without buffering the whole input.
+type pushDownTopNOptimizer struct{}
+
+func (s *pushDownTopNOptimizer) optimize(p LogicalPlan) (LogicalPlan, error) {
+	return pushDownTopN(p), nil
+}
+
+func pushDownTopN(p LogicalPlan) LogicalPlan {
+	for i, child := range p.Children() {
+		p.Children()[i] = pushDownTopN(child)
+	}
+	limit, ok := p.(*LogicalLimit)
+	if !ok {
+		return p
+	}
+	sort, ok := limit.children[0].(*LogicalSort)
+	if !ok {
+		return p
+	}
+	topN := LogicalTopN{ByItems: sort.ByItems, Offset: limit.Offset, Count: limit.Count}.Init(p.context())
+	topN.SetChildren(sort.children...)
+	return topN
+}
@@ -0,0 +1,183 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package privilege
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+// The auth plugins supported by the server.
+const (
+	AuthNativePassword      = "mysql_native_password"
+	AuthCachingSha2Password = "caching_sha2_password"
+)
+
+// The values of ssl_type in mysql.user, they are set by REQUIRE NONE, REQUIRE SSL and REQUIRE X509.
+const (
+	SSLTypeNone = ""
+	SSLTypeAny  = "ANY"
+	SSLTypeX509 = "X509"
+)
+
+// IsSupportedPlugin returns whether the auth plugin is supported.
+func IsSupportedPlugin(plugin string) bool {
+	switch plugin {
+	case AuthNativePassword, AuthCachingSha2Password:
+		return true
+	}
+	return false
+}
+
+// EncodePassword hashes the password in clear text by the auth plugin, the hash of an empty password
+// is empty.
+func EncodePassword(plugin, pwd string) string {
+	if len(pwd) == 0 {
+		return ""
+	}
+	if plugin == AuthCachingSha2Password {
+		return encodeSha2Password(pwd)
+	}
+	return auth.EncodePassword(pwd)
+}
+
+// IsValidHash returns whether the hash string, which is used by IDENTIFIED BY PASSWORD and
+// IDENTIFIED WITH plugin AS, is a valid hash of the auth plugin.
+func IsValidHash(plugin, hash string) bool {
+	if len(hash) == 0 {
+		return true
+	}
+	if plugin == AuthCachingSha2Password {
+		_, _, _, ok := decodeSha2Password(hash)
+		return ok
+	}
+	if len(hash) != mysql.PWDHashLen+1 || hash[0] != '*' {
+		return false
+	}
+	_, err := hex.DecodeString(hash[1:])
+	return err == nil
+}
+
+// CheckSSL checks whether the connection meets the TLS requirement of the user, REQUIRE X509 requires
+// a client certificate verified by the CA of the server.
+func CheckSSL(record *UserRecord, tlsState *tls.ConnectionState) bool {
+	switch record.SSLType {
+	case SSLTypeNone:
+		return true
+	case SSLTypeAny:
+		return tlsState != nil
+	case SSLTypeX509:
+		return tlsState != nil && len(tlsState.VerifiedChains) > 0
+	}
+	log.Errorf("User [%s] ssl_type %s from SystemDB is unknown", record.User, record.SSLType)
+	return false
+}
+
+// VerifyScramble verifies the response of the client to the salt. It's the scramble of
+// mysql_native_password, or the scramble of caching_sha2_password which is verified by the cached
+// digest of the password, so it fails if the user hasn't passed the full authentication yet.
+func (h *Handle) VerifyScramble(record *UserRecord, authentication, salt []byte) bool {
+	// The user without password is authenticated by an empty response, and vice versa.
+	if len(record.AuthString) == 0 || len(authentication) == 0 {
+		return len(record.AuthString) == 0 && len(authentication) == 0
+	}
+
+	if record.Plugin == AuthCachingSha2Password {
+		digest, ok := h.sha2Cache.get(record)
+		return ok && checkSha2Scramble(authentication, salt, digest)
+	}
+
+	if len(record.AuthString) != mysql.PWDHashLen+1 {
+		log.Errorf("User [%s] password from SystemDB not like a sha1sum", record.User)
+		return false
+	}
+	hpwd, err := auth.DecodePassword(record.AuthString)
+	if err != nil {
+		log.Errorf("Decode password string error %v", err)
+		return false
+	}
+	return len(authentication) == len(hpwd) && auth.CheckScrambledPassword(salt, hpwd, authentication)
+}
+
+// VerifyPassword verifies the password in clear text, which is sent by the full authentication of
+// caching_sha2_password. The digest of the password is cached if it succeeds, so that the following
+// connections of the user can pass the fast authentication.
+func (h *Handle) VerifyPassword(record *UserRecord, pwd string) bool {
+	if len(record.AuthString) == 0 || len(pwd) == 0 {
+		return len(record.AuthString) == 0 && len(pwd) == 0
+	}
+
+	if record.Plugin != AuthCachingSha2Password {
+		return subtle.ConstantTimeCompare([]byte(auth.EncodePassword(pwd)), []byte(record.AuthString)) == 1
+	}
+	if !checkSha2Password(pwd, record.AuthString) {
+		return false
+	}
+	stage1 := sha256.Sum256([]byte(pwd))
+	stage2 := sha256.Sum256(stage1[:])
+	h.sha2Cache.put(record, stage2[:])
+	return true
+}
+
+// sha2CacheEntry is the cached digest of a caching_sha2_password user, the digest is SHA256(SHA256(password)).
+type sha2CacheEntry struct {
+	authString string
+	digest     []byte
+}
+
+// sha2Cache is the in-memory cache of caching_sha2_password, keyed by the user record.
+type sha2Cache struct {
+	sync.Mutex
+	entries map[string]sha2CacheEntry
+}
+
+func newSha2Cache() *sha2Cache {
+	return &sha2Cache{entries: make(map[string]sha2CacheEntry)}
+}
+
+func sha2CacheKey(record *UserRecord) string {
+	return record.User + "@" + strings.ToLower(record.Host)
+}
+
+// get returns the cached digest of the user, the entry is ignored if the password is changed since it
+// was cached.
+func (c *sha2Cache) get(record *UserRecord) ([]byte, bool) {
+	c.Lock()
+	defer c.Unlock()
+	entry, ok := c.entries[sha2CacheKey(record)]
+	if !ok || entry.authString != record.AuthString {
+		return nil, false
+	}
+	return entry.digest, true
+}
+
+func (c *sha2Cache) put(record *UserRecord, digest []byte) {
+	c.Lock()
+	defer c.Unlock()
+	c.entries[sha2CacheKey(record)] = sha2CacheEntry{authString: record.AuthString, digest: digest}
+}
+
+func (c *sha2Cache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[string]sha2CacheEntry)
+}
@@ -0,0 +1,827 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/privilege/privileges/cache.go
+//
+
+package privilege
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/util/stringutil"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/util/chunk"
+	"fedb/util/sqlexec"
+)
+
+// UserRecord is used to represent a user record in privilege cache.
+type UserRecord struct {
+	Host       string // max length 64, primary key
+	User       string // max length 32, primary key
+	AuthString string // the hash of the password, empty if the user has no password.
+	Plugin     string // the auth plugin which AuthString is hashed by.
+	SSLType    string // the TLS the user requires, empty, ANY or X509.
+	Locked     bool   // the account can't be logged in as, the roles are locked.
+	Privileges mysql.PrivilegeType
+
+	// patChars is compiled from Host, cached for pattern match performance.
+	patChars []byte
+	patTypes []byte
+}
+
+type dbRecord struct {
+	Host       string
+	DB         string
+	User       string
+	Privileges mysql.PrivilegeType
+
+	// dbPatChars is compiled from DB, cached for pattern match performance.
+	dbPatChars []byte
+	dbPatTypes []byte
+}
+
+type tablesPrivRecord struct {
+	Host       string
+	DB         string
+	User       string
+	TableName  string
+	TablePriv  mysql.PrivilegeType
+	ColumnPriv mysql.PrivilegeType // the privileges granted on any column of the table.
+}
+
+type columnsPrivRecord struct {
+	Host       string
+	DB         string
+	User       string
+	TableName  string
+	ColumnName string
+	ColumnPriv mysql.PrivilegeType
+}
+
+// roleEdgeRecord is a role granted to an account, the account may be a role too.
+type roleEdgeRecord struct {
+	FromHost        string
+	FromUser        string
+	ToHost          string
+	ToUser          string
+	WithAdminOption bool
+}
+
+// defaultRoleRecord is a role activated when the account logs in.
+type defaultRoleRecord struct {
+	Host            string
+	User            string
+	DefaultRoleHost string
+	DefaultRoleUser string
+}
+
+// MySQLPrivilege is the in-memory cache of mysql privilege tables.
+type MySQLPrivilege struct {
+	User         []UserRecord
+	DB           []dbRecord
+	TablesPriv   []tablesPrivRecord
+	ColumnsPriv  []columnsPrivRecord
+	RoleEdges    []roleEdgeRecord
+	DefaultRoles []defaultRoleRecord
+}
+
+// LoadAll loads the tables from database to memory.
+func (p *MySQLPrivilege) LoadAll(exec sqlexec.RestrictedSQLExecutor) error {
+	for _, load := range []func(sqlexec.RestrictedSQLExecutor) error{
+		p.LoadUserTable,
+		p.LoadDBTable,
+		p.LoadTablesPrivTable,
+		p.LoadColumnsPrivTable,
+		p.LoadRoleEdgesTable,
+		p.LoadDefaultRolesTable,
+	} {
+		if err := load(exec); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// PrivColumns returns the columns of the privileges in mysql.user and mysql.db, separated by commas.
+func PrivColumns(privs []mysql.PrivilegeType) string {
+	cols := make([]string, 0, len(privs))
+	for _, priv := range privs {
+		cols = append(cols, mysql.Priv2UserCol[priv])
+	}
+	return strings.Join(cols, ", ")
+}
+
+// LoadUserTable loads the mysql.user table from database.
+func (p *MySQLPrivilege) LoadUserTable(exec sqlexec.RestrictedSQLExecutor) error {
+	err := p.loadTable(exec, "SELECT Host, User, authentication_string, plugin, ssl_type, account_locked, "+
+		PrivColumns(mysql.AllGlobalPrivs)+" FROM mysql.user", p.decodeUserTableRow)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// See https://dev.mysql.com/doc/refman/8.0/en/connection-access.html
+	// When multiple matches are possible, the server must determine which of them to use. It resolves this issue as follows:
+	// 1. Whenever the server reads the user table into memory, it sorts the rows.
+	// 2. When a client attempts to connect, the server looks through the rows in sorted order.
+	// 3. The server uses the first row that matches the client host name and user name.
+	// The server uses sorting rules that order rows with the most-specific Host values first.
+	p.SortUserTable()
+	return nil
+}
+
+type sortedUserRecord []UserRecord
+
+func (s sortedUserRecord) Len() int {
+	return len(s)
+}
+
+func (s sortedUserRecord) Less(i, j int) bool {
+	x := s[i]
+	y := s[j]
+
+	// Compare two item by user's host first.
+	c1 := compareHost(x.Host, y.Host)
+	if c1 < 0 {
+		return true
+	}
+	if c1 > 0 {
+		return false
+	}
+
+	// Then, compare item by user's name value.
+	return x.User < y.User
+}
+
+// compareHost compares two host string using some special rules, return value 1, 0, -1 means > = <.
+func compareHost(x, y string) int {
+	// The more-specific, the smaller it is.
+	// The pattern '%' means “any host” and is least specific.
+	if y == `%` {
+		if x == `%` {
+			return 0
+		}
+		return -1
+	}
+
+	// The empty string '' also means “any host” but sorts after '%'.
+	if y == "" {
+		if x == "" {
+			return 0
+		}
+		return -1
+	}
+
+	// One of them end with `%`.
+	xEnd := strings.HasSuffix(x, `%`)
+	yEnd := strings.HasSuffix(y, `%`)
+	if xEnd || yEnd {
+		switch {
+		case !xEnd && yEnd:
+			return -1
+		case xEnd && !yEnd:
+			return 1
+		case xEnd && yEnd:
+			// 192.168.199.% smaller than 192.168.%
+			// A not very accurate comparison, compare them by length.
+			if len(x) > len(y) {
+				return -1
+			}
+			if len(x) < len(y) {
+				return 1
+			}
+		}
+		return 0
+	}
+
+	// For other case, the order is nondeterministic.
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func (s sortedUserRecord) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// SortUserTable sorts p.User in the MySQLPrivilege struct.
+func (p MySQLPrivilege) SortUserTable() {
+	sort.Stable(sortedUserRecord(p.User))
+}
+
+// LoadDBTable loads the mysql.db table from database.
+func (p *MySQLPrivilege) LoadDBTable(exec sqlexec.RestrictedSQLExecutor) error {
+	return p.loadTable(exec, "SELECT Host, DB, User, "+PrivColumns(mysql.AllDBPrivs)+" FROM mysql.db", p.decodeDBTableRow)
+}
+
+// LoadTablesPrivTable loads the mysql.tables_priv table from database.
+func (p *MySQLPrivilege) LoadTablesPrivTable(exec sqlexec.RestrictedSQLExecutor) error {
+	return p.loadTable(exec, "SELECT Host, DB, User, Table_name, Table_priv, Column_priv FROM mysql.tables_priv",
+		p.decodeTablesPrivTableRow)
+}
+
+// LoadColumnsPrivTable loads the mysql.columns_priv table from database.
+func (p *MySQLPrivilege) LoadColumnsPrivTable(exec sqlexec.RestrictedSQLExecutor) error {
+	return p.loadTable(exec, "SELECT Host, DB, User, Table_name, Column_name, Column_priv FROM mysql.columns_priv",
+		p.decodeColumnsPrivTableRow)
+}
+
+// LoadRoleEdgesTable loads the mysql.role_edges table from database.
+func (p *MySQLPrivilege) LoadRoleEdgesTable(exec sqlexec.RestrictedSQLExecutor) error {
+	return p.loadTable(exec, "SELECT FROM_HOST, FROM_USER, TO_HOST, TO_USER, WITH_ADMIN_OPTION FROM mysql.role_edges",
+		p.decodeRoleEdgesTableRow)
+}
+
+// LoadDefaultRolesTable loads the mysql.default_roles table from database.
+func (p *MySQLPrivilege) LoadDefaultRolesTable(exec sqlexec.RestrictedSQLExecutor) error {
+	return p.loadTable(exec, "SELECT HOST, USER, DEFAULT_ROLE_HOST, DEFAULT_ROLE_USER FROM mysql.default_roles",
+		p.decodeDefaultRolesTableRow)
+}
+
+func (p *MySQLPrivilege) loadTable(exec sqlexec.RestrictedSQLExecutor, sql string,
+	decodeTableRow func(chunk.Row, []*ast.ResultField) error) error {
+	rows, fs, err := exec.ExecRestrictedSQL(goctx.Background(), sql)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, row := range rows {
+		if err = decodeTableRow(row, fs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeUserTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value UserRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "user":
+			value.User = row.GetString(i)
+		case "host":
+			value.Host = row.GetString(i)
+			value.patChars, value.patTypes = stringutil.CompilePattern(value.Host, '\\')
+		case "authentication_string":
+			value.AuthString = row.GetString(i)
+		case "plugin":
+			value.Plugin = strings.ToLower(row.GetString(i))
+		case "ssl_type":
+			value.SSLType = strings.ToUpper(row.GetString(i))
+		case "account_locked":
+			value.Locked = row.GetEnum(i).String() == "Y"
+		default:
+			value.Privileges |= decodeEnumPriv(row, i, f)
+		}
+	}
+	if value.Plugin == "" {
+		value.Plugin = AuthNativePassword
+	}
+	p.User = append(p.User, value)
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeDBTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value dbRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "user":
+			value.User = row.GetString(i)
+		case "host":
+			value.Host = row.GetString(i)
+		case "db":
+			value.DB = row.GetString(i)
+			value.dbPatChars, value.dbPatTypes = stringutil.CompilePattern(strings.ToUpper(value.DB), '\\')
+		default:
+			value.Privileges |= decodeEnumPriv(row, i, f)
+		}
+	}
+	p.DB = append(p.DB, value)
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeTablesPrivTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value tablesPrivRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "user":
+			value.User = row.GetString(i)
+		case "host":
+			value.Host = row.GetString(i)
+		case "db":
+			value.DB = row.GetString(i)
+		case "table_name":
+			value.TableName = row.GetString(i)
+		case "table_priv":
+			value.TablePriv = DecodeSetPrivs(row.GetSet(i).Name)
+		case "column_priv":
+			value.ColumnPriv = DecodeSetPrivs(row.GetSet(i).Name)
+		}
+	}
+	p.TablesPriv = append(p.TablesPriv, value)
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeColumnsPrivTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value columnsPrivRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "user":
+			value.User = row.GetString(i)
+		case "host":
+			value.Host = row.GetString(i)
+		case "db":
+			value.DB = row.GetString(i)
+		case "table_name":
+			value.TableName = row.GetString(i)
+		case "column_name":
+			value.ColumnName = row.GetString(i)
+		case "column_priv":
+			value.ColumnPriv = DecodeSetPrivs(row.GetSet(i).Name)
+		}
+	}
+	p.ColumnsPriv = append(p.ColumnsPriv, value)
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeRoleEdgesTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value roleEdgeRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "from_host":
+			value.FromHost = row.GetString(i)
+		case "from_user":
+			value.FromUser = row.GetString(i)
+		case "to_host":
+			value.ToHost = row.GetString(i)
+		case "to_user":
+			value.ToUser = row.GetString(i)
+		case "with_admin_option":
+			value.WithAdminOption = row.GetEnum(i).String() == "Y"
+		}
+	}
+	p.RoleEdges = append(p.RoleEdges, value)
+	return nil
+}
+
+func (p *MySQLPrivilege) decodeDefaultRolesTableRow(row chunk.Row, fs []*ast.ResultField) error {
+	var value defaultRoleRecord
+	for i, f := range fs {
+		if row.IsNull(i) {
+			continue
+		}
+		switch f.ColumnAsName.L {
+		case "host":
+			value.Host = row.GetString(i)
+		case "user":
+			value.User = row.GetString(i)
+		case "default_role_host":
+			value.DefaultRoleHost = row.GetString(i)
+		case "default_role_user":
+			value.DefaultRoleUser = row.GetString(i)
+		}
+	}
+	p.DefaultRoles = append(p.DefaultRoles, value)
+	return nil
+}
+
+// decodeEnumPriv returns the privilege of the column if it's a 'Y' privilege column.
+func decodeEnumPriv(row chunk.Row, i int, f *ast.ResultField) mysql.PrivilegeType {
+	priv, ok := mysql.Col2PrivType[f.ColumnAsName.O]
+	if !ok || f.Column.Tp != mysql.TypeEnum || row.GetEnum(i).String() != "Y" {
+		return 0
+	}
+	return priv
+}
+
+// DecodeSetPrivs returns the privileges of the value of a SET column of tables_priv or columns_priv.
+func DecodeSetPrivs(s string) mysql.PrivilegeType {
+	var privs mysql.PrivilegeType
+	if s == "" {
+		return privs
+	}
+	for _, str := range strings.Split(s, ",") {
+		priv, ok := mysql.SetStr2Priv[str]
+		if !ok {
+			log.Warn("unsupported privilege type:", str)
+			continue
+		}
+		privs |= priv
+	}
+	return privs
+}
+
+// EncodeSetPrivs returns the value of a SET column of tables_priv or columns_priv of the privileges.
+func EncodeSetPrivs(privs mysql.PrivilegeType, allPrivs []mysql.PrivilegeType) string {
+	strs := make([]string, 0, len(allPrivs))
+	for _, priv := range allPrivs {
+		if privs&priv > 0 {
+			strs = append(strs, mysql.Priv2SetStr[priv])
+		}
+	}
+	return strings.Join(strs, ",")
+}
+
+func (record *UserRecord) match(user, host string) bool {
+	return record.User == user && patternMatch(strings.ToLower(host), record.patChars, record.patTypes)
+}
+
+// patternMatch matches "%" the same way as ".*" in regular expression, for example,
+// "10.0.%" would match "10.0.1" "10.0.1.118" ...
+func patternMatch(str string, patChars, patTypes []byte) bool {
+	return stringutil.DoMatch(str, patChars, patTypes)
+}
+
+// MatchUser finds the most specific record of the user which matches the host, it's nil if no
+// record matches. Only the most specific record is used for authentication as MySQL does, a wrong
+// password is not tried with the other records.
+func (p *MySQLPrivilege) MatchUser(user, host string) *UserRecord {
+	for i := 0; i < len(p.User); i++ {
+		record := &p.User[i]
+		if record.match(user, host) {
+			return record
+		}
+	}
+	return nil
+}
+
+// findUser returns the record of the account, the host is matched exactly.
+func (p *MySQLPrivilege) findUser(user, host string) *UserRecord {
+	for i := 0; i < len(p.User); i++ {
+		record := &p.User[i]
+		if record.User == user && strings.EqualFold(record.Host, host) {
+			return record
+		}
+	}
+	return nil
+}
+
+// dbPrivileges returns the privileges of the account on the database, the records whose database
+// patterns match it are all counted.
+func (p *MySQLPrivilege) dbPrivileges(user, host, db string) mysql.PrivilegeType {
+	var privs mysql.PrivilegeType
+	for i := 0; i < len(p.DB); i++ {
+		record := &p.DB[i]
+		if record.User == user && strings.EqualFold(record.Host, host) &&
+			patternMatch(strings.ToUpper(db), record.dbPatChars, record.dbPatTypes) {
+			privs |= record.Privileges
+		}
+	}
+	return privs
+}
+
+func (p *MySQLPrivilege) findTablesPriv(user, host, db, table string) *tablesPrivRecord {
+	for i := 0; i < len(p.TablesPriv); i++ {
+		record := &p.TablesPriv[i]
+		if record.User == user && strings.EqualFold(record.Host, host) &&
+			strings.EqualFold(record.DB, db) && strings.EqualFold(record.TableName, table) {
+			return record
+		}
+	}
+	return nil
+}
+
+func (p *MySQLPrivilege) findColumnsPriv(user, host, db, table, column string) *columnsPrivRecord {
+	for i := 0; i < len(p.ColumnsPriv); i++ {
+		record := &p.ColumnsPriv[i]
+		if record.User == user && strings.EqualFold(record.Host, host) && strings.EqualFold(record.DB, db) &&
+			strings.EqualFold(record.TableName, table) && strings.EqualFold(record.ColumnName, column) {
+			return record
+		}
+	}
+	return nil
+}
+
+// GrantedRoles returns the roles granted to the account.
+func (p *MySQLPrivilege) GrantedRoles(user, host string) []*auth.UserIdentity {
+	var roles []*auth.UserIdentity
+	for _, edge := range p.RoleEdges {
+		if edge.ToUser == user && strings.EqualFold(edge.ToHost, host) {
+			roles = append(roles, &auth.UserIdentity{Username: edge.FromUser, Hostname: edge.FromHost})
+		}
+	}
+	return roles
+}
+
+// GetDefaultRoles returns the roles activated when the account logs in.
+func (p *MySQLPrivilege) GetDefaultRoles(user, host string) []*auth.UserIdentity {
+	var roles []*auth.UserIdentity
+	for _, record := range p.DefaultRoles {
+		if record.User == user && strings.EqualFold(record.Host, host) {
+			roles = append(roles, &auth.UserIdentity{Username: record.DefaultRoleUser, Hostname: record.DefaultRoleHost})
+		}
+	}
+	return roles
+}
+
+// activeAccounts returns the account and the roles whose privileges it has, they are the active
+// roles which are still granted to it, and the roles granted to them in turn.
+func (p *MySQLPrivilege) activeAccounts(user, host string, roles []*auth.UserIdentity) []*auth.UserIdentity {
+	accounts := []*auth.UserIdentity{{Username: user, Hostname: host}}
+	granted := p.GrantedRoles(user, host)
+	for _, role := range roles {
+		if findAccount(granted, role.Username, role.Hostname) && !findAccount(accounts, role.Username, role.Hostname) {
+			accounts = append(accounts, role)
+		}
+	}
+	// The roles may be granted to each other in a cycle, the found ones are skipped.
+	for i := 1; i < len(accounts); i++ {
+		for _, role := range p.GrantedRoles(accounts[i].Username, accounts[i].Hostname) {
+			if !findAccount(accounts, role.Username, role.Hostname) {
+				accounts = append(accounts, role)
+			}
+		}
+	}
+	return accounts
+}
+
+func findAccount(accounts []*auth.UserIdentity, user, host string) bool {
+	for _, account := range accounts {
+		if account.Username == user && strings.EqualFold(account.Hostname, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestVerification checks whether the account has the privilege, by itself or by the roles it
+// activates. The database privileges are checked only if db isn't empty, and so are the table and
+// the column privileges. A table is accessible by the privilege granted on any of its columns, the
+// columns are checked one by one then. AllPrivMask means any privilege, a database is accessible by
+// any privilege on the tables in it too.
+func (p *MySQLPrivilege) RequestVerification(user, host string, roles []*auth.UserIdentity, db, table, column string,
+	priv mysql.PrivilegeType) bool {
+	if priv == 0 {
+		return true
+	}
+	for _, account := range p.activeAccounts(user, host, roles) {
+		if p.requestVerification(account.Username, account.Hostname, db, table, column, priv) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *MySQLPrivilege) requestVerification(user, host, db, table, column string, priv mysql.PrivilegeType) bool {
+	if record := p.findUser(user, host); record != nil && record.Privileges&priv > 0 {
+		return true
+	}
+	if db == "" {
+		return false
+	}
+	if table == "" && priv == mysql.AllPrivMask {
+		return p.dbIsVisible(user, host, db)
+	}
+	if p.dbPrivileges(user, host, db)&priv > 0 {
+		return true
+	}
+	if table == "" {
+		return false
+	}
+	if record := p.findTablesPriv(user, host, db, table); record != nil {
+		if record.TablePriv&priv > 0 || column == "" && record.ColumnPriv&priv > 0 {
+			return true
+		}
+	}
+	if column == "" {
+		return false
+	}
+	record := p.findColumnsPriv(user, host, db, table, column)
+	return record != nil && record.ColumnPriv&priv > 0
+}
+
+// DBIsVisible checks whether the account has any privilege on the database, by itself or by the
+// roles it activates. INFORMATION_SCHEMA is visible to all the users.
+func (p *MySQLPrivilege) DBIsVisible(user, host string, roles []*auth.UserIdentity, db string) bool {
+	if strings.EqualFold(db, "INFORMATION_SCHEMA") {
+		return true
+	}
+	for _, account := range p.activeAccounts(user, host, roles) {
+		if p.dbIsVisible(account.Username, account.Hostname, db) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *MySQLPrivilege) dbIsVisible(user, host, db string) bool {
+	if record := p.findUser(user, host); record != nil && record.Privileges != 0 {
+		return true
+	}
+	if p.dbPrivileges(user, host, db) != 0 {
+		return true
+	}
+	for _, record := range p.TablesPriv {
+		if record.User == user && strings.EqualFold(record.Host, host) && strings.EqualFold(record.DB, db) &&
+			record.TablePriv|record.ColumnPriv != 0 {
+			return true
+		}
+	}
+	for _, record := range p.ColumnsPriv {
+		if record.User == user && strings.EqualFold(record.Host, host) && strings.EqualFold(record.DB, db) &&
+			record.ColumnPriv != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ShowGrants returns the GRANT statements of the privileges and the roles granted to the account,
+// false is returned if the account doesn't exist. The global privileges are always shown, USAGE
+// means none.
+func (p *MySQLPrivilege) ShowGrants(user, host string) ([]string, bool) {
+	record := p.findUser(user, host)
+	if record == nil {
+		return nil, false
+	}
+	account := quoteAccount(user, record.Host)
+	gs := []string{grantString(privString(record.Privileges, mysql.AllGlobalPrivs, nil), "*.*", account,
+		record.Privileges)}
+
+	var dbs []dbRecord
+	for _, record := range p.DB {
+		if record.User == user && strings.EqualFold(record.Host, host) && record.Privileges != 0 {
+			dbs = append(dbs, record)
+		}
+	}
+	sort.Slice(dbs, func(i, j int) bool { return dbs[i].DB < dbs[j].DB })
+	for _, record := range dbs {
+		gs = append(gs, grantString(privString(record.Privileges, mysql.AllDBPrivs, nil),
+			quoteIdent(record.DB)+".*", account, record.Privileges))
+	}
+
+	var tables []tablesPrivRecord
+	for _, record := range p.TablesPriv {
+		if record.User == user && strings.EqualFold(record.Host, host) && record.TablePriv|record.ColumnPriv != 0 {
+			tables = append(tables, record)
+		}
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].DB != tables[j].DB {
+			return tables[i].DB < tables[j].DB
+		}
+		return tables[i].TableName < tables[j].TableName
+	})
+	for _, record := range tables {
+		columns := make(map[mysql.PrivilegeType][]string)
+		for _, col := range p.ColumnsPriv {
+			if col.User != user || !strings.EqualFold(col.Host, host) || !strings.EqualFold(col.DB, record.DB) ||
+				!strings.EqualFold(col.TableName, record.TableName) {
+				continue
+			}
+			for _, priv := range mysql.AllColumnPrivs {
+				if col.ColumnPriv&priv > 0 {
+					columns[priv] = append(columns[priv], quoteIdent(col.ColumnName))
+				}
+			}
+		}
+		for _, cols := range columns {
+			sort.Strings(cols)
+		}
+		gs = append(gs, grantString(privString(record.TablePriv, mysql.AllTablePrivs, columns),
+			quoteIdent(record.DB)+"."+quoteIdent(record.TableName), account, record.TablePriv))
+	}
+
+	var roles, adminRoles []string
+	for _, edge := range p.RoleEdges {
+		if edge.ToUser != user || !strings.EqualFold(edge.ToHost, host) {
+			continue
+		}
+		if edge.WithAdminOption {
+			adminRoles = append(adminRoles, quoteAccount(edge.FromUser, edge.FromHost))
+		} else {
+			roles = append(roles, quoteAccount(edge.FromUser, edge.FromHost))
+		}
+	}
+	sort.Strings(roles)
+	sort.Strings(adminRoles)
+	if len(roles) > 0 {
+		gs = append(gs, fmt.Sprintf("GRANT %s TO %s", strings.Join(roles, ","), account))
+	}
+	if len(adminRoles) > 0 {
+		gs = append(gs, fmt.Sprintf("GRANT %s TO %s WITH ADMIN OPTION", strings.Join(adminRoles, ","), account))
+	}
+	return gs, true
+}
+
+func grantString(privs, object, account string, granted mysql.PrivilegeType) string {
+	s := fmt.Sprintf("GRANT %s ON %s TO %s", privs, object, account)
+	if granted&mysql.GrantPriv > 0 {
+		s += " WITH GRANT OPTION"
+	}
+	return s
+}
+
+// privString returns the privileges of a GRANT statement, GRANT OPTION is shown by WITH GRANT OPTION.
+// The columns are the ones the privileges are granted on, if they are not granted on the table.
+func privString(privs mysql.PrivilegeType, allPrivs []mysql.PrivilegeType, columns map[mysql.PrivilegeType][]string) string {
+	privs &^= mysql.GrantPriv
+	if len(columns) == 0 && privs == computePrivMask(allPrivs)&^mysql.GrantPriv {
+		return mysql.AllPrivilegeLiteral
+	}
+	strs := make([]string, 0, len(allPrivs))
+	for _, priv := range allPrivs {
+		name := strings.ToUpper(mysql.Priv2Str[priv])
+		switch {
+		case priv == mysql.GrantPriv:
+		case privs&priv > 0:
+			strs = append(strs, name)
+		case len(columns[priv]) > 0:
+			strs = append(strs, fmt.Sprintf("%s (%s)", name, strings.Join(columns[priv], ", ")))
+		}
+	}
+	if len(strs) == 0 {
+		return "USAGE"
+	}
+	return strings.Join(strs, ", ")
+}
+
+func computePrivMask(privs []mysql.PrivilegeType) mysql.PrivilegeType {
+	var mask mysql.PrivilegeType
+	for _, p := range privs {
+		mask |= p
+	}
+	return mask
+}
+
+func quoteIdent(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+func quoteAccount(user, host string) string {
+	return quoteIdent(user) + "@" + quoteIdent(host)
+}
+
+// Handle wraps MySQLPrivilege providing thread safe access.
+type Handle struct {
+	priv atomic.Value
+	// sha2Cache caches the password digests of the caching_sha2_password users which
+	// have passed the full authentication.
+	sha2Cache *sha2Cache
+}
+
+// NewHandle returns a Handle.
+func NewHandle() *Handle {
+	h := &Handle{sha2Cache: newSha2Cache()}
+	h.priv.Store(&MySQLPrivilege{})
+	return h
+}
+
+// Get the MySQLPrivilege for read.
+func (h *Handle) Get() *MySQLPrivilege {
+	return h.priv.Load().(*MySQLPrivilege)
+}
+
+// Update loads all the privilege info from kv storage.
+func (h *Handle) Update(exec sqlexec.RestrictedSQLExecutor) error {
+	var priv MySQLPrivilege
+	err := priv.LoadAll(exec)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	h.priv.Store(&priv)
+	return nil
+}
+
+// ClearCache empties the cache of caching_sha2_password as FLUSH PRIVILEGES does in MySQL, so
+// that all the users must pass the full authentication again.
+func (h *Handle) ClearCache() {
+	h.sha2Cache.clear()
+}
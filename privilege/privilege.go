@@ -0,0 +1,76 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/privilege/privilege.go
+//
+
+package privilege
+
+import (
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+)
+
+// Manager is the interface for providing privilege related operations.
+type Manager interface {
+	// RequestVerification verifies user privilege for the request.
+	// If table is "", only check global/db scope privileges.
+	// If table is not "", check global/db/table scope privileges, and the column privileges if column
+	// is not "". AllPrivMask means any privilege would be OK.
+	RequestVerification(db, table, column string, priv mysql.PrivilegeType) bool
+
+	// DBIsVisible returns true is the database is visible to current user.
+	DBIsVisible(db string) bool
+
+	// ShowGrants returns the GRANT statements of the account, false is returned if it doesn't exist.
+	ShowGrants(user, host string) ([]string, bool)
+}
+
+// UserPrivileges implements Manager by the privilege cache, for the account a session is
+// authenticated as and the roles it activates.
+type UserPrivileges struct {
+	*Handle
+
+	User  string
+	Host  string
+	Roles []*auth.UserIdentity
+}
+
+var _ Manager = (*UserPrivileges)(nil)
+
+// RequestVerification implements the Manager interface.
+func (p *UserPrivileges) RequestVerification(db, table, column string, priv mysql.PrivilegeType) bool {
+	return p.Get().RequestVerification(p.User, p.Host, p.Roles, db, table, column, priv)
+}
+
+// DBIsVisible implements the Manager interface.
+func (p *UserPrivileges) DBIsVisible(db string) bool {
+	return p.Get().DBIsVisible(p.User, p.Host, p.Roles, db)
+}
+
+// ShowGrants implements the Manager interface.
+func (p *UserPrivileges) ShowGrants(user, host string) ([]string, bool) {
+	return p.Get().ShowGrants(user, host)
+}
+
+// LevelPrivs returns the privileges which can be granted at the level, ALL means them.
+func LevelPrivs(level ast.GrantLevelType) []mysql.PrivilegeType {
+	switch level {
+	case ast.GrantLevelGlobal:
+		return mysql.AllGlobalPrivs
+	case ast.GrantLevelDB:
+		return mysql.AllDBPrivs
+	}
+	return mysql.AllTablePrivs
+}
@@ -0,0 +1,199 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package privilege
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"strconv"
+)
+
+// The authentication_string of caching_sha2_password is "$A$" + rounds / 1000 in 3 digits + "$" +
+// salt + hash, where the hash is the SHA-256 crypt of the password, encoded by the crypt base64.
+// See https://github.com/mysql/mysql-server/blob/8.0/sql/auth/sha2_password.cc
+const (
+	sha2Prefix          = "$A$"
+	sha2RoundsDigits    = 3
+	sha2RoundsThousands = 5
+	sha2SaltLength      = 20
+	sha2HashLength      = 43
+	sha2AuthStringLen   = len(sha2Prefix) + sha2RoundsDigits + 1 + sha2SaltLength + sha2HashLength
+)
+
+// cryptBase64 is the alphabet of the crypt base64 encoding.
+const cryptBase64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// encodeSha2Password hashes the password with a random salt by caching_sha2_password.
+func encodeSha2Password(pwd string) string {
+	salt := newSha2Salt()
+	hash := sha256Crypt([]byte(pwd), salt, sha2RoundsThousands*1000)
+	return fmt.Sprintf("%s%03d$%s%s", sha2Prefix, sha2RoundsThousands, salt, hash)
+}
+
+// decodeSha2Password splits the authentication_string of caching_sha2_password.
+func decodeSha2Password(authString string) (rounds int, salt []byte, hash string, ok bool) {
+	if len(authString) != sha2AuthStringLen || authString[:len(sha2Prefix)] != sha2Prefix {
+		return 0, nil, "", false
+	}
+	pos := len(sha2Prefix)
+	n, err := strconv.Atoi(authString[pos : pos+sha2RoundsDigits])
+	if err != nil || n <= 0 {
+		return 0, nil, "", false
+	}
+	pos += sha2RoundsDigits
+	if authString[pos] != '$' {
+		return 0, nil, "", false
+	}
+	pos++
+	return n * 1000, []byte(authString[pos : pos+sha2SaltLength]), authString[pos+sha2SaltLength:], true
+}
+
+// checkSha2Password checks the password in clear text against the authentication_string of
+// caching_sha2_password.
+func checkSha2Password(pwd, authString string) bool {
+	rounds, salt, hash, ok := decodeSha2Password(authString)
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(sha256Crypt([]byte(pwd), salt, rounds)), []byte(hash)) == 1
+}
+
+// checkSha2Scramble checks the scramble sent by the fast authentication of caching_sha2_password, the
+// scramble is XOR(SHA256(password), SHA256(SHA256(SHA256(password)), nonce)), and the digest is
+// SHA256(SHA256(password)).
+func checkSha2Scramble(scramble, nonce, digest []byte) bool {
+	if len(scramble) != sha256.Size {
+		return false
+	}
+	h := sha256.New()
+	h.Write(digest)
+	h.Write(nonce)
+	stage1 := h.Sum(nil)
+	for i := range stage1 {
+		stage1[i] ^= scramble[i]
+	}
+	stage2 := sha256.Sum256(stage1)
+	return subtle.ConstantTimeCompare(stage2[:], digest) == 1
+}
+
+// newSha2Salt generates a random salt without '\0' and '$' as MySQL does.
+func newSha2Salt() []byte {
+	salt := make([]byte, sha2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	for i := range salt {
+		salt[i] &= 0x7f
+		if salt[i] == 0 || salt[i] == '$' {
+			salt[i]++
+		}
+	}
+	return salt
+}
+
+// sha256Crypt computes the hash of the SHA-256 crypt without the prefix and the salt.
+// See https://www.akkadia.org/drepper/SHA-crypt.txt
+func sha256Crypt(pwd, salt []byte, rounds int) string {
+	h := sha256.New()
+
+	// Digest B = SHA256(password + salt + password).
+	h.Write(pwd)
+	h.Write(salt)
+	h.Write(pwd)
+	b := h.Sum(nil)
+
+	// Digest A = SHA256(password + salt + B repeated to the length of password + B or password
+	// selected by the bits of the length of password).
+	h.Reset()
+	h.Write(pwd)
+	h.Write(salt)
+	n := len(pwd)
+	for ; n > sha256.Size; n -= sha256.Size {
+		h.Write(b)
+	}
+	h.Write(b[:n])
+	for n = len(pwd); n > 0; n >>= 1 {
+		if n&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(pwd)
+		}
+	}
+	a := h.Sum(nil)
+
+	// Sequence P is derived from the password, and sequence S is derived from the salt.
+	h.Reset()
+	for i := 0; i < len(pwd); i++ {
+		h.Write(pwd)
+	}
+	p := repeatBytes(h.Sum(nil), len(pwd))
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(salt)
+	}
+	s := repeatBytes(h.Sum(nil), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(a)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(a)
+		} else {
+			h.Write(p)
+		}
+		a = h.Sum(a[:0])
+	}
+
+	// The bytes of the digest are encoded in groups of 3 bytes, in the order of the specification.
+	buf := make([]byte, 0, sha2HashLength)
+	for i := 0; i < 10; i++ {
+		x, y, z := (i*21)%30, (i*21+10)%30, (i*21+20)%30
+		buf = appendCryptBase64(buf, uint(a[x])<<16|uint(a[y])<<8|uint(a[z]), 4)
+	}
+	return string(appendCryptBase64(buf, uint(a[31])<<8|uint(a[30]), 3))
+}
+
+// repeatBytes repeats the digest to the length.
+func repeatBytes(digest []byte, length int) []byte {
+	buf := make([]byte, 0, length)
+	for len(buf) < length {
+		n := length - len(buf)
+		if n > len(digest) {
+			n = len(digest)
+		}
+		buf = append(buf, digest[:n]...)
+	}
+	return buf
+}
+
+// appendCryptBase64 encodes the lowest 6*n bits of v, the least significant bits first.
+func appendCryptBase64(buf []byte, v uint, n int) []byte {
+	for ; n > 0; n-- {
+		buf = append(buf, cryptBase64[v&0x3f])
+		v >>= 6
+	}
+	return buf
+}
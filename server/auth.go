@@ -0,0 +1,198 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/privilege"
+)
+
+// The packets of the authentication exchange after the handshake response.
+// See https://dev.mysql.com/doc/internals/en/connection-phase-packets.html
+const (
+	authSwitchRequest byte = 0xfe
+	authMoreData      byte = 0x01
+)
+
+// The messages of caching_sha2_password in AuthMoreData, and the request of the client for the
+// public key of the server.
+// See https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+const (
+	sha2RequestPublicKey byte = 0x02
+	sha2FastAuthSuccess  byte = 0x03
+	sha2PerformFullAuth  byte = 0x04
+)
+
+// rsaKeyBits is the size of the RSA key pair used by the full authentication of caching_sha2_password
+// over the connections without TLS.
+const rsaKeyBits = 2048
+
+// newRSAKey generates the RSA key pair of the server, the public key is encoded in PEM as it's sent
+// to the clients.
+func newRSAKey() (*rsa.PrivateKey, []byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// openSessionAndDoAuth opens the session and authenticates the user by the auth plugin of the account.
+// The client is asked to switch to the plugin of the account if it starts with another plugin.
+func (cc *clientConn) openSessionAndDoAuth(resp *handshakeResponse41) error {
+	var tlsStatePtr *tls.ConnectionState
+	if cc.tlsConn != nil {
+		tlsState := cc.tlsConn.ConnectionState()
+		tlsStatePtr = &tlsState
+	}
+	var err error
+	cc.ctx, err = cc.server.driver.OpenCtx(uint64(cc.connectionID), cc.capability, cc.collation, cc.dbname, tlsStatePtr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	user := &auth.UserIdentity{Username: cc.user, Hostname: cc.peerHost}
+	authData, plugin := resp.Auth, resp.AuthPlugin
+	if accountPlugin := cc.ctx.AuthPlugin(user); accountPlugin != "" && accountPlugin != plugin {
+		if cc.capability&mysql.ClientPluginAuth == 0 {
+			log.Warnf("con:%d client can't switch to auth plugin %s", cc.connectionID, accountPlugin)
+			return cc.accessDenied(authData)
+		}
+		if authData, err = cc.switchAuthPlugin(accountPlugin); err != nil {
+			return errors.Trace(err)
+		}
+		plugin = accountPlugin
+	}
+
+	if plugin == privilege.AuthCachingSha2Password {
+		return errors.Trace(cc.authSha2Password(user, authData))
+	}
+	if !cc.ctx.Auth(user, authData, cc.salt) {
+		return cc.accessDenied(authData)
+	}
+	return nil
+}
+
+// switchAuthPlugin sends AuthSwitchRequest with the salt, and reads the auth data of the plugin.
+func (cc *clientConn) switchAuthPlugin(plugin string) ([]byte, error) {
+	data := make([]byte, 4, 4+1+len(plugin)+1+len(cc.salt)+1)
+	data = append(data, authSwitchRequest)
+	data = append(data, plugin...)
+	data = append(data, 0)
+	data = append(data, cc.salt...)
+	data = append(data, 0)
+	if err := cc.writePacket(data); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := cc.flush(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	authData, err := cc.readPacket()
+	return authData, errors.Trace(err)
+}
+
+// authSha2Password authenticates the user by caching_sha2_password. The scramble passes the fast
+// authentication if the password of the user is cached, otherwise the client is asked to send the
+// password, in clear text over TLS, or encrypted by the public key of the server.
+func (cc *clientConn) authSha2Password(user *auth.UserIdentity, authData []byte) error {
+	// The client sends a single '\0' for an empty password.
+	if len(authData) == 0 || (len(authData) == 1 && authData[0] == 0) {
+		if !cc.ctx.Auth(user, nil, cc.salt) {
+			return cc.accessDenied(nil)
+		}
+		return nil
+	}
+	if cc.ctx.Auth(user, authData, cc.salt) {
+		return errors.Trace(cc.writeAuthMoreData([]byte{sha2FastAuthSuccess}))
+	}
+
+	if err := cc.writeAuthMoreData([]byte{sha2PerformFullAuth}); err != nil {
+		return errors.Trace(err)
+	}
+	pwd, err := cc.readSha2Password()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if !cc.ctx.AuthWithPassword(user, pwd) {
+		return cc.accessDenied(authData)
+	}
+	return nil
+}
+
+// readSha2Password reads the password of the full authentication of caching_sha2_password. The
+// password is in clear text over TLS, otherwise it's XORed with the salt and encrypted by the public
+// key of the server, which is sent to the client if it asks for it.
+func (cc *clientConn) readSha2Password() (string, error) {
+	data, err := cc.readPacket()
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if cc.tlsConn != nil {
+		return string(bytes.TrimRight(data, "\x00")), nil
+	}
+	if len(data) == 1 && data[0] == sha2RequestPublicKey {
+		if err = cc.writeAuthMoreData(cc.server.rsaPublicKey); err != nil {
+			return "", errors.Trace(err)
+		}
+		if data, err = cc.readPacket(); err != nil {
+			return "", errors.Trace(err)
+		}
+	}
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, cc.server.rsaKey, data, nil)
+	if err != nil {
+		log.Warnf("con:%d decrypt password error %v", cc.connectionID, err)
+		return "", cc.accessDenied(data)
+	}
+	for i := range plain {
+		plain[i] ^= cc.salt[i%len(cc.salt)]
+	}
+	return string(bytes.TrimRight(plain, "\x00")), nil
+}
+
+// writeAuthMoreData sends AuthMoreData with the data.
+func (cc *clientConn) writeAuthMoreData(moreData []byte) error {
+	data := make([]byte, 4, 4+1+len(moreData))
+	data = append(data, authMoreData)
+	data = append(data, moreData...)
+	if err := cc.writePacket(data); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cc.flush())
+}
+
+// accessDenied returns the access denied error of the user, the loopback address is reported as
+// localhost as MySQL does.
+func (cc *clientConn) accessDenied(authData []byte) error {
+	authHost := cc.peerHost
+	if authHost == "127.0.0.1" || authHost == "::1" {
+		authHost = "localhost"
+	}
+	return errors.Trace(errAccessDenied.GenWithStackByArgs(cc.user, authHost, hasPassword(authData)))
+}
@@ -18,6 +18,7 @@ package server
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/binary"
 	"fmt"
 	"github.com/opentracing/opentracing-go"
@@ -28,12 +29,17 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/terror"
 	log "github.com/sirupsen/logrus"
 
+	"fedb/kv"
+	"fedb/parser"
+	"fedb/privilege"
 	"fedb/util"
 	"fedb/util/arena"
 	"fedb/util/hack"
@@ -58,14 +64,16 @@ func newClientConn(s *Server) *clientConn {
 }
 
 type clientConn struct {
-	pkt  *packetIO // a helper to read and write data in packet format.
-	conn net.Conn  // net.Conn
-	//tlsConn      *tls.Conn         // TLS connection, nil if not TLS.
+	pkt          *packetIO         // a helper to read and write data in packet format.
+	conn         net.Conn          // net.Conn
+	tlsConn      *tls.Conn         // TLS connection, nil if not TLS.
 	server       *Server           // a reference of server instance.
 	capability   uint32            // client capability affects the way server handles client request.
 	connectionID uint32            // atomically allocated by a global variable, unique in process scope.
 	collation    uint8             // collation used by client, may be different from the collation used by database.
 	user         string            // user of the client.
+	peerHost     string            // host of the client.
+	peerPort     string            // port of the client.
 	dbname       string            // default database name.
 	salt         []byte            // random bytes used for authentication.
 	alloc        arena.Allocator   // an memory allocator for reducing memory allocation.
@@ -91,6 +99,18 @@ func (cc *clientConn) setConn(conn net.Conn) {
 	}
 }
 
+// upgradeToTLS performs the TLS handshake on the connection after SSLRequest, the following packets
+// are read and written over TLS.
+func (cc *clientConn) upgradeToTLS(tlsConfig *tls.Config) error {
+	tlsConn := tls.Server(cc.conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return errors.Trace(err)
+	}
+	cc.setConn(tlsConn)
+	cc.tlsConn = tlsConn
+	return nil
+}
+
 func (cc *clientConn) readPacket() ([]byte, error) {
 	return cc.pkt.readPacket()
 }
@@ -103,7 +123,7 @@ func (cc *clientConn) Run() {
 			buf := make([]byte, 4096)
 			stackSize := runtime.Stack(buf, false)
 			buf = buf[:stackSize]
-			log.Errorf("lastCmd %s, %v, %s", cc.lastCmd, r, buf)
+			log.Errorf("lastCmd %s, %v, %s", sqlStrForLog(cc.lastCmd), r, buf)
 		}
 		if !closedOutside {
 			err := cc.Close()
@@ -154,7 +174,7 @@ func (cc *clientConn) Run() {
 				return
 			}
 			log.Warnf("[%d] dispatch error:\n%s\n%q\n%s",
-				cc.connectionID, cc, queryStrForLog(string(data[1:])), errStrForLog(err))
+				cc.connectionID, cc, cmdStrForLog(data), errStrForLog(err))
 			err1 := cc.writeError(err)
 			terror.Log(errors.Trace(err1))
 		}
@@ -183,9 +203,13 @@ func (cc *clientConn) handshake() error {
 		data = append(data, 0, 0)
 	}
 
-	cc.ctx, err = cc.server.driver.OpenCtx(uint64(cc.connectionID), cc.capability, cc.collation, cc.dbname, nil)
-	if err != nil {
-		return errors.Trace(err)
+	cc.ctx.SetSessionManager(cc.server)
+	cc.ctx.SetProcessInfo("", time.Now(), mysql.ComSleep)
+	if cc.dbname != "" {
+		if err = cc.useDB(goctx.Background(), cc.dbname); err != nil {
+			terror.Log(errors.Trace(cc.writeError(err)))
+			return errors.Trace(err)
+		}
 	}
 
 	err = cc.writePacket(data)
@@ -233,7 +257,7 @@ func (cc *clientConn) writeInitialHandshake() error {
 	data = append(data, cc.salt[8:]...)
 	data = append(data, 0)
 	// auth-plugin name
-	data = append(data, []byte("mysql_native_password")...)
+	data = append(data, []byte(cc.server.cfg.DefaultAuthPlugin)...)
 	data = append(data, 0)
 	err := cc.writePacket(data)
 	if err != nil {
@@ -248,6 +272,7 @@ type handshakeResponse41 struct {
 	User       string
 	DBName     string
 	Auth       []byte
+	AuthPlugin string
 	Attrs      map[string]string
 }
 
@@ -318,9 +343,16 @@ func parseHandshakeResponseBody(packet *handshakeResponse41, data []byte, offset
 		}
 	}
 
+	// The clients without ClientPluginAuth only support mysql_native_password.
+	packet.AuthPlugin = privilege.AuthNativePassword
 	if packet.Capability&mysql.ClientPluginAuth > 0 {
-		// TODO: Support mysql.ClientPluginAuth, skip it now
 		idx := bytes.IndexByte(data[offset:], 0)
+		if idx < 0 {
+			idx = len(data[offset:])
+		}
+		if idx > 0 {
+			packet.AuthPlugin = string(data[offset : offset+idx])
+		}
 		offset = offset + idx + 1
 	}
 
@@ -378,21 +410,21 @@ func (cc *clientConn) readOptionalSSLRequestAndHandshakeResponse() error {
 		return errors.Trace(err)
 	}
 
-	// if (resp.Capability&mysql.ClientSSL > 0) && cc.server.tlsConfig != nil {
-	// 	// The packet is a SSLRequest, let's switch to TLS.
-	// 	if err = cc.upgradeToTLS(cc.server.tlsConfig); err != nil {
-	// 		return errors.Trace(err)
-	// 	}
-	// 	// Read the following HandshakeResponse packet.
-	// 	data, err = cc.readPacket()
-	// 	if err != nil {
-	// 		return errors.Trace(err)
-	// 	}
-	// 	pos, err = parseHandshakeResponseHeader(&resp, data)
-	// 	if err != nil {
-	// 		return errors.Trace(err)
-	// 	}
-	// }
+	if (resp.Capability&mysql.ClientSSL > 0) && cc.server.tlsConfig != nil {
+		// The packet is a SSLRequest, let's switch to TLS.
+		if err = cc.upgradeToTLS(cc.server.tlsConfig); err != nil {
+			return errors.Trace(err)
+		}
+		// Read the following HandshakeResponse packet.
+		data, err = cc.readPacket()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		pos, err = parseHandshakeResponseHeader(&resp, data)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
 
 	// Read the remaining part of the packet.
 	if err = parseHandshakeResponseBody(&resp, data, pos); err != nil {
@@ -405,38 +437,15 @@ func (cc *clientConn) readOptionalSSLRequestAndHandshakeResponse() error {
 	cc.collation = resp.Collation
 	cc.attrs = resp.Attrs
 
-	// Open session and do auth.
-	// var tlsStatePtr *tls.ConnectionState
-	// if cc.tlsConn != nil {
-	// 	tlsState := cc.tlsConn.ConnectionState()
-	// 	tlsStatePtr = &tlsState
-	// }
-	// cc.ctx, err = cc.server.driver.OpenCtx(uint64(cc.connectionID), cc.capability, cc.collation, cc.dbname, nil)
-	// if err != nil {
-	// 	return errors.Trace(err)
-	// }
-	// if !cc.server.skipAuth() {
-	// 	// Do Auth.
-	// 	addr := cc.bufReadConn.RemoteAddr().String()
-	// 	host, _, err1 := net.SplitHostPort(addr)
-	// 	if err1 != nil {
-	// 		return errors.Trace(errAccessDenied.GenByArgs(cc.user, addr, "YES"))
-	// 	}
-	// 	if !cc.ctx.Auth(&auth.UserIdentity{Username: cc.user, Hostname: host}, resp.Auth, cc.salt) {
-	// 		return errors.Trace(errAccessDenied.GenByArgs(cc.user, host, "YES"))
-	// 	}
-	// }
-	// if cc.dbname != "" {
-	// 	err = cc.useDB(goctx.Background(), cc.dbname)
-	// 	if err != nil {
-	// 		return errors.Trace(err)
-	// 	}
-	// }
-	// cc.ctx.SetSessionManager(cc.server)
-	// if cc.server.cfg.EnableChunk {
-	// 	cc.ctx.EnableChunk()
-	// }
-	return nil
+	return errors.Trace(cc.openSessionAndDoAuth(&resp))
+}
+
+// hasPassword returns the "using password" part of the access denied error.
+func hasPassword(authData []byte) string {
+	if len(authData) == 0 {
+		return "NO"
+	}
+	return "YES"
 }
 
 // dispatch handles client request based on command which is the first byte of the data.
@@ -451,16 +460,27 @@ func (cc *clientConn) dispatch(data []byte) error {
 	cc.mu.cancelFunc = cancelFunc
 	cc.mu.Unlock()
 
+	t := time.Now()
 	cmd := data[0]
 	data = data[1:]
 	cc.lastCmd = hack.String(data)
 	//token := cc.server.getToken()
 	defer func() {
+		cc.ctx.SetProcessInfo("", time.Now(), mysql.ComSleep)
 		//cc.server.releaseToken(token)
 		span.Finish()
 	}()
 
-	log.Infof("cmd:0x%x, %v", cmd, data)
+	switch cmd {
+	case mysql.ComPing:
+		cc.ctx.SetProcessInfo("", t, cmd)
+	case mysql.ComInitDB:
+		cc.ctx.SetProcessInfo("use "+hack.String(data), t, cmd)
+	case mysql.ComStmtPrepare:
+		cc.ctx.SetProcessInfo(hack.String(data), t, cmd)
+	}
+
+	log.Debugf("cmd:0x%x", cmd)
 
 	switch cmd {
 	case mysql.ComSleep:
@@ -486,18 +506,20 @@ func (cc *clientConn) dispatch(data []byte) error {
 			return errors.Trace(err)
 		}
 		return cc.writeOK()
-	// case mysql.ComFieldList:
-	// 	return cc.handleFieldList(hack.String(data))
-	// case mysql.ComStmtPrepare:
-	// 	return cc.handleStmtPrepare(hack.String(data))
-	// case mysql.ComStmtExecute:
-	// 	return cc.handleStmtExecute(goCtx1, data)
-	// case mysql.ComStmtClose:
-	// 	return cc.handleStmtClose(data)
-	// case mysql.ComStmtSendLongData:
-	// 	return cc.handleStmtSendLongData(data)
-	// case mysql.ComStmtReset:
-	// 	return cc.handleStmtReset(data)
+	case mysql.ComFieldList:
+		return cc.handleFieldList(hack.String(data))
+	case mysql.ComStmtPrepare:
+		return cc.handleStmtPrepare(hack.String(data))
+	case mysql.ComStmtExecute:
+		return cc.handleStmtExecute(goCtx1, data)
+	case mysql.ComStmtFetch:
+		return cc.handleStmtFetch(goCtx1, data)
+	case mysql.ComStmtClose:
+		return cc.handleStmtClose(data)
+	case mysql.ComStmtSendLongData:
+		return cc.handleStmtSendLongData(data)
+	case mysql.ComStmtReset:
+		return cc.handleStmtReset(data)
 	// case mysql.ComSetOption:
 	// 	return cc.handleSetOption(data)
 	default:
@@ -505,18 +527,46 @@ func (cc *clientConn) dispatch(data []byte) error {
 	}
 }
 
+// handleFieldList returns the columns of a table of the current database, the wildcard is not
+// supported.
+func (cc *clientConn) handleFieldList(sql string) (err error) {
+	parts := strings.Split(sql, "\x00")
+	columns, err := cc.ctx.FieldList(parts[0])
+	if err != nil {
+		return errors.Trace(err)
+	}
+	data := make([]byte, 4, 1024)
+	for _, column := range columns {
+		// The default values are not sent, but the length byte is kept to make the mariadb client happy.
+		// https://dev.mysql.com/doc/internals/en/com-query-response.html#column-definition
+		column.DefaultValueLength = 0
+		column.DefaultValue = []byte{}
+
+		data = data[0:4]
+		data = column.Dump(data)
+		if err := cc.writePacket(data); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := cc.writeEOF(0); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cc.flush())
+}
+
 func (cc *clientConn) writeOK() error {
+	return cc.writeOKWithStatus(0)
+}
+
+// writeOKWithStatus writes an OK packet with the server status flags added to the status of the session.
+func (cc *clientConn) writeOKWithStatus(serverStatus uint16) error {
 	data := cc.alloc.AllocWithLen(4, 32)
 	data = append(data, mysql.OKHeader)
-	//TODO data = dumpLengthEncodedInt(data, cc.ctx.AffectedRows())
-	data = dumpLengthEncodedInt(data, 0)
-	//TODO data = dumpLengthEncodedInt(data, cc.ctx.LastInsertID())
-	data = dumpLengthEncodedInt(data, 0)
+	data = dumpLengthEncodedInt(data, cc.ctx.AffectedRows())
+	data = dumpLengthEncodedInt(data, cc.ctx.LastInsertID())
 	if cc.capability&mysql.ClientProtocol41 > 0 {
-		//TODO data = dumpUint16(data, cc.ctx.Status())
-		data = dumpUint16(data, mysql.ServerStatusAutocommit)
-		//TODO data = dumpUint16(data, cc.ctx.WarningCount())
-		data = dumpUint16(data, 0)
+		data = dumpUint16(data, cc.ctx.Status()|serverStatus)
+		data = dumpUint16(data, cc.ctx.WarningCount())
 	}
 
 	err := cc.writePacket(data)
@@ -527,6 +577,26 @@ func (cc *clientConn) writeOK() error {
 	return errors.Trace(cc.flush())
 }
 
+// writeEOF writes an EOF packet.
+// Note this function won't flush the stream because maybe there are more
+// packets following it.
+// serverStatus, a flag bit represents server information
+// in the packet.
+func (cc *clientConn) writeEOF(serverStatus uint16) error {
+	data := cc.alloc.AllocWithLen(4, 9)
+
+	data = append(data, mysql.EOFHeader)
+	if cc.capability&mysql.ClientProtocol41 > 0 {
+		data = dumpUint16(data, cc.ctx.WarningCount())
+		status := cc.ctx.Status()
+		status |= serverStatus
+		data = dumpUint16(data, status)
+	}
+
+	err := cc.writePacket(data)
+	return errors.Trace(err)
+}
+
 func (cc *clientConn) writeError(e error) error {
 	var (
 		m  *mysql.SQLError
@@ -536,7 +606,7 @@ func (cc *clientConn) writeError(e error) error {
 	originErr := errors.Cause(e)
 	if te, ok = originErr.(*terror.Error); ok {
 		m = te.ToSQLError()
-	} else {
+	} else if m, ok = originErr.(*mysql.SQLError); !ok {
 		m = mysql.NewErrf(mysql.ErrUnknown, "%s", e.Error())
 	}
 
@@ -580,8 +650,12 @@ func (cc *clientConn) Close() error {
 
 func (cc *clientConn) String() string {
 	collationStr := mysql.Collations[cc.collation]
+	var status uint16
+	if cc.ctx != nil {
+		status = cc.ctx.Status()
+	}
 	return fmt.Sprintf("id:%d, addr:%s status:%d, collation:%s, user:%s",
-		cc.connectionID, cc.conn.RemoteAddr(), cc.status /*cc.ctx.Status()*/, collationStr, cc.user,
+		cc.connectionID, cc.conn.RemoteAddr(), status, collationStr, cc.user,
 	)
 }
 
@@ -593,21 +667,154 @@ func queryStrForLog(query string) string {
 	return query
 }
 
+// cmdStrForLog returns the text of the command to log, the queries are logged by sqlStrForLog.
+func cmdStrForLog(data []byte) string {
+	switch data[0] {
+	case mysql.ComQuery, mysql.ComStmtPrepare:
+		return sqlStrForLog(string(data[1:]))
+	}
+	return queryStrForLog(string(data[1:]))
+}
+
+// sqlStrForLog returns the text of the query to log. The statements are logged by their
+// secure texts to hide the passwords, and the query is left out if it can't be parsed.
+func sqlStrForLog(query string) string {
+	stmts, err := parser.New().Parse(query, "", "")
+	if err != nil {
+		return fmt.Sprintf("(unparsed query, len: %d)", len(query))
+	}
+	texts := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		if sensitive, ok := stmt.(ast.SensitiveStmtNode); ok {
+			texts = append(texts, sensitive.SecureText())
+		} else {
+			texts = append(texts, strings.TrimSuffix(stmt.Text(), ";"))
+		}
+	}
+	return queryStrForLog(strings.Join(texts, "; "))
+}
+
 func errStrForLog(err error) string {
-	//TODO do not log stack for duplicated entry error.
+	if kv.ErrKeyExists.Equal(err) {
+		// Do not log stack for duplicated entry error.
+		return err.Error()
+	}
 	return errors.ErrorStack(err)
 }
 
 func (cc *clientConn) useDB(goCtx goctx.Context, db string) (err error) {
-	//TODO change DB
+	// if input is "use `SELECT`", mysql client just send "SELECT"
+	// so we add `` around db.
+	_, err = cc.ctx.Execute(goCtx, "use `"+strings.Replace(db, "`", "``", -1)+"`")
+	if err != nil {
+		return errors.Trace(err)
+	}
 	cc.dbname = db
-	return nil
+	return
 }
 
+// handleQuery executes the statements of the sql query one by one and writes their result sets
+// or result oks to the client. A statement is executed after the result set of the previous one
+// is written, as it may commit the transaction the result set reads.
 func (cc *clientConn) handleQuery(goCtx goctx.Context, sql string) (err error) {
-	_, err = cc.ctx.Execute(goCtx, sql)
-	//TODO
+	stmts, err := cc.ctx.Parse(goCtx, sql)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if len(stmts) == 0 {
+		return errors.Trace(cc.writeOK())
+	}
+	for i, stmt := range stmts {
+		var serverStatus uint16
+		if i < len(stmts)-1 {
+			serverStatus = mysql.ServerMoreResultsExists
+		}
+		rs, err := cc.ctx.ExecuteStmt(goCtx, stmt)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if rs != nil {
+			err = cc.writeResultset(goCtx, rs, false, serverStatus)
+		} else {
+			err = cc.writeOKWithStatus(serverStatus)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
 
-	err = cc.writeOK()
-	return errors.Trace(err)
+// writeResultset writes data into a resultset and uses rs.Next to get row data back.
+// If binary is true, the data would be encoded in BINARY format.
+// serverStatus, a flag bit represents server information.
+func (cc *clientConn) writeResultset(goCtx goctx.Context, rs ResultSet, binary bool, serverStatus uint16) error {
+	if err := cc.writeColumnInfo(rs.Columns(), serverStatus); err != nil {
+		terror.Call(rs.Close)
+		return errors.Trace(err)
+	}
+	if err := cc.writeRows(goCtx, rs, binary); err != nil {
+		terror.Call(rs.Close)
+		return errors.Trace(err)
+	}
+	// Closing the result set finishes the statement, the error of committing its
+	// transaction is sent to the client instead of the EOF packet.
+	if err := rs.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := cc.writeEOF(serverStatus); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cc.flush())
+}
+
+func (cc *clientConn) writeColumnInfo(columns []*ColumnInfo, serverStatus uint16) error {
+	data := make([]byte, 4, 1024)
+	data = dumpLengthEncodedInt(data, uint64(len(columns)))
+	if err := cc.writePacket(data); err != nil {
+		return errors.Trace(err)
+	}
+	for _, v := range columns {
+		data = data[0:4]
+		data = v.Dump(data)
+		if err := cc.writePacket(data); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if err := cc.writeEOF(serverStatus); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// writeRows writes every row of the batches fetched from the ResultSet in text or binary format.
+func (cc *clientConn) writeRows(goCtx goctx.Context, rs ResultSet, binary bool) error {
+	data := make([]byte, 4, 1024)
+	columns := rs.Columns()
+	req := rs.NewRecordBatch()
+	for {
+		err := rs.Next(goCtx, req)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		rowCount := req.NumRows()
+		if rowCount == 0 {
+			break
+		}
+		for i := 0; i < rowCount; i++ {
+			data = data[0:4]
+			if binary {
+				data, err = dumpBinaryRow(data, columns, req.GetRow(i))
+			} else {
+				data, err = dumpTextRow(data, columns, req.GetRow(i))
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if err = cc.writePacket(data); err != nil {
+				return errors.Trace(err)
+			}
+		}
+	}
+	return nil
 }
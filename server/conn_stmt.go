@@ -0,0 +1,595 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/server/conn_stmt.go
+//
+
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/util/chunk"
+)
+
+// The cursor types of COM_STMT_EXECUTE, only the forward-only, read-only cursor is supported.
+// See https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+const (
+	cursorTypeNoCursor = 0x00
+	cursorTypeReadOnly = 0x01
+)
+
+// maxFetchSize is the max number of rows sent by a COM_STMT_FETCH.
+const maxFetchSize = 1024
+
+func errUnknownStmtHandler(stmtID int, command string) error {
+	return mysql.NewErrf(mysql.ErrUnknownStmtHandler, "Unknown prepared statement handler (%d) given to %s", stmtID, command)
+}
+
+func (cc *clientConn) handleStmtPrepare(sql string) error {
+	stmt, columns, params, err := cc.ctx.Prepare(sql)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	data := make([]byte, 4, 128)
+
+	//status ok
+	data = append(data, 0)
+	//stmt id
+	data = dumpUint32(data, uint32(stmt.ID()))
+	//number columns
+	data = dumpUint16(data, uint16(len(columns)))
+	//number params
+	data = dumpUint16(data, uint16(len(params)))
+	//filter [00]
+	data = append(data, 0)
+	//warning count
+	data = dumpUint16(data, cc.ctx.WarningCount())
+
+	if err := cc.writePacket(data); err != nil {
+		return errors.Trace(err)
+	}
+
+	if len(params) > 0 {
+		for i := 0; i < len(params); i++ {
+			data = data[0:4]
+			data = params[i].Dump(data)
+
+			if err := cc.writePacket(data); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		if err := cc.writeEOF(0); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	if len(columns) > 0 {
+		for i := 0; i < len(columns); i++ {
+			data = data[0:4]
+			data = columns[i].Dump(data)
+
+			if err := cc.writePacket(data); err != nil {
+				return errors.Trace(err)
+			}
+		}
+
+		if err := cc.writeEOF(0); err != nil {
+			return errors.Trace(err)
+		}
+
+	}
+	return errors.Trace(cc.flush())
+}
+
+func (cc *clientConn) handleStmtExecute(goCtx goctx.Context, data []byte) (err error) {
+	if len(data) < 9 {
+		return mysql.ErrMalformPacket
+	}
+	pos := 0
+	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	pos += 4
+
+	stmt := cc.ctx.GetStatement(int(stmtID))
+	if stmt == nil {
+		return errUnknownStmtHandler(int(stmtID), "mysqld_stmt_execute")
+	}
+
+	flag := data[pos]
+	pos++
+	// The client indicates that it wants to use cursor by setting this flag.
+	// 0x00 CURSOR_TYPE_NO_CURSOR
+	// 0x01 CURSOR_TYPE_READ_ONLY
+	// 0x02 CURSOR_TYPE_FOR_UPDATE
+	// 0x04 CURSOR_TYPE_SCROLLABLE
+	var useCursor bool
+	switch flag {
+	case cursorTypeNoCursor:
+	case cursorTypeReadOnly:
+		useCursor = true
+	default:
+		return mysql.NewErrf(mysql.ErrUnknown, "unsupported flag %d", flag)
+	}
+
+	// skip iteration-count, always 1
+	pos += 4
+
+	var (
+		nullBitmaps []byte
+		paramTypes  []byte
+		paramValues []byte
+	)
+	numParams := stmt.NumParams()
+	args := make([]interface{}, numParams)
+	if numParams > 0 {
+		nullBitmapLen := (numParams + 7) >> 3
+		if len(data) < (pos + nullBitmapLen + 1) {
+			return mysql.ErrMalformPacket
+		}
+		nullBitmaps = data[pos : pos+nullBitmapLen]
+		pos += nullBitmapLen
+
+		// new param bound flag
+		if data[pos] == 1 {
+			pos++
+			if len(data) < (pos + (numParams << 1)) {
+				return mysql.ErrMalformPacket
+			}
+
+			paramTypes = data[pos : pos+(numParams<<1)]
+			pos += numParams << 1
+			paramValues = data[pos:]
+			// Just the first StmtExecute packet contain parameters type,
+			// we need save it for further use.
+			stmt.SetParamsType(append([]byte(nil), paramTypes...))
+		} else {
+			paramValues = data[pos+1:]
+		}
+
+		err = parseStmtArgs(args, stmt.BoundParams(), nullBitmaps, stmt.GetParamsType(), paramValues)
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	// The long data is used by one execution only, and the cursor of the last execution is
+	// closed before the statement is executed again.
+	stmt.Reset()
+	rs, err := stmt.Execute(goCtx, args...)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if rs == nil {
+		return errors.Trace(cc.writeOK())
+	}
+
+	// if the client wants to use cursor
+	// we should hold the ResultSet in PreparedStatement for next stmt_fetch, and only send back ColumnInfo.
+	// Tell the client cursor exists in server by setting proper serverStatus.
+	if useCursor {
+		stmt.StoreResultSet(rs)
+		err = cc.writeColumnInfo(rs.Columns(), mysql.ServerStatusCursorExists)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		// explicitly flush columnInfo to client.
+		return errors.Trace(cc.flush())
+	}
+	return errors.Trace(cc.writeResultset(goCtx, rs, true, 0))
+}
+
+func (cc *clientConn) handleStmtFetch(goCtx goctx.Context, data []byte) (err error) {
+	stmtID, fetchSize, err := parseStmtFetchCmd(data)
+	if err != nil {
+		return err
+	}
+
+	stmt := cc.ctx.GetStatement(int(stmtID))
+	if stmt == nil {
+		return errUnknownStmtHandler(int(stmtID), "mysqld_stmt_fetch")
+	}
+	sql := ""
+	if prepared, ok := stmt.(*FeDBStatement); ok {
+		sql = prepared.sql
+	}
+	cc.ctx.SetProcessInfo(sql, time.Now(), mysql.ComStmtFetch)
+	rs := stmt.GetResultSet()
+	if rs == nil {
+		return errUnknownStmtHandler(int(stmtID), "mysqld_stmt_fetch")
+	}
+
+	err = cc.writeFetchedRows(goCtx, rs, mysql.ServerStatusCursorExists, int(fetchSize))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(cc.flush())
+}
+
+// writeFetchedRows writes at most fetchSize rows of the cursor in binary format, followed by an
+// EOF packet. The rows read from the result set but not sent are kept for the next fetch, and the
+// result set is closed when all the rows are sent.
+func (cc *clientConn) writeFetchedRows(goCtx goctx.Context, rs ResultSet, serverStatus uint16, fetchSize int) error {
+	fetchedRows := rs.GetFetchedRows()
+	// if fetchedRows is not enough, getting data from recordSet.
+	for len(fetchedRows) < fetchSize {
+		req := rs.NewRecordBatch()
+		if err := rs.Next(goCtx, req); err != nil {
+			return errors.Trace(err)
+		}
+		rowCount := req.NumRows()
+		if rowCount == 0 {
+			break
+		}
+		for i := 0; i < rowCount; i++ {
+			fetchedRows = append(fetchedRows, req.GetRow(i))
+		}
+	}
+
+	// tell the client COM_STMT_FETCH has finished by setting proper serverStatus.
+	if len(fetchedRows) == 0 {
+		serverStatus |= mysql.ServerStatusLastRowSend
+		// The error of committing the transaction of the statement is sent to the client.
+		if err := rs.Close(); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(cc.writeEOF(serverStatus))
+	}
+
+	var curRows []chunk.Row
+	if fetchSize < len(fetchedRows) {
+		curRows = fetchedRows[:fetchSize]
+		fetchedRows = fetchedRows[fetchSize:]
+	} else {
+		curRows = fetchedRows
+		fetchedRows = nil
+	}
+	rs.StoreFetchedRows(fetchedRows)
+
+	data := make([]byte, 4, 1024)
+	columns := rs.Columns()
+	for _, row := range curRows {
+		var err error
+		data, err = dumpBinaryRow(data[0:4], columns, row)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = cc.writePacket(data); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(cc.writeEOF(serverStatus))
+}
+
+func parseStmtFetchCmd(data []byte) (uint32, uint32, error) {
+	if len(data) != 8 {
+		return 0, 0, mysql.ErrMalformPacket
+	}
+	// Please refer to https://dev.mysql.com/doc/internals/en/com-stmt-fetch.html
+	stmtID := binary.LittleEndian.Uint32(data[0:4])
+	fetchSize := binary.LittleEndian.Uint32(data[4:8])
+	if fetchSize > maxFetchSize {
+		fetchSize = maxFetchSize
+	}
+	return stmtID, fetchSize, nil
+}
+
+func parseStmtArgs(args []interface{}, boundParams [][]byte, nullBitmap, paramTypes, paramValues []byte) (err error) {
+	pos := 0
+	var v []byte
+	var n int
+	var isNull bool
+
+	for i := 0; i < len(args); i++ {
+		// if params had received via ComStmtSendLongData, use them directly.
+		// ref https://dev.mysql.com/doc/internals/en/com-stmt-send-long-data.html
+		// see clientConn#handleStmtSendLongData
+		if boundParams[i] != nil {
+			args[i] = boundParams[i]
+			continue
+		}
+
+		// check nullBitMap to determine the NULL arguments.
+		// ref https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+		// notice: some client(e.g. mariadb) will set nullBitMap even if data had be sent via ComStmtSendLongData,
+		// so this check need place after boundParam's check.
+		if nullBitmap[i>>3]&(1<<(uint(i)%8)) > 0 {
+			args[i] = nil
+			continue
+		}
+
+		if (i<<1)+1 >= len(paramTypes) {
+			return mysql.ErrMalformPacket
+		}
+
+		tp := paramTypes[i<<1]
+		isUnsigned := (paramTypes[(i<<1)+1] & 0x80) > 0
+
+		switch tp {
+		case mysql.TypeNull:
+			args[i] = nil
+			continue
+
+		case mysql.TypeTiny:
+			if len(paramValues) < (pos + 1) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+
+			if isUnsigned {
+				args[i] = uint8(paramValues[pos])
+			} else {
+				args[i] = int8(paramValues[pos])
+			}
+
+			pos++
+			continue
+
+		case mysql.TypeShort, mysql.TypeYear:
+			if len(paramValues) < (pos + 2) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			valU16 := binary.LittleEndian.Uint16(paramValues[pos : pos+2])
+			if isUnsigned {
+				args[i] = valU16
+			} else {
+				args[i] = int16(valU16)
+			}
+			pos += 2
+			continue
+
+		case mysql.TypeInt24, mysql.TypeLong:
+			if len(paramValues) < (pos + 4) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			valU32 := binary.LittleEndian.Uint32(paramValues[pos : pos+4])
+			if isUnsigned {
+				args[i] = valU32
+			} else {
+				args[i] = int32(valU32)
+			}
+			pos += 4
+			continue
+
+		case mysql.TypeLonglong:
+			if len(paramValues) < (pos + 8) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			valU64 := binary.LittleEndian.Uint64(paramValues[pos : pos+8])
+			if isUnsigned {
+				args[i] = valU64
+			} else {
+				args[i] = int64(valU64)
+			}
+			pos += 8
+			continue
+
+		case mysql.TypeFloat:
+			if len(paramValues) < (pos + 4) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+
+			args[i] = math.Float32frombits(binary.LittleEndian.Uint32(paramValues[pos : pos+4]))
+			pos += 4
+			continue
+
+		case mysql.TypeDouble:
+			if len(paramValues) < (pos + 8) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+
+			args[i] = math.Float64frombits(binary.LittleEndian.Uint64(paramValues[pos : pos+8]))
+			pos += 8
+			continue
+
+		case mysql.TypeDate, mysql.TypeTimestamp, mysql.TypeDatetime:
+			if len(paramValues) < (pos + 1) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			// See https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+			// for more details.
+			length := uint8(paramValues[pos])
+			pos++
+			if len(paramValues) < pos+int(length) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			switch length {
+			case 0:
+				args[i] = types.ZeroDatetimeStr
+			case 4:
+				pos, args[i] = parseBinaryDate(pos, paramValues)
+			case 7:
+				pos, args[i] = parseBinaryDateTime(pos, paramValues)
+			case 11:
+				pos, args[i] = parseBinaryTimestamp(pos, paramValues)
+			default:
+				err = mysql.ErrMalformPacket
+				return
+			}
+			continue
+
+		case mysql.TypeDuration:
+			if len(paramValues) < (pos + 1) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			// See https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+			// for more details.
+			length := uint8(paramValues[pos])
+			pos++
+			if len(paramValues) < pos+int(length) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+			switch length {
+			case 0:
+				args[i] = "0"
+			case 8:
+				isNegative := uint8(paramValues[pos])
+				if isNegative > 1 {
+					err = mysql.ErrMalformPacket
+					return
+				}
+				pos++
+				pos, args[i] = parseBinaryDuration(pos, paramValues, isNegative)
+			case 12:
+				isNegative := uint8(paramValues[pos])
+				if isNegative > 1 {
+					err = mysql.ErrMalformPacket
+					return
+				}
+				pos++
+				pos, args[i] = parseBinaryDurationWithMS(pos, paramValues, isNegative)
+			default:
+				err = mysql.ErrMalformPacket
+				return
+			}
+			continue
+
+		case mysql.TypeUnspecified, mysql.TypeNewDecimal, mysql.TypeVarchar,
+			mysql.TypeBit, mysql.TypeEnum, mysql.TypeSet, mysql.TypeTinyBlob,
+			mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob,
+			mysql.TypeVarString, mysql.TypeString, mysql.TypeGeometry, mysql.TypeJSON:
+			if len(paramValues) < (pos + 1) {
+				err = mysql.ErrMalformPacket
+				return
+			}
+
+			v, isNull, n, err = parseLengthEncodedBytes(paramValues[pos:])
+			pos += n
+			if err != nil {
+				return
+			}
+
+			if !isNull {
+				args[i] = string(v)
+			} else {
+				args[i] = nil
+			}
+			continue
+		default:
+			err = errUnknownFieldType.GenWithStack("stmt unknown field type %d", tp)
+			return
+		}
+	}
+	return
+}
+
+func parseBinaryDate(pos int, paramValues []byte) (int, string) {
+	year := binary.LittleEndian.Uint16(paramValues[pos : pos+2])
+	pos += 2
+	month := uint8(paramValues[pos])
+	pos++
+	day := uint8(paramValues[pos])
+	pos++
+	return pos, fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+}
+
+func parseBinaryDateTime(pos int, paramValues []byte) (int, string) {
+	pos, date := parseBinaryDate(pos, paramValues)
+	hour := uint8(paramValues[pos])
+	pos++
+	minute := uint8(paramValues[pos])
+	pos++
+	second := uint8(paramValues[pos])
+	pos++
+	return pos, fmt.Sprintf("%s %02d:%02d:%02d", date, hour, minute, second)
+}
+
+func parseBinaryTimestamp(pos int, paramValues []byte) (int, string) {
+	pos, dateTime := parseBinaryDateTime(pos, paramValues)
+	microSecond := binary.LittleEndian.Uint32(paramValues[pos : pos+4])
+	pos += 4
+	return pos, fmt.Sprintf("%s.%06d", dateTime, microSecond)
+}
+
+func parseBinaryDuration(pos int, paramValues []byte, isNegative uint8) (int, string) {
+	sign := ""
+	if isNegative == 1 {
+		sign = "-"
+	}
+	days := binary.LittleEndian.Uint32(paramValues[pos : pos+4])
+	pos += 4
+	hours := uint8(paramValues[pos])
+	pos++
+	minutes := uint8(paramValues[pos])
+	pos++
+	seconds := uint8(paramValues[pos])
+	pos++
+	return pos, fmt.Sprintf("%s%d %02d:%02d:%02d", sign, days, hours, minutes, seconds)
+}
+
+func parseBinaryDurationWithMS(pos int, paramValues []byte,
+	isNegative uint8) (int, string) {
+	pos, dur := parseBinaryDuration(pos, paramValues, isNegative)
+	microSecond := binary.LittleEndian.Uint32(paramValues[pos : pos+4])
+	pos += 4
+	return pos, fmt.Sprintf("%s.%06d", dur, microSecond)
+}
+
+func (cc *clientConn) handleStmtClose(data []byte) (err error) {
+	if len(data) < 4 {
+		return
+	}
+
+	stmtID := int(binary.LittleEndian.Uint32(data[0:4]))
+	stmt := cc.ctx.GetStatement(stmtID)
+	if stmt != nil {
+		return errors.Trace(stmt.Close())
+	}
+	return
+}
+
+func (cc *clientConn) handleStmtSendLongData(data []byte) (err error) {
+	if len(data) < 6 {
+		return mysql.ErrMalformPacket
+	}
+
+	stmtID := int(binary.LittleEndian.Uint32(data[0:4]))
+
+	stmt := cc.ctx.GetStatement(stmtID)
+	if stmt == nil {
+		return errUnknownStmtHandler(stmtID, "mysqld_stmt_send_long_data")
+	}
+
+	paramID := int(binary.LittleEndian.Uint16(data[4:6]))
+	return stmt.AppendParam(paramID, data[6:])
+}
+
+func (cc *clientConn) handleStmtReset(data []byte) (err error) {
+	if len(data) < 4 {
+		return mysql.ErrMalformPacket
+	}
+
+	stmtID := int(binary.LittleEndian.Uint32(data[0:4]))
+	stmt := cc.ctx.GetStatement(stmtID)
+	if stmt == nil {
+		return errUnknownStmtHandler(stmtID, "mysqld_stmt_reset")
+	}
+	stmt.Reset()
+	return cc.writeOK()
+}
@@ -0,0 +1,196 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/util/chunk"
+)
+
+// binaryValueTest is a value sent in the binary protocol, the value dumped from datum by
+// dumpBinaryRow takes size bytes and is parsed back to arg by parseStmtArgs.
+type binaryValueTest struct {
+	name  string
+	tp    byte
+	flag  uint
+	datum types.Datum
+	size  int
+	arg   interface{}
+}
+
+func newBinaryTime(tp byte, t time.Time) types.Datum {
+	return types.NewTimeDatum(types.Time{Time: types.FromGoTime(t), Type: tp, Fsp: 6})
+}
+
+func newDecimal(s string) types.Datum {
+	dec := new(types.MyDecimal)
+	if err := dec.FromString([]byte(s)); err != nil {
+		panic(err)
+	}
+	return types.NewDecimalDatum(dec)
+}
+
+var binaryValueTests = []binaryValueTest{
+	{"tinyint", mysql.TypeTiny, 0, types.NewIntDatum(-128), 1, int8(-128)},
+	{"tinyint unsigned", mysql.TypeTiny, mysql.UnsignedFlag, types.NewUintDatum(255), 1, uint8(255)},
+	{"smallint", mysql.TypeShort, 0, types.NewIntDatum(math.MinInt16), 2, int16(math.MinInt16)},
+	{"smallint unsigned", mysql.TypeShort, mysql.UnsignedFlag, types.NewUintDatum(math.MaxUint16), 2, uint16(math.MaxUint16)},
+	{"year", mysql.TypeYear, 0, types.NewIntDatum(2155), 2, int16(2155)},
+	{"mediumint", mysql.TypeInt24, 0, types.NewIntDatum(-8388608), 4, int32(-8388608)},
+	{"int", mysql.TypeLong, 0, types.NewIntDatum(math.MinInt32), 4, int32(math.MinInt32)},
+	{"int unsigned", mysql.TypeLong, mysql.UnsignedFlag, types.NewUintDatum(math.MaxUint32), 4, uint32(math.MaxUint32)},
+	{"bigint", mysql.TypeLonglong, 0, types.NewIntDatum(math.MinInt64), 8, int64(math.MinInt64)},
+	{"bigint unsigned", mysql.TypeLonglong, mysql.UnsignedFlag, types.NewUintDatum(math.MaxUint64), 8, uint64(math.MaxUint64)},
+	{"float", mysql.TypeFloat, 0, types.NewFloat32Datum(-1.5), 4, float32(-1.5)},
+	{"double", mysql.TypeDouble, 0, types.NewFloat64Datum(math.MaxFloat64), 8, math.MaxFloat64},
+	{"decimal", mysql.TypeNewDecimal, 0, newDecimal("-12345678901234567890.123456"), 29, "-12345678901234567890.123456"},
+	{"decimal zero", mysql.TypeNewDecimal, 0, newDecimal("0.00"), 5, "0.00"},
+	{"varchar", mysql.TypeVarchar, 0, types.NewStringDatum("a'b\"c"), 6, "a'b\"c"},
+	{"varchar empty", mysql.TypeVarchar, 0, types.NewStringDatum(""), 1, ""},
+	{"blob", mysql.TypeBlob, 0, types.NewBytesDatum([]byte{0, 0xff, 0}), 4, "\x00\xff\x00"},
+	{"date", mysql.TypeDate, 0, newBinaryTime(mysql.TypeDate, time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)), 5, "2020-02-29"},
+	// DATETIME and TIMESTAMP are always sent with the microseconds in 11 bytes.
+	{"datetime", mysql.TypeDatetime, 0, newBinaryTime(mysql.TypeDatetime, time.Date(9999, 12, 31, 23, 59, 59, 999999000, time.UTC)), 12, "9999-12-31 23:59:59.999999"},
+	{"datetime second", mysql.TypeDatetime, 0, newBinaryTime(mysql.TypeDatetime, time.Date(1000, 1, 1, 0, 0, 1, 0, time.UTC)), 12, "1000-01-01 00:00:01.000000"},
+	{"timestamp", mysql.TypeTimestamp, 0, newBinaryTime(mysql.TypeTimestamp, time.Date(1970, 1, 1, 0, 0, 1, 1000, time.UTC)), 12, "1970-01-01 00:00:01.000001"},
+	{"datetime zero", mysql.TypeDatetime, 0, types.NewTimeDatum(types.ZeroDatetime), 1, types.ZeroDatetimeStr},
+	// TIME takes 8 bytes without the microseconds, 12 bytes with them and none if it is 0.
+	{"time", mysql.TypeDuration, 0, types.NewDurationDatum(types.Duration{Duration: 34*time.Hour + 5*time.Minute + 6*time.Second}), 9, "1 10:05:06"},
+	{"time negative", mysql.TypeDuration, 0, types.NewDurationDatum(types.Duration{Duration: -(838*time.Hour + 59*time.Minute + 59*time.Second)}), 9, "-34 22:59:59"},
+	{"time micro", mysql.TypeDuration, 0, types.NewDurationDatum(types.Duration{Duration: -(time.Second + 500*time.Microsecond), Fsp: 6}), 13, "-0 00:00:01.000500"},
+	{"time zero", mysql.TypeDuration, 0, types.NewDurationDatum(types.Duration{}), 1, "0"},
+}
+
+// dumpTestRow dumps the row of the datums in the binary protocol and returns the NULL bitmap
+// and the values of the row.
+func dumpTestRow(t *testing.T, tests []binaryValueTest, nulls []bool) ([]byte, []byte) {
+	columns := make([]*ColumnInfo, len(tests))
+	fields := make([]*types.FieldType, len(tests))
+	for i, tt := range tests {
+		columns[i] = &ColumnInfo{Name: tt.name, Type: tt.tp, Flag: uint16(tt.flag), Decimal: 6}
+		fields[i] = types.NewFieldType(tt.tp)
+		fields[i].Flag = tt.flag
+	}
+	chk := chunk.NewChunkWithCapacity(fields, 1)
+	for i, tt := range tests {
+		d := tt.datum
+		if nulls[i] {
+			d = types.Datum{}
+		}
+		chk.AppendDatum(i, &d)
+	}
+	data, err := dumpBinaryRow(nil, columns, chk.GetRow(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != mysql.OKHeader {
+		t.Fatalf("expected the OK header, got %x", data)
+	}
+	// The NULL bitmap of the rows starts from the third bit.
+	numBytes := (len(tests) + 7 + 2) / 8
+	return data[1 : 1+numBytes], data[1+numBytes:]
+}
+
+// parseTestArgs parses the values dumped by dumpTestRow as the arguments of COM_STMT_EXECUTE,
+// the NULL bitmap of the arguments starts from the first bit.
+func parseTestArgs(tests []binaryValueTest, rowNulls, values []byte) ([]interface{}, error) {
+	nullBitmap := make([]byte, (len(tests)+7)/8)
+	paramTypes := make([]byte, 0, len(tests)*2)
+	for i, tt := range tests {
+		if rowNulls[(i+2)/8]&(1<<uint((i+2)%8)) != 0 {
+			nullBitmap[i/8] |= 1 << uint(i%8)
+		}
+		var flag byte
+		if tt.flag&mysql.UnsignedFlag != 0 {
+			flag = 0x80
+		}
+		paramTypes = append(paramTypes, tt.tp, flag)
+	}
+	args := make([]interface{}, len(tests))
+	err := parseStmtArgs(args, make([][]byte, len(tests)), nullBitmap, paramTypes, values)
+	return args, err
+}
+
+func TestBinaryValueRoundTrip(t *testing.T) {
+	for _, tt := range binaryValueTests {
+		tests := []binaryValueTest{tt}
+		nulls, values := dumpTestRow(t, tests, []bool{false})
+		if len(values) != tt.size {
+			t.Fatalf("%s: expected %d bytes, got %x", tt.name, tt.size, values)
+		}
+		args, err := parseTestArgs(tests, nulls, values)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(args[0], tt.arg) {
+			t.Fatalf("%s: expected %#v, got %#v", tt.name, tt.arg, args[0])
+		}
+		// A truncated value is a malformed packet.
+		if _, err = parseTestArgs(tests, nulls, values[:len(values)-1]); err == nil {
+			t.Fatalf("%s: expected an error of the truncated value %x", tt.name, values[:len(values)-1])
+		}
+	}
+}
+
+// TestBinaryRowNulls sends all the values in a row with the NULLs at different bits of the
+// bitmaps, which are offset by 2 bits in the rows and are not in the arguments.
+func TestBinaryRowNulls(t *testing.T) {
+	for shift := 0; shift < 8; shift++ {
+		nulls := make([]bool, len(binaryValueTests))
+		var size int
+		for i, tt := range binaryValueTests {
+			nulls[i] = (i+shift)%3 == 0
+			if !nulls[i] {
+				size += tt.size
+			}
+		}
+		rowNulls, values := dumpTestRow(t, binaryValueTests, nulls)
+		if len(values) != size {
+			t.Fatalf("shift %d: expected %d bytes of the values, got %d", shift, size, len(values))
+		}
+		args, err := parseTestArgs(binaryValueTests, rowNulls, values)
+		if err != nil {
+			t.Fatalf("shift %d: %v", shift, err)
+		}
+		for i, tt := range binaryValueTests {
+			expected := tt.arg
+			if nulls[i] {
+				expected = nil
+			}
+			if !reflect.DeepEqual(args[i], expected) {
+				t.Fatalf("shift %d, %s: expected %#v, got %#v", shift, tt.name, expected, args[i])
+			}
+		}
+	}
+}
+
+// TestStmtArgsTypeNull checks that the arguments of MYSQL_TYPE_NULL take no bytes.
+func TestStmtArgsTypeNull(t *testing.T) {
+	args := make([]interface{}, 3)
+	paramTypes := []byte{mysql.TypeLonglong, 0, mysql.TypeNull, 0, mysql.TypeTiny, 0x80}
+	values := []byte{1, 0, 0, 0, 0, 0, 0, 0, 0xff}
+	if err := parseStmtArgs(args, make([][]byte, 3), []byte{0}, paramTypes, values); err != nil {
+		t.Fatal(err)
+	}
+	if expected := []interface{}{int64(1), nil, uint8(255)}; !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %#v, got %#v", expected, args)
+	}
+}
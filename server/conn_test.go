@@ -0,0 +1,236 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/parser/mysql"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/session"
+	"fedb/store"
+	"fedb/store/localstore"
+	"fedb/store/localstore/memory"
+)
+
+// testResult is the result of a statement read by the test client, rows is nil for an OK
+// packet and err is the message of an error packet.
+type testResult struct {
+	rows [][]string
+	err  string
+}
+
+// testClient talks to a clientConn over a pipe without the handshake.
+type testClient struct {
+	t   *testing.T
+	cc  *clientConn
+	pkt *packetIO
+}
+
+func newTestClient(t *testing.T, name string) *testClient {
+	// The driver is registered by every test, only the first succeeds.
+	store.Register("memory", localstore.Driver{Driver: memory.Driver{}})
+	s, err := store.New("memory://" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = session.BootstrapSession(s); err != nil {
+		t.Fatal(err)
+	}
+	capability := uint32(mysql.ClientProtocol41 | mysql.ClientMultiStatements)
+	ctx, err := NewFeDBDriver(s).OpenCtx(1, capability, mysql.DefaultCollationID, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	cc := newClientConn(nil)
+	cc.setConn(serverConn)
+	cc.capability = capability
+	cc.ctx = ctx
+	return &testClient{t: t, cc: cc, pkt: newPacketIO(clientConn)}
+}
+
+// query sends the query and reads the results of its statements.
+func (c *testClient) query(sql string) []testResult {
+	done := make(chan error, 1)
+	go func() {
+		data, err := c.cc.readPacket()
+		if err == nil {
+			if err = c.cc.dispatch(data); err != nil {
+				err = c.cc.writeError(err)
+			}
+		}
+		c.cc.pkt.sequence = 0
+		done <- err
+	}()
+	results := c.send(sql)
+	if err := <-done; err != nil {
+		c.t.Fatal(err)
+	}
+	return results
+}
+
+// send sends the query and reads the results of its statements, the query is read by
+// the server side of the connection.
+func (c *testClient) send(sql string) []testResult {
+	c.pkt.sequence = 0
+	if err := c.pkt.writePacket(append([]byte{0, 0, 0, 0, mysql.ComQuery}, sql...)); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.pkt.flush(); err != nil {
+		c.t.Fatal(err)
+	}
+	var results []testResult
+	for more := true; more; {
+		var result testResult
+		result, more = c.readResult()
+		results = append(results, result)
+	}
+	return results
+}
+
+func (c *testClient) readPacket() []byte {
+	data, err := c.pkt.readPacket()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return data
+}
+
+// readResult reads the result of a statement, more is true if the results of the
+// following statements exist.
+func (c *testClient) readResult() (result testResult, more bool) {
+	data := c.readPacket()
+	switch data[0] {
+	case mysql.OKHeader:
+		_, _, n := parseLengthEncodedInt(data[1:])
+		_, _, m := parseLengthEncodedInt(data[1+n:])
+		status := binary.LittleEndian.Uint16(data[1+n+m:])
+		return result, status&mysql.ServerMoreResultsExists > 0
+	case mysql.ErrHeader:
+		// The error code and the sql state are skipped.
+		return testResult{err: string(data[9:])}, false
+	}
+	columns, _, _ := parseLengthEncodedInt(data)
+	for i := uint64(0); i <= columns; i++ {
+		// The column definitions and the EOF after them.
+		c.readPacket()
+	}
+	result.rows = [][]string{}
+	for {
+		data = c.readPacket()
+		if data[0] == mysql.EOFHeader && len(data) < 9 {
+			status := binary.LittleEndian.Uint16(data[3:])
+			return result, status&mysql.ServerMoreResultsExists > 0
+		}
+		var row []string
+		for pos := 0; pos < len(data); {
+			value, isNull, n, err := parseLengthEncodedBytes(data[pos:])
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			if isNull {
+				row = append(row, "NULL")
+			} else {
+				row = append(row, string(value))
+			}
+			pos += n
+		}
+		result.rows = append(result.rows, row)
+	}
+}
+
+func (c *testClient) mustQuery(sql string, expected ...testResult) {
+	results := c.query(sql)
+	for i := range results {
+		if i < len(expected) && expected[i].err != "" && strings.Contains(results[i].err, expected[i].err) {
+			results[i].err = expected[i].err
+		}
+	}
+	if !reflect.DeepEqual(results, expected) {
+		c.t.Fatalf("%s: got %v, expected %v", sql, results, expected)
+	}
+}
+
+func TestMultiStatements(t *testing.T) {
+	c := newTestClient(t, "TestMultiStatements")
+	ok := testResult{}
+	c.mustQuery("create database test; use test; create table t (a int primary key)", ok, ok, ok)
+	c.mustQuery("insert into t values (1), (2)", ok)
+
+	// The autocommit transaction of the SELECT is finished before the INSERT is executed.
+	c.mustQuery("select a from t; insert into t values (3); select a from t",
+		testResult{rows: [][]string{{"1"}, {"2"}}},
+		ok,
+		testResult{rows: [][]string{{"1"}, {"2"}, {"3"}}})
+
+	c.mustQuery("begin; select a from t where a > 1; update t set a = a + 10 where a = 3; select a from t; commit",
+		ok,
+		testResult{rows: [][]string{{"2"}, {"3"}}},
+		ok,
+		testResult{rows: [][]string{{"1"}, {"2"}, {"13"}}},
+		ok)
+
+	// The statements after a failed statement are not executed.
+	c.mustQuery("select a from t where a = 1; select b from t; insert into t values (4)",
+		testResult{rows: [][]string{{"1"}}},
+		testResult{err: "Unknown column 'b'"})
+	c.mustQuery("select count(*) from t", testResult{rows: [][]string{{"3"}}})
+}
+
+func TestDispatchErrorLog(t *testing.T) {
+	c := newTestClient(t, "TestDispatchErrorLog")
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	go c.cc.Run()
+
+	c.send("create user 'u1'@'%' identified by 'secret1'")
+	queries := []string{
+		// The user exists already.
+		"create user 'u1'@'%' identified by 'secret2'",
+		"select 1; alter user 'u2'@'%' identified by 'secret3'",
+		// The query can't be parsed.
+		"create user 'u3'@'%' identified by 'secret4' password",
+	}
+	for _, query := range queries {
+		results := c.send(query)
+		if results[len(results)-1].err == "" {
+			t.Fatalf("%s: expected an error", query)
+		}
+	}
+	output := buf.String()
+	for i := 1; i <= 4; i++ {
+		if strings.Contains(output, fmt.Sprintf("secret%d", i)) {
+			t.Fatalf("the password is logged:\n%s", output)
+		}
+	}
+	for _, text := range []string{
+		"create user u1@% IDENTIFIED BY <secret>",
+		"select 1; alter user u2@% IDENTIFIED BY <secret>",
+		"(unparsed query, len: 53)",
+	} {
+		if !strings.Contains(output, text) {
+			t.Fatalf("expected %q in the log:\n%s", text, output)
+		}
+	}
+}
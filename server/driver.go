@@ -15,8 +15,14 @@ package server
 
 import (
 	"crypto/tls"
+	"time"
 
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
 	goctx "golang.org/x/net/context"
+
+	"fedb/util"
+	"fedb/util/chunk"
 )
 
 // IDriver opens IContext.
@@ -28,13 +34,13 @@ type IDriver interface {
 // QueryCtx is the interface to execute command.
 type QueryCtx interface {
 	// Status returns server status code.
-	//Status() uint16
+	Status() uint16
 
 	// LastInsertID returns last inserted ID.
-	//LastInsertID() uint64
+	LastInsertID() uint64
 
 	// AffectedRows returns affected rows of last executed command.
-	//AffectedRows() uint64
+	AffectedRows() uint64
 
 	// Value returns the value associated with this context for key.
 	//Value(key fmt.Stringer) interface{}
@@ -49,7 +55,7 @@ type QueryCtx interface {
 	//RollbackTxn() error
 
 	// WarningCount returns warning count of last executed command.
-	//WarningCount() uint16
+	WarningCount() uint16
 
 	// CurrentDB returns current DB.
 	//CurrentDB() string
@@ -57,28 +63,44 @@ type QueryCtx interface {
 	// Execute executes a SQL statement.
 	Execute(goCtx goctx.Context, sql string) ([]ResultSet, error)
 
+	// Parse parses the statements of a query.
+	Parse(goCtx goctx.Context, sql string) ([]ast.StmtNode, error)
+
+	// ExecuteStmt executes a statement, its result set must be closed before the next statement.
+	ExecuteStmt(goCtx goctx.Context, stmt ast.StmtNode) (ResultSet, error)
+
 	// SetClientCapability sets client capability flags
 	//SetClientCapability(uint32)
 
 	// Prepare prepares a statement.
-	//Prepare(sql string) (statement PreparedStatement, columns, params []*ColumnInfo, err error)
+	Prepare(sql string) (statement PreparedStatement, columns, params []*ColumnInfo, err error)
 
 	// GetStatement gets PreparedStatement by statement ID.
-	//GetStatement(stmtID int) PreparedStatement
+	GetStatement(stmtID int) PreparedStatement
 
 	// FieldList returns columns of a table.
-	//FieldList(tableName string) (columns []*ColumnInfo, err error)
+	FieldList(tableName string) (columns []*ColumnInfo, err error)
 
 	// Close closes the QueryCtx.
 	Close() error
 
+	// AuthPlugin returns the auth plugin of the user's account, it's empty if any plugin is accepted.
+	AuthPlugin(user *auth.UserIdentity) string
+
 	// Auth verifies user's authentication.
-	//Auth(user *auth.UserIdentity, auth []byte, salt []byte) bool
+	Auth(user *auth.UserIdentity, auth []byte, salt []byte) bool
+
+	// AuthWithPassword verifies user's authentication by the password in clear text.
+	AuthWithPassword(user *auth.UserIdentity, password string) bool
+
+	// SetProcessInfo sets the command the connection is running.
+	SetProcessInfo(sql string, t time.Time, command byte)
 
 	// ShowProcess shows the information about the session.
-	//ShowProcess() util.ProcessInfo
+	ShowProcess() util.ProcessInfo
 
-	//SetSessionManager(util.SessionManager)
+	// SetSessionManager sets the session manager used by the kill statement.
+	SetSessionManager(util.SessionManager)
 
 	// EnableChunk indicates whether the chunk execution model is enabled.
 	// TODO: remove this after tidb-server configuration "enable-chunk' removed.
@@ -86,7 +108,6 @@ type QueryCtx interface {
 }
 
 // PreparedStatement is the interface to use a prepared statement.
-/*
 type PreparedStatement interface {
 	// ID returns statement ID
 	ID() int
@@ -109,20 +130,29 @@ type PreparedStatement interface {
 	// GetParamsType returns the type for parameters.
 	GetParamsType() []byte
 
-	// Reset removes all bound parameters.
+	// StoreResultSet stores the result set of the cursor, whose rows are fetched by COM_STMT_FETCH.
+	StoreResultSet(rs ResultSet)
+
+	// GetResultSet gets the result set of the cursor.
+	GetResultSet() ResultSet
+
+	// Reset removes all bound parameters and closes the cursor.
 	Reset()
 
 	// Close closes the statement.
 	Close() error
 }
-*/
 
 // ResultSet is the result set of an query.
 type ResultSet interface {
-	//Columns() []*ColumnInfo
-	//Next(goctx.Context) (types.Row, error)
-	//SupportChunk() bool
-	//NewChunk() *chunk.Chunk
-	//NextChunk(goctx.Context, *chunk.Chunk) error
-	//Close() error
+	Columns() []*ColumnInfo
+	// NewRecordBatch creates a batch that fits the rows of the result set.
+	NewRecordBatch() *chunk.RecordBatch
+	// Next fills the batch with the next rows, an empty batch means there is no more data.
+	Next(goctx.Context, *chunk.RecordBatch) error
+	// StoreFetchedRows stores the rows read from the result set but not sent by COM_STMT_FETCH yet.
+	StoreFetchedRows(rows []chunk.Row)
+	// GetFetchedRows gets the rows read from the result set but not sent yet.
+	GetFetchedRows() []chunk.Row
+	Close() error
 }
@@ -18,35 +18,45 @@ package server
 
 import (
 	"crypto/tls"
-	"fedb/util/sqlexec"
+	"time"
 
 	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
 	goctx "golang.org/x/net/context"
 
+	"fedb/kv"
 	"fedb/session"
+	"fedb/util"
+	"fedb/util/chunk"
+	"fedb/util/sqlexec"
 )
 
 // FeDBDriver implements IDriver.
 type FeDBDriver struct {
-	//store
+	store kv.Storage
 }
 
 // NewFeDBDriver creates a new FeDBDriver.
-func NewFeDBDriver() *FeDBDriver {
-	driver := &FeDBDriver{}
+func NewFeDBDriver(store kv.Storage) *FeDBDriver {
+	driver := &FeDBDriver{
+		store: store,
+	}
 	return driver
 }
 
 // OpenCtx creates context
 func (drv *FeDBDriver) OpenCtx(connID uint64, capability uint32, collation uint8, dbname string, tlsState *tls.ConnectionState) (QueryCtx, error) {
-	//TODO: ignore collation, tlsState
-
-	session, err := session.CreateSession()
+	session, err := session.CreateSession(drv.store)
 	if err != nil {
 		return nil, errors.Trace(err)
 	}
 
 	session.SetConnectionID(connID).SetClientCapability(capability)
+	session.SetTLSState(tlsState)
 	err = session.SetCollation(int(collation))
 	if err != nil {
 		return nil, errors.Trace(err)
@@ -55,6 +65,7 @@ func (drv *FeDBDriver) OpenCtx(connID uint64, capability uint32, collation uint8
 	ctx := &FeDBContext{
 		session:   session,
 		currentDB: dbname,
+		stmts:     make(map[int]*FeDBStatement),
 	}
 	return ctx, nil
 }
@@ -63,13 +74,268 @@ func (drv *FeDBDriver) OpenCtx(connID uint64, capability uint32, collation uint8
 type FeDBContext struct {
 	session   session.Session
 	currentDB string
+	stmts     map[int]*FeDBStatement
+}
+
+// FeDBStatement implements PreparedStatement.
+type FeDBStatement struct {
+	id          uint32
+	numParams   int
+	boundParams [][]byte
+	paramsType  []byte
+	ctx         *FeDBContext
+	rs          ResultSet
+	sql         string
+}
+
+// ID implements PreparedStatement ID method.
+func (ts *FeDBStatement) ID() int {
+	return int(ts.id)
+}
+
+// Execute implements PreparedStatement Execute method.
+func (ts *FeDBStatement) Execute(goCtx goctx.Context, args ...interface{}) (rs ResultSet, err error) {
+	recordSet, err := ts.ctx.session.ExecutePreparedStmt(goCtx, ts.id, args...)
+	if err != nil {
+		return nil, err
+	}
+	if recordSet == nil {
+		return nil, nil
+	}
+	return &fedbResultSet{
+		recordSet: recordSet,
+	}, nil
+}
+
+// AppendParam implements PreparedStatement AppendParam method.
+func (ts *FeDBStatement) AppendParam(paramID int, data []byte) error {
+	if paramID >= len(ts.boundParams) {
+		return mysql.NewErr(mysql.ErrWrongArguments, "stmt_send_longdata")
+	}
+	// If len(data) is 0, append an empty byte slice to the end to distinguish no data and no parameter.
+	if len(data) == 0 {
+		ts.boundParams[paramID] = []byte{}
+	} else {
+		ts.boundParams[paramID] = append(ts.boundParams[paramID], data...)
+	}
+	return nil
+}
+
+// NumParams implements PreparedStatement NumParams method.
+func (ts *FeDBStatement) NumParams() int {
+	return ts.numParams
+}
+
+// BoundParams implements PreparedStatement BoundParams method.
+func (ts *FeDBStatement) BoundParams() [][]byte {
+	return ts.boundParams
+}
+
+// SetParamsType implements PreparedStatement SetParamsType method.
+func (ts *FeDBStatement) SetParamsType(paramsType []byte) {
+	ts.paramsType = paramsType
+}
+
+// GetParamsType implements PreparedStatement GetParamsType method.
+func (ts *FeDBStatement) GetParamsType() []byte {
+	return ts.paramsType
+}
+
+// StoreResultSet implements PreparedStatement StoreResultSet method.
+func (ts *FeDBStatement) StoreResultSet(rs ResultSet) {
+	// refer to https://dev.mysql.com/doc/refman/5.7/en/cursor-restrictions.html
+	// You can have open only a single cursor per prepared statement.
+	// closing previous ResultSet before associating a new ResultSet with this statement
+	// if it exists
+	if ts.rs != nil {
+		terror.Call(ts.rs.Close)
+	}
+	ts.rs = rs
+}
+
+// GetResultSet implements PreparedStatement GetResultSet method.
+func (ts *FeDBStatement) GetResultSet() ResultSet {
+	return ts.rs
+}
+
+// Reset implements PreparedStatement Reset method.
+func (ts *FeDBStatement) Reset() {
+	for i := range ts.boundParams {
+		ts.boundParams[i] = nil
+	}
+
+	// closing previous ResultSet if it exists
+	if ts.rs != nil {
+		terror.Call(ts.rs.Close)
+		ts.rs = nil
+	}
+}
+
+// Close implements PreparedStatement Close method.
+func (ts *FeDBStatement) Close() error {
+	// close ResultSet associated with this statement
+	if ts.rs != nil {
+		terror.Call(ts.rs.Close)
+		ts.rs = nil
+	}
+	delete(ts.ctx.stmts, int(ts.id))
+	return errors.Trace(ts.ctx.session.DropPreparedStmt(ts.id))
 }
 
 type fedbResultSet struct {
-	recordSet sqlexec.RecordSet
-	//columns   []*ColumnInfo
-	//rows      []chunk.Row
-	//closed    bool
+	recordSet   sqlexec.RecordSet
+	columns     []*ColumnInfo
+	closed      bool
+	fetchedRows []chunk.Row
+}
+
+func (trs *fedbResultSet) NewRecordBatch() *chunk.RecordBatch {
+	return trs.recordSet.NewRecordBatch()
+}
+
+func (trs *fedbResultSet) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	// The cursor is closed when all its rows are fetched, the later fetches get no rows.
+	if trs.closed {
+		req.Reset()
+		return nil
+	}
+	return trs.recordSet.Next(goCtx, req)
+}
+
+func (trs *fedbResultSet) StoreFetchedRows(rows []chunk.Row) {
+	trs.fetchedRows = rows
+}
+
+func (trs *fedbResultSet) GetFetchedRows() []chunk.Row {
+	return trs.fetchedRows
+}
+
+func (trs *fedbResultSet) Close() error {
+	if trs.closed {
+		return nil
+	}
+	trs.closed = true
+	return trs.recordSet.Close()
+}
+
+func (trs *fedbResultSet) Columns() []*ColumnInfo {
+	if trs.columns == nil {
+		fields := trs.recordSet.Fields()
+		for _, v := range fields {
+			trs.columns = append(trs.columns, convertColumnInfo(v))
+		}
+	}
+	return trs.columns
+}
+
+func convertColumnInfo(fld *ast.ResultField) (ci *ColumnInfo) {
+	ci = new(ColumnInfo)
+	ci.Name = fld.ColumnAsName.O
+	ci.OrgName = fld.Column.Name.O
+	ci.Table = fld.TableAsName.O
+	if fld.Table != nil {
+		ci.OrgTable = fld.Table.Name.O
+	}
+	ci.Schema = fld.DBName.O
+	ci.Flag = uint16(fld.Column.Flag)
+	ci.Charset = uint16(mysql.CharsetIDs[fld.Column.Charset])
+	if fld.Column.Flen == types.UnspecifiedLength {
+		ci.ColumnLength = 0
+	} else {
+		ci.ColumnLength = uint32(fld.Column.Flen)
+	}
+	if fld.Column.Tp == mysql.TypeNewDecimal {
+		// Consider the negative sign.
+		ci.ColumnLength++
+		if fld.Column.Decimal > types.DefaultFsp {
+			// Consider the decimal point.
+			ci.ColumnLength++
+		}
+	} else if types.IsString(fld.Column.Tp) {
+		// The flen is a hint, not a precise value, but some clients truncate the value
+		// to it, so use the largest multiple of all the character sets.
+		ci.ColumnLength = ci.ColumnLength * mysql.MaxBytesOfCharacter
+	}
+
+	if fld.Column.Decimal == types.UnspecifiedLength {
+		if fld.Column.Tp == mysql.TypeDuration {
+			ci.Decimal = types.DefaultFsp
+		} else {
+			ci.Decimal = mysql.NotFixedDec
+		}
+	} else {
+		ci.Decimal = uint8(fld.Column.Decimal)
+	}
+	ci.Type = fld.Column.Tp
+
+	// Keep things compatible for old clients.
+	// Refer to mysql-server/sql/protocol.cc send_result_set_metadata()
+	if ci.Type == mysql.TypeVarchar {
+		ci.Type = mysql.TypeVarString
+	}
+	return
+}
+
+// Status implements QueryCtx Status method.
+func (ctx *FeDBContext) Status() uint16 {
+	return ctx.session.Status()
+}
+
+// LastInsertID implements QueryCtx LastInsertID method.
+func (ctx *FeDBContext) LastInsertID() uint64 {
+	return ctx.session.LastInsertID()
+}
+
+// AffectedRows implements QueryCtx AffectedRows method.
+func (ctx *FeDBContext) AffectedRows() uint64 {
+	return ctx.session.AffectedRows()
+}
+
+// FieldList implements QueryCtx FieldList method.
+func (ctx *FeDBContext) FieldList(table string) (columns []*ColumnInfo, err error) {
+	fields, err := ctx.session.FieldList(table)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	columns = make([]*ColumnInfo, 0, len(fields))
+	for _, f := range fields {
+		columns = append(columns, convertColumnInfo(f))
+	}
+	return columns, nil
+}
+
+// WarningCount implements QueryCtx WarningCount method.
+func (ctx *FeDBContext) WarningCount() uint16 {
+	return ctx.session.WarningCount()
+}
+
+// SetSessionManager implements the QueryCtx SetSessionManager method.
+func (ctx *FeDBContext) SetSessionManager(sm util.SessionManager) {
+	ctx.session.SetSessionManager(sm)
+}
+
+// SetProcessInfo implements the QueryCtx SetProcessInfo method.
+func (ctx *FeDBContext) SetProcessInfo(sql string, t time.Time, command byte) {
+	ctx.session.SetProcessInfo(sql, t, command)
+}
+
+// Parse implements QueryCtx Parse method.
+func (ctx *FeDBContext) Parse(goCtx goctx.Context, sql string) ([]ast.StmtNode, error) {
+	return ctx.session.Parse(goCtx, sql)
+}
+
+// ExecuteStmt implements QueryCtx ExecuteStmt method.
+func (ctx *FeDBContext) ExecuteStmt(goCtx goctx.Context, stmt ast.StmtNode) (ResultSet, error) {
+	recordSet, err := ctx.session.ExecuteStmt(goCtx, stmt)
+	if err != nil || recordSet == nil {
+		return nil, err
+	}
+	return &fedbResultSet{recordSet: recordSet}, nil
+}
+
+// ShowProcess implements the QueryCtx ShowProcess method.
+func (ctx *FeDBContext) ShowProcess() util.ProcessInfo {
+	return ctx.session.ShowProcess()
 }
 
 // Execute executes SQL query
@@ -91,8 +357,62 @@ func (ctx *FeDBContext) Execute(goCtx goctx.Context, sql string) (rs []ResultSet
 	return rs, nil
 }
 
+// GetStatement implements QueryCtx GetStatement method.
+func (ctx *FeDBContext) GetStatement(stmtID int) PreparedStatement {
+	if stmt, ok := ctx.stmts[stmtID]; ok {
+		return stmt
+	}
+	return nil
+}
+
+// Prepare implements QueryCtx Prepare method, the types of the parameters are unknown
+// until they are sent by COM_STMT_EXECUTE, so they are described as blobs.
+func (ctx *FeDBContext) Prepare(sql string) (statement PreparedStatement, columns, params []*ColumnInfo, err error) {
+	stmtID, paramCount, fields, err := ctx.session.PrepareStmt(sql)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stmt := &FeDBStatement{
+		sql:         sql,
+		id:          stmtID,
+		numParams:   paramCount,
+		boundParams: make([][]byte, paramCount),
+		ctx:         ctx,
+	}
+	columns = make([]*ColumnInfo, len(fields))
+	for i := range fields {
+		columns[i] = convertColumnInfo(fields[i])
+	}
+	params = make([]*ColumnInfo, paramCount)
+	for i := range params {
+		params[i] = &ColumnInfo{
+			Type: mysql.TypeBlob,
+		}
+	}
+	ctx.stmts[int(stmtID)] = stmt
+	return stmt, columns, params, nil
+}
+
+// AuthPlugin implements QueryCtx AuthPlugin method.
+func (ctx *FeDBContext) AuthPlugin(user *auth.UserIdentity) string {
+	return ctx.session.AuthPlugin(user)
+}
+
+// Auth implements QueryCtx Auth method.
+func (ctx *FeDBContext) Auth(user *auth.UserIdentity, auth []byte, salt []byte) bool {
+	return ctx.session.Auth(user, auth, salt)
+}
+
+// AuthWithPassword implements QueryCtx AuthWithPassword method.
+func (ctx *FeDBContext) AuthWithPassword(user *auth.UserIdentity, password string) bool {
+	return ctx.session.AuthWithPassword(user, password)
+}
+
 // Close closes context
 func (ctx *FeDBContext) Close() error {
+	for _, stmt := range ctx.stmts {
+		terror.Call(stmt.Close)
+	}
 	ctx.session.Close()
 	return nil
 }
@@ -48,6 +48,7 @@ func newPacketIO(conn net.Conn) *packetIO {
 }
 
 func (p *packetIO) setBuffer(conn net.Conn) {
+	p.conn = conn
 	p.bufReader = bufio.NewReaderSize(conn, defaultReaderSize)
 	p.bufWriter = bufio.NewWriterSize(conn, defaultWriterSize)
 }
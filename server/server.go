@@ -17,10 +17,13 @@
 package server
 
 import (
+	"crypto/rsa"
+	"crypto/tls"
 	"fmt"
 	"math/rand"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
@@ -29,6 +32,9 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"fedb/config"
+	"fedb/privilege"
+	"fedb/sessionctx/variable"
+	"fedb/util"
 )
 
 // Server error codes.
@@ -47,12 +53,12 @@ var (
 )
 
 var (
-	//errUnknownFieldType  = terror.ClassServer.New(codeUnknownFieldType, "unknown field type")
+	errUnknownFieldType = terror.ClassServer.New(codeUnknownFieldType, "unknown field type")
 	//errInvalidPayloadLen = terror.ClassServer.New(codeInvalidPayloadLen, "invalid payload length")
 	errInvalidSequence = terror.ClassServer.New(codeInvalidSequence, "invalid sequence")
-	//errInvalidType       = terror.ClassServer.New(codeInvalidType, "invalid type")
+	errInvalidType     = terror.ClassServer.New(codeInvalidType, "invalid type")
 	//errNotAllowedCommand = terror.ClassServer.New(codeNotAllowedCommand, "the used command is not allowed with this TiDB version")
-	//errAccessDenied      = terror.ClassServer.New(codeAccessDenied, mysql.MySQLErrName[mysql.ErrAccessDenied])
+	errAccessDenied = terror.ClassServer.New(codeAccessDenied, mysql.MySQLErrName[mysql.ErrAccessDenied])
 )
 
 // DefaultCapability is the capability of the server when it is created using the default configuration.
@@ -65,14 +71,20 @@ const defaultCapability = mysql.ClientLongPassword | mysql.ClientLongFlag |
 
 // Server is the MySQL protocol server
 type Server struct {
-	cfg *config.Config
-	//tlsConfig         *tls.Config
-	driver   IDriver
-	listener net.Listener
-	rwlock   *sync.RWMutex
+	cfg       *config.Config
+	tlsConfig *tls.Config
+	driver    IDriver
+	listener  net.Listener
+	rwlock    *sync.RWMutex
 	//concurrentLimiter *TokenLimiter
 	clients    map[uint32]*clientConn
 	capability uint32
+	startTime  time.Time
+
+	// rsaKey decrypts the passwords of caching_sha2_password sent over the connections without TLS,
+	// the clients encrypt them by rsaPublicKey.
+	rsaKey       *rsa.PrivateKey
+	rsaPublicKey []byte
 
 	// stopListenerCh is used when a critical error occurred, we don't want to exit the process, because there may be
 	// a supervisor automatically restart it, then new client connection will be created, but we can't server it.
@@ -86,14 +98,27 @@ func NewServer(cfg *config.Config, driver IDriver) (*Server, error) {
 		cfg:    cfg,
 		driver: driver,
 		//concurrentLimiter
-		rwlock:  &sync.RWMutex{},
-		clients: make(map[uint32]*clientConn),
+		rwlock:    &sync.RWMutex{},
+		clients:   make(map[uint32]*clientConn),
+		startTime: time.Now(),
 	}
 
+	if err := s.loadTLSCertificates(); err != nil {
+		return nil, errors.Trace(err)
+	}
 	s.capability = defaultCapability
-	// tlsConfig
+	if s.tlsConfig != nil {
+		s.capability |= mysql.ClientSSL
+	}
 
+	if !privilege.IsSupportedPlugin(cfg.DefaultAuthPlugin) {
+		return nil, errors.Errorf("unsupported auth plugin %s", cfg.DefaultAuthPlugin)
+	}
 	var err error
+	if s.rsaKey, s.rsaPublicKey, err = newRSAKey(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
 	if s.listener, err = net.Listen("tcp", addr); err == nil {
 		log.Infof("Server listen at [%s]", addr)
@@ -104,6 +129,7 @@ func NewServer(cfg *config.Config, driver IDriver) (*Server, error) {
 	}
 
 	rand.Seed(time.Now().UTC().UnixNano())
+	variable.RegisterStatistics(s)
 	return s, nil
 }
 
@@ -146,7 +172,8 @@ func (s *Server) onConn(c net.Conn) {
 
 	if err := conn.handshake(); err != nil {
 		log.Infof("handshake error %s", errors.ErrorStack(err))
-		err = c.Close()
+		// The session is opened before the user is authenticated, so it's closed too.
+		err = conn.Close()
 		terror.Log(errors.Trace(err))
 		return
 	}
@@ -167,12 +194,68 @@ func (s *Server) newConn(conn net.Conn) *clientConn {
 		}
 	}
 	cc.setConn(conn)
+	host, port, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+	cc.peerHost, cc.peerPort = host, port
 	return cc
 }
 
+// ShowProcessList implements the SessionManager interface.
+func (s *Server) ShowProcessList() map[uint64]util.ProcessInfo {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+	rs := make(map[uint64]util.ProcessInfo, len(s.clients))
+	for _, client := range s.clients {
+		if atomic.LoadInt32(&client.status) == connStatusWaitShutdown {
+			continue
+		}
+		pi := client.ctx.ShowProcess()
+		pi.User = client.user
+		pi.Host = net.JoinHostPort(client.peerHost, client.peerPort)
+		rs[pi.ID] = pi
+	}
+	return rs
+}
+
+// Kill implements the SessionManager interface.
+func (s *Server) Kill(connectionID uint64, query bool) {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+	log.Infof("[server] Kill connectionID %d, query %t", connectionID, query)
+
+	conn, ok := s.clients[uint32(connectionID)]
+	if !ok {
+		return
+	}
+	killConn(conn, query)
+}
+
+func killConn(conn *clientConn, query bool) {
+	if !query {
+		// Mark the client connection status as WaitShutdown, when the goroutine detect
+		// this, it will end the dispatch loop and exit.
+		atomic.StoreInt32(&conn.status, connStatusWaitShutdown)
+	}
+	conn.mu.RLock()
+	cancelFunc := conn.mu.cancelFunc
+	conn.mu.RUnlock()
+	if cancelFunc != nil {
+		cancelFunc()
+	}
+}
+
 // GracefulDown graceful shutdown the server
 func (s *Server) GracefulDown() {
 	log.Infof("[server] graceful shutdown.")
 
 	//TODO
 }
+
+func init() {
+	serverMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeAccessDenied: mysql.ErrAccessDenied,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassServer] = serverMySQLErrCodes
+}
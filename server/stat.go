@@ -0,0 +1,61 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"crypto/tls"
+	"sync/atomic"
+	"time"
+
+	"fedb/sessionctx/variable"
+)
+
+// The status variables of the server.
+const (
+	statusUptime           = "Uptime"
+	statusConnections      = "Connections"
+	statusThreadsConnected = "Threads_connected"
+	statusSSLCipher        = "Ssl_cipher"
+	statusSSLVersion       = "Ssl_version"
+)
+
+// GetScope implements the variable.Statistics interface, the status variables of TLS are of the
+// session, the others are global.
+func (s *Server) GetScope(status string) variable.ScopeFlag {
+	switch status {
+	case statusSSLCipher, statusSSLVersion:
+		return variable.ScopeSession
+	}
+	return variable.ScopeGlobal
+}
+
+// Stats implements the variable.Statistics interface.
+func (s *Server) Stats(vars *variable.SessionVars) (map[string]interface{}, error) {
+	s.rwlock.RLock()
+	connected := len(s.clients)
+	s.rwlock.RUnlock()
+	// The status variables of TLS are empty if the connection isn't over TLS.
+	var cipher, version string
+	if state := vars.TLSConnectionState; state != nil {
+		cipher = tls.CipherSuiteName(state.CipherSuite)
+		version = tlsVersionName(state.Version)
+	}
+	return map[string]interface{}{
+		statusUptime:           int64(time.Since(s.startTime) / time.Second),
+		statusConnections:      atomic.LoadUint32(&baseConnID),
+		statusThreadsConnected: connected,
+		statusSSLCipher:        cipher,
+		statusSSLVersion:       version,
+	}, nil
+}
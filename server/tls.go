@@ -0,0 +1,129 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/sessionctx/variable"
+)
+
+// selfSignedCertValidity is how long the self-signed certificate of AutoTLS is valid.
+const selfSignedCertValidity = 365 * 24 * time.Hour
+
+// loadTLSCertificates loads the certificate of the server and the CA certificate to verify the
+// clients, or generates a self-signed certificate if AutoTLS is enabled. TLS is disabled if there
+// is no certificate.
+func (s *Server) loadTLSCertificates() error {
+	var (
+		tlsCert tls.Certificate
+		err     error
+	)
+	switch {
+	case len(s.cfg.SSLCert) > 0 && len(s.cfg.SSLKey) > 0:
+		tlsCert, err = tls.LoadX509KeyPair(s.cfg.SSLCert, s.cfg.SSLKey)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		variable.SysVars["ssl_cert"].Value = s.cfg.SSLCert
+		variable.SysVars["ssl_key"].Value = s.cfg.SSLKey
+	case s.cfg.AutoTLS:
+		tlsCert, err = generateSelfSignedCertificate()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		log.Warn("Secure connection uses a self-signed certificate")
+	default:
+		log.Warn("Secure connection is NOT ENABLED")
+		return nil
+	}
+
+	// The certificates of the clients are verified if they are given, REQUIRE X509 requires them.
+	clientAuthPolicy := tls.NoClientCert
+	var certPool *x509.CertPool
+	if len(s.cfg.SSLCA) > 0 {
+		caCert, err := ioutil.ReadFile(s.cfg.SSLCA)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		certPool = x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caCert) {
+			return errors.Errorf("no certificate is found in %s", s.cfg.SSLCA)
+		}
+		clientAuthPolicy = tls.VerifyClientCertIfGiven
+		variable.SysVars["ssl_ca"].Value = s.cfg.SSLCA
+	}
+	s.tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{tlsCert},
+		ClientCAs:    certPool,
+		ClientAuth:   clientAuthPolicy,
+	}
+	variable.SysVars["have_openssl"].Value = "YES"
+	variable.SysVars["have_ssl"].Value = "YES"
+	log.Infof("Secure connection is enabled (client verification enabled = %v)", certPool != nil)
+	return nil
+}
+
+// generateSelfSignedCertificate generates a certificate signed by its own key.
+func generateSelfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, errors.Trace(err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, errors.Trace(err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "FeDB Server Auto Generated Certificate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, errors.Trace(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// tlsVersionName returns the name of the TLS version as MySQL shows it in Ssl_version.
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLSv1"
+	case tls.VersionTLS11:
+		return "TLSv1.1"
+	case tls.VersionTLS12:
+		return "TLSv1.2"
+	case tls.VersionTLS13:
+		return "TLSv1.3"
+	}
+	return ""
+}
@@ -35,15 +35,17 @@
 package server
 
 import (
-	//"encoding/binary"
+	"encoding/binary"
 	"io"
-	//"math"
-	//"strconv"
-	//"time"
-	//"fedb/mysql"
-	//"github.com/pingcap/errors"
-	//"github.com/pingcap/tidb/types"
-	//"fedb/util/hack"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/util/chunk"
+	"fedb/util/hack"
 )
 
 func parseLengthEncodedInt(b []byte) (num uint64, isNull bool, n int) {
@@ -157,168 +159,169 @@ func init() {
 	}
 }
 
-// func dumpBinaryTime(dur time.Duration) (data []byte) {
-// 	if dur == 0 {
-// 		data = tinyIntCache[0]
-// 		return
-// 	}
-// 	data = make([]byte, 13)
-// 	data[0] = 12
-// 	if dur < 0 {
-// 		data[1] = 1
-// 		dur = -dur
-// 	}
-// 	days := dur / (24 * time.Hour)
-// 	dur -= days * 24 * time.Hour
-// 	data[2] = byte(days)
-// 	hours := dur / time.Hour
-// 	dur -= hours * time.Hour
-// 	data[6] = byte(hours)
-// 	minutes := dur / time.Minute
-// 	dur -= minutes * time.Minute
-// 	data[7] = byte(minutes)
-// 	seconds := dur / time.Second
-// 	dur -= seconds * time.Second
-// 	data[8] = byte(seconds)
-// 	if dur == 0 {
-// 		data[0] = 8
-// 		return data[:9]
-// 	}
-// 	binary.LittleEndian.PutUint32(data[9:13], uint32(dur/time.Microsecond))
-// 	return
-// }
-
-// func dumpBinaryDateTime(data []byte, t types.Time, loc *time.Location) ([]byte, error) {
-// 	if t.Type == mysql.TypeTimestamp && loc != nil {
-// 		// TODO: Consider time_zone variable.
-// 		t1, err := t.Time.GoTime(time.Local)
-// 		if err != nil {
-// 			return nil, errors.Errorf("FATAL: convert timestamp %v go time return error!", t.Time)
-// 		}
-// 		t.Time = types.FromGoTime(t1.In(loc))
-// 	}
+func dumpBinaryTime(dur time.Duration) (data []byte) {
+	if dur == 0 {
+		data = tinyIntCache[0]
+		return
+	}
+	data = make([]byte, 13)
+	data[0] = 12
+	if dur < 0 {
+		data[1] = 1
+		dur = -dur
+	}
+	days := dur / (24 * time.Hour)
+	dur -= days * 24 * time.Hour
+	data[2] = byte(days)
+	hours := dur / time.Hour
+	dur -= hours * time.Hour
+	data[6] = byte(hours)
+	minutes := dur / time.Minute
+	dur -= minutes * time.Minute
+	data[7] = byte(minutes)
+	seconds := dur / time.Second
+	dur -= seconds * time.Second
+	data[8] = byte(seconds)
+	if dur == 0 {
+		data[0] = 8
+		return data[:9]
+	}
+	binary.LittleEndian.PutUint32(data[9:13], uint32(dur/time.Microsecond))
+	return
+}
 
-// 	year, mon, day := t.Time.Year(), t.Time.Month(), t.Time.Day()
-// 	if t.IsZero() {
-// 		year, mon, day = 1, int(time.January), 1
-// 	}
-// 	switch t.Type {
-// 	case mysql.TypeTimestamp, mysql.TypeDatetime:
-// 		data = append(data, 11)
-// 		data = dumpUint16(data, uint16(year))
-// 		data = append(data, byte(mon), byte(day), byte(t.Time.Hour()), byte(t.Time.Minute()), byte(t.Time.Second()))
-// 		data = dumpUint32(data, uint32(t.Time.Microsecond()))
-// 	case mysql.TypeDate:
-// 		data = append(data, 4)
-// 		data = dumpUint16(data, uint16(year)) //year
-// 		data = append(data, byte(mon), byte(day))
-// 	}
-// 	return data, nil
-// }
+// dumpBinaryDateTime dumps a DATE, DATETIME or TIMESTAMP value in the binary protocol, the zero
+// value is sent with no bytes so that clients read it as 0000-00-00.
+func dumpBinaryDateTime(data []byte, t types.Time) []byte {
+	if t.IsZero() {
+		return append(data, 0)
+	}
+	year, mon, day := t.Time.Year(), t.Time.Month(), t.Time.Day()
+	switch t.Type {
+	case mysql.TypeTimestamp, mysql.TypeDatetime:
+		data = append(data, 11)
+		data = dumpUint16(data, uint16(year))
+		data = append(data, byte(mon), byte(day), byte(t.Time.Hour()), byte(t.Time.Minute()), byte(t.Time.Second()))
+		data = dumpUint32(data, uint32(t.Time.Microsecond()))
+	case mysql.TypeDate:
+		data = append(data, 4)
+		data = dumpUint16(data, uint16(year)) //year
+		data = append(data, byte(mon), byte(day))
+	}
+	return data
+}
 
-// func dumpBinaryRow(buffer []byte, columns []*ColumnInfo, row types.Row) ([]byte, error) {
-// 	buffer = append(buffer, mysql.OKHeader)
-// 	nullBitmapOff := len(buffer)
-// 	numBytes4Null := (len(columns) + 7 + 2) / 8
-// 	for i := 0; i < numBytes4Null; i++ {
-// 		buffer = append(buffer, 0)
-// 	}
-// 	for i := range columns {
-// 		if row.IsNull(i) {
-// 			bytePos := (i + 2) / 8
-// 			bitPos := byte((i + 2) % 8)
-// 			buffer[nullBitmapOff+bytePos] |= 1 << bitPos
-// 			continue
-// 		}
-// 		switch columns[i].Type {
-// 		case mysql.TypeTiny:
-// 			buffer = append(buffer, byte(row.GetInt64(i)))
-// 		case mysql.TypeShort, mysql.TypeYear:
-// 			buffer = dumpUint16(buffer, uint16(row.GetInt64(i)))
-// 		case mysql.TypeInt24, mysql.TypeLong:
-// 			buffer = dumpUint32(buffer, uint32(row.GetInt64(i)))
-// 		case mysql.TypeLonglong:
-// 			buffer = dumpUint64(buffer, row.GetUint64(i))
-// 		case mysql.TypeFloat:
-// 			buffer = dumpUint32(buffer, math.Float32bits(row.GetFloat32(i)))
-// 		case mysql.TypeDouble:
-// 			buffer = dumpUint64(buffer, math.Float64bits(row.GetFloat64(i)))
-// 		case mysql.TypeNewDecimal:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetMyDecimal(i).String()))
-// 		case mysql.TypeString, mysql.TypeVarString, mysql.TypeVarchar, mysql.TypeBit,
-// 			mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
-// 			buffer = dumpLengthEncodedString(buffer, row.GetBytes(i))
-// 		case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
-// 			var err error
-// 			buffer, err = dumpBinaryDateTime(buffer, row.GetTime(i), nil)
-// 			if err != nil {
-// 				return buffer, errors.Trace(err)
-// 			}
-// 		case mysql.TypeDuration:
-// 			buffer = append(buffer, dumpBinaryTime(row.GetDuration(i).Duration)...)
-// 		case mysql.TypeEnum:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetEnum(i).String()))
-// 		case mysql.TypeSet:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetSet(i).String()))
-// 		case mysql.TypeJSON:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetJSON(i).String()))
-// 		default:
-// 			return nil, errInvalidType.Gen("invalid type %v", columns[i].Type)
-// 		}
-// 	}
-// 	return buffer, nil
-// }
+// dumpBinaryRow dumps a row in the binary protocol, which is the response of COM_STMT_EXECUTE
+// and COM_STMT_FETCH.
+func dumpBinaryRow(buffer []byte, columns []*ColumnInfo, row chunk.Row) ([]byte, error) {
+	buffer = append(buffer, mysql.OKHeader)
+	nullBitmapOff := len(buffer)
+	numBytes4Null := (len(columns) + 7 + 2) / 8
+	for i := 0; i < numBytes4Null; i++ {
+		buffer = append(buffer, 0)
+	}
+	for i := range columns {
+		if row.IsNull(i) {
+			bytePos := (i + 2) / 8
+			bitPos := byte((i + 2) % 8)
+			buffer[nullBitmapOff+bytePos] |= 1 << bitPos
+			continue
+		}
+		switch columns[i].Type {
+		case mysql.TypeTiny:
+			buffer = append(buffer, byte(row.GetInt64(i)))
+		case mysql.TypeShort, mysql.TypeYear:
+			buffer = dumpUint16(buffer, uint16(row.GetInt64(i)))
+		case mysql.TypeInt24, mysql.TypeLong:
+			buffer = dumpUint32(buffer, uint32(row.GetInt64(i)))
+		case mysql.TypeLonglong:
+			buffer = dumpUint64(buffer, row.GetUint64(i))
+		case mysql.TypeFloat:
+			buffer = dumpUint32(buffer, math.Float32bits(row.GetFloat32(i)))
+		case mysql.TypeDouble:
+			buffer = dumpUint64(buffer, math.Float64bits(row.GetFloat64(i)))
+		case mysql.TypeNewDecimal:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetMyDecimal(i).String()))
+		case mysql.TypeString, mysql.TypeVarString, mysql.TypeVarchar, mysql.TypeBit,
+			mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
+			buffer = dumpLengthEncodedString(buffer, row.GetBytes(i))
+		case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+			buffer = dumpBinaryDateTime(buffer, row.GetTime(i))
+		case mysql.TypeDuration:
+			buffer = append(buffer, dumpBinaryTime(row.GetDuration(i, int(columns[i].Decimal)).Duration)...)
+		case mysql.TypeEnum:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetEnum(i).String()))
+		case mysql.TypeSet:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetSet(i).String()))
+		case mysql.TypeJSON:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetJSON(i).String()))
+		default:
+			return nil, errInvalidType.GenWithStack("invalid type %v", columns[i].Type)
+		}
+	}
+	return buffer, nil
+}
 
-// func dumpTextRow(buffer []byte, columns []*ColumnInfo, row types.Row) ([]byte, error) {
-// 	tmp := make([]byte, 0, 20)
-// 	for i, col := range columns {
-// 		if row.IsNull(i) {
-// 			buffer = append(buffer, 0xfb)
-// 			continue
-// 		}
-// 		switch col.Type {
-// 		case mysql.TypeTiny, mysql.TypeShort, mysql.TypeYear, mysql.TypeInt24, mysql.TypeLong:
-// 			tmp = strconv.AppendInt(tmp[:0], row.GetInt64(i), 10)
-// 			buffer = dumpLengthEncodedString(buffer, tmp)
-// 		case mysql.TypeLonglong:
-// 			if mysql.HasUnsignedFlag(uint(columns[i].Flag)) {
-// 				tmp = strconv.AppendUint(tmp[:0], row.GetUint64(i), 10)
-// 			} else {
-// 				tmp = strconv.AppendInt(tmp[:0], row.GetInt64(i), 10)
-// 			}
-// 			buffer = dumpLengthEncodedString(buffer, tmp)
-// 		case mysql.TypeFloat:
-// 			prec := -1
-// 			if columns[i].Decimal > 0 && int(col.Decimal) != mysql.NotFixedDec {
-// 				prec = int(col.Decimal)
-// 			}
-// 			tmp = strconv.AppendFloat(tmp[:0], float64(row.GetFloat32(i)), 'f', prec, 32)
-// 			buffer = dumpLengthEncodedString(buffer, tmp)
-// 		case mysql.TypeDouble:
-// 			prec := -1
-// 			if col.Decimal > 0 && int(col.Decimal) != mysql.NotFixedDec {
-// 				prec = int(col.Decimal)
-// 			}
-// 			tmp = strconv.AppendFloat(tmp[:0], row.GetFloat64(i), 'f', prec, 64)
-// 			buffer = dumpLengthEncodedString(buffer, tmp)
-// 		case mysql.TypeNewDecimal:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetMyDecimal(i).String()))
-// 		case mysql.TypeString, mysql.TypeVarString, mysql.TypeVarchar, mysql.TypeBit,
-// 			mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
-// 			buffer = dumpLengthEncodedString(buffer, row.GetBytes(i))
-// 		case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetTime(i).String()))
-// 		case mysql.TypeDuration:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetDuration(i).String()))
-// 		case mysql.TypeEnum:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetEnum(i).String()))
-// 		case mysql.TypeSet:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetSet(i).String()))
-// 		case mysql.TypeJSON:
-// 			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetJSON(i).String()))
-// 		default:
-// 			return nil, errInvalidType.Gen("invalid type %v", columns[i].Type)
-// 		}
-// 	}
-// 	return buffer, nil
-// }
+func dumpTextRow(buffer []byte, columns []*ColumnInfo, row chunk.Row) ([]byte, error) {
+	tmp := make([]byte, 0, 20)
+	for i, col := range columns {
+		if row.IsNull(i) {
+			buffer = append(buffer, 0xfb)
+			continue
+		}
+		switch col.Type {
+		case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong:
+			tmp = strconv.AppendInt(tmp[:0], row.GetInt64(i), 10)
+			buffer = dumpLengthEncodedString(buffer, tmp)
+		case mysql.TypeYear:
+			year := row.GetInt64(i)
+			tmp = tmp[:0]
+			if year == 0 {
+				tmp = append(tmp, '0', '0', '0', '0')
+			} else {
+				tmp = strconv.AppendInt(tmp, year, 10)
+			}
+			buffer = dumpLengthEncodedString(buffer, tmp)
+		case mysql.TypeLonglong:
+			if mysql.HasUnsignedFlag(uint(col.Flag)) {
+				tmp = strconv.AppendUint(tmp[:0], row.GetUint64(i), 10)
+			} else {
+				tmp = strconv.AppendInt(tmp[:0], row.GetInt64(i), 10)
+			}
+			buffer = dumpLengthEncodedString(buffer, tmp)
+		case mysql.TypeFloat:
+			prec := -1
+			if col.Decimal > 0 && int(col.Decimal) != mysql.NotFixedDec {
+				prec = int(col.Decimal)
+			}
+			tmp = strconv.AppendFloat(tmp[:0], float64(row.GetFloat32(i)), 'f', prec, 32)
+			buffer = dumpLengthEncodedString(buffer, tmp)
+		case mysql.TypeDouble:
+			prec := -1
+			if col.Decimal > 0 && int(col.Decimal) != mysql.NotFixedDec {
+				prec = int(col.Decimal)
+			}
+			tmp = strconv.AppendFloat(tmp[:0], row.GetFloat64(i), 'f', prec, 64)
+			buffer = dumpLengthEncodedString(buffer, tmp)
+		case mysql.TypeNewDecimal:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetMyDecimal(i).String()))
+		case mysql.TypeString, mysql.TypeVarString, mysql.TypeVarchar, mysql.TypeBit,
+			mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
+			buffer = dumpLengthEncodedString(buffer, row.GetBytes(i))
+		case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetTime(i).String()))
+		case mysql.TypeDuration:
+			dur := row.GetDuration(i, int(col.Decimal))
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(dur.String()))
+		case mysql.TypeEnum:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetEnum(i).String()))
+		case mysql.TypeSet:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetSet(i).String()))
+		case mysql.TypeJSON:
+			buffer = dumpLengthEncodedString(buffer, hack.Slice(row.GetJSON(i).String()))
+		default:
+			return nil, errInvalidType.GenWithStack("invalid type %v", col.Type)
+		}
+	}
+	return buffer, nil
+}
@@ -0,0 +1,256 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/session/bootstrap.go
+//
+
+package session
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/infoschema"
+)
+
+const (
+	// CreateUserTable is the SQL statement creates User table in system db.
+	CreateUserTable = `CREATE TABLE IF NOT EXISTS mysql.user (
+		Host                  CHAR(64),
+		User                  CHAR(32),
+		authentication_string TEXT,
+		plugin                CHAR(64) NOT NULL DEFAULT 'mysql_native_password',
+		ssl_type              CHAR(9) NOT NULL DEFAULT '',
+		account_locked        ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Select_priv           ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Insert_priv           ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Update_priv           ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Delete_priv           ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Create_priv           ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Drop_priv             ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Process_priv          ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Grant_priv            ENUM('N','Y') NOT NULL DEFAULT 'N',
+		References_priv       ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Alter_priv            ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Show_db_priv          ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Super_priv            ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Execute_priv          ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Index_priv            ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Create_user_priv      ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Trigger_priv          ENUM('N','Y') NOT NULL DEFAULT 'N',
+		PRIMARY KEY (Host, User));`
+	// CreateDBPrivTable is the SQL statement creates DB scope privilege table in system db.
+	CreateDBPrivTable = `CREATE TABLE IF NOT EXISTS mysql.db (
+		Host         CHAR(64),
+		DB           CHAR(64),
+		User         CHAR(32),
+		Select_priv  ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Insert_priv  ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Update_priv  ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Delete_priv  ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Create_priv  ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Drop_priv    ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Grant_priv   ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Alter_priv   ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Execute_priv ENUM('N','Y') NOT NULL DEFAULT 'N',
+		Index_priv   ENUM('N','Y') NOT NULL DEFAULT 'N',
+		PRIMARY KEY (Host, DB, User));`
+	// CreateTablePrivTable is the SQL statement creates table scope privilege table in system db.
+	CreateTablePrivTable = `CREATE TABLE IF NOT EXISTS mysql.tables_priv (
+		Host        CHAR(64),
+		DB          CHAR(64),
+		User        CHAR(32),
+		Table_name  CHAR(64),
+		Grantor     CHAR(77),
+		Timestamp   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		Table_priv  SET('Select','Insert','Update','Delete','Create','Drop','Grant','Index','Alter') NOT NULL DEFAULT '',
+		Column_priv SET('Select','Insert','Update') NOT NULL DEFAULT '',
+		PRIMARY KEY (Host, DB, User, Table_name));`
+	// CreateColumnPrivTable is the SQL statement creates column scope privilege table in system db.
+	CreateColumnPrivTable = `CREATE TABLE IF NOT EXISTS mysql.columns_priv (
+		Host        CHAR(64),
+		DB          CHAR(64),
+		User        CHAR(32),
+		Table_name  CHAR(64),
+		Column_name CHAR(64),
+		Timestamp   TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		Column_priv SET('Select','Insert','Update') NOT NULL DEFAULT '',
+		PRIMARY KEY (Host, DB, User, Table_name, Column_name));`
+	// CreateRoleEdgesTable is the SQL statement creates the table of the roles granted to the
+	// accounts in system db.
+	CreateRoleEdgesTable = `CREATE TABLE IF NOT EXISTS mysql.role_edges (
+		FROM_HOST         CHAR(64) NOT NULL DEFAULT '',
+		FROM_USER         CHAR(32) NOT NULL DEFAULT '',
+		TO_HOST           CHAR(64) NOT NULL DEFAULT '',
+		TO_USER           CHAR(32) NOT NULL DEFAULT '',
+		WITH_ADMIN_OPTION ENUM('N','Y') NOT NULL DEFAULT 'N',
+		PRIMARY KEY (FROM_HOST, FROM_USER, TO_HOST, TO_USER));`
+	// CreateDefaultRolesTable is the SQL statement creates the table of the default roles of the
+	// accounts in system db.
+	CreateDefaultRolesTable = `CREATE TABLE IF NOT EXISTS mysql.default_roles (
+		HOST              CHAR(64) NOT NULL DEFAULT '',
+		USER              CHAR(32) NOT NULL DEFAULT '',
+		DEFAULT_ROLE_HOST CHAR(64) NOT NULL DEFAULT '%',
+		DEFAULT_ROLE_USER CHAR(32) NOT NULL DEFAULT '',
+		PRIMARY KEY (HOST, USER, DEFAULT_ROLE_HOST, DEFAULT_ROLE_USER));`
+	// CreateFeDBTable is the SQL statement creates a table in system db.
+	// This table is a key-value struct contains some information used by FeDB.
+	// Currently we only put bootstrap version in it.
+	CreateFeDBTable = `CREATE TABLE IF NOT EXISTS mysql.fedb (
+		VARIABLE_NAME  VARCHAR(64) NOT NULL PRIMARY KEY,
+		VARIABLE_VALUE VARCHAR(1024) DEFAULT NULL,
+		COMMENT        VARCHAR(1024));`
+)
+
+const (
+	// The variable name in mysql.fedb table.
+	// It is used for getting the version of the FeDB server which bootstrapped the store.
+	fedbServerVersionVar = "fedb_server_version"
+
+	// Const for FeDB server version 1.
+	version1 = 1
+
+	// currentBootstrapVersion is the version of the system tables created by this server.
+	currentBootstrapVersion = version1
+)
+
+// bootstrap creates the system tables when the store is used for the first time, and upgrades
+// them if the store is bootstrapped by an older version.
+func bootstrap(s *session) error {
+	ver, err := getBootstrapVersion(s)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if ver >= currentBootstrapVersion {
+		// It is already bootstrapped/upgraded by a higher version FeDB server.
+		return nil
+	}
+	if ver == 0 {
+		log.Infof("[bootstrap] bootstrap the store to version %d", currentBootstrapVersion)
+		if err = doDDLWorks(s); err != nil {
+			return errors.Trace(err)
+		}
+		return errors.Trace(doDMLWorks(s))
+	}
+	return errors.Trace(upgrade(s, ver))
+}
+
+// upgrade does the upgrade works, when the system is bootstrapped by a lower version FeDB server,
+// then updates the bootstrap version.
+func upgrade(s *session, ver int64) error {
+	log.Infof("[bootstrap] upgrade the store from version %d to %d", ver, currentBootstrapVersion)
+	return errors.Trace(updateBootstrapVer(s))
+}
+
+// grantAllGlobalPrivs returns the assignments of UPDATE mysql.user which grant all the privileges.
+func grantAllGlobalPrivs() string {
+	assigns := make([]string, 0, len(mysql.AllGlobalPrivs))
+	for _, priv := range mysql.AllGlobalPrivs {
+		assigns = append(assigns, mysql.Priv2UserCol[priv]+" = 'Y'")
+	}
+	return strings.Join(assigns, ", ")
+}
+
+// getFeDBVar gets variable value from mysql.fedb table.
+func getFeDBVar(s *session, name string) (sVal string, isNull bool, e error) {
+	rows, _, err := s.ExecRestrictedSQL(goctx.Background(),
+		"SELECT VARIABLE_VALUE FROM mysql.fedb WHERE VARIABLE_NAME = ?", name)
+	if err != nil {
+		return "", true, errors.Trace(err)
+	}
+	if len(rows) == 0 || rows[0].IsNull(0) {
+		return "", true, nil
+	}
+	return rows[0].GetString(0), false, nil
+}
+
+// getBootstrapVersion gets bootstrap version from mysql.fedb table, it's 0 if the store is
+// not bootstrapped.
+func getBootstrapVersion(s *session) (int64, error) {
+	sVal, isNull, err := getFeDBVar(s, fedbServerVersionVar)
+	if err != nil {
+		if infoschema.ErrDatabaseNotExists.Equal(err) || infoschema.ErrTableNotExists.Equal(err) {
+			return 0, nil
+		}
+		return 0, errors.Trace(err)
+	}
+	if isNull {
+		return 0, nil
+	}
+	ver, err := strconv.ParseInt(sVal, 10, 64)
+	return ver, errors.Trace(err)
+}
+
+// updateBootstrapVer updates bootstrap version variable in mysql.fedb table.
+func updateBootstrapVer(s *session) error {
+	_, _, err := s.ExecRestrictedSQL(goctx.Background(),
+		`INSERT INTO mysql.fedb VALUES (?, ?, "Bootstrap version. Do not delete.")
+		ON DUPLICATE KEY UPDATE VARIABLE_VALUE = VALUES(VARIABLE_VALUE)`,
+		fedbServerVersionVar, strconv.Itoa(currentBootstrapVersion))
+	return errors.Trace(err)
+}
+
+// doDDLWorks executes DDL statements in bootstrap stage.
+func doDDLWorks(s *session) error {
+	for _, sql := range []string{
+		// Create system db.
+		"CREATE DATABASE IF NOT EXISTS " + mysql.SystemDB,
+		// Create privilege tables.
+		CreateUserTable,
+		CreateDBPrivTable,
+		CreateTablePrivTable,
+		CreateColumnPrivTable,
+		CreateRoleEdgesTable,
+		CreateDefaultRolesTable,
+		// Create FeDB table.
+		CreateFeDBTable,
+	} {
+		if err := executeBootstrapSQL(s, sql); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// doDMLWorks executes DML statements in bootstrap stage.
+// All the statements run in a single transaction.
+func doDMLWorks(s *session) error {
+	if err := executeBootstrapSQL(s, "BEGIN"); err != nil {
+		return errors.Trace(err)
+	}
+	// Insert a default user with empty password and all the privileges.
+	if err := executeBootstrapSQL(s, `INSERT INTO mysql.user (Host, User, authentication_string) VALUES ("%", "root", "")`); err != nil {
+		return errors.Trace(err)
+	}
+	if err := executeBootstrapSQL(s, `UPDATE mysql.user SET `+grantAllGlobalPrivs()+` WHERE Host = "%" AND User = "root"`); err != nil {
+		return errors.Trace(err)
+	}
+	if err := updateBootstrapVer(s); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(executeBootstrapSQL(s, "COMMIT"))
+}
+
+// executeBootstrapSQL executes a statement of the bootstrap, the statement is added to the error.
+func executeBootstrapSQL(s *session, sql string) error {
+	_, err := s.Execute(goctx.Background(), sql)
+	if err != nil {
+		return errors.Annotatef(err, "[bootstrap] execute %q", sql)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/executor/ddl.go
+//
+
+package session
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	goctx "golang.org/x/net/context"
+
+	"fedb/ddl"
+	"fedb/infoschema"
+	"fedb/sessionctx/variable"
+)
+
+// executeDDL commits the current transaction before the DDL statement as MySQL does,
+// the DDL statement runs in its own transaction.
+func (s *session) executeDDL(ctx goctx.Context, stmt ast.DDLNode) error {
+	if err := s.CommitTxn(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	switch x := stmt.(type) {
+	case *ast.CreateDatabaseStmt:
+		return s.executeCreateDatabase(x)
+	case *ast.DropDatabaseStmt:
+		return s.executeDropDatabase(x)
+	case *ast.CreateTableStmt:
+		return s.executeCreateTable(x)
+	case *ast.DropTableStmt:
+		return s.executeDropTable(x)
+	case *ast.CreateIndexStmt:
+		return s.executeCreateIndex(x)
+	case *ast.DropIndexStmt:
+		return s.executeDropIndex(x)
+	case *ast.AlterTableStmt:
+		return s.executeAlterTable(x)
+	case *ast.RenameTableStmt:
+		return s.executeRenameTable(x)
+	}
+	return errors.Errorf("unsupported statement: %s", stmt.Text())
+}
+
+func (s *session) executeCreateDatabase(stmt *ast.CreateDatabaseStmt) error {
+	var opt *ast.CharsetOpt
+	if len(stmt.Options) != 0 {
+		opt = &ast.CharsetOpt{}
+		for _, val := range stmt.Options {
+			switch val.Tp {
+			case ast.DatabaseOptionCharset:
+				opt.Chs = val.Value
+			case ast.DatabaseOptionCollate:
+				opt.Col = val.Value
+			}
+		}
+	}
+	err := s.dom.DDL().CreateSchema(model.NewCIStr(stmt.Name), opt)
+	if err != nil {
+		if infoschema.ErrDatabaseExists.Equal(err) && stmt.IfNotExists {
+			err = nil
+		}
+	}
+	return errors.Trace(err)
+}
+
+func (s *session) executeDropDatabase(stmt *ast.DropDatabaseStmt) error {
+	dbName := model.NewCIStr(stmt.Name)
+	if infoschema.IsMemoryDB(dbName.L) {
+		return infoschema.ErrDBAccessDenied.GenWithStackByArgs(dbName.O)
+	}
+	err := s.dom.DDL().DropSchema(dbName)
+	if infoschema.ErrDatabaseNotExists.Equal(err) {
+		if stmt.IfExists {
+			err = nil
+		} else {
+			err = infoschema.ErrDatabaseDropExists.GenWithStackByArgs(stmt.Name)
+		}
+	}
+	sessionVars := s.sessionVars
+	if err == nil && strings.ToLower(sessionVars.CurrentDB) == dbName.L {
+		sessionVars.CurrentDB = ""
+		terror.Log(errors.Trace(sessionVars.SetSystemVar(variable.CharsetDatabase, mysql.DefaultCharset)))
+		terror.Log(errors.Trace(sessionVars.SetSystemVar(variable.CollationDatabase, mysql.DefaultCollationName)))
+	}
+	return errors.Trace(err)
+}
+
+func (s *session) executeCreateTable(stmt *ast.CreateTableStmt) error {
+	ident, err := s.tableIdent(stmt.Table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.dom.DDL().CreateTable(ident, stmt))
+}
+
+// executeDropTable drops the tables one by one, the tables not exist are reported together.
+func (s *session) executeDropTable(stmt *ast.DropTableStmt) error {
+	var notExistTables []string
+	for _, tn := range stmt.Tables {
+		fullti, err := s.tableIdent(tn)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		err = s.dom.DDL().DropTable(fullti)
+		if infoschema.ErrDatabaseNotExists.Equal(err) || infoschema.ErrTableNotExists.Equal(err) {
+			notExistTables = append(notExistTables, fullti.String())
+		} else if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if len(notExistTables) > 0 && !stmt.IfExists {
+		return infoschema.ErrTableDropExists.GenWithStackByArgs(strings.Join(notExistTables, ","))
+	}
+	return nil
+}
+
+func (s *session) executeCreateIndex(stmt *ast.CreateIndexStmt) error {
+	ident, err := s.tableIdent(stmt.Table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = s.dom.DDL().CreateIndex(ident, stmt.Unique, model.NewCIStr(stmt.IndexName), stmt.IndexColNames)
+	return errors.Trace(err)
+}
+
+func (s *session) executeDropIndex(stmt *ast.DropIndexStmt) error {
+	ident, err := s.tableIdent(stmt.Table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = s.dom.DDL().DropIndex(ident, model.NewCIStr(stmt.IndexName))
+	if ddl.ErrCantDropFieldOrKey.Equal(err) && stmt.IfExists {
+		err = nil
+	}
+	return errors.Trace(err)
+}
+
+func (s *session) executeAlterTable(stmt *ast.AlterTableStmt) error {
+	ident, err := s.tableIdent(stmt.Table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.dom.DDL().AlterTable(ident, stmt.Specs))
+}
+
+// executeRenameTable renames the tables in order, every rename is a DDL job, so the
+// renames before a failed one are not undone.
+func (s *session) executeRenameTable(stmt *ast.RenameTableStmt) error {
+	for _, t2t := range stmt.TableToTables {
+		oldIdent, err := s.tableIdent(t2t.OldTable)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		newIdent, err := s.tableIdent(t2t.NewTable)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = s.dom.DDL().RenameTable(oldIdent, newIdent); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// tableIdent returns the identifier of the table, the current database is used
+// if the table name is not qualified. The tables of the memory databases can't be
+// changed by the DDL statements.
+func (s *session) tableIdent(tn *ast.TableName) (ast.Ident, error) {
+	schema := tn.Schema
+	if schema.L == "" {
+		if s.sessionVars.CurrentDB == "" {
+			return ast.Ident{}, errNoDB
+		}
+		schema = model.NewCIStr(s.sessionVars.CurrentDB)
+	}
+	if infoschema.IsMemoryDB(schema.L) {
+		return ast.Ident{}, infoschema.ErrDBAccessDenied.GenWithStackByArgs(schema.O)
+	}
+	return ast.Ident{Schema: schema, Name: tn.Name}, nil
+}
+
+func (s *session) executeUse(stmt *ast.UseStmt) error {
+	dbname := model.NewCIStr(stmt.DBName)
+	dbinfo, exists := s.dom.InfoSchema().SchemaByName(dbname)
+	if !exists {
+		return infoschema.ErrDatabaseNotExists.GenWithStackByArgs(dbname)
+	}
+	s.sessionVars.CurrentDB = dbname.O
+	// character_set_database is the character set used by the default database.
+	// The server sets this variable whenever the default database changes.
+	// See http://dev.mysql.com/doc/refman/5.7/en/server-system-variables.html#sysvar_character_set_database
+	sessionVars := s.sessionVars
+	terror.Log(errors.Trace(sessionVars.SetSystemVar(variable.CharsetDatabase, dbinfo.Charset)))
+	terror.Log(errors.Trace(sessionVars.SetSystemVar(variable.CollationDatabase, dbinfo.Collate)))
+	return nil
+}
@@ -0,0 +1,204 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pingcap/parser/model"
+
+	"fedb/kv"
+	"fedb/tablecodec"
+)
+
+func countKeys(t *testing.T, s kv.Storage, prefix kv.Key) int {
+	var n int
+	err := kv.RunInNewTxn(s, false, func(txn kv.Transaction) error {
+		n = 0
+		it, err := txn.Iter(prefix, prefix.PrefixNext())
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for it.Valid() {
+			n++
+			if err = it.Next(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestDropTableData(t *testing.T) {
+	s := newTestStore(t, "TestDropTableData")
+	se := newTestSession(t, s)
+	mustExec(t, se, "create table t (a int primary key, b int, c int, index ib (b), index ic (c))")
+	mustExec(t, se, "insert into t values (1, 1, 1), (2, 2, 2), (3, 3, 3)")
+	tblInfo, err := se.(*session).GetInfoSchema().TableByName(model.NewCIStr("test"), model.NewCIStr("t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordPrefix := tablecodec.GenTableRecordPrefix(tblInfo.ID)
+	ibPrefix := tablecodec.EncodeTableIndexPrefix(tblInfo.ID, tblInfo.Indices[0].ID)
+	icPrefix := tablecodec.EncodeTableIndexPrefix(tblInfo.ID, tblInfo.Indices[1].ID)
+	if n := countKeys(t, s, icPrefix); n != 3 {
+		t.Fatalf("expected 3 keys of index ic, got %d", n)
+	}
+
+	// The worker deletes the data of a job before it runs the next one.
+	mustExec(t, se, "drop index ic on t")
+	mustExec(t, se, "create table t1 (a int)")
+	if n := countKeys(t, s, icPrefix); n != 0 {
+		t.Fatalf("expected no keys of the dropped index, got %d", n)
+	}
+	if n := countKeys(t, s, ibPrefix); n != 3 {
+		t.Fatalf("expected 3 keys of index ib, got %d", n)
+	}
+
+	mustExec(t, se, "drop table t")
+	mustExec(t, se, "create table t2 (a int)")
+	for _, prefix := range []kv.Key{recordPrefix, ibPrefix, tablecodec.EncodeTablePrefix(tblInfo.ID)} {
+		if n := countKeys(t, s, prefix); n != 0 {
+			t.Fatalf("expected no keys of the dropped table, got %d", n)
+		}
+	}
+}
+
+func TestDropDatabaseData(t *testing.T) {
+	s := newTestStore(t, "TestDropDatabaseData")
+	se := newTestSession(t, s)
+	mustExec(t, se, "create database d")
+	mustExec(t, se, "create table d.t (a int primary key, b int, index ib (b))")
+	mustExec(t, se, "insert into d.t values (1, 1), (2, 2)")
+	tblInfo, err := se.(*session).GetInfoSchema().TableByName(model.NewCIStr("d"), model.NewCIStr("t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := tablecodec.EncodeTablePrefix(tblInfo.ID)
+	if n := countKeys(t, s, prefix); n != 4 {
+		t.Fatalf("expected 4 keys of the table, got %d", n)
+	}
+
+	mustExec(t, se, "drop database d")
+	mustExec(t, se, "create table t (a int)")
+	if n := countKeys(t, s, prefix); n != 0 {
+		t.Fatalf("expected no keys of the dropped database, got %d", n)
+	}
+}
+
+func mustRows(t *testing.T, se Session, sql string, expected string) {
+	if rows := fmt.Sprint(mustExec(t, se, sql)); rows != expected {
+		t.Fatalf("%s: expected %s, got %s", sql, expected, rows)
+	}
+}
+
+// TestDecodeRowsAfterSchemaChange checks that the rows written before the columns are added or
+// dropped are decoded by the column IDs, the added columns get their original default values.
+func TestDecodeRowsAfterSchemaChange(t *testing.T) {
+	s := newTestStore(t, "TestDecodeRowsAfterSchemaChange")
+	se := newTestSession(t, s)
+	mustExec(t, se, "create table t (id int primary key, a int, b varchar(20), c decimal(10,2), d datetime)")
+	mustExec(t, se, "insert into t values (1, -1, 'one', 1.5, '2001-01-01 01:01:01'), (2, null, null, null, null)")
+
+	mustExec(t, se, "alter table t add column e int default 7")
+	mustExec(t, se, "alter table t add column f varchar(10)")
+	mustExec(t, se, "alter table t add column g varchar(10) not null default 'x'")
+	mustRows(t, se, "select * from t order by id",
+		"[[1 -1 one 1.50 2001-01-01 01:01:01 7  x] [2     7  x]]")
+	// A changed default value doesn't change the values of the old rows.
+	mustExec(t, se, "alter table t alter column e set default 8")
+	mustExec(t, se, "insert into t (id) values (3)")
+	mustRows(t, se, "select id, e, f, g from t order by id", "[[1 7  x] [2 7  x] [3 8  x]]")
+
+	mustExec(t, se, "alter table t drop column b")
+	mustExec(t, se, "alter table t drop column d")
+	mustRows(t, se, "select * from t order by id",
+		"[[1 -1 1.50 7  x] [2   7  x] [3   8  x]]")
+
+	// The re-added column has a new ID, the values of the dropped one are not decoded.
+	mustExec(t, se, "alter table t add column b int default 5")
+	mustExec(t, se, "update t set f = 'y' where id = 1")
+	mustExec(t, se, "insert into t (id, a, b) values (4, 4, 4)")
+	mustRows(t, se, "select * from t order by id",
+		"[[1 -1 1.50 7 y x 5] [2   7  x 5] [3   8  x 5] [4 4  8  x 4]]")
+	mustRows(t, se, "select id from t where b = 5 and e = 7 order by id", "[[1] [2]]")
+}
+
+// TestDMLDuringCreateIndex runs the autocommit DML statements while the index is built,
+// the statements compiled with the schema before a state change of the index are executed
+// again with the new one, so none of them fails and the index has the keys of all the rows.
+func TestDMLDuringCreateIndex(t *testing.T) {
+	s := newTestStore(t, "TestDMLDuringCreateIndex")
+	se := newTestSession(t, s)
+	mustExec(t, se, "create table t (id int primary key, b int)")
+	for i := 0; i < 50; i++ {
+		var values []string
+		for j := 0; j < 100; j++ {
+			id := i*100 + j
+			values = append(values, fmt.Sprintf("(%d, %d)", id, id))
+		}
+		mustExec(t, se, "insert into t values "+strings.Join(values, ", "))
+	}
+
+	const workers = 4
+	stop := make(chan struct{})
+	errCh := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		se := newTestSession(t, s)
+		go func(i int) {
+			var err error
+			for j := 0; err == nil; j++ {
+				select {
+				case <-stop:
+					errCh <- nil
+					return
+				default:
+				}
+				id := 10000 + j*workers + i
+				_, err = execSQL(se, fmt.Sprintf("insert into t values (%d, %d)", id, id))
+				if err == nil {
+					_, err = execSQL(se, fmt.Sprintf("update t set b = b + 1 where id = %d", j*workers+i))
+				}
+				if err == nil && j%2 == 0 {
+					_, err = execSQL(se, fmt.Sprintf("delete from t where id = %d", id))
+				}
+			}
+			errCh <- err
+		}(i)
+	}
+	mustExec(t, se, "create index ib on t (b)")
+	close(stop)
+	for i := 0; i < workers; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tblInfo, err := se.(*session).GetInfoSchema().TableByName(model.NewCIStr("test"), model.NewCIStr("t"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := mustExec(t, se, "select count(*) from t")
+	n := countKeys(t, s, tablecodec.EncodeTableIndexPrefix(tblInfo.ID, tblInfo.Indices[0].ID))
+	if fmt.Sprint(n) != rows[0][0] {
+		t.Fatalf("expected %s keys of the index, got %d", rows[0][0], n)
+	}
+}
@@ -0,0 +1,81 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+)
+
+// Error codes.
+const (
+	codeSavepointNotExists       terror.ErrCode = terror.ErrCode(mysql.ErrSpDoesNotExist)
+	codeCantExecuteInReadOnlyTxn terror.ErrCode = terror.ErrCode(mysql.ErrCantExecuteInReadOnlyTransaction)
+	codeQueryInterrupted         terror.ErrCode = terror.ErrCode(mysql.ErrQueryInterrupted)
+	codeNoDB                     terror.ErrCode = terror.ErrCode(mysql.ErrNoDB)
+	codeCannotUser               terror.ErrCode = terror.ErrCode(mysql.ErrCannotUser)
+	codePasswordFormat           terror.ErrCode = terror.ErrCode(mysql.ErrPasswordFormat)
+	codePluginIsNotLoaded        terror.ErrCode = terror.ErrCode(mysql.ErrPluginIsNotLoaded)
+	codeCantCreateUserWithGrant  terror.ErrCode = terror.ErrCode(mysql.ErrCantCreateUserWithGrant)
+	codeNonexistingGrant         terror.ErrCode = terror.ErrCode(mysql.ErrNonexistingGrant)
+	codeNonexistingTableGrant    terror.ErrCode = terror.ErrCode(mysql.ErrNonexistingTableGrant)
+	codeIllegalGrantForTable     terror.ErrCode = terror.ErrCode(mysql.ErrIllegalGrantForTable)
+	codeWrongUsage               terror.ErrCode = terror.ErrCode(mysql.ErrWrongUsage)
+	codeNotSupportedYet          terror.ErrCode = terror.ErrCode(mysql.ErrNotSupportedYet)
+	codeUnknownAuthID            terror.ErrCode = 3523
+	codeRoleNotGranted           terror.ErrCode = 3530
+	codeInfoSchemaChanged        terror.ErrCode = 8028
+)
+
+// Error instances.
+var (
+	errSavepointNotExists       = terror.ClassSession.New(codeSavepointNotExists, "SAVEPOINT %s does not exist")
+	errCantExecuteInReadOnlyTxn = terror.ClassSession.New(codeCantExecuteInReadOnlyTxn, "Cannot execute statement in a READ ONLY transaction.")
+	errQueryInterrupted         = terror.ClassSession.New(codeQueryInterrupted, mysql.MySQLErrName[mysql.ErrQueryInterrupted])
+	errNoDB                     = terror.ClassSession.New(codeNoDB, "No database selected")
+	errCannotUser               = terror.ClassSession.New(codeCannotUser, mysql.MySQLErrName[mysql.ErrCannotUser])
+	errPasswordFormat           = terror.ClassSession.New(codePasswordFormat, mysql.MySQLErrName[mysql.ErrPasswordFormat])
+	errPluginIsNotLoaded        = terror.ClassSession.New(codePluginIsNotLoaded, mysql.MySQLErrName[mysql.ErrPluginIsNotLoaded])
+	errCantCreateUserWithGrant  = terror.ClassSession.New(codeCantCreateUserWithGrant, mysql.MySQLErrName[mysql.ErrCantCreateUserWithGrant])
+	errNonexistingGrant         = terror.ClassSession.New(codeNonexistingGrant, mysql.MySQLErrName[mysql.ErrNonexistingGrant])
+	errNonexistingTableGrant    = terror.ClassSession.New(codeNonexistingTableGrant, mysql.MySQLErrName[mysql.ErrNonexistingTableGrant])
+	errIllegalGrantForTable     = terror.ClassSession.New(codeIllegalGrantForTable, mysql.MySQLErrName[mysql.ErrIllegalGrantForTable])
+	errWrongUsage               = terror.ClassSession.New(codeWrongUsage, mysql.MySQLErrName[mysql.ErrWrongUsage])
+	errNotSupportedYet          = terror.ClassSession.New(codeNotSupportedYet, mysql.MySQLErrName[mysql.ErrNotSupportedYet])
+	errUnknownAuthID            = terror.ClassSession.New(codeUnknownAuthID, "Unknown authorization ID `%s`@`%s`")
+	errRoleNotGranted           = terror.ClassSession.New(codeRoleNotGranted, "`%s`@`%s` is not granted to `%s`@`%s`")
+	errInfoSchemaChanged        = terror.ClassSession.New(codeInfoSchemaChanged, "Information schema is changed. [try again later]")
+)
+
+func init() {
+	sessionMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeSavepointNotExists:       mysql.ErrSpDoesNotExist,
+		codeCantExecuteInReadOnlyTxn: mysql.ErrCantExecuteInReadOnlyTransaction,
+		codeQueryInterrupted:         mysql.ErrQueryInterrupted,
+		codeNoDB:                     mysql.ErrNoDB,
+		codeCannotUser:               mysql.ErrCannotUser,
+		codePasswordFormat:           mysql.ErrPasswordFormat,
+		codePluginIsNotLoaded:        mysql.ErrPluginIsNotLoaded,
+		codeCantCreateUserWithGrant:  mysql.ErrCantCreateUserWithGrant,
+		codeNonexistingGrant:         mysql.ErrNonexistingGrant,
+		codeNonexistingTableGrant:    mysql.ErrNonexistingTableGrant,
+		codeIllegalGrantForTable:     mysql.ErrIllegalGrantForTable,
+		codeWrongUsage:               mysql.ErrWrongUsage,
+		codeNotSupportedYet:          mysql.ErrNotSupportedYet,
+		codeUnknownAuthID:            uint16(codeUnknownAuthID),
+		codeRoleNotGranted:           uint16(codeRoleNotGranted),
+		codeInfoSchemaChanged:        uint16(codeInfoSchemaChanged),
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassSession] = sessionMySQLErrCodes
+}
@@ -0,0 +1,86 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/session/tidb.go
+//
+
+package session
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/domain"
+	"fedb/kv"
+)
+
+type domainMap struct {
+	domains map[string]*domain.Domain
+	mu      sync.Mutex
+}
+
+func (dm *domainMap) Get(store kv.Storage) (d *domain.Domain, err error) {
+	key := store.UUID()
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	d = dm.domains[key]
+	if d != nil {
+		return
+	}
+
+	log.Infof("store %v new domain", store.UUID())
+	d = domain.NewDomain(store)
+	if err = d.Init(); err != nil {
+		d.Close()
+		log.Errorf("[ddl] init domain failed %v", errors.ErrorStack(errors.Trace(err)))
+		return nil, errors.Trace(err)
+	}
+	dm.domains[key] = d
+
+	return
+}
+
+func (dm *domainMap) Delete(store kv.Storage) {
+	dm.mu.Lock()
+	delete(dm.domains, store.UUID())
+	dm.mu.Unlock()
+}
+
+var (
+	domap = &domainMap{
+		domains: map[string]*domain.Domain{},
+	}
+)
+
+// BootstrapSession loads the schema of the store, it must be called before the sessions are created.
+// The system tables are created if the store is new, and the privilege tables are loaded.
+func BootstrapSession(store kv.Storage) (*domain.Domain, error) {
+	dom, err := domap.Get(store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s, err := createSession(store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer s.Close()
+	if err = bootstrap(s); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = dom.PrivilegeHandle().Update(s); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return dom, nil
+}
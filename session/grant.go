@@ -0,0 +1,313 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	goctx "golang.org/x/net/context"
+
+	plannercore "fedb/planner/core"
+	"fedb/privilege"
+	"fedb/util/chunk"
+)
+
+// privChange is the privileges GRANT or REVOKE changes at its level, and the ones it changes on
+// the columns of the table, the columns are keyed by their lower case names.
+type privChange struct {
+	level   ast.GrantLevelType
+	db      string
+	table   string
+	privs   mysql.PrivilegeType
+	columns map[string]mysql.PrivilegeType
+	// revokeAll is set by REVOKE ALL, the column privileges are revoked too.
+	revokeAll bool
+	// grantor is the account granting the privileges, it's kept in tables_priv.
+	grantor string
+}
+
+// executeGrant grants the privileges to the users, they are added to the ones the users have.
+// The users must exist, GRANT doesn't create them.
+func (s *session) executeGrant(ctx goctx.Context, stmt *ast.GrantStmt) error {
+	change, err := s.buildPrivChange(stmt.Level, stmt.Privs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if stmt.WithGrant {
+		change.privs |= mysql.GrantPriv
+	}
+	return s.updateUsers(ctx, func(se *session) error {
+		for _, spec := range stmt.Users {
+			user, host, err := s.resolveUser(spec.User)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if _, exists, err := getUserPlugin(ctx, se, user, host); err != nil {
+				return errors.Trace(err)
+			} else if !exists {
+				return errCantCreateUserWithGrant
+			}
+			if err = applyPrivChange(ctx, se, user, host, change, true); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	})
+}
+
+// executeRevoke revokes the privileges from the users, REVOKE ALL revokes GRANT OPTION too.
+func (s *session) executeRevoke(ctx goctx.Context, stmt *ast.RevokeStmt) error {
+	change, err := s.buildPrivChange(stmt.Level, stmt.Privs)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if change.revokeAll {
+		change.privs |= mysql.GrantPriv
+	}
+	return s.updateUsers(ctx, func(se *session) error {
+		for _, spec := range stmt.Users {
+			user, host, err := s.resolveUser(spec.User)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if _, exists, err := getUserPlugin(ctx, se, user, host); err != nil {
+				return errors.Trace(err)
+			} else if !exists {
+				return errNonexistingGrant.GenWithStackByArgs(user, host)
+			}
+			if err = applyPrivChange(ctx, se, user, host, change, false); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	})
+}
+
+// buildPrivChange checks the privileges can be granted at the level, ALL means all of them but
+// GRANT OPTION. The table and the columns must exist.
+func (s *session) buildPrivChange(level *ast.GrantLevel, privs []*ast.PrivElem) (*privChange, error) {
+	db, table, err := plannercore.GrantObject(s, level)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	change := &privChange{level: level.Level, db: db, table: table, columns: make(map[string]mysql.PrivilegeType)}
+	if user := s.sessionVars.User; user != nil {
+		change.grantor = user.AuthUsername + "@" + user.AuthHostname
+	}
+	var tblInfo *model.TableInfo
+	if level.Level == ast.GrantLevelTable {
+		if tblInfo, err = s.GetInfoSchema().TableByName(model.NewCIStr(db), model.NewCIStr(table)); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	levelPrivs := privilege.LevelPrivs(level.Level)
+	for _, item := range privs {
+		switch {
+		case len(item.Cols) > 0:
+			if tblInfo == nil || !containsPriv(mysql.AllColumnPrivs, item.Priv) {
+				return nil, errIllegalGrantForTable
+			}
+			for _, col := range item.Cols {
+				if model.FindColumnInfo(tblInfo.Columns, col.Name.L) == nil {
+					return nil, plannercore.ErrUnknownColumn.GenWithStackByArgs(col.Name.O, "field list")
+				}
+				change.columns[col.Name.L] |= item.Priv
+			}
+		case item.Priv == mysql.AllPriv:
+			for _, priv := range levelPrivs {
+				change.privs |= priv
+			}
+			change.privs &^= mysql.GrantPriv
+			change.revokeAll = true
+		case item.Priv == 0:
+			// USAGE means no privilege.
+		case !containsPriv(levelPrivs, item.Priv):
+			if level.Level == ast.GrantLevelDB {
+				return nil, errWrongUsage.GenWithStackByArgs("DB GRANT", "GLOBAL PRIVILEGES")
+			}
+			return nil, errIllegalGrantForTable
+		default:
+			change.privs |= item.Priv
+		}
+	}
+	return change, nil
+}
+
+func containsPriv(privs []mysql.PrivilegeType, priv mysql.PrivilegeType) bool {
+	for _, p := range privs {
+		if p == priv {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPrivChange grants or revokes the privileges of the account in the privilege table of the
+// level, the rows without any privilege are deleted.
+func applyPrivChange(ctx goctx.Context, se *session, user, host string, change *privChange, grant bool) error {
+	switch change.level {
+	case ast.GrantLevelGlobal:
+		return errors.Trace(changeGlobalPrivs(ctx, se, user, host, change, grant))
+	case ast.GrantLevelDB:
+		return errors.Trace(changeDBPrivs(ctx, se, user, host, change, grant))
+	}
+	return errors.Trace(changeTablePrivs(ctx, se, user, host, change, grant))
+}
+
+// updatePrivs returns the privileges after the change is granted or revoked.
+func updatePrivs(privs, change mysql.PrivilegeType, grant bool) mysql.PrivilegeType {
+	if grant {
+		return privs | change
+	}
+	return privs &^ change
+}
+
+func changeGlobalPrivs(ctx goctx.Context, se *session, user, host string, change *privChange, grant bool) error {
+	rows, _, err := se.ExecRestrictedSQL(ctx, "SELECT "+privilege.PrivColumns(mysql.AllGlobalPrivs)+
+		" FROM mysql.user WHERE Host = ? AND User = ?", host, user)
+	if err != nil || len(rows) == 0 {
+		return errors.Trace(err)
+	}
+	privs := updatePrivs(decodeEnumPrivs(rows[0], mysql.AllGlobalPrivs), change.privs, grant)
+	sql, args := encodeEnumPrivs(privs, mysql.AllGlobalPrivs)
+	_, _, err = se.ExecRestrictedSQL(ctx, "UPDATE mysql.user SET "+sql+" WHERE Host = ? AND User = ?",
+		append(args, host, user)...)
+	return errors.Trace(err)
+}
+
+func changeDBPrivs(ctx goctx.Context, se *session, user, host string, change *privChange, grant bool) error {
+	rows, _, err := se.ExecRestrictedSQL(ctx, "SELECT "+privilege.PrivColumns(mysql.AllDBPrivs)+
+		" FROM mysql.db WHERE Host = ? AND DB = ? AND User = ?", host, change.db, user)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var privs mysql.PrivilegeType
+	if len(rows) > 0 {
+		privs = decodeEnumPrivs(rows[0], mysql.AllDBPrivs)
+	} else if !grant {
+		return errNonexistingGrant.GenWithStackByArgs(user, host)
+	}
+	privs = updatePrivs(privs, change.privs, grant)
+	if privs == 0 {
+		_, _, err = se.ExecRestrictedSQL(ctx, "DELETE FROM mysql.db WHERE Host = ? AND DB = ? AND User = ?",
+			host, change.db, user)
+		return errors.Trace(err)
+	}
+	_, args := encodeEnumPrivs(privs, mysql.AllDBPrivs)
+	_, _, err = se.ExecRestrictedSQL(ctx, "REPLACE INTO mysql.db (Host, DB, User, "+
+		privilege.PrivColumns(mysql.AllDBPrivs)+") VALUES (?, ?, ?"+strings.Repeat(", ?", len(args))+")",
+		append([]interface{}{host, change.db, user}, args...)...)
+	return errors.Trace(err)
+}
+
+// changeTablePrivs changes the privileges on the table and its columns, the column privileges of
+// tables_priv are the ones granted on any column. Revoking a privilege on the table revokes it on
+// the columns too.
+func changeTablePrivs(ctx goctx.Context, se *session, user, host string, change *privChange, grant bool) error {
+	rows, _, err := se.ExecRestrictedSQL(ctx, "SELECT Table_priv FROM mysql.tables_priv "+
+		"WHERE Host = ? AND DB = ? AND User = ? AND Table_name = ?", host, change.db, user, change.table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	var tablePriv mysql.PrivilegeType
+	if len(rows) > 0 {
+		tablePriv = privilege.DecodeSetPrivs(rows[0].GetSet(0).Name)
+	} else if !grant {
+		return errNonexistingTableGrant.GenWithStackByArgs(user, host, change.table)
+	}
+	tablePriv = updatePrivs(tablePriv, change.privs, grant)
+
+	rows, _, err = se.ExecRestrictedSQL(ctx, "SELECT Column_name, Column_priv FROM mysql.columns_priv "+
+		"WHERE Host = ? AND DB = ? AND User = ? AND Table_name = ?", host, change.db, user, change.table)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	columns := make(map[string]mysql.PrivilegeType, len(rows)+len(change.columns))
+	for _, row := range rows {
+		columns[strings.ToLower(row.GetString(0))] = privilege.DecodeSetPrivs(row.GetSet(1).Name)
+	}
+	if !grant {
+		for col, privs := range columns {
+			if change.revokeAll {
+				privs = 0
+			}
+			columns[col] = privs &^ change.privs
+		}
+	}
+	for col, privs := range change.columns {
+		columns[col] = updatePrivs(columns[col], privs, grant)
+	}
+
+	var columnPriv mysql.PrivilegeType
+	for col, privs := range columns {
+		columnPriv |= privs
+		if err = saveColumnPrivs(ctx, se, user, host, change, col, privs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if tablePriv == 0 && columnPriv == 0 {
+		_, _, err = se.ExecRestrictedSQL(ctx, "DELETE FROM mysql.tables_priv "+
+			"WHERE Host = ? AND DB = ? AND User = ? AND Table_name = ?", host, change.db, user, change.table)
+		return errors.Trace(err)
+	}
+	_, _, err = se.ExecRestrictedSQL(ctx, "REPLACE INTO mysql.tables_priv "+
+		"(Host, DB, User, Table_name, Grantor, Table_priv, Column_priv) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		host, change.db, user, change.table, change.grantor,
+		privilege.EncodeSetPrivs(tablePriv, mysql.AllTablePrivs), privilege.EncodeSetPrivs(columnPriv, mysql.AllColumnPrivs))
+	return errors.Trace(err)
+}
+
+func saveColumnPrivs(ctx goctx.Context, se *session, user, host string, change *privChange, col string,
+	privs mysql.PrivilegeType) error {
+	if privs == 0 {
+		_, _, err := se.ExecRestrictedSQL(ctx, "DELETE FROM mysql.columns_priv "+
+			"WHERE Host = ? AND DB = ? AND User = ? AND Table_name = ? AND Column_name = ?",
+			host, change.db, user, change.table, col)
+		return errors.Trace(err)
+	}
+	_, _, err := se.ExecRestrictedSQL(ctx, "REPLACE INTO mysql.columns_priv "+
+		"(Host, DB, User, Table_name, Column_name, Column_priv) VALUES (?, ?, ?, ?, ?, ?)",
+		host, change.db, user, change.table, col, privilege.EncodeSetPrivs(privs, mysql.AllColumnPrivs))
+	return errors.Trace(err)
+}
+
+// decodeEnumPrivs returns the privileges of the 'Y' columns, they are the columns of the privileges in order.
+func decodeEnumPrivs(row chunk.Row, privs []mysql.PrivilegeType) mysql.PrivilegeType {
+	var decoded mysql.PrivilegeType
+	for i, priv := range privs {
+		if row.GetEnum(i).String() == "Y" {
+			decoded |= priv
+		}
+	}
+	return decoded
+}
+
+// encodeEnumPrivs returns the assignments of the privilege columns and their values.
+func encodeEnumPrivs(privs mysql.PrivilegeType, allPrivs []mysql.PrivilegeType) (string, []interface{}) {
+	assigns := make([]string, 0, len(allPrivs))
+	args := make([]interface{}, 0, len(allPrivs))
+	for _, priv := range allPrivs {
+		assigns = append(assigns, mysql.Priv2UserCol[priv]+" = ?")
+		if privs&priv > 0 {
+			args = append(args, "Y")
+		} else {
+			args = append(args, "N")
+		}
+	}
+	return strings.Join(assigns, ", "), args
+}
@@ -0,0 +1,139 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/session.go
+//
+
+package session
+
+import (
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/executor"
+	"fedb/util/sqlexec"
+)
+
+// PrepareStmt parses and plans a statement with parameter markers, the plan is thrown away as
+// the statement is planned again with the values of the parameters when it's executed.
+func (s *session) PrepareStmt(sql string) (stmtID uint32, paramCount int, fields []*ast.ResultField, err error) {
+	log.Debugf("prepare: %v", sql)
+
+	charsetInfo, collation := s.sessionVars.GetCharsetInfo()
+	stmtNodes, err := s.parser.Parse(sql, charsetInfo, collation)
+	if err != nil {
+		return 0, 0, nil, errors.AddStack(err)
+	}
+	if len(stmtNodes) != 1 {
+		return 0, 0, nil, executor.ErrPrepareMulti
+	}
+	stmtNode := stmtNodes[0]
+	s.resetStmtCtx(stmtNode)
+
+	// The uncorrelated subqueries are evaluated when the statement is planned, which may
+	// begin a transaction, so the statement is finished like the executed ones.
+	prepared, fields, err := executor.Prepare(s, stmtNode)
+	if err = s.finishStmt(goctx.Background(), err); err != nil {
+		return 0, 0, nil, errors.Trace(err)
+	}
+	stmtID = s.sessionVars.GetNextPreparedStmtID()
+	s.sessionVars.PreparedStmts[stmtID] = prepared
+	return stmtID, len(prepared.Params), fields, nil
+}
+
+// checkArgs makes sure all the arguments' types are known and can be handled.
+// integer types are converted to int64 and uint64, float32 is converted to float64,
+// time.Time is converted to types.Time, time.Duration is converted to types.Duration,
+// other known types are leaved as it is.
+func checkArgs(args ...interface{}) error {
+	for i, v := range args {
+		switch x := v.(type) {
+		case bool:
+			if x {
+				args[i] = int64(1)
+			} else {
+				args[i] = int64(0)
+			}
+		case int8:
+			args[i] = int64(x)
+		case int16:
+			args[i] = int64(x)
+		case int32:
+			args[i] = int64(x)
+		case int:
+			args[i] = int64(x)
+		case uint8:
+			args[i] = uint64(x)
+		case uint16:
+			args[i] = uint64(x)
+		case uint32:
+			args[i] = uint64(x)
+		case uint:
+			args[i] = uint64(x)
+		case float32:
+			args[i] = float64(x)
+		case int64:
+		case uint64:
+		case float64:
+		case string:
+		case []byte:
+		case time.Duration:
+			args[i] = types.Duration{Duration: x}
+		case time.Time:
+			args[i] = types.Time{Time: types.FromGoTime(x), Type: mysql.TypeDatetime}
+		case nil:
+		default:
+			return errors.Errorf("cannot use arg[%d] (type %T):unsupported type", i, v)
+		}
+	}
+	return nil
+}
+
+// ExecutePreparedStmt executes a prepared statement like a statement of a query.
+func (s *session) ExecutePreparedStmt(ctx goctx.Context, stmtID uint32, args ...interface{}) (rs sqlexec.RecordSet, err error) {
+	defer func() {
+		if err != nil {
+			// The error is shown by SHOW ERRORS and SHOW WARNINGS.
+			s.sessionVars.StmtCtx.AppendError(err)
+		}
+	}()
+	prepared, ok := s.sessionVars.PreparedStmts[stmtID]
+	if !ok {
+		return nil, executor.ErrStmtNotFound.GenWithStackByArgs(stmtID, "EXECUTE")
+	}
+	log.Debugf("execute: %v", secureText(prepared.Stmt))
+
+	if err = checkArgs(args...); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = executor.SetPreparedParams(prepared, args); err != nil {
+		return nil, errors.Trace(err)
+	}
+	rs, err = s.runStmt(ctx, prepared.Stmt, mysql.ComStmtExecute)
+	return rs, errors.Trace(err)
+}
+
+// DropPreparedStmt removes a prepared statement.
+func (s *session) DropPreparedStmt(stmtID uint32) error {
+	if _, ok := s.sessionVars.PreparedStmts[stmtID]; !ok {
+		return executor.ErrStmtNotFound.GenWithStackByArgs(stmtID, "DEALLOCATE PREPARE")
+	}
+	delete(s.sessionVars.PreparedStmts, stmtID)
+	return nil
+}
@@ -0,0 +1,105 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/terror"
+	goctx "golang.org/x/net/context"
+
+	"fedb/util/chunk"
+	"fedb/util/sqlexec"
+)
+
+// execStmtResult is the record set of a statement, the statement is finished
+// when the record set is closed, so that an autocommit transaction lasts until
+// all the rows are read.
+type execStmtResult struct {
+	sqlexec.RecordSet
+
+	se      *session
+	ctx     goctx.Context
+	lastErr error
+}
+
+func (rs *execStmtResult) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	err := rs.RecordSet.Next(goCtx, req)
+	if err != nil {
+		if goCtx.Err() == goctx.Canceled {
+			err = errQueryInterrupted.GenWithStackByArgs()
+		}
+		rs.lastErr = err
+	}
+	return errors.Trace(err)
+}
+
+func (rs *execStmtResult) Close() error {
+	closeErr := rs.RecordSet.Close()
+	if err := rs.se.finishStmt(rs.ctx, rs.lastErr); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(closeErr)
+}
+
+// bufferedRecordSet is a record set whose rows are read in advance, the statement
+// is finished when all the rows are read.
+type bufferedRecordSet struct {
+	fields   []*ast.ResultField
+	newBatch func() *chunk.RecordBatch
+	batches  []*chunk.RecordBatch
+	cursor   int
+}
+
+// bufferRecordSet reads all the rows of the record set and closes it.
+func bufferRecordSet(ctx goctx.Context, rs sqlexec.RecordSet) (sqlexec.RecordSet, error) {
+	buffered := &bufferedRecordSet{fields: rs.Fields(), newBatch: rs.NewRecordBatch}
+	for {
+		req := rs.NewRecordBatch()
+		if err := rs.Next(ctx, req); err != nil {
+			terror.Call(rs.Close)
+			return nil, errors.Trace(err)
+		}
+		if req.NumRows() == 0 {
+			break
+		}
+		buffered.batches = append(buffered.batches, req)
+	}
+	if err := rs.Close(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return buffered, nil
+}
+
+func (rs *bufferedRecordSet) Fields() []*ast.ResultField {
+	return rs.fields
+}
+
+func (rs *bufferedRecordSet) Next(goCtx goctx.Context, req *chunk.RecordBatch) error {
+	req.Reset()
+	if rs.cursor < len(rs.batches) {
+		req.Append(rs.batches[rs.cursor].Chunk, 0, rs.batches[rs.cursor].NumRows())
+		rs.cursor++
+	}
+	return nil
+}
+
+func (rs *bufferedRecordSet) NewRecordBatch() *chunk.RecordBatch {
+	return rs.newBatch()
+}
+
+func (rs *bufferedRecordSet) Close() error {
+	rs.cursor = 0
+	return nil
+}
@@ -0,0 +1,265 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/auth"
+	goctx "golang.org/x/net/context"
+
+	"fedb/config"
+	"fedb/parser"
+)
+
+// executeCreateRole creates the roles, they are locked accounts without password. None is created
+// if any of them exists, unless IF NOT EXISTS is given and the existing ones are skipped.
+func (s *session) executeCreateRole(ctx goctx.Context, stmt *parser.CreateRoleStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		var failedRoles []string
+		for _, role := range stmt.Roles {
+			user, host := role.Username, normalizeHost(role.Hostname)
+			_, exists, err := getUserPlugin(ctx, se, user, host)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if exists {
+				if !stmt.IfNotExists {
+					failedRoles = append(failedRoles, userString(user, host))
+				}
+				continue
+			}
+			_, _, err = se.ExecRestrictedSQL(ctx, "INSERT INTO mysql.user "+
+				"(Host, User, authentication_string, plugin, account_locked) VALUES (?, ?, '', ?, 'Y')",
+				host, user, config.GetGlobalConfig().DefaultAuthPlugin)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if len(failedRoles) > 0 {
+			return errCannotUser.GenWithStackByArgs("CREATE ROLE", strings.Join(failedRoles, ","))
+		}
+		return nil
+	})
+}
+
+// executeDropRole drops the roles, they are revoked from the accounts they are granted to. None
+// is dropped if any of them doesn't exist, unless IF EXISTS is given and the missing ones are skipped.
+func (s *session) executeDropRole(ctx goctx.Context, stmt *parser.DropRoleStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		var failedRoles []string
+		for _, role := range stmt.Roles {
+			user, host := role.Username, normalizeHost(role.Hostname)
+			_, exists, err := getUserPlugin(ctx, se, user, host)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if !exists {
+				if !stmt.IfExists {
+					failedRoles = append(failedRoles, userString(user, host))
+				}
+				continue
+			}
+			if err = deleteAccount(ctx, se, user, host); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if len(failedRoles) > 0 {
+			return errCannotUser.GenWithStackByArgs("DROP ROLE", strings.Join(failedRoles, ","))
+		}
+		return nil
+	})
+}
+
+// deleteAccount deletes the user or the role with its privileges, the roles granted to it, and
+// the grants of it as a role.
+func deleteAccount(ctx goctx.Context, se *session, user, host string) error {
+	for _, sql := range []string{
+		"DELETE FROM mysql.user WHERE Host = ? AND User = ?",
+		"DELETE FROM mysql.db WHERE Host = ? AND User = ?",
+		"DELETE FROM mysql.tables_priv WHERE Host = ? AND User = ?",
+		"DELETE FROM mysql.columns_priv WHERE Host = ? AND User = ?",
+		"DELETE FROM mysql.role_edges WHERE FROM_HOST = ? AND FROM_USER = ?",
+		"DELETE FROM mysql.role_edges WHERE TO_HOST = ? AND TO_USER = ?",
+		"DELETE FROM mysql.default_roles WHERE HOST = ? AND USER = ?",
+		"DELETE FROM mysql.default_roles WHERE DEFAULT_ROLE_HOST = ? AND DEFAULT_ROLE_USER = ?",
+	} {
+		if _, _, err := se.ExecRestrictedSQL(ctx, sql, host, user); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// executeGrantRole grants the roles to the users, WITH ADMIN OPTION is kept for SHOW GRANTS.
+func (s *session) executeGrantRole(ctx goctx.Context, stmt *parser.GrantRoleStmt) error {
+	adminOption := "N"
+	if stmt.WithAdminOption {
+		adminOption = "Y"
+	}
+	return s.updateUsers(ctx, func(se *session) error {
+		roles, err := s.resolveAccounts(ctx, se, stmt.Roles)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		users, err := s.resolveAccounts(ctx, se, stmt.Users)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, user := range users {
+			for _, role := range roles {
+				_, _, err = se.ExecRestrictedSQL(ctx, "REPLACE INTO mysql.role_edges "+
+					"(FROM_HOST, FROM_USER, TO_HOST, TO_USER, WITH_ADMIN_OPTION) VALUES (?, ?, ?, ?, ?)",
+					role.Hostname, role.Username, user.Hostname, user.Username, adminOption)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// executeRevokeRole revokes the roles from the users, the roles must be granted to them.
+func (s *session) executeRevokeRole(ctx goctx.Context, stmt *parser.RevokeRoleStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		roles, err := s.resolveAccounts(ctx, se, stmt.Roles)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		users, err := s.resolveAccounts(ctx, se, stmt.Users)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, user := range users {
+			granted := s.dom.PrivilegeHandle().Get().GrantedRoles(user.Username, user.Hostname)
+			for _, role := range roles {
+				if !containsAccount(granted, role) {
+					return errRoleNotGranted.GenWithStackByArgs(role.Username, role.Hostname, user.Username, user.Hostname)
+				}
+				_, _, err = se.ExecRestrictedSQL(ctx, "DELETE FROM mysql.role_edges "+
+					"WHERE FROM_HOST = ? AND FROM_USER = ? AND TO_HOST = ? AND TO_USER = ?",
+					role.Hostname, role.Username, user.Hostname, user.Username)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// executeSetRole activates the roles in the session, they must be granted to the account the
+// session is authenticated as.
+func (s *session) executeSetRole(stmt *parser.SetRoleStmt) error {
+	u := s.sessionVars.User
+	if u == nil {
+		return nil
+	}
+	pm := s.dom.PrivilegeHandle().Get()
+	granted := pm.GrantedRoles(u.AuthUsername, u.AuthHostname)
+	var roles []*auth.UserIdentity
+	switch stmt.Tp {
+	case parser.SetRoleDefault:
+		roles = pm.GetDefaultRoles(u.AuthUsername, u.AuthHostname)
+	case parser.SetRoleAll:
+		roles = granted
+	case parser.SetRoleAllExcept:
+		for _, role := range granted {
+			if !containsAccount(stmt.Roles, role) {
+				roles = append(roles, role)
+			}
+		}
+	case parser.SetRoleList:
+		for _, role := range stmt.Roles {
+			if !containsAccount(granted, role) {
+				return errRoleNotGranted.GenWithStackByArgs(role.Username, role.Hostname, u.AuthUsername, u.AuthHostname)
+			}
+			roles = append(roles, role)
+		}
+	}
+	s.sessionVars.ActiveRoles = roles
+	return nil
+}
+
+// executeSetDefaultRole sets the roles activated when the users log in, they must be granted to
+// the users. The default roles set before are replaced.
+func (s *session) executeSetDefaultRole(ctx goctx.Context, stmt *parser.SetDefaultRoleStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		users, err := s.resolveAccounts(ctx, se, stmt.Users)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		for _, user := range users {
+			granted := s.dom.PrivilegeHandle().Get().GrantedRoles(user.Username, user.Hostname)
+			var roles []*auth.UserIdentity
+			switch stmt.Tp {
+			case parser.SetRoleAll:
+				roles = granted
+			case parser.SetRoleList:
+				for _, role := range stmt.Roles {
+					if !containsAccount(granted, role) {
+						return errRoleNotGranted.GenWithStackByArgs(role.Username, role.Hostname, user.Username, user.Hostname)
+					}
+					roles = append(roles, role)
+				}
+			}
+			_, _, err = se.ExecRestrictedSQL(ctx, "DELETE FROM mysql.default_roles WHERE HOST = ? AND USER = ?",
+				user.Hostname, user.Username)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			for _, role := range roles {
+				_, _, err = se.ExecRestrictedSQL(ctx, "REPLACE INTO mysql.default_roles "+
+					"(HOST, USER, DEFAULT_ROLE_HOST, DEFAULT_ROLE_USER) VALUES (?, ?, ?, ?)",
+					user.Hostname, user.Username, normalizeHost(role.Hostname), role.Username)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// resolveAccounts resolves the users or the roles, they must exist.
+func (s *session) resolveAccounts(ctx goctx.Context, se *session, accounts []*auth.UserIdentity) ([]*auth.UserIdentity, error) {
+	resolved := make([]*auth.UserIdentity, 0, len(accounts))
+	for _, account := range accounts {
+		user, host, err := s.resolveUser(account)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		_, exists, err := getUserPlugin(ctx, se, user, host)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !exists {
+			return nil, errUnknownAuthID.GenWithStackByArgs(user, host)
+		}
+		resolved = append(resolved, &auth.UserIdentity{Username: user, Hostname: host})
+	}
+	return resolved, nil
+}
+
+// containsAccount checks whether the account is one of the accounts, the hosts are case insensitive.
+func containsAccount(accounts []*auth.UserIdentity, account *auth.UserIdentity) bool {
+	for _, a := range accounts {
+		if a.Username == account.Username && strings.EqualFold(a.Hostname, account.Hostname) {
+			return true
+		}
+	}
+	return false
+}
@@ -17,47 +17,123 @@
 package session
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/opentracing/opentracing-go"
 	goctx "golang.org/x/net/context"
 
 	"github.com/pingcap/errors"
-	"github.com/pingcap/parser"
 	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
 	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	driver "github.com/pingcap/tidb/types/parser_driver"
 	log "github.com/sirupsen/logrus"
 
+	"fedb/config"
+	"fedb/domain"
+	"fedb/executor"
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/parser"
+	plannercore "fedb/planner/core"
+	"fedb/privilege"
+	"fedb/sessionctx"
 	"fedb/sessionctx/variable"
+	"fedb/util"
+	"fedb/util/chunk"
 	"fedb/util/sqlexec"
 )
 
 // Session is the session interface
 type Session interface {
 	Execute(goctx.Context, string) ([]sqlexec.RecordSet, error) // Execute a sql statement.
+	// Parse parses the statements of a query.
+	Parse(ctx goctx.Context, sql string) ([]ast.StmtNode, error)
+	// ExecuteStmt executes a statement, its record set must be closed before the next statement.
+	ExecuteStmt(ctx goctx.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error)
 
 	SetConnectionID(uint64) Session
 	SetCollation(coID int) error
 	SetClientCapability(uint32) Session
+	SetTLSState(*tls.ConnectionState)
+	Status() uint16       // Flag of current status, such as autocommit.
+	LastInsertID() uint64 // LastInsertID is the last inserted auto_increment ID.
+	AffectedRows() uint64 // Affected rows by latest executed stmt.
+	WarningCount() uint16 // WarningCount is the number of the warnings of the last statement.
+	SetSessionManager(util.SessionManager)
+	// SetProcessInfo sets the command the session is running, it's shown by the processlist.
+	SetProcessInfo(sql string, t time.Time, command byte)
+	// ShowProcess returns the information of the session for the processlist.
+	ShowProcess() util.ProcessInfo
+	// FieldList returns the columns of a table in the current database.
+	FieldList(tableName string) ([]*ast.ResultField, error)
+	// PrepareStmt prepares a statement, the fields are returned if it returns rows.
+	PrepareStmt(sql string) (stmtID uint32, paramCount int, fields []*ast.ResultField, err error)
+	// ExecutePreparedStmt executes a prepared statement with the values of its parameters.
+	ExecutePreparedStmt(ctx goctx.Context, stmtID uint32, args ...interface{}) (sqlexec.RecordSet, error)
+	// DropPreparedStmt removes a prepared statement.
+	DropPreparedStmt(stmtID uint32) error
+	// AuthPlugin returns the auth plugin of the account the user connecting from the host is
+	// authenticated as, it's empty if any plugin is accepted.
+	AuthPlugin(user *auth.UserIdentity) string
+	// Auth verifies the user connecting from the host by the password scrambled with the salt.
+	Auth(user *auth.UserIdentity, authentication []byte, salt []byte) bool
+	// AuthWithPassword verifies the user connecting from the host by the password in clear text.
+	AuthWithPassword(user *auth.UserIdentity, password string) bool
 
 	Close()
 }
 
 type session struct {
-	//TODO: store
+	store       kv.Storage
+	dom         *domain.Domain
 	parser      *parser.Parser
 	sessionVars *variable.SessionVars
+
+	txn        *TxnState
+	savepoints []savepoint
+
+	sessionManager util.SessionManager
+	// processInfo is read by the other sessions, so it's replaced atomically.
+	processInfo atomic.Value
 }
 
 var (
-	_ Session = (*session)(nil)
+	_ Session            = (*session)(nil)
+	_ sessionctx.Context = (*session)(nil)
+
+	_ sqlexec.RestrictedSQLExecutor = (*session)(nil)
 )
 
 // CreateSession creates a new session environment.
-func CreateSession() (Session, error) {
+func CreateSession(store kv.Storage) (Session, error) {
+	s, err := createSession(store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return s, nil
+}
+
+// createSession creates a session, the internal sessions which execute the statements of
+// the system tables are created by it too.
+func createSession(store kv.Storage) (*session, error) {
+	dom, err := domap.Get(store)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
 	s := &session{
-		//TODO: store
+		store:       store,
+		dom:         dom,
 		parser:      parser.New(),
 		sessionVars: variable.NewSessionVars(),
 	}
@@ -74,6 +150,11 @@ func (s *session) SetClientCapability(capability uint32) Session {
 	return s
 }
 
+// SetTLSState sets the TLS state of the connection, it's nil if the connection isn't over TLS.
+func (s *session) SetTLSState(tlsState *tls.ConnectionState) {
+	s.sessionVars.TLSConnectionState = tlsState
+}
+
 func (s *session) SetCollation(coID int) error {
 	cs, co, err := charset.GetCharsetInfoByID(coID)
 	if err != nil {
@@ -86,49 +167,485 @@ func (s *session) SetCollation(coID int) error {
 	return nil
 }
 
-func (s *session) Close() {
-	// statsCollector
-	// RoolbackTxn
+// AuthPlugin returns the auth plugin of the account the user connecting from the host is
+// authenticated as, it's the default plugin if no account matches, and it's empty if the
+// privilege tables are skipped.
+func (s *session) AuthPlugin(user *auth.UserIdentity) string {
+	if config.GetGlobalConfig().SkipGrantTable {
+		return ""
+	}
+	if record, _ := s.matchUser(user); record != nil {
+		return record.Plugin
+	}
+	return config.GetGlobalConfig().DefaultAuthPlugin
 }
 
-type visitor struct{}
+// Auth verifies the user connecting from the host by the password scrambled with the salt.
+func (s *session) Auth(user *auth.UserIdentity, authentication []byte, salt []byte) bool {
+	return s.authenticate(user, func(record *privilege.UserRecord) bool {
+		return s.dom.PrivilegeHandle().VerifyScramble(record, authentication, salt)
+	})
+}
 
-func (v *visitor) Enter(in ast.Node) (out ast.Node, skipChildren bool) {
-	fmt.Printf("Enter %T\n", in)
-	return in, false
+// AuthWithPassword verifies the user connecting from the host by the password in clear text.
+func (s *session) AuthWithPassword(user *auth.UserIdentity, password string) bool {
+	return s.authenticate(user, func(record *privilege.UserRecord) bool {
+		return s.dom.PrivilegeHandle().VerifyPassword(record, password)
+	})
 }
 
-func (v *visitor) Leave(in ast.Node) (out ast.Node, ok bool) {
-	fmt.Printf("Leave: %T\n", in)
-	return in, true
+// authenticate verifies the user by the account it matches and checks the TLS the account requires,
+// and sets the user of the session and activates its default roles if it succeeds. The locked
+// accounts, such as the roles, can't be logged in as. Every user is accepted if the privilege tables are skipped.
+func (s *session) authenticate(user *auth.UserIdentity, verify func(record *privilege.UserRecord) bool) bool {
+	if config.GetGlobalConfig().SkipGrantTable {
+		s.sessionVars.User = &auth.UserIdentity{
+			Username:     user.Username,
+			Hostname:     user.Hostname,
+			AuthUsername: user.Username,
+			AuthHostname: "%",
+		}
+		return true
+	}
+
+	record, host := s.matchUser(user)
+	if record == nil || record.Locked || !privilege.CheckSSL(record, s.sessionVars.TLSConnectionState) || !verify(record) {
+		return false
+	}
+	s.sessionVars.User = &auth.UserIdentity{
+		Username:     user.Username,
+		Hostname:     host,
+		AuthUsername: record.User,
+		AuthHostname: record.Host,
+	}
+	s.sessionVars.ActiveRoles = s.dom.PrivilegeHandle().Get().GetDefaultRoles(record.User, record.Host)
+	return true
 }
 
-// Execute a sql statement.
-func (s *session) Execute(ctx goctx.Context, sql string) (recordSets []sqlexec.RecordSet, err error) {
-	log.Infof("sql: %v", sql)
+// matchUser finds the account of the user by the address of the client, then by the host names
+// of the address, the host the account matches is returned too.
+func (s *session) matchUser(user *auth.UserIdentity) (*privilege.UserRecord, string) {
+	pm := s.dom.PrivilegeHandle().Get()
+	if record := pm.MatchUser(user.Username, user.Hostname); record != nil {
+		return record, user.Hostname
+	}
+	for _, host := range getHostByIP(user.Hostname) {
+		if record := pm.MatchUser(user.Username, host); record != nil {
+			return record, host
+		}
+	}
+	return nil, ""
+}
+
+// getHostByIP returns the host names of the address, the loopback address is localhost.
+func getHostByIP(ip string) []string {
+	if ip == "127.0.0.1" || ip == "::1" {
+		return []string{"localhost"}
+	}
+	addrs, err := net.LookupAddr(ip)
+	if err != nil {
+		return nil
+	}
+	for i, addr := range addrs {
+		addrs[i] = strings.TrimSuffix(addr, ".")
+	}
+	return addrs
+}
+
+func (s *session) Status() uint16 {
+	return s.sessionVars.Status
+}
+
+// AffectedRows returns the rows affected by the last statement.
+func (s *session) AffectedRows() uint64 {
+	return s.sessionVars.StmtCtx.AffectedRows()
+}
 
+// LastInsertID returns the ID generated by the last statement, or the ID given to the
+// auto_increment column if none is generated.
+func (s *session) LastInsertID() uint64 {
+	if s.sessionVars.LastInsertID > 0 {
+		return s.sessionVars.LastInsertID
+	}
+	return s.sessionVars.InsertID
+}
+
+// WarningCount returns the number of the warnings of the last statement, SHOW WARNINGS and
+// SHOW ERRORS have none as they keep the warnings.
+func (s *session) WarningCount() uint16 {
+	return s.sessionVars.StmtCtx.WarningCount()
+}
+
+func (s *session) SetSessionManager(sm util.SessionManager) {
+	s.sessionManager = sm
+}
+
+func (s *session) SetProcessInfo(sql string, t time.Time, command byte) {
+	pi := util.ProcessInfo{
+		ID:      s.sessionVars.ConnectionID,
+		DB:      s.sessionVars.CurrentDB,
+		Command: mysql.Command2Str[command],
+		Time:    t,
+		State:   s.Status(),
+		Info:    sql,
+	}
+	s.processInfo.Store(pi)
+}
+
+func (s *session) ShowProcess() util.ProcessInfo {
+	pi, _ := s.processInfo.Load().(util.ProcessInfo)
+	return pi
+}
+
+func (s *session) FieldList(tableName string) ([]*ast.ResultField, error) {
+	dbName := model.NewCIStr(s.sessionVars.CurrentDB)
+	tblInfo, err := s.GetInfoSchema().TableByName(dbName, model.NewCIStr(tableName))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	fields := make([]*ast.ResultField, 0, len(tblInfo.Columns))
+	for _, col := range tblInfo.Columns {
+		if col.State != model.StatePublic {
+			continue
+		}
+		fields = append(fields, &ast.ResultField{
+			Column:       col,
+			ColumnAsName: col.Name,
+			Table:        tblInfo,
+			TableAsName:  tblInfo.Name,
+			DBName:       dbName,
+		})
+	}
+	return fields, nil
+}
+
+// GetStore implements sessionctx.Context interface.
+func (s *session) GetStore() kv.Storage {
+	return s.store
+}
+
+// GetSessionVars implements sessionctx.Context interface.
+func (s *session) GetSessionVars() *variable.SessionVars {
+	return s.sessionVars
+}
+
+// GetSessionManager implements sessionctx.Context interface.
+func (s *session) GetSessionManager() util.SessionManager {
+	return s.sessionManager
+}
+
+// GetInfoSchema implements sessionctx.Context interface.
+func (s *session) GetInfoSchema() infoschema.InfoSchema {
+	return s.dom.InfoSchema()
+}
+
+// GetPrivilegeManager implements sessionctx.Context interface, the privileges are not checked for
+// the internal sessions, and when the privilege tables are skipped.
+func (s *session) GetPrivilegeManager() privilege.Manager {
+	if s.sessionVars.User == nil || config.GetGlobalConfig().SkipGrantTable {
+		return nil
+	}
+	return &privilege.UserPrivileges{
+		Handle: s.dom.PrivilegeHandle(),
+		User:   s.sessionVars.User.AuthUsername,
+		Host:   s.sessionVars.User.AuthHostname,
+		Roles:  s.sessionVars.ActiveRoles,
+	}
+}
+
+// Close rolls back the transaction which is not committed.
+func (s *session) Close() {
+	terror.Log(s.RollbackTxn(goctx.Background()))
+}
+
+// Execute a sql statement. The record sets of the statements followed by other
+// statements are read in advance, as the following statements may commit the
+// transactions they read.
+func (s *session) Execute(ctx goctx.Context, sql string) (recordSets []sqlexec.RecordSet, err error) {
 	if span := opentracing.SpanFromContext(ctx); span != nil && span.Tracer() != nil {
 		span1 := span.Tracer().StartSpan("session.Execute", opentracing.ChildOf(span.Context()))
 		defer span1.Finish()
 	}
-	recordSets, err = s.execute(ctx, sql)
-	return
+	stmtNodes, err := s.Parse(ctx, sql)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for i, stmtNode := range stmtNodes {
+		rs, err := s.ExecuteStmt(ctx, stmtNode)
+		if err == nil && rs != nil && i < len(stmtNodes)-1 {
+			rs, err = bufferRecordSet(ctx, rs)
+		}
+		if err != nil {
+			for _, rs := range recordSets {
+				terror.Call(rs.Close)
+			}
+			return nil, errors.Trace(err)
+		}
+		if rs != nil {
+			recordSets = append(recordSets, rs)
+		}
+	}
+	return recordSets, nil
 }
 
-func (s *session) execute(ctx goctx.Context, sql string) (recordSets []sqlexec.RecordSet, err error) {
+// Parse parses the statements of a query, which are executed one by one by ExecuteStmt.
+func (s *session) Parse(ctx goctx.Context, sql string) ([]ast.StmtNode, error) {
 	charsetInfo, collation := s.sessionVars.GetCharsetInfo()
-
 	stmtNodes, err := s.parser.Parse(sql, charsetInfo, collation)
 	if err != nil {
+		// The error is shown by SHOW ERRORS and SHOW WARNINGS.
+		s.sessionVars.StmtCtx.AppendError(err)
 		return nil, errors.AddStack(err)
 	}
+	return stmtNodes, nil
+}
+
+// ExecuteStmt executes a statement parsed by Parse. The statement is finished when its
+// record set is closed, which must be done before the next statement is executed.
+func (s *session) ExecuteStmt(ctx goctx.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error) {
+	log.Debugf("sql: %v", secureText(stmtNode))
+	if config.GetGlobalConfig().DumpAST {
+		dumpAST(stmtNode)
+	}
+	rs, err := s.runStmt(ctx, stmtNode, mysql.ComQuery)
+	if err != nil {
+		// The error is shown by SHOW ERRORS and SHOW WARNINGS.
+		s.sessionVars.StmtCtx.AppendError(err)
+		return nil, errors.Trace(err)
+	}
+	return rs, nil
+}
+
+// secureText returns the text of the statement to log, the passwords of the
+// sensitive statements are hidden.
+func secureText(stmtNode ast.StmtNode) string {
+	if sensitive, ok := stmtNode.(ast.SensitiveStmtNode); ok {
+		return sensitive.SecureText()
+	}
+	return stmtNode.Text()
+}
 
-	for _, stmtNode := range stmtNodes {
-		v := visitor{}
-		stmtNode.Accept(&v)
+// ExecRestrictedSQL implements the sqlexec.RestrictedSQLExecutor interface, the internal sessions
+// use it to read and write the system tables. The arguments are bound to the parameter markers,
+// so the names given by the users don't need to be escaped.
+func (s *session) ExecRestrictedSQL(ctx goctx.Context, sql string, args ...interface{}) ([]chunk.Row, []*ast.ResultField, error) {
+	stmtID, _, _, err := s.PrepareStmt(sql)
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	defer func() {
+		terror.Log(s.DropPreparedStmt(stmtID))
+	}()
+	rs, err := s.ExecutePreparedStmt(ctx, stmtID, args...)
+	if err != nil || rs == nil {
+		return nil, nil, errors.Trace(err)
 	}
-	//TODO
-	//compiler
+	defer terror.Call(rs.Close)
+
+	var rows []chunk.Row
+	for {
+		// The rows refer to the batch, so a new batch is used for every call.
+		req := rs.NewRecordBatch()
+		if err = rs.Next(ctx, req); err != nil {
+			return nil, nil, errors.Trace(err)
+		}
+		if req.NumRows() == 0 {
+			return rows, rs.Fields(), nil
+		}
+		for i := 0; i < req.NumRows(); i++ {
+			rows = append(rows, req.GetRow(i))
+		}
+	}
+}
 
-	return nil, nil
+// runStmt executes a statement sent by the command, the statement is finished at once
+// if it returns no rows.
+func (s *session) runStmt(ctx goctx.Context, stmtNode ast.StmtNode, command byte) (sqlexec.RecordSet, error) {
+	s.resetStmtCtx(stmtNode)
+	s.SetProcessInfo(secureText(stmtNode), time.Now(), command)
+
+	canRetry := s.canRetryAutocommit(stmtNode)
+	for retry := 0; ; retry++ {
+		rs, err := s.executeStmt(ctx, stmtNode)
+		if err != nil && ctx.Err() == goctx.Canceled {
+			err = errQueryInterrupted.GenWithStackByArgs()
+		}
+		if rs != nil && err == nil {
+			// The statement is finished when its record set is closed.
+			return &execStmtResult{RecordSet: rs, se: s, ctx: ctx}, nil
+		}
+		err = s.finishStmt(ctx, err)
+		if !canRetry || retry >= maxAutocommitRetries || !isAutocommitRetryable(err) {
+			return nil, errors.Trace(err)
+		}
+		log.Infof("retry the autocommit statement: %v", err)
+		// LAST_INSERT_ID() still returns the ID generated by the previous statement.
+		prevLastInsertID := s.sessionVars.PrevLastInsertID
+		s.resetStmtCtx(stmtNode)
+		s.sessionVars.PrevLastInsertID = prevLastInsertID
+	}
+}
+
+// resetStmtCtx resets the statement context for a new statement. The values which
+// can't be converted exactly are warnings in SELECT, and are truncated silently
+// in other statements until the SQL mode is supported. The duplicate entries and
+// the bad NULL values are warnings in the DML statements with IGNORE. SHOW WARNINGS
+// and SHOW ERRORS keep the warnings of the last statement.
+func (s *session) resetStmtCtx(stmtNode ast.StmtNode) {
+	sc := &stmtctx.StatementContext{
+		TimeZone: time.Local,
+		NowTs:    time.Now(),
+	}
+	switch x := stmtNode.(type) {
+	case *ast.SelectStmt, *ast.UnionStmt:
+		sc.InSelectStmt = true
+		sc.TruncateAsWarning = true
+		sc.DividedByZeroAsWarning = true
+	case *ast.InsertStmt:
+		sc.InInsertStmt = true
+		sc.IgnoreTruncate = true
+		sc.DupKeyAsWarning = x.IgnoreErr
+		sc.BadNullAsWarning = x.IgnoreErr
+	case *ast.UpdateStmt:
+		sc.InUpdateOrDeleteStmt = true
+		sc.IgnoreTruncate = true
+		sc.DupKeyAsWarning = x.IgnoreErr
+		sc.BadNullAsWarning = x.IgnoreErr
+	case *ast.DeleteStmt:
+		sc.InUpdateOrDeleteStmt = true
+		sc.IgnoreTruncate = true
+		sc.DupKeyAsWarning = x.IgnoreErr
+		sc.BadNullAsWarning = x.IgnoreErr
+	case *ast.ShowStmt:
+		sc.IgnoreTruncate = true
+		if x.Tp == ast.ShowWarnings || x.Tp == ast.ShowErrors {
+			sc.InShowWarning = true
+			sc.SetWarnings(s.sessionVars.StmtCtx.GetWarnings())
+		}
+	default:
+		sc.IgnoreTruncate = true
+	}
+	s.sessionVars.StmtCtx = sc
+	// LAST_INSERT_ID() returns the last ID generated by a previous statement.
+	if s.sessionVars.LastInsertID > 0 {
+		s.sessionVars.PrevLastInsertID = s.sessionVars.LastInsertID
+		s.sessionVars.LastInsertID = 0
+	}
+	s.sessionVars.InsertID = 0
+}
+
+// executeStmt checks the privileges of the statement and executes it, the statements not supported
+// yet are rejected. The compiled statements are checked when they are compiled.
+func (s *session) executeStmt(ctx goctx.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error) {
+	if err := plannercore.CheckPrivilege(s, stmtNode); err != nil {
+		return nil, errors.Trace(err)
+	}
+	switch x := stmtNode.(type) {
+	case *ast.SelectStmt:
+		if x.LockTp == ast.SelectLockForUpdate {
+			return s.executeLocked(ctx, x)
+		}
+		return s.executeCompiled(ctx, x)
+	case *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+		return s.executeLocked(ctx, x)
+	case *ast.UnionStmt, *ast.AdminStmt, *ast.ShowStmt:
+		return s.executeCompiled(ctx, x)
+	case *ast.SetStmt:
+		return nil, s.executeSet(ctx, x)
+	case *ast.BeginStmt:
+		return nil, s.executeBegin(ctx, false, false)
+	case *parser.BeginStmt:
+		return nil, s.executeBegin(ctx, x.ReadOnly, x.ReadWrite)
+	case *ast.CommitStmt:
+		return nil, s.CommitTxn(ctx)
+	case *ast.RollbackStmt:
+		return nil, s.RollbackTxn(ctx)
+	case *parser.SavepointStmt:
+		return nil, s.executeSavepoint(x)
+	case *parser.RollbackToStmt:
+		return nil, s.executeRollbackTo(x)
+	case *parser.ReleaseSavepointStmt:
+		return nil, s.executeReleaseSavepoint(x)
+	case *ast.KillStmt:
+		return nil, s.executeKill(x)
+	case *ast.UseStmt:
+		return nil, s.executeUse(x)
+	case *parser.CreateUserStmt:
+		return nil, s.executeCreateUser(ctx, x)
+	case *parser.AlterUserStmt:
+		return nil, s.executeAlterUser(ctx, x)
+	case *ast.DropUserStmt:
+		return nil, s.executeDropUser(ctx, x)
+	case *ast.GrantStmt:
+		return nil, s.executeGrant(ctx, x)
+	case *ast.RevokeStmt:
+		return nil, s.executeRevoke(ctx, x)
+	case *parser.CreateRoleStmt:
+		return nil, s.executeCreateRole(ctx, x)
+	case *parser.DropRoleStmt:
+		return nil, s.executeDropRole(ctx, x)
+	case *parser.GrantRoleStmt:
+		return nil, s.executeGrantRole(ctx, x)
+	case *parser.RevokeRoleStmt:
+		return nil, s.executeRevokeRole(ctx, x)
+	case *parser.SetRoleStmt:
+		return nil, s.executeSetRole(x)
+	case *parser.SetDefaultRoleStmt:
+		return nil, s.executeSetDefaultRole(ctx, x)
+	case *ast.FlushStmt:
+		return nil, s.executeFlush(ctx, x)
+	case ast.DDLNode:
+		return nil, s.executeDDL(ctx, x)
+	}
+	return nil, errNotSupportedYet.GenWithStackByArgs(reflect.TypeOf(stmtNode).Elem().Name())
+}
+
+// executeCompiled compiles the statement into a plan and executes it.
+func (s *session) executeCompiled(ctx goctx.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error) {
+	compiler := executor.Compiler{Ctx: s}
+	stmt, err := compiler.Compile(ctx, stmtNode)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	rs, err := stmt.Exec(ctx)
+	if s.txn != nil {
+		s.txn.recordSchemaVersion(stmt.InfoSchema.SchemaMetaVersion())
+	}
+	return rs, errors.Trace(err)
+}
+
+// astDumper writes the nodes of a statement indented by their depths.
+type astDumper struct {
+	buf   strings.Builder
+	depth int
+}
+
+func (d *astDumper) Enter(in ast.Node) (out ast.Node, skipChildren bool) {
+	fmt.Fprintf(&d.buf, "%s%T", strings.Repeat("  ", d.depth), in)
+	switch x := in.(type) {
+	case *ast.ColumnName:
+		fmt.Fprintf(&d.buf, " %s", x)
+	case *ast.TableName:
+		fmt.Fprintf(&d.buf, " %s", x.Name.O)
+	case *driver.ValueExpr:
+		fmt.Fprintf(&d.buf, " %v", x.GetValue())
+	}
+	d.buf.WriteByte('\n')
+	d.depth++
+	return in, false
+}
+
+func (d *astDumper) Leave(in ast.Node) (out ast.Node, ok bool) {
+	d.depth--
+	return in, true
+}
+
+// dumpAST logs the AST of the statement, it is enabled by the dump-ast flag.
+func dumpAST(stmtNode ast.StmtNode) {
+	d := &astDumper{}
+	stmtNode.Accept(d)
+	log.Infof("AST of %q:", secureText(stmtNode))
+	for _, line := range strings.Split(strings.TrimSuffix(d.buf.String(), "\n"), "\n") {
+		log.Info(line)
+	}
 }
@@ -0,0 +1,478 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	goctx "golang.org/x/net/context"
+
+	"fedb/config"
+	"fedb/kv"
+	"fedb/parser"
+	plannercore "fedb/planner/core"
+	"fedb/privilege"
+	"fedb/sessionctx/variable"
+)
+
+// executeBegin commits the current transaction if there is one, and starts a new transaction.
+// The transaction is read only if readOnly is true, or tx_read_only is on and readWrite is false.
+func (s *session) executeBegin(ctx goctx.Context, readOnly, readWrite bool) error {
+	if err := s.CommitTxn(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	if !readOnly && !readWrite {
+		readOnly = s.isTxnReadOnly()
+	}
+	if err := s.beginTxn(readOnly); err != nil {
+		return errors.Trace(err)
+	}
+	s.sessionVars.SetStatusFlag(mysql.ServerStatusInTrans, true)
+	return nil
+}
+
+// executeKill kills the connection or the statement it is executing, a statement
+// waiting for a lock is interrupted at once.
+func (s *session) executeKill(stmt *ast.KillStmt) error {
+	if s.sessionManager == nil {
+		return nil
+	}
+	s.sessionManager.Kill(stmt.ConnectionID, stmt.Query)
+	return nil
+}
+
+func (s *session) executeSavepoint(stmt *parser.SavepointStmt) error {
+	if !s.sessionVars.InTxn() && s.sessionVars.IsAutocommit() {
+		// The savepoint is discarded at once out of a transaction.
+		return nil
+	}
+	txn, err := s.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	sp := savepoint{name: stmt.Name}
+	err = kv.WalkMemBuffer(txn.GetMemBuffer(), func(k kv.Key, v []byte) error {
+		sp.entries = append(sp.entries, savepointEntry{key: k.Clone(), value: append([]byte(nil), v...)})
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	// A savepoint with the same name replaces the old one.
+	if i := s.findSavepoint(stmt.Name); i >= 0 {
+		s.savepoints = append(s.savepoints[:i], s.savepoints[i+1:]...)
+	}
+	s.savepoints = append(s.savepoints, sp)
+	return nil
+}
+
+// executeRollbackTo restores the transaction buffer to the savepoint, the savepoints
+// set after it are removed. The locks are kept as MySQL does.
+func (s *session) executeRollbackTo(stmt *parser.RollbackToStmt) error {
+	i := s.findSavepoint(stmt.Name)
+	if i < 0 || s.txn == nil {
+		return errSavepointNotExists.GenWithStackByArgs(stmt.Name)
+	}
+	buf := s.txn.GetMemBuffer()
+	buf.Reset()
+	for _, e := range s.savepoints[i].entries {
+		var err error
+		if len(e.value) == 0 {
+			err = buf.Delete(e.key)
+		} else {
+			err = buf.Set(e.key, e.value)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	s.savepoints = s.savepoints[:i+1]
+	return nil
+}
+
+func (s *session) executeReleaseSavepoint(stmt *parser.ReleaseSavepointStmt) error {
+	i := s.findSavepoint(stmt.Name)
+	if i < 0 {
+		return errSavepointNotExists.GenWithStackByArgs(stmt.Name)
+	}
+	s.savepoints = s.savepoints[:i]
+	return nil
+}
+
+// findSavepoint returns the index of the savepoint, the names are case insensitive.
+func (s *session) findSavepoint(name string) int {
+	for i := len(s.savepoints) - 1; i >= 0; i-- {
+		if strings.EqualFold(s.savepoints[i].name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *session) executeSet(ctx goctx.Context, stmt *ast.SetStmt) error {
+	for _, v := range stmt.Variables {
+		if v.Name == ast.SetNames {
+			if err := s.setNames(v); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		name := strings.ToLower(v.Name)
+		if !v.IsSystem {
+			d, err := plannercore.EvalAstExpr(s, v.Value)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if d.IsNull() {
+				delete(s.sessionVars.Users, name)
+				continue
+			}
+			str, err := d.ToString()
+			if err != nil {
+				return errors.Trace(err)
+			}
+			s.sessionVars.Users[name] = str
+			continue
+		}
+
+		sv := variable.GetSysVar(name)
+		if sv == nil {
+			return variable.UnknownSystemVar.GenWithStackByArgs(name)
+		}
+		val, err := s.evalSetValue(sv, v.Value)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if v.IsGlobal {
+			if err = variable.SetGlobalSysVar(name, val); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+		if err = s.setSessionVar(ctx, sv, val); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// evalSetValue evaluates the value of SET statement, DEFAULT is the global value.
+func (s *session) evalSetValue(sv *variable.SysVar, expr ast.ExprNode) (string, error) {
+	switch x := expr.(type) {
+	case *ast.DefaultExpr:
+		val, _ := variable.GetGlobalSysVar(sv.Name)
+		return val, nil
+	case *ast.ColumnNameExpr:
+		// Keywords like `SET tx_isolation = READ-COMMITTED` are parsed as column names.
+		return x.Name.Name.O, nil
+	}
+	d, err := plannercore.EvalAstExpr(s, expr)
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if d.IsNull() {
+		return "", variable.ErrWrongValueForVar.GenWithStackByArgs(sv.Name, "NULL")
+	}
+	val, err := d.ToString()
+	return val, errors.Trace(err)
+}
+
+func (s *session) setSessionVar(ctx goctx.Context, sv *variable.SysVar, val string) error {
+	if sv.Scope == variable.ScopeNone {
+		return variable.ErrReadOnlyVar.GenWithStackByArgs(sv.Name)
+	}
+	if sv.Scope&variable.ScopeSession == 0 {
+		return variable.ErrGlobalVariable.GenWithStackByArgs(sv.Name)
+	}
+	wasAutocommit := s.sessionVars.IsAutocommit()
+	if err := s.sessionVars.SetSystemVar(sv.Name, val); err != nil {
+		return errors.Trace(err)
+	}
+	if sv.Name == variable.AutocommitVar && !wasAutocommit && s.sessionVars.IsAutocommit() {
+		// Enabling autocommit commits the current transaction.
+		return errors.Trace(s.CommitTxn(ctx))
+	}
+	return nil
+}
+
+// setNames handles `SET NAMES charset [COLLATE collation]`.
+func (s *session) setNames(v *ast.VariableAssignment) error {
+	d, err := plannercore.EvalAstExpr(s, v.Value)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	cs, err := d.ToString()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	co := ""
+	if v.ExtendValue != nil {
+		co = v.ExtendValue.GetString()
+	}
+	if co == "" {
+		if co, err = charset.GetDefaultCollation(cs); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, name := range variable.SetNamesVariables {
+		terror.Log(errors.Trace(s.sessionVars.SetSystemVar(name, cs)))
+	}
+	return errors.Trace(s.sessionVars.SetSystemVar(variable.CollationConnection, co))
+}
+
+// executeCreateUser creates the users, none is created if any of them exists, unless IF NOT
+// EXISTS is given and the existing users are skipped. The users created without IDENTIFIED WITH
+// use the default auth plugin.
+func (s *session) executeCreateUser(ctx goctx.Context, stmt *parser.CreateUserStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		var failedUsers []string
+		for _, spec := range stmt.Specs {
+			user, host, err := s.resolveUser(spec.User)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			_, exists, err := getUserPlugin(ctx, se, user, host)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if exists {
+				if !stmt.IfNotExists {
+					failedUsers = append(failedUsers, userString(user, host))
+				}
+				continue
+			}
+			plugin := spec.AuthPlugin
+			if plugin == "" {
+				plugin = config.GetGlobalConfig().DefaultAuthPlugin
+			}
+			plugin, pwd, err := encodeUserPassword(plugin, spec.AuthOpt)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			_, _, err = se.ExecRestrictedSQL(ctx,
+				"INSERT INTO mysql.user (Host, User, authentication_string, plugin, ssl_type) VALUES (?, ?, ?, ?, ?)",
+				host, user, pwd, plugin, sslType(stmt.Require))
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if len(failedUsers) > 0 {
+			return errCannotUser.GenWithStackByArgs("CREATE USER", strings.Join(failedUsers, ","))
+		}
+		return nil
+	})
+}
+
+// executeAlterUser changes the passwords, the auth plugins and the TLS options of the users,
+// ALTER USER USER() changes the password of the account the session is authenticated as. The
+// password is hashed by the current plugin of the user if IDENTIFIED WITH isn't given.
+func (s *session) executeAlterUser(ctx goctx.Context, stmt *parser.AlterUserStmt) error {
+	specs := stmt.Specs
+	if stmt.CurrentAuth != nil {
+		specs = []*parser.UserSpec{{
+			User:    &auth.UserIdentity{CurrentUser: true},
+			AuthOpt: stmt.CurrentAuth,
+		}}
+	}
+	return s.updateUsers(ctx, func(se *session) error {
+		var failedUsers []string
+		for _, spec := range specs {
+			user, host, err := s.resolveUser(spec.User)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			plugin, exists, err := getUserPlugin(ctx, se, user, host)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if !exists {
+				if !stmt.IfExists {
+					failedUsers = append(failedUsers, userString(user, host))
+				}
+				continue
+			}
+			if stmt.Require != "" {
+				_, _, err = se.ExecRestrictedSQL(ctx,
+					"UPDATE mysql.user SET ssl_type = ? WHERE Host = ? AND User = ?", sslType(stmt.Require), host, user)
+				if err != nil {
+					return errors.Trace(err)
+				}
+			}
+			if spec.AuthPlugin == "" && spec.AuthOpt == nil {
+				continue
+			}
+			if spec.AuthPlugin != "" {
+				plugin = spec.AuthPlugin
+			}
+			plugin, pwd, err := encodeUserPassword(plugin, spec.AuthOpt)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			_, _, err = se.ExecRestrictedSQL(ctx,
+				"UPDATE mysql.user SET authentication_string = ?, plugin = ? WHERE Host = ? AND User = ?",
+				pwd, plugin, host, user)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if len(failedUsers) > 0 {
+			return errCannotUser.GenWithStackByArgs("ALTER USER", strings.Join(failedUsers, ","))
+		}
+		return nil
+	})
+}
+
+// executeDropUser drops the users with their privileges and roles, none is dropped if any of them
+// doesn't exist, unless IF EXISTS is given and the missing users are skipped. The connections of
+// the users are kept.
+func (s *session) executeDropUser(ctx goctx.Context, stmt *ast.DropUserStmt) error {
+	return s.updateUsers(ctx, func(se *session) error {
+		var failedUsers []string
+		for _, u := range stmt.UserList {
+			user, host, err := s.resolveUser(u)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			_, exists, err := getUserPlugin(ctx, se, user, host)
+			if err != nil {
+				return errors.Trace(err)
+			}
+			if !exists {
+				if !stmt.IfExists {
+					failedUsers = append(failedUsers, userString(user, host))
+				}
+				continue
+			}
+			if err = deleteAccount(ctx, se, user, host); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if len(failedUsers) > 0 {
+			return errCannotUser.GenWithStackByArgs("DROP USER", strings.Join(failedUsers, ","))
+		}
+		return nil
+	})
+}
+
+// executeFlush reloads the privilege tables for FLUSH PRIVILEGES, so the changes made to them
+// directly take effect, and empties the cache of caching_sha2_password. The other kinds of FLUSH
+// have nothing to do.
+func (s *session) executeFlush(ctx goctx.Context, stmt *ast.FlushStmt) error {
+	if stmt.Tp != ast.FlushPrivileges {
+		return nil
+	}
+	se, err := createSession(s.store)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer se.Close()
+	s.dom.PrivilegeHandle().ClearCache()
+	return errors.Trace(s.dom.PrivilegeHandle().Update(se))
+}
+
+// updateUsers commits the current transaction as MySQL does, then changes the privilege tables
+// in a transaction of an internal session, and reloads them if the changes are committed.
+func (s *session) updateUsers(ctx goctx.Context, update func(se *session) error) error {
+	if err := s.CommitTxn(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	se, err := createSession(s.store)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer se.Close()
+	if err = se.executeBegin(ctx, false, true); err != nil {
+		return errors.Trace(err)
+	}
+	if err = update(se); err != nil {
+		terror.Log(se.RollbackTxn(ctx))
+		return errors.Trace(err)
+	}
+	if err = se.CommitTxn(ctx); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.dom.PrivilegeHandle().Update(se))
+}
+
+// getUserPlugin returns the auth plugin of the account, and whether the account exists.
+func getUserPlugin(ctx goctx.Context, se *session, user, host string) (string, bool, error) {
+	rows, _, err := se.ExecRestrictedSQL(ctx, "SELECT plugin FROM mysql.user WHERE Host = ? AND User = ?", host, user)
+	if err != nil {
+		return "", false, errors.Trace(err)
+	}
+	if len(rows) == 0 {
+		return "", false, nil
+	}
+	return strings.ToLower(rows[0].GetString(0)), true, nil
+}
+
+// resolveUser returns the user name and the host of an account, CURRENT_USER is the account the
+// session is authenticated as.
+func (s *session) resolveUser(u *auth.UserIdentity) (user, host string, err error) {
+	if !u.CurrentUser {
+		return u.Username, normalizeHost(u.Hostname), nil
+	}
+	if s.sessionVars.User == nil {
+		return "", "", errors.New("Session user is empty")
+	}
+	return s.sessionVars.User.AuthUsername, normalizeHost(s.sessionVars.User.AuthHostname), nil
+}
+
+// encodeUserPassword checks the auth plugin, and returns the authentication_string of the auth
+// option hashed by it. The password is empty if there is no auth option.
+func encodeUserPassword(plugin string, authOpt *ast.AuthOption) (string, string, error) {
+	plugin = strings.ToLower(plugin)
+	if !privilege.IsSupportedPlugin(plugin) {
+		return "", "", errPluginIsNotLoaded.GenWithStackByArgs(plugin)
+	}
+	if authOpt == nil {
+		return plugin, "", nil
+	}
+	if authOpt.ByAuthString {
+		return plugin, privilege.EncodePassword(plugin, authOpt.AuthString), nil
+	}
+	if !privilege.IsValidHash(plugin, authOpt.HashString) {
+		return "", "", errPasswordFormat
+	}
+	return plugin, authOpt.HashString, nil
+}
+
+// sslType returns the ssl_type of REQUIRE, it's empty if REQUIRE isn't given or it's REQUIRE NONE.
+func sslType(require string) string {
+	switch require {
+	case parser.RequireSSL:
+		return privilege.SSLTypeAny
+	case parser.RequireX509:
+		return privilege.SSLTypeX509
+	}
+	return privilege.SSLTypeNone
+}
+
+// normalizeHost returns the host of an account as it's stored, the host names are case insensitive.
+func normalizeHost(host string) string {
+	return strings.ToLower(host)
+}
+
+// userString formats an account the way MySQL reports it in the errors.
+func userString(user, host string) string {
+	return fmt.Sprintf("'%s'@'%s'", user, host)
+}
@@ -0,0 +1,375 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/session/txn.go
+//
+
+package session
+
+import (
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+	"fedb/sessionctx/variable"
+	"fedb/util/sqlexec"
+)
+
+// TxnState wraps kv.Transaction to provide a statement level buffer. The writes
+// of a statement go to the buffer first, and are merged into the transaction
+// when the statement succeeds, so that a failed statement is rolled back without
+// aborting the whole transaction.
+type TxnState struct {
+	kv.Transaction
+
+	buf         kv.MemBuffer
+	readOnly    bool
+	pessimistic bool
+	// reads records the keys read by the statement if it isn't nil, SELECT FOR UPDATE
+	// locks them.
+	reads map[string]struct{}
+	// schemaVer is the version of the InfoSchema the statements of the transaction
+	// are compiled with, it is -1 if they are compiled with different versions.
+	schemaVer int64
+}
+
+func newTxnState(txn kv.Transaction, readOnly bool, pessimistic bool) *TxnState {
+	return &TxnState{
+		Transaction: txn,
+		buf:         kv.NewMemDbBuffer(4 * 1024),
+		readOnly:    readOnly,
+		pessimistic: pessimistic,
+	}
+}
+
+// Get overrides the Transaction interface.
+func (st *TxnState) Get(k kv.Key) ([]byte, error) {
+	if st.reads != nil {
+		st.reads[string(k)] = struct{}{}
+	}
+	val, err := st.buf.Get(k)
+	if kv.IsErrNotFound(err) {
+		return st.Transaction.Get(k)
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(val) == 0 {
+		return nil, kv.ErrNotExist
+	}
+	return val, nil
+}
+
+// Iter overrides the Transaction interface.
+func (st *TxnState) Iter(k kv.Key, upperBound kv.Key) (kv.Iterator, error) {
+	bufferIt, err := st.buf.Iter(k, upperBound)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	retrieverIt, err := st.Transaction.Iter(k, upperBound)
+	if err != nil {
+		bufferIt.Close()
+		return nil, errors.Trace(err)
+	}
+	it, err := kv.NewUnionIter(bufferIt, retrieverIt, false)
+	if err != nil || st.reads == nil {
+		return it, errors.Trace(err)
+	}
+	recordingIt := &recordingIter{Iterator: it, reads: st.reads}
+	recordingIt.record()
+	return recordingIt, nil
+}
+
+// recordingIter records the keys it iterates as the keys read by the statement.
+type recordingIter struct {
+	kv.Iterator
+	reads map[string]struct{}
+}
+
+func (it *recordingIter) record() {
+	if it.Valid() {
+		it.reads[string(it.Key())] = struct{}{}
+	}
+}
+
+// Next implements the Iterator Next interface.
+func (it *recordingIter) Next() error {
+	if err := it.Iterator.Next(); err != nil {
+		return errors.Trace(err)
+	}
+	it.record()
+	return nil
+}
+
+// Set overrides the Transaction interface.
+func (st *TxnState) Set(k kv.Key, v []byte) error {
+	if st.readOnly {
+		return errCantExecuteInReadOnlyTxn.GenWithStackByArgs()
+	}
+	return st.buf.Set(k, v)
+}
+
+// Delete overrides the Transaction interface.
+func (st *TxnState) Delete(k kv.Key) error {
+	if st.readOnly {
+		return errCantExecuteInReadOnlyTxn.GenWithStackByArgs()
+	}
+	return st.buf.Delete(k)
+}
+
+// recordSchemaVersion records the version of the InfoSchema a statement is compiled with.
+func (st *TxnState) recordSchemaVersion(ver int64) {
+	if st.schemaVer == 0 {
+		st.schemaVer = ver
+	} else if st.schemaVer != ver {
+		st.schemaVer = -1
+	}
+}
+
+// StmtCommit merges the writes of the statement into the transaction.
+func (st *TxnState) StmtCommit() error {
+	defer st.buf.Reset()
+	return kv.WalkMemBuffer(st.buf, func(k kv.Key, v []byte) error {
+		if len(v) == 0 {
+			return errors.Trace(st.Transaction.Delete(k))
+		}
+		return errors.Trace(st.Transaction.Set(k, v))
+	})
+}
+
+// StmtRollback discards the writes of the statement.
+func (st *TxnState) StmtRollback() {
+	st.buf.Reset()
+}
+
+// stmtKeys returns the keys written by the statement.
+func (st *TxnState) stmtKeys() ([]kv.Key, error) {
+	var keys []kv.Key
+	err := kv.WalkMemBuffer(st.buf, func(k kv.Key, _ []byte) error {
+		keys = append(keys, k.Clone())
+		return nil
+	})
+	return keys, errors.Trace(err)
+}
+
+// readKeys returns the keys read by the statement in order.
+func (st *TxnState) readKeys() []kv.Key {
+	keys := make([]kv.Key, 0, len(st.reads))
+	for k := range st.reads {
+		keys = append(keys, kv.Key(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Cmp(keys[j]) < 0
+	})
+	return keys
+}
+
+// Txn returns the transaction of the session, a new one is begun if there is none.
+// When autocommit is off, the new transaction lasts until it is committed or rolled back.
+func (s *session) Txn() (kv.Transaction, error) {
+	if s.txn != nil {
+		return s.txn, nil
+	}
+	if err := s.beginTxn(false); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if !s.sessionVars.IsAutocommit() {
+		s.sessionVars.SetStatusFlag(mysql.ServerStatusInTrans, true)
+	}
+	return s.txn, nil
+}
+
+func (s *session) beginTxn(readOnly bool) error {
+	txn, err := s.store.Begin()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	pessimistic := s.sessionVars.IsPessimistic()
+	if pessimistic {
+		txn.SetOption(kv.Pessimistic, true)
+		txn.SetOption(kv.LockWaitTimeout, s.sessionVars.LockWaitTimeout())
+	}
+	s.txn = newTxnState(txn, readOnly, pessimistic)
+	return nil
+}
+
+// CommitTxn commits the transaction of the session. The transaction is finished
+// even if it fails, for example, because of a write conflict. The writes compiled
+// with an InfoSchema which is not the latest one may miss the changes of the
+// indexes being built, so such a transaction is rolled back. The DDL statements
+// can't change the schema between the check and the commit.
+func (s *session) CommitTxn(ctx goctx.Context) error {
+	txn := s.txn
+	s.finishTxn()
+	if txn == nil {
+		return nil
+	}
+	locker := s.dom.DDL().SchemaRLocker()
+	locker.Lock()
+	defer locker.Unlock()
+	if txn.Len() > 0 && txn.schemaVer != 0 && txn.schemaVer != s.dom.InfoSchema().SchemaMetaVersion() {
+		terror.Log(txn.Rollback())
+		return errInfoSchemaChanged.GenWithStackByArgs()
+	}
+	return errors.Trace(txn.Commit(ctx))
+}
+
+// RollbackTxn rolls back the transaction of the session.
+func (s *session) RollbackTxn(ctx goctx.Context) error {
+	txn := s.txn
+	s.finishTxn()
+	if txn == nil {
+		return nil
+	}
+	return errors.Trace(txn.Rollback())
+}
+
+func (s *session) finishTxn() {
+	s.txn = nil
+	s.savepoints = nil
+	s.sessionVars.SetStatusFlag(mysql.ServerStatusInTrans, false)
+}
+
+// finishStmt ends the statement level buffer of the transaction, and commits
+// the transaction if the statement is not in an explicit transaction. A deadlock
+// rolls back the whole transaction, like MySQL does, so that the locks held by
+// the victim are released.
+func (s *session) finishStmt(ctx goctx.Context, err error) error {
+	if s.txn != nil {
+		if err != nil {
+			s.txn.StmtRollback()
+		} else {
+			err = s.txn.StmtCommit()
+		}
+	}
+	if s.sessionVars.InTxn() && !kv.ErrDeadlock.Equal(err) {
+		return errors.Trace(err)
+	}
+	if err != nil {
+		terror.Log(s.RollbackTxn(ctx))
+		return errors.Trace(err)
+	}
+	return errors.Trace(s.CommitTxn(ctx))
+}
+
+// maxAutocommitRetries is the max number of times a DML statement in its own autocommit
+// transaction is executed again after the transaction fails to commit for a write conflict,
+// or for a schema change such as a state change of an index being built.
+const maxAutocommitRetries = 10
+
+// isAutocommitRetryable checks whether the autocommit transaction failed to commit for an
+// error which may not happen again. The statement is compiled again with the latest schema
+// when it is retried, so it fails if it is no longer valid, for example, the table is dropped.
+func isAutocommitRetryable(err error) bool {
+	return kv.ErrWriteConflict.Equal(err) || errInfoSchemaChanged.Equal(err)
+}
+
+// canRetryAutocommit checks whether the statement is a DML statement which runs in its own
+// autocommit transaction. Such a transaction can be executed again as a whole, since none
+// of its reads are returned to the client.
+func (s *session) canRetryAutocommit(stmtNode ast.StmtNode) bool {
+	if s.txn != nil || s.sessionVars.InTxn() || !s.sessionVars.IsAutocommit() {
+		return false
+	}
+	switch stmtNode.(type) {
+	case *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+		return true
+	}
+	return false
+}
+
+// executeLocked executes a DML statement or SELECT FOR UPDATE, and locks the keys it writes
+// or reads. A DML statement in an optimistic transaction doesn't lock, its conflicts are
+// checked at commit. In a pessimistic transaction, the statement reads the latest committed
+// values, and is executed again if any of the keys is committed by others before it is
+// locked, the locked keys are read at the ts when they are locked then.
+func (s *session) executeLocked(ctx goctx.Context, stmtNode ast.StmtNode) (sqlexec.RecordSet, error) {
+	if _, err := s.Txn(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	_, forUpdate := stmtNode.(*ast.SelectStmt)
+	if !s.txn.pessimistic && !forUpdate {
+		return s.executeCompiled(ctx, stmtNode)
+	}
+	for {
+		rs, err := s.executeLockedOnce(ctx, stmtNode, forUpdate)
+		if !s.txn.pessimistic || !kv.ErrWriteConflict.Equal(err) {
+			return rs, errors.Trace(err)
+		}
+		log.Debugf("retry for write conflict: %v", err)
+		s.txn.StmtRollback()
+		s.resetStmtCtx(stmtNode)
+	}
+}
+
+func (s *session) executeLockedOnce(ctx goctx.Context, stmtNode ast.StmtNode, forUpdate bool) (sqlexec.RecordSet, error) {
+	txn := s.txn
+	if txn.pessimistic {
+		ver, err := s.store.CurrentVersion()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		txn.SetOption(kv.ForUpdateTS, ver.Ver)
+		defer txn.SetOption(kv.ForUpdateTS, uint64(0))
+	}
+	if forUpdate {
+		txn.reads = make(map[string]struct{})
+		defer func() {
+			txn.reads = nil
+		}()
+	}
+	rs, err := s.executeCompiled(ctx, stmtNode)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if rs != nil {
+		// The rows are read before the keys are locked, so that the statement can
+		// be executed again if they are stale.
+		if rs, err = bufferRecordSet(ctx, rs); err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	var keys []kv.Key
+	if forUpdate {
+		keys = txn.readKeys()
+	} else if keys, err = txn.stmtKeys(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = txn.LockKeys(ctx, keys...); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return rs, nil
+}
+
+// isTxnReadOnly returns whether the new transactions are read only by tx_read_only.
+func (s *session) isTxnReadOnly() bool {
+	val, _ := s.sessionVars.GetSystemVar(variable.TxReadOnly)
+	return variable.IsOn(val)
+}
+
+// savepoint is the state of the transaction buffer when the savepoint is set.
+type savepoint struct {
+	name    string
+	entries []savepointEntry
+}
+
+type savepointEntry struct {
+	key   kv.Key
+	value []byte
+}
@@ -0,0 +1,283 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+	"fedb/store"
+	"fedb/store/localstore"
+	"fedb/store/localstore/memory"
+)
+
+func newTestStore(t *testing.T, name string) kv.Storage {
+	// The driver is registered by every test, only the first succeeds.
+	store.Register("memory", localstore.Driver{Driver: memory.Driver{}})
+	s, err := store.New("memory://" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = BootstrapSession(s); err != nil {
+		t.Fatal(err)
+	}
+	se, err := CreateSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, se, "create database test")
+	return s
+}
+
+func newTestSession(t *testing.T, s kv.Storage) Session {
+	se, err := CreateSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, se, "use test")
+	return se
+}
+
+// execSQL executes the statements and returns the rows of the last record set.
+func execSQL(se Session, sql string) ([][]string, error) {
+	ctx := goctx.Background()
+	rss, err := se.Execute(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, rs := range rss {
+		var fts []*types.FieldType
+		for _, f := range rs.Fields() {
+			fts = append(fts, &f.Column.FieldType)
+		}
+		rows = nil
+		for {
+			req := rs.NewRecordBatch()
+			if err = rs.Next(ctx, req); err != nil || req.NumRows() == 0 {
+				break
+			}
+			for i := 0; i < req.NumRows(); i++ {
+				var row []string
+				for _, d := range req.GetRow(i).GetDatumRow(fts) {
+					s, _ := d.ToString()
+					row = append(row, s)
+				}
+				rows = append(rows, row)
+			}
+		}
+		if closeErr := rs.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+func mustExec(t *testing.T, se Session, sql string) [][]string {
+	rows, err := execSQL(se, sql)
+	if err != nil {
+		t.Fatalf("%s: %v", sql, err)
+	}
+	return rows
+}
+
+func mustValue(t *testing.T, se Session, sql string, expected string) {
+	rows := mustExec(t, se, sql)
+	if len(rows) != 1 || len(rows[0]) != 1 || rows[0][0] != expected {
+		t.Fatalf("%s: expected %s, got %v", sql, expected, rows)
+	}
+}
+
+func mustErrCode(t *testing.T, err error, code uint16) {
+	tErr, ok := errors.Cause(err).(*terror.Error)
+	if !ok || tErr.ToSQLError().Code != code {
+		t.Fatalf("expected error %d, got %v", code, err)
+	}
+}
+
+// mustErrMsg checks that the client gets the message of MySQL for the error.
+func mustErrMsg(t *testing.T, err error, code uint16, msg string) {
+	mustErrCode(t, err, code)
+	if sqlErr := errors.Cause(err).(*terror.Error).ToSQLError(); sqlErr.Message != msg {
+		t.Fatalf("expected error message %q, got %q", msg, sqlErr.Message)
+	}
+}
+
+// execAsync executes the statement in another goroutine.
+func execAsync(se Session, sql string) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := execSQL(se, sql)
+		done <- err
+	}()
+	return done
+}
+
+func mustBlock(t *testing.T, done <-chan error) {
+	select {
+	case err := <-done:
+		t.Fatalf("expected the statement to wait for the lock, got %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func newPessimisticSessions(t *testing.T, name string) (Session, Session) {
+	s := newTestStore(t, name)
+	se1, se2 := newTestSession(t, s), newTestSession(t, s)
+	mustExec(t, se1, "create table t (id int primary key, v int)")
+	mustExec(t, se1, "insert into t values (1, 0), (2, 0)")
+	for _, se := range []Session{se1, se2} {
+		mustExec(t, se, "set @@session.fedb_txn_mode = 'pessimistic'")
+	}
+	return se1, se2
+}
+
+func TestPessimisticLockWait(t *testing.T) {
+	se1, se2 := newPessimisticSessions(t, "TestPessimisticLockWait")
+	mustExec(t, se1, "begin")
+	mustExec(t, se1, "update t set v = v + 1 where id = 1")
+
+	mustExec(t, se2, "begin")
+	done := execAsync(se2, "update t set v = v + 10 where id = 1")
+	mustBlock(t, done)
+	mustExec(t, se1, "commit")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// The update reads the value committed while it waits, so the commit doesn't conflict.
+	mustValue(t, se2, "select v from t where id = 1", "11")
+	mustExec(t, se2, "commit")
+	mustValue(t, se1, "select v from t where id = 1", "11")
+}
+
+func TestPessimisticLockWaitTimeout(t *testing.T) {
+	se1, se2 := newPessimisticSessions(t, "TestPessimisticLockWaitTimeout")
+	mustExec(t, se2, "set @@session.innodb_lock_wait_timeout = 1")
+	mustExec(t, se1, "begin")
+	mustExec(t, se1, "delete from t where id = 1")
+
+	mustExec(t, se2, "begin")
+	mustExec(t, se2, "update t set v = 1 where id = 2")
+	_, err := execSQL(se2, "update t set v = 1 where id = 1")
+	mustErrMsg(t, err, mysql.ErrLockWaitTimeout, "Lock wait timeout exceeded; try restarting transaction")
+	// The timeout fails the statement only.
+	mustExec(t, se2, "commit")
+	mustExec(t, se1, "commit")
+	mustValue(t, se1, "select count(*) from t", "1")
+	mustValue(t, se1, "select v from t where id = 2", "1")
+}
+
+func TestPessimisticDeadlock(t *testing.T) {
+	se1, se2 := newPessimisticSessions(t, "TestPessimisticDeadlock")
+	mustExec(t, se1, "begin")
+	mustExec(t, se1, "update t set v = 1 where id = 1")
+	mustExec(t, se2, "begin")
+	mustExec(t, se2, "update t set v = 2 where id = 2")
+
+	done := execAsync(se1, "update t set v = 1 where id = 2")
+	mustBlock(t, done)
+	_, err := execSQL(se2, "update t set v = 2 where id = 1")
+	mustErrMsg(t, err, mysql.ErrLockDeadlock, "Deadlock found when trying to get lock; try restarting transaction")
+	// The victim is rolled back, so the other one gets the lock.
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, se1, "commit")
+	mustValue(t, se2, "select sum(v) from t", "2")
+}
+
+func TestSelectForUpdate(t *testing.T) {
+	se1, se2 := newPessimisticSessions(t, "TestSelectForUpdate")
+	mustExec(t, se1, "begin")
+	mustValue(t, se1, "select v from t where id = 1", "0")
+	mustExec(t, se2, "update t set v = 5 where id = 1")
+
+	// The locking read sees the latest value, while the consistent read sees the snapshot.
+	mustValue(t, se1, "select v from t where id = 1 for update", "5")
+	mustValue(t, se1, "select v from t where id = 2", "0")
+	// The locked rows are read at the ts when they are locked by the scans too.
+	mustValue(t, se1, "select sum(v) from t", "5")
+
+	done := execAsync(se2, "update t set v = 6 where id = 1")
+	mustBlock(t, done)
+	mustExec(t, se1, "update t set v = v + 1 where id = 1")
+	mustExec(t, se1, "commit")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	mustValue(t, se1, "select v from t where id = 1", "6")
+}
+
+func TestOptimisticSelectForUpdate(t *testing.T) {
+	s := newTestStore(t, "TestOptimisticSelectForUpdate")
+	se1, se2 := newTestSession(t, s), newTestSession(t, s)
+	mustExec(t, se1, "create table t (id int primary key, v int)")
+	mustExec(t, se1, "insert into t values (1, 0)")
+
+	mustExec(t, se1, "begin")
+	mustValue(t, se1, "select v from t where id = 1 for update", "0")
+	mustExec(t, se2, "update t set v = 5 where id = 1")
+	// The locked key is checked for conflicts at commit.
+	_, err := execSQL(se1, "commit")
+	if !kv.ErrWriteConflict.Equal(err) {
+		t.Fatalf("expected write conflict, got %v", err)
+	}
+}
+
+func TestAutocommitRetry(t *testing.T) {
+	s := newTestStore(t, "TestAutocommitRetry")
+	se := newTestSession(t, s)
+	mustExec(t, se, "create table t (id int primary key, v int)")
+	mustExec(t, se, "insert into t values (1, 0)")
+
+	const workers, count = 8, 50
+	errCh := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		se := newTestSession(t, s)
+		go func() {
+			var err error
+			for j := 0; j < count && err == nil; j++ {
+				_, err = execSQL(se, "update t set v = v + 1 where id = 1")
+			}
+			errCh <- err
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustValue(t, se, "select v from t where id = 1", "400")
+
+	// The statements in an explicit transaction are not retried.
+	se2 := newTestSession(t, s)
+	mustExec(t, se, "begin")
+	mustExec(t, se, "update t set v = v + 1 where id = 1")
+	mustExec(t, se2, "update t set v = v + 1 where id = 1")
+	_, err := execSQL(se, "commit")
+	if !kv.ErrWriteConflict.Equal(err) {
+		t.Fatalf("expected a write conflict, got %v", err)
+	}
+	mustValue(t, se, "select v from t where id = 1", "401")
+}
@@ -0,0 +1,47 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/sessionctx/context.go
+//
+
+package sessionctx
+
+import (
+	"fedb/infoschema"
+	"fedb/kv"
+	"fedb/privilege"
+	"fedb/sessionctx/variable"
+	"fedb/util"
+)
+
+// Context is an interface for transaction and executive args environment.
+type Context interface {
+	// Txn returns the current transaction, a new one is begun if there is none.
+	Txn() (kv.Transaction, error)
+
+	// GetStore returns the store of session.
+	GetStore() kv.Storage
+
+	// GetSessionVars returns the session variables.
+	GetSessionVars() *variable.SessionVars
+
+	// GetSessionManager returns the manager of the sessions, it may be nil.
+	GetSessionManager() util.SessionManager
+
+	// GetInfoSchema returns the latest InfoSchema, the statements are compiled with it.
+	GetInfoSchema() infoschema.InfoSchema
+
+	// GetPrivilegeManager returns the privileges of the user of the session, it's nil if the
+	// privileges are not checked, such as for the internal sessions.
+	GetPrivilegeManager() privilege.Manager
+}
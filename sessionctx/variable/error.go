@@ -0,0 +1,51 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/sessionctx/variable/varsutil.go
+//
+
+package variable
+
+import (
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+)
+
+// Error instances.
+var (
+	UnknownSystemVar    = terror.ClassVariable.New(CodeUnknownSystemVar, "unknown system variable '%s'")
+	ErrWrongValueForVar = terror.ClassVariable.New(CodeWrongValueForVar, "Variable '%s' can't be set to the value of '%s'")
+	ErrReadOnlyVar      = terror.ClassVariable.New(CodeIncorrectScope, "Variable '%s' is a read only variable")
+	ErrLocalVariable    = terror.ClassVariable.New(CodeLocalVariable, "Variable '%s' is a SESSION variable and can't be used with SET GLOBAL")
+	ErrGlobalVariable   = terror.ClassVariable.New(CodeGlobalVariable, "Variable '%s' is a GLOBAL variable and should be set with SET GLOBAL")
+)
+
+// Error codes.
+const (
+	CodeUnknownSystemVar terror.ErrCode = terror.ErrCode(mysql.ErrUnknownSystemVariable)
+	CodeWrongValueForVar terror.ErrCode = terror.ErrCode(mysql.ErrWrongValueForVar)
+	CodeIncorrectScope   terror.ErrCode = terror.ErrCode(mysql.ErrIncorrectGlobalLocalVar)
+	CodeLocalVariable    terror.ErrCode = terror.ErrCode(mysql.ErrLocalVariable)
+	CodeGlobalVariable   terror.ErrCode = terror.ErrCode(mysql.ErrGlobalVariable)
+)
+
+func init() {
+	mySQLErrCodes := map[terror.ErrCode]uint16{
+		CodeUnknownSystemVar: mysql.ErrUnknownSystemVariable,
+		CodeWrongValueForVar: mysql.ErrWrongValueForVar,
+		CodeIncorrectScope:   mysql.ErrIncorrectGlobalLocalVar,
+		CodeLocalVariable:    mysql.ErrLocalVariable,
+		CodeGlobalVariable:   mysql.ErrGlobalVariable,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassVariable] = mySQLErrCodes
+}
@@ -16,9 +16,23 @@
 
 package variable
 
+import (
+	"crypto/tls"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/auth"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+)
+
 // SessionVars is session variables
 type SessionVars struct {
 	systems map[string]string // systems variables
+	// Users are user defined variables.
+	Users map[string]string
 
 	// Following variables are special for current session.
 	Status uint16
@@ -26,25 +40,163 @@ type SessionVars struct {
 	ClientCapability uint32 // ClientCapability is client capability
 	ConnectionID     uint64 // ConnectionID is connection id
 	CurrentDB        string // CurrentDB is current db name
+	// User is the user of the connection and the account it's authenticated as, it's nil in
+	// the internal sessions.
+	User *auth.UserIdentity
+	// ActiveRoles are the roles whose privileges the session has, they are set by SET ROLE, and
+	// are the default roles of the account when it logs in.
+	ActiveRoles []*auth.UserIdentity
+	// TLSConnectionState is the TLS connection state, it's nil if the connection isn't over TLS.
+	TLSConnectionState *tls.ConnectionState
+
+	// StmtCtx holds variables for current executing statement.
+	StmtCtx *stmtctx.StatementContext
+
+	PrevLastInsertID uint64 // PrevLastInsertID is the last insert ID of a previous statement.
+	LastInsertID     uint64 // LastInsertID is the auto-generated ID in the current statement.
+	InsertID         uint64 // InsertID is the given insert ID of an auto_increment column.
+
+	// PreparedStmts stores the prepared statements of the session by their IDs.
+	PreparedStmts  map[uint32]*ast.Prepared
+	preparedStmtID uint32
+
+	// PlanID is the unique id of logical and physical plan.
+	PlanID int
+
+	// PlanColumnID is the unique id for column when building plan.
+	PlanColumnID int64
 }
 
 // NewSessionVars create SessionVars
 func NewSessionVars() *SessionVars {
-	return &SessionVars{
-		systems: make(map[string]string),
+	vars := &SessionVars{
+		systems:       make(map[string]string),
+		Users:         make(map[string]string),
+		PreparedStmts: make(map[uint32]*ast.Prepared),
+		StmtCtx:       new(stmtctx.StatementContext),
+	}
+	if autocommit, _ := GetGlobalSysVar(AutocommitVar); IsOn(autocommit) {
+		vars.Status = mysql.ServerStatusAutocommit
 	}
+	return vars
+}
+
+// AllocPlanColumnID allocates column id for plan.
+func (s *SessionVars) AllocPlanColumnID() int64 {
+	s.PlanColumnID++
+	return s.PlanColumnID
+}
+
+// GetNextPreparedStmtID generates and returns the next session scope prepared statement id.
+func (s *SessionVars) GetNextPreparedStmtID() uint32 {
+	s.preparedStmtID++
+	return s.preparedStmtID
+}
+
+// SetStatusFlag sets the session server status variable.
+// If on is true sets the flag in session status,
+// otherwise removes the flag.
+func (s *SessionVars) SetStatusFlag(flag uint16, on bool) {
+	if on {
+		s.Status |= flag
+		return
+	}
+	s.Status &= ^flag
+}
+
+// GetStatusFlag gets the session server status variable, returns true if it is on.
+func (s *SessionVars) GetStatusFlag(flag uint16) bool {
+	return s.Status&flag > 0
+}
+
+// InTxn returns if the session is in transaction.
+func (s *SessionVars) InTxn() bool {
+	return s.GetStatusFlag(mysql.ServerStatusInTrans)
+}
+
+// IsAutocommit returns if the session is set to autocommit.
+func (s *SessionVars) IsAutocommit() bool {
+	return s.GetStatusFlag(mysql.ServerStatusAutocommit)
+}
+
+// IsPessimistic returns if the new transactions of the session are pessimistic by fedb_txn_mode.
+func (s *SessionVars) IsPessimistic() bool {
+	mode, _ := s.GetSystemVar(TxnMode)
+	return mode == TxnModePessimistic
+}
+
+// LockWaitTimeout returns how long a pessimistic transaction waits for a lock by innodb_lock_wait_timeout.
+func (s *SessionVars) LockWaitTimeout() time.Duration {
+	val, _ := s.GetSystemVar(InnodbLockWaitTimeout)
+	secs, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		secs = 50
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// MaxChunkSize returns the max number of rows in a chunk by fedb_max_chunk_size.
+func (s *SessionVars) MaxChunkSize() int {
+	val, _ := s.GetSystemVar(MaxChunkSize)
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		n = DefMaxChunkSize
+	}
+	return n
+}
+
+// HashJoinConcurrency returns the number of the probe workers of a hash join by fedb_hash_join_concurrency.
+func (s *SessionVars) HashJoinConcurrency() int {
+	val, _ := s.GetSystemVar(HashJoinConcurrency)
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		n = DefHashJoinConcurrency
+	}
+	return n
+}
+
+// AutoIncrementIncrement returns the interval between the auto_increment IDs by auto_increment_increment.
+func (s *SessionVars) AutoIncrementIncrement() int64 {
+	val, _ := s.GetSystemVar(AutoIncrementIncrement)
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		n = minAutoIncrement
+	}
+	return n
+}
+
+// AutoIncrementOffset returns the starting point of the auto_increment IDs by auto_increment_offset.
+func (s *SessionVars) AutoIncrementOffset() int64 {
+	val, _ := s.GetSystemVar(AutoIncrementOffset)
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		n = minAutoIncrement
+	}
+	return n
 }
 
 // SetSystemVar sets the value of system variable.
 func (s *SessionVars) SetSystemVar(name string, val string) error {
+	name = strings.ToLower(name)
+	val, err := ValidateSetSystemVar(name, val)
+	if err != nil {
+		return err
+	}
+	if name == AutocommitVar {
+		s.SetStatusFlag(mysql.ServerStatusAutocommit, IsOn(val))
+	}
 	s.systems[name] = val
 	return nil
 }
 
-// GetSystemVar gets value of system variable.
+// GetSystemVar gets value of system variable, the default value is returned if
+// the variable is not set in this session.
 func (s *SessionVars) GetSystemVar(name string) (string, bool) {
-	val, ok := s.systems[name]
-	return val, ok
+	name = strings.ToLower(name)
+	if val, ok := s.systems[name]; ok {
+		return val, true
+	}
+	return GetGlobalSysVar(name)
 }
 
 // GetCharsetInfo gets charset and collation for current context.
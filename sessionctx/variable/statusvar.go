@@ -0,0 +1,67 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/sessionctx/variable/statusvar.go
+//
+
+package variable
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+)
+
+var (
+	statisticsMu   sync.RWMutex
+	statisticsList []Statistics
+)
+
+// StatusVal is the value of the corresponding status variable.
+type StatusVal struct {
+	Scope ScopeFlag
+	Value interface{}
+}
+
+// Statistics is the interface of statistics.
+type Statistics interface {
+	// GetScope gets the status variables scope.
+	GetScope(status string) ScopeFlag
+	// Stats returns the statistics status variables.
+	Stats(*SessionVars) (map[string]interface{}, error)
+}
+
+// RegisterStatistics registers statistics, their status variables are shown by SHOW STATUS.
+func RegisterStatistics(s Statistics) {
+	statisticsMu.Lock()
+	defer statisticsMu.Unlock()
+	statisticsList = append(statisticsList, s)
+}
+
+// GetStatusVars gets registered statistics status variables.
+func GetStatusVars(vars *SessionVars) (map[string]*StatusVal, error) {
+	statisticsMu.RLock()
+	defer statisticsMu.RUnlock()
+	statusVars := make(map[string]*StatusVal)
+	for _, statistics := range statisticsList {
+		vals, err := statistics.Stats(vars)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for name, val := range vals {
+			scope := statistics.GetScope(name)
+			statusVars[name] = &StatusVal{Value: val, Scope: scope}
+		}
+	}
+	return statusVars, nil
+}
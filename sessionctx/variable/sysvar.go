@@ -16,6 +16,14 @@
 
 package variable
 
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/parser/mysql"
+)
+
 // SetNamesVariables is the system variable names related to set names statements.
 var SetNamesVariables = []string{
 	"character_set_client",
@@ -24,6 +32,10 @@ var SetNamesVariables = []string{
 }
 
 const (
+	// AutocommitVar is the name for autocommit system variable.
+	AutocommitVar = "autocommit"
+	// TxReadOnly is the name of tx_read_only system variable.
+	TxReadOnly = "tx_read_only"
 	// CharacterSetConnection is the name for character_set_connection system variable.
 	CharacterSetConnection = "character_set_connection"
 	// CollationConnection is the name for collation_connection system variable.
@@ -32,4 +44,254 @@ const (
 	CharsetDatabase = "character_set_database"
 	// CollationDatabase is the name for collation_database system variable.
 	CollationDatabase = "collation_database"
+	// TxnMode is the name of fedb_txn_mode system variable, it decides whether
+	// the new transactions are optimistic or pessimistic.
+	TxnMode = "fedb_txn_mode"
+	// InnodbLockWaitTimeout is the name of innodb_lock_wait_timeout system variable,
+	// it is the seconds a pessimistic transaction waits for a row lock.
+	InnodbLockWaitTimeout = "innodb_lock_wait_timeout"
+	// MaxChunkSize is the name of fedb_max_chunk_size system variable, it is the max number
+	// of rows in a chunk passed between the executors.
+	MaxChunkSize = "fedb_max_chunk_size"
+	// HashJoinConcurrency is the name of fedb_hash_join_concurrency system variable, it is the
+	// number of the workers which probe the hash table of a hash join.
+	HashJoinConcurrency = "fedb_hash_join_concurrency"
+	// AutoIncrementIncrement is the name of auto_increment_increment system variable, it is
+	// the interval between the successive auto_increment IDs.
+	AutoIncrementIncrement = "auto_increment_increment"
+	// AutoIncrementOffset is the name of auto_increment_offset system variable, it is the
+	// starting point of the auto_increment IDs.
+	AutoIncrementOffset = "auto_increment_offset"
+)
+
+// The values of fedb_txn_mode.
+const (
+	TxnModeOptimistic  = "OPTIMISTIC"
+	TxnModePessimistic = "PESSIMISTIC"
+)
+
+// The bounds of innodb_lock_wait_timeout.
+const (
+	minLockWaitTimeout = 1
+	maxLockWaitTimeout = 1073741824
+)
+
+// The bounds of fedb_max_chunk_size.
+const (
+	// DefMaxChunkSize is the default value of fedb_max_chunk_size.
+	DefMaxChunkSize = 1024
+	minMaxChunkSize = 32
+	maxMaxChunkSize = 65536
 )
+
+// The bounds of fedb_hash_join_concurrency.
+const (
+	// DefHashJoinConcurrency is the default value of fedb_hash_join_concurrency.
+	DefHashJoinConcurrency = 5
+	minHashJoinConcurrency = 1
+	maxHashJoinConcurrency = 256
+)
+
+// The bounds of auto_increment_increment and auto_increment_offset.
+const (
+	minAutoIncrement = 1
+	maxAutoIncrement = 65535
+)
+
+// ScopeFlag is for system variable whether can be changed in global/session dynamically or not.
+type ScopeFlag uint8
+
+const (
+	// ScopeNone means the system variable can not be changed dynamically.
+	ScopeNone ScopeFlag = 0
+	// ScopeGlobal means the system variable can be changed globally.
+	ScopeGlobal ScopeFlag = 1 << 0
+	// ScopeSession means the system variable can only be changed in current session.
+	ScopeSession ScopeFlag = 1 << 1
+)
+
+// SysVar is for system variable.
+type SysVar struct {
+	// Scope is for whether can be changed or not
+	Scope ScopeFlag
+
+	// Name is the variable name.
+	Name string
+
+	// Value is the variable value.
+	Value string
+}
+
+// SysVars is global sys vars map.
+var SysVars map[string]*SysVar
+
+// sysVarsMu protects the values of SysVars.
+var sysVarsMu sync.RWMutex
+
+// GetSysVar returns sys var info for name as key.
+func GetSysVar(name string) *SysVar {
+	name = strings.ToLower(name)
+	return SysVars[name]
+}
+
+// GetGlobalSysVar returns the global value of the system variable.
+func GetGlobalSysVar(name string) (string, bool) {
+	sv := GetSysVar(name)
+	if sv == nil {
+		return "", false
+	}
+	sysVarsMu.RLock()
+	defer sysVarsMu.RUnlock()
+	return sv.Value, true
+}
+
+// SetGlobalSysVar sets the global value of the system variable, it is used by the
+// sessions created after it.
+func SetGlobalSysVar(name string, val string) error {
+	sv := GetSysVar(name)
+	if sv == nil {
+		return UnknownSystemVar.GenWithStackByArgs(name)
+	}
+	if sv.Scope == ScopeNone {
+		return ErrReadOnlyVar.GenWithStackByArgs(name)
+	}
+	if sv.Scope&ScopeGlobal == 0 {
+		return ErrLocalVariable.GenWithStackByArgs(name)
+	}
+	val, err := ValidateSetSystemVar(sv.Name, val)
+	if err != nil {
+		return err
+	}
+	sysVarsMu.Lock()
+	defer sysVarsMu.Unlock()
+	sv.Value = val
+	return nil
+}
+
+// IsOn returns whether the value of a boolean variable is on.
+func IsOn(val string) bool {
+	on, err := parseBool("", val)
+	return err == nil && on
+}
+
+// BoolToIntStr converts bool to int string, for example "0" or "1".
+func BoolToIntStr(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// ValidateSetSystemVar checks the value to set to the system variable, and returns
+// it in the normalized form.
+func ValidateSetSystemVar(name string, val string) (string, error) {
+	switch name {
+	case AutocommitVar:
+		on, err := parseBool(name, val)
+		if err != nil {
+			return "", err
+		}
+		return BoolToIntStr(on), nil
+	case TxnMode:
+		switch upper := strings.ToUpper(val); upper {
+		case TxnModeOptimistic, TxnModePessimistic:
+			return upper, nil
+		case "":
+			return TxnModeOptimistic, nil
+		}
+		return "", ErrWrongValueForVar.GenWithStackByArgs(name, val)
+	case InnodbLockWaitTimeout:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return "", ErrWrongValueForVar.GenWithStackByArgs(name, val)
+		}
+		if n < minLockWaitTimeout {
+			n = minLockWaitTimeout
+		} else if n > maxLockWaitTimeout {
+			n = maxLockWaitTimeout
+		}
+		return strconv.FormatInt(n, 10), nil
+	case MaxChunkSize:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n < minMaxChunkSize || n > maxMaxChunkSize {
+			return "", ErrWrongValueForVar.GenWithStackByArgs(name, val)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case HashJoinConcurrency:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n < minHashJoinConcurrency || n > maxHashJoinConcurrency {
+			return "", ErrWrongValueForVar.GenWithStackByArgs(name, val)
+		}
+		return strconv.FormatInt(n, 10), nil
+	case AutoIncrementIncrement, AutoIncrementOffset:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return "", ErrWrongValueForVar.GenWithStackByArgs(name, val)
+		}
+		if n < minAutoIncrement {
+			n = minAutoIncrement
+		} else if n > maxAutoIncrement {
+			n = maxAutoIncrement
+		}
+		return strconv.FormatInt(n, 10), nil
+	}
+	return val, nil
+}
+
+func parseBool(name string, val string) (bool, error) {
+	switch strings.ToUpper(val) {
+	case "1", "ON", "TRUE":
+		return true, nil
+	case "0", "OFF", "FALSE":
+		return false, nil
+	}
+	return false, ErrWrongValueForVar.GenWithStackByArgs(name, val)
+}
+
+func init() {
+	SysVars = make(map[string]*SysVar)
+	for _, v := range defaultSysVars {
+		SysVars[v.Name] = v
+	}
+}
+
+var defaultSysVars = []*SysVar{
+	{ScopeGlobal | ScopeSession, AutocommitVar, "1"},
+	{ScopeNone, "version", mysql.ServerVersion},
+	{ScopeNone, "version_comment", "FeDB Server (Apache License 2.0), MySQL 5.7 compatible"},
+	{ScopeNone, "system_time_zone", "CST"},
+	{ScopeNone, "lower_case_table_names", "2"},
+	{ScopeGlobal | ScopeSession, "time_zone", "SYSTEM"},
+	{ScopeGlobal | ScopeSession, "sql_mode", mysql.DefaultSQLMode},
+	{ScopeGlobal | ScopeSession, "max_allowed_packet", "67108864"},
+	{ScopeGlobal | ScopeSession, "net_buffer_length", "16384"},
+	{ScopeGlobal | ScopeSession, "wait_timeout", "28800"},
+	{ScopeGlobal | ScopeSession, "interactive_timeout", "28800"},
+	{ScopeGlobal | ScopeSession, "tx_isolation", "REPEATABLE-READ"},
+	{ScopeGlobal | ScopeSession, "transaction_isolation", "REPEATABLE-READ"},
+	{ScopeGlobal | ScopeSession, TxReadOnly, "0"},
+	{ScopeGlobal | ScopeSession, TxnMode, TxnModeOptimistic},
+	{ScopeGlobal | ScopeSession, InnodbLockWaitTimeout, "50"},
+	{ScopeGlobal | ScopeSession, MaxChunkSize, strconv.Itoa(DefMaxChunkSize)},
+	{ScopeGlobal | ScopeSession, HashJoinConcurrency, strconv.Itoa(DefHashJoinConcurrency)},
+	{ScopeGlobal | ScopeSession, AutoIncrementIncrement, "1"},
+	{ScopeGlobal | ScopeSession, AutoIncrementOffset, "1"},
+	{ScopeGlobal | ScopeSession, "character_set_client", mysql.DefaultCharset},
+	{ScopeGlobal | ScopeSession, "character_set_connection", mysql.DefaultCharset},
+	{ScopeGlobal | ScopeSession, "character_set_results", mysql.DefaultCharset},
+	{ScopeGlobal | ScopeSession, "character_set_server", mysql.DefaultCharset},
+	{ScopeGlobal | ScopeSession, "character_set_database", mysql.DefaultCharset},
+	{ScopeGlobal | ScopeSession, "collation_connection", mysql.DefaultCollationName},
+	{ScopeGlobal | ScopeSession, "collation_server", mysql.DefaultCollationName},
+	{ScopeGlobal | ScopeSession, "collation_database", mysql.DefaultCollationName},
+	{ScopeGlobal | ScopeSession, "init_connect", ""},
+	{ScopeGlobal | ScopeSession, "query_cache_type", "OFF"},
+	{ScopeNone, "query_cache_size", "0"},
+	{ScopeNone, "license", "Apache License 2.0"},
+	{ScopeNone, "have_ssl", "DISABLED"},
+	{ScopeNone, "have_openssl", "DISABLED"},
+	{ScopeNone, "ssl_ca", ""},
+	{ScopeNone, "ssl_cert", ""},
+	{ScopeNone, "ssl_key", ""},
+	{ScopeGlobal | ScopeSession, "performance_schema", "0"},
+}
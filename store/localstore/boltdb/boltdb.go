@@ -0,0 +1,198 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package boltdb
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/terror"
+	"go.etcd.io/bbolt"
+
+	"fedb/store/localstore/engine"
+)
+
+const (
+	dataFile = "fedb.bolt"
+
+	// iterBatchSize is the number of entries an iterator loads in one read transaction.
+	iterBatchSize = 256
+)
+
+var bucketName = []byte("fedb")
+
+// Driver implements engine Driver.
+type Driver struct {
+}
+
+// Open opens or creates a bolt DB in the directory of the schema.
+func (driver Driver) Open(schema string) (engine.DB, error) {
+	if schema == "" {
+		return nil, errors.New("boltdb: data directory is not specified")
+	}
+	if err := os.MkdirAll(schema, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	d, err := bbolt.Open(filepath.Join(schema, dataFile), 0600, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	err = d.Update(func(tx *bbolt.Tx) error {
+		_, err1 := tx.CreateBucketIfNotExists(bucketName)
+		return err1
+	})
+	if err != nil {
+		terror.Log(errors.Trace(d.Close()))
+		return nil, errors.Trace(err)
+	}
+	return &db{DB: d}, nil
+}
+
+// db is a durable engine based on the B+tree of bbolt.
+type db struct {
+	*bbolt.DB
+}
+
+func (d *db) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := d.DB.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(bucketName).Get(key)
+		if v != nil {
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return value, errors.Trace(err)
+}
+
+// GetSnapshot returns a snapshot which reads the latest data.
+// A bolt read transaction blocks the writer from growing the data file, so the
+// snapshot doesn't hold one, every read opens a short-lived transaction instead.
+func (d *db) GetSnapshot() (engine.Snapshot, error) {
+	return &snapshot{db: d}, nil
+}
+
+func (d *db) NewBatch() engine.Batch {
+	return engine.NewMemBatch()
+}
+
+func (d *db) Commit(b engine.Batch) error {
+	batch, ok := b.(*engine.MemBatch)
+	if !ok {
+		return errors.Errorf("invalid batch type %T", b)
+	}
+	err := d.DB.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(bucketName)
+		for _, op := range batch.Ops {
+			var err error
+			if op.Delete {
+				err = bucket.Delete(op.Key)
+			} else {
+				err = bucket.Put(op.Key, op.Value)
+			}
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		return nil
+	})
+	return errors.Trace(err)
+}
+
+func (d *db) Close() error {
+	return errors.Trace(d.DB.Close())
+}
+
+type snapshot struct {
+	db *db
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	return s.db.Get(key)
+}
+
+func (s *snapshot) NewIterator(startKey []byte) engine.Iterator {
+	return &iterator{db: s.db, seekKey: startKey}
+}
+
+func (s *snapshot) Release() {
+}
+
+type kvPair struct {
+	key   []byte
+	value []byte
+}
+
+// iterator loads the entries in batches, each batch is read in its own read transaction.
+type iterator struct {
+	db      *db
+	seekKey []byte
+	// exhausted is set when there are no more entries after the current batch.
+	exhausted bool
+	pairs     []kvPair
+	pos       int
+	err       error
+}
+
+func (it *iterator) Next() bool {
+	it.pos++
+	if it.pos < len(it.pairs) {
+		return true
+	}
+	if it.exhausted || it.err != nil {
+		return false
+	}
+	it.load()
+	return it.pos < len(it.pairs)
+}
+
+func (it *iterator) load() {
+	it.pairs = it.pairs[:0]
+	it.pos = 0
+	it.err = it.db.DB.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		k, v := c.Seek(it.seekKey)
+		for ; k != nil && len(it.pairs) < iterBatchSize; k, v = c.Next() {
+			it.pairs = append(it.pairs, kvPair{
+				key:   append([]byte(nil), k...),
+				value: append([]byte(nil), v...),
+			})
+		}
+		it.exhausted = k == nil
+		return nil
+	})
+	if len(it.pairs) > 0 {
+		// The next batch starts right after the last loaded key.
+		last := it.pairs[len(it.pairs)-1].key
+		it.seekKey = append(append(it.seekKey[:0:0], last...), 0)
+	}
+}
+
+func (it *iterator) Key() []byte {
+	return it.pairs[it.pos].key
+}
+
+func (it *iterator) Value() []byte {
+	return it.pairs[it.pos].value
+}
+
+func (it *iterator) Error() error {
+	return it.err
+}
+
+func (it *iterator) Release() {
+	it.pairs = nil
+}
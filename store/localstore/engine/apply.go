@@ -0,0 +1,110 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package engine
+
+import (
+	"sync"
+)
+
+// ApplyQueue applies the batches of a GroupCommitter after they are durable, so that a
+// batch is never visible before it survives a crash. The batches are applied in the order
+// they are pushed, which must be the order they are logged.
+type ApplyQueue struct {
+	apply func(lsn uint64, b *MemBatch) error
+
+	mu     sync.Mutex
+	queue  []*queuedBatch
+	closed bool
+	notify chan struct{}
+
+	wg sync.WaitGroup
+}
+
+type queuedBatch struct {
+	lsn    uint64
+	batch  *MemBatch
+	logged <-chan error
+	done   chan error
+}
+
+// NewApplyQueue creates an ApplyQueue which applies the batches by the apply function.
+func NewApplyQueue(apply func(lsn uint64, b *MemBatch) error) *ApplyQueue {
+	q := &ApplyQueue{
+		apply:  apply,
+		notify: make(chan struct{}, 1),
+	}
+	q.wg.Add(1)
+	go q.applyLoop()
+	return q
+}
+
+// Push queues the batch logged with the lsn, logged receives the result of logging it. The
+// returned channel receives the error of logging or applying the batch, or nil after it is
+// applied. Push doesn't block, so it can be called under the lock which orders the logging.
+func (q *ApplyQueue) Push(lsn uint64, b *MemBatch, logged <-chan error) <-chan error {
+	done := make(chan error, 1)
+	q.mu.Lock()
+	q.queue = append(q.queue, &queuedBatch{lsn: lsn, batch: b, logged: logged, done: done})
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return done
+}
+
+func (q *ApplyQueue) applyLoop() {
+	defer q.wg.Done()
+	for range q.notify {
+		for {
+			q.mu.Lock()
+			if len(q.queue) == 0 {
+				closed := q.closed
+				q.mu.Unlock()
+				if closed {
+					return
+				}
+				break
+			}
+			qb := q.queue[0]
+			q.queue[0] = nil
+			q.queue = q.queue[1:]
+			q.mu.Unlock()
+
+			// A batch which fails to be logged is not applied, and the log fails the ones
+			// after it too, so the applied batches never have a gap.
+			err := <-qb.logged
+			if err == nil {
+				err = q.apply(qb.lsn, qb.batch)
+			}
+			qb.done <- err
+		}
+	}
+}
+
+// Close waits for the queued batches to be applied and stops the queue.
+func (q *ApplyQueue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	q.wg.Wait()
+}
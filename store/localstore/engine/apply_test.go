@@ -0,0 +1,57 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestApplyQueue checks that a batch is applied after it is logged, in the order it is
+// pushed, and is not applied if logging it fails.
+func TestApplyQueue(t *testing.T) {
+	var applied []uint64
+	q := NewApplyQueue(func(lsn uint64, b *MemBatch) error {
+		applied = append(applied, lsn)
+		return nil
+	})
+	logged1, logged2, logged3 := make(chan error, 1), make(chan error, 1), make(chan error, 1)
+	done1 := q.Push(1, NewMemBatch(), logged1)
+	done2 := q.Push(2, NewMemBatch(), logged2)
+	done3 := q.Push(3, NewMemBatch(), logged3)
+
+	logged2 <- nil
+	select {
+	case err := <-done2:
+		t.Fatalf("batch 2 is finished before batch 1 is logged: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	logged1 <- nil
+	if err := <-done1; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done2; err != nil {
+		t.Fatal(err)
+	}
+	logErr := errors.New("log error")
+	logged3 <- logErr
+	if err := <-done3; err != logErr {
+		t.Fatalf("expected the log error, got %v", err)
+	}
+	q.Close()
+	if len(applied) != 2 || applied[0] != 1 || applied[1] != 2 {
+		t.Fatalf("expected batches 1 and 2 applied in order, got %v", applied)
+	}
+}
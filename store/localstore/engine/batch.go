@@ -0,0 +1,146 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package engine
+
+import (
+	"encoding/binary"
+
+	"github.com/pingcap/errors"
+)
+
+// Op is a single write operation in a MemBatch.
+type Op struct {
+	Key    []byte
+	Value  []byte
+	Delete bool
+}
+
+// MemBatch is a Batch which keeps its operations in memory, for the engines that
+// apply the operations by themselves.
+type MemBatch struct {
+	Ops  []Op
+	size int
+}
+
+// NewMemBatch creates a MemBatch.
+func NewMemBatch() *MemBatch {
+	return &MemBatch{}
+}
+
+// Put implements the Batch Put interface.
+func (b *MemBatch) Put(key []byte, value []byte) {
+	b.Ops = append(b.Ops, Op{Key: append([]byte(nil), key...), Value: append([]byte(nil), value...)})
+	b.size += len(key) + len(value)
+}
+
+// Delete implements the Batch Delete interface.
+func (b *MemBatch) Delete(key []byte) {
+	b.Ops = append(b.Ops, Op{Key: append([]byte(nil), key...), Delete: true})
+	b.size += len(key)
+}
+
+// Len implements the Batch Len interface.
+func (b *MemBatch) Len() int {
+	return len(b.Ops)
+}
+
+// Size returns the sum of the keys and values length.
+func (b *MemBatch) Size() int {
+	return b.size
+}
+
+const (
+	opPut byte = iota + 1
+	opDelete
+)
+
+// Encode encodes the operations of the batch into bytes, it can be decoded by DecodeMemBatch.
+func (b *MemBatch) Encode() []byte {
+	buf := make([]byte, 0, b.size+len(b.Ops)*3+binary.MaxVarintLen64)
+	buf = appendUvarint(buf, uint64(len(b.Ops)))
+	for _, op := range b.Ops {
+		if op.Delete {
+			buf = append(buf, opDelete)
+			buf = appendUvarint(buf, uint64(len(op.Key)))
+			buf = append(buf, op.Key...)
+			continue
+		}
+		buf = append(buf, opPut)
+		buf = appendUvarint(buf, uint64(len(op.Key)))
+		buf = append(buf, op.Key...)
+		buf = appendUvarint(buf, uint64(len(op.Value)))
+		buf = append(buf, op.Value...)
+	}
+	return buf
+}
+
+// DecodeMemBatch decodes the bytes produced by MemBatch.Encode.
+func DecodeMemBatch(data []byte) (*MemBatch, error) {
+	count, data, err := readUvarint(data)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	b := &MemBatch{Ops: make([]Op, 0, count)}
+	for i := uint64(0); i < count; i++ {
+		if len(data) == 0 {
+			return nil, errors.Trace(errBadBatch)
+		}
+		tp := data[0]
+		var key, value []byte
+		key, data, err = readBytes(data[1:])
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		switch tp {
+		case opPut:
+			value, data, err = readBytes(data)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			b.Put(key, value)
+		case opDelete:
+			b.Delete(key)
+		default:
+			return nil, errors.Trace(errBadBatch)
+		}
+	}
+	return b, nil
+}
+
+var errBadBatch = errors.New("malformed batch data")
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errBadBatch
+	}
+	return v, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	l, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(data)) < l {
+		return nil, nil, errBadBatch
+	}
+	return data[:l], data[l:], nil
+}
@@ -0,0 +1,143 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package engine_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fedb/store/localstore/boltdb"
+	"fedb/store/localstore/engine"
+	"fedb/store/localstore/lsm"
+	"fedb/store/localstore/memory"
+	"fedb/store/localstore/native"
+)
+
+// The benchmarks compare the engines by the same workloads, the durable engines sync
+// every commit by default.
+var benchEngines = []struct {
+	name string
+	open func(dir string) (engine.DB, error)
+}{
+	{"memory", func(string) (engine.DB, error) { return memory.NewDB(), nil }},
+	{"boltdb", func(dir string) (engine.DB, error) { return boltdb.Driver{}.Open(dir) }},
+	{"native", func(dir string) (engine.DB, error) { return native.Open(dir, native.Options{}) }},
+	{"lsm", func(dir string) (engine.DB, error) { return lsm.Open(dir, lsm.Options{}) }},
+}
+
+const benchKeys = 10000
+
+func benchKey(i int) []byte {
+	return []byte(fmt.Sprintf("key%08d", i))
+}
+
+var benchValue = make([]byte, 100)
+
+// runEngines runs the benchmark on every engine, the engines are loaded with benchKeys
+// keys if load is true.
+func runEngines(b *testing.B, load bool, fn func(b *testing.B, db engine.DB)) {
+	for _, e := range benchEngines {
+		b.Run(e.name, func(b *testing.B) {
+			dir, err := ioutil.TempDir("", "engine")
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			db, err := e.open(filepath.Join(dir, e.name))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer db.Close()
+			if load {
+				batch := db.NewBatch()
+				for i := 0; i < benchKeys; i++ {
+					batch.Put(benchKey(i), benchValue)
+					if batch.Len() == 1000 {
+						if err = db.Commit(batch); err != nil {
+							b.Fatal(err)
+						}
+						batch = db.NewBatch()
+					}
+				}
+			}
+			b.ResetTimer()
+			fn(b, db)
+		})
+	}
+}
+
+func BenchmarkCommit(b *testing.B) {
+	runEngines(b, false, func(b *testing.B, db engine.DB) {
+		for i := 0; i < b.N; i++ {
+			batch := db.NewBatch()
+			batch.Put(benchKey(i), benchValue)
+			if err := db.Commit(batch); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCommitBatch(b *testing.B) {
+	runEngines(b, false, func(b *testing.B, db engine.DB) {
+		for i := 0; i < b.N; i++ {
+			batch := db.NewBatch()
+			for j := 0; j < 100; j++ {
+				batch.Put(benchKey(i*100+j), benchValue)
+			}
+			if err := db.Commit(batch); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	runEngines(b, true, func(b *testing.B, db engine.DB) {
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < b.N; i++ {
+			if _, err := db.Get(benchKey(r.Intn(benchKeys))); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkScan(b *testing.B) {
+	runEngines(b, true, func(b *testing.B, db engine.DB) {
+		for i := 0; i < b.N; i++ {
+			snapshot, err := db.GetSnapshot()
+			if err != nil {
+				b.Fatal(err)
+			}
+			it := snapshot.NewIterator(nil)
+			n := 0
+			for it.Next() {
+				n++
+			}
+			if err = it.Error(); err != nil {
+				b.Fatal(err)
+			}
+			it.Release()
+			snapshot.Release()
+			if n != benchKeys {
+				b.Fatalf("expected %d keys, got %d", benchKeys, n)
+			}
+		}
+	})
+}
@@ -0,0 +1,80 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/store/localstore/engine/engine.go
+//
+
+package engine
+
+// Driver is the interface that must be implemented by a local storage engine.
+type Driver interface {
+	// Open opens or creates a local storage DB.
+	// The schema is a string for a local storage DB specific format.
+	Open(schema string) (DB, error)
+}
+
+// DB is the interface for local storage.
+type DB interface {
+	// Get gets the associated value with key, returns (nil, nil) if no value found.
+	Get(key []byte) ([]byte, error)
+	// GetSnapshot gets a snapshot.
+	GetSnapshot() (Snapshot, error)
+	// NewBatch creates a Batch for writing.
+	NewBatch() Batch
+	// Commit writes the changed data in Batch.
+	Commit(b Batch) error
+	// Close closes database.
+	Close() error
+}
+
+// Snapshot is the interface for local storage.
+type Snapshot interface {
+	// Get gets the associated value with key in the snapshot, returns (nil, nil) if no value found.
+	Get(key []byte) ([]byte, error)
+	// NewIterator creates an iterator, seeks to the first key >= startKey.
+	NewIterator(startKey []byte) Iterator
+	// Release releases the snapshot.
+	Release()
+}
+
+// Iterator is the interface for iterating over the local storage in key order.
+type Iterator interface {
+	// Next moves the iterator to the next entry, it must be called before reading the first entry.
+	// It returns false when the iterator is exhausted.
+	Next() bool
+	// Key returns the key of the current entry.
+	Key() []byte
+	// Value returns the value of the current entry.
+	Value() []byte
+	// Error returns the error which stops the iterator, Next returns false after it.
+	Error() error
+	// Release releases the iterator.
+	Release()
+}
+
+// Batch is the interface for local storage.
+type Batch interface {
+	// Put appends 'put operation' of the key/value to the batch.
+	Put(key []byte, value []byte)
+	// Delete appends 'delete operation' of the key/value to the batch.
+	Delete(key []byte)
+	// Len return length of the batch
+	Len() int
+}
+
+// GroupCommitter is implemented by the engines which make a batch durable asynchronously,
+// so that the concurrent commits can share one sync of the log.
+type GroupCommitter interface {
+	// CommitAsync applies the batch, the returned channel receives the result when the batch is durable.
+	CommitAsync(b Batch) <-chan error
+}
@@ -0,0 +1,177 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+	log "github.com/sirupsen/logrus"
+)
+
+// gcBatchSize is the number of versions deleted in a batch.
+const gcBatchSize = 256
+
+// gcWorker periodically removes the old versions which are not visible to
+// any running transaction or any transaction started within the life time.
+type gcWorker struct {
+	store    *dbStore
+	lifeTime time.Duration
+	interval time.Duration
+	closeCh  chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newGCWorker(store *dbStore, lifeTime, interval time.Duration) *gcWorker {
+	w := &gcWorker{
+		store:    store,
+		lifeTime: lifeTime,
+		interval: interval,
+		closeCh:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.run()
+	return w
+}
+
+func (w *gcWorker) run() {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.store.gc(w.lifeTime); err != nil {
+				log.Errorf("[gc] run GC error %v", errors.ErrorStack(err))
+			}
+		case <-w.closeCh:
+			return
+		}
+	}
+}
+
+func (w *gcWorker) close() {
+	close(w.closeCh)
+	w.wg.Wait()
+}
+
+// gc advances the safe point and removes the versions which are not visible at it.
+func (s *dbStore) gc(lifeTime time.Duration) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+
+	safePoint := s.updateSafePoint(lifeTime)
+	start := time.Now()
+	deleted, err := s.deleteVersions(safePoint)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	b := s.db.NewBatch()
+	b.Put(safePointKey, codec.EncodeUint(nil, safePoint))
+	if err = s.db.Commit(b); err != nil {
+		return errors.Trace(err)
+	}
+	log.Infof("[gc] safe point %d, removed %d versions in %v", safePoint, deleted, time.Since(start))
+	return nil
+}
+
+// updateSafePoint moves the safe point to the ts of lifeTime ago, but not after
+// the start ts of any running transaction.
+func (s *dbStore) updateSafePoint(lifeTime time.Duration) uint64 {
+	safePoint := composeTS(getPhysical(time.Now().Add(-lifeTime)), 0)
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	for startTS := range s.active {
+		if startTS < safePoint {
+			safePoint = startTS
+		}
+	}
+	if safePoint > s.safePoint {
+		s.safePoint = safePoint
+	}
+	return s.safePoint
+}
+
+// deleteVersions deletes the versions older than the newest version of each key at the
+// safe point, and the newest one as well if it is a deletion. The lock records at or
+// before the safe point are always deleted.
+func (s *dbStore) deleteVersions(safePoint uint64) (int, error) {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer snapshot.Release()
+	it := snapshot.NewIterator(mvccPrefix)
+	defer it.Release()
+
+	var (
+		deleted int
+		prefix  []byte
+		// kept is whether the newest data version at the safe point of the current key is found.
+		kept bool
+		b    = s.db.NewBatch()
+	)
+	for it.Next() {
+		raw := it.Key()
+		if !bytes.HasPrefix(raw, mvccPrefix) {
+			break
+		}
+		if prefix == nil || !isVersionOf(raw, prefix) {
+			prefix = append(prefix[:0], raw[:len(raw)-8]...)
+			kept = false
+		}
+		_, ver, err := codec.DecodeUintDesc(raw[len(raw)-8:])
+		if err != nil {
+			return deleted, errors.Trace(err)
+		}
+		if ver > safePoint {
+			continue
+		}
+		if !kept {
+			flag, _ := mvccDecodeValue(it.Value())
+			if flag == flagLock {
+				b.Delete(raw)
+				continue
+			}
+			kept = true
+			if flag == flagPut {
+				continue
+			}
+		}
+		b.Delete(raw)
+		if b.Len() >= gcBatchSize {
+			if err = s.db.Commit(b); err != nil {
+				return deleted, errors.Trace(err)
+			}
+			deleted += b.Len()
+			b = s.db.NewBatch()
+		}
+	}
+	if err = it.Error(); err != nil {
+		return deleted, errors.Trace(err)
+	}
+	if b.Len() > 0 {
+		if err = s.db.Commit(b); err != nil {
+			return deleted, errors.Trace(err)
+		}
+		deleted += b.Len()
+	}
+	return deleted, nil
+}
@@ -0,0 +1,393 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/config"
+	"fedb/kv"
+	"fedb/store/localstore/engine"
+)
+
+// Driver implements kv.Driver on top of a local storage engine.
+type Driver struct {
+	engine.Driver
+}
+
+var (
+	mxStores sync.Mutex
+	// stores keeps the opened stores, the same path always opens the same store.
+	stores = make(map[string]*dbStore)
+)
+
+// Open opens or creates a storage with specific format for a local engine Driver.
+// The path should be a URL like "memory://", "boltdb:///tmp/fedb", "native:///tmp/fedb" or "lsm:///tmp/fedb".
+func (d Driver) Open(path string) (kv.Storage, error) {
+	mxStores.Lock()
+	defer mxStores.Unlock()
+
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	schema := u.Host + u.Path
+	if store, ok := stores[path]; ok && schema != "" {
+		return store, nil
+	}
+
+	db, err := d.Driver.Open(schema)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	log.Infof("[kv] New store, path %s", path)
+	s := &dbStore{
+		uuid:     fmt.Sprintf("localstore-%s-%d", path, time.Now().UnixNano()),
+		path:     path,
+		db:       db,
+		active:   make(map[uint64]int),
+		inflight: make(map[string]uint64),
+		lockMgr:  newLockManager(),
+	}
+	if err = s.loadMeta(); err != nil {
+		db.Close()
+		return nil, errors.Trace(err)
+	}
+	cfg := config.GetGlobalConfig()
+	if cfg.GCRunInterval > 0 {
+		s.gcWorker = newGCWorker(s, cfg.GCLifeTime, cfg.GCRunInterval)
+	}
+	if schema != "" {
+		stores[path] = s
+	}
+	return s, nil
+}
+
+type dbStore struct {
+	mu sync.RWMutex
+	// commitMu serializes the commits, a commit checks the conflicts, allocates
+	// its commit ts and writes under it.
+	commitMu sync.Mutex
+	// lastCommit is closed when the last commit being made durable by a GroupCommitter
+	// and the ones before it are finished, it is nil if there is none. inflight maps the
+	// keys of such commits to their commit ts. They are protected by commitMu.
+	lastCommit chan struct{}
+	inflight   map[string]uint64
+
+	db     engine.DB
+	uuid   string
+	path   string
+	closed bool
+
+	oracle *localOracle
+
+	// activeMu protects the fields below.
+	activeMu sync.Mutex
+	// active counts the running transactions by start ts, GC keeps the versions they may read.
+	active map[uint64]int
+	// safePoint is the ts before which the versions may have been removed by GC.
+	safePoint uint64
+
+	gcWorker *gcWorker
+
+	// lockMgr holds the locks of the pessimistic transactions.
+	lockMgr *lockManager
+}
+
+// loadMeta loads the last commit ts and the GC safe point from the engine.
+func (s *dbStore) loadMeta() error {
+	var lastTS uint64
+	for _, item := range []struct {
+		key []byte
+		ts  *uint64
+	}{
+		{lastCommitTSKey, &lastTS},
+		{safePointKey, &s.safePoint},
+	} {
+		v, err := s.db.Get(item.key)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if v == nil {
+			continue
+		}
+		if _, *item.ts, err = codec.DecodeUint(v); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if s.safePoint > lastTS {
+		lastTS = s.safePoint
+	}
+	s.oracle = newLocalOracle(lastTS)
+	return nil
+}
+
+// currentTS allocates a ts which is larger than the commit ts of all the committed transactions.
+// It waits for the commit in progress, so that the writes before the ts are all visible.
+func (s *dbStore) currentTS() uint64 {
+	s.commitMu.Lock()
+	ts := s.oracle.getTimestamp()
+	wait := s.lastCommit
+	s.commitMu.Unlock()
+	if wait != nil {
+		<-wait
+	}
+	return ts
+}
+
+// Begin transaction
+func (s *dbStore) Begin() (kv.Transaction, error) {
+	return s.BeginWithStartTS(s.currentTS())
+}
+
+// BeginWithStartTS begins transaction with startTS.
+func (s *dbStore) BeginWithStartTS(startTS uint64) (kv.Transaction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, errors.Trace(kv.ErrStoreClosed)
+	}
+	if err := s.addActive(startTS); err != nil {
+		return nil, errors.Trace(err)
+	}
+	snapshot, err := s.newSnapshot(kv.NewVersion(startTS))
+	if err != nil {
+		s.removeActive(startTS)
+		return nil, errors.Trace(err)
+	}
+	return newTxn(s, snapshot, startTS), nil
+}
+
+// addActive registers a running transaction, so that GC keeps the versions it reads.
+func (s *dbStore) addActive(startTS uint64) error {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	if startTS < s.safePoint {
+		return kv.ErrGCTooEarly.GenWithStack("start ts %d is before GC safe point %d", startTS, s.safePoint)
+	}
+	s.active[startTS]++
+	return nil
+}
+
+func (s *dbStore) removeActive(startTS uint64) {
+	s.activeMu.Lock()
+	defer s.activeMu.Unlock()
+	if s.active[startTS]--; s.active[startTS] <= 0 {
+		delete(s.active, startTS)
+	}
+}
+
+// GetSnapshot gets a snapshot that is able to read any data which data is <= ver.
+func (s *dbStore) GetSnapshot(ver kv.Version) (kv.Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, errors.Trace(kv.ErrStoreClosed)
+	}
+	s.activeMu.Lock()
+	safePoint := s.safePoint
+	s.activeMu.Unlock()
+	if ver.Ver < safePoint {
+		return nil, kv.ErrGCTooEarly.GenWithStack("version %d is before GC safe point %d", ver.Ver, safePoint)
+	}
+	snapshot, err := s.newSnapshot(ver)
+	return snapshot, errors.Trace(err)
+}
+
+func (s *dbStore) newSnapshot(ver kv.Version) (*dbSnapshot, error) {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &dbSnapshot{store: s, snapshot: snapshot, version: ver}, nil
+}
+
+// CurrentVersion returns a version which can read all the committed data.
+func (s *dbStore) CurrentVersion() (kv.Version, error) {
+	return kv.NewVersion(s.currentTS()), nil
+}
+
+// UUID return a unique ID which represents a Storage.
+func (s *dbStore) UUID() string {
+	return s.uuid
+}
+
+// Close closes the store and the underlying engine.
+func (s *dbStore) Close() error {
+	mxStores.Lock()
+	delete(stores, s.path)
+	mxStores.Unlock()
+
+	if s.gcWorker != nil {
+		s.gcWorker.close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return errors.Trace(s.db.Close())
+}
+
+// commit writes the changes of the transaction as the versions of a new commit ts,
+// it fails with kv.ErrWriteConflict if any of the keys is locked by other pessimistic
+// transactions, or committed by others after the transaction reads it.
+func (s *dbStore) commit(txn *dbTxn) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return 0, errors.Trace(kv.ErrStoreClosed)
+	}
+
+	var (
+		keys   []kv.Key
+		values [][]byte
+	)
+	err := kv.WalkMemBuffer(txn.buffer, func(k kv.Key, v []byte) error {
+		keys = append(keys, k)
+		values = append(values, v)
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	for k := range txn.lockKeys {
+		if _, err = txn.buffer.Get(kv.Key(k)); kv.IsErrNotFound(err) {
+			keys = append(keys, kv.Key(k))
+			values = append(values, nil)
+		}
+	}
+
+	s.commitMu.Lock()
+	unlocked := false
+	defer func() {
+		if !unlocked {
+			s.commitMu.Unlock()
+		}
+	}()
+	// The locks are checked under commitMu, so a key can't be locked by others after the
+	// check and before the commit, as LockKeys allocates the lock ts under it.
+	if k, owner, ok := s.lockMgr.lockedByOthers(txn.startTS, keys); ok {
+		return 0, kv.ErrWriteConflict.GenWithStack("write conflict on key %q, start ts %d, locked by txn %d", k, txn.startTS, owner)
+	}
+	k, conflictTS, err := s.newestConflict(keys, txn.readTS)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if k == nil {
+		// The commits being made durable are not visible in the engine yet.
+		for _, key := range keys {
+			if ts, ok := s.inflight[string(key)]; ok && ts > txn.readTS(key) {
+				k, conflictTS = key, ts
+				break
+			}
+		}
+	}
+	if k != nil {
+		return 0, kv.ErrWriteConflict.GenWithStack("write conflict on key %q, start ts %d, conflict ts %d", k, txn.startTS, conflictTS)
+	}
+
+	commitTS := s.oracle.getTimestamp()
+	b := s.db.NewBatch()
+	for i, k := range keys {
+		var value []byte
+		switch {
+		case values[i] == nil:
+			value = mvccEncodeValue(flagLock, nil)
+		case len(values[i]) == 0:
+			value = mvccEncodeValue(flagDelete, nil)
+		default:
+			value = mvccEncodeValue(flagPut, values[i])
+		}
+		b.Put(mvccEncodeVersionKey(k, commitTS), value)
+	}
+	b.Put(lastCommitTSKey, codec.EncodeUint(nil, commitTS))
+
+	gc, ok := s.db.(engine.GroupCommitter)
+	if !ok {
+		return commitTS, errors.Trace(s.db.Commit(b))
+	}
+	// Wait for the batch to be durable outside the lock, so that the concurrent
+	// commits can be synced together. The batch is visible after it is durable, the
+	// ts allocated before then wait for it by lastCommit, and the conflicts with it
+	// are checked by inflight.
+	done := gc.CommitAsync(b)
+	prev, cur := s.lastCommit, make(chan struct{})
+	s.lastCommit = cur
+	for _, k := range keys {
+		s.inflight[string(k)] = commitTS
+	}
+	s.commitMu.Unlock()
+	unlocked = true
+
+	err = <-done
+	if prev != nil {
+		<-prev
+	}
+	s.commitMu.Lock()
+	for _, k := range keys {
+		if s.inflight[string(k)] == commitTS {
+			delete(s.inflight, string(k))
+		}
+	}
+	if s.lastCommit == cur {
+		s.lastCommit = nil
+	}
+	s.commitMu.Unlock()
+	close(cur)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return commitTS, nil
+}
+
+// newestConflict returns the first key of keys which has a version newer than the ts the
+// transaction reads it at, with the ts of the version.
+func (s *dbStore) newestConflict(keys []kv.Key, readTS func(kv.Key) uint64) (kv.Key, uint64, error) {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	defer snapshot.Release()
+	for _, k := range keys {
+		prefix := mvccEncodeKey(k)
+		it := snapshot.NewIterator(prefix)
+		if it.Next() && isVersionOf(it.Key(), prefix) {
+			_, ver, err := mvccDecode(it.Key())
+			if err != nil {
+				it.Release()
+				return nil, 0, errors.Trace(err)
+			}
+			if ver > readTS(k) {
+				it.Release()
+				return k, ver, nil
+			}
+		}
+		err = it.Error()
+		it.Release()
+		if err != nil {
+			return nil, 0, errors.Trace(err)
+		}
+	}
+	return nil, 0, nil
+}
@@ -0,0 +1,240 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+	"fedb/store/localstore/memory"
+)
+
+func newTestStore(t *testing.T) *dbStore {
+	s, err := Driver{Driver: memory.Driver{}}.Open("memory://")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s.(*dbStore)
+}
+
+func mustBegin(t *testing.T, s *dbStore) kv.Transaction {
+	txn, err := s.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txn
+}
+
+func mustCommit(t *testing.T, txn kv.Transaction) {
+	if err := txn.Commit(goctx.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mustPut writes the key in a transaction of its own, it's deleted if value is nil.
+func mustPut(t *testing.T, s *dbStore, key string, value []byte) {
+	txn := mustBegin(t, s)
+	var err error
+	if value == nil {
+		err = txn.Delete(kv.Key(key))
+	} else {
+		err = txn.Set(kv.Key(key), value)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustCommit(t, txn)
+}
+
+// mustGet checks the value of the key read by the transaction, nil means it doesn't exist.
+func mustGet(t *testing.T, txn kv.Transaction, key string, expected []byte) {
+	value, err := txn.Get(kv.Key(key))
+	if expected == nil {
+		if !kv.ErrNotExist.Equal(err) {
+			t.Fatalf("expected %q not to exist, got %q, %v", key, value, err)
+		}
+		return
+	}
+	if err != nil || !bytes.Equal(value, expected) {
+		t.Fatalf("expected %q of %q, got %q, %v", expected, key, value, err)
+	}
+}
+
+// scanKeys returns the keys and the values read by the transaction.
+func scanKeys(t *testing.T, txn kv.Transaction) string {
+	it, err := txn.Iter(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var b bytes.Buffer
+	for it.Valid() {
+		b.WriteString(string(it.Key()) + "=" + string(it.Value()) + " ")
+		if err = it.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.String()
+}
+
+// countVersions returns the number of the versions of the key in the engine.
+func countVersions(t *testing.T, s *dbStore, key string) int {
+	snapshot, err := s.db.GetSnapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Release()
+	prefix := mvccEncodeKey(kv.Key(key))
+	it := snapshot.NewIterator(prefix)
+	defer it.Release()
+	n := 0
+	for it.Next() && isVersionOf(it.Key(), prefix) {
+		n++
+	}
+	if err = it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSnapshotIsolation(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	mustPut(t, s, "a", []byte("1"))
+	mustPut(t, s, "b", []byte("1"))
+
+	txn := mustBegin(t, s)
+	mustPut(t, s, "a", []byte("2"))
+	mustPut(t, s, "b", nil)
+	mustPut(t, s, "c", []byte("2"))
+	// The changes committed after the transaction begins are not visible to it.
+	mustGet(t, txn, "a", []byte("1"))
+	mustGet(t, txn, "b", []byte("1"))
+	mustGet(t, txn, "c", nil)
+	if keys := scanKeys(t, txn); keys != "a=1 b=1 " {
+		t.Fatalf("unexpected keys %s", keys)
+	}
+	// The transaction reads its own writes.
+	if err := txn.Set(kv.Key("a"), []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, txn, "a", []byte("3"))
+	if err := txn.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	txn = mustBegin(t, s)
+	if keys := scanKeys(t, txn); keys != "a=2 c=2 " {
+		t.Fatalf("unexpected keys %s", keys)
+	}
+	mustCommit(t, txn)
+}
+
+func TestWriteConflict(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	mustPut(t, s, "a", []byte("1"))
+
+	txn1, txn2 := mustBegin(t, s), mustBegin(t, s)
+	if err := txn1.Set(kv.Key("a"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn2.Delete(kv.Key("a")); err != nil {
+		t.Fatal(err)
+	}
+	mustCommit(t, txn1)
+	if err := txn2.Commit(goctx.Background()); !kv.ErrWriteConflict.Equal(err) {
+		t.Fatalf("expected a write conflict, got %v", err)
+	}
+
+	// The transactions which only read the key or write the other keys don't conflict.
+	txn1, txn2 = mustBegin(t, s), mustBegin(t, s)
+	mustGet(t, txn2, "a", []byte("2"))
+	if err := txn2.Set(kv.Key("b"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := txn1.Set(kv.Key("a"), []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	mustCommit(t, txn1)
+	mustCommit(t, txn2)
+
+	// The transaction begun after the commit doesn't conflict with it.
+	txn := mustBegin(t, s)
+	mustGet(t, txn, "a", []byte("3"))
+	if err := txn.Set(kv.Key("a"), []byte("4")); err != nil {
+		t.Fatal(err)
+	}
+	mustCommit(t, txn)
+	txn = mustBegin(t, s)
+	mustGet(t, txn, "a", []byte("4"))
+	mustGet(t, txn, "b", []byte("1"))
+	mustCommit(t, txn)
+}
+
+func TestGCKeepsVisibleVersions(t *testing.T) {
+	s := newTestStore(t)
+	defer s.Close()
+	mustPut(t, s, "a", []byte("1"))
+	mustPut(t, s, "b", []byte("1"))
+
+	old := mustBegin(t, s)
+	mustPut(t, s, "a", []byte("2"))
+	mustPut(t, s, "a", []byte("3"))
+	mustPut(t, s, "b", nil)
+	if err := s.gc(0); err != nil {
+		t.Fatal(err)
+	}
+	// The versions read by the running transaction are kept.
+	mustGet(t, old, "a", []byte("1"))
+	mustGet(t, old, "b", []byte("1"))
+	if keys := scanKeys(t, old); keys != "a=1 b=1 " {
+		t.Fatalf("unexpected keys %s", keys)
+	}
+	if n := countVersions(t, s, "a"); n != 3 {
+		t.Fatalf("expected 3 versions of a, got %d", n)
+	}
+	if err := old.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The versions not visible to any transaction are removed once it finishes. The safe
+	// point is in milliseconds, so it passes the commits after a while.
+	time.Sleep(5 * time.Millisecond)
+	if err := s.gc(0); err != nil {
+		t.Fatal(err)
+	}
+	if n := countVersions(t, s, "a"); n != 1 {
+		t.Fatalf("expected 1 version of a, got %d", n)
+	}
+	if n := countVersions(t, s, "b"); n != 0 {
+		t.Fatalf("expected no version of the deleted b, got %d", n)
+	}
+	txn := mustBegin(t, s)
+	mustGet(t, txn, "a", []byte("3"))
+	mustGet(t, txn, "b", nil)
+	mustCommit(t, txn)
+
+	// The transactions and the snapshots before the safe point can't be begun.
+	if _, err := s.BeginWithStartTS(old.StartTS()); !kv.ErrGCTooEarly.Equal(err) {
+		t.Fatalf("expected the GC too early error, got %v", err)
+	}
+	if _, err := s.GetSnapshot(kv.NewVersion(old.StartTS())); !kv.ErrGCTooEarly.Equal(err) {
+		t.Fatalf("expected the GC too early error, got %v", err)
+	}
+}
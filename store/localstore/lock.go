@@ -0,0 +1,140 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+)
+
+// keyLock is a key locked by a pessimistic transaction, released is closed when
+// the lock is released, to wake up the transactions waiting for it.
+type keyLock struct {
+	owner    uint64
+	released chan struct{}
+}
+
+// lockManager holds the locks of the pessimistic transactions in memory. The
+// transactions are identified by the start ts. A transaction waits for at most
+// one lock at a time, so the wait-for graph is tracked by the lock each
+// transaction waits for, and a wait which closes a cycle fails with kv.ErrDeadlock.
+type lockManager struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+	// waitFor maps a waiting transaction to the owner of the lock it waits for.
+	waitFor map[uint64]uint64
+}
+
+func newLockManager() *lockManager {
+	return &lockManager{
+		locks:   make(map[string]*keyLock),
+		waitFor: make(map[uint64]uint64),
+	}
+}
+
+// acquire locks the key for the transaction txnID, it waits until the lock is
+// released by the owner, or fails when the wait takes longer than timeout, makes
+// a deadlock, or the goctx.Context is done.
+func (m *lockManager) acquire(goCtx goctx.Context, txnID uint64, key kv.Key, timeout time.Duration) error {
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+
+	m.mu.Lock()
+	for {
+		l, ok := m.locks[string(key)]
+		if !ok {
+			m.locks[string(key)] = &keyLock{owner: txnID, released: make(chan struct{})}
+			m.mu.Unlock()
+			return nil
+		}
+		if l.owner == txnID {
+			m.mu.Unlock()
+			return nil
+		}
+		if m.detectDeadlock(txnID, l.owner) {
+			m.mu.Unlock()
+			// The client gets the message of MySQL, the key and the transactions are only logged.
+			log.Infof("[kv] deadlock on key %q, txn %d waits for txn %d", key, txnID, l.owner)
+			return kv.ErrDeadlock.GenWithStackByArgs()
+		}
+		m.waitFor[txnID] = l.owner
+		m.mu.Unlock()
+
+		var err error
+		select {
+		case <-l.released:
+		case <-timer:
+			log.Infof("[kv] lock wait timeout on key %q, txn %d waits for txn %d", key, txnID, l.owner)
+			err = kv.ErrLockWaitTimeout.GenWithStackByArgs()
+		case <-goCtx.Done():
+			err = errors.Trace(goCtx.Err())
+		}
+
+		m.mu.Lock()
+		delete(m.waitFor, txnID)
+		if err != nil {
+			m.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// detectDeadlock checks whether txnID waiting for owner makes a cycle in the wait-for graph.
+// It must be called with mu held.
+func (m *lockManager) detectDeadlock(txnID, owner uint64) bool {
+	for {
+		if owner == txnID {
+			return true
+		}
+		next, ok := m.waitFor[owner]
+		if !ok {
+			return false
+		}
+		owner = next
+	}
+}
+
+// release releases the locks of the keys owned by the transaction.
+func (m *lockManager) release(txnID uint64, keys []kv.Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		if l, ok := m.locks[string(k)]; ok && l.owner == txnID {
+			delete(m.locks, string(k))
+			close(l.released)
+		}
+	}
+}
+
+// lockedByOthers returns the first key of keys which is locked by a transaction other than txnID.
+func (m *lockManager) lockedByOthers(txnID uint64, keys []kv.Key) (kv.Key, uint64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range keys {
+		if l, ok := m.locks[string(k)]; ok && l.owner != txnID {
+			return k, l.owner, true
+		}
+	}
+	return nil, 0, false
+}
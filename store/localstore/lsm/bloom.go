@@ -0,0 +1,78 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import "hash/fnv"
+
+// bloomBitsPerKey gives about 1% false positive rate.
+const bloomBitsPerKey = 10
+
+// bloomFilter is a bloom filter over the keys of a table, the last byte is the number of probes.
+type bloomFilter []byte
+
+func bloomHash(key []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(key)
+	return h.Sum32()
+}
+
+// newBloomFilter builds a filter from the hashes of the keys.
+func newBloomFilter(hashes []uint32) bloomFilter {
+	// k = bitsPerKey * ln(2), rounded down.
+	k := uint8(bloomBitsPerKey * 69 / 100)
+	if k < 1 {
+		k = 1
+	} else if k > 30 {
+		k = 30
+	}
+	nBits := len(hashes) * bloomBitsPerKey
+	if nBits < 64 {
+		nBits = 64
+	}
+	nBytes := (nBits + 7) / 8
+	nBits = nBytes * 8
+
+	filter := make([]byte, nBytes+1)
+	for _, h := range hashes {
+		// Double hashing as in the Kirsch-Mitzenmacher paper.
+		delta := h>>17 | h<<15
+		for j := uint8(0); j < k; j++ {
+			pos := h % uint32(nBits)
+			filter[pos/8] |= 1 << (pos % 8)
+			h += delta
+		}
+	}
+	filter[nBytes] = k
+	return filter
+}
+
+// mayContain returns false if the key is definitely not in the table.
+func (f bloomFilter) mayContain(key []byte) bool {
+	if len(f) < 2 {
+		return true
+	}
+	nBytes := len(f) - 1
+	nBits := uint32(nBytes * 8)
+	k := f[nBytes]
+	h := bloomHash(key)
+	delta := h>>17 | h<<15
+	for j := uint8(0); j < k; j++ {
+		pos := h % nBits
+		if f[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
@@ -0,0 +1,364 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"bytes"
+	"sort"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/store/localstore/engine"
+)
+
+// backgroundRetryInterval is the wait before retrying a failed flush or compaction.
+const backgroundRetryInterval = time.Second
+
+// backgroundLoop flushes the immutable memtable and runs the compactions one at a time.
+func (d *DB) backgroundLoop() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.bgCh:
+		case <-d.closeCh:
+			return
+		}
+		if err := d.backgroundWork(); err != nil {
+			log.Errorf("[lsm] background work error %v", errors.ErrorStack(err))
+			select {
+			case <-time.After(backgroundRetryInterval):
+				d.scheduleBackground()
+			case <-d.closeCh:
+				return
+			}
+		}
+	}
+}
+
+// backgroundWork does the work until there is nothing to flush or compact.
+func (d *DB) backgroundWork() error {
+	for {
+		select {
+		case <-d.closeCh:
+			return nil
+		default:
+		}
+
+		d.mu.Lock()
+		imm := d.imm
+		d.mu.Unlock()
+		if imm != nil {
+			if err := d.flushMemTable(imm); err != nil {
+				return errors.Trace(err)
+			}
+			continue
+		}
+
+		c := d.pickCompaction()
+		if c == nil {
+			return nil
+		}
+		if err := d.runCompaction(c); err != nil {
+			return errors.Trace(err)
+		}
+	}
+}
+
+// flushMemTable writes the immutable memtable into a level 0 file.
+func (d *DB) flushMemTable(imm *memTable) error {
+	start := time.Now()
+	levels := d.current.levels
+	var files []*tableFile
+	if imm.len() > 0 {
+		it := imm.newIterator(nil)
+		var err error
+		files, err = d.writeTables(it, 0)
+		it.Release()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		levels[0] = append(files, levels[0]...)
+	}
+
+	if err := d.installVersion(levels, imm.lastLSN, files); err != nil {
+		return errors.Trace(err)
+	}
+	d.mu.Lock()
+	d.imm = nil
+	d.roomCh.Broadcast()
+	d.mu.Unlock()
+	log.Infof("[lsm] flushed memtable of %d entries in %v", imm.len(), time.Since(start))
+
+	if err := d.log.TruncateBefore(imm.lastLSN + 1); err != nil {
+		log.Warnf("[lsm] truncate log error %v", errors.ErrorStack(err))
+	}
+	return nil
+}
+
+// installVersion persists the levels in the manifest and makes them the current version.
+// The files are the new files in the levels, they are removed if it fails.
+func (d *DB) installVersion(levels [numLevels][]*tableFile, logLSN uint64, files []*tableFile) error {
+	m := &manifest{NextFileNum: d.nextFileNum, LogLSN: logLSN}
+	for level, fs := range levels {
+		for _, f := range fs {
+			m.Levels[level] = append(m.Levels[level], f.tableMeta)
+		}
+	}
+	if err := m.save(d.dir); err != nil {
+		for _, f := range files {
+			f.refs = 1
+			f.unref()
+		}
+		return errors.Trace(err)
+	}
+	d.logLSN = logLSN
+
+	v := newVersion(levels)
+	d.mu.Lock()
+	old := d.current
+	d.current = v
+	d.roomCh.Broadcast()
+	d.mu.Unlock()
+	old.unref()
+	return nil
+}
+
+// writeTables writes the entries of the iterator into new table files, a file is
+// finished when it reaches maxSize, zero means no limit.
+func (d *DB) writeTables(it engine.Iterator, maxSize int64) ([]*tableFile, error) {
+	var (
+		files []*tableFile
+		w     *tableWriter
+		num   uint64
+	)
+	cleanup := func() {
+		for _, f := range files {
+			f.refs = 1
+			f.unref()
+		}
+	}
+	finish := func() error {
+		meta, err := w.finish()
+		w = nil
+		if err != nil {
+			return errors.Trace(err)
+		}
+		meta.Num = num
+		t, err := openTable(tableFileName(d.dir, num))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		files = append(files, &tableFile{tableMeta: *meta, dir: d.dir, t: t})
+		return nil
+	}
+
+	for it.Next() {
+		if w == nil {
+			num = d.nextFileNum
+			d.nextFileNum++
+			var err error
+			if w, err = newTableWriter(tableFileName(d.dir, num)); err != nil {
+				cleanup()
+				return nil, errors.Trace(err)
+			}
+		}
+		if err := w.add(it.Key(), it.Value()); err != nil {
+			w.abort()
+			cleanup()
+			return nil, errors.Trace(err)
+		}
+		if maxSize > 0 && int64(w.estimatedSize()) >= maxSize {
+			if err := finish(); err != nil {
+				cleanup()
+				return nil, errors.Trace(err)
+			}
+		}
+	}
+	// The entries after an error are missed, the files written are removed so that the
+	// inputs are kept.
+	if err := it.Error(); err != nil {
+		if w != nil {
+			w.abort()
+		}
+		cleanup()
+		return nil, errors.Trace(err)
+	}
+	if w != nil {
+		if err := finish(); err != nil {
+			cleanup()
+			return nil, errors.Trace(err)
+		}
+	}
+	if len(files) > 0 {
+		if err := syncDir(d.dir); err != nil {
+			cleanup()
+			return nil, errors.Trace(err)
+		}
+	}
+	return files, nil
+}
+
+// compaction merges the input files of a level into the overlapping files of the next level.
+type compaction struct {
+	level  int
+	inputs [2][]*tableFile
+}
+
+func (c *compaction) keyRange() (smallest, largest []byte) {
+	for _, files := range c.inputs {
+		for _, f := range files {
+			if smallest == nil || bytes.Compare(f.Smallest, smallest) < 0 {
+				smallest = f.Smallest
+			}
+			if largest == nil || bytes.Compare(f.Largest, largest) > 0 {
+				largest = f.Largest
+			}
+		}
+	}
+	return
+}
+
+func (d *DB) maxLevelSize(level int) int64 {
+	size := d.opts.BaseLevelSize
+	for ; level > 1; level-- {
+		size *= 10
+	}
+	return size
+}
+
+// pickCompaction picks the level which exceeds its limit the most.
+func (d *DB) pickCompaction() *compaction {
+	v := d.current
+	bestLevel, bestScore := -1, 1.0
+	if n := len(v.levels[0]); n >= d.opts.L0CompactionTrigger {
+		bestLevel, bestScore = 0, float64(n)/float64(d.opts.L0CompactionTrigger)
+	}
+	for level := 1; level < numLevels-1; level++ {
+		score := float64(v.levelSize(level)) / float64(d.maxLevelSize(level))
+		if score > bestScore {
+			bestLevel, bestScore = level, score
+		}
+	}
+	if bestLevel < 0 {
+		return nil
+	}
+
+	c := &compaction{level: bestLevel}
+	if bestLevel == 0 {
+		c.inputs[0] = v.levels[0]
+	} else {
+		// Pick the files in a round-robin way from the key after the last compaction of the level.
+		files := v.levels[bestLevel]
+		f := files[0]
+		if pointer := d.compactPointers[bestLevel]; pointer != nil {
+			for _, file := range files {
+				if bytes.Compare(file.Smallest, pointer) > 0 {
+					f = file
+					break
+				}
+			}
+		}
+		c.inputs[0] = []*tableFile{f}
+	}
+	smallest, largest := c.keyRange()
+	c.inputs[1] = v.overlapping(bestLevel+1, smallest, largest)
+	return c
+}
+
+// isBottom returns whether there is no data below the output level in the key range of the compaction,
+// then the deletions can be dropped.
+func (d *DB) isBottom(c *compaction) bool {
+	smallest, largest := c.keyRange()
+	for level := c.level + 2; level < numLevels; level++ {
+		if len(d.current.overlapping(level, smallest, largest)) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *DB) runCompaction(c *compaction) error {
+	start := time.Now()
+	levels := d.current.levels
+	var outputs []*tableFile
+
+	if c.level > 0 && len(c.inputs[0]) == 1 && len(c.inputs[1]) == 0 {
+		// Move the file to the next level without rewriting it.
+		outputs = c.inputs[0]
+	} else {
+		var iters []engine.Iterator
+		if c.level == 0 {
+			for _, f := range c.inputs[0] {
+				iters = append(iters, f.t.newIterator(nil))
+			}
+		} else {
+			iters = append(iters, newLevelIterator(c.inputs[0], nil))
+		}
+		iters = append(iters, newLevelIterator(c.inputs[1], nil))
+		it := newMergingIterator(iters, d.isBottom(c))
+		var err error
+		outputs, err = d.writeTables(it, d.opts.TableFileSize)
+		it.Release()
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	levels[c.level] = removeFiles(levels[c.level], c.inputs[0])
+	next := append(removeFiles(levels[c.level+1], c.inputs[1]), outputs...)
+	sort.Slice(next, func(i, j int) bool {
+		return bytes.Compare(next[i].Smallest, next[j].Smallest) < 0
+	})
+	levels[c.level+1] = next
+
+	var newFiles []*tableFile
+	if len(c.inputs[1]) > 0 || c.level == 0 {
+		newFiles = outputs
+	}
+	if err := d.installVersion(levels, d.logLSN, newFiles); err != nil {
+		return errors.Trace(err)
+	}
+	_, largest := c.keyRange()
+	d.compactPointers[c.level] = largest
+
+	var inputSize, outputSize int64
+	for _, files := range c.inputs {
+		for _, f := range files {
+			inputSize += f.Size
+		}
+	}
+	for _, f := range outputs {
+		outputSize += f.Size
+	}
+	log.Infof("[lsm] compacted %d+%d files of level %d (%d bytes) into %d files (%d bytes) in %v",
+		len(c.inputs[0]), len(c.inputs[1]), c.level, inputSize, len(outputs), outputSize, time.Since(start))
+	return nil
+}
+
+// removeFiles returns the files not in the removed ones, in a new slice.
+func removeFiles(files []*tableFile, removed []*tableFile) []*tableFile {
+	set := make(map[*tableFile]struct{}, len(removed))
+	for _, f := range removed {
+		set[f] = struct{}{}
+	}
+	result := make([]*tableFile, 0, len(files))
+	for _, f := range files {
+		if _, ok := set[f]; !ok {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,258 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"bytes"
+	"container/heap"
+	"sync"
+
+	"github.com/pingcap/errors"
+
+	"fedb/store/localstore/engine"
+)
+
+// mergingIterator merges the sorted iterators into one. The iterators are
+// ordered from the newest to the oldest, and only the newest entry of a key is
+// returned. The values are in the encoded form. It stops at the first error of
+// the iterators, as the entries after it may be shadowed by the ones missed.
+type mergingIterator struct {
+	iters []engine.Iterator
+	h     iterHeap
+	// skipDeletes skips the deletion entries instead of returning them.
+	skipDeletes bool
+	started     bool
+	key         []byte
+	value       []byte
+	err         error
+}
+
+func newMergingIterator(iters []engine.Iterator, skipDeletes bool) *mergingIterator {
+	return &mergingIterator{
+		iters:       iters,
+		h:           iterHeap{iters: iters},
+		skipDeletes: skipDeletes,
+	}
+}
+
+func (it *mergingIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		for i, iter := range it.iters {
+			if iter.Next() {
+				it.h.idx = append(it.h.idx, i)
+			} else if it.err = iter.Error(); it.err != nil {
+				break
+			}
+		}
+		heap.Init(&it.h)
+	}
+	for it.err == nil && it.h.Len() > 0 {
+		top := it.iters[it.h.idx[0]]
+		// The iterators may reuse the buffers of the key and value after Next.
+		key := append([]byte(nil), top.Key()...)
+		value := append([]byte(nil), top.Value()...)
+		// Skip the older entries of the same key.
+		for it.h.Len() > 0 && bytes.Equal(it.iters[it.h.idx[0]].Key(), key) {
+			i := it.h.idx[0]
+			if it.iters[i].Next() {
+				heap.Fix(&it.h, 0)
+			} else if it.err = it.iters[i].Error(); it.err != nil {
+				break
+			} else {
+				heap.Pop(&it.h)
+			}
+		}
+		if it.err != nil {
+			break
+		}
+		if it.skipDeletes {
+			if _, deleted := decodeValue(value); deleted {
+				continue
+			}
+		}
+		it.key, it.value = key, value
+		return true
+	}
+	it.key, it.value = nil, nil
+	return false
+}
+
+func (it *mergingIterator) Key() []byte {
+	return it.key
+}
+
+func (it *mergingIterator) Value() []byte {
+	return it.value
+}
+
+func (it *mergingIterator) Error() error {
+	return it.err
+}
+
+func (it *mergingIterator) Release() {
+	for _, iter := range it.iters {
+		iter.Release()
+	}
+	it.h.idx = nil
+}
+
+// iterHeap is a min-heap of the valid iterators, the newer iterator goes first for the same key.
+type iterHeap struct {
+	iters []engine.Iterator
+	idx   []int
+}
+
+func (h *iterHeap) Len() int {
+	return len(h.idx)
+}
+
+func (h *iterHeap) Less(i, j int) bool {
+	c := bytes.Compare(h.iters[h.idx[i]].Key(), h.iters[h.idx[j]].Key())
+	if c != 0 {
+		return c < 0
+	}
+	return h.idx[i] < h.idx[j]
+}
+
+func (h *iterHeap) Swap(i, j int) {
+	h.idx[i], h.idx[j] = h.idx[j], h.idx[i]
+}
+
+func (h *iterHeap) Push(x interface{}) {
+	h.idx = append(h.idx, x.(int))
+}
+
+func (h *iterHeap) Pop() interface{} {
+	n := len(h.idx)
+	x := h.idx[n-1]
+	h.idx = h.idx[:n-1]
+	return x
+}
+
+// levelIterator iterates over the disjoint sorted files of a level, opening one file at a time.
+type levelIterator struct {
+	files    []*tableFile
+	startKey []byte
+	next     int
+	cur      engine.Iterator
+	err      error
+}
+
+func newLevelIterator(files []*tableFile, startKey []byte) *levelIterator {
+	return &levelIterator{
+		files:    files,
+		startKey: startKey,
+		next:     searchFile(files, startKey),
+	}
+}
+
+func (it *levelIterator) Next() bool {
+	for it.err == nil {
+		if it.cur != nil {
+			if it.cur.Next() {
+				return true
+			}
+			it.err = it.cur.Error()
+			it.cur.Release()
+			it.cur = nil
+			continue
+		}
+		if it.next >= len(it.files) {
+			return false
+		}
+		it.cur = it.files[it.next].t.newIterator(it.startKey)
+		it.next++
+	}
+	return false
+}
+
+func (it *levelIterator) Key() []byte {
+	return it.cur.Key()
+}
+
+func (it *levelIterator) Value() []byte {
+	return it.cur.Value()
+}
+
+func (it *levelIterator) Error() error {
+	return it.err
+}
+
+func (it *levelIterator) Release() {
+	if it.cur != nil {
+		it.cur.Release()
+		it.cur = nil
+	}
+	it.next = len(it.files)
+}
+
+// snapshot reads the memtables and the table files at the time it is taken.
+// The active memtable is still shared with the writers, so the snapshot sees
+// the writes committed after it as the other local engines do.
+type snapshot struct {
+	mem  *memTable
+	imm  *memTable
+	v    *version
+	once sync.Once
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	if v, found := s.mem.get(key); found {
+		value, _ := decodeValue(v)
+		return value, nil
+	}
+	if s.imm != nil {
+		if v, found := s.imm.get(key); found {
+			value, _ := decodeValue(v)
+			return value, nil
+		}
+	}
+	v, found, err := s.v.get(key)
+	if err != nil || !found {
+		return nil, errors.Trace(err)
+	}
+	value, _ := decodeValue(v)
+	return value, nil
+}
+
+func (s *snapshot) NewIterator(startKey []byte) engine.Iterator {
+	iters := []engine.Iterator{s.mem.newIterator(startKey)}
+	if s.imm != nil {
+		iters = append(iters, s.imm.newIterator(startKey))
+	}
+	for _, f := range s.v.levels[0] {
+		iters = append(iters, f.t.newIterator(startKey))
+	}
+	for level := 1; level < numLevels; level++ {
+		if len(s.v.levels[level]) > 0 {
+			iters = append(iters, newLevelIterator(s.v.levels[level], startKey))
+		}
+	}
+	return &dbIterator{newMergingIterator(iters, true)}
+}
+
+func (s *snapshot) Release() {
+	s.once.Do(s.v.unref)
+}
+
+// dbIterator returns the user values of a merging iterator.
+type dbIterator struct {
+	*mergingIterator
+}
+
+func (it *dbIterator) Value() []byte {
+	value, _ := decodeValue(it.mergingIterator.Value())
+	return value
+}
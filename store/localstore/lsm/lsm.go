@@ -0,0 +1,362 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package lsm implements a log-structured merge-tree storage engine.
+//
+// The writes go to the write-ahead log and a skiplist memtable. A full memtable
+// becomes immutable and is flushed into a sorted table file in level 0, and the
+// files are merged into the lower levels by the background compaction, so the
+// random writes are turned into sequential file writes.
+package lsm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/config"
+	"fedb/store/localstore/engine"
+	"fedb/store/localstore/wal"
+)
+
+const walDir = "wal"
+
+// Driver implements engine Driver, the options are taken from the global config.
+type Driver struct {
+}
+
+// Open opens or creates a LSM DB in the directory of the schema.
+func (driver Driver) Open(schema string) (engine.DB, error) {
+	if schema == "" {
+		return nil, errors.New("lsm: data directory is not specified")
+	}
+	cfg := config.GetGlobalConfig()
+	policy, err := wal.ParseSyncPolicy(cfg.SyncPolicy)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return Open(schema, Options{
+		SyncPolicy:   policy,
+		SyncInterval: cfg.SyncInterval,
+	})
+}
+
+// Options is the options of a LSM DB, the zero values are replaced by the defaults.
+type Options struct {
+	// SyncPolicy decides when the write-ahead log is synced.
+	SyncPolicy wal.SyncPolicy
+	// SyncInterval is the sync period of the write-ahead log for wal.SyncInterval.
+	SyncInterval time.Duration
+	// SegmentSize is the size of the write-ahead log segment files.
+	SegmentSize int64
+
+	// MemTableSize is the size to switch to a new memtable.
+	MemTableSize int
+	// TableFileSize is the target size of the files written by the compaction.
+	TableFileSize int64
+	// BaseLevelSize is the max size of level 1, every lower level is 10 times larger.
+	BaseLevelSize int64
+	// L0CompactionTrigger is the number of level 0 files to start a compaction.
+	L0CompactionTrigger int
+	// L0StopWritesTrigger is the number of level 0 files to block the writes until the compaction catches up.
+	L0StopWritesTrigger int
+}
+
+func (opts *Options) fillDefaults() {
+	if opts.MemTableSize <= 0 {
+		opts.MemTableSize = 4 * 1024 * 1024
+	}
+	if opts.TableFileSize <= 0 {
+		opts.TableFileSize = 2 * 1024 * 1024
+	}
+	if opts.BaseLevelSize <= 0 {
+		opts.BaseLevelSize = 10 * 1024 * 1024
+	}
+	if opts.L0CompactionTrigger <= 0 {
+		opts.L0CompactionTrigger = 4
+	}
+	if opts.L0StopWritesTrigger <= opts.L0CompactionTrigger {
+		opts.L0StopWritesTrigger = opts.L0CompactionTrigger * 3
+	}
+}
+
+// DB is the LSM engine.
+type DB struct {
+	dir  string
+	opts Options
+	log  *wal.Log
+
+	// applyQueue applies the batches to the memtable after they are durable.
+	applyQueue *engine.ApplyQueue
+
+	// mu protects the fields below. The batches are logged and queued to be applied
+	// under it, so that they are in the same order in the log and in the memtable.
+	mu      sync.Mutex
+	roomCh  *sync.Cond
+	mem     *memTable
+	imm     *memTable
+	current *version
+	closed  bool
+
+	// The fields below are only used by the background goroutine after the DB is opened.
+	nextFileNum     uint64
+	logLSN          uint64
+	compactPointers [numLevels][]byte
+
+	bgCh    chan struct{}
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens the LSM DB in the dir, the data in the memtable is recovered from the log.
+func Open(dir string, opts Options) (*DB, error) {
+	opts.fillDefaults()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	d := &DB{
+		dir:     dir,
+		opts:    opts,
+		mem:     newMemTable(),
+		bgCh:    make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+	d.roomCh = sync.NewCond(&d.mu)
+	if err := d.recover(); err != nil {
+		if d.current != nil {
+			d.closeTables()
+		}
+		if d.log != nil {
+			d.log.Close()
+		}
+		return nil, errors.Trace(err)
+	}
+
+	d.applyQueue = engine.NewApplyQueue(d.apply)
+	d.wg.Add(1)
+	go d.backgroundLoop()
+	d.scheduleBackground()
+	return d, nil
+}
+
+func (d *DB) recover() error {
+	m, err := loadManifest(d.dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	d.nextFileNum = m.NextFileNum
+	d.logLSN = m.LogLSN
+
+	var levels [numLevels][]*tableFile
+	live := make(map[uint64]struct{})
+	for level, metas := range m.Levels {
+		for _, meta := range metas {
+			t, err := openTable(tableFileName(d.dir, meta.Num))
+			if err != nil {
+				d.current = newVersion(levels)
+				return errors.Trace(err)
+			}
+			levels[level] = append(levels[level], &tableFile{tableMeta: meta, dir: d.dir, t: t})
+			live[meta.Num] = struct{}{}
+		}
+	}
+	d.current = newVersion(levels)
+	d.removeObsoleteTables(live)
+
+	d.log, err = wal.Open(filepath.Join(d.dir, walDir), wal.Options{
+		SegmentSize:  d.opts.SegmentSize,
+		SyncPolicy:   d.opts.SyncPolicy,
+		SyncInterval: d.opts.SyncInterval,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	d.mem.lastLSN = d.logLSN
+	replayed := 0
+	err = d.log.Replay(d.logLSN, func(lsn uint64, data []byte) error {
+		batch, err1 := engine.DecodeMemBatch(data)
+		if err1 != nil {
+			return errors.Trace(err1)
+		}
+		if err1 = d.mem.apply(batch); err1 != nil {
+			return errors.Trace(err1)
+		}
+		d.mem.lastLSN = lsn
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Infof("[lsm] opened %s with %d table files, replayed %d batches from log", d.dir, len(live), replayed)
+	return nil
+}
+
+// removeObsoleteTables removes the table files left by an interrupted flush or compaction.
+func (d *DB) removeObsoleteTables(live map[uint64]struct{}) {
+	fis, err := ioutil.ReadDir(d.dir)
+	if err != nil {
+		log.Warnf("[lsm] read dir %s error %v", d.dir, err)
+		return
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasSuffix(name, tableExt) {
+			continue
+		}
+		num, err := strconv.ParseUint(strings.TrimSuffix(name, tableExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := live[num]; !ok {
+			log.Infof("[lsm] remove obsolete table %s", name)
+			os.Remove(filepath.Join(d.dir, name))
+		}
+	}
+}
+
+// Get implements the DB Get interface.
+func (d *DB) Get(key []byte) ([]byte, error) {
+	s, err := d.GetSnapshot()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer s.Release()
+	return s.Get(key)
+}
+
+// GetSnapshot implements the DB GetSnapshot interface.
+func (d *DB) GetSnapshot() (engine.Snapshot, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, errors.New("lsm db is closed")
+	}
+	d.current.ref()
+	return &snapshot{mem: d.mem, imm: d.imm, v: d.current}, nil
+}
+
+// NewBatch implements the DB NewBatch interface.
+func (d *DB) NewBatch() engine.Batch {
+	return engine.NewMemBatch()
+}
+
+// Commit implements the DB Commit interface.
+func (d *DB) Commit(b engine.Batch) error {
+	return <-d.CommitAsync(b)
+}
+
+// CommitAsync implements the GroupCommitter interface.
+// The channel receives the result after the batch is logged according to the sync policy
+// and applied, the batch is visible then.
+func (d *DB) CommitAsync(b engine.Batch) <-chan error {
+	done := make(chan error, 1)
+	batch, ok := b.(*engine.MemBatch)
+	if !ok {
+		done <- errors.Errorf("invalid batch type %T", b)
+		return done
+	}
+	data := batch.Encode()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.makeRoomForWrite(); err != nil {
+		done <- errors.Trace(err)
+		return done
+	}
+	lsn, logged := d.log.Submit(data)
+	if lsn == 0 {
+		return logged
+	}
+	return d.applyQueue.Push(lsn, batch, logged)
+}
+
+// apply applies the logged batch to the current memtable.
+func (d *DB) apply(lsn uint64, batch *engine.MemBatch) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.mem.apply(batch); err != nil {
+		return errors.Annotatef(err, "apply batch %d", lsn)
+	}
+	d.mem.lastLSN = lsn
+	return nil
+}
+
+// makeRoomForWrite switches to a new memtable when the current one is full,
+// and stalls the writes when the flush or the level 0 compaction falls behind.
+// It must be called with mu held.
+func (d *DB) makeRoomForWrite() error {
+	for {
+		if d.closed {
+			return errors.New("lsm db is closed")
+		}
+		switch {
+		case len(d.current.levels[0]) >= d.opts.L0StopWritesTrigger:
+			d.scheduleBackground()
+			d.roomCh.Wait()
+		case d.mem.size() < d.opts.MemTableSize:
+			return nil
+		case d.imm != nil:
+			d.roomCh.Wait()
+		default:
+			// Start a new log segment, so that the log of the immutable memtable can be removed after it is flushed.
+			if err := d.log.Rotate(); err != nil {
+				return errors.Trace(err)
+			}
+			d.imm = d.mem
+			d.mem = newMemTable()
+			d.mem.lastLSN = d.imm.lastLSN
+			d.scheduleBackground()
+		}
+	}
+}
+
+func (d *DB) scheduleBackground() {
+	select {
+	case d.bgCh <- struct{}{}:
+	default:
+	}
+}
+
+// Close implements the DB Close interface. The memtable is not flushed, it is
+// recovered from the log on the next open.
+func (d *DB) Close() error {
+	d.applyQueue.Close()
+	d.mu.Lock()
+	d.closed = true
+	d.roomCh.Broadcast()
+	d.mu.Unlock()
+
+	close(d.closeCh)
+	d.wg.Wait()
+
+	err := d.log.Close()
+	d.closeTables()
+	return errors.Trace(err)
+}
+
+// closeTables closes the table files without removing them.
+func (d *DB) closeTables() {
+	for _, files := range d.current.levels {
+		for _, f := range files {
+			f.t.close()
+		}
+	}
+}
@@ -0,0 +1,144 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pingcap/errors"
+
+	"fedb/store/localstore/engine"
+)
+
+// reusingIterator reuses the buffers of the key and value on Next like the memdb iterators.
+type reusingIterator struct {
+	entries [][2]string
+	pos     int
+	key     []byte
+	value   []byte
+}
+
+func (it *reusingIterator) Next() bool {
+	it.pos++
+	if it.pos >= len(it.entries) {
+		return false
+	}
+	it.key = append(it.key[:0], it.entries[it.pos][0]...)
+	it.value = append(it.value[:0], it.entries[it.pos][1]...)
+	return true
+}
+
+func (it *reusingIterator) Key() []byte   { return it.key }
+func (it *reusingIterator) Value() []byte { return it.value }
+func (it *reusingIterator) Error() error  { return nil }
+func (it *reusingIterator) Release()      {}
+
+func newReusingIterator(entries ...[2]string) *reusingIterator {
+	return &reusingIterator{entries: entries, pos: -1}
+}
+
+func TestMergingIteratorValues(t *testing.T) {
+	it := newMergingIterator([]engine.Iterator{
+		newReusingIterator([2]string{"a", "new"}, [2]string{"b", "xyz"}),
+		newReusingIterator([2]string{"a", "old"}, [2]string{"c", "zzz"}),
+	}, false)
+	defer it.Release()
+	var got []string
+	for it.Next() {
+		got = append(got, string(it.Key())+"="+string(it.Value()))
+	}
+	if fmt.Sprint(got) != "[a=new b=xyz c=zzz]" {
+		t.Fatalf("unexpected entries %v", got)
+	}
+}
+
+func listTables(t *testing.T, dir string) []string {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+tableExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestCompactionReadError checks that a compaction stops at a broken input table, and
+// keeps the inputs without writing a truncated output.
+func TestCompactionReadError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d, err := Open(dir, Options{MemTableSize: 4096, L0CompactionTrigger: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	value := make([]byte, 100)
+	for i := 0; i < 1000; i++ {
+		b := d.NewBatch()
+		b.Put([]byte(fmt.Sprintf("key%05d", i)), value)
+		if err = d.Commit(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var files []*tableFile
+	for deadline := time.Now().Add(10 * time.Second); ; {
+		d.mu.Lock()
+		files, flushing := d.current.levels[0], d.imm != nil
+		d.mu.Unlock()
+		if len(files) >= 2 && !flushing {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the memtables are not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	d.mu.Lock()
+	files = d.current.levels[0]
+	d.mu.Unlock()
+
+	// Break the first data block of the oldest file.
+	f, err := os.OpenFile(tableFileName(dir, files[len(files)-1].Num), os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 8); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	before := listTables(t, dir)
+	err = d.runCompaction(&compaction{level: 0, inputs: [2][]*tableFile{files, nil}})
+	if errors.Cause(err) != errBadTable {
+		t.Fatalf("expected errBadTable, got %v", err)
+	}
+	if after := listTables(t, dir); fmt.Sprint(after) != fmt.Sprint(before) {
+		t.Fatalf("expected the tables %v to be kept, got %v", before, after)
+	}
+	d.mu.Lock()
+	n := len(d.current.levels[0])
+	d.mu.Unlock()
+	if n != len(files) {
+		t.Fatalf("expected %d level 0 files, got %d", len(files), n)
+	}
+}
@@ -0,0 +1,91 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"github.com/pingcap/goleveldb/leveldb"
+	"github.com/pingcap/goleveldb/leveldb/comparer"
+	"github.com/pingcap/goleveldb/leveldb/memdb"
+	"github.com/pingcap/goleveldb/leveldb/util"
+
+	"fedb/store/localstore/engine"
+)
+
+// The values inside the engine are prefixed by a kind byte, so that a deletion
+// can shadow the older values of the key in the lower levels until it is compacted.
+const (
+	kindDelete byte = 0
+	kindPut    byte = 1
+)
+
+func encodeValue(kind byte, value []byte) []byte {
+	buf := make([]byte, 1+len(value))
+	buf[0] = kind
+	copy(buf[1:], value)
+	return buf
+}
+
+// decodeValue returns the user value and whether the entry is a deletion.
+func decodeValue(v []byte) ([]byte, bool) {
+	if len(v) == 0 || v[0] == kindDelete {
+		return nil, true
+	}
+	return v[1:], false
+}
+
+// memTable is the in-memory sorted table receiving the writes, it is based on a skiplist.
+type memTable struct {
+	db *memdb.DB
+	// lastLSN is the LSN of the last batch in the table, the log before it can be
+	// removed once the table is flushed.
+	lastLSN uint64
+}
+
+func newMemTable() *memTable {
+	return &memTable{db: memdb.New(comparer.DefaultComparer, 4*1024*1024)}
+}
+
+func (m *memTable) apply(batch *engine.MemBatch) error {
+	for _, op := range batch.Ops {
+		kind := kindPut
+		if op.Delete {
+			kind = kindDelete
+		}
+		if err := m.db.Put(op.Key, encodeValue(kind, op.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get returns the encoded value of the key, found is false if the table doesn't contain the key.
+func (m *memTable) get(key []byte) (value []byte, found bool) {
+	v, err := m.db.Get(key)
+	if err == leveldb.ErrNotFound || err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+func (m *memTable) size() int {
+	return m.db.Size()
+}
+
+func (m *memTable) len() int {
+	return m.db.Len()
+}
+
+func (m *memTable) newIterator(startKey []byte) engine.Iterator {
+	return m.db.NewIterator(&util.Range{Start: startKey})
+}
@@ -0,0 +1,404 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pingcap/errors"
+
+	"fedb/store/localstore/engine"
+)
+
+// The SSTable file layout:
+//
+//	data block | ... | data block | index block | bloom block | footer
+//
+// A data block is a sequence of entries, uvarint(len(key)) | key | uvarint(len(value)) | value,
+// the index block has an entry for every data block, uvarint(len(lastKey)) | lastKey |
+// uvarint(offset) | uvarint(size). Every block ends with its crc32, and the fixed-size
+// footer locates the index and bloom blocks.
+const (
+	tableExt        = ".sst"
+	blockSize       = 4 * 1024
+	footerSize      = 48
+	blockTrailerLen = 4
+	tableMagic      = uint64(0x4645444253535431) // "FEDBSST1"
+)
+
+var (
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+	errBadTable = errors.New("lsm: corrupted table")
+)
+
+func tableFileName(dir string, num uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%06d%s", num, tableExt))
+}
+
+type blockHandle struct {
+	lastKey []byte
+	offset  uint64
+	size    uint64
+}
+
+// tableWriter writes the sorted entries into a new table file.
+type tableWriter struct {
+	f      *os.File
+	w      *bufio.Writer
+	offset uint64
+
+	block    []byte
+	lastKey  []byte
+	index    []blockHandle
+	hashes   []uint32
+	count    uint64
+	smallest []byte
+}
+
+func newTableWriter(path string) (*tableWriter, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &tableWriter{f: f, w: bufio.NewWriterSize(f, 64*1024)}, nil
+}
+
+// add appends an entry, the keys must be added in increasing order.
+func (w *tableWriter) add(key, value []byte) error {
+	if w.count == 0 {
+		w.smallest = append([]byte(nil), key...)
+	}
+	w.block = appendBytes(w.block, key)
+	w.block = appendBytes(w.block, value)
+	w.lastKey = append(w.lastKey[:0], key...)
+	w.hashes = append(w.hashes, bloomHash(key))
+	w.count++
+	if len(w.block) >= blockSize {
+		return errors.Trace(w.flushBlock())
+	}
+	return nil
+}
+
+func (w *tableWriter) writeBlock(block []byte) (uint64, uint64, error) {
+	var trailer [blockTrailerLen]byte
+	binary.LittleEndian.PutUint32(trailer[:], crc32.Checksum(block, crcTable))
+	if _, err := w.w.Write(block); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if _, err := w.w.Write(trailer[:]); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	offset, size := w.offset, uint64(len(block)+blockTrailerLen)
+	w.offset += size
+	return offset, size, nil
+}
+
+func (w *tableWriter) flushBlock() error {
+	if len(w.block) == 0 {
+		return nil
+	}
+	offset, size, err := w.writeBlock(w.block)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	w.index = append(w.index, blockHandle{
+		lastKey: append([]byte(nil), w.lastKey...),
+		offset:  offset,
+		size:    size,
+	})
+	w.block = w.block[:0]
+	return nil
+}
+
+// estimatedSize returns the size of the file if it is finished now.
+func (w *tableWriter) estimatedSize() uint64 {
+	return w.offset + uint64(len(w.block))
+}
+
+// finish writes the index, the bloom filter and the footer, then syncs and closes the file.
+func (w *tableWriter) finish() (*tableMeta, error) {
+	defer w.f.Close()
+	if err := w.flushBlock(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var index []byte
+	for _, h := range w.index {
+		index = appendBytes(index, h.lastKey)
+		index = binary.AppendUvarint(index, h.offset)
+		index = binary.AppendUvarint(index, h.size)
+	}
+	indexOffset, indexSize, err := w.writeBlock(index)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	bloomOffset, bloomSize, err := w.writeBlock(newBloomFilter(w.hashes))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	var footer [footerSize]byte
+	binary.LittleEndian.PutUint64(footer[0:], indexOffset)
+	binary.LittleEndian.PutUint64(footer[8:], indexSize)
+	binary.LittleEndian.PutUint64(footer[16:], bloomOffset)
+	binary.LittleEndian.PutUint64(footer[24:], bloomSize)
+	binary.LittleEndian.PutUint64(footer[32:], w.count)
+	binary.LittleEndian.PutUint64(footer[40:], tableMagic)
+	if _, err = w.w.Write(footer[:]); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = w.w.Flush(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err = w.f.Sync(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &tableMeta{
+		Size:     int64(w.offset) + footerSize,
+		Count:    w.count,
+		Smallest: w.smallest,
+		Largest:  append([]byte(nil), w.lastKey...),
+	}, nil
+}
+
+// abort closes and removes the unfinished file.
+func (w *tableWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// table is an opened table file, its index and bloom filter are kept in memory.
+type table struct {
+	f     *os.File
+	index []blockHandle
+	bloom bloomFilter
+}
+
+func openTable(path string) (*table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	t := &table{f: f}
+	if err = t.load(); err != nil {
+		f.Close()
+		return nil, errors.Annotatef(err, "open table %s", path)
+	}
+	return t, nil
+}
+
+func (t *table) load() error {
+	fi, err := t.f.Stat()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if fi.Size() < footerSize {
+		return errors.Trace(errBadTable)
+	}
+	var footer [footerSize]byte
+	if _, err = t.f.ReadAt(footer[:], fi.Size()-footerSize); err != nil {
+		return errors.Trace(err)
+	}
+	if binary.LittleEndian.Uint64(footer[40:]) != tableMagic {
+		return errors.Trace(errBadTable)
+	}
+	index, err := t.readBlock(binary.LittleEndian.Uint64(footer[0:]), binary.LittleEndian.Uint64(footer[8:]))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for len(index) > 0 {
+		var h blockHandle
+		if h.lastKey, index, err = readBytes(index); err != nil {
+			return errors.Trace(err)
+		}
+		if h.offset, index, err = readUvarint(index); err != nil {
+			return errors.Trace(err)
+		}
+		if h.size, index, err = readUvarint(index); err != nil {
+			return errors.Trace(err)
+		}
+		t.index = append(t.index, h)
+	}
+	bloom, err := t.readBlock(binary.LittleEndian.Uint64(footer[16:]), binary.LittleEndian.Uint64(footer[24:]))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	t.bloom = bloom
+	return nil
+}
+
+// readBlock reads a block and verifies its checksum, the trailer is removed.
+func (t *table) readBlock(offset, size uint64) ([]byte, error) {
+	if size < blockTrailerLen {
+		return nil, errors.Trace(errBadTable)
+	}
+	buf := make([]byte, size)
+	if _, err := t.f.ReadAt(buf, int64(offset)); err != nil {
+		return nil, errors.Trace(err)
+	}
+	data := buf[:size-blockTrailerLen]
+	if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(buf[size-blockTrailerLen:]) {
+		return nil, errors.Trace(errBadTable)
+	}
+	return data, nil
+}
+
+// seekBlock returns the index of the first block which may contain keys >= key.
+func (t *table) seekBlock(key []byte) int {
+	return sort.Search(len(t.index), func(i int) bool {
+		return bytes.Compare(t.index[i].lastKey, key) >= 0
+	})
+}
+
+// get returns the encoded value of the key, found is false if the table doesn't contain the key.
+func (t *table) get(key []byte) (value []byte, found bool, err error) {
+	if !t.bloom.mayContain(key) {
+		return nil, false, nil
+	}
+	i := t.seekBlock(key)
+	if i >= len(t.index) {
+		return nil, false, nil
+	}
+	block, err := t.readBlock(t.index[i].offset, t.index[i].size)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	for len(block) > 0 {
+		var k, v []byte
+		if k, block, err = readBytes(block); err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		if v, block, err = readBytes(block); err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		switch bytes.Compare(k, key) {
+		case 0:
+			return v, true, nil
+		case 1:
+			return nil, false, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (t *table) close() error {
+	return errors.Trace(t.f.Close())
+}
+
+func (t *table) newIterator(startKey []byte) engine.Iterator {
+	return &tableIterator{t: t, startKey: startKey, block: -1}
+}
+
+// tableIterator iterates over the entries of a table, reading one block at a time.
+type tableIterator struct {
+	t        *table
+	startKey []byte
+	block    int
+	data     []byte
+	key      []byte
+	value    []byte
+	err      error
+}
+
+func (it *tableIterator) Next() bool {
+	for {
+		if len(it.data) > 0 {
+			var err error
+			if it.key, it.data, err = readBytes(it.data); err == nil {
+				it.value, it.data, err = readBytes(it.data)
+			}
+			if err != nil {
+				it.fail(err)
+				return false
+			}
+			if it.startKey != nil && bytes.Compare(it.key, it.startKey) < 0 {
+				continue
+			}
+			return true
+		}
+
+		if it.block < 0 {
+			it.block = it.t.seekBlock(it.startKey)
+		} else {
+			it.block++
+		}
+		if it.block >= len(it.t.index) {
+			it.key, it.value = nil, nil
+			return false
+		}
+		h := it.t.index[it.block]
+		data, err := it.t.readBlock(h.offset, h.size)
+		if err != nil {
+			it.fail(err)
+			return false
+		}
+		it.data = data
+	}
+}
+
+// fail stops the iterator with the error.
+func (it *tableIterator) fail(err error) {
+	it.err = errors.Annotatef(err, "read table %s", it.t.f.Name())
+	it.block = len(it.t.index)
+	it.data = nil
+	it.key, it.value = nil, nil
+}
+
+func (it *tableIterator) Key() []byte {
+	return it.key
+}
+
+func (it *tableIterator) Value() []byte {
+	return it.value
+}
+
+func (it *tableIterator) Error() error {
+	return it.err
+}
+
+func (it *tableIterator) Release() {
+	it.data = nil
+}
+
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func readUvarint(data []byte) (uint64, []byte, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, nil, errBadTable
+	}
+	return v, data[n:], nil
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	l, data, err := readUvarint(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(data)) < l {
+		return nil, nil, errBadTable
+	}
+	return data[:l:l], data[l:], nil
+}
@@ -0,0 +1,218 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package lsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	numLevels = 7
+
+	manifestFile = "MANIFEST"
+	manifestTmp  = "MANIFEST.tmp"
+)
+
+// tableMeta is the persistent description of a table file.
+type tableMeta struct {
+	Num      uint64
+	Size     int64
+	Count    uint64
+	Smallest []byte
+	Largest  []byte
+}
+
+// overlaps returns whether the key range of the table intersects [smallest, largest].
+func (m *tableMeta) overlaps(smallest, largest []byte) bool {
+	return bytes.Compare(m.Largest, smallest) >= 0 && bytes.Compare(m.Smallest, largest) <= 0
+}
+
+// tableFile is a table file referenced by the versions.
+type tableFile struct {
+	tableMeta
+	dir string
+	t   *table
+	// refs is the number of the versions containing the file, the file is removed when it drops to zero.
+	refs int32
+}
+
+func (f *tableFile) ref() {
+	atomic.AddInt32(&f.refs, 1)
+}
+
+func (f *tableFile) unref() {
+	if atomic.AddInt32(&f.refs, -1) > 0 {
+		return
+	}
+	f.t.close()
+	path := tableFileName(f.dir, f.Num)
+	if err := os.Remove(path); err != nil {
+		log.Warnf("[lsm] remove obsolete table %s error %v", path, err)
+	}
+}
+
+// version is an immutable set of the table files in the levels. Level 0 files
+// may overlap and are ordered from the newest to the oldest, the files in other
+// levels are disjoint and ordered by key.
+type version struct {
+	levels [numLevels][]*tableFile
+	// refs is held by the DB for the current version and by the snapshots and compactions using it.
+	refs int32
+}
+
+func newVersion(levels [numLevels][]*tableFile) *version {
+	v := &version{levels: levels, refs: 1}
+	for _, files := range levels {
+		for _, f := range files {
+			f.ref()
+		}
+	}
+	return v
+}
+
+func (v *version) ref() {
+	atomic.AddInt32(&v.refs, 1)
+}
+
+func (v *version) unref() {
+	if atomic.AddInt32(&v.refs, -1) > 0 {
+		return
+	}
+	for _, files := range v.levels {
+		for _, f := range files {
+			f.unref()
+		}
+	}
+}
+
+func (v *version) levelSize(level int) int64 {
+	var size int64
+	for _, f := range v.levels[level] {
+		size += f.Size
+	}
+	return size
+}
+
+// overlapping returns the files in the level overlapping [smallest, largest].
+func (v *version) overlapping(level int, smallest, largest []byte) []*tableFile {
+	var files []*tableFile
+	for _, f := range v.levels[level] {
+		if f.overlaps(smallest, largest) {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// get looks up the key from the newest data to the oldest.
+func (v *version) get(key []byte) (value []byte, found bool, err error) {
+	for _, f := range v.levels[0] {
+		if !f.overlaps(key, key) {
+			continue
+		}
+		if value, found, err = f.t.get(key); err != nil || found {
+			return
+		}
+	}
+	for level := 1; level < numLevels; level++ {
+		files := v.levels[level]
+		i := searchFile(files, key)
+		if i < len(files) && bytes.Compare(files[i].Smallest, key) <= 0 {
+			if value, found, err = files[i].t.get(key); err != nil || found {
+				return
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+// searchFile returns the first file whose largest key >= key in a sorted level.
+func searchFile(files []*tableFile, key []byte) int {
+	lo, hi := 0, len(files)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if bytes.Compare(files[mid].Largest, key) < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// manifest is the persistent state of the DB, it is rewritten atomically on every change of the version.
+type manifest struct {
+	NextFileNum uint64
+	// LogLSN is the LSN of the last batch persisted in the table files, the log before it is not needed.
+	LogLSN uint64
+	Levels [numLevels][]tableMeta
+}
+
+func loadManifest(dir string) (*manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, manifestFile))
+	if os.IsNotExist(err) {
+		return &manifest{NextFileNum: 1}, nil
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	m := &manifest{}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, errors.Annotate(err, "lsm: corrupted manifest")
+	}
+	return m, nil
+}
+
+func (m *manifest) save(dir string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tmpPath := filepath.Join(dir, manifestTmp)
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = os.Rename(tmpPath, filepath.Join(dir, manifestFile)); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(syncDir(dir))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer d.Close()
+	return errors.Trace(d.Sync())
+}
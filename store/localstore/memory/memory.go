@@ -0,0 +1,142 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package memory
+
+import (
+	"sync"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/goleveldb/leveldb"
+	"github.com/pingcap/goleveldb/leveldb/comparer"
+	"github.com/pingcap/goleveldb/leveldb/memdb"
+	"github.com/pingcap/goleveldb/leveldb/util"
+
+	"fedb/store/localstore/engine"
+)
+
+// Driver implements engine Driver.
+type Driver struct {
+}
+
+// Open opens a new in-memory DB, the schema is ignored.
+func (driver Driver) Open(schema string) (engine.DB, error) {
+	return NewDB(), nil
+}
+
+// DB is an ordered in-memory engine based on a skiplist, its data is lost when the process exits.
+type DB struct {
+	mu     sync.RWMutex
+	db     *memdb.DB
+	closed bool
+}
+
+// NewDB creates an empty in-memory DB.
+func NewDB() *DB {
+	return &DB{
+		db: memdb.New(comparer.DefaultComparer, 4*1024*1024),
+	}
+}
+
+// Get implements the DB Get interface.
+func (d *DB) Get(key []byte) ([]byte, error) {
+	v, err := d.db.Get(key)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return v, errors.Trace(err)
+}
+
+// GetSnapshot implements the DB GetSnapshot interface.
+// The in-memory DB doesn't keep history, so the snapshot reads the latest data.
+func (d *DB) GetSnapshot() (engine.Snapshot, error) {
+	return &snapshot{d: d}, nil
+}
+
+// NewBatch implements the DB NewBatch interface.
+func (d *DB) NewBatch() engine.Batch {
+	return engine.NewMemBatch()
+}
+
+// Commit implements the DB Commit interface.
+func (d *DB) Commit(b engine.Batch) error {
+	batch, ok := b.(*engine.MemBatch)
+	if !ok {
+		return errors.Errorf("invalid batch type %T", b)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return errors.New("memory db is closed")
+	}
+	return errors.Trace(d.Apply(batch))
+}
+
+// Apply writes the operations of the batch into the skiplist without any locking,
+// it is used by the engines built on top of the in-memory DB.
+func (d *DB) Apply(batch *engine.MemBatch) error {
+	for _, op := range batch.Ops {
+		var err error
+		if op.Delete {
+			err = d.db.Delete(op.Key)
+			if err == leveldb.ErrNotFound {
+				err = nil
+			}
+		} else {
+			err = d.db.Put(op.Key, op.Value)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries in the DB.
+func (d *DB) Len() int {
+	return d.db.Len()
+}
+
+// Size returns the sum of the keys and values length.
+func (d *DB) Size() int {
+	return d.db.Size()
+}
+
+// NewIterator creates an iterator over the latest data, seeks to the first key >= startKey.
+func (d *DB) NewIterator(startKey []byte) engine.Iterator {
+	return d.db.NewIterator(&util.Range{Start: startKey})
+}
+
+// Close implements the DB Close interface.
+func (d *DB) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.db.Reset()
+	return nil
+}
+
+type snapshot struct {
+	d *DB
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	return s.d.Get(key)
+}
+
+func (s *snapshot) NewIterator(startKey []byte) engine.Iterator {
+	return s.d.NewIterator(startKey)
+}
+
+func (s *snapshot) Release() {
+}
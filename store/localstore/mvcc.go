@@ -0,0 +1,96 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"bytes"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+
+	"fedb/kv"
+)
+
+// Every committed write of a key is kept as a version in the engine:
+//
+//	'm' | memcomparable(key) | ^commitTS  =>  flag | value
+//
+// The versions of a key are adjacent and ordered from the newest to the oldest,
+// so a snapshot reads the first version whose commitTS <= its startTS. The
+// metadata of the store is kept under the 's' prefix.
+var (
+	mvccPrefix = []byte("m")
+
+	lastCommitTSKey = []byte("s_last_commit_ts")
+	safePointKey    = []byte("s_gc_safe_point")
+)
+
+// The flags of the versions.
+const (
+	flagPut    byte = 'P'
+	flagDelete byte = 'D'
+	// flagLock is written for the locked keys without any change, it makes the
+	// concurrent writers of the key conflict, and is skipped by the readers.
+	flagLock byte = 'L'
+)
+
+var errInvalidMvccKey = errors.New("invalid mvcc key")
+
+// mvccEncodeKey encodes the key without version, it is the prefix of all the versions of the key.
+func mvccEncodeKey(key kv.Key) []byte {
+	return codec.EncodeBytes(append([]byte(nil), mvccPrefix...), key)
+}
+
+// mvccEncodeVersionKey encodes the key with version.
+func mvccEncodeVersionKey(key kv.Key, ver uint64) []byte {
+	return codec.EncodeUintDesc(mvccEncodeKey(key), ver)
+}
+
+// mvccDecode decodes the key and the version of an encoded version key.
+func mvccDecode(raw []byte) (kv.Key, uint64, error) {
+	if !bytes.HasPrefix(raw, mvccPrefix) {
+		return nil, 0, errors.Trace(errInvalidMvccKey)
+	}
+	remain, key, err := codec.DecodeBytes(raw[len(mvccPrefix):], nil)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	remain, ver, err := codec.DecodeUintDesc(remain)
+	if err != nil {
+		return nil, 0, errors.Trace(err)
+	}
+	if len(remain) != 0 {
+		return nil, 0, errors.Trace(errInvalidMvccKey)
+	}
+	return key, ver, nil
+}
+
+// isVersionOf returns whether the raw key is a version of the key whose encoded prefix is given.
+func isVersionOf(raw []byte, prefix []byte) bool {
+	return len(raw) == len(prefix)+8 && bytes.HasPrefix(raw, prefix)
+}
+
+func mvccEncodeValue(flag byte, value []byte) []byte {
+	buf := make([]byte, 0, 1+len(value))
+	buf = append(buf, flag)
+	return append(buf, value...)
+}
+
+// mvccDecodeValue returns the flag and the value of a version.
+func mvccDecodeValue(v []byte) (byte, []byte) {
+	if len(v) == 0 {
+		return flagDelete, nil
+	}
+	return v[0], v[1:]
+}
@@ -0,0 +1,262 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package native
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pingcap/errors"
+
+	"fedb/store/localstore/engine"
+	"fedb/store/localstore/memory"
+)
+
+// The checkpoint file layout:
+//
+//	magic(8) | lsn(8) | entries | 0x00 | count(8) | crc32(4)
+//
+// every entry is 0x01 | uvarint(len(key)) | key | uvarint(len(value)) | value,
+// and the crc32 covers all the bytes before it.
+const (
+	checkpointFile = "checkpoint"
+	checkpointTmp  = "checkpoint.tmp"
+)
+
+var (
+	checkpointMagic  = []byte("FEDBCKP1")
+	crcTable         = crc32.MakeTable(crc32.Castagnoli)
+	errBadCheckpoint = errors.New("native: corrupted checkpoint")
+)
+
+// writeCheckpoint dumps all the data of mem into the checkpoint file of dir, it returns the number of entries.
+func writeCheckpoint(dir string, lsn uint64, mem *memory.DB, sync func() error) (uint64, error) {
+	tmpPath := filepath.Join(dir, checkpointTmp)
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	defer f.Close()
+
+	crc := crc32.New(crcTable)
+	w := bufio.NewWriterSize(io.MultiWriter(f, crc), 256*1024)
+	var (
+		buf   [binary.MaxVarintLen64]byte
+		count uint64
+	)
+	w.Write(checkpointMagic)
+	binary.LittleEndian.PutUint64(buf[:8], lsn)
+	w.Write(buf[:8])
+
+	it := mem.NewIterator(nil)
+	for it.Next() {
+		key, value := it.Key(), it.Value()
+		w.WriteByte(1)
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(key)))])
+		w.Write(key)
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(value)))])
+		if _, err = w.Write(value); err != nil {
+			it.Release()
+			return 0, errors.Trace(err)
+		}
+		count++
+	}
+	err = it.Error()
+	it.Release()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	w.WriteByte(0)
+	binary.LittleEndian.PutUint64(buf[:8], count)
+	w.Write(buf[:8])
+	if err = w.Flush(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	binary.LittleEndian.PutUint32(buf[:4], crc.Sum32())
+	if _, err = f.Write(buf[:4]); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if err = f.Sync(); err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	// The batches applied while dumping may be partially in the checkpoint, make sure
+	// they are durable in the log so that replaying completes them.
+	if err = sync(); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if err = os.Rename(tmpPath, filepath.Join(dir, checkpointFile)); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return count, errors.Trace(syncDir(dir))
+}
+
+// loadCheckpoint loads the checkpoint file of dir into mem, it returns the LSN of the checkpoint and the number of entries.
+func loadCheckpoint(dir string, mem *memory.DB) (uint64, uint64, error) {
+	f, err := os.Open(filepath.Join(dir, checkpointFile))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if fi.Size() < int64(len(checkpointMagic))+8+1+8+4 {
+		return 0, 0, errors.Trace(errBadCheckpoint)
+	}
+	crc := crc32.New(crcTable)
+	r := &checkpointReader{
+		r:         bufio.NewReaderSize(io.LimitReader(f, fi.Size()-4), 256*1024),
+		crc:       crc,
+		remaining: fi.Size() - 4,
+	}
+
+	magic := make([]byte, len(checkpointMagic))
+	if err = r.readFull(magic); err != nil || !bytes.Equal(magic, checkpointMagic) {
+		return 0, 0, errors.Trace(errBadCheckpoint)
+	}
+	lsn, err := r.readUint64()
+	if err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+
+	var count uint64
+	batch := engine.NewMemBatch()
+	for {
+		flag, err := r.readByte()
+		if err != nil {
+			return 0, 0, errors.Trace(err)
+		}
+		if flag == 0 {
+			break
+		}
+		key, err := r.readBytes()
+		if err != nil {
+			return 0, 0, errors.Trace(err)
+		}
+		value, err := r.readBytes()
+		if err != nil {
+			return 0, 0, errors.Trace(err)
+		}
+		batch.Put(key, value)
+		count++
+		if batch.Len() >= 4096 {
+			if err = mem.Apply(batch); err != nil {
+				return 0, 0, errors.Trace(err)
+			}
+			batch = engine.NewMemBatch()
+		}
+	}
+	if err = mem.Apply(batch); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	total, err := r.readUint64()
+	if err != nil || total != count {
+		return 0, 0, errors.Trace(errBadCheckpoint)
+	}
+
+	var sum [4]byte
+	if _, err = f.ReadAt(sum[:], fi.Size()-4); err != nil {
+		return 0, 0, errors.Trace(err)
+	}
+	if binary.LittleEndian.Uint32(sum[:]) != crc.Sum32() {
+		return 0, 0, errors.Trace(errBadCheckpoint)
+	}
+	return lsn, count, nil
+}
+
+type checkpointReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	// remaining is the number of bytes left to read, a length larger than it is broken.
+	remaining int64
+}
+
+func (r *checkpointReader) readFull(buf []byte) error {
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return errBadCheckpoint
+	}
+	r.crc.Write(buf)
+	r.remaining -= int64(len(buf))
+	return nil
+}
+
+func (r *checkpointReader) readByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, errBadCheckpoint
+	}
+	r.crc.Write([]byte{b})
+	r.remaining--
+	return b, nil
+}
+
+func (r *checkpointReader) readUint64() (uint64, error) {
+	var buf [8]byte
+	if err := r.readFull(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+func (r *checkpointReader) readUvarint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.readByte()
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+	return 0, errBadCheckpoint
+}
+
+func (r *checkpointReader) readBytes() ([]byte, error) {
+	l, err := r.readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if l > uint64(r.remaining) {
+		return nil, errBadCheckpoint
+	}
+	buf := make([]byte, l)
+	if err = r.readFull(buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer d.Close()
+	return errors.Trace(d.Sync())
+}
@@ -0,0 +1,278 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package native implements the FeDB native storage engine, an in-memory ordered
+// table made durable by a write-ahead log and periodic checkpoints.
+package native
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/config"
+	"fedb/store/localstore/engine"
+	"fedb/store/localstore/memory"
+	"fedb/store/localstore/wal"
+)
+
+const (
+	walDir = "wal"
+
+	defaultCheckpointInterval = 5 * time.Minute
+	defaultCheckpointLogSize  = 64 * 1024 * 1024
+	// checkLogSizeInterval is how often the log size is checked for triggering a checkpoint.
+	checkLogSizeInterval = 10 * time.Second
+)
+
+// Driver implements engine Driver, the options are taken from the global config.
+type Driver struct {
+}
+
+// Open opens or creates a native DB in the directory of the schema.
+func (driver Driver) Open(schema string) (engine.DB, error) {
+	if schema == "" {
+		return nil, errors.New("native: data directory is not specified")
+	}
+	cfg := config.GetGlobalConfig()
+	policy, err := wal.ParseSyncPolicy(cfg.SyncPolicy)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return Open(schema, Options{
+		SyncPolicy:         policy,
+		SyncInterval:       cfg.SyncInterval,
+		CheckpointInterval: cfg.CheckpointInterval,
+	})
+}
+
+// Options is the options of a native DB.
+type Options struct {
+	// SyncPolicy decides when the write-ahead log is synced.
+	SyncPolicy wal.SyncPolicy
+	// SyncInterval is the sync period of the write-ahead log for wal.SyncInterval.
+	SyncInterval time.Duration
+	// CheckpointInterval is the period to write a checkpoint.
+	CheckpointInterval time.Duration
+	// CheckpointLogSize triggers a checkpoint when the write-ahead log grows larger than it.
+	CheckpointLogSize int64
+	// SegmentSize is the size of the write-ahead log segment files.
+	SegmentSize int64
+}
+
+// DB is the native engine.
+type DB struct {
+	dir  string
+	opts Options
+
+	// mu makes the batches queued in the same order as they are logged.
+	mu  sync.Mutex
+	mem *memory.DB
+	log *wal.Log
+	// applyQueue applies the batches to the memory after they are durable.
+	applyQueue *engine.ApplyQueue
+	// appliedLSN is the LSN of the last batch applied to the memory, it is accessed atomically.
+	appliedLSN uint64
+
+	// checkpointMu serializes the checkpoints.
+	checkpointMu  sync.Mutex
+	checkpointLSN uint64
+
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens the native DB in the dir, the data is recovered from the last checkpoint and the log.
+func Open(dir string, opts Options) (*DB, error) {
+	if opts.CheckpointInterval <= 0 {
+		opts.CheckpointInterval = defaultCheckpointInterval
+	}
+	if opts.CheckpointLogSize <= 0 {
+		opts.CheckpointLogSize = defaultCheckpointLogSize
+	}
+
+	d := &DB{
+		dir:     dir,
+		opts:    opts,
+		mem:     memory.NewDB(),
+		closeCh: make(chan struct{}),
+	}
+	if err := d.recover(); err != nil {
+		if d.log != nil {
+			d.log.Close()
+		}
+		return nil, errors.Trace(err)
+	}
+
+	d.applyQueue = engine.NewApplyQueue(d.apply)
+	d.wg.Add(1)
+	go d.checkpointLoop()
+	return d, nil
+}
+
+func (d *DB) recover() error {
+	start := time.Now()
+	lsn, count, err := loadCheckpoint(d.dir, d.mem)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	d.checkpointLSN = lsn
+	d.appliedLSN = lsn
+
+	d.log, err = wal.Open(filepath.Join(d.dir, walDir), wal.Options{
+		SegmentSize:  d.opts.SegmentSize,
+		SyncPolicy:   d.opts.SyncPolicy,
+		SyncInterval: d.opts.SyncInterval,
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+
+	replayed := 0
+	err = d.log.Replay(lsn, func(lsn uint64, data []byte) error {
+		batch, err1 := engine.DecodeMemBatch(data)
+		if err1 != nil {
+			return errors.Trace(err1)
+		}
+		if err1 = d.mem.Apply(batch); err1 != nil {
+			return errors.Trace(err1)
+		}
+		d.appliedLSN = lsn
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	log.Infof("[native] recovered %d entries from checkpoint %d and %d batches from log in %v",
+		count, lsn, replayed, time.Since(start))
+	return nil
+}
+
+// Get implements the DB Get interface.
+func (d *DB) Get(key []byte) ([]byte, error) {
+	return d.mem.Get(key)
+}
+
+// GetSnapshot implements the DB GetSnapshot interface.
+func (d *DB) GetSnapshot() (engine.Snapshot, error) {
+	return d.mem.GetSnapshot()
+}
+
+// NewBatch implements the DB NewBatch interface.
+func (d *DB) NewBatch() engine.Batch {
+	return engine.NewMemBatch()
+}
+
+// Commit implements the DB Commit interface.
+func (d *DB) Commit(b engine.Batch) error {
+	return <-d.CommitAsync(b)
+}
+
+// CommitAsync implements the GroupCommitter interface.
+// The channel receives the result after the batch is logged according to the sync policy
+// and applied, the batch is visible then.
+func (d *DB) CommitAsync(b engine.Batch) <-chan error {
+	batch, ok := b.(*engine.MemBatch)
+	if !ok {
+		done := make(chan error, 1)
+		done <- errors.Errorf("invalid batch type %T", b)
+		return done
+	}
+	data := batch.Encode()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	lsn, logged := d.log.Submit(data)
+	if lsn == 0 {
+		return logged
+	}
+	return d.applyQueue.Push(lsn, batch, logged)
+}
+
+func (d *DB) apply(lsn uint64, batch *engine.MemBatch) error {
+	if err := d.mem.Apply(batch); err != nil {
+		return errors.Annotatef(err, "apply batch %d", lsn)
+	}
+	atomic.StoreUint64(&d.appliedLSN, lsn)
+	return nil
+}
+
+func (d *DB) checkpointLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(checkLogSizeInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ticker.C:
+			if time.Since(last) < d.opts.CheckpointInterval && d.log.Size() < d.opts.CheckpointLogSize {
+				continue
+			}
+			if err := d.Checkpoint(); err != nil {
+				log.Errorf("[native] checkpoint error %v", errors.ErrorStack(err))
+				continue
+			}
+			last = time.Now()
+		case <-d.closeCh:
+			return
+		}
+	}
+}
+
+// Checkpoint writes all the data into a checkpoint file and removes the log before it.
+//
+// The checkpoint is fuzzy, the commits are not blocked while it is written. It is
+// tagged with the LSN applied when it starts, and the log after the LSN is synced
+// before the checkpoint is installed, so replaying the log on the checkpoint always
+// reaches a consistent state.
+func (d *DB) Checkpoint() error {
+	d.checkpointMu.Lock()
+	defer d.checkpointMu.Unlock()
+
+	if err := d.log.Rotate(); err != nil {
+		return errors.Trace(err)
+	}
+	lsn := atomic.LoadUint64(&d.appliedLSN)
+	if lsn == d.checkpointLSN {
+		return nil
+	}
+
+	start := time.Now()
+	count, err := writeCheckpoint(d.dir, lsn, d.mem, d.log.Sync)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	d.checkpointLSN = lsn
+	log.Infof("[native] checkpoint %d with %d entries in %v", lsn, count, time.Since(start))
+	return errors.Trace(d.log.TruncateBefore(lsn + 1))
+}
+
+// Close implements the DB Close interface, it writes a checkpoint before closing
+// so that the next open doesn't need to replay the log.
+func (d *DB) Close() error {
+	d.applyQueue.Close()
+	close(d.closeCh)
+	d.wg.Wait()
+
+	if err := d.Checkpoint(); err != nil {
+		log.Errorf("[native] checkpoint error %v", errors.ErrorStack(err))
+	}
+	err := d.log.Close()
+	d.mem.Close()
+	return errors.Trace(err)
+}
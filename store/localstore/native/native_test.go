@@ -0,0 +1,226 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package native
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func testKey(i int) []byte {
+	return []byte(fmt.Sprintf("key%04d", i))
+}
+
+func mustCommit(t *testing.T, d *DB, i int) {
+	b := d.NewBatch()
+	b.Put(testKey(i), []byte(fmt.Sprintf("value%d", i)))
+	if err := d.Commit(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// copyDir copies the files of the DB while it is open, which is what a crash leaves.
+func copyDir(t *testing.T, src string, dst string) {
+	err := filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recoveredPrefix returns n if the DB has exactly the keys of the first n commits.
+func recoveredPrefix(t *testing.T, d *DB, total int) int {
+	n := 0
+	for n < total {
+		v, err := d.Get(testKey(n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v == nil {
+			break
+		}
+		if string(v) != fmt.Sprintf("value%d", n) {
+			t.Fatalf("key %d has value %q", n, v)
+		}
+		n++
+	}
+	for i := n; i < total; i++ {
+		if v, _ := d.Get(testKey(i)); v != nil {
+			t.Fatalf("recovered commit %d without commit %d", i, n)
+		}
+	}
+	return n
+}
+
+// TestRecoverTruncatedLog cuts the log after a checkpoint at every offset, the DB recovers
+// the checkpoint and a prefix of the commits after it.
+func TestRecoverTruncatedLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	d, err := Open(src, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const checkpointed, total = 8, 20
+	for i := 0; i < total; i++ {
+		if i == checkpointed {
+			if err = d.Checkpoint(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mustCommit(t, d, i)
+	}
+	image := filepath.Join(dir, "image")
+	copyDir(t, src, image)
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(image, walDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Fatalf("expected the log before the checkpoint to be removed, got %d segments", len(fis))
+	}
+	segment := filepath.Join(walDir, fis[0].Name())
+	last := checkpointed
+	for offset := int64(0); offset <= fis[0].Size(); offset++ {
+		dst := filepath.Join(dir, fmt.Sprintf("cut%d", offset))
+		copyDir(t, image, dst)
+		if err = os.Truncate(filepath.Join(dst, segment), offset); err != nil {
+			t.Fatal(err)
+		}
+		d, err = Open(dst, Options{})
+		if err != nil {
+			t.Fatalf("offset %d: %v", offset, err)
+		}
+		n := recoveredPrefix(t, d, total)
+		if n < last {
+			t.Fatalf("offset %d: recovered %d commits, fewer than %d at a smaller offset", offset, n, last)
+		}
+		last = n
+		if err = d.Close(); err != nil {
+			t.Fatal(err)
+		}
+		os.RemoveAll(dst)
+	}
+	if last != total {
+		t.Fatalf("expected %d commits recovered from the whole log, got %d", total, last)
+	}
+}
+
+// TestCheckpointWithCommits writes checkpoints while committing, the crash image after
+// them has all the commits.
+func TestCheckpointWithCommits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	d, err := Open(src, Options{SegmentSize: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers, count = 4, 100
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w * count; i < (w+1)*count; i++ {
+				mustCommit(t, d, i)
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for checkpointing := true; checkpointing; {
+		select {
+		case <-done:
+			checkpointing = false
+		default:
+		}
+		if err = d.Checkpoint(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	image := filepath.Join(dir, "image")
+	copyDir(t, src, image)
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err = Open(image, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if n := recoveredPrefix(t, d, workers*count); n != workers*count {
+		t.Fatalf("expected %d commits, got %d", workers*count, n)
+	}
+}
+
+// TestBadCheckpointLength checks that a broken length in the checkpoint fails the open
+// instead of allocating it.
+func TestBadCheckpointLength(t *testing.T) {
+	dir, err := ioutil.TempDir("", "native")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var buf [binary.MaxVarintLen64]byte
+	data := append([]byte(nil), checkpointMagic...)
+	data = append(data, make([]byte, 8)...)
+	data = append(data, 1)
+	data = append(data, buf[:binary.PutUvarint(buf[:], 1<<60)]...)
+	data = append(data, make([]byte, 16)...)
+	if err = ioutil.WriteFile(filepath.Join(dir, checkpointFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(dir, Options{}); err == nil {
+		t.Fatal("expected the broken checkpoint to fail the open")
+	}
+}
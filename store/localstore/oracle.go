@@ -0,0 +1,63 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/store/tikv/oracle/oracles/local.go
+//
+
+package localstore
+
+import (
+	"sync"
+	"time"
+)
+
+const physicalShiftBits = 18
+
+// composeTS creates a ts from physical and logical parts.
+func composeTS(physical, logical int64) uint64 {
+	return uint64((physical << physicalShiftBits) + logical)
+}
+
+// extractPhysical returns a ts's physical part.
+func extractPhysical(ts uint64) int64 {
+	return int64(ts >> physicalShiftBits)
+}
+
+// getPhysical returns physical from an instant time with millisecond precision.
+func getPhysical(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// localOracle allocates the increasing timestamps from the local clock, the
+// physical part is in milliseconds and the logical part breaks the ties.
+type localOracle struct {
+	sync.Mutex
+	lastTS uint64
+}
+
+// newLocalOracle creates an oracle whose timestamps are always larger than lastTS,
+// so that they keep increasing after a restart even if the clock goes back.
+func newLocalOracle(lastTS uint64) *localOracle {
+	return &localOracle{lastTS: lastTS}
+}
+
+func (l *localOracle) getTimestamp() uint64 {
+	l.Lock()
+	defer l.Unlock()
+	ts := composeTS(getPhysical(time.Now()), 0)
+	if ts <= l.lastTS {
+		ts = l.lastTS + 1
+	}
+	l.lastTS = ts
+	return ts
+}
@@ -0,0 +1,160 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"bytes"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/util/codec"
+
+	"fedb/kv"
+	"fedb/store/localstore/engine"
+)
+
+// dbSnapshot implements kv.Snapshot on an engine snapshot, it reads the latest
+// versions committed before its version.
+type dbSnapshot struct {
+	store    *dbStore
+	snapshot engine.Snapshot
+	version  kv.Version
+}
+
+var _ kv.Snapshot = (*dbSnapshot)(nil)
+
+// Get implements the Retriever Get interface.
+func (s *dbSnapshot) Get(k kv.Key) ([]byte, error) {
+	prefix := mvccEncodeKey(k)
+	it := s.snapshot.NewIterator(codec.EncodeUintDesc(prefix, s.version.Ver))
+	defer it.Release()
+	for it.Next() {
+		if !isVersionOf(it.Key(), prefix) {
+			break
+		}
+		flag, value := mvccDecodeValue(it.Value())
+		switch flag {
+		case flagPut:
+			return value, nil
+		case flagDelete:
+			return nil, kv.ErrNotExist
+		}
+	}
+	if err := it.Error(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return nil, kv.ErrNotExist
+}
+
+// BatchGet implements the Snapshot BatchGet interface.
+func (s *dbSnapshot) BatchGet(keys []kv.Key) (map[string][]byte, error) {
+	m := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		v, err := s.Get(k)
+		if kv.IsErrNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		m[string(k)] = v
+	}
+	return m, nil
+}
+
+// Iter implements the Retriever Iter interface.
+func (s *dbSnapshot) Iter(k kv.Key, upperBound kv.Key) (kv.Iterator, error) {
+	it := &dbIter{
+		it:         s.snapshot.NewIterator(mvccEncodeKey(k)),
+		version:    s.version.Ver,
+		upperBound: upperBound,
+	}
+	if err := it.Next(); err != nil {
+		it.Close()
+		return nil, errors.Trace(err)
+	}
+	return it, nil
+}
+
+// Release releases the underlying engine snapshot.
+func (s *dbSnapshot) Release() {
+	if s.snapshot != nil {
+		s.snapshot.Release()
+		s.snapshot = nil
+	}
+}
+
+// dbIter implements kv.Iterator on an engine iterator, it returns the latest
+// version of every key visible to the snapshot.
+type dbIter struct {
+	it         engine.Iterator
+	version    uint64
+	upperBound kv.Key
+	// decided is the last key whose visible version has been found.
+	decided kv.Key
+	key     kv.Key
+	value   []byte
+	valid   bool
+}
+
+// Next implements the Iterator Next interface.
+func (it *dbIter) Next() error {
+	it.valid = false
+	for it.it.Next() {
+		raw := it.it.Key()
+		if !bytes.HasPrefix(raw, mvccPrefix) {
+			return nil
+		}
+		key, ver, err := mvccDecode(raw)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if len(it.upperBound) > 0 && key.Cmp(it.upperBound) >= 0 {
+			return nil
+		}
+		if ver > it.version || (it.decided != nil && key.Cmp(it.decided) == 0) {
+			continue
+		}
+		flag, value := mvccDecodeValue(it.it.Value())
+		if flag == flagLock {
+			continue
+		}
+		it.decided = key
+		if flag == flagDelete {
+			continue
+		}
+		it.key, it.value, it.valid = key, value, true
+		return nil
+	}
+	return errors.Trace(it.it.Error())
+}
+
+// Valid implements the Iterator Valid interface.
+func (it *dbIter) Valid() bool {
+	return it.valid
+}
+
+// Key implements the Iterator Key interface.
+func (it *dbIter) Key() kv.Key {
+	return it.key
+}
+
+// Value implements the Iterator Value interface.
+func (it *dbIter) Value() []byte {
+	return it.value
+}
+
+// Close implements the Iterator Close interface.
+func (it *dbIter) Close() {
+	it.it.Release()
+}
@@ -0,0 +1,333 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package localstore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pingcap/errors"
+	goctx "golang.org/x/net/context"
+
+	"fedb/kv"
+)
+
+// dbTxn implements kv.Transaction, it buffers all the writes in memory until it commits.
+type dbTxn struct {
+	store    *dbStore
+	snapshot *dbSnapshot
+	buffer   kv.MemBuffer
+	startTS  uint64
+	commitTS uint64
+	valid    bool
+	lockKeys map[string]struct{}
+
+	pessimistic     bool
+	lockWaitTimeout time.Duration
+	// locked maps the keys locked by the pessimistic transaction to the ts when
+	// they are locked, the locked keys are read and checked for conflicts at it.
+	locked map[string]uint64
+	// forUpdateTS is the ts the other keys are read at instead of the start ts if
+	// it isn't 0, forUpdateSnapshot is created at it when the transaction reads.
+	forUpdateTS       uint64
+	forUpdateSnapshot *dbSnapshot
+}
+
+var _ kv.Transaction = (*dbTxn)(nil)
+
+func newTxn(store *dbStore, snapshot *dbSnapshot, startTS uint64) *dbTxn {
+	return &dbTxn{
+		store:    store,
+		snapshot: snapshot,
+		buffer:   kv.NewMemDbBuffer(4 * 1024),
+		startTS:  startTS,
+		valid:    true,
+		lockKeys: make(map[string]struct{}),
+		locked:   make(map[string]uint64),
+	}
+}
+
+// Get implements the Retriever Get interface.
+func (txn *dbTxn) Get(k kv.Key) ([]byte, error) {
+	val, err := txn.buffer.Get(k)
+	if kv.IsErrNotFound(err) {
+		if ts, ok := txn.locked[string(k)]; ok {
+			val, err = txn.getLocked(k, ts)
+		} else {
+			var snapshot *dbSnapshot
+			if snapshot, err = txn.readSnapshot(); err == nil {
+				val, err = snapshot.Get(k)
+			}
+		}
+	}
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(val) == 0 {
+		return nil, kv.ErrNotExist
+	}
+	return val, nil
+}
+
+// getLocked reads the key locked by the pessimistic transaction at the ts when it is locked,
+// which sees the latest committed value, as the key can not be committed by others since then.
+func (txn *dbTxn) getLocked(k kv.Key, ts uint64) ([]byte, error) {
+	snapshot, err := txn.store.newSnapshot(kv.NewVersion(ts))
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	defer snapshot.Release()
+	return snapshot.Get(k)
+}
+
+// readSnapshot returns the snapshot the keys which aren't locked are read from, it is at
+// the for update ts if it is set.
+func (txn *dbTxn) readSnapshot() (*dbSnapshot, error) {
+	if txn.forUpdateTS == 0 {
+		return txn.snapshot, nil
+	}
+	if txn.forUpdateSnapshot == nil {
+		snapshot, err := txn.store.newSnapshot(kv.NewVersion(txn.forUpdateTS))
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		txn.forUpdateSnapshot = snapshot
+	}
+	return txn.forUpdateSnapshot, nil
+}
+
+// setForUpdateTS changes the ts the keys which aren't locked are read at. The snapshot
+// at the previous ts is released, the iterators on it must be closed.
+func (txn *dbTxn) setForUpdateTS(ts uint64) {
+	if txn.forUpdateSnapshot != nil {
+		txn.forUpdateSnapshot.Release()
+		txn.forUpdateSnapshot = nil
+	}
+	txn.forUpdateTS = ts
+}
+
+// readTS returns the ts at which the key is read by the transaction.
+func (txn *dbTxn) readTS(k kv.Key) uint64 {
+	if ts, ok := txn.locked[string(k)]; ok {
+		return ts
+	}
+	if txn.forUpdateTS != 0 {
+		return txn.forUpdateTS
+	}
+	return txn.startTS
+}
+
+// Iter implements the Retriever Iter interface.
+func (txn *dbTxn) Iter(k kv.Key, upperBound kv.Key) (kv.Iterator, error) {
+	bufferIt, err := txn.buffer.Iter(k, upperBound)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	snapshotIt, err := txn.snapshotIter(k, upperBound)
+	if err != nil {
+		bufferIt.Close()
+		return nil, errors.Trace(err)
+	}
+	it, err := kv.NewUnionIter(bufferIt, snapshotIt, false)
+	return it, errors.Trace(err)
+}
+
+// snapshotIter iterates the committed values like Get reads them, the locked keys in the range
+// are read at the ts when they are locked, which override the ones in the snapshot.
+func (txn *dbTxn) snapshotIter(k kv.Key, upperBound kv.Key) (kv.Iterator, error) {
+	snapshot, err := txn.readSnapshot()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	lockedBuf := kv.NewMemDbBuffer(4 * 1024)
+	for key, ts := range txn.locked {
+		if kv.Key(key).Cmp(k) < 0 || upperBound != nil && kv.Key(key).Cmp(upperBound) >= 0 {
+			continue
+		}
+		val, err := txn.getLocked(kv.Key(key), ts)
+		switch {
+		case kv.IsErrNotFound(err):
+			// The empty value hides the key in the snapshot.
+			err = lockedBuf.Delete(kv.Key(key))
+		case err == nil:
+			err = lockedBuf.Set(kv.Key(key), val)
+		}
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	snapshotIt, err := snapshot.Iter(k, upperBound)
+	if err != nil || lockedBuf.Len() == 0 {
+		return snapshotIt, errors.Trace(err)
+	}
+	lockedIt, err := lockedBuf.Iter(k, upperBound)
+	if err != nil {
+		snapshotIt.Close()
+		return nil, errors.Trace(err)
+	}
+	it, err := kv.NewUnionIter(lockedIt, snapshotIt, false)
+	return it, errors.Trace(err)
+}
+
+// Set implements the Mutator Set interface.
+func (txn *dbTxn) Set(k kv.Key, v []byte) error {
+	return txn.buffer.Set(k, v)
+}
+
+// Delete implements the Mutator Delete interface.
+func (txn *dbTxn) Delete(k kv.Key) error {
+	return txn.buffer.Delete(k)
+}
+
+// Size implements the MemBuffer Size interface.
+func (txn *dbTxn) Size() int {
+	return txn.buffer.Size()
+}
+
+// Len implements the MemBuffer Len interface.
+func (txn *dbTxn) Len() int {
+	return txn.buffer.Len()
+}
+
+// Reset implements the MemBuffer Reset interface.
+func (txn *dbTxn) Reset() {
+	txn.buffer.Reset()
+}
+
+// Commit implements the Transaction Commit interface.
+func (txn *dbTxn) Commit(goCtx goctx.Context) error {
+	if !txn.valid {
+		return errors.Trace(kv.ErrInvalidTxn)
+	}
+	defer txn.close()
+	if txn.IsReadOnly() {
+		return nil
+	}
+
+	var err error
+	txn.commitTS, err = txn.store.commit(txn)
+	return errors.Trace(err)
+}
+
+// Rollback implements the Transaction Rollback interface.
+func (txn *dbTxn) Rollback() error {
+	if !txn.valid {
+		return errors.Trace(kv.ErrInvalidTxn)
+	}
+	txn.close()
+	return nil
+}
+
+func (txn *dbTxn) close() {
+	txn.valid = false
+	txn.buffer.Reset()
+	txn.snapshot.Release()
+	txn.setForUpdateTS(0)
+	txn.store.removeActive(txn.startTS)
+	if len(txn.locked) > 0 {
+		keys := make([]kv.Key, 0, len(txn.locked))
+		for k := range txn.locked {
+			keys = append(keys, kv.Key(k))
+		}
+		txn.store.lockMgr.release(txn.startTS, keys)
+	}
+}
+
+// String implements fmt.Stringer interface.
+func (txn *dbTxn) String() string {
+	return fmt.Sprintf("%d", txn.startTS)
+}
+
+// LockKeys implements the Transaction LockKeys interface. An optimistic transaction
+// checks the locked keys for conflicts at commit, while a pessimistic transaction
+// acquires the locks now and holds them until it finishes. A pessimistic transaction
+// fails with kv.ErrWriteConflict if any of the keys is committed by others after it
+// reads them, the keys stay locked and are read at the ts when they are locked, so
+// the statement can be executed again to see the latest values.
+func (txn *dbTxn) LockKeys(goCtx goctx.Context, keys ...kv.Key) error {
+	if !txn.pessimistic {
+		for _, k := range keys {
+			txn.lockKeys[string(k)] = struct{}{}
+		}
+		return nil
+	}
+
+	var acquired []kv.Key
+	for _, k := range keys {
+		if _, ok := txn.locked[string(k)]; ok {
+			continue
+		}
+		if err := txn.store.lockMgr.acquire(goCtx, txn.startTS, k, txn.lockWaitTimeout); err != nil {
+			txn.store.lockMgr.release(txn.startTS, acquired)
+			return errors.Trace(err)
+		}
+		acquired = append(acquired, k)
+	}
+	if len(acquired) == 0 {
+		return nil
+	}
+	// The ts is allocated after the locks are acquired, so it sees the commits of the
+	// previous owners.
+	ts := txn.store.currentTS()
+	conflict, conflictTS, err := txn.store.newestConflict(acquired, txn.readTS)
+	for _, k := range acquired {
+		txn.locked[string(k)] = ts
+	}
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if conflict != nil {
+		return kv.ErrWriteConflict.GenWithStack("write conflict on key %q, start ts %d, conflict ts %d",
+			conflict, txn.startTS, conflictTS)
+	}
+	return nil
+}
+
+// SetOption implements the Transaction SetOption interface.
+func (txn *dbTxn) SetOption(opt kv.Option, val interface{}) {
+	switch opt {
+	case kv.Pessimistic:
+		txn.pessimistic, _ = val.(bool)
+	case kv.LockWaitTimeout:
+		txn.lockWaitTimeout, _ = val.(time.Duration)
+	case kv.ForUpdateTS:
+		ts, _ := val.(uint64)
+		txn.setForUpdateTS(ts)
+	}
+}
+
+// IsReadOnly implements the Transaction IsReadOnly interface.
+func (txn *dbTxn) IsReadOnly() bool {
+	return txn.buffer.Len() == 0 && len(txn.lockKeys) == 0
+}
+
+// StartTS implements the Transaction StartTS interface.
+func (txn *dbTxn) StartTS() uint64 {
+	return txn.startTS
+}
+
+// Valid implements the Transaction Valid interface.
+func (txn *dbTxn) Valid() bool {
+	return txn.valid
+}
+
+// GetMemBuffer implements the Transaction GetMemBuffer interface.
+func (txn *dbTxn) GetMemBuffer() kv.MemBuffer {
+	return txn.buffer
+}
+
+// GetSnapshot implements the Transaction GetSnapshot interface.
+func (txn *dbTxn) GetSnapshot() kv.Snapshot {
+	return txn.snapshot
+}
@@ -0,0 +1,637 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+// Package wal implements a segment-based write-ahead log.
+//
+// The log is a sequence of records stored in segment files named by the LSN
+// (log sequence number) of their first record. Every record carries its LSN
+// and a CRC32 checksum, so a record torn by a crash can be detected and
+// dropped on the next open. Concurrent appends are grouped and share one sync.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SyncPolicy decides when the log is synced to the disk.
+type SyncPolicy int
+
+const (
+	// SyncEveryCommit syncs the log before a commit returns.
+	SyncEveryCommit SyncPolicy = iota
+	// SyncInterval syncs the log periodically, a crash may lose the commits in the last interval.
+	SyncInterval
+	// SyncNone never syncs the log, leaving it to the operating system.
+	SyncNone
+)
+
+// ParseSyncPolicy parses the sync policy names used in the config: "commit", "interval" and "none".
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "commit":
+		return SyncEveryCommit, nil
+	case "interval":
+		return SyncInterval, nil
+	case "none":
+		return SyncNone, nil
+	}
+	return SyncEveryCommit, errors.Errorf("invalid sync policy %q", s)
+}
+
+const (
+	segmentExt = ".log"
+	// headerSize is the size of the record header: crc(4) + length(4) + lsn(8).
+	headerSize = 16
+
+	// maxRecordSize is the limit of a record, a larger length in the header means the header is broken.
+	maxRecordSize = 512 * 1024 * 1024
+
+	defaultSegmentSize  = 64 * 1024 * 1024
+	defaultSyncInterval = time.Second
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrCorrupted is returned when a record in the middle of the log is broken.
+var ErrCorrupted = errors.New("wal: corrupted record")
+
+// ErrClosed is returned when appending to a closed log.
+var ErrClosed = errors.New("wal: log is closed")
+
+// Options is the options of a Log.
+type Options struct {
+	// SegmentSize is the size to switch to a new segment file.
+	SegmentSize int64
+	// SyncPolicy decides when the log is synced.
+	SyncPolicy SyncPolicy
+	// SyncInterval is the sync period for SyncInterval policy.
+	SyncInterval time.Duration
+}
+
+type request struct {
+	lsn  uint64
+	data []byte
+	done chan error
+	// barrier requests write nothing, they wait for the records submitted before them to be synced.
+	barrier bool
+}
+
+type segment struct {
+	firstLSN uint64
+	path     string
+}
+
+// Log is a write-ahead log in a directory.
+type Log struct {
+	dir  string
+	opts Options
+
+	// mu protects the fields below, which are used by the appenders.
+	mu      sync.Mutex
+	nextLSN uint64
+	pending []*request
+	closed  bool
+	notify  chan struct{}
+	// err is the first error of writing or syncing the log, it's unknown which records are
+	// on the disk after it, so all the appends after it fail with it.
+	err error
+
+	// fileMu protects the segment files.
+	fileMu   sync.Mutex
+	segments []segment
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	dirty    bool
+	// writtenLSN is the LSN of the last record written to the current file.
+	writtenLSN uint64
+
+	closeCh chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens the log in the directory, a torn record at the tail of the log is truncated.
+func Open(dir string, opts Options) (*Log, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultSyncInterval
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Trace(err)
+	}
+	l := &Log{
+		dir:     dir,
+		opts:    opts,
+		notify:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+		nextLSN: 1,
+	}
+	if err := l.loadSegments(); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := l.openTail(); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	l.wg.Add(1)
+	go l.writeLoop()
+	if opts.SyncPolicy == SyncInterval {
+		l.wg.Add(1)
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+func (l *Log) loadSegments() error {
+	names, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, fi := range names {
+		name := fi.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		var lsn uint64
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, segmentExt), "%016x", &lsn); err != nil {
+			continue
+		}
+		l.segments = append(l.segments, segment{firstLSN: lsn, path: filepath.Join(l.dir, name)})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].firstLSN < l.segments[j].firstLSN })
+	return nil
+}
+
+// openTail validates the last segment, truncates the torn tail and opens it for appending.
+func (l *Log) openTail() error {
+	if len(l.segments) == 0 {
+		return errors.Trace(l.createSegment(l.nextLSN))
+	}
+	tail := l.segments[len(l.segments)-1]
+	f, err := os.OpenFile(tail.path, os.O_RDWR, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	lastLSN := tail.firstLSN - 1
+	offset, err := scanSegment(f, tail.firstLSN, func(lsn uint64, data []byte) error {
+		lastLSN = lsn
+		return nil
+	})
+	if err != nil && errors.Cause(err) != errTorn {
+		f.Close()
+		return errors.Trace(err)
+	}
+	if err != nil {
+		log.Warnf("[wal] truncate torn tail of %s at offset %d", tail.path, offset)
+		if err = f.Truncate(offset); err != nil {
+			f.Close()
+			return errors.Trace(err)
+		}
+		if err = f.Sync(); err != nil {
+			f.Close()
+			return errors.Trace(err)
+		}
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	l.file = f
+	l.writer = bufio.NewWriterSize(f, 64*1024)
+	l.size = offset
+	l.writtenLSN = lastLSN
+	l.nextLSN = lastLSN + 1
+	return nil
+}
+
+func (l *Log) createSegment(firstLSN uint64) error {
+	path := filepath.Join(l.dir, fmt.Sprintf("%016x%s", firstLSN, segmentExt))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = syncDir(l.dir); err != nil {
+		f.Close()
+		return errors.Trace(err)
+	}
+	l.segments = append(l.segments, segment{firstLSN: firstLSN, path: path})
+	l.file = f
+	l.writer = bufio.NewWriterSize(f, 64*1024)
+	l.size = 0
+	return nil
+}
+
+// errTorn means the segment ends with an incomplete or broken record.
+var errTorn = errors.New("wal: torn record")
+
+// errChecksum means a complete record fails the checksum.
+var errChecksum = errors.New("wal: checksum mismatch")
+
+// scanSegment reads the records of a segment and returns the offset after the last valid record.
+// A broken record is a torn tail unless it is followed by the next record, or a valid record has
+// an unexpected LSN, which mean the records in the middle of the log are lost and fail with
+// ErrCorrupted.
+func scanSegment(f *os.File, firstLSN uint64, fn func(lsn uint64, data []byte) error) (int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, errors.Trace(err)
+	}
+	r := bufio.NewReaderSize(f, 64*1024)
+	var (
+		offset  int64
+		wantLSN = firstLSN
+	)
+	for {
+		lsn, data, err := readRecord(r)
+		if err == io.EOF {
+			return offset, nil
+		}
+		if err == errChecksum {
+			if next, _, err1 := readRecord(r); err1 == nil && next == wantLSN+1 {
+				return offset, errors.Annotatef(ErrCorrupted, "record %d at offset %d", wantLSN, offset)
+			}
+			return offset, errTorn
+		}
+		if err != nil {
+			return offset, errTorn
+		}
+		if lsn != wantLSN {
+			return offset, errors.Annotatef(ErrCorrupted, "record %d at offset %d has lsn %d", wantLSN, offset, lsn)
+		}
+		if err = fn(lsn, data); err != nil {
+			return offset, errors.Trace(err)
+		}
+		offset += headerSize + int64(len(data))
+		wantLSN++
+	}
+}
+
+// readRecord reads a record, it returns io.EOF at the end of the segment, errTorn if the record
+// is incomplete and errChecksum if it is complete but broken.
+func readRecord(r *bufio.Reader) (uint64, []byte, error) {
+	var header [headerSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, errTorn
+	}
+	crc := binary.LittleEndian.Uint32(header[0:4])
+	length := binary.LittleEndian.Uint32(header[4:8])
+	lsn := binary.LittleEndian.Uint64(header[8:16])
+	if length > maxRecordSize {
+		return 0, nil, errTorn
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, errTorn
+	}
+	if checksum(header[8:16], data) != crc {
+		return 0, nil, errChecksum
+	}
+	return lsn, data, nil
+}
+
+func checksum(lsn []byte, data []byte) uint32 {
+	crc := crc32.Update(0, crcTable, lsn)
+	return crc32.Update(crc, crcTable, data)
+}
+
+// Replay calls fn for every record whose LSN is greater than after, in the LSN order.
+func (l *Log) Replay(after uint64, fn func(lsn uint64, data []byte) error) error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if err := l.writer.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	for i, seg := range l.segments {
+		if i+1 < len(l.segments) && l.segments[i+1].firstLSN <= after+1 {
+			// All the records of this segment are before after.
+			continue
+		}
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		lastLSN := seg.firstLSN - 1
+		_, err = scanSegment(f, seg.firstLSN, func(lsn uint64, data []byte) error {
+			lastLSN = lsn
+			if lsn <= after {
+				return nil
+			}
+			return fn(lsn, data)
+		})
+		f.Close()
+		if errors.Cause(err) == errTorn {
+			// Only the tail is allowed to be torn, and it is truncated when the log is opened.
+			return errors.Annotatef(ErrCorrupted, "segment %s", seg.path)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if i+1 < len(l.segments) && lastLSN+1 != l.segments[i+1].firstLSN {
+			return errors.Annotatef(ErrCorrupted, "segment %s ends at %d, the next one starts at %d",
+				seg.path, lastLSN, l.segments[i+1].firstLSN)
+		}
+	}
+	return nil
+}
+
+// Submit appends a record to the log asynchronously, the returned channel receives the
+// result after the record is written and synced according to the sync policy.
+func (l *Log) Submit(data []byte) (uint64, <-chan error) {
+	done := make(chan error, 1)
+	l.mu.Lock()
+	if l.closed || l.err != nil {
+		err := l.err
+		if l.closed {
+			err = ErrClosed
+		}
+		l.mu.Unlock()
+		done <- err
+		return 0, done
+	}
+	req := &request{lsn: l.nextLSN, data: data, done: done}
+	l.nextLSN++
+	l.pending = append(l.pending, req)
+	l.mu.Unlock()
+
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+	return req.lsn, done
+}
+
+// Sync waits for all the records submitted before to be written and synced.
+func (l *Log) Sync() error {
+	done := make(chan error, 1)
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	if l.err != nil {
+		err := l.err
+		l.mu.Unlock()
+		return err
+	}
+	l.pending = append(l.pending, &request{done: done, barrier: true})
+	l.mu.Unlock()
+
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+	return <-done
+}
+
+// Append appends a record to the log and waits for it.
+func (l *Log) Append(data []byte) (uint64, error) {
+	lsn, done := l.Submit(data)
+	return lsn, <-done
+}
+
+// LastLSN returns the LSN of the last record submitted.
+func (l *Log) LastLSN() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.nextLSN - 1
+}
+
+// Size returns the total size of the segment files.
+func (l *Log) Size() int64 {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	var size int64
+	for _, seg := range l.segments[:len(l.segments)-1] {
+		if fi, err := os.Stat(seg.path); err == nil {
+			size += fi.Size()
+		}
+	}
+	return size + l.size
+}
+
+// writeLoop is the only writer of the log, it writes all the pending records as a group
+// and syncs them once.
+func (l *Log) writeLoop() {
+	defer l.wg.Done()
+	for {
+		select {
+		case <-l.notify:
+		case <-l.closeCh:
+			// Drain the requests submitted before closing.
+			l.writeGroup()
+			return
+		}
+		l.writeGroup()
+	}
+}
+
+func (l *Log) writeGroup() {
+	l.mu.Lock()
+	group := l.pending
+	l.pending = nil
+	err := l.err
+	l.mu.Unlock()
+	if len(group) == 0 {
+		return
+	}
+
+	if err == nil {
+		err = l.fail(l.write(group))
+	}
+	for _, req := range group {
+		req.done <- err
+	}
+}
+
+// fail makes the log fail all the appends after the error if it is not nil, and returns
+// the error the log fails with.
+func (l *Log) fail(err error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil && l.err == nil {
+		log.Errorf("[wal] the log fails after error %v", errors.ErrorStack(err))
+		l.err = err
+	}
+	return l.err
+}
+
+func (l *Log) write(group []*request) error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+
+	var (
+		header [headerSize]byte
+		force  bool
+	)
+	for _, req := range group {
+		if req.barrier {
+			force = true
+			continue
+		}
+		if l.size >= l.opts.SegmentSize {
+			if err := l.rotate(req.lsn); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		binary.LittleEndian.PutUint32(header[4:8], uint32(len(req.data)))
+		binary.LittleEndian.PutUint64(header[8:16], req.lsn)
+		binary.LittleEndian.PutUint32(header[0:4], checksum(header[8:16], req.data))
+		if _, err := l.writer.Write(header[:]); err != nil {
+			return errors.Trace(err)
+		}
+		if _, err := l.writer.Write(req.data); err != nil {
+			return errors.Trace(err)
+		}
+		l.size += headerSize + int64(len(req.data))
+		l.writtenLSN = req.lsn
+	}
+	if err := l.writer.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	l.dirty = true
+	if l.opts.SyncPolicy == SyncEveryCommit || force {
+		return errors.Trace(l.sync())
+	}
+	return nil
+}
+
+func (l *Log) sync() error {
+	if !l.dirty {
+		return nil
+	}
+	if err := l.file.Sync(); err != nil {
+		return errors.Trace(err)
+	}
+	l.dirty = false
+	return nil
+}
+
+func (l *Log) syncLoop() {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.fileMu.Lock()
+			if err := l.sync(); err != nil {
+				l.fail(err)
+			}
+			l.fileMu.Unlock()
+		case <-l.closeCh:
+			return
+		}
+	}
+}
+
+// rotate closes the current segment and starts a new one with the firstLSN.
+func (l *Log) rotate(firstLSN uint64) error {
+	if err := l.writer.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	l.dirty = true
+	if err := l.sync(); err != nil {
+		return errors.Trace(err)
+	}
+	if err := l.file.Close(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(l.createSegment(firstLSN))
+}
+
+// Rotate starts a new segment if the current one is not empty, the records written before
+// can be removed by TruncateBefore once they are not needed.
+func (l *Log) Rotate() error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if err := l.fail(nil); err != nil {
+		return err
+	}
+	if l.size == 0 {
+		return nil
+	}
+	return errors.Trace(l.fail(l.rotate(l.writtenLSN + 1)))
+}
+
+// TruncateBefore removes the segments whose records all have LSN less than lsn.
+func (l *Log) TruncateBefore(lsn uint64) error {
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	removed := 0
+	for i := 0; i+1 < len(l.segments); i++ {
+		if l.segments[i+1].firstLSN > lsn {
+			break
+		}
+		if err := os.Remove(l.segments[i].path); err != nil {
+			return errors.Trace(err)
+		}
+		removed++
+	}
+	l.segments = l.segments[removed:]
+	if removed > 0 {
+		return errors.Trace(syncDir(l.dir))
+	}
+	return nil
+}
+
+// Close writes the pending records, syncs and closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	close(l.closeCh)
+	l.wg.Wait()
+
+	l.fileMu.Lock()
+	defer l.fileMu.Unlock()
+	if err := l.writer.Flush(); err != nil {
+		return errors.Trace(err)
+	}
+	l.dirty = true
+	if err := l.sync(); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(l.file.Close())
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer d.Close()
+	return errors.Trace(d.Sync())
+}
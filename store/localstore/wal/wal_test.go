@@ -0,0 +1,279 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package wal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pingcap/errors"
+)
+
+func testRecord(i int) []byte {
+	return bytes.Repeat([]byte{byte(i)}, i%7*5+1)
+}
+
+func mustOpen(t *testing.T, dir string, opts Options) *Log {
+	l, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func replayAll(t *testing.T, l *Log) [][]byte {
+	var records [][]byte
+	err := l.Replay(0, func(lsn uint64, data []byte) error {
+		if lsn != uint64(len(records)+1) {
+			return errors.Errorf("expected lsn %d, got %d", len(records)+1, lsn)
+		}
+		records = append(records, data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func copyDir(t *testing.T, src string, dst string) {
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := ioutil.ReadDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range fis {
+		data, err := ioutil.ReadFile(filepath.Join(src, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dst, fi.Name()), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestTruncateTail cuts the log at every offset like a crash does, the log is reopened
+// with the records before the offset and appends after them.
+func TestTruncateTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	l := mustOpen(t, src, Options{})
+	var ends []int64
+	var size int64
+	for i := 1; i <= 20; i++ {
+		if _, err = l.Append(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+		size += headerSize + int64(len(testRecord(i)))
+		ends = append(ends, size)
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	segment := fmt.Sprintf("%016x%s", 1, segmentExt)
+
+	for offset := int64(0); offset <= size; offset++ {
+		dst := filepath.Join(dir, fmt.Sprintf("cut%d", offset))
+		copyDir(t, src, dst)
+		if err = os.Truncate(filepath.Join(dst, segment), offset); err != nil {
+			t.Fatal(err)
+		}
+		expected := 0
+		for expected < len(ends) && ends[expected] <= offset {
+			expected++
+		}
+
+		l = mustOpen(t, dst, Options{})
+		records := replayAll(t, l)
+		if len(records) != expected {
+			t.Fatalf("offset %d: expected %d records, got %d", offset, expected, len(records))
+		}
+		for i, data := range records {
+			if !bytes.Equal(data, testRecord(i+1)) {
+				t.Fatalf("offset %d: record %d mismatch", offset, i+1)
+			}
+		}
+		lsn, err := l.Append(testRecord(expected + 1))
+		if err != nil || lsn != uint64(expected+1) {
+			t.Fatalf("offset %d: append got lsn %d, err %v", offset, lsn, err)
+		}
+		if err = l.Close(); err != nil {
+			t.Fatal(err)
+		}
+		l = mustOpen(t, dst, Options{})
+		if records = replayAll(t, l); len(records) != expected+1 {
+			t.Fatalf("offset %d: expected %d records after reopen, got %d", offset, expected+1, len(records))
+		}
+		l.Close()
+		os.RemoveAll(dst)
+	}
+}
+
+// TestCorruptedMiddle checks that a broken record followed by valid ones is not truncated.
+func TestCorruptedMiddle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := mustOpen(t, dir, Options{})
+	for i := 1; i <= 5; i++ {
+		if _, err = l.Append(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%016x%s", 1, segmentExt))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Break the data of the second record.
+	data[2*headerSize+len(testRecord(1))] ^= 0xff
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Open(dir, Options{}); errors.Cause(err) != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+// TestMissingSegment checks that a gap between the segments fails the replay.
+func TestMissingSegment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := mustOpen(t, dir, Options{SegmentSize: 64})
+	for i := 1; i <= 20; i++ {
+		if _, err = l.Append(testRecord(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) < 3 {
+		t.Fatalf("expected at least 3 segments, got %d", len(fis))
+	}
+	if err = os.Remove(filepath.Join(dir, fis[1].Name())); err != nil {
+		t.Fatal(err)
+	}
+	l = mustOpen(t, dir, Options{})
+	defer l.Close()
+	if err = l.Replay(0, func(uint64, []byte) error { return nil }); errors.Cause(err) != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, got %v", err)
+	}
+}
+
+// TestGroupCommit appends concurrently, every record is logged once in the LSN order.
+func TestGroupCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := mustOpen(t, dir, Options{SegmentSize: 4096})
+	const workers, count = 8, 200
+	lsns := make([][]uint64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				lsn, err := l.Append([]byte(fmt.Sprintf("%d-%d", w, i)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				lsns[w] = append(lsns[w], lsn)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	l = mustOpen(t, dir, Options{})
+	defer l.Close()
+	records := replayAll(t, l)
+	if len(records) != workers*count {
+		t.Fatalf("expected %d records, got %d", workers*count, len(records))
+	}
+	for w := range lsns {
+		for i, lsn := range lsns[w] {
+			if expected := fmt.Sprintf("%d-%d", w, i); string(records[lsn-1]) != expected {
+				t.Fatalf("record %d: expected %s, got %s", lsn, expected, records[lsn-1])
+			}
+		}
+	}
+}
+
+// TestWriteError checks that the log fails all the appends after a write error.
+func TestWriteError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l := mustOpen(t, dir, Options{})
+	if _, err = l.Append(testRecord(1)); err != nil {
+		t.Fatal(err)
+	}
+	l.fileMu.Lock()
+	l.file.Close()
+	l.fileMu.Unlock()
+	if _, err = l.Append(testRecord(2)); err == nil {
+		t.Fatal("expected the append to fail")
+	}
+	if lsn, done := l.Submit(testRecord(3)); lsn != 0 || <-done == nil {
+		t.Fatal("expected the log to fail the later appends")
+	}
+	if err = l.Sync(); err == nil {
+		t.Fatal("expected the log to fail the sync")
+	}
+	l.Close()
+
+	l = mustOpen(t, dir, Options{})
+	defer l.Close()
+	if records := replayAll(t, l); len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+}
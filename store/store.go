@@ -0,0 +1,76 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/session/tidb.go
+//
+
+package store
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/errors"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/kv"
+)
+
+var (
+	storesMu sync.RWMutex
+	stores   = make(map[string]kv.Driver)
+)
+
+// Register registers a kv storage with unique name and its associated Driver.
+func Register(name string, driver kv.Driver) error {
+	name = strings.ToLower(name)
+
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if _, ok := stores[name]; ok {
+		return errors.Errorf("%s is already registered", name)
+	}
+
+	stores[name] = driver
+	return nil
+}
+
+// New creates a kv Storage with path.
+//
+// The path must be a URL format 'engine://path?params' like the one for
+// database/sql. For example:
+//
+//	memory://
+//	boltdb:///tmp/fedb
+func New(path string) (kv.Storage, error) {
+	storeURL, err := url.Parse(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	name := strings.ToLower(storeURL.Scheme)
+	storesMu.RLock()
+	d, ok := stores[name]
+	storesMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("invalid uri format, storage %s is not registered", name)
+	}
+
+	s, err := d.Open(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	log.Infof("new store %s", path)
+	return s, nil
+}
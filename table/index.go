@@ -0,0 +1,44 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/table/index.go
+//
+
+package table
+
+import (
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/kv"
+	"fedb/sessionctx"
+)
+
+// Index is the interface for index data on KV store.
+type Index interface {
+	// Meta returns IndexInfo.
+	Meta() *model.IndexInfo
+	// Create supports insert into statement.
+	Create(ctx sessionctx.Context, rm kv.RetrieverMutator, indexedValues []types.Datum, h int64) (int64, error)
+	// Delete supports delete from statement.
+	Delete(sc *stmtctx.StatementContext, m kv.Mutator, indexedValues []types.Datum, h int64) error
+	// Exist supports check index exists or not.
+	Exist(sc *stmtctx.StatementContext, r kv.Retriever, indexedValues []types.Datum, h int64) (bool, int64, error)
+	// GenIndexKey generates an index key.
+	GenIndexKey(sc *stmtctx.StatementContext, indexedValues []types.Datum, h int64, buf []byte) (key []byte, distinct bool, err error)
+	// FetchValues fetched index column values in a row.
+	// Param columns is a reused buffer, if it is not nil, FetchValues will fill the index values in it,
+	// and return the buffer, if it is nil, FetchValues will allocate the buffer instead.
+	FetchValues(row []types.Datum, columns []types.Datum) ([]types.Datum, error)
+}
@@ -0,0 +1,141 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/table/table.go
+//
+
+package table
+
+import (
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/parser/terror"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/kv"
+	"fedb/sessionctx"
+)
+
+var (
+	// ErrColumnCantNull is used for inserting null to a not null column.
+	ErrColumnCantNull  = terror.ClassTable.New(codeColumnCantNull, mysql.MySQLErrName[mysql.ErrBadNull])
+	errUnknownColumn   = terror.ClassTable.New(codeUnknownColumn, "unknown column")
+	errDuplicateColumn = terror.ClassTable.New(codeDuplicateColumn, "duplicate column")
+
+	errGetDefaultFailed = terror.ClassTable.New(codeGetDefaultFailed, "get default value fail")
+
+	// ErrNoDefaultValue is used when insert a row, the column value is not given, and the column has not null flag
+	// and it doesn't have a default value.
+	ErrNoDefaultValue = terror.ClassTable.New(codeNoDefaultValue, "field doesn't have a default value")
+	// ErrRowNotFound returns for row not found.
+	ErrRowNotFound = terror.ClassTable.New(codeRowNotFound, "can not find the row")
+	// ErrTruncateWrongValue returns for truncate wrong value for field.
+	ErrTruncateWrongValue = terror.ClassTable.New(codeTruncateWrongValue, "incorrect value")
+	// ErrIndexOutBound returns for index column offset out of bound.
+	ErrIndexOutBound = terror.ClassTable.New(codeIndexOutBound, "index column offset out of bound")
+)
+
+// RecordIterFunc is used for low-level record iteration.
+type RecordIterFunc func(h int64, rec []types.Datum, cols []*Column) (more bool, err error)
+
+// Table is used to retrieve and modify rows in table.
+type Table interface {
+	// IterRecords iterates records in the table and calls fn.
+	IterRecords(ctx sessionctx.Context, startKey kv.Key, cols []*Column, fn RecordIterFunc) error
+
+	// RowWithCols returns a row that contains the given cols.
+	RowWithCols(ctx sessionctx.Context, h int64, cols []*Column) ([]types.Datum, error)
+
+	// Row returns a row for all columns.
+	Row(ctx sessionctx.Context, h int64) ([]types.Datum, error)
+
+	// Cols returns the columns of the table which is used in select.
+	Cols() []*Column
+
+	// WritableCols returns the columns that the values of the rows are written to, they are
+	// public or in the write only states of being added or dropped.
+	WritableCols() []*Column
+
+	// RecordPrefix returns the record key prefix.
+	RecordPrefix() kv.Key
+
+	// FirstKey returns the first key.
+	FirstKey() kv.Key
+
+	// RecordKey returns the key in KV storage for the row.
+	RecordKey(h int64) kv.Key
+
+	// Indices returns the indices of the table, the entries of a removed row are deleted from all of them.
+	Indices() []Index
+
+	// WritableIndices returns the indices that the entries of the new rows are written to, they are
+	// public or being built.
+	WritableIndices() []Index
+
+	// AddRecord inserts a row which should contain only public columns, and returns its handle.
+	// The handle is the value of the int primary key if it is the handle, or a new allocated one.
+	// The other writable columns get their original default values.
+	AddRecord(ctx sessionctx.Context, r []types.Datum) (recordID int64, err error)
+
+	// UpdateRecord updates a row which should contain only public columns, the indices of the
+	// touched columns are rebuilt. The values of the other writable columns are kept.
+	UpdateRecord(ctx sessionctx.Context, h int64, currData, newData []types.Datum, touched []bool) error
+
+	// RemoveRecord removes a row and its index entries.
+	RemoveRecord(ctx sessionctx.Context, h int64, r []types.Datum) error
+
+	// AllocAutoID allocates an ID for the auto_increment column of a new row, the IDs follow
+	// auto_increment_increment and auto_increment_offset of the session.
+	AllocAutoID(ctx sessionctx.Context) (int64, error)
+
+	// RebaseAutoID rebases the auto_increment ID, the IDs allocated later are greater than newBase.
+	RebaseAutoID(newBase int64) error
+
+	// Meta returns TableInfo.
+	Meta() *model.TableInfo
+}
+
+// Table error codes.
+const (
+	codeGetDefaultFailed = 1
+	codeIndexOutBound    = 2
+	codeRowNotFound      = 4
+
+	codeColumnCantNull     = mysql.ErrBadNull
+	codeUnknownColumn      = 1054
+	codeDuplicateColumn    = 1110
+	codeNoDefaultValue     = 1364
+	codeTruncateWrongValue = 1366
+)
+
+// Slice is used for table sorting.
+type Slice []Table
+
+func (s Slice) Len() int { return len(s) }
+
+func (s Slice) Less(i, j int) bool {
+	return s[i].Meta().Name.O < s[j].Meta().Name.O
+}
+
+func (s Slice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func init() {
+	tableMySQLErrCodes := map[terror.ErrCode]uint16{
+		codeColumnCantNull:     mysql.ErrBadNull,
+		codeUnknownColumn:      mysql.ErrBadField,
+		codeDuplicateColumn:    mysql.ErrFieldSpecifiedTwice,
+		codeNoDefaultValue:     mysql.ErrNoDefaultForField,
+		codeTruncateWrongValue: mysql.ErrTruncatedWrongValueForField,
+	}
+	terror.ErrClassToMySQLCodes[terror.ClassTable] = tableMySQLErrCodes
+}
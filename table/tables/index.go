@@ -0,0 +1,226 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/table/tables/index.go
+//
+
+package tables
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf8"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/util/codec"
+
+	"fedb/kv"
+	"fedb/sessionctx"
+	"fedb/table"
+	"fedb/tablecodec"
+)
+
+// EncodeHandle encodes handle in data.
+func EncodeHandle(h int64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], uint64(h))
+	return data[:]
+}
+
+// index is the data structure for index data in the KV store.
+type index struct {
+	idxInfo *model.IndexInfo
+	tblInfo *model.TableInfo
+	prefix  kv.Key
+}
+
+// NewIndex builds a new Index object.
+func NewIndex(tblInfo *model.TableInfo, indexInfo *model.IndexInfo) table.Index {
+	return &index{
+		idxInfo: indexInfo,
+		tblInfo: tblInfo,
+		prefix:  tablecodec.EncodeTableIndexPrefix(tblInfo.ID, indexInfo.ID),
+	}
+}
+
+// Meta returns index info.
+func (c *index) Meta() *model.IndexInfo {
+	return c.idxInfo
+}
+
+func (c *index) getIndexKeyBuf(buf []byte, defaultCap int) []byte {
+	if buf != nil {
+		return buf[:0]
+	}
+
+	return make([]byte, 0, defaultCap)
+}
+
+// TruncateIndexValuesIfNeeded truncates the index values created using only the leading part of column values.
+func TruncateIndexValuesIfNeeded(tblInfo *model.TableInfo, idxInfo *model.IndexInfo, indexedValues []types.Datum) []types.Datum {
+	for i := 0; i < len(indexedValues); i++ {
+		v := &indexedValues[i]
+		if v.Kind() == types.KindString || v.Kind() == types.KindBytes {
+			ic := idxInfo.Columns[i]
+			colCharset := tblInfo.Columns[ic.Offset].Charset
+			colValue := v.GetBytes()
+			isUTF8Charset := colCharset == charset.CharsetUTF8 || colCharset == charset.CharsetUTF8MB4
+			origKind := v.Kind()
+			if isUTF8Charset {
+				if ic.Length != types.UnspecifiedLength && utf8.RuneCount(colValue) > ic.Length {
+					rs := bytes.Runes(colValue)
+					truncateStr := string(rs[:ic.Length])
+					// truncate value and limit its length
+					v.SetString(truncateStr)
+					if origKind == types.KindBytes {
+						v.SetBytes(v.GetBytes())
+					}
+				}
+			} else if ic.Length != types.UnspecifiedLength && len(colValue) > ic.Length {
+				// truncate value and limit its length
+				v.SetBytes(colValue[:ic.Length])
+				if origKind == types.KindString {
+					v.SetString(v.GetString())
+				}
+			}
+		}
+	}
+
+	return indexedValues
+}
+
+// GenIndexKey generates storage key for index values. Returned distinct indicates whether the
+// indexed values should be distinct in storage (i.e. whether handle is encoded in the key).
+func (c *index) GenIndexKey(sc *stmtctx.StatementContext, indexedValues []types.Datum, h int64, buf []byte) (key []byte, distinct bool, err error) {
+	if c.idxInfo.Unique {
+		// See https://dev.mysql.com/doc/refman/5.7/en/create-index.html
+		// A UNIQUE index creates a constraint such that all values in the index must be distinct.
+		// An error occurs if you try to add a new row with a key value that matches an existing row.
+		// For all engines, a UNIQUE index permits multiple NULL values for columns that can contain NULL.
+		distinct = true
+		for _, cv := range indexedValues {
+			if cv.IsNull() {
+				distinct = false
+				break
+			}
+		}
+	}
+
+	// For string columns, indexes can be created using only the leading part of column values,
+	// using col_name(length) syntax to specify an index prefix length.
+	indexedValues = TruncateIndexValuesIfNeeded(c.tblInfo, c.idxInfo, indexedValues)
+	key = c.getIndexKeyBuf(buf, len(c.prefix)+len(indexedValues)*9+9)
+	key = append(key, []byte(c.prefix)...)
+	key, err = codec.EncodeKey(sc, key, indexedValues...)
+	if !distinct && err == nil {
+		key, err = codec.EncodeKey(sc, key, types.NewDatum(h))
+	}
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	return
+}
+
+// Create creates a new entry in the kvIndex data.
+// If the index is unique and there is an existing entry with the same key,
+// Create will return the existing entry's handle as the first return value, ErrKeyExists as the second return value.
+func (c *index) Create(ctx sessionctx.Context, rm kv.RetrieverMutator, indexedValues []types.Datum, h int64) (int64, error) {
+	key, distinct, err := c.GenIndexKey(ctx.GetSessionVars().StmtCtx, indexedValues, h, nil)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	if !distinct {
+		// non-unique index doesn't need store value, write a '0' to reduce space
+		err = rm.Set(key, []byte{'0'})
+		return 0, errors.Trace(err)
+	}
+
+	value, err := rm.Get(key)
+	if kv.IsErrNotFound(err) {
+		err = rm.Set(key, EncodeHandle(h))
+		return 0, errors.Trace(err)
+	}
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+
+	handle, err := tablecodec.DecodeIndexValueAsHandle(value)
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return handle, kv.ErrKeyExists
+}
+
+// Delete removes the entry for handle h and indexdValues from KV index.
+func (c *index) Delete(sc *stmtctx.StatementContext, m kv.Mutator, indexedValues []types.Datum, h int64) error {
+	key, _, err := c.GenIndexKey(sc, indexedValues, h, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	err = m.Delete(key)
+	return errors.Trace(err)
+}
+
+// Exist checks whether the entry of indexedValues exists, it returns the handle of the
+// entry, and ErrKeyExists if the index is unique and the handle is not h.
+func (c *index) Exist(sc *stmtctx.StatementContext, r kv.Retriever, indexedValues []types.Datum, h int64) (bool, int64, error) {
+	key, distinct, err := c.GenIndexKey(sc, indexedValues, h, nil)
+	if err != nil {
+		return false, 0, errors.Trace(err)
+	}
+
+	value, err := r.Get(key)
+	if kv.IsErrNotFound(err) {
+		return false, 0, nil
+	}
+	if err != nil {
+		return false, 0, errors.Trace(err)
+	}
+
+	// For distinct index, the value of key is handle.
+	if distinct {
+		handle, err := tablecodec.DecodeIndexValueAsHandle(value)
+		if err != nil {
+			return false, 0, errors.Trace(err)
+		}
+
+		if handle != h {
+			return true, handle, errors.Trace(kv.ErrKeyExists)
+		}
+
+		return true, handle, nil
+	}
+
+	return true, h, nil
+}
+
+// FetchValues implements table.Index FetchValues interface.
+func (c *index) FetchValues(r []types.Datum, vals []types.Datum) ([]types.Datum, error) {
+	needLength := len(c.idxInfo.Columns)
+	if vals == nil || cap(vals) < needLength {
+		vals = make([]types.Datum, needLength)
+	}
+	vals = vals[:needLength]
+	for i, ic := range c.idxInfo.Columns {
+		if ic.Offset < 0 || ic.Offset >= len(r) {
+			return nil, table.ErrIndexOutBound.GenWithStack("Index column %s offset out of bound, offset: %d, row: %v",
+				ic.Name, ic.Offset, r)
+		}
+		vals[i] = r[ic.Offset]
+	}
+	return vals, nil
+}
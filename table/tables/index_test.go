@@ -0,0 +1,202 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tables
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/table"
+)
+
+// fuzzRounds is the number of random pairs compared for every kind of datum.
+const fuzzRounds = 5000
+
+// datumGen generates the random datums of a column type, the index keys of a column
+// are encoded from the values of the same type.
+type datumGen struct {
+	name    string
+	tp      byte
+	charset string
+	gen     func(r *rand.Rand) types.Datum
+}
+
+func randString(r *rand.Rand) string {
+	// A small alphabet with zero bytes makes the common prefixes and the padding of the
+	// group encoding likely.
+	b := make([]byte, r.Intn(20))
+	for i := range b {
+		b[i] = []byte{0, 1, 'a', 'b', 0xfe, 0xff}[r.Intn(6)]
+	}
+	return string(b)
+}
+
+var datumGens = []datumGen{
+	{"int", mysql.TypeLonglong, "", func(r *rand.Rand) types.Datum {
+		switch r.Intn(4) {
+		case 0:
+			return types.NewIntDatum([]int64{math.MinInt64, -1, 0, 1, math.MaxInt64}[r.Intn(5)])
+		case 1:
+			return types.NewIntDatum(r.Int63n(200) - 100)
+		}
+		return types.NewIntDatum(int64(r.Uint64()))
+	}},
+	{"uint", mysql.TypeLonglong, "", func(r *rand.Rand) types.Datum {
+		if r.Intn(4) == 0 {
+			return types.NewUintDatum([]uint64{0, 1, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64}[r.Intn(5)])
+		}
+		return types.NewUintDatum(r.Uint64())
+	}},
+	{"float", mysql.TypeDouble, "", func(r *rand.Rand) types.Datum {
+		switch r.Intn(4) {
+		case 0:
+			return types.NewFloat64Datum([]float64{-math.MaxFloat64, -1, 0, math.SmallestNonzeroFloat64, 1, math.MaxFloat64}[r.Intn(6)])
+		case 1:
+			return types.NewFloat64Datum(float64(r.Intn(200) - 100))
+		}
+		return types.NewFloat64Datum(r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20)))
+	}},
+	{"decimal(20,6)", mysql.TypeNewDecimal, "", func(r *rand.Rand) types.Datum {
+		s := fmt.Sprintf("%d.%06d", r.Int63n(1e14)-r.Int63n(1e14), r.Intn(1e6))
+		if r.Intn(3) == 0 {
+			s = fmt.Sprintf("%d.%d", r.Intn(20)-10, r.Intn(10))
+		}
+		dec := new(types.MyDecimal)
+		if err := dec.FromString([]byte(s)); err != nil {
+			panic(err)
+		}
+		d := types.NewDecimalDatum(dec)
+		d.SetLength(20)
+		d.SetFrac(6)
+		return d
+	}},
+	{"varbinary", mysql.TypeVarchar, charset.CharsetBin, func(r *rand.Rand) types.Datum {
+		return types.NewBytesDatum([]byte(randString(r)))
+	}},
+	{"varchar", mysql.TypeVarchar, charset.CharsetUTF8MB4, func(r *rand.Rand) types.Datum {
+		return types.NewStringDatum(randString(r))
+	}},
+	{"datetime(6)", mysql.TypeDatetime, "", func(r *rand.Rand) types.Datum {
+		t := types.FromDate(1000+r.Intn(9000), 1+r.Intn(12), 1+r.Intn(28), r.Intn(24), r.Intn(60), r.Intn(60), r.Intn(1000000))
+		return types.NewTimeDatum(types.Time{Time: t, Type: mysql.TypeDatetime, Fsp: 6})
+	}},
+	{"time(6)", mysql.TypeDuration, "", func(r *rand.Rand) types.Datum {
+		d := time.Duration(r.Int63n(int64(838*time.Hour))) - time.Duration(r.Int63n(int64(838*time.Hour)))
+		return types.NewDurationDatum(types.Duration{Duration: d, Fsp: 6})
+	}},
+}
+
+// maybeNull replaces the datum with NULL sometimes, a nullable column sorts NULL first.
+func maybeNull(r *rand.Rand, d types.Datum) types.Datum {
+	if r.Intn(20) == 0 {
+		return types.Datum{}
+	}
+	return d
+}
+
+func sign(c int) int {
+	switch {
+	case c < 0:
+		return -1
+	case c > 0:
+		return 1
+	}
+	return 0
+}
+
+func compareDatums(t *testing.T, sc *stmtctx.StatementContext, a, b []types.Datum) int {
+	for i := range a {
+		c, err := a[i].CompareDatum(sc, &b[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c != 0 {
+			return sign(c)
+		}
+	}
+	return 0
+}
+
+// newIndex returns the non-unique index of the columns generated by gens.
+func newIndex(gens []datumGen) table.Index {
+	tblInfo := &model.TableInfo{ID: 42}
+	idxInfo := &model.IndexInfo{ID: 1, Name: model.NewCIStr("idx"), State: model.StatePublic}
+	for i, g := range gens {
+		name := model.NewCIStr(fmt.Sprintf("c%d", i))
+		col := &model.ColumnInfo{ID: int64(i + 1), Name: name, Offset: i, State: model.StatePublic}
+		col.FieldType = *types.NewFieldType(g.tp)
+		col.Charset = g.charset
+		tblInfo.Columns = append(tblInfo.Columns, col)
+		idxInfo.Columns = append(idxInfo.Columns, &model.IndexColumn{Name: name, Offset: i, Length: types.UnspecifiedLength})
+	}
+	tblInfo.Indices = []*model.IndexInfo{idxInfo}
+	return NewIndex(tblInfo, idxInfo)
+}
+
+func indexKey(t *testing.T, sc *stmtctx.StatementContext, idx table.Index, values []types.Datum, handle int64) []byte {
+	key, _, err := idx.GenIndexKey(sc, values, handle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// TestIndexKeyOrder checks with random values that the order of the index keys is the
+// order of the datums, for the single column and the multiple column indexes.
+func TestIndexKeyOrder(t *testing.T) {
+	seed := time.Now().UnixNano()
+	r := rand.New(rand.NewSource(seed))
+	sc := &stmtctx.StatementContext{TimeZone: time.UTC}
+	for _, g := range datumGens {
+		for i := 0; i < fuzzRounds; i++ {
+			gens := []datumGen{g}
+			a := []types.Datum{maybeNull(r, g.gen(r))}
+			b := []types.Datum{maybeNull(r, g.gen(r))}
+			if r.Intn(2) == 0 {
+				// The second column of a composite index.
+				g2 := datumGens[r.Intn(len(datumGens))]
+				gens = append(gens, g2)
+				a = append(a, maybeNull(r, g2.gen(r)))
+				if r.Intn(2) == 0 {
+					b = append(b, a[1])
+				} else {
+					b = append(b, maybeNull(r, g2.gen(r)))
+				}
+			}
+			if r.Intn(4) == 0 {
+				b[0] = a[0]
+			}
+			expected := compareDatums(t, sc, a, b)
+			handleA, handleB := r.Int63n(3)-1, r.Int63n(3)-1
+			if expected == 0 {
+				expected = sign(int(handleA - handleB))
+			}
+			idx := newIndex(gens)
+			got := bytes.Compare(indexKey(t, sc, idx, a, handleA), indexKey(t, sc, idx, b, handleB))
+			if got != expected {
+				t.Fatalf("seed %d, %s: compare %v, %v and keys compare %d", seed, g.name, a, b, got)
+			}
+		}
+	}
+}
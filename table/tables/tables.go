@@ -0,0 +1,582 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2013 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/table/tables/tables.go
+//
+
+package tables
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	log "github.com/sirupsen/logrus"
+
+	"fedb/kv"
+	"fedb/meta/autoid"
+	"fedb/sessionctx"
+	"fedb/table"
+	"fedb/tablecodec"
+)
+
+// Table implements table.Table interface.
+type Table struct {
+	tableID int64
+	meta    *model.TableInfo
+	Columns []*table.Column
+
+	publicColumns   []*table.Column
+	writableColumns []*table.Column
+	indices         []table.Index
+	writableIndices []table.Index
+	recordPrefix    kv.Key
+	alloc           autoid.Allocator
+}
+
+var _ table.Table = (*Table)(nil)
+
+// TableFromMeta creates a Table instance from model.TableInfo. The allocator allocates the
+// handles and the auto_increment IDs of the new rows, it may be nil if no row is added to the table.
+func TableFromMeta(alloc autoid.Allocator, tblInfo *model.TableInfo) (table.Table, error) {
+	colsLen := len(tblInfo.Columns)
+	columns := make([]*table.Column, 0, colsLen)
+	for i, colInfo := range tblInfo.Columns {
+		// Print some information when the column's offset isn't equal to i.
+		if colInfo.Offset != i {
+			log.Errorf("[tables] table %#v schema is wrong, no.%d col %#v, cols len %v", tblInfo, i, tblInfo.Columns[i], colsLen)
+		}
+		columns = append(columns, table.ToColumn(colInfo))
+	}
+
+	t := &Table{
+		tableID:      tblInfo.ID,
+		meta:         tblInfo,
+		Columns:      columns,
+		recordPrefix: tablecodec.GenTableRecordPrefix(tblInfo.ID),
+		alloc:        alloc,
+	}
+	t.publicColumns = t.Cols()
+	t.writableColumns = t.WritableCols()
+	for _, idxInfo := range tblInfo.Indices {
+		if idxInfo.State == model.StateNone {
+			return nil, errors.Errorf("index %s can't be in none state", idxInfo.Name)
+		}
+		idx := NewIndex(tblInfo, idxInfo)
+		t.indices = append(t.indices, idx)
+		if idxInfo.State != model.StateDeleteOnly {
+			t.writableIndices = append(t.writableIndices, idx)
+		}
+	}
+	return t, nil
+}
+
+// Indices implements table.Table Indices interface.
+func (t *Table) Indices() []table.Index {
+	return t.indices
+}
+
+// WritableIndices implements table.Table WritableIndices interface.
+func (t *Table) WritableIndices() []table.Index {
+	return t.writableIndices
+}
+
+// Meta implements table.Table Meta interface.
+func (t *Table) Meta() *model.TableInfo {
+	return t.meta
+}
+
+// Cols implements table.Table Cols interface.
+func (t *Table) Cols() []*table.Column {
+	if len(t.publicColumns) > 0 {
+		return t.publicColumns
+	}
+	publicColumns := make([]*table.Column, len(t.Columns))
+	maxOffset := -1
+	for _, col := range t.Columns {
+		if col.State != model.StatePublic {
+			continue
+		}
+		publicColumns[col.Offset] = col
+		if maxOffset < col.Offset {
+			maxOffset = col.Offset
+		}
+	}
+	return publicColumns[0 : maxOffset+1]
+}
+
+// WritableCols implements table.Table WritableCols interface.
+func (t *Table) WritableCols() []*table.Column {
+	if len(t.writableColumns) > 0 {
+		return t.writableColumns
+	}
+	writableColumns := make([]*table.Column, len(t.Columns))
+	maxOffset := -1
+	for _, col := range t.Columns {
+		if col.State == model.StateDeleteOnly || col.State == model.StateDeleteReorganization {
+			continue
+		}
+		writableColumns[col.Offset] = col
+		if maxOffset < col.Offset {
+			maxOffset = col.Offset
+		}
+	}
+	return writableColumns[0 : maxOffset+1]
+}
+
+// RecordPrefix implements table.Table RecordPrefix interface.
+func (t *Table) RecordPrefix() kv.Key {
+	return t.recordPrefix
+}
+
+// RecordKey implements table.Table RecordKey interface.
+func (t *Table) RecordKey(h int64) kv.Key {
+	return tablecodec.EncodeRecordKey(t.recordPrefix, h)
+}
+
+// FirstKey implements table.Table FirstKey interface.
+func (t *Table) FirstKey() kv.Key {
+	return t.RecordKey(0)
+}
+
+// RowWithCols implements table.Table RowWithCols interface.
+func (t *Table) RowWithCols(ctx sessionctx.Context, h int64, cols []*table.Column) ([]types.Datum, error) {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	// Get raw row data from kv.
+	key := t.RecordKey(h)
+	value, err := txn.Get(key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	v, _, err := DecodeRawRowData(ctx, t.Meta(), h, cols, value)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return v, nil
+}
+
+// DecodeRawRowData decodes raw row data into a datum slice and a (columnID:columnValue) map.
+func DecodeRawRowData(ctx sessionctx.Context, meta *model.TableInfo, h int64, cols []*table.Column,
+	value []byte) ([]types.Datum, map[int64]types.Datum, error) {
+	v := make([]types.Datum, len(cols))
+	colTps := make(map[int64]*types.FieldType, len(cols))
+	for i, col := range cols {
+		if col == nil {
+			continue
+		}
+		if col.IsPKHandleColumn(meta) || col.ID == model.ExtraHandleID {
+			if mysql.HasUnsignedFlag(col.Flag) {
+				v[i].SetUint64(uint64(h))
+			} else {
+				v[i].SetInt64(h)
+			}
+			continue
+		}
+		colTps[col.ID] = &col.FieldType
+	}
+	rowMap, err := tablecodec.DecodeRow(value, colTps, time.Local)
+	if err != nil {
+		return nil, rowMap, errors.Trace(err)
+	}
+	defaultVals := make([]types.Datum, len(cols))
+	for i, col := range cols {
+		if col == nil {
+			continue
+		}
+		if col.IsPKHandleColumn(meta) || col.ID == model.ExtraHandleID {
+			continue
+		}
+		ri, ok := rowMap[col.ID]
+		if ok {
+			v[i] = ri
+			continue
+		}
+		v[i], err = GetColDefaultValue(ctx, col, i, defaultVals)
+		if err != nil {
+			return nil, rowMap, errors.Trace(err)
+		}
+	}
+	return v, rowMap, nil
+}
+
+// Row implements table.Table Row interface.
+func (t *Table) Row(ctx sessionctx.Context, h int64) ([]types.Datum, error) {
+	r, err := t.RowWithCols(ctx, h, t.Cols())
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return r, nil
+}
+
+// AddRecord implements table.Table AddRecord interface.
+func (t *Table) AddRecord(ctx sessionctx.Context, r []types.Datum) (recordID int64, err error) {
+	var hasRecordID bool
+	for _, col := range t.Cols() {
+		if col.IsPKHandleColumn(t.meta) {
+			recordID = r[col.Offset].GetInt64()
+			hasRecordID = true
+			break
+		}
+	}
+	if !hasRecordID {
+		alloc, err := t.allocator()
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		recordID, err = alloc.Alloc(t.tableID, 1, 1)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+
+	txn, err := ctx.Txn()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	// The entries of the row are buffered, so nothing is written if a unique key is duplicated.
+	bs := kv.NewBufferStore(txn, kv.DefaultTxnMembufCap)
+	if t.meta.PKIsHandle {
+		if err = CheckHandleExists(ctx, t, recordID); err != nil {
+			return recordID, errors.Trace(err)
+		}
+	}
+	for _, idx := range t.writableIndices {
+		vals, err := idx.FetchValues(r, nil)
+		if err != nil {
+			return 0, errors.Trace(err)
+		}
+		if err = t.buildIndexForRow(ctx, bs, recordID, vals, idx); err != nil {
+			return 0, errors.Trace(err)
+		}
+	}
+	if err = t.setRow(ctx, bs, recordID, r); err != nil {
+		return 0, errors.Trace(err)
+	}
+	if err = bs.SaveTo(txn); err != nil {
+		return 0, errors.Trace(err)
+	}
+	return recordID, nil
+}
+
+// UpdateRecord implements table.Table UpdateRecord interface.
+func (t *Table) UpdateRecord(ctx sessionctx.Context, h int64, oldData, newData []types.Datum, touched []bool) error {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if cols := t.WritableCols(); len(cols) > len(newData) {
+		// The values of the non-public columns are read from the row, which is written
+		// with the whole table. The columns missing in the row get their original default values.
+		value, err := txn.Get(t.RecordKey(h))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		oldRow, rowMap, err := DecodeRawRowData(ctx, t.meta, h, cols, value)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		newData = newData[:len(newData):len(newData)]
+		for _, col := range cols[len(newData):] {
+			v := oldRow[col.Offset]
+			if _, ok := rowMap[col.ID]; !ok {
+				if v, err = table.GetColOriginDefaultValue(ctx, col.ToInfo()); err != nil {
+					return errors.Trace(err)
+				}
+			}
+			newData = append(newData, v)
+		}
+	}
+	bs := kv.NewBufferStore(txn, kv.DefaultTxnMembufCap)
+	if err = t.rebuildIndices(ctx, bs, h, touched, oldData, newData); err != nil {
+		return errors.Trace(err)
+	}
+	if err = t.setRow(ctx, bs, h, newData); err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(bs.SaveTo(txn))
+}
+
+// rebuildIndices replaces the index entries of the row which contain the touched columns, the
+// indices in the delete only state have the old entries deleted only.
+func (t *Table) rebuildIndices(ctx sessionctx.Context, rm kv.RetrieverMutator, h int64, touched []bool,
+	oldData []types.Datum, newData []types.Datum) error {
+	sc := ctx.GetSessionVars().StmtCtx
+	var rebuilt []table.Index
+	for _, idx := range t.indices {
+		for _, ic := range idx.Meta().Columns {
+			if touched[ic.Offset] {
+				rebuilt = append(rebuilt, idx)
+				break
+			}
+		}
+	}
+	// All the old entries are removed first, so that the values swapped between the rows
+	// of a unique index are not reported as duplicated by mistake.
+	for _, idx := range rebuilt {
+		oldVs, err := idx.FetchValues(oldData, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = idx.Delete(sc, rm, oldVs, h); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	for _, idx := range rebuilt {
+		if idx.Meta().State == model.StateDeleteOnly {
+			continue
+		}
+		newVs, err := idx.FetchValues(newData, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = t.buildIndexForRow(ctx, rm, h, newVs, idx); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// setRow encodes the writable columns of the row into the record of handle h, the columns
+// which are not in r get their original default values.
+func (t *Table) setRow(ctx sessionctx.Context, m kv.Mutator, h int64, r []types.Datum) error {
+	cols := t.WritableCols()
+	colIDs := make([]int64, 0, len(cols))
+	row := make([]types.Datum, 0, len(cols))
+	for _, col := range cols {
+		var value types.Datum
+		if col.Offset < len(r) {
+			value = r[col.Offset]
+		} else {
+			var err error
+			if value, err = table.GetColOriginDefaultValue(ctx, col.ToInfo()); err != nil {
+				return errors.Trace(err)
+			}
+		}
+		if !CanSkip(t.meta, col, value) {
+			colIDs = append(colIDs, col.ID)
+			row = append(row, value)
+		}
+	}
+	value, err := tablecodec.EncodeRow(ctx.GetSessionVars().StmtCtx, row, colIDs, nil, nil)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return errors.Trace(m.Set(t.RecordKey(h), value))
+}
+
+// RemoveRecord implements table.Table RemoveRecord interface.
+func (t *Table) RemoveRecord(ctx sessionctx.Context, h int64, r []types.Datum) error {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err = txn.Delete(t.RecordKey(h)); err != nil {
+		return errors.Trace(err)
+	}
+	sc := ctx.GetSessionVars().StmtCtx
+	for _, idx := range t.indices {
+		vals, err := idx.FetchValues(r, nil)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err = idx.Delete(sc, txn, vals, h); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return nil
+}
+
+// AllocAutoID implements table.Table AllocAutoID interface.
+func (t *Table) AllocAutoID(ctx sessionctx.Context) (int64, error) {
+	alloc, err := t.allocator()
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	vars := ctx.GetSessionVars()
+	return alloc.Alloc(t.tableID, vars.AutoIncrementIncrement(), vars.AutoIncrementOffset())
+}
+
+// RebaseAutoID implements table.Table RebaseAutoID interface.
+func (t *Table) RebaseAutoID(newBase int64) error {
+	alloc, err := t.allocator()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return alloc.Rebase(t.tableID, newBase)
+}
+
+func (t *Table) allocator() (autoid.Allocator, error) {
+	if t.alloc == nil {
+		return nil, errors.Errorf("table %s has no allocator for the new rows", t.meta.Name)
+	}
+	return t.alloc, nil
+}
+
+func (t *Table) buildIndexForRow(ctx sessionctx.Context, rm kv.RetrieverMutator, h int64, vals []types.Datum, idx table.Index) error {
+	if _, err := idx.Create(ctx, rm, vals, h); err != nil {
+		if kv.ErrKeyExists.Equal(err) {
+			// Make error message consistent with MySQL.
+			entryKey, err1 := GenIndexKeyStr(vals)
+			if err1 != nil {
+				// if GenIndexKeyStr failed, return the original error.
+				return errors.Trace(err)
+			}
+
+			return kv.ErrKeyExists.FastGen("Duplicate entry '%s' for key '%s'", entryKey, idx.Meta().Name)
+		}
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// GenIndexKeyStr formats the values of an index entry for the duplicate entry errors.
+func GenIndexKeyStr(colVals []types.Datum) (string, error) {
+	strVals := make([]string, 0, len(colVals))
+	for _, cv := range colVals {
+		cvs := "NULL"
+		var err error
+		if !cv.IsNull() {
+			cvs, err = types.ToString(cv.GetValue())
+			if err != nil {
+				return "", errors.Trace(err)
+			}
+		}
+		strVals = append(strVals, cvs)
+	}
+	return strings.Join(strVals, "-"), nil
+}
+
+// CheckHandleExists checks whether the row of recordID exists, it returns the duplicate entry
+// error of the primary key if it does.
+func CheckHandleExists(ctx sessionctx.Context, t table.Table, recordID int64) error {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	_, err = txn.Get(t.RecordKey(recordID))
+	if err == nil {
+		return kv.ErrKeyExists.FastGen("Duplicate entry '%d' for key 'PRIMARY'", recordID)
+	} else if !kv.ErrNotExist.Equal(err) {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// IterRecords implements table.Table IterRecords interface, the values passed to fn
+// are in the order of cols.
+func (t *Table) IterRecords(ctx sessionctx.Context, startKey kv.Key, cols []*table.Column,
+	fn table.RecordIterFunc) error {
+	txn, err := ctx.Txn()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	prefix := t.RecordPrefix()
+	it, err := txn.Iter(startKey, prefix.PrefixNext())
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer it.Close()
+
+	if !it.Valid() {
+		return nil
+	}
+
+	log.Debugf("startKey:%q, key:%q, value:%q", startKey, it.Key(), it.Value())
+
+	colMap := make(map[int64]*types.FieldType)
+	for _, col := range cols {
+		colMap[col.ID] = &col.FieldType
+	}
+	defaultVals := make([]types.Datum, len(cols))
+	for it.Valid() && it.Key().HasPrefix(prefix) {
+		handle, err := tablecodec.DecodeRowKey(it.Key())
+		if err != nil {
+			return errors.Trace(err)
+		}
+		rowMap, err := tablecodec.DecodeRow(it.Value(), colMap, time.Local)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		data := make([]types.Datum, len(cols))
+		for i, col := range cols {
+			if col.IsPKHandleColumn(t.meta) || col.ID == model.ExtraHandleID {
+				if mysql.HasUnsignedFlag(col.Flag) {
+					data[i].SetUint64(uint64(handle))
+				} else {
+					data[i].SetInt64(handle)
+				}
+				continue
+			}
+			if _, ok := rowMap[col.ID]; ok {
+				data[i] = rowMap[col.ID]
+				continue
+			}
+			data[i], err = GetColDefaultValue(ctx, col, i, defaultVals)
+			if err != nil {
+				return errors.Trace(err)
+			}
+		}
+		more, err := fn(handle, data, cols)
+		if !more || err != nil {
+			return errors.Trace(err)
+		}
+
+		if err = it.Next(); err != nil {
+			return errors.Trace(err)
+		}
+	}
+
+	return nil
+}
+
+// GetColDefaultValue gets the value of a column which is missing in the row, the row
+// is written before the column is added. defaultVals caches the values by offset.
+func GetColDefaultValue(ctx sessionctx.Context, col *table.Column, offset int, defaultVals []types.Datum) (
+	colVal types.Datum, err error) {
+	if col.OriginDefaultValue == nil && mysql.HasNotNullFlag(col.Flag) {
+		return colVal, errors.New("Miss column")
+	}
+	if col.State != model.StatePublic {
+		return colVal, nil
+	}
+	if defaultVals[offset].IsNull() {
+		colVal, err = table.GetColOriginDefaultValue(ctx, col.ToInfo())
+		if err != nil {
+			return colVal, errors.Trace(err)
+		}
+		defaultVals[offset] = colVal
+	} else {
+		colVal = defaultVals[offset]
+	}
+
+	return colVal, nil
+}
+
+// CanSkip is for these cases, we can skip the columns in encoded row:
+// 1. the column is included in primary key;
+// 2. the column's default value is null, and the value equals to that.
+func CanSkip(info *model.TableInfo, col *table.Column, value types.Datum) bool {
+	if col.IsPKHandleColumn(info) {
+		return true
+	}
+	if col.GetDefaultValue() == nil && value.IsNull() {
+		return true
+	}
+	return false
+}
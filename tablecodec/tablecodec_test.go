@@ -0,0 +1,51 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package tablecodec
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// fuzzRounds is the number of random handles compared.
+const fuzzRounds = 5000
+
+// TestRowKeyOrder checks that the row keys are ordered by the handles and are decoded back.
+func TestRowKeyOrder(t *testing.T) {
+	seed := time.Now().UnixNano()
+	r := rand.New(rand.NewSource(seed))
+	for i := 0; i < fuzzRounds; i++ {
+		a, b := int64(r.Uint64()), int64(r.Uint64())
+		if r.Intn(4) == 0 {
+			a = []int64{math.MinInt64, -1, 0, 1, math.MaxInt64}[r.Intn(5)]
+		}
+		keyA, keyB := EncodeRowKeyWithHandle(42, a), EncodeRowKeyWithHandle(42, b)
+		expected := 0
+		if a < b {
+			expected = -1
+		} else if a > b {
+			expected = 1
+		}
+		if got := bytes.Compare(keyA, keyB); got != expected {
+			t.Fatalf("seed %d: handles %d, %d and keys compare %d", seed, a, b, got)
+		}
+		tableID, handle, err := DecodeRecordKey(keyA)
+		if err != nil || tableID != 42 || handle != a {
+			t.Fatalf("seed %d: decode %d got table %d handle %d error %v", seed, a, tableID, handle, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2015 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/util/admin/admin.go
+//
+
+package admin
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/pingcap/parser/model"
+
+	"fedb/kv"
+	"fedb/meta"
+)
+
+// DefNumHistoryJobs is the number of the history jobs which are shown with the jobs in the queue.
+const DefNumHistoryJobs = 10
+
+// GetDDLJobs returns the DDL jobs in the queue, in the order they are run.
+func GetDDLJobs(txn kv.Transaction) ([]*model.Job, error) {
+	jobs, err := meta.NewMeta(txn).GetAllDDLJobsInQueue()
+	return jobs, errors.Trace(err)
+}
+
+// GetHistoryDDLJobs returns the last finished DDL jobs, at most maxNumJobs jobs are returned.
+func GetHistoryDDLJobs(txn kv.Transaction, maxNumJobs int) ([]*model.Job, error) {
+	jobs, err := meta.NewMeta(txn).GetAllHistoryDDLJobs()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if len(jobs) > maxNumJobs {
+		jobs = jobs[len(jobs)-maxNumJobs:]
+	}
+	return jobs, nil
+}
+
+// CancelJobs marks the DDL jobs as cancelling, the worker cancels or rolls back them later.
+// It returns the result of every job, the error of the transaction is returned separately.
+func CancelJobs(txn kv.Transaction, ids []int64) ([]error, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	jobs, err := GetDDLJobs(txn)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	errs := make([]error, len(ids))
+	t := meta.NewMeta(txn)
+	for i, id := range ids {
+		found := false
+		for j, job := range jobs {
+			if id != job.ID {
+				continue
+			}
+			found = true
+			// These states can't be cancelled.
+			if job.IsDone() || job.IsSynced() {
+				errs[i] = errors.Errorf("This job:%v is finished, so can't be cancelled", id)
+				continue
+			}
+			// If the state is rolling back, it means the work is cleaning the data after cancelling the job.
+			if job.IsCancelled() || job.IsCancelling() || job.IsRollingback() || job.IsRollbackDone() {
+				errs[i] = errors.Errorf("This job:%v is cancelled or rolling back, so can't be cancelled", id)
+				continue
+			}
+			if !isJobRollbackable(job) {
+				errs[i] = errors.Errorf("This job:%v is in state %s, so can't be cancelled", id, job.SchemaState)
+				continue
+			}
+			job.State = model.JobStateCancelling
+			// The RawArgs isn't overwritten, the Args of the job are not decoded.
+			if err = t.UpdateDDLJob(int64(j), job, false); err != nil {
+				errs[i] = errors.Trace(err)
+			}
+		}
+		if !found {
+			errs[i] = errors.Errorf("Can't find this job:%v", id)
+		}
+	}
+	return errs, nil
+}
+
+// isJobRollbackable checks whether the changes of the job can be undone. The jobs which add an index
+// or a column are rolled back in any state, the others can only be cancelled before they start.
+func isJobRollbackable(job *model.Job) bool {
+	switch job.Type {
+	case model.ActionAddIndex, model.ActionAddColumn:
+		return true
+	}
+	return job.SchemaState == model.StateNone
+}
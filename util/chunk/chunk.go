@@ -0,0 +1,242 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/util/chunk/chunk.go
+//
+
+package chunk
+
+import (
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
+)
+
+// Chunk stores multiple rows of data in Apache Arrow format.
+// See https://arrow.apache.org/docs/memory_layout.html
+// Values are appended in compact format and can be directly accessed without decoding.
+// When the chunk is done processing, we can reuse the allocated memory by resetting it.
+type Chunk struct {
+	columns []*Column
+	// numVirtualRows indicates the number of virtual rows, which have zero Column.
+	// It is used only when this Chunk doesn't hold any data, i.e. "len(columns)==0".
+	numVirtualRows int
+	// capacity indicates the max number of rows this chunk can hold.
+	capacity int
+}
+
+// RecordBatch is the input parameter of the Executor.Next method.
+type RecordBatch struct {
+	*Chunk
+}
+
+// NewRecordBatch is used to construct a RecordBatch.
+func NewRecordBatch(chk *Chunk) *RecordBatch {
+	return &RecordBatch{chk}
+}
+
+// NewChunkWithCapacity creates a new chunk with field types and capacity.
+func NewChunkWithCapacity(fields []*types.FieldType, cap int) *Chunk {
+	return New(fields, cap, cap)
+}
+
+// New creates a new chunk.
+//
+//	cap: the limit for the max number of rows.
+//	maxChunkSize: the max limit for the number of rows.
+func New(fields []*types.FieldType, cap, maxChunkSize int) *Chunk {
+	chk := &Chunk{
+		columns:  make([]*Column, 0, len(fields)),
+		capacity: min(cap, maxChunkSize),
+	}
+	for _, f := range fields {
+		chk.columns = append(chk.columns, NewColumn(f, chk.capacity))
+	}
+	return chk
+}
+
+// Reset resets the chunk, so the memory it allocated can be reused.
+// Make sure all the data in the chunk is not used anymore before you reuse this chunk.
+func (c *Chunk) Reset() {
+	for _, col := range c.columns {
+		col.Reset()
+	}
+	c.numVirtualRows = 0
+}
+
+// Capacity returns the max number of rows the chunk holds.
+func (c *Chunk) Capacity() int {
+	return c.capacity
+}
+
+// IsFull returns if this chunk is considered full.
+func (c *Chunk) IsFull() bool {
+	return c.NumRows() >= c.capacity
+}
+
+// NumCols returns the number of columns in the chunk.
+func (c *Chunk) NumCols() int {
+	return len(c.columns)
+}
+
+// NumRows returns the number of rows in the chunk.
+func (c *Chunk) NumRows() int {
+	if c.NumCols() == 0 {
+		return c.numVirtualRows
+	}
+	return c.columns[0].Len()
+}
+
+// SetNumVirtualRows sets the virtual row number for a Chunk.
+// It should only be used when there exists no column in the Chunk.
+func (c *Chunk) SetNumVirtualRows(numVirtualRows int) {
+	c.numVirtualRows = numVirtualRows
+}
+
+// Column returns the specific column.
+func (c *Chunk) Column(colIdx int) *Column {
+	return c.columns[colIdx]
+}
+
+// SwapColumns swaps columns with another Chunk.
+func (c *Chunk) SwapColumns(other *Chunk) {
+	c.columns, other.columns = other.columns, c.columns
+	c.numVirtualRows, other.numVirtualRows = other.numVirtualRows, c.numVirtualRows
+}
+
+// GetRow gets the Row in the chunk with the row index.
+func (c *Chunk) GetRow(idx int) Row {
+	return Row{c: c, idx: idx}
+}
+
+// AppendRow appends a row to the chunk.
+func (c *Chunk) AppendRow(row Row) {
+	c.AppendPartialRow(0, row)
+	c.numVirtualRows++
+}
+
+// AppendPartialRow appends a row to the chunk, the columns of the row are appended
+// from the colIdx-th column.
+func (c *Chunk) AppendPartialRow(colIdx int, row Row) {
+	for i, rowCol := range row.c.columns {
+		c.columns[colIdx+i].appendRow(rowCol, row.idx)
+	}
+}
+
+// AppendJoinedRow appends a row whose columns are the ones of lhs followed by the ones of rhs.
+func (c *Chunk) AppendJoinedRow(lhs, rhs Row) {
+	c.AppendPartialRow(0, lhs)
+	c.AppendPartialRow(lhs.Len(), rhs)
+	c.numVirtualRows++
+}
+
+// Append appends rows in [begin, end) in another Chunk to a Chunk.
+func (c *Chunk) Append(other *Chunk, begin, end int) {
+	for colID, src := range other.columns {
+		dst := c.columns[colID]
+		for i := begin; i < end; i++ {
+			dst.appendRow(src, i)
+		}
+	}
+	c.numVirtualRows += end - begin
+}
+
+// TruncateTo truncates rows from tail to head in a Chunk to "numRows" rows.
+func (c *Chunk) TruncateTo(numRows int) {
+	for _, col := range c.columns {
+		if col.isFixed() {
+			elemLen := len(col.elemBuf)
+			col.data = col.data[:numRows*elemLen]
+		} else {
+			col.data = col.data[:col.offsets[numRows]]
+			col.offsets = col.offsets[:numRows+1]
+		}
+		for i := numRows; i < col.length; i++ {
+			if col.IsNull(i) {
+				col.nullCount--
+			}
+		}
+		col.length = numRows
+		bitmapLen := (col.length + 7) / 8
+		col.nullBitmap = col.nullBitmap[:bitmapLen]
+		if col.length%8 != 0 {
+			// When we append null, we simply increment the nullCount,
+			// so we need to clear the unused bits in the last bitmap byte.
+			lastByte := col.nullBitmap[bitmapLen-1]
+			unusedBitsLen := 8 - uint(col.length%8)
+			lastByte <<= unusedBitsLen
+			lastByte >>= unusedBitsLen
+			col.nullBitmap[bitmapLen-1] = lastByte
+		}
+	}
+	c.numVirtualRows = numRows
+}
+
+// AppendNull appends a null value to the chunk.
+func (c *Chunk) AppendNull(colIdx int) {
+	c.columns[colIdx].AppendNull()
+}
+
+// AppendInt64 appends a int64 value to the chunk.
+func (c *Chunk) AppendInt64(colIdx int, i int64) {
+	c.columns[colIdx].AppendInt64(i)
+}
+
+// AppendUint64 appends a uint64 value to the chunk.
+func (c *Chunk) AppendUint64(colIdx int, u uint64) {
+	c.columns[colIdx].AppendUint64(u)
+}
+
+// AppendFloat64 appends a float64 value to the chunk.
+func (c *Chunk) AppendFloat64(colIdx int, f float64) {
+	c.columns[colIdx].AppendFloat64(f)
+}
+
+// AppendString appends a string value to the chunk.
+func (c *Chunk) AppendString(colIdx int, str string) {
+	c.columns[colIdx].AppendString(str)
+}
+
+// AppendBytes appends a bytes value to the chunk.
+func (c *Chunk) AppendBytes(colIdx int, b []byte) {
+	c.columns[colIdx].AppendBytes(b)
+}
+
+// AppendMyDecimal appends a MyDecimal value to the chunk.
+func (c *Chunk) AppendMyDecimal(colIdx int, dec *types.MyDecimal) {
+	c.columns[colIdx].AppendMyDecimal(dec)
+}
+
+// AppendJSON appends a JSON value to the chunk.
+func (c *Chunk) AppendJSON(colIdx int, j json.BinaryJSON) {
+	c.columns[colIdx].AppendJSON(j)
+}
+
+// AppendDatum appends a datum into the chunk.
+func (c *Chunk) AppendDatum(colIdx int, d *types.Datum) {
+	c.columns[colIdx].AppendDatum(d)
+}
+
+// AppendDatumRow appends a row of datums whose kinds match the types of the columns.
+func (c *Chunk) AppendDatumRow(row []types.Datum) {
+	for i := range row {
+		c.columns[i].AppendDatum(&row[i])
+	}
+	c.numVirtualRows++
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,470 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2017 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/util/chunk/column.go
+//
+
+package chunk
+
+import (
+	"encoding/binary"
+	"time"
+	"unsafe"
+
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/types"
+	"github.com/pingcap/tidb/types/json"
+	"github.com/pingcap/tidb/util/hack"
+)
+
+// varElemLen indicates this column is a variable length column.
+const varElemLen = -1
+
+// getFixedLen returns the length of the values of the type, or varElemLen if the values
+// are of variable length.
+func getFixedLen(colType *types.FieldType) int {
+	switch colType.Tp {
+	case mysql.TypeFloat:
+		return 4
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong,
+		mysql.TypeLonglong, mysql.TypeDouble, mysql.TypeYear, mysql.TypeDuration:
+		return 8
+	case mysql.TypeDate, mysql.TypeDatetime, mysql.TypeTimestamp:
+		return 16
+	case mysql.TypeNewDecimal:
+		return types.MyDecimalStructSize
+	default:
+		return varElemLen
+	}
+}
+
+// Column stores the values of a column in a Chunk. A fixed length value is stored in
+// elemLen bytes of data, a variable length value is stored in data[offsets[i]:offsets[i+1]].
+// The i-th bit of nullBitmap is 0 if the i-th value is NULL.
+type Column struct {
+	length     int
+	nullCount  int
+	nullBitmap []byte
+	offsets    []int64
+	data       []byte
+	elemBuf    []byte
+}
+
+// NewColumn creates a column for the values of the type with initial capacity.
+func NewColumn(ft *types.FieldType, cap int) *Column {
+	elemLen := getFixedLen(ft)
+	if elemLen == varElemLen {
+		return newVarLenColumn(cap)
+	}
+	return newFixedLenColumn(elemLen, cap)
+}
+
+// newFixedLenColumn creates a fixed length column with elemLen and initial data capacity.
+func newFixedLenColumn(elemLen, cap int) *Column {
+	return &Column{
+		elemBuf:    make([]byte, elemLen),
+		data:       make([]byte, 0, cap*elemLen),
+		nullBitmap: make([]byte, 0, (cap+7)>>3),
+	}
+}
+
+// newVarLenColumn creates a variable length column with initial data capacity.
+func newVarLenColumn(cap int) *Column {
+	// The length of a value is unknown, 8 bytes is estimated for each.
+	return &Column{
+		offsets:    make([]int64, 1, cap+1),
+		data:       make([]byte, 0, cap*8),
+		nullBitmap: make([]byte, 0, (cap+7)>>3),
+	}
+}
+
+func (c *Column) isFixed() bool {
+	return c.elemBuf != nil
+}
+
+// Reset resets the column, the allocated memory is reused.
+func (c *Column) Reset() {
+	c.length = 0
+	c.nullCount = 0
+	c.nullBitmap = c.nullBitmap[:0]
+	if len(c.offsets) > 0 {
+		// The first offset is always 0, it makes slicing the data easier, we need to keep it.
+		c.offsets = c.offsets[:1]
+	}
+	c.data = c.data[:0]
+}
+
+// Len returns the number of the values in the column.
+func (c *Column) Len() int {
+	return c.length
+}
+
+// NullCount returns the number of the NULL values in the column.
+func (c *Column) NullCount() int {
+	return c.nullCount
+}
+
+// IsNull returns whether the rowIdx-th value is NULL.
+func (c *Column) IsNull(rowIdx int) bool {
+	nullByte := c.nullBitmap[rowIdx/8]
+	return nullByte&(1<<(uint(rowIdx)&7)) == 0
+}
+
+// SetNull sets the rowIdx-th value to NULL or not NULL.
+func (c *Column) SetNull(rowIdx int, isNull bool) {
+	if isNull == c.IsNull(rowIdx) {
+		return
+	}
+	if isNull {
+		c.nullBitmap[rowIdx>>3] &^= 1 << (uint(rowIdx) & 7)
+		c.nullCount++
+	} else {
+		c.nullBitmap[rowIdx>>3] |= 1 << (uint(rowIdx) & 7)
+		c.nullCount--
+	}
+}
+
+func (c *Column) appendNullBitmap(notNull bool) {
+	idx := c.length >> 3
+	if idx >= len(c.nullBitmap) {
+		c.nullBitmap = append(c.nullBitmap, 0)
+	}
+	if notNull {
+		pos := uint(c.length) & 7
+		c.nullBitmap[idx] |= byte(1 << pos)
+	} else {
+		c.nullCount++
+	}
+}
+
+// AppendNull appends a NULL value.
+func (c *Column) AppendNull() {
+	c.appendNullBitmap(false)
+	if c.isFixed() {
+		c.data = append(c.data, c.elemBuf...)
+	} else {
+		c.offsets = append(c.offsets, c.offsets[c.length])
+	}
+	c.length++
+}
+
+func (c *Column) finishAppendFixed() {
+	c.data = append(c.data, c.elemBuf...)
+	c.appendNullBitmap(true)
+	c.length++
+}
+
+func (c *Column) finishAppendVar() {
+	c.appendNullBitmap(true)
+	c.offsets = append(c.offsets, int64(len(c.data)))
+	c.length++
+}
+
+// AppendInt64 appends an int64 value.
+func (c *Column) AppendInt64(i int64) {
+	*(*int64)(unsafe.Pointer(&c.elemBuf[0])) = i
+	c.finishAppendFixed()
+}
+
+// AppendUint64 appends a uint64 value.
+func (c *Column) AppendUint64(u uint64) {
+	*(*uint64)(unsafe.Pointer(&c.elemBuf[0])) = u
+	c.finishAppendFixed()
+}
+
+// AppendFloat32 appends a float32 value.
+func (c *Column) AppendFloat32(f float32) {
+	*(*float32)(unsafe.Pointer(&c.elemBuf[0])) = f
+	c.finishAppendFixed()
+}
+
+// AppendFloat64 appends a float64 value.
+func (c *Column) AppendFloat64(f float64) {
+	*(*float64)(unsafe.Pointer(&c.elemBuf[0])) = f
+	c.finishAppendFixed()
+}
+
+// AppendString appends a string value.
+func (c *Column) AppendString(str string) {
+	c.data = append(c.data, str...)
+	c.finishAppendVar()
+}
+
+// AppendBytes appends a bytes value.
+func (c *Column) AppendBytes(b []byte) {
+	c.data = append(c.data, b...)
+	c.finishAppendVar()
+}
+
+// AppendTime appends a Time value.
+func (c *Column) AppendTime(t types.Time) {
+	writeTime(c.elemBuf, t)
+	c.finishAppendFixed()
+}
+
+// AppendDuration appends a Duration value.
+func (c *Column) AppendDuration(dur types.Duration) {
+	c.AppendInt64(int64(dur.Duration))
+}
+
+// AppendMyDecimal appends a MyDecimal value.
+func (c *Column) AppendMyDecimal(dec *types.MyDecimal) {
+	*(*types.MyDecimal)(unsafe.Pointer(&c.elemBuf[0])) = *dec
+	c.finishAppendFixed()
+}
+
+func (c *Column) appendNameValue(name string, val uint64) {
+	var buf [8]byte
+	*(*uint64)(unsafe.Pointer(&buf[0])) = val
+	c.data = append(c.data, buf[:]...)
+	c.data = append(c.data, name...)
+	c.finishAppendVar()
+}
+
+// AppendEnum appends an Enum value.
+func (c *Column) AppendEnum(enum types.Enum) {
+	c.appendNameValue(enum.Name, enum.Value)
+}
+
+// AppendSet appends a Set value.
+func (c *Column) AppendSet(set types.Set) {
+	c.appendNameValue(set.Name, set.Value)
+}
+
+// AppendJSON appends a JSON value.
+func (c *Column) AppendJSON(j json.BinaryJSON) {
+	c.data = append(c.data, j.TypeCode)
+	c.data = append(c.data, j.Value...)
+	c.finishAppendVar()
+}
+
+// AppendDatum appends a datum, the kind of the datum must match the type of the column.
+func (c *Column) AppendDatum(d *types.Datum) {
+	switch d.Kind() {
+	case types.KindNull:
+		c.AppendNull()
+	case types.KindInt64:
+		c.AppendInt64(d.GetInt64())
+	case types.KindUint64:
+		c.AppendUint64(d.GetUint64())
+	case types.KindFloat32:
+		c.AppendFloat32(d.GetFloat32())
+	case types.KindFloat64:
+		c.AppendFloat64(d.GetFloat64())
+	case types.KindString, types.KindBytes, types.KindBinaryLiteral, types.KindRaw, types.KindMysqlBit:
+		c.AppendBytes(d.GetBytes())
+	case types.KindMysqlDecimal:
+		c.AppendMyDecimal(d.GetMysqlDecimal())
+	case types.KindMysqlDuration:
+		c.AppendDuration(d.GetMysqlDuration())
+	case types.KindMysqlEnum:
+		c.AppendEnum(d.GetMysqlEnum())
+	case types.KindMysqlSet:
+		c.AppendSet(d.GetMysqlSet())
+	case types.KindMysqlTime:
+		c.AppendTime(d.GetMysqlTime())
+	case types.KindMysqlJSON:
+		c.AppendJSON(d.GetMysqlJSON())
+	}
+}
+
+// appendRow appends the rowIdx-th value of src.
+func (c *Column) appendRow(src *Column, rowIdx int) {
+	c.appendNullBitmap(!src.IsNull(rowIdx))
+	if src.isFixed() {
+		elemLen := len(src.elemBuf)
+		offset := rowIdx * elemLen
+		c.data = append(c.data, src.data[offset:offset+elemLen]...)
+	} else {
+		start, end := src.offsets[rowIdx], src.offsets[rowIdx+1]
+		c.data = append(c.data, src.data[start:end]...)
+		c.offsets = append(c.offsets, int64(len(c.data)))
+	}
+	c.length++
+}
+
+// CopyFrom replaces the values of the column with the ones of src, the columns must be of the same type.
+func (c *Column) CopyFrom(src *Column) {
+	c.length = src.length
+	c.nullCount = src.nullCount
+	c.nullBitmap = append(c.nullBitmap[:0], src.nullBitmap...)
+	c.data = append(c.data[:0], src.data...)
+	if !src.isFixed() {
+		c.offsets = append(c.offsets[:0], src.offsets...)
+	}
+}
+
+// resize sets the number of the fixed length values to n, the values are all NULL or all not NULL.
+func (c *Column) resize(n int, elemLen int, isNull bool) {
+	if cap(c.data) < n*elemLen {
+		c.data = make([]byte, n*elemLen)
+	} else {
+		c.data = c.data[:n*elemLen]
+	}
+	numBytes := (n + 7) >> 3
+	if cap(c.nullBitmap) < numBytes {
+		c.nullBitmap = make([]byte, numBytes)
+	} else {
+		c.nullBitmap = c.nullBitmap[:numBytes]
+	}
+	b := byte(0xff)
+	c.nullCount = 0
+	if isNull {
+		b = 0
+		c.nullCount = n
+	}
+	for i := range c.nullBitmap {
+		c.nullBitmap[i] = b
+	}
+	c.length = n
+}
+
+// ResizeInt64 sets the number of the int64 values to n, the values are all NULL or all not NULL.
+// The values can be set through Int64s.
+func (c *Column) ResizeInt64(n int, isNull bool) {
+	c.resize(n, 8, isNull)
+}
+
+// ResizeFloat64 sets the number of the float64 values to n, the values are all NULL or all not NULL.
+// The values can be set through Float64s.
+func (c *Column) ResizeFloat64(n int, isNull bool) {
+	c.resize(n, 8, isNull)
+}
+
+// Int64s returns the int64 values of the column, a NULL value is undefined.
+func (c *Column) Int64s() []int64 {
+	if c.length == 0 {
+		return nil
+	}
+	return (*[1 << 30]int64)(unsafe.Pointer(&c.data[0]))[:c.length:c.length]
+}
+
+// Uint64s returns the uint64 values of the column, a NULL value is undefined.
+func (c *Column) Uint64s() []uint64 {
+	if c.length == 0 {
+		return nil
+	}
+	return (*[1 << 30]uint64)(unsafe.Pointer(&c.data[0]))[:c.length:c.length]
+}
+
+// Float64s returns the float64 values of the column, a NULL value is undefined.
+func (c *Column) Float64s() []float64 {
+	if c.length == 0 {
+		return nil
+	}
+	return (*[1 << 30]float64)(unsafe.Pointer(&c.data[0]))[:c.length:c.length]
+}
+
+// GetInt64 returns the rowIdx-th value as an int64.
+func (c *Column) GetInt64(rowIdx int) int64 {
+	return *(*int64)(unsafe.Pointer(&c.data[rowIdx*8]))
+}
+
+// GetUint64 returns the rowIdx-th value as a uint64.
+func (c *Column) GetUint64(rowIdx int) uint64 {
+	return *(*uint64)(unsafe.Pointer(&c.data[rowIdx*8]))
+}
+
+// GetFloat32 returns the rowIdx-th value as a float32.
+func (c *Column) GetFloat32(rowIdx int) float32 {
+	return *(*float32)(unsafe.Pointer(&c.data[rowIdx*4]))
+}
+
+// GetFloat64 returns the rowIdx-th value as a float64.
+func (c *Column) GetFloat64(rowIdx int) float64 {
+	return *(*float64)(unsafe.Pointer(&c.data[rowIdx*8]))
+}
+
+// GetString returns the rowIdx-th value as a string, it shares the memory of the column.
+func (c *Column) GetString(rowIdx int) string {
+	return hack.String(c.GetBytes(rowIdx))
+}
+
+// GetBytes returns the rowIdx-th value as a bytes slice, it shares the memory of the column.
+func (c *Column) GetBytes(rowIdx int) []byte {
+	start, end := c.offsets[rowIdx], c.offsets[rowIdx+1]
+	return c.data[start:end]
+}
+
+// GetTime returns the rowIdx-th value as a Time.
+func (c *Column) GetTime(rowIdx int) types.Time {
+	return readTime(c.data[rowIdx*16:])
+}
+
+// GetDuration returns the rowIdx-th value as a Duration.
+func (c *Column) GetDuration(rowIdx int, fillFsp int) types.Duration {
+	dur := *(*int64)(unsafe.Pointer(&c.data[rowIdx*8]))
+	return types.Duration{Duration: time.Duration(dur), Fsp: fillFsp}
+}
+
+// GetMyDecimal returns the rowIdx-th value as a MyDecimal, it shares the memory of the column.
+func (c *Column) GetMyDecimal(rowIdx int) *types.MyDecimal {
+	return (*types.MyDecimal)(unsafe.Pointer(&c.data[rowIdx*types.MyDecimalStructSize]))
+}
+
+func (c *Column) getNameValue(rowIdx int) (string, uint64) {
+	start, end := c.offsets[rowIdx], c.offsets[rowIdx+1]
+	if start == end {
+		return "", 0
+	}
+	val := *(*uint64)(unsafe.Pointer(&c.data[start]))
+	name := hack.String(c.data[start+8 : end])
+	return name, val
+}
+
+// GetEnum returns the rowIdx-th value as an Enum.
+func (c *Column) GetEnum(rowIdx int) types.Enum {
+	name, val := c.getNameValue(rowIdx)
+	return types.Enum{Name: name, Value: val}
+}
+
+// GetSet returns the rowIdx-th value as a Set.
+func (c *Column) GetSet(rowIdx int) types.Set {
+	name, val := c.getNameValue(rowIdx)
+	return types.Set{Name: name, Value: val}
+}
+
+// GetJSON returns the rowIdx-th value as a BinaryJSON.
+func (c *Column) GetJSON(rowIdx int) json.BinaryJSON {
+	start, end := c.offsets[rowIdx], c.offsets[rowIdx+1]
+	return json.BinaryJSON{TypeCode: c.data[start], Value: c.data[start+1 : end]}
+}
+
+func writeTime(buf []byte, t types.Time) {
+	binary.BigEndian.PutUint16(buf, uint16(t.Time.Year()))
+	buf[2] = uint8(t.Time.Month())
+	buf[3] = uint8(t.Time.Day())
+	buf[4] = uint8(t.Time.Hour())
+	buf[5] = uint8(t.Time.Minute())
+	buf[6] = uint8(t.Time.Second())
+	binary.BigEndian.PutUint32(buf[8:], uint32(t.Time.Microsecond()))
+	buf[12] = t.Type
+	buf[13] = uint8(t.Fsp)
+}
+
+func readTime(buf []byte) types.Time {
+	year := int(binary.BigEndian.Uint16(buf))
+	month := int(buf[2])
+	day := int(buf[3])
+	hour := int(buf[4])
+	minute := int(buf[5])
+	second := int(buf[6])
+	microseconds := int(binary.BigEndian.Uint32(buf[8:]))
+	tp := buf[12]
+	fsp := int(buf[13])
+	return types.Time{
+		Time: types.FromDate(year, month, day, hour, minute, second, microseconds),
+		Type: tp,
+		Fsp:  fsp,
+	}
+}
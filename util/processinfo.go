@@ -0,0 +1,44 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// some code copied from Copyright 2016 PingCAP, Inc.
+// https://github.com/pingcap/tidb/blob/source-code/util/processinfo.go
+//
+
+package util
+
+import (
+	"time"
+)
+
+// ProcessInfo is the state of a connection, it's shown by the processlist.
+type ProcessInfo struct {
+	ID      uint64
+	User    string
+	Host    string
+	DB      string
+	Command string
+	// Time is the start time of the command.
+	Time  time.Time
+	State uint16
+	Info  string
+}
+
+// SessionManager is an interface for session manage. Show processlist and
+// kill statement rely on this interface.
+type SessionManager interface {
+	// ShowProcessList returns map[connectionID]ProcessInfo
+	ShowProcessList() map[uint64]ProcessInfo
+	// Kill kills the connection, when query is true, only the statement the
+	// connection is executing is interrupted, the connection itself is kept.
+	Kill(connectionID uint64, query bool)
+}
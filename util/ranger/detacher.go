@@ -0,0 +1,344 @@
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package ranger
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/charset"
+	"github.com/pingcap/parser/mysql"
+	"github.com/pingcap/tidb/sessionctx/stmtctx"
+	"github.com/pingcap/tidb/types"
+
+	"fedb/expression"
+)
+
+// DetachCondsForIndex builds the ranges of an index from the conditions, cols are the index columns
+// and a nil column ends the columns that can be used. lengths are the prefix lengths of the columns,
+// it can be nil if no column is indexed by a prefix. The ranges are built from the equal, IN and
+// IS NULL conditions on a prefix of the columns followed by the comparisons on the next column.
+// It returns the ranges in ascending order, the conditions used to build them and the conditions
+// that still need to be evaluated on the rows. If no condition can be used, accessConds is empty.
+// The conditions on a column indexed by a prefix are in both accessConds and filterConds, because
+// the ranges only limit the prefixes of the values.
+func DetachCondsForIndex(sc *stmtctx.StatementContext, conds []expression.Expression, cols []*expression.Column, lengths []int) (
+	ranges []*Range, accessConds, filterConds []expression.Expression) {
+	ranges = []*Range{{}}
+	used := make([]bool, len(conds))
+	// usedByPrefix marks the used conditions on the columns indexed by a prefix.
+	usedByPrefix := make([]bool, len(conds))
+	for i, col := range cols {
+		if col == nil {
+			break
+		}
+		length := types.UnspecifiedLength
+		if lengths != nil {
+			length = lengths[i]
+		}
+		if points, idx := detachPointCond(sc, conds, used, col); idx != -1 {
+			used[idx] = true
+			if length != types.UnspecifiedLength {
+				usedByPrefix[idx] = true
+				for j := range points {
+					points[j] = cutPrefix(points[j], length, col.RetType)
+				}
+				points = sortAndDedupPoints(sc, points)
+			}
+			ranges = appendPoints(ranges, points)
+			continue
+		}
+		prevUsed := append([]bool(nil), used...)
+		if iv, ok := detachIntervalConds(sc, conds, used, col); ok {
+			if length != types.UnspecifiedLength {
+				for j := range used {
+					usedByPrefix[j] = used[j] && !prevUsed[j]
+				}
+				// The values with the bounds as their prefixes are in the interval.
+				iv.low, iv.high = cutPrefix(iv.low, length, col.RetType), cutPrefix(iv.high, length, col.RetType)
+				iv.lowExclude, iv.highExclude = false, false
+			}
+			ranges = appendInterval(sc, ranges, iv)
+		}
+		break
+	}
+	for i, cond := range conds {
+		if used[i] {
+			accessConds = append(accessConds, cond)
+		}
+		if !used[i] || usedByPrefix[i] {
+			filterConds = append(filterConds, cond)
+		}
+	}
+	if len(accessConds) == 0 {
+		return nil, nil, conds
+	}
+	return ranges, accessConds, filterConds
+}
+
+// DetachCondsForTable builds the ranges of the int handle from the conditions on the handle column.
+// The bounds of the ranges are int64 values.
+func DetachCondsForTable(sc *stmtctx.StatementContext, conds []expression.Expression, pkCol *expression.Column) (
+	ranges []*Range, accessConds, filterConds []expression.Expression) {
+	ranges, accessConds, filterConds = DetachCondsForIndex(sc, conds, []*expression.Column{pkCol}, nil)
+	for _, ran := range ranges {
+		if ran.LowVal[0].Kind() == types.KindMinNotNull {
+			ran.LowVal[0] = types.NewIntDatum(math.MinInt64)
+		}
+		if ran.HighVal[0].Kind() == types.KindMaxValue {
+			ran.HighVal[0] = types.NewIntDatum(math.MaxInt64)
+		}
+	}
+	return ranges, accessConds, filterConds
+}
+
+// cutPrefix returns the prefix of a string value in the index, the length is the number of the
+// characters for the utf8 charsets, and the number of the bytes for the others.
+// See tables.TruncateIndexValuesIfNeeded.
+func cutPrefix(d types.Datum, length int, tp *types.FieldType) types.Datum {
+	if d.Kind() != types.KindString && d.Kind() != types.KindBytes {
+		return d
+	}
+	b := d.GetBytes()
+	if tp.Charset == charset.CharsetUTF8 || tp.Charset == charset.CharsetUTF8MB4 {
+		if utf8.RuneCount(b) <= length {
+			return d
+		}
+		b = []byte(string(bytes.Runes(b)[:length]))
+	} else {
+		if len(b) <= length {
+			return d
+		}
+		b = b[:length]
+	}
+	var cut types.Datum
+	if d.Kind() == types.KindString {
+		cut.SetString(string(b))
+	} else {
+		cut.SetBytes(b)
+	}
+	return cut
+}
+
+// interval is the values of a column between low and high.
+type interval struct {
+	low, high               types.Datum
+	lowExclude, highExclude bool
+}
+
+// detachPointCond finds the first unused condition that limits the column to a set of values,
+// it returns the sorted values and the offset of the condition.
+func detachPointCond(sc *stmtctx.StatementContext, conds []expression.Expression, used []bool, col *expression.Column) ([]types.Datum, int) {
+	for i, cond := range conds {
+		if used[i] {
+			continue
+		}
+		f, ok := cond.(*expression.ScalarFunction)
+		if !ok {
+			continue
+		}
+		args := f.GetArgs()
+		var points []types.Datum
+		switch f.FuncName.L {
+		case ast.EQ, ast.NullEQ:
+			c, _, ok := columnAndConstant(args, col)
+			if !ok || (c.Value.IsNull() && f.FuncName.L == ast.EQ) {
+				continue
+			}
+			if c.Value.IsNull() {
+				if mysql.HasNotNullFlag(col.RetType.Flag) {
+					continue
+				}
+				points = append(points, types.Datum{})
+				break
+			}
+			v, ok := ConvertLossless(sc, c.Value, col.RetType)
+			if !ok {
+				continue
+			}
+			points = append(points, v)
+		case ast.In:
+			if !args[0].Equal(col) {
+				continue
+			}
+			points = make([]types.Datum, 0, len(args)-1)
+			for _, arg := range args[1:] {
+				c, isConst := arg.(*expression.Constant)
+				if !isConst {
+					points = nil
+					break
+				}
+				// NULL in the list never matches.
+				if c.Value.IsNull() {
+					continue
+				}
+				v, ok := ConvertLossless(sc, c.Value, col.RetType)
+				if !ok {
+					points = nil
+					break
+				}
+				points = append(points, v)
+			}
+			if points == nil {
+				continue
+			}
+		case ast.IsNull:
+			if !args[0].Equal(col) || mysql.HasNotNullFlag(col.RetType.Flag) {
+				continue
+			}
+			points = append(points, types.Datum{})
+		default:
+			continue
+		}
+		return sortAndDedupPoints(sc, points), i
+	}
+	return nil, -1
+}
+
+// detachIntervalConds intersects the unused comparisons between the column and constants.
+func detachIntervalConds(sc *stmtctx.StatementContext, conds []expression.Expression, used []bool, col *expression.Column) (interval, bool) {
+	iv := interval{low: types.MinNotNullDatum(), high: types.MaxValueDatum()}
+	found := false
+	for i, cond := range conds {
+		if used[i] {
+			continue
+		}
+		f, ok := cond.(*expression.ScalarFunction)
+		if !ok {
+			continue
+		}
+		op := f.FuncName.L
+		switch op {
+		case ast.LT, ast.LE, ast.GT, ast.GE:
+		default:
+			continue
+		}
+		c, reversed, ok := columnAndConstant(f.GetArgs(), col)
+		if !ok || c.Value.IsNull() {
+			continue
+		}
+		v, ok := ConvertLossless(sc, c.Value, col.RetType)
+		if !ok {
+			continue
+		}
+		if reversed {
+			op = reverseOp[op]
+		}
+		switch op {
+		case ast.GT, ast.GE:
+			if cmp := compareDatum(sc, v, iv.low); cmp > 0 || (cmp == 0 && op == ast.GT) {
+				iv.low, iv.lowExclude = v, op == ast.GT
+			}
+		case ast.LT, ast.LE:
+			if cmp := compareDatum(sc, v, iv.high); cmp < 0 || (cmp == 0 && op == ast.LT) {
+				iv.high, iv.highExclude = v, op == ast.LT
+			}
+		}
+		used[i] = true
+		found = true
+	}
+	return iv, found
+}
+
+var reverseOp = map[string]string{
+	ast.LT: ast.GT,
+	ast.LE: ast.GE,
+	ast.GT: ast.LT,
+	ast.GE: ast.LE,
+}
+
+// columnAndConstant checks whether the arguments are the column and a constant,
+// reversed is true if the constant is the first argument.
+func columnAndConstant(args []expression.Expression, col *expression.Column) (c *expression.Constant, reversed bool, ok bool) {
+	if len(args) != 2 {
+		return nil, false, false
+	}
+	if args[0].Equal(col) {
+		c, ok = args[1].(*expression.Constant)
+		return c, false, ok
+	}
+	if args[1].Equal(col) {
+		c, ok = args[0].(*expression.Constant)
+		return c, true, ok
+	}
+	return nil, false, false
+}
+
+// ConvertLossless converts the value to the type of the column, the conversion fails if the
+// converted value is not equal to the original one, like 1.5 for an int column.
+func ConvertLossless(sc *stmtctx.StatementContext, v types.Datum, tp *types.FieldType) (types.Datum, bool) {
+	// The warnings of the conversion are not reported to the user.
+	localSc := &stmtctx.StatementContext{TimeZone: sc.TimeZone}
+	casted, err := v.ConvertTo(localSc, tp)
+	if err != nil || casted.IsNull() {
+		return casted, false
+	}
+	cmp, err := v.CompareDatum(localSc, &casted)
+	if err != nil || cmp != 0 {
+		return casted, false
+	}
+	return casted, true
+}
+
+func compareDatum(sc *stmtctx.StatementContext, a, b types.Datum) int {
+	cmp, err := a.CompareDatum(sc, &b)
+	if err != nil {
+		return 0
+	}
+	return cmp
+}
+
+func sortAndDedupPoints(sc *stmtctx.StatementContext, points []types.Datum) []types.Datum {
+	sort.SliceStable(points, func(i, j int) bool {
+		return compareDatum(sc, points[i], points[j]) < 0
+	})
+	result := points[:0]
+	for i, p := range points {
+		if i > 0 && compareDatum(sc, p, result[len(result)-1]) == 0 {
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+func appendPoints(ranges []*Range, points []types.Datum) []*Range {
+	newRanges := make([]*Range, 0, len(ranges)*len(points))
+	for _, ran := range ranges {
+		for _, p := range points {
+			newRange := ran.Clone()
+			newRange.LowVal = append(newRange.LowVal, p)
+			newRange.HighVal = append(newRange.HighVal, p)
+			newRanges = append(newRanges, newRange)
+		}
+	}
+	return newRanges
+}
+
+func appendInterval(sc *stmtctx.StatementContext, ranges []*Range, iv interval) []*Range {
+	cmp := compareDatum(sc, iv.low, iv.high)
+	if cmp > 0 || (cmp == 0 && (iv.lowExclude || iv.highExclude)) {
+		// The conditions can never be satisfied.
+		return ranges[:0]
+	}
+	for _, ran := range ranges {
+		ran.LowVal = append(ran.LowVal, iv.low)
+		ran.HighVal = append(ran.HighVal, iv.high)
+		ran.LowExclude, ran.HighExclude = iv.lowExclude, iv.highExclude
+	}
+	return ranges
+}
@@ -16,18 +16,34 @@
 
 package sqlexec
 
+import (
+	"github.com/pingcap/parser/ast"
+	goctx "golang.org/x/net/context"
+
+	"fedb/util/chunk"
+)
+
 // RecordSet is an abstract result set interface to help get data from Plan.
 type RecordSet interface {
 	// Fields gets result fields.
-	//Fields() []*ast.ResultField
+	Fields() []*ast.ResultField
 
-	// Next reads records into chunk.
-	//Next(ctx context.Context, req *chunk.RecordBatch) error
+	// Next reads records into the batch, an empty batch means there is no more to return.
+	Next(ctx goctx.Context, req *chunk.RecordBatch) error
 
-	//NewRecordBatch create a recordBatch.
-	//NewRecordBatch() *chunk.RecordBatch
+	// NewRecordBatch creates a batch that fits the records.
+	NewRecordBatch() *chunk.RecordBatch
 
 	// Close closes the underlying iterator, call Next after Close will
 	// restart the iteration.
-	//Close() error
+	Close() error
+}
+
+// RestrictedSQLExecutor is an interface provides executing the internal SQL statements.
+// Why we need this interface? To break circle dependence of packages, the privilege
+// package loads the privilege tables by SQL without importing the session package.
+type RestrictedSQLExecutor interface {
+	// ExecRestrictedSQL runs a statement with the arguments bound to its parameter
+	// markers, and returns all the rows it returns.
+	ExecRestrictedSQL(ctx goctx.Context, sql string, args ...interface{}) ([]chunk.Row, []*ast.ResultField, error)
 }